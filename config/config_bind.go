@@ -0,0 +1,156 @@
+// Configuration struct binding
+//
+// Bind populates the fields of a service configuration struct from the configuration variables, using struct tags:
+//
+//	type ServiceConfig struct {
+//		ListenAddr  string        `env:"LISTEN_ADDR" default:":8080"`
+//		DatabaseUri string        `env:"DATABASE_URI" required:"true"`
+//		Timeout     time.Duration `env:"TIMEOUT" default:"30s"`
+//		Hosts       []string      `env:"HOSTS"`                  // comma-separated
+//		Cache       CacheConfig   `envPrefix:"CACHE_"`           // nested struct, keys are prefixed
+//	}
+//
+//	cfg := ServiceConfig{}
+//	if err := config.Bind(&cfg); err != nil { ... }
+//
+// The value of each field is resolved in the following order: environment variable, configuration variable (loaded
+// from files or remote providers, see Load), and finally the default tag value. Fields without env tag are ignored
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Bind populates the configuration struct using the singleton configuration instance
+func Bind(target any) error {
+	return Get().Bind(target)
+}
+
+// Bind populates the configuration struct fields from the configuration variables by the struct tags
+// All the missing required fields and invalid values are reported in the returned error
+func (c *BaseConfig) Bind(target any) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind target must be a non-nil pointer to struct, got %T", target)
+	}
+
+	errs := make([]string, 0)
+	c.bindStruct("", value.Elem(), &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("configuration binding failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// bind the struct fields, nested structs (without env tag) are bound recursively
+func (c *BaseConfig) bindStruct(prefix string, value reflect.Value, errs *[]string) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		key, hasKey := field.Tag.Lookup("env")
+		if !hasKey {
+			if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+				c.bindStruct(prefix+field.Tag.Get("envPrefix"), value.Field(i), errs)
+			}
+			continue
+		}
+		key = prefix + key
+
+		raw, found := c.lookup(key)
+		if !found {
+			raw, found = field.Tag.Lookup("default")
+		}
+		if !found {
+			if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+				*errs = append(*errs, fmt.Sprintf("%s is required", key))
+			}
+			continue
+		}
+
+		if err := setFieldValue(value.Field(i), raw); err != nil {
+			*errs = append(*errs, fmt.Sprintf("%s: %s", key, err.Error()))
+		}
+	}
+}
+
+// lookup the key in the environment variables and then in the configuration variables
+func (c *BaseConfig) lookup(key string) (string, bool) {
+	if v := os.Getenv(key); len(v) > 0 {
+		return v, true
+	}
+	if v := c.GetStringParamValueOrDefault(key, ""); len(v) > 0 {
+		return v, true
+	}
+	return "", false
+}
+
+// set the field value from its string representation
+func setFieldValue(field reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+	case reflect.Slice:
+		items := make([]string, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFieldValue(list.Index(i), item); err != nil {
+				return err
+			}
+		}
+		field.Set(list)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Configuration loaders
+//
+// The configuration variables are loaded from the following sources, each source overrides the values of the previous:
+//
+//  1. Default values of the base configuration (and values added by the concrete service configuration)
+//  2. Configuration files (JSON / YAML) by the order they are listed
+//  3. Environment files (.env) by the order they are listed
+//  4. Remote providers (e.g. secret manager, distributed cache) by the order they are listed
+//  5. Environment variables
+//
+// Nested keys in configuration files are flattened to upper case keys joined by underscore, for example:
+//
+//	database:
+//	  uri: postgres://localhost:5432/db    # DATABASE_URI
+//	  hosts: [host-1, host-2]              # DATABASE_HOSTS = "host-1,host-2"
+//
+// Keys which are loaded from files or remote providers are added to the configuration, so the environment variables
+// with the same name override them as well
+
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IRemoteConfigProvider is a remote source of configuration variables (e.g. GCP Secret Manager, IDataCache hash)
+type IRemoteConfigProvider interface {
+	// Name of the provider (used in error messages)
+	Name() string
+
+	// Fetch returns the configuration variables map of key -> value
+	Fetch() (map[string]string, error)
+}
+
+// RemoteProviderFunc adapts a function to IRemoteConfigProvider, for example a provider backed by IDataCache hash:
+//
+//	provider := config.RemoteProviderFunc("datacache", func() (map[string]string, error) {
+//		raw, err := dc.HGetRawAll("service-config") // field name -> value
+//		...
+//	})
+func RemoteProviderFunc(name string, fetch func() (map[string]string, error)) IRemoteConfigProvider {
+	return &remoteProviderFunc{name: name, fetch: fetch}
+}
+
+type remoteProviderFunc struct {
+	name  string
+	fetch func() (map[string]string, error)
+}
+
+func (p *remoteProviderFunc) Name() string                      { return p.name }
+func (p *remoteProviderFunc) Fetch() (map[string]string, error) { return p.fetch() }
+
+// LoaderOptions lists the configuration sources to load
+type LoaderOptions struct {
+	Files           []string                // Configuration files (.json, .yaml, .yml)
+	EnvFiles        []string                // Environment files (.env)
+	RemoteProviders []IRemoteConfigProvider // Remote configuration providers
+	IgnoreMissing   bool                    // Ignore files which do not exist
+}
+
+// Load the configuration sources by the documented precedence order and finally apply the environment variables
+func (c *BaseConfig) Load(options LoaderOptions) error {
+	for _, file := range options.Files {
+		if err := c.LoadFile(file); err != nil {
+			if options.IgnoreMissing && os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+	for _, file := range options.EnvFiles {
+		if err := c.LoadEnvFile(file); err != nil {
+			if options.IgnoreMissing && os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+	}
+	for _, provider := range options.RemoteProviders {
+		if err := c.LoadRemote(provider); err != nil {
+			return err
+		}
+	}
+	c.ScanEnvVariables()
+	return nil
+}
+
+// LoadFile loads configuration variables from JSON or YAML file (by the file extension)
+func (c *BaseConfig) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	content := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &content)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &content)
+	default:
+		return fmt.Errorf("unsupported configuration file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse configuration file %s: %w", path, err)
+	}
+
+	vars := make(map[string]string)
+	flattenConfig("", content, vars)
+	c.addConfigVars(vars)
+	return nil
+}
+
+// LoadEnvFile loads configuration variables from .env file
+// Each line is in the format of KEY=VALUE (optionally prefixed by "export"), empty lines and lines starting with # are
+// ignored, and values may be quoted with single or double quotes
+func (c *BaseConfig) LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimSpace(strings.TrimPrefix(text, "export "))
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("%s line %d: missing '='", path, line)
+		}
+		vars[strings.TrimSpace(key)] = parseEnvValue(strings.TrimSpace(value))
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	c.addConfigVars(vars)
+	return nil
+}
+
+// LoadRemote loads configuration variables from remote provider
+func (c *BaseConfig) LoadRemote(provider IRemoteConfigProvider) error {
+	vars, err := provider.Fetch()
+	if err != nil {
+		return fmt.Errorf("load configuration from %s: %w", provider.Name(), err)
+	}
+	c.addConfigVars(vars)
+	return nil
+}
+
+// add configuration variables
+func (c *BaseConfig) addConfigVars(vars map[string]string) {
+	for key, value := range vars {
+		c.AddConfigVar(key, value)
+	}
+}
+
+// unquote .env value or strip inline comment of unquoted value
+func parseEnvValue(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			unquoted := value[1 : len(value)-1]
+			if value[0] == '"' {
+				unquoted = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(unquoted)
+			}
+			return unquoted
+		}
+	}
+	if idx := strings.Index(value, " #"); idx >= 0 {
+		value = strings.TrimSpace(value[:idx])
+	}
+	return value
+}
+
+// flatten nested configuration to upper case keys joined by underscore, lists are joined by comma
+func flattenConfig(prefix string, value any, vars map[string]string) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			name := strings.ToUpper(key)
+			if len(prefix) > 0 {
+				name = prefix + "_" + name
+			}
+			flattenConfig(name, v[key], vars)
+		}
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprintf("%v", item))
+		}
+		vars[prefix] = strings.Join(items, ",")
+	case float64:
+		vars[prefix] = strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		vars[prefix] = ""
+	default:
+		vars[prefix] = fmt.Sprintf("%v", v)
+	}
+}
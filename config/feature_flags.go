@@ -0,0 +1,266 @@
+// Feature flags
+//
+// Feature flags are loaded from providers (environment variables, distributed cache etc.) and evaluated per tenant:
+//
+//	flags := config.NewFeatureFlags(config.NewEnvFeatureFlagProvider("FF_"))
+//	if flags.IsEnabled("NEW_REPORTS", tenantId) { ... }
+//	limit := flags.GetInt("EXPORT_LIMIT", tenantId, 1000)
+//
+// Environment variable flags are defined in a compact format (see ParseFeatureFlag), for example:
+//
+//	FF_NEW_REPORTS=true             # enabled for all tenants
+//	FF_NEW_UI=25%                   # enabled for 25% of the tenants
+//	FF_BETA=tenants:acme,globex     # enabled only for the listed tenants
+//	FF_EXPORT_LIMIT=5000            # enabled with int variant
+//	FF_THEME=dark                   # enabled with string variant
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// FeatureFlag is the definition of a single feature flag
+type FeatureFlag struct {
+	Name       string   `json:"name"`       // Flag name
+	Enabled    bool     `json:"enabled"`    // Flag global switch
+	Percentage int      `json:"percentage"` // Percentage of tenants the flag is enabled for (0 or 100: all the tenants)
+	Tenants    []string `json:"tenants"`    // Tenants the flag is always enabled for (if percentage is 0, only for these tenants)
+	Value      string   `json:"value"`      // Variant value of the flag (string / int / bool)
+}
+
+// IsEnabledFor evaluates the flag for the tenant
+// The tenant percentage bucket is stable: it is calculated from the hash of the flag name and the tenant id
+func (f FeatureFlag) IsEnabledFor(tenantId string) bool {
+	if !f.Enabled {
+		return false
+	}
+	for _, t := range f.Tenants {
+		if t == tenantId {
+			return true
+		}
+	}
+	if f.Percentage > 0 && f.Percentage < 100 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(f.Name + ":" + tenantId))
+		return int(h.Sum32()%100) < f.Percentage
+	}
+	return len(f.Tenants) == 0 || f.Percentage >= 100
+}
+
+// ParseFeatureFlag parses the compact flag format: "true" / "false", "25%", "tenants:a,b" or a variant value
+func ParseFeatureFlag(name, raw string) FeatureFlag {
+	raw = strings.TrimSpace(raw)
+	flag := FeatureFlag{Name: name, Enabled: true}
+
+	switch lower := strings.ToLower(raw); {
+	case lower == "true" || lower == "on":
+	case lower == "false" || lower == "off" || len(lower) == 0:
+		flag.Enabled = false
+	case strings.HasSuffix(lower, "%"):
+		if p, err := strconv.Atoi(strings.TrimSuffix(lower, "%")); err == nil {
+			flag.Percentage = p
+			flag.Enabled = p > 0
+		} else {
+			flag.Value = raw
+		}
+	case strings.HasPrefix(lower, "tenants:"):
+		for _, t := range strings.Split(raw[len("tenants:"):], ",") {
+			if t = strings.TrimSpace(t); len(t) > 0 {
+				flag.Tenants = append(flag.Tenants, t)
+			}
+		}
+	default:
+		flag.Value = raw
+	}
+	return flag
+}
+
+// region Feature flags providers --------------------------------------------------------------------------------------
+
+// IFeatureFlagProvider is a source of feature flags definitions
+type IFeatureFlagProvider interface {
+	// GetFlags returns the flags definitions by flag name
+	GetFlags() (map[string]FeatureFlag, error)
+}
+
+// FeatureFlagProviderFunc adapts a function to IFeatureFlagProvider (for flags stored in the data cache, see
+// database.NewDataCacheFeatureFlagProvider)
+type FeatureFlagProviderFunc func() (map[string]FeatureFlag, error)
+
+// GetFlags returns the flags definitions
+func (f FeatureFlagProviderFunc) GetFlags() (map[string]FeatureFlag, error) {
+	return f()
+}
+
+// envFeatureFlagProvider loads feature flags from environment variables
+type envFeatureFlagProvider struct {
+	prefix string
+}
+
+// NewEnvFeatureFlagProvider creates a provider of the flags defined by environment variables with the given prefix
+// (the flag name is the variable name without the prefix)
+func NewEnvFeatureFlagProvider(prefix string) IFeatureFlagProvider {
+	return &envFeatureFlagProvider{prefix: prefix}
+}
+
+// GetFlags returns the flags defined by environment variables
+func (p *envFeatureFlagProvider) GetFlags() (map[string]FeatureFlag, error) {
+	flags := make(map[string]FeatureFlag)
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if name, ok := strings.CutPrefix(key, p.prefix); ok && len(name) > 0 {
+			flags[name] = ParseFeatureFlag(name, value)
+		}
+	}
+	return flags, nil
+}
+
+// endregion
+
+// region Feature flags component --------------------------------------------------------------------------------------
+
+// FeatureFlagChangeListener is notified when a flag is added, changed or removed (removed flag has empty name)
+type FeatureFlagChangeListener func(name string, previous, current FeatureFlag)
+
+// FeatureFlags evaluates feature flags loaded from providers, providers listed later override the previous ones
+type FeatureFlags struct {
+	mu        sync.RWMutex
+	providers []IFeatureFlagProvider
+	flags     map[string]FeatureFlag
+	listeners []FeatureFlagChangeListener
+}
+
+// NewFeatureFlags creates the feature flags component and loads the flags from the providers
+func NewFeatureFlags(providers ...IFeatureFlagProvider) *FeatureFlags {
+	ff := &FeatureFlags{providers: providers, flags: make(map[string]FeatureFlag)}
+	if err := ff.Refresh(); err != nil {
+		logger.Warn("load feature flags failed: %s", err.Error())
+	}
+	return ff
+}
+
+// OnChange registers listener to the flags changes (detected by Refresh)
+func (ff *FeatureFlags) OnChange(listener FeatureFlagChangeListener) {
+	ff.mu.Lock()
+	defer ff.mu.Unlock()
+	ff.listeners = append(ff.listeners, listener)
+}
+
+// Refresh reloads the flags from the providers and notifies the listeners about changed flags
+// If a provider fails, the current flags are kept
+func (ff *FeatureFlags) Refresh() error {
+	flags := make(map[string]FeatureFlag)
+	for _, provider := range ff.providers {
+		list, err := provider.GetFlags()
+		if err != nil {
+			return fmt.Errorf("load feature flags: %w", err)
+		}
+		for name, flag := range list {
+			flag.Name = name
+			flags[name] = flag
+		}
+	}
+
+	ff.mu.Lock()
+	previous := ff.flags
+	ff.flags = flags
+	listeners := ff.listeners
+	ff.mu.Unlock()
+
+	for name, current := range flags {
+		if prev, ok := previous[name]; !ok || !equalFlags(prev, current) {
+			for _, listener := range listeners {
+				listener(name, prev, current)
+			}
+		}
+	}
+	for name, prev := range previous {
+		if _, ok := flags[name]; !ok {
+			for _, listener := range listeners {
+				listener(name, prev, FeatureFlag{})
+			}
+		}
+	}
+	return nil
+}
+
+// StartRefresh refreshes the flags periodically until the context is done
+func (ff *FeatureFlags) StartRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ff.Refresh(); err != nil {
+					logger.Warn("refresh feature flags failed: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Flag returns the flag definition
+func (ff *FeatureFlags) Flag(name string) (FeatureFlag, bool) {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+	flag, ok := ff.flags[name]
+	return flag, ok
+}
+
+// IsEnabled checks if the flag is enabled for the tenant (undefined flags are disabled)
+func (ff *FeatureFlags) IsEnabled(name, tenantId string) bool {
+	flag, ok := ff.Flag(name)
+	return ok && flag.IsEnabledFor(tenantId)
+}
+
+// GetString returns the flag string variant for the tenant, or the default value if the flag is disabled or has no value
+func (ff *FeatureFlags) GetString(name, tenantId, defaultValue string) string {
+	if flag, ok := ff.Flag(name); ok && len(flag.Value) > 0 && flag.IsEnabledFor(tenantId) {
+		return flag.Value
+	}
+	return defaultValue
+}
+
+// GetInt returns the flag int variant for the tenant, or the default value if the flag is disabled or not an int
+func (ff *FeatureFlags) GetInt(name, tenantId string, defaultValue int) int {
+	if v, err := strconv.Atoi(ff.GetString(name, tenantId, "")); err == nil {
+		return v
+	}
+	return defaultValue
+}
+
+// GetBool returns the flag bool variant for the tenant, or the default value if the flag is disabled or not a bool
+func (ff *FeatureFlags) GetBool(name, tenantId string, defaultValue bool) bool {
+	if v, err := strconv.ParseBool(ff.GetString(name, tenantId, "")); err == nil {
+		return v
+	}
+	return defaultValue
+}
+
+// compare flags definitions
+func equalFlags(a, b FeatureFlag) bool {
+	if a.Enabled != b.Enabled || a.Percentage != b.Percentage || a.Value != b.Value || len(a.Tenants) != len(b.Tenants) {
+		return false
+	}
+	for i := range a.Tenants {
+		if a.Tenants[i] != b.Tenants[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// endregion
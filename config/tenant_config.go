@@ -0,0 +1,160 @@
+// Per-tenant configuration overrides
+//
+// Tenant specific values (e.g. quotas and feature settings) are stored in a tenant config store and layered above the
+// service configuration, values which are not overridden for the tenant fall back to the service configuration:
+//
+//	config.Get().SetTenantStore(store)
+//	quota := config.Get().ForTenant(tenantId).GetInt("MAX_USERS", 100)
+
+package config
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// ITenantConfigStore is the store of the tenants configuration overrides (see database.NewDataCacheTenantConfigStore
+// and database.NewDatabaseTenantConfigStore for stores backed by IDataCache hash per tenant and by IDatabase table)
+type ITenantConfigStore interface {
+	// GetTenantVars returns the configuration overrides of the tenant as map of key -> value
+	GetTenantVars(tenantId string) (map[string]string, error)
+}
+
+// TenantConfigStoreFunc adapts a function to ITenantConfigStore, for example a store backed by a remote service:
+//
+//	store := config.TenantConfigStoreFunc(func(tenantId string) (map[string]string, error) {
+//		return client.GetTenantSettings(ctx, tenantId)
+//	})
+type TenantConfigStoreFunc func(tenantId string) (map[string]string, error)
+
+// GetTenantVars returns the configuration overrides of the tenant
+func (f TenantConfigStoreFunc) GetTenantVars(tenantId string) (map[string]string, error) {
+	return f(tenantId)
+}
+
+// region In memory tenant config store --------------------------------------------------------------------------------
+
+// InMemoryTenantConfigStore is in memory implementation of ITenantConfigStore (for testing and static overrides)
+type InMemoryTenantConfigStore struct {
+	mu      sync.RWMutex
+	tenants map[string]map[string]string
+}
+
+// NewInMemoryTenantConfigStore Factory method
+func NewInMemoryTenantConfigStore() *InMemoryTenantConfigStore {
+	return &InMemoryTenantConfigStore{tenants: make(map[string]map[string]string)}
+}
+
+// Set overrides the configuration variable for the tenant
+func (s *InMemoryTenantConfigStore) Set(tenantId, key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.tenants[tenantId]; !ok {
+		s.tenants[tenantId] = make(map[string]string)
+	}
+	s.tenants[tenantId][key] = value
+}
+
+// Delete removes the configuration variable override of the tenant
+func (s *InMemoryTenantConfigStore) Delete(tenantId, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tenants[tenantId], key)
+}
+
+// GetTenantVars returns a copy of the configuration overrides of the tenant
+func (s *InMemoryTenantConfigStore) GetTenantVars(tenantId string) (map[string]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]string, len(s.tenants[tenantId]))
+	for key, value := range s.tenants[tenantId] {
+		result[key] = value
+	}
+	return result, nil
+}
+
+// endregion
+
+// region Tenant scoped configuration ----------------------------------------------------------------------------------
+
+// TenantConfig is a tenant scoped view of the configuration
+type TenantConfig struct {
+	tenantId  string
+	base      *BaseConfig
+	overrides *BaseConfig
+}
+
+// SetTenantStore sets the store of the tenants configuration overrides
+func (c *BaseConfig) SetTenantStore(store ITenantConfigStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tenantStore = store
+}
+
+// ForTenant returns the tenant scoped configuration, the tenant overrides are fetched once when the scope is created
+// If the store is not set or fails, the scope falls back to the service configuration
+func (c *BaseConfig) ForTenant(tenantId string) *TenantConfig {
+	c.mu.RLock()
+	store := c.tenantStore
+	c.mu.RUnlock()
+
+	overrides := make(map[string]string)
+	if store != nil {
+		if vars, err := store.GetTenantVars(tenantId); err != nil {
+			logger.Warn("get tenant %s configuration overrides failed: %s", tenantId, err.Error())
+		} else if vars != nil {
+			overrides = vars
+		}
+	}
+	return &TenantConfig{
+		tenantId:  tenantId,
+		base:      c,
+		overrides: &BaseConfig{cfg: overrides},
+	}
+}
+
+// TenantId returns the tenant of the scope
+func (t *TenantConfig) TenantId() string {
+	return t.tenantId
+}
+
+// GetString gets the tenant configuration variable as string
+func (t *TenantConfig) GetString(key string, defaultValue string) string {
+	return t.overrides.GetStringParamValueOrDefault(key, t.base.GetStringParamValueOrDefault(key, defaultValue))
+}
+
+// GetInt gets the tenant configuration variable as int
+func (t *TenantConfig) GetInt(key string, defaultValue int) int {
+	return t.overrides.GetIntParamValueOrDefault(key, t.base.GetIntParamValueOrDefault(key, defaultValue))
+}
+
+// GetInt64 gets the tenant configuration variable as int64
+func (t *TenantConfig) GetInt64(key string, defaultValue int64) int64 {
+	return t.overrides.GetInt64ParamValueOrDefault(key, t.base.GetInt64ParamValueOrDefault(key, defaultValue))
+}
+
+// GetBool gets the tenant configuration variable as bool
+func (t *TenantConfig) GetBool(key string, defaultValue bool) bool {
+	return t.overrides.GetBoolParamValueOrDefault(key, t.base.GetBoolParamValueOrDefault(key, defaultValue))
+}
+
+// GetFloat gets the tenant configuration variable as float64
+func (t *TenantConfig) GetFloat(key string, defaultValue float64) float64 {
+	return t.overrides.GetFloatParamValueOrDefault(key, t.base.GetFloatParamValueOrDefault(key, defaultValue))
+}
+
+// GetDuration gets the tenant configuration variable as duration
+func (t *TenantConfig) GetDuration(key string, defaultValue time.Duration) time.Duration {
+	return t.overrides.GetDurationParamValueOrDefault(key, t.base.GetDurationParamValueOrDefault(key, defaultValue))
+}
+
+// GetStringSlice gets the tenant configuration variable as list of strings
+func (t *TenantConfig) GetStringSlice(key string, defaultValue []string) []string {
+	return t.overrides.GetStringSliceParamValueOrDefault(key, t.base.GetStringSliceParamValueOrDefault(key, defaultValue))
+}
+
+// endregion
@@ -4,6 +4,8 @@
 // * IDatabase  - interface for RDBMS wrapper implementations
 // * IDataCache - interface for distributed cache wrapper implementations
 // * IDatastore - interface for NoSQL Big Data (Document Store) wrapper implementations
+// * IObjectStore - interface for object (blob) storage wrapper implementations
+// * IKeyValueStore - interface for durable key-value store (settings and state) wrapper implementations
 //
 // The package also includes in-memory implementations of all the above mainly for testing but can be used
 // for some use cases when data persistent is not required
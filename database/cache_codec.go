@@ -0,0 +1,222 @@
+// Entity codecs for the data cache
+//
+// The codec converts entities to the raw bytes stored in the cache and back, so values set using Set can be read using
+// GetRaw and values set using SetRaw can be read using Get, as long as the same codec is used:
+//
+//	dc, _ := database.NewInMemoryDataCache()
+//	dc.(*database.InMemoryDataCache).SetCodec(database.NewMsgPackCodec())
+
+package database
+
+import (
+	"bytes"
+	stdbinary "encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils/binary"
+)
+
+// IEntityCodec encodes entities to raw bytes and decodes raw bytes to entities
+type IEntityCodec interface {
+
+	// Name of the codec
+	Name() string
+
+	// Encode the entity to raw bytes
+	Encode(entity Entity) ([]byte, error)
+
+	// Decode the raw bytes to a new entity created by the factory
+	Decode(factory EntityFactory, data []byte) (Entity, error)
+}
+
+// region JSON codec ---------------------------------------------------------------------------------------------------
+
+type jsonCodec struct{}
+
+// NewJsonCodec creates a codec using the entity JSON representation (the default cache codec)
+func NewJsonCodec() IEntityCodec {
+	return jsonCodec{}
+}
+
+// Name of the codec
+func (c jsonCodec) Name() string {
+	return "json"
+}
+
+// Encode the entity to JSON
+func (c jsonCodec) Encode(entity Entity) ([]byte, error) {
+	return Marshal(entity)
+}
+
+// Decode the JSON to a new entity
+func (c jsonCodec) Decode(factory EntityFactory, data []byte) (Entity, error) {
+	entity, err := newCodecEntity(factory)
+	if err != nil {
+		return nil, err
+	}
+	if err = Unmarshal(data, entity); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// endregion
+
+// region Binary codec -------------------------------------------------------------------------------------------------
+
+type binaryCodec struct{}
+
+// NewBinaryCodec creates a codec using the binary Writer / Reader, entities implementing binary.Marshaler /
+// binary.Unmarshaler (or encoding.BinaryMarshaler / encoding.BinaryUnmarshaler) encode themselves, other entities are
+// encoded as binary JSON objects (see binary.Writer.JsonObject)
+func NewBinaryCodec() IEntityCodec {
+	return binaryCodec{}
+}
+
+// Name of the codec
+func (c binaryCodec) Name() string {
+	return "binary"
+}
+
+// Encode the entity to binary
+func (c binaryCodec) Encode(entity Entity) ([]byte, error) {
+	switch v := entity.(type) {
+	case binary.Marshaler:
+		return binary.Marshal(v), nil
+	case stdbinary.BinaryMarshaler:
+		return v.MarshalBinary()
+	}
+
+	object, err := JsonMarshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	w := binary.Acquire()
+	defer binary.Release(w)
+	return w.JsonObject(object).GetBytes(), nil
+}
+
+// Decode the binary to a new entity
+func (c binaryCodec) Decode(factory EntityFactory, data []byte) (Entity, error) {
+	entity, err := newCodecEntity(factory)
+	if err != nil {
+		return nil, err
+	}
+	switch v := entity.(type) {
+	case binary.Unmarshaler:
+		err = binary.Unmarshal(data, v)
+	case stdbinary.BinaryUnmarshaler:
+		err = v.UnmarshalBinary(data)
+	default:
+		var object Json
+		if object, err = binary.NewReader(data).JsonObject(); err == nil {
+			err = JsonUnmarshal(object, entity)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// endregion
+
+// region Gob codec ----------------------------------------------------------------------------------------------------
+
+type gobCodec struct{}
+
+// NewGobCodec creates a codec using encoding/gob
+func NewGobCodec() IEntityCodec {
+	return gobCodec{}
+}
+
+// Name of the codec
+func (c gobCodec) Name() string {
+	return "gob"
+}
+
+// Encode the entity to gob
+func (c gobCodec) Encode(entity Entity) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(entity); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decode the gob to a new entity
+func (c gobCodec) Decode(factory EntityFactory, data []byte) (Entity, error) {
+	entity, err := newCodecEntity(factory)
+	if err != nil {
+		return nil, err
+	}
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(entity); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// endregion
+
+// region MessagePack codec --------------------------------------------------------------------------------------------
+
+type msgPackCodec struct{}
+
+// NewMsgPackCodec creates a codec using MessagePack, the entity is encoded as a map of its JSON fields
+func NewMsgPackCodec() IEntityCodec {
+	return msgPackCodec{}
+}
+
+// Name of the codec
+func (c msgPackCodec) Name() string {
+	return "msgpack"
+}
+
+// Encode the entity to MessagePack
+func (c msgPackCodec) Encode(entity Entity) ([]byte, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err = decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return msgpackEncode(make([]byte, 0, len(data)), value)
+}
+
+// Decode the MessagePack to a new entity
+func (c msgPackCodec) Decode(factory EntityFactory, data []byte) (Entity, error) {
+	entity, err := newCodecEntity(factory)
+	if err != nil {
+		return nil, err
+	}
+	value, err := msgpackDecode(data)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if err = Unmarshal(jsonData, entity); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// endregion
+
+// create a new entity to decode into
+func newCodecEntity(factory EntityFactory) (Entity, error) {
+	if factory == nil {
+		return nil, fmt.Errorf("entity factory is required to decode the value")
+	}
+	return factory(), nil
+}
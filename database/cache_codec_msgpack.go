@@ -0,0 +1,292 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Maximum nesting level of MessagePack arrays and maps
+const maxMsgPackDepth = 64
+
+// region MessagePack encoder ------------------------------------------------------------------------------------------
+
+// append the MessagePack encoding of the JSON value (nil, bool, json.Number, string, []any or map[string]any)
+func msgpackEncode(buf []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case json.Number:
+		return msgpackEncodeNumber(buf, v)
+	case string:
+		return msgpackEncodeString(buf, v), nil
+	case []any:
+		buf = msgpackEncodeHeader(buf, len(v), 0x90, 0xdc, 0xdd)
+		var err error
+		for _, item := range v {
+			if buf, err = msgpackEncode(buf, item); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		buf = msgpackEncodeHeader(buf, len(v), 0x80, 0xde, 0xdf)
+		var err error
+		for _, key := range keys {
+			buf = msgpackEncodeString(buf, key)
+			if buf, err = msgpackEncode(buf, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type %T", value)
+	}
+}
+
+// append the number as integer when possible, otherwise as float64
+func msgpackEncodeNumber(buf []byte, number json.Number) ([]byte, error) {
+	if i, err := strconv.ParseInt(number.String(), 10, 64); err == nil {
+		switch {
+		case i >= 0 && i <= 0x7f:
+			return append(buf, byte(i)), nil
+		case i < 0 && i >= -32:
+			return append(buf, byte(int8(i))), nil
+		default:
+			buf = append(buf, 0xd3)
+			return binary.BigEndian.AppendUint64(buf, uint64(i)), nil
+		}
+	}
+	if u, err := strconv.ParseUint(number.String(), 10, 64); err == nil {
+		buf = append(buf, 0xcf)
+		return binary.BigEndian.AppendUint64(buf, u), nil
+	}
+	f, err := number.Float64()
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: invalid number %s", number)
+	}
+	buf = append(buf, 0xcb)
+	return binary.BigEndian.AppendUint64(buf, math.Float64bits(f)), nil
+}
+
+// append the string header and bytes
+func msgpackEncodeString(buf []byte, value string) []byte {
+	if len(value) <= 0xff && len(value) > 31 {
+		buf = append(buf, 0xd9, byte(len(value)))
+	} else {
+		buf = msgpackEncodeHeader(buf, len(value), 0xa0, 0xda, 0xdb)
+	}
+	return append(buf, value...)
+}
+
+// append the length header using the fix format (up to 15 items or 31 bytes for strings), 16 bits or 32 bits format
+func msgpackEncodeHeader(buf []byte, length int, fix, code16, code32 byte) []byte {
+	fixMax := 15
+	if fix == 0xa0 {
+		fixMax = 31
+	}
+	switch {
+	case length <= fixMax:
+		return append(buf, fix|byte(length))
+	case length <= math.MaxUint16:
+		buf = append(buf, code16)
+		return binary.BigEndian.AppendUint16(buf, uint16(length))
+	default:
+		buf = append(buf, code32)
+		return binary.BigEndian.AppendUint32(buf, uint32(length))
+	}
+}
+
+// endregion
+
+// region MessagePack decoder ------------------------------------------------------------------------------------------
+
+// decode the MessagePack data to JSON value (nil, bool, int64, uint64, float64, string, []any or map[string]any)
+func msgpackDecode(data []byte) (any, error) {
+	d := &msgpackDecoder{data: data}
+	value, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("msgpack: %d unexpected trailing bytes", len(d.data)-d.pos)
+	}
+	return value, nil
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+// read the next value
+func (d *msgpackDecoder) value(depth int) (any, error) {
+	if depth > maxMsgPackDepth {
+		return nil, fmt.Errorf("msgpack: value is nested more than %d levels", maxMsgPackDepth)
+	}
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	code := b[0]
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xe0 == 0xa0:
+		return d.string(int(code & 0x1f))
+	case code&0xf0 == 0x90:
+		return d.array(int(code&0x0f), depth)
+	case code&0xf0 == 0x80:
+		return d.object(int(code&0x0f), depth)
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, er := d.uint(1 << (code - 0xcc))
+		if er != nil || u > math.MaxInt64 {
+			return u, er
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		u, er := d.uint(size)
+		if er != nil {
+			return nil, er
+		}
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, nil
+	case 0xca:
+		u, er := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), er
+	case 0xcb:
+		u, er := d.uint(8)
+		return math.Float64frombits(u), er
+	case 0xd9, 0xda, 0xdb:
+		n, er := d.uint(1 << (code - 0xd9))
+		if er != nil {
+			return nil, er
+		}
+		return d.string(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, er := d.uint(1 << (code - 0xc4))
+		if er != nil {
+			return nil, er
+		}
+		b, er = d.read(int(n))
+		if er != nil {
+			return nil, er
+		}
+		return append([]byte(nil), b...), nil
+	case 0xdc, 0xdd:
+		n, er := d.uint(2 << (code - 0xdc))
+		if er != nil {
+			return nil, er
+		}
+		return d.array(int(n), depth)
+	case 0xde, 0xdf:
+		n, er := d.uint(2 << (code - 0xde))
+		if er != nil {
+			return nil, er
+		}
+		return d.object(int(n), depth)
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type code 0x%02x", code)
+	}
+}
+
+// read a string of n bytes
+func (d *msgpackDecoder) string(n int) (any, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// read an array of n items
+func (d *msgpackDecoder) array(n int, depth int) (any, error) {
+	// Each item takes at least one byte
+	if n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("msgpack: unexpected end of data")
+	}
+	result := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		item, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// read a map of n string keys and values
+func (d *msgpackDecoder) object(n int, depth int) (any, error) {
+	// Each entry takes at least two bytes
+	if n > (len(d.data)-d.pos)/2 {
+		return nil, fmt.Errorf("msgpack: unexpected end of data")
+	}
+	result := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		key, err := d.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: map key must be a string, got %T", key)
+		}
+		if result[name], err = d.value(depth + 1); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// read an unsigned big endian integer of the given size (1, 2, 4 or 8 bytes)
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	result := uint64(0)
+	for _, c := range b {
+		result = result<<8 | uint64(c)
+	}
+	return result, nil
+}
+
+// read the next n bytes
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, fmt.Errorf("msgpack: unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// endregion
@@ -0,0 +1,228 @@
+// Import / export utilities for IDatabase and IDatastore implementations
+//
+// The export format is NDJSON (newline delimited JSON), each line represents a single entity wrapped with its registered
+// type name (see entity.RegisterEntity), so the file can be loaded back into any implementation.
+
+package database
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+const defaultExportBatchSize = 1000
+
+// ExportRecord is a single line in the NDJSON export file (the shard key is resolved from the entity on import)
+type ExportRecord struct {
+	Type string          `json:"type"` // Registered entity type name (see entity.RegisterEntity)
+	Data json.RawMessage `json:"data"` // Entity content
+}
+
+// ExportOptions controls which entities are exported
+type ExportOptions struct {
+	TablePattern string    // Wildcard pattern (e.g. "usage-*") of registered entity names to export, empty for all
+	Keys         []string  // Shard keys used to resolve sharded tables
+	TimeField    string    // Time field for range filter, empty for no time filter
+	From         Timestamp // Start of the time range
+	To           Timestamp // End of the time range
+	BatchSize    int       // Page size used to read entities (default: 1000)
+}
+
+// ImportOptions controls how entities are loaded
+type ImportOptions struct {
+	TablePattern string // Wildcard pattern of registered entity names to import, empty for all
+	BatchSize    int    // Number of entities per bulk write (default: 1000)
+	Upsert       bool   // Use upsert instead of insert
+}
+
+// region Export -------------------------------------------------------------------------------------------------------
+
+// ExportDatabase dumps all registered entities matching the options from the database to NDJSON, tables which do not
+// exist in the database are skipped and a failure in one table does not stop the export of the others (all the
+// failures are returned as a joined error)
+func ExportDatabase(db IDatabase, w io.Writer, opts ExportOptions) (total int64, err error) {
+	return exportEntities(db.Query, w, opts)
+}
+
+// ExportDatastore dumps all registered entities matching the options from the datastore to NDJSON, indices which do not
+// exist in the datastore are skipped (see ExportDatabase)
+func ExportDatastore(ds IDatastore, w io.Writer, opts ExportOptions) (total int64, err error) {
+	return exportEntities(ds.Query, w, opts)
+}
+
+// export entities of all registered types using the query builder
+func exportEntities(query func(factory EntityFactory) IQuery, w io.Writer, opts ExportOptions) (total int64, err error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultExportBatchSize
+	}
+
+	bw := bufio.NewWriter(w)
+	errs := make([]error, 0)
+	for _, name := range RegisteredEntities() {
+		if !matchTablePattern(name, opts.TablePattern) {
+			continue
+		}
+		factory, _ := GetEntityFactory(name)
+
+		count, fe := exportTable(query, factory, name, bw, opts)
+		total += count
+		if fe != nil && !isTableNotExists(fe) {
+			errs = append(errs, fmt.Errorf("export %s failed: %s", name, fe.Error()))
+		}
+	}
+	if fe := bw.Flush(); fe != nil {
+		errs = append(errs, fe)
+	}
+	return total, errors.Join(errs...)
+}
+
+// check if the query error reports a missing table or index (entity registration is global, so registered entities
+// are not necessarily stored in the exported source)
+func isTableNotExists(err error) bool {
+	return errors.Is(err, ErrTableNotExists) || errors.Is(err, ErrIndexNotExists)
+}
+
+// export all entities of a single type page by page
+func exportTable(query func(factory EntityFactory) IQuery, factory EntityFactory, name string, w *bufio.Writer, opts ExportOptions) (count int64, err error) {
+	for page := 0; ; page++ {
+		q := query(factory).Page(page).Limit(opts.BatchSize)
+		if len(opts.TimeField) > 0 {
+			q.Range(opts.TimeField, opts.From, opts.To)
+		}
+
+		list, total, fe := q.Find(opts.Keys...)
+		if fe != nil {
+			return count, fe
+		}
+
+		for _, ent := range list {
+			if er := writeRecord(w, name, ent); er != nil {
+				return count, er
+			}
+			count += 1
+		}
+
+		if len(list) < opts.BatchSize || count >= total {
+			return count, nil
+		}
+	}
+}
+
+// write a single entity as NDJSON line
+func writeRecord(w *bufio.Writer, name string, ent Entity) error {
+	data, err := Marshal(ent)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(ExportRecord{Type: name, Data: data})
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(line); err != nil {
+		return err
+	}
+	return w.WriteByte('\n')
+}
+
+// endregion
+
+// region Import -------------------------------------------------------------------------------------------------------
+
+// ImportDatabase loads NDJSON entities (see ExportDatabase) to the database
+func ImportDatabase(db IDatabase, r io.Reader, opts ImportOptions) (total int64, err error) {
+	write := db.BulkInsert
+	if opts.Upsert {
+		write = db.BulkUpsert
+	}
+	return importEntities(write, r, opts)
+}
+
+// ImportDatastore loads NDJSON entities (see ExportDatastore) to the datastore
+func ImportDatastore(ds IDatastore, r io.Reader, opts ImportOptions) (total int64, err error) {
+	write := ds.BulkInsert
+	if opts.Upsert {
+		write = ds.BulkUpsert
+	}
+	return importEntities(write, r, opts)
+}
+
+// read NDJSON lines and write them in bulks, each bulk has entities of a single type
+func importEntities(write func(entities []Entity) (int64, error), r io.Reader, opts ImportOptions) (total int64, err error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultExportBatchSize
+	}
+
+	bulk := make([]Entity, 0, opts.BatchSize)
+	bulkType := ""
+	flush := func() error {
+		if len(bulk) == 0 {
+			return nil
+		}
+		affected, fe := write(bulk)
+		total += affected
+		bulk = bulk[:0]
+		return fe
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		record := ExportRecord{}
+		if fe := json.Unmarshal(scanner.Bytes(), &record); fe != nil {
+			return total, fmt.Errorf("line %d: %s", line, fe.Error())
+		}
+		if !matchTablePattern(record.Type, opts.TablePattern) {
+			continue
+		}
+
+		factory, ok := GetEntityFactory(record.Type)
+		if !ok {
+			return total, fmt.Errorf("line %d: entity type %s is not registered", line, record.Type)
+		}
+		ent := factory()
+		if fe := Unmarshal(record.Data, ent); fe != nil {
+			return total, fmt.Errorf("line %d: %s", line, fe.Error())
+		}
+
+		// Bulk writes require entities of the same type
+		if record.Type != bulkType {
+			if fe := flush(); fe != nil {
+				return total, fe
+			}
+			bulkType = record.Type
+		}
+
+		if bulk = append(bulk, ent); len(bulk) >= opts.BatchSize {
+			if fe := flush(); fe != nil {
+				return total, fe
+			}
+		}
+	}
+	if fe := scanner.Err(); fe != nil {
+		return total, fe
+	}
+	return total, flush()
+}
+
+// endregion
+
+// check if the entity name matches the wildcard pattern (empty pattern matches all)
+func matchTablePattern(name, pattern string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	return utils.StringUtils().WildCardMatch(name, pattern)
+}
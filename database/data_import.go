@@ -0,0 +1,338 @@
+// Data import pipeline for IDatabase and IDatastore implementations
+//
+// Records are stream-parsed from CSV (with header line) or NDJSON files, the columns are mapped to the entity JSON
+// fields, transformed, converted to the entity field types, validated and written in bulks:
+//
+//	result, err := database.ImportRecordsDatabase(db, NewUser, file, database.RecordsImportOptions{
+//		Format:  database.ImportFormatCsv,
+//		Mapping: map[string]string{"Full Name": "name", "E-mail": "email"},
+//		Transforms: map[string]database.FieldTransform{
+//			"email": func(value any) (any, error) { return strings.ToLower(fmt.Sprint(value)), nil },
+//		},
+//		Validate: func(ent entity.Entity) error { ... },
+//	})
+
+package database
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+// ImportFormat is the format of the imported records
+type ImportFormat int
+
+const (
+	// ImportFormatCsv is CSV with a header line of the column names
+	ImportFormatCsv ImportFormat = iota
+	// ImportFormatNdjson is newline delimited JSON, each line is a JSON object
+	ImportFormatNdjson
+)
+
+// FieldTransform converts the value of a field before it is set to the entity
+type FieldTransform func(value any) (any, error)
+
+// RecordsImportOptions controls the records import
+type RecordsImportOptions struct {
+	Format     ImportFormat              // Records format (default: CSV)
+	Comma      rune                      // CSV field delimiter (default: ',')
+	Mapping    map[string]string         // Column name to entity JSON field name, unmapped columns are used as is
+	Ignore     []string                  // Columns to ignore
+	Transforms map[string]FieldTransform // Transform functions by entity JSON field name
+	Validate   func(ent Entity) error    // Validates (and optionally modifies) the entity before it is written
+	BatchSize  int                       // Number of entities per bulk write (default: 1000)
+	Upsert     bool                      // Use upsert instead of insert
+	DryRun     bool                      // Parse, convert and validate the records without writing them
+	MaxErrors  int                       // Number of invalid records to skip before the import fails (0: fail on the first invalid record)
+	OnProgress func(progress ImportProgress)
+}
+
+// ImportProgress is the progress of the import, reported after each bulk
+type ImportProgress struct {
+	Records  int64 `json:"records"`  // Number of records read
+	Imported int64 `json:"imported"` // Number of entities written (validated entities in dry-run mode)
+	Failed   int64 `json:"failed"`   // Number of invalid records
+}
+
+// ImportRecordError is an error of a single record
+type ImportRecordError struct {
+	Line int   `json:"line"`  // Line number of the record (1 based, including the CSV header)
+	Err  error `json:"error"` // Parsing, conversion or validation error
+}
+
+// Error implements error
+func (e ImportRecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+// Unwrap returns the record error
+func (e ImportRecordError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON writes the error message instead of the error value
+func (e ImportRecordError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"line": e.Line, "error": e.Err.Error()})
+}
+
+// ImportResult is the summary of the import
+type ImportResult struct {
+	ImportProgress
+	Errors []ImportRecordError `json:"errors"` // Errors of the invalid records which were skipped
+}
+
+// region Records import -----------------------------------------------------------------------------------------------
+
+// ImportRecordsDatabase imports CSV / NDJSON records as entities of the factory to the database
+func ImportRecordsDatabase(db IDatabase, factory EntityFactory, r io.Reader, opts RecordsImportOptions) (*ImportResult, error) {
+	write := db.BulkInsert
+	if opts.Upsert {
+		write = db.BulkUpsert
+	}
+	return importRecords(write, factory, r, opts)
+}
+
+// ImportRecordsDatastore imports CSV / NDJSON records as entities of the factory to the datastore
+func ImportRecordsDatastore(ds IDatastore, factory EntityFactory, r io.Reader, opts RecordsImportOptions) (*ImportResult, error) {
+	write := ds.BulkInsert
+	if opts.Upsert {
+		write = ds.BulkUpsert
+	}
+	return importRecords(write, factory, r, opts)
+}
+
+// read the records, convert them to entities and write them in bulks
+func importRecords(write func(entities []Entity) (int64, error), factory EntityFactory, r io.Reader, opts RecordsImportOptions) (*ImportResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultExportBatchSize
+	}
+	result := &ImportResult{Errors: make([]ImportRecordError, 0)}
+	fields := utils.JsonUtils().FieldTypes(factory())
+
+	bulk := make([]Entity, 0, opts.BatchSize)
+	flush := func() error {
+		if len(bulk) > 0 {
+			if opts.DryRun {
+				result.Imported += int64(len(bulk))
+			} else {
+				affected, err := write(bulk)
+				result.Imported += affected
+				if err != nil {
+					return err
+				}
+			}
+			bulk = bulk[:0]
+		}
+		if opts.OnProgress != nil {
+			opts.OnProgress(result.ImportProgress)
+		}
+		return nil
+	}
+
+	err := readRecords(r, opts, func(line int, record map[string]any, readErr error) error {
+		result.Records += 1
+
+		var entity Entity
+		err := readErr
+		if err == nil {
+			entity, err = recordToEntity(factory, fields, record, opts)
+		}
+		if err != nil {
+			result.Failed += 1
+			recordErr := ImportRecordError{Line: line, Err: err}
+			if int(result.Failed) > opts.MaxErrors {
+				return recordErr
+			}
+			result.Errors = append(result.Errors, recordErr)
+			return nil
+		}
+
+		if bulk = append(bulk, entity); len(bulk) >= opts.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, flush()
+}
+
+// convert the record to entity: map the columns, transform and convert the values, and validate the entity
+func recordToEntity(factory EntityFactory, fields map[string]reflect.Type, record map[string]any, opts RecordsImportOptions) (Entity, error) {
+	values := make(map[string]any, len(record))
+	for column, value := range record {
+		if slices.Contains(opts.Ignore, column) {
+			continue
+		}
+		field := column
+		if mapped, ok := opts.Mapping[column]; ok {
+			field = mapped
+		}
+		if transform, ok := opts.Transforms[field]; ok {
+			transformed, err := transform(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field, err)
+			}
+			value = transformed
+		}
+		if str, ok := value.(string); ok {
+			if fieldType, found := fields[field]; found {
+				converted, err := convertFieldValue(str, fieldType)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field, err)
+				}
+				value = converted
+			}
+		}
+		if value != nil {
+			values[field] = value
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	ent := factory()
+	if err = Unmarshal(data, ent); err != nil {
+		return nil, err
+	}
+	if opts.Validate != nil {
+		if err = opts.Validate(ent); err != nil {
+			return nil, err
+		}
+	}
+	return ent, nil
+}
+
+// endregion
+
+// region Records readers ----------------------------------------------------------------------------------------------
+
+// read the records of the format and call the callback for each record (or record parsing error)
+func readRecords(r io.Reader, opts RecordsImportOptions, callback func(line int, record map[string]any, err error) error) error {
+	switch opts.Format {
+	case ImportFormatCsv:
+		return readCsvRecords(r, opts, callback)
+	case ImportFormatNdjson:
+		return readNdjsonRecords(r, callback)
+	default:
+		return fmt.Errorf("unsupported import format: %d", opts.Format)
+	}
+}
+
+// read CSV records, the first line is the header of the column names
+func readCsvRecords(r io.Reader, opts RecordsImportOptions, callback func(line int, record map[string]any, err error) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return fmt.Errorf("read CSV header failed: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, column := range header {
+		columns[i] = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+	}
+
+	for {
+		row, er := reader.Read()
+		if errors.Is(er, io.EOF) {
+			return nil
+		}
+		line, _ := reader.FieldPos(0)
+
+		var record map[string]any
+		if er == nil {
+			record = make(map[string]any, len(columns))
+			for i, value := range row {
+				if i < len(columns) && len(value) > 0 {
+					record[columns[i]] = value
+				}
+			}
+		} else {
+			var parseErr *csv.ParseError
+			if !errors.As(er, &parseErr) {
+				return er
+			}
+			line = parseErr.Line
+		}
+		if er = callback(line, record, er); er != nil {
+			return er
+		}
+	}
+}
+
+// read NDJSON records, empty lines are skipped
+func readNdjsonRecords(r io.Reader, callback func(line int, record map[string]any, err error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		record := make(map[string]any)
+		er := json.Unmarshal(scanner.Bytes(), &record)
+		if er != nil {
+			record = nil
+		}
+		if er = callback(line, record, er); er != nil {
+			return er
+		}
+	}
+	return scanner.Err()
+}
+
+// endregion
+
+// region Field conversion ---------------------------------------------------------------------------------------------
+
+// convert the text value to the JSON value of the field type, values of other types (e.g. structs, slices) are
+// parsed as JSON
+func convertFieldValue(value string, t reflect.Type) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	value = strings.TrimSpace(value)
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	default:
+		var result any
+		if err := json.Unmarshal([]byte(value), &result); err != nil {
+			return nil, fmt.Errorf("invalid JSON value: %s", value)
+		}
+		return result, nil
+	}
+}
+
+// endregion
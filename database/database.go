@@ -1,11 +1,16 @@
 package database
 
 import (
+	"errors"
 	"io"
 
 	. "github.com/go-yaaf/yaaf-common/entity"
 )
 
+// ErrTableNotExists is returned when the entity table does not exist in the database, implementations should return
+// (or wrap) it so callers can detect missing tables with errors.Is
+var ErrTableNotExists = errors.New(TABLE_NOT_EXISTS)
+
 // IDatabase Database interface
 type IDatabase interface {
 
@@ -86,3 +91,10 @@ type IDatabase interface {
 	// PurgeTable Fast delete table content (truncate)
 	PurgeTable(table string) (err error)
 }
+
+// ITransactional is implemented by databases supporting transactions
+// The function is executed with a database instance bound to the transaction, the transaction is committed if the
+// function returns nil and rolled back otherwise
+type ITransactional interface {
+	WithTransaction(fn func(tx IDatabase) error) error
+}
@@ -30,19 +30,24 @@ type IDataCache interface {
 	// Get the value of a key
 	Get(factory EntityFactory, key string) (Entity, error)
 
-	// GetRaw gets the raw value of a key
+	// GetRaw gets the raw value of a key, values set using Set are encoded by the cache codec (see IEntityCodec)
 	GetRaw(key string) ([]byte, error)
 
 	// GetKeys Get the value of all the given keys
 	GetKeys(factory EntityFactory, keys ...string) ([]Entity, error)
 
+	// GetKeysTyped gets the value of all the given keys, each key with its own factory (for heterogeneous entities)
+	// Keys which do not exist are not included in the result
+	GetKeysTyped(keys map[string]EntityFactory) (map[string]Entity, error)
+
 	// GetRawKeys gets the raw value of all the given keys
 	GetRawKeys(keys ...string) ([]Tuple[string, []byte], error)
 
 	// Set value of key with optional expiration
 	Set(key string, entity Entity, expiration ...time.Duration) error
 
-	// SetRaw sets the raw value of key with optional expiration
+	// SetRaw sets the raw value of key with optional expiration, the value should be encoded by the cache codec to be
+	// read using Get
 	SetRaw(key string, bytes []byte, expiration ...time.Duration) error
 
 	// SetNX sets value of key only if it is not exist with optional expiration, return false if the key exists
@@ -57,6 +62,10 @@ type IDataCache interface {
 	// AddRaw sets the raw value of a key only if the key does not exist
 	AddRaw(key string, bytes []byte, expiration time.Duration) (bool, error)
 
+	// Incr increments the integer value of a key by delta and returns the new value, a missing key is set to 0 before the
+	// operation. If expiration is provided, the key expiration is set on each increment
+	Incr(key string, delta int64, expiration ...time.Duration) (int64, error)
+
 	// Del Delete keys
 	Del(keys ...string) (err error)
 
@@ -66,11 +75,22 @@ type IDataCache interface {
 	// Exists Check if key exists
 	Exists(key string) (result bool, err error)
 
-	// Scan keys from the provided cursor
+	// Scan keys from the provided cursor (0 to start), match is a glob-style pattern and count is the number of keys to
+	// examine, the returned cursor is 0 when the iteration is complete
 	Scan(from uint64, match string, count int64) (keys []string, cursor uint64, err error)
 
 	// endregion
 
+	// region Tag actions ----------------------------------------------------------------------------------------------
+
+	// SetWithTags sets value of key and adds the key to the tags sets (e.g. entity type or tenant) for group invalidation
+	SetWithTags(key string, entity Entity, tags ...string) error
+
+	// InvalidateTag deletes all the keys associated with the tag and returns the number of deleted keys
+	InvalidateTag(tag string) (count int64, err error)
+
+	// endregion
+
 	// region Hash actions ---------------------------------------------------------------------------------------------
 
 	// HGet gets the value of a hash field
@@ -79,13 +99,13 @@ type IDataCache interface {
 	// HGetRaw gets the raw value of a hash field
 	HGetRaw(key, field string) ([]byte, error)
 
-	// HKeys gets all the fields in a hash
+	// HKeys gets all the field names in a hash (the field names as set by HSet, without the hash key)
 	HKeys(key string) (fields []string, err error)
 
-	// HGetAll gets all the fields and values in a hash
+	// HGetAll gets all the fields and values in a hash, the result map key is the field name (without the hash key)
 	HGetAll(factory EntityFactory, key string) (result map[string]Entity, err error)
 
-	// HGetRawAll gets all the fields and raw values in a hash
+	// HGetRawAll gets all the fields and raw values in a hash, the result map key is the field name (without the hash key)
 	HGetRawAll(key string) (result map[string][]byte, err error)
 
 	// HSet sets the value of a hash field
@@ -134,12 +154,26 @@ type IDataCache interface {
 	// BLPop Remove and get the first element in a list or block until one is available
 	BLPop(factory EntityFactory, timeout time.Duration, keys ...string) (key string, entity Entity, err error)
 
-	// LRange Get a range of elements from list
+	// LRange Get a range of elements from list, start and stop are inclusive zero-based indexes, negative indexes are
+	// offsets from the end of the list (-1 is the last element)
 	LRange(factory EntityFactory, key string, start, stop int64) (result []Entity, err error)
 
+	// LTrim Trim the list to the range of elements (same index semantics as LRange), the list is deleted if the range is empty
+	LTrim(key string, start, stop int64) (err error)
+
+	// LRem Remove the first count occurrences of the value from the list (count > 0: from head to tail, count < 0: from
+	// tail to head, count = 0: all occurrences) and return the number of removed elements
+	LRem(key string, count int64, value Entity) (removed int64, err error)
+
+	// LSet Set the element at the index of the list (negative index is an offset from the end of the list)
+	LSet(key string, index int64, value Entity) (err error)
+
 	// LLen Get the length of a list
 	LLen(key string) (result int64)
 
+	// LExpire sets a timeout on a list, the list is deleted after the timeout expires, return false if the list does not exist
+	LExpire(key string, expiration time.Duration) (result bool, err error)
+
 	// endregion
 
 	// region List actions ---------------------------------------------------------------------------------------------
@@ -0,0 +1,92 @@
+// Feature flags provider backed by the data cache
+//
+// Each flag is stored in its own key: <prefix><flag name>, the value is either FeatureFlag json or the compact flag
+// format (see config.ParseFeatureFlag):
+//
+//	_ = dc.SetRaw("feature-flags:NEW_UI", []byte("25%"))
+//	flags := config.NewFeatureFlags(database.NewDataCacheFeatureFlagProvider(dc, "feature-flags:", time.Minute))
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/config"
+)
+
+// dataCacheFeatureFlagProvider loads feature flags from the data cache keys with the given prefix
+type dataCacheFeatureFlagProvider struct {
+	dc       IDataCache
+	prefix   string
+	ttl      time.Duration
+	mu       sync.Mutex
+	flags    map[string]config.FeatureFlag
+	loadedAt time.Time
+}
+
+// NewDataCacheFeatureFlagProvider creates a provider of the flags stored in the data cache keys with the given prefix
+// (the flag name is the key without the prefix). The flags are cached by the provider for the ttl (0 for no caching)
+func NewDataCacheFeatureFlagProvider(dc IDataCache, prefix string, ttl time.Duration) config.IFeatureFlagProvider {
+	return &dataCacheFeatureFlagProvider{dc: dc, prefix: prefix, ttl: ttl}
+}
+
+// GetFlags returns the flags stored in the data cache
+func (p *dataCacheFeatureFlagProvider) GetFlags() (map[string]config.FeatureFlag, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.flags != nil && time.Since(p.loadedAt) < p.ttl {
+		return p.flags, nil
+	}
+
+	flags, err := p.loadFlags()
+	if err != nil {
+		return nil, err
+	}
+	p.flags, p.loadedAt = flags, time.Now()
+	return flags, nil
+}
+
+// scan the flags keys and parse their values
+func (p *dataCacheFeatureFlagProvider) loadFlags() (map[string]config.FeatureFlag, error) {
+	match := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`).Replace(p.prefix) + "*"
+
+	keys := make([]string, 0)
+	cursor := uint64(0)
+	for {
+		list, next, err := p.dc.Scan(cursor, match, 0)
+		if err != nil {
+			return nil, fmt.Errorf("scan feature flags: %w", err)
+		}
+		keys = append(keys, list...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	flags := make(map[string]config.FeatureFlag)
+	if len(keys) == 0 {
+		return flags, nil
+	}
+	values, err := p.dc.GetRawKeys(keys...)
+	if err != nil {
+		return nil, fmt.Errorf("get feature flags: %w", err)
+	}
+	for _, value := range values {
+		name := strings.TrimPrefix(value.Key, p.prefix)
+		if len(name) == 0 {
+			continue
+		}
+		var flag config.FeatureFlag
+		if err = json.Unmarshal(value.Value, &flag); err != nil {
+			flag = config.ParseFeatureFlag(name, string(value.Value))
+		}
+		flag.Name = name
+		flags[name] = flag
+	}
+	return flags, nil
+}
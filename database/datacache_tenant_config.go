@@ -0,0 +1,122 @@
+// Tenant configuration stores backed by the data cache and the database
+//
+// Data cache: the overrides of each tenant are stored in a hash: <prefix><tenant id>, the hash field is the
+// configuration variable name:
+//
+//	_ = dc.HSetRaw("tenant-config:acme", "MAX_USERS", []byte("500"))
+//	config.Get().SetTenantStore(database.NewDataCacheTenantConfigStore(dc, "tenant-config:"))
+//
+// Database: the overrides are stored as TenantConfigVar entities (one per tenant variable) in the tenant-config table:
+//
+//	_, _ = db.Insert(database.NewTenantConfigVar("acme", "MAX_USERS", "500"))
+//	config.Get().SetTenantStore(database.NewDatabaseTenantConfigStore(db))
+
+package database
+
+import (
+	"fmt"
+
+	"github.com/go-yaaf/yaaf-common/config"
+	. "github.com/go-yaaf/yaaf-common/entity"
+)
+
+// region Data cache tenant config store -------------------------------------------------------------------------------
+
+// dataCacheTenantConfigStore loads the tenant configuration overrides from the data cache hash of the tenant
+type dataCacheTenantConfigStore struct {
+	dc     IDataCache
+	prefix string
+}
+
+// NewDataCacheTenantConfigStore creates a tenant config store of the overrides stored in the data cache hash
+// <prefix><tenant id> (field name -> value)
+func NewDataCacheTenantConfigStore(dc IDataCache, prefix string) config.ITenantConfigStore {
+	return &dataCacheTenantConfigStore{dc: dc, prefix: prefix}
+}
+
+// GetTenantVars returns the configuration overrides of the tenant
+func (s *dataCacheTenantConfigStore) GetTenantVars(tenantId string) (map[string]string, error) {
+	raw, err := s.dc.HGetRawAll(s.prefix + tenantId)
+	if err != nil {
+		return nil, fmt.Errorf("get tenant %s config: %w", tenantId, err)
+	}
+	result := make(map[string]string, len(raw))
+	for field, value := range raw {
+		result[field] = string(value)
+	}
+	return result, nil
+}
+
+// endregion
+
+// region Database tenant config store ---------------------------------------------------------------------------------
+
+// TenantConfigVar is a configuration variable override of a tenant
+type TenantConfigVar struct {
+	BaseEntity
+	TenantId string `json:"tenantId"` // Tenant id
+	Key      string `json:"key"`      // Configuration variable name
+	Value    string `json:"value"`    // Configuration variable value
+}
+
+// TABLE returns the table name
+func (v *TenantConfigVar) TABLE() string { return "tenant-config" }
+
+// NAME returns the entity name
+func (v *TenantConfigVar) NAME() string { return fmt.Sprintf("%s %s", v.TenantId, v.Key) }
+
+// KEY returns the entity sharding key
+func (v *TenantConfigVar) KEY() string { return "" }
+
+// NewTenantConfigVar creates the configuration variable override of the tenant, the entity id is <tenant id>:<key>
+func NewTenantConfigVar(tenantId, key, value string) Entity {
+	return &TenantConfigVar{
+		BaseEntity: BaseEntity{Id: tenantId + ":" + key, CreatedOn: Now(), UpdatedOn: Now()},
+		TenantId:   tenantId,
+		Key:        key,
+		Value:      value,
+	}
+}
+
+// TenantConfigVarFactory is the TenantConfigVar entity factory
+func TenantConfigVarFactory() Entity {
+	return &TenantConfigVar{}
+}
+
+// tenantConfigPageSize is the page size of the tenant config variables query
+const tenantConfigPageSize = 1000
+
+// databaseTenantConfigStore loads the tenant configuration overrides from the tenant-config table
+type databaseTenantConfigStore struct {
+	db IDatabase
+}
+
+// NewDatabaseTenantConfigStore creates a tenant config store of the TenantConfigVar entities stored in the database
+func NewDatabaseTenantConfigStore(db IDatabase) config.ITenantConfigStore {
+	return &databaseTenantConfigStore{db: db}
+}
+
+// GetTenantVars returns the configuration overrides of the tenant
+func (s *databaseTenantConfigStore) GetTenantVars(tenantId string) (map[string]string, error) {
+	result := make(map[string]string)
+	for page, count := 0, 0; ; page++ {
+		list, total, err := s.db.Query(TenantConfigVarFactory).
+			Filter(F("tenantId").Eq(tenantId)).
+			Page(page).
+			Limit(tenantConfigPageSize).
+			Find()
+		if err != nil {
+			return nil, fmt.Errorf("get tenant %s config: %w", tenantId, err)
+		}
+		for _, item := range list {
+			if v, ok := item.(*TenantConfigVar); ok {
+				result[v.Key] = v.Value
+			}
+		}
+		if count += len(list); len(list) < tenantConfigPageSize || int64(count) >= total {
+			return result, nil
+		}
+	}
+}
+
+// endregion
@@ -1,10 +1,16 @@
 package database
 
 import (
-	. "github.com/go-yaaf/yaaf-common/entity"
+	"errors"
 	"io"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
 )
 
+// ErrIndexNotExists is returned when the entity index does not exist in the datastore, implementations should return
+// (or wrap) it so callers can detect missing indices with errors.Is
+var ErrIndexNotExists = errors.New(INDEX_NOT_EXISTS)
+
 // IDatastore interface for NoSQL Big Data wrapper implementations
 type IDatastore interface {
 
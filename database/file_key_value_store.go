@@ -0,0 +1,94 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// region Key-value store definitions ----------------------------------------------------------------------------------
+
+// FileKeyValueStore represent key-value store backed by a JSON file, the file is rewritten (atomically) on each change.
+// The file is owned by a single process, changes made to the file by other processes are not detected
+type FileKeyValueStore struct {
+	*InMemoryKeyValueStore
+	path string
+}
+
+// endregion
+
+// region Factory and connectivity methods -----------------------------------------------------------------------------
+
+// NewFileKeyValueStore is a factory method for file backed key-value store, the values are loaded from the file if it
+// exists, otherwise the file is created on the first change
+func NewFileKeyValueStore(path string) (IKeyValueStore, error) {
+	values := make(map[string][]byte)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("read key-value store file failed: %w", err)
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("invalid key-value store file %s: %w", path, err)
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create key-value store folder failed: %w", err)
+	}
+
+	store := &FileKeyValueStore{InMemoryKeyValueStore: newInMemoryKeyValueStore(values), path: path}
+	store.persist = store.write
+	return store, nil
+}
+
+// Ping tests connectivity for retries number of time with time interval (in seconds) between retries
+func (s *FileKeyValueStore) Ping(retries uint, interval uint) error {
+	if _, err := os.Stat(filepath.Dir(s.path)); err != nil {
+		return fmt.Errorf("key-value store folder is not accessible: %w", err)
+	}
+	return nil
+}
+
+// Close key-value store and free resources
+func (s *FileKeyValueStore) Close() error {
+	logger.Debug("File key-value store %s closed", s.path)
+	return nil
+}
+
+// endregion
+
+// region PRIVATE SECTION ----------------------------------------------------------------------------------------------
+
+// write the values to a temporary file and replace the store file
+func (s *FileKeyValueStore) write(values map[string][]byte) error {
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write key-value store file failed: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+
+	if _, err = temp.Write(data); err == nil {
+		err = temp.Sync()
+	}
+	if er := temp.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		return fmt.Errorf("write key-value store file failed: %w", err)
+	}
+	return os.Rename(temp.Name(), s.path)
+}
+
+// endregion
@@ -20,7 +20,8 @@ const (
 
 // InMemoryDatabase represents in memory database with tables
 type InMemoryDatabase struct {
-	db map[string]ITable
+	db     map[string]ITable
+	strict bool
 }
 
 // Resolve table name from entity class name and shard keys
@@ -60,6 +61,12 @@ func NewInMemoryDatabase() (dbs IDatabase, err error) {
 	return &InMemoryDatabase{db: make(map[string]ITable)}, nil
 }
 
+// SetStrictMode enables or disables strict mode, in strict mode queries with unknown fields, unsupported operators or
+// type mismatches return errors (like the real adapters) instead of silently returning no results
+func (dbs *InMemoryDatabase) SetStrictMode(strict bool) {
+	dbs.strict = strict
+}
+
 // Ping Test database connectivity
 // @param retries - how many retries are required (max 10)
 // @param interval - time interval (in seconds) between retries (max 60)
@@ -90,7 +97,7 @@ func (dbs *InMemoryDatabase) Get(factory EntityFactory, entityID string, keys ..
 	if tbl, ok := dbs.db[table]; ok {
 		return tbl.Get(entityID)
 	} else {
-		return nil, fmt.Errorf(TABLE_NOT_EXISTS)
+		return nil, ErrTableNotExists
 	}
 }
 
@@ -109,7 +116,7 @@ func (dbs *InMemoryDatabase) List(factory EntityFactory, entityIDs []string, key
 			}
 		}
 	} else {
-		return list, fmt.Errorf(TABLE_NOT_EXISTS)
+		return list, ErrTableNotExists
 	}
 	return
 }
@@ -123,7 +130,7 @@ func (dbs *InMemoryDatabase) Exists(factory EntityFactory, entityID string, keys
 	if tbl, ok := dbs.db[table]; ok {
 		return tbl.Exists(entityID)
 	} else {
-		return false, fmt.Errorf(TABLE_NOT_EXISTS)
+		return false, ErrTableNotExists
 	}
 }
 
@@ -154,7 +161,7 @@ func (dbs *InMemoryDatabase) Upsert(entity Entity) (updated Entity, err error) {
 	if tbl, ok := dbs.db[table]; ok {
 		return tbl.Upsert(entity)
 	} else {
-		return nil, fmt.Errorf(TABLE_NOT_EXISTS)
+		return nil, ErrTableNotExists
 	}
 }
 
@@ -167,7 +174,7 @@ func (dbs *InMemoryDatabase) Delete(factory EntityFactory, entityID string, keys
 	if tbl, ok := dbs.db[table]; ok {
 		return tbl.Delete(entityID)
 	} else {
-		return fmt.Errorf(TABLE_NOT_EXISTS)
+		return ErrTableNotExists
 	}
 }
 
@@ -239,7 +246,7 @@ func (dbs *InMemoryDatabase) SetFields(factory EntityFactory, entityID string, f
 	}
 
 	// convert entity to Json
-	js, fe := utils.JsonUtils().ToJson(entity)
+	js, fe := utils.JsonUtils().ToMap(entity)
 	if fe != nil {
 		return fe
 	}
@@ -249,7 +256,7 @@ func (dbs *InMemoryDatabase) SetFields(factory EntityFactory, entityID string, f
 		js[k] = v
 	}
 
-	toSet, fe := utils.JsonUtils().FromJson(factory, js)
+	toSet, fe := utils.JsonUtils().FromMap(factory, js)
 	if fe != nil {
 		return fe
 	}
@@ -270,7 +277,7 @@ func (dbs *InMemoryDatabase) BulkSetFields(factory EntityFactory, field string,
 	// convert entity to Json
 	count := 0
 	for _, entity := range list {
-		js, err := utils.JsonUtils().ToJson(entity)
+		js, err := utils.JsonUtils().ToMap(entity)
 		if err != nil {
 			return 0, err
 		}
@@ -279,7 +286,7 @@ func (dbs *InMemoryDatabase) BulkSetFields(factory EntityFactory, field string,
 		if val, ok := values[entity.ID()]; ok {
 			js[field] = val
 
-			if toSet, _ := utils.JsonUtils().FromJson(factory, js); toSet != nil {
+			if toSet, _ := utils.JsonUtils().FromMap(factory, js); toSet != nil {
 				if _, er := dbs.Update(toSet); er == nil {
 					count += 1
 				}
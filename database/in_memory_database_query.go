@@ -16,6 +16,7 @@ type inMemoryDatabaseQuery struct {
 	factory    EntityFactory            // The entity factory method
 	allFilters [][]QueryFilter          // List of lists of AND filters
 	anyFilters [][]QueryFilter          // List of lists of OR filters
+	conditions []*FilterExpression      // List of boolean conditions (AND)
 	ascOrders  []any                    // List of fields for ASC order
 	descOrders []any                    // List of fields for DESC order
 	callbacks  []func(in Entity) Entity // List of entity transformation callback functions
@@ -74,7 +75,30 @@ func (s *inMemoryDatabaseQuery) MatchAny(filters ...QueryFilter) IQuery {
 			list = append(list, filter)
 		}
 	}
-	s.anyFilters = append(s.allFilters, list)
+	s.anyFilters = append(s.anyFilters, list)
+	return s
+}
+
+// And adds a boolean condition, all the conditions should be satisfied (AND operator equivalent)
+func (s *inMemoryDatabaseQuery) And(conditions ...Condition) IQuery {
+	return s.addCondition(And(conditions...))
+}
+
+// Or adds a boolean condition, any of the conditions should be satisfied (OR operator equivalent)
+func (s *inMemoryDatabaseQuery) Or(conditions ...Condition) IQuery {
+	return s.addCondition(Or(conditions...))
+}
+
+// Not adds a boolean condition, the condition should not be satisfied (NOT operator equivalent)
+func (s *inMemoryDatabaseQuery) Not(condition Condition) IQuery {
+	return s.addCondition(Not(condition))
+}
+
+// add the condition unless it has no active filters
+func (s *inMemoryDatabaseQuery) addCondition(expr *FilterExpression) IQuery {
+	if !expr.isEmpty() {
+		s.conditions = append(s.conditions, expr)
+	}
 	return s
 }
 
@@ -137,7 +161,11 @@ func (s *inMemoryDatabaseQuery) Find(keys ...string) (out []Entity, total int64,
 
 	tbl, ok := s.db.db[table]
 	if !ok {
-		return nil, 0, fmt.Errorf(TABLE_NOT_EXISTS)
+		return nil, 0, ErrTableNotExists
+	}
+
+	if err = s.validate(); err != nil {
+		return
 	}
 
 	// If range is defined, add it to the filters
@@ -176,7 +204,11 @@ func (s *inMemoryDatabaseQuery) Count(keys ...string) (total int64, err error) {
 
 	tbl, ok := s.db.db[table]
 	if !ok {
-		return 0, fmt.Errorf(TABLE_NOT_EXISTS)
+		return 0, ErrTableNotExists
+	}
+
+	if err = s.validate(); err != nil {
+		return
 	}
 
 	// If range is defined, add it to the filters
@@ -314,7 +346,7 @@ func (s *inMemoryDatabaseQuery) SetFields(fields map[string]any, keys ...string)
 
 	for _, entity := range list {
 
-		raw, er := utils.JsonUtils().ToJson(entity)
+		raw, er := utils.JsonUtils().ToMap(entity)
 		if er != nil {
 			continue
 		}
@@ -323,7 +355,7 @@ func (s *inMemoryDatabaseQuery) SetFields(fields map[string]any, keys ...string)
 			raw[f] = v
 		}
 
-		if changed, _ := utils.JsonUtils().FromJson(s.factory, raw); changed != nil {
+		if changed, _ := utils.JsonUtils().FromMap(s.factory, raw); changed != nil {
 			changeList = append(changeList, changed)
 		}
 	}
@@ -338,11 +370,25 @@ func (s *inMemoryDatabaseQuery) SetFields(fields map[string]any, keys ...string)
 // endregion
 
 // region QueryBuilder Internal Methods --------------------------------------------------------------------------------
+
+// validate the query in strict mode
+func (s *inMemoryDatabaseQuery) validate() error {
+	if !s.db.strict {
+		return nil
+	}
+	orders := append(append([]any{}, s.ascOrders...), s.descOrders...)
+	filters := append(append([][]QueryFilter{}, s.allFilters...), s.anyFilters...)
+	for _, condition := range s.conditions {
+		filters = append(filters, condition.Filters())
+	}
+	return validateQuery(s.factory, filters, orders, s.rangeField)
+}
+
 // Filter entity based on conditions
 func (s *inMemoryDatabaseQuery) filter(in Entity) (out Entity) {
 
 	// convert entity to Json
-	raw, fe := utils.JsonUtils().ToJson(in)
+	raw, fe := utils.JsonUtils().ToMap(in)
 	if fe != nil {
 		return in
 	}
@@ -372,6 +418,13 @@ func (s *inMemoryDatabaseQuery) filter(in Entity) (out Entity) {
 		}
 	}
 
+	// Apply boolean conditions
+	for _, condition := range s.conditions {
+		if matched, _ := testExpression(raw, condition); !matched {
+			return nil
+		}
+	}
+
 	return in
 }
 
@@ -408,4 +461,13 @@ func (s *inMemoryDatabaseQuery) ToString() string {
 	}
 }
 
+// ToJson gets the portable JSON representation of the query
+func (s *inMemoryDatabaseQuery) ToJson() ([]byte, error) {
+	spec, err := newQuerySpec(s.allFilters, s.anyFilters, s.conditions, s.ascOrders, s.descOrders, s.page, s.limit, s.rangeField, s.rangeFrom, s.rangeTo)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Json()
+}
+
 // endregion
@@ -40,6 +40,48 @@ func testField(raw map[string]any, filter QueryFilter) bool {
 	}
 }
 
+// Test the boolean filter expression, inactive filters are ignored (active is false when the expression has no active filter)
+func testExpression(raw map[string]any, expr *FilterExpression) (matched bool, active bool) {
+	if expr.Filter != nil {
+		if !expr.Filter.IsActive() {
+			return true, false
+		}
+		return testField(raw, expr.Filter), true
+	}
+
+	switch expr.Operator {
+	case AndOperator:
+		for _, child := range expr.Children {
+			if m, a := testExpression(raw, child); a {
+				active = true
+				if !m {
+					return false, true
+				}
+			}
+		}
+		return true, active
+	case OrOperator:
+		for _, child := range expr.Children {
+			if m, a := testExpression(raw, child); a {
+				active = true
+				if m {
+					return true, true
+				}
+			}
+		}
+		return !active, active
+	case NotOperator:
+		for _, child := range expr.Children {
+			if m, a := testExpression(raw, child); a {
+				return !m, true
+			}
+		}
+		return true, false
+	default:
+		return false, true
+	}
+}
+
 // equal
 func eq(raw map[string]any, filter QueryFilter) bool {
 	if entityVal, ok := raw[filter.GetField()]; ok {
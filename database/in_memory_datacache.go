@@ -1,9 +1,14 @@
 package database
 
 import (
+	"bytes"
 	"container/list"
+	"context"
 	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,11 +21,19 @@ import (
 
 // region Database store definitions -----------------------------------------------------------------------------------
 
+// default number of keys examined by Scan
+const defaultScanCount = 10
+
 // InMemoryDataCache represent in memory data cache
 type InMemoryDataCache struct {
-	keys   *cache.Cache[string, any]
-	lists  map[string]*list.List
-	queues map[string]collections.Queue
+	keys     *cache.Cache[string, any]
+	lists    map[string]*list.List
+	listsTTL map[string]time.Time
+	tags     map[string]map[string]bool // tag -> tagged keys
+	keyTags  map[string]map[string]bool // key -> tags of the key (to remove deleted and expired keys from the tags)
+	queues   map[string]collections.Queue
+	locks    map[string]*inMemoryLocker
+	codec    IEntityCodec
 
 	mu sync.RWMutex
 }
@@ -31,11 +44,34 @@ type InMemoryDataCache struct {
 
 // NewInMemoryDataCache is a factory method for DB store
 func NewInMemoryDataCache() (dc IDataCache, err error) {
-	return &InMemoryDataCache{
-		keys:   cache.NewTtlCache[string, any](),
-		lists:  make(map[string]*list.List),
-		queues: make(map[string]collections.Queue),
-	}, nil
+	imc := &InMemoryDataCache{
+		keys:     cache.NewTtlCache[string, any](),
+		lists:    make(map[string]*list.List),
+		listsTTL: make(map[string]time.Time),
+		tags:     make(map[string]map[string]bool),
+		keyTags:  make(map[string]map[string]bool),
+		queues:   make(map[string]collections.Queue),
+		locks:    make(map[string]*inMemoryLocker),
+		codec:    NewJsonCodec(),
+	}
+	imc.keys.SetExpirationCallback(func(key string, _ any) {
+		imc.untagExpired(key)
+	})
+	return imc, nil
+}
+
+// SetCodec sets the codec used to store key and hash values (default: JSON), values set before changing the codec can't
+// be read using Get
+func (dc *InMemoryDataCache) SetCodec(codec IEntityCodec) {
+	if codec == nil {
+		codec = NewJsonCodec()
+	}
+	dc.codec = codec
+}
+
+// Codec gets the codec used to store key and hash values
+func (dc *InMemoryDataCache) Codec() IEntityCodec {
+	return dc.codec
 }
 
 // Ping tests connectivity for retries number of time with time interval (in seconds) between retries
@@ -68,7 +104,7 @@ func (dc *InMemoryDataCache) Get(factory EntityFactory, key string) (result Enti
 	})
 
 	if value, ok := dc.keys.Get(key); ok {
-		return value.(Entity), nil
+		return dc.codec.Decode(factory, value.([]byte))
 	} else {
 		return nil, fmt.Errorf("key %s not found", key)
 	}
@@ -101,6 +137,17 @@ func (dc *InMemoryDataCache) GetKeys(factory EntityFactory, keys ...string) (res
 	return
 }
 
+// GetKeysTyped gets the value of all the given keys, each key with its own factory
+func (dc *InMemoryDataCache) GetKeysTyped(keys map[string]EntityFactory) (map[string]Entity, error) {
+	results := make(map[string]Entity)
+	for key, factory := range keys {
+		if entity, fe := dc.Get(factory, key); fe == nil {
+			results[key] = entity
+		}
+	}
+	return results, nil
+}
+
 // GetRawKeys gets the raw value of all the given keys
 func (dc *InMemoryDataCache) GetRawKeys(keys ...string) ([]Tuple[string, []byte], error) {
 	results := make([]Tuple[string, []byte], 0)
@@ -114,12 +161,11 @@ func (dc *InMemoryDataCache) GetRawKeys(keys ...string) ([]Tuple[string, []byte]
 
 // Set value of key with optional expiration
 func (dc *InMemoryDataCache) Set(key string, entity Entity, expiration ...time.Duration) (err error) {
-	if len(expiration) == 0 {
-		dc.keys.Set(key, entity)
-	} else {
-		dc.keys.SetWithTTL(key, entity, expiration[0])
+	data, err := dc.codec.Encode(entity)
+	if err != nil {
+		return err
 	}
-	return nil
+	return dc.SetRaw(key, data, expiration...)
 }
 
 // SetRaw sets the raw value of key with optional expiration
@@ -160,7 +206,7 @@ func (dc *InMemoryDataCache) SetRawNX(key string, bytes []byte, expiration ...ti
 
 // Add Set the value of a key only if the key does not exist
 func (dc *InMemoryDataCache) Add(key string, entity Entity, expiration time.Duration) (result bool, err error) {
-	if _, fe := dc.Get(nil, key); fe != nil {
+	if exists, _ := dc.Exists(key); !exists {
 		return true, dc.Set(key, entity, expiration)
 	} else {
 		return false, nil
@@ -169,18 +215,48 @@ func (dc *InMemoryDataCache) Add(key string, entity Entity, expiration time.Dura
 
 // AddRaw sets the raw value of a key only if the key does not exist
 func (dc *InMemoryDataCache) AddRaw(key string, bytes []byte, expiration time.Duration) (result bool, err error) {
-	if _, fe := dc.Get(nil, key); fe != nil {
+	if exists, _ := dc.Exists(key); !exists {
 		return true, dc.SetRaw(key, bytes, expiration)
 	} else {
 		return false, nil
 	}
 }
 
+// Incr increments the integer value of a key by delta and returns the new value
+func (dc *InMemoryDataCache) Incr(key string, delta int64, expiration ...time.Duration) (int64, error) {
+	// Thread safeguard (the read and the write must be atomic)
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	current := int64(0)
+	if value, ok := dc.keys.Get(key); ok {
+		bytes, isRaw := value.([]byte)
+		if !isRaw {
+			return 0, fmt.Errorf("key %s value is not an integer", key)
+		}
+		v, err := strconv.ParseInt(string(bytes), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("key %s value is not an integer", key)
+		}
+		current = v
+	}
+
+	current += delta
+	bytes := []byte(strconv.FormatInt(current, 10))
+	if len(expiration) > 0 {
+		dc.keys.SetWithTTL(key, bytes, expiration[0])
+	} else {
+		dc.keys.Set(key, bytes)
+	}
+	return current, nil
+}
+
 // Del Delete keys
 func (dc *InMemoryDataCache) Del(keys ...string) (err error) {
 	for _, key := range keys {
 		dc.keys.Delete(key)
 	}
+	dc.untag(keys...)
 	return nil
 }
 
@@ -194,10 +270,10 @@ func (dc *InMemoryDataCache) Rename(key string, newKey string) (err error) {
 		return fmt.Errorf("key %s already exists", newKey)
 	}
 
-	if entity, fe := dc.Get(nil, key); fe != nil {
+	if bytes, fe := dc.GetRaw(key); fe != nil {
 		return fe
 	} else {
-		_ = dc.Set(newKey, entity)
+		_ = dc.SetRaw(newKey, bytes)
 		_ = dc.Del(key)
 		return nil
 	}
@@ -209,23 +285,130 @@ func (dc *InMemoryDataCache) Exists(key string) (result bool, err error) {
 	return exists, nil
 }
 
-// Scan keys from the provided cursor
+// Scan keys from the provided cursor (0 to start the iteration), count is the number of keys to examine (default: 10)
+// and match is a glob-style pattern (e.g. "user:*", empty for all keys). The returned cursor is 0 when the iteration
+// is complete. Keys are iterated in the order of their hash, so keys existing during the whole iteration are returned
 func (dc *InMemoryDataCache) Scan(from uint64, match string, count int64) (keys []string, cursor uint64, err error) {
-	rex, _ := regexp.Compile(match)
+	if len(match) == 0 {
+		match = "*"
+	}
+	rex, err := regexp.Compile(utils.StringUtils().GlobToRegexp(match))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid match pattern %s: %w", match, err)
+	}
+	if count <= 0 {
+		count = defaultScanCount
+	}
 
-	keys = make([]string, 0)
-	cb := func(k string, v any) bool {
-		if rex != nil {
-			if rex.MatchString(fmt.Sprintf("%v", k)) {
-				keys = append(keys, fmt.Sprintf("%v", k))
-			}
-		} else {
-			keys = append(keys, fmt.Sprintf("%v", k))
+	// Collect the keys from the cursor sorted by hash
+	type scanEntry struct {
+		hash uint64
+		key  string
+	}
+	entries := make([]scanEntry, 0)
+	dc.keys.Range(func(k string, v any) bool {
+		if hash := utils.HashUtils().Hash64(k); hash >= from {
+			entries = append(entries, scanEntry{hash: hash, key: k})
 		}
 		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].hash == entries[j].hash {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].hash < entries[j].hash
+	})
+
+	// Examine count keys, keys with the same hash are always examined together
+	keys = make([]string, 0)
+	for i, entry := range entries {
+		if int64(i) >= count && entry.hash != entries[i-1].hash {
+			return keys, entry.hash, nil
+		}
+		if rex.MatchString(entry.key) {
+			keys = append(keys, entry.key)
+		}
 	}
-	dc.keys.Range(cb)
-	return
+	return keys, 0, nil
+}
+
+// endregion
+
+// region Tag actions ----------------------------------------------------------------------------------------------
+
+// SetWithTags sets value of key and adds the key to the tags sets for group invalidation
+func (dc *InMemoryDataCache) SetWithTags(key string, entity Entity, tags ...string) error {
+	if err := dc.Set(key, entity); err != nil {
+		return err
+	}
+
+	// Thread safeguard
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	for _, tag := range tags {
+		if _, ok := dc.tags[tag]; !ok {
+			dc.tags[tag] = make(map[string]bool)
+		}
+		dc.tags[tag][key] = true
+		if _, ok := dc.keyTags[key]; !ok {
+			dc.keyTags[key] = make(map[string]bool)
+		}
+		dc.keyTags[key][tag] = true
+	}
+	return nil
+}
+
+// InvalidateTag deletes all the keys associated with the tag and returns the number of deleted keys
+func (dc *InMemoryDataCache) InvalidateTag(tag string) (count int64, err error) {
+	// Thread safeguard
+	dc.mu.Lock()
+	keys := make([]string, 0, len(dc.tags[tag]))
+	for key := range dc.tags[tag] {
+		keys = append(keys, key)
+	}
+	dc.mu.Unlock()
+
+	for _, key := range keys {
+		if exists, _ := dc.Exists(key); exists {
+			dc.keys.Delete(key)
+			count += 1
+		}
+	}
+	dc.untag(keys...)
+	return count, nil
+}
+
+// remove the keys from all their tags, tags without keys are removed
+func (dc *InMemoryDataCache) untag(keys ...string) {
+	// Thread safeguard
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	for _, key := range keys {
+		dc.removeKeyTags(key)
+	}
+}
+
+// remove the expired key from its tags unless it was set again since it expired
+func (dc *InMemoryDataCache) untagExpired(key string) {
+	// Thread safeguard
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if exists, _ := dc.Exists(key); !exists {
+		dc.removeKeyTags(key)
+	}
+}
+
+// remove the key from its tags (the caller must hold the lock)
+func (dc *InMemoryDataCache) removeKeyTags(key string) {
+	for tag := range dc.keyTags[key] {
+		if delete(dc.tags[tag], key); len(dc.tags[tag]) == 0 {
+			delete(dc.tags, tag)
+		}
+	}
+	delete(dc.keyTags, key)
 }
 
 // endregion
@@ -244,31 +427,28 @@ func (dc *InMemoryDataCache) HGetRaw(key, field string) ([]byte, error) {
 	return dc.GetRaw(hKey)
 }
 
-// HKeys get all the fields in a hash
+// HKeys get all the field names in a hash (without the hash key)
 func (dc *InMemoryDataCache) HKeys(key string) (fields []string, err error) {
-	keys, _, fe := dc.Scan(0, key, 0)
-	return keys, fe
+	return dc.hashFields(key), nil
 }
 
-// HGetAll gets all the fields and values in a hash
+// HGetAll gets all the fields and values in a hash, mapped by the field name (without the hash key)
 func (dc *InMemoryDataCache) HGetAll(factory EntityFactory, key string) (result map[string]Entity, err error) {
 	result = make(map[string]Entity)
-	keys, _, err := dc.Scan(0, key, 0)
-	for _, k := range keys {
-		if entity, fe := dc.Get(factory, k); fe == nil {
-			result[k] = entity
+	for _, field := range dc.hashFields(key) {
+		if entity, fe := dc.HGet(factory, key, field); fe == nil {
+			result[field] = entity
 		}
 	}
 	return
 }
 
-// HGetRawAll gets all the fields and raw values in a hash
+// HGetRawAll gets all the fields and raw values in a hash, mapped by the field name (without the hash key)
 func (dc *InMemoryDataCache) HGetRawAll(key string) (result map[string][]byte, err error) {
 	result = make(map[string][]byte)
-	keys, _, err := dc.Scan(0, key, 0)
-	for _, k := range keys {
-		if bytes, fe := dc.GetRaw(k); fe == nil {
-			result[k] = bytes
+	for _, field := range dc.hashFields(key) {
+		if bytes, fe := dc.HGetRaw(key, field); fe == nil {
+			result[field] = bytes
 		}
 	}
 	return
@@ -335,6 +515,20 @@ func (dc *InMemoryDataCache) HAddRaw(key, field string, bytes []byte) (result bo
 	return dc.AddRaw(hKey, bytes, 0)
 }
 
+// get the field names of the hash (hash fields are stored as key@field keys)
+func (dc *InMemoryDataCache) hashFields(key string) []string {
+	prefix := key + "@"
+	fields := make([]string, 0)
+	dc.keys.Range(func(k string, v any) bool {
+		if strings.HasPrefix(k, prefix) {
+			fields = append(fields, k[len(prefix):])
+		}
+		return true
+	})
+	sort.Strings(fields)
+	return fields
+}
+
 // HExists Check if key exists
 func (dc *InMemoryDataCache) HExists(key, field string) (result bool, err error) {
 	hKey := fmt.Sprintf("%s@%s", key, field)
@@ -347,66 +541,54 @@ func (dc *InMemoryDataCache) HExists(key, field string) (result bool, err error)
 
 // RPush append (add to the right) one or multiple values to a list
 func (dc *InMemoryDataCache) RPush(key string, value ...Entity) (err error) {
-	// Ensure list exists
-	lst, ok := dc.lists[key]
-	if !ok {
-		lst = list.New()
-		lst.Init()
-		dc.lists[key] = lst
-	}
-
-	for _, val := range value {
-		lst.PushBack(val)
-	}
+	dc.getList(key, true, func(lst *list.List) {
+		for _, val := range value {
+			lst.PushBack(val)
+		}
+	})
 	return nil
 }
 
 // LPush Prepend (add to the left) one or multiple values to a list
 func (dc *InMemoryDataCache) LPush(key string, value ...Entity) (err error) {
-	// Ensure list exists
-	lst, ok := dc.lists[key]
-	if !ok {
-		lst = list.New()
-		lst.Init()
-		dc.lists[key] = lst
-	}
-
-	for _, val := range value {
-		lst.PushFront(val)
-	}
+	dc.getList(key, true, func(lst *list.List) {
+		for _, val := range value {
+			lst.PushFront(val)
+		}
+	})
 	return nil
 }
 
 // RPop Remove and get the last element in a list
 func (dc *InMemoryDataCache) RPop(factory EntityFactory, key string) (entity Entity, err error) {
-	// Ensure list exists
-	if lst, ok := dc.lists[key]; !ok {
-		return nil, fmt.Errorf("list %s not exists", key)
-	} else {
-		if e := lst.Back(); e != nil {
-			return nil, fmt.Errorf("end of list")
+	exists := dc.getList(key, false, func(lst *list.List) {
+		if e := lst.Back(); e == nil {
+			err = fmt.Errorf("end of list")
 		} else {
 			entity = e.Value.(Entity)
 			lst.Remove(e)
 		}
+	})
+	if !exists {
+		return nil, fmt.Errorf("list %s not exists", key)
 	}
-	return entity, nil
+	return entity, err
 }
 
 // LPop Remove and get the first element in a list
 func (dc *InMemoryDataCache) LPop(factory EntityFactory, key string) (entity Entity, err error) {
-	// Ensure list exists
-	if lst, ok := dc.lists[key]; !ok {
-		return nil, fmt.Errorf("list %s not exists", key)
-	} else {
-		if e := lst.Front(); e != nil {
-			return nil, fmt.Errorf("end of list")
+	exists := dc.getList(key, false, func(lst *list.List) {
+		if e := lst.Front(); e == nil {
+			err = fmt.Errorf("end of list")
 		} else {
 			entity = e.Value.(Entity)
 			lst.Remove(e)
 		}
+	})
+	if !exists {
+		return nil, fmt.Errorf("list %s not exists", key)
 	}
-	return entity, nil
+	return entity, err
 }
 
 // BRPop Remove and get the last element in a list or block until one is available
@@ -457,47 +639,238 @@ func (dc *InMemoryDataCache) blPop(keys ...string) (key string, entity Entity, e
 	return "", nil, false
 }
 
-// LRange Get a range of elements from list
+// LRange Get a range of elements from list, start and stop are inclusive zero-based indexes, negative indexes are
+// offsets from the end of the list (-1 is the last element)
 func (dc *InMemoryDataCache) LRange(factory EntityFactory, key string, start, stop int64) (result []Entity, err error) {
 	result = make([]Entity, 0)
 
-	index := int64(-1)
-
-	// Ensure list exists
-	if lst, ok := dc.lists[key]; !ok {
-		return nil, fmt.Errorf("key %s not found", key)
-	} else {
-		for e := lst.Front(); e != nil; e = e.Next() {
+	exists := dc.getList(key, false, func(lst *list.List) {
+		from, to := listRange(int64(lst.Len()), start, stop)
+		index := int64(0)
+		for e := lst.Front(); e != nil && index <= to; e = e.Next() {
+			if index >= from {
+				result = append(result, e.Value.(Entity))
+			}
 			index += 1
-			if index < start {
-				continue
+		}
+	})
+	if !exists {
+		return nil, fmt.Errorf("key %s not found", key)
+	}
+	return result, nil
+}
+
+// LTrim Trim the list to the range of elements (same index semantics as LRange), the list is deleted if the range is empty
+func (dc *InMemoryDataCache) LTrim(key string, start, stop int64) (err error) {
+	dc.getList(key, false, func(lst *list.List) {
+		from, to := listRange(int64(lst.Len()), start, stop)
+		index := int64(0)
+		for e := lst.Front(); e != nil; index += 1 {
+			next := e.Next()
+			if index < from || index > to {
+				lst.Remove(e)
 			}
-			if stop > 0 && index > stop {
-				continue
+			e = next
+		}
+	})
+	return nil
+}
+
+// LRem Remove the first count occurrences of the value from the list (count > 0: from head to tail, count < 0: from
+// tail to head, count = 0: all occurrences) and return the number of removed elements, values are compared by their
+// codec encoding (see SetCodec)
+func (dc *InMemoryDataCache) LRem(key string, count int64, value Entity) (removed int64, err error) {
+	target, err := dc.codec.Encode(value)
+	if err != nil {
+		return 0, err
+	}
+
+	dc.getList(key, false, func(lst *list.List) {
+		first, next := lst.Front, (*list.Element).Next
+		if count < 0 {
+			first, next, count = lst.Back, (*list.Element).Prev, -count
+		}
+		for e := first(); e != nil && (count == 0 || removed < count); {
+			following := next(e)
+			if data, er := dc.codec.Encode(e.Value.(Entity)); er == nil && bytes.Equal(data, target) {
+				lst.Remove(e)
+				removed += 1
 			}
-			result = append(result, e.Value.(Entity))
+			e = following
 		}
-		return
+	})
+	return removed, nil
+}
+
+// LSet Set the element at the index of the list (negative index is an offset from the end of the list)
+func (dc *InMemoryDataCache) LSet(key string, index int64, value Entity) (err error) {
+	exists := dc.getList(key, false, func(lst *list.List) {
+		length := int64(lst.Len())
+		if index < 0 {
+			index += length
+		}
+		if index < 0 || index >= length {
+			err = fmt.Errorf("index out of range")
+			return
+		}
+		e := lst.Front()
+		for i := int64(0); i < index; i++ {
+			e = e.Next()
+		}
+		e.Value = value
+	})
+	if !exists {
+		return fmt.Errorf("key %s not found", key)
 	}
+	return err
 }
 
 // LLen Get the length of a list
 func (dc *InMemoryDataCache) LLen(key string) (result int64) {
-	// Ensure list exists
-	if lst, ok := dc.lists[key]; !ok {
-		return 0
-	} else {
-		return int64(lst.Len())
+	dc.getList(key, false, func(lst *list.List) {
+		result = int64(lst.Len())
+	})
+	return result
+}
+
+// LExpire sets a timeout on a list, the list is deleted after the timeout expires, return false if the list does not exist
+func (dc *InMemoryDataCache) LExpire(key string, expiration time.Duration) (result bool, err error) {
+	result = dc.getList(key, false, func(lst *list.List) {
+		if expiration <= 0 {
+			lst.Init()
+		} else {
+			dc.listsTTL[key] = time.Now().Add(expiration)
+		}
+	})
+	return result, nil
+}
+
+// convert the Redis-style range (negative indexes are offsets from the end) to the inclusive range of the list
+// indexes, the range is empty (from > to) if it is out of the list
+func listRange(length, start, stop int64) (from, to int64) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
 	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	return start, stop
+}
+
+// run the action on the list by key while holding the lock (expired lists are deleted), a new list is created if not
+// exists and create is true, the list is deleted if it is empty after the action. Return false if the list does not exist
+func (dc *InMemoryDataCache) getList(key string, create bool, action func(lst *list.List)) bool {
+	// Thread safeguard
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if expiresOn, ok := dc.listsTTL[key]; ok && !time.Now().Before(expiresOn) {
+		delete(dc.lists, key)
+		delete(dc.listsTTL, key)
+	}
+
+	lst, ok := dc.lists[key]
+	if !ok {
+		if !create {
+			return false
+		}
+		lst = list.New()
+		dc.lists[key] = lst
+	}
+
+	action(lst)
+
+	if lst.Len() == 0 {
+		delete(dc.lists, key)
+		delete(dc.listsTTL, key)
+	}
+	return true
 }
 
 // endregion
 
 // region List actions ---------------------------------------------------------------------------------------------
 
-// ObtainLocker tries to obtain a new lock using a key with the given TTL
+// ObtainLocker tries to obtain a new lock using a key with the given TTL, fails if the key is locked and not expired
 func (dc *InMemoryDataCache) ObtainLocker(key string, ttl time.Duration) (ILocker, error) {
-	return nil, fmt.Errorf("locker not supported in this implementation")
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if current, ok := dc.locks[key]; ok && time.Now().Before(current.expires) {
+		return nil, fmt.Errorf("lock %s not obtained", key)
+	}
+	locker := &inMemoryLocker{dc: dc, key: key, token: NanoID(), expires: time.Now().Add(ttl)}
+	dc.locks[key] = locker
+	return locker, nil
+}
+
+// endregion
+
+// region Locker -------------------------------------------------------------------------------------------------------
+
+// inMemoryLocker is the in memory implementation of ILocker
+type inMemoryLocker struct {
+	dc      *InMemoryDataCache
+	key     string
+	token   string
+	expires time.Time
+}
+
+// Key returns the locker key
+func (l *inMemoryLocker) Key() string {
+	return l.key
+}
+
+// Token returns the token value set by the lock.
+func (l *inMemoryLocker) Token() string {
+	return l.token
+}
+
+// TTL returns the remaining time-to-live. Returns 0 if the lock has expired.
+func (l *inMemoryLocker) TTL(ctx context.Context) (time.Duration, error) {
+	l.dc.mu.RLock()
+	defer l.dc.mu.RUnlock()
+
+	if !l.isHeld() {
+		return 0, nil
+	}
+	return time.Until(l.expires), nil
+}
+
+// Refresh extends the lock with a new TTL.
+func (l *inMemoryLocker) Refresh(ctx context.Context, ttl time.Duration) error {
+	l.dc.mu.Lock()
+	defer l.dc.mu.Unlock()
+
+	if !l.isHeld() {
+		return fmt.Errorf("lock %s not held", l.key)
+	}
+	l.expires = time.Now().Add(ttl)
+	return nil
+}
+
+// Release manually releases the lock.
+func (l *inMemoryLocker) Release(ctx context.Context) error {
+	l.dc.mu.Lock()
+	defer l.dc.mu.Unlock()
+
+	if !l.isHeld() {
+		return fmt.Errorf("lock %s not held", l.key)
+	}
+	delete(l.dc.locks, l.key)
+	return nil
+}
+
+// check if the lock is still held by this locker (under lock)
+func (l *inMemoryLocker) isHeld() bool {
+	current, ok := l.dc.locks[l.key]
+	return ok && current == l && time.Now().Before(l.expires)
 }
 
 // endregion
@@ -22,7 +22,8 @@ const (
 
 // InMemoryDatastore Represent a db with tables
 type InMemoryDatastore struct {
-	db map[string]ITable
+	db     map[string]ITable
+	strict bool
 }
 
 // Resolve index name from entity name
@@ -62,6 +63,12 @@ func NewInMemoryDatastore() (dbs IDatastore, err error) {
 	return &InMemoryDatastore{db: make(map[string]ITable)}, nil
 }
 
+// SetStrictMode enables or disables strict mode, in strict mode queries with unknown fields, unsupported operators or
+// type mismatches return errors (like the real adapters) instead of silently returning no results
+func (dbs *InMemoryDatastore) SetStrictMode(strict bool) {
+	dbs.strict = strict
+}
+
 // Ping tests database connectivity for retries number of time with time interval (in seconds) between retries
 func (dbs *InMemoryDatastore) Ping(retries uint, interval uint) error {
 	logger.Debug("Pinging %d times with %d interval", retries, interval)
@@ -91,7 +98,7 @@ func (dbs *InMemoryDatastore) Get(factory EntityFactory, entityID string, keys .
 	if tbl, ok := dbs.db[index]; ok {
 		return tbl.Get(entityID)
 	} else {
-		return nil, fmt.Errorf(INDEX_NOT_EXISTS)
+		return nil, ErrIndexNotExists
 	}
 }
 
@@ -109,7 +116,7 @@ func (dbs *InMemoryDatastore) List(factory EntityFactory, entityIDs []string, ke
 			}
 		}
 	} else {
-		return list, fmt.Errorf(INDEX_NOT_EXISTS)
+		return list, ErrIndexNotExists
 	}
 	return
 }
@@ -122,7 +129,7 @@ func (dbs *InMemoryDatastore) Exists(factory EntityFactory, entityID string, key
 	if tbl, ok := dbs.db[index]; ok {
 		return tbl.Exists(entityID)
 	} else {
-		return false, fmt.Errorf(INDEX_NOT_EXISTS)
+		return false, ErrIndexNotExists
 	}
 }
 
@@ -155,7 +162,7 @@ func (dbs *InMemoryDatastore) Upsert(entity Entity) (updated Entity, err error)
 	if tbl, ok := dbs.db[index]; ok {
 		return tbl.Upsert(entity)
 	} else {
-		return nil, fmt.Errorf(INDEX_NOT_EXISTS)
+		return nil, ErrIndexNotExists
 	}
 }
 
@@ -165,7 +172,7 @@ func (dbs *InMemoryDatastore) Delete(factory EntityFactory, entityID string, key
 	if tbl, ok := dbs.db[index]; ok {
 		return tbl.Delete(entityID)
 	} else {
-		return fmt.Errorf(INDEX_NOT_EXISTS)
+		return ErrIndexNotExists
 	}
 }
 
@@ -237,7 +244,7 @@ func (dbs *InMemoryDatastore) SetFields(factory EntityFactory, entityID string,
 	}
 
 	// convert entity to Json
-	js, fe := utils.JsonUtils().ToJson(entity)
+	js, fe := utils.JsonUtils().ToMap(entity)
 	if fe != nil {
 		return fe
 	}
@@ -247,7 +254,7 @@ func (dbs *InMemoryDatastore) SetFields(factory EntityFactory, entityID string,
 		js[k] = v
 	}
 
-	toSet, fe := utils.JsonUtils().FromJson(factory, js)
+	toSet, fe := utils.JsonUtils().FromMap(factory, js)
 	if fe != nil {
 		return fe
 	}
@@ -268,7 +275,7 @@ func (dbs *InMemoryDatastore) BulkSetFields(factory EntityFactory, field string,
 	// convert entity to Json
 	count := 0
 	for _, entity := range list {
-		js, err := utils.JsonUtils().ToJson(entity)
+		js, err := utils.JsonUtils().ToMap(entity)
 		if err != nil {
 			return 0, err
 		}
@@ -277,7 +284,7 @@ func (dbs *InMemoryDatastore) BulkSetFields(factory EntityFactory, field string,
 		if val, ok := values[entity.ID()]; ok {
 			js[field] = val
 
-			if toSet, _ := utils.JsonUtils().FromJson(factory, js); toSet != nil {
+			if toSet, _ := utils.JsonUtils().FromMap(factory, js); toSet != nil {
 				if _, er := dbs.Update(toSet); er == nil {
 					count += 1
 				}
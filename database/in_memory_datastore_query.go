@@ -16,6 +16,7 @@ type inMemoryDatastoreQuery struct {
 	factory    EntityFactory            // The entity factory method
 	allFilters [][]QueryFilter          // List of lists of AND filters
 	anyFilters [][]QueryFilter          // List of lists of OR filters
+	conditions []*FilterExpression      // List of boolean conditions (AND)
 	ascOrders  []any                    // List of fields for ASC order
 	descOrders []any                    // List of fields for DESC order
 	callbacks  []func(in Entity) Entity // List of entity transformation callback functions
@@ -75,7 +76,30 @@ func (s *inMemoryDatastoreQuery) MatchAny(filters ...QueryFilter) IQuery {
 			list = append(list, filter)
 		}
 	}
-	s.anyFilters = append(s.allFilters, list)
+	s.anyFilters = append(s.anyFilters, list)
+	return s
+}
+
+// And adds a boolean condition, all the conditions should be satisfied (AND operator equivalent)
+func (s *inMemoryDatastoreQuery) And(conditions ...Condition) IQuery {
+	return s.addCondition(And(conditions...))
+}
+
+// Or adds a boolean condition, any of the conditions should be satisfied (OR operator equivalent)
+func (s *inMemoryDatastoreQuery) Or(conditions ...Condition) IQuery {
+	return s.addCondition(Or(conditions...))
+}
+
+// Not adds a boolean condition, the condition should not be satisfied (NOT operator equivalent)
+func (s *inMemoryDatastoreQuery) Not(condition Condition) IQuery {
+	return s.addCondition(Not(condition))
+}
+
+// add the condition unless it has no active filters
+func (s *inMemoryDatastoreQuery) addCondition(expr *FilterExpression) IQuery {
+	if !expr.isEmpty() {
+		s.conditions = append(s.conditions, expr)
+	}
 	return s
 }
 
@@ -139,7 +163,11 @@ func (s *inMemoryDatastoreQuery) Find(keys ...string) (out []Entity, total int64
 
 	tbl, ok := s.db.db[index]
 	if !ok {
-		return nil, 0, fmt.Errorf(INDEX_NOT_EXISTS)
+		return nil, 0, ErrIndexNotExists
+	}
+
+	if err = s.validate(); err != nil {
+		return
 	}
 
 	// If range is defined, add it to the filters
@@ -178,7 +206,11 @@ func (s *inMemoryDatastoreQuery) Count(keys ...string) (total int64, err error)
 
 	tbl, ok := s.db.db[table]
 	if !ok {
-		return 0, fmt.Errorf(TABLE_NOT_EXISTS)
+		return 0, ErrIndexNotExists
+	}
+
+	if err = s.validate(); err != nil {
+		return
 	}
 
 	// If range is defined, add it to the filters
@@ -316,7 +348,7 @@ func (s *inMemoryDatastoreQuery) SetFields(fields map[string]any, keys ...string
 	}
 
 	for _, entity := range list {
-		raw, er := utils.JsonUtils().ToJson(entity)
+		raw, er := utils.JsonUtils().ToMap(entity)
 		if er != nil {
 			continue
 		}
@@ -325,7 +357,7 @@ func (s *inMemoryDatastoreQuery) SetFields(fields map[string]any, keys ...string
 			raw[f] = v
 		}
 
-		if changed, _ := utils.JsonUtils().FromJson(s.factory, raw); changed != nil {
+		if changed, _ := utils.JsonUtils().FromMap(s.factory, raw); changed != nil {
 			changeList = append(changeList, changed)
 		}
 	}
@@ -340,11 +372,25 @@ func (s *inMemoryDatastoreQuery) SetFields(fields map[string]any, keys ...string
 // endregion
 
 // region QueryBuilder Internal Methods --------------------------------------------------------------------------------
+
+// validate the query in strict mode
+func (s *inMemoryDatastoreQuery) validate() error {
+	if !s.db.strict {
+		return nil
+	}
+	orders := append(append([]any{}, s.ascOrders...), s.descOrders...)
+	filters := append(append([][]QueryFilter{}, s.allFilters...), s.anyFilters...)
+	for _, condition := range s.conditions {
+		filters = append(filters, condition.Filters())
+	}
+	return validateQuery(s.factory, filters, orders, s.rangeField)
+}
+
 // Filter entity based on conditions
 func (s *inMemoryDatastoreQuery) filter(in Entity) (out Entity) {
 
 	// convert entity to Json
-	raw, fe := utils.JsonUtils().ToJson(in)
+	raw, fe := utils.JsonUtils().ToMap(in)
 	if fe != nil {
 		return in
 	}
@@ -373,6 +419,13 @@ func (s *inMemoryDatastoreQuery) filter(in Entity) (out Entity) {
 			return nil
 		}
 	}
+
+	// Apply boolean conditions
+	for _, condition := range s.conditions {
+		if matched, _ := testExpression(raw, condition); !matched {
+			return nil
+		}
+	}
 	return in
 }
 
@@ -409,4 +462,13 @@ func (s *inMemoryDatastoreQuery) ToString() string {
 	}
 }
 
+// ToJson gets the portable JSON representation of the query
+func (s *inMemoryDatastoreQuery) ToJson() ([]byte, error) {
+	spec, err := newQuerySpec(s.allFilters, s.anyFilters, s.conditions, s.ascOrders, s.descOrders, s.page, s.limit, s.rangeField, s.rangeFrom, s.rangeTo)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Json()
+}
+
 // endregion
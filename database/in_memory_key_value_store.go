@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// region Key-value store definitions ----------------------------------------------------------------------------------
+
+// InMemoryKeyValueStore represent in memory key-value store
+type InMemoryKeyValueStore struct {
+	values   map[string][]byte
+	watchers keyValueWatchers
+
+	// persist the values after each change (used by the file backed store), the change is rolled back on error
+	persist func(values map[string][]byte) error
+
+	mu sync.RWMutex
+}
+
+// endregion
+
+// region Factory and connectivity methods -----------------------------------------------------------------------------
+
+// NewInMemoryKeyValueStore is a factory method for in memory key-value store
+func NewInMemoryKeyValueStore() (IKeyValueStore, error) {
+	return newInMemoryKeyValueStore(nil), nil
+}
+
+func newInMemoryKeyValueStore(values map[string][]byte) *InMemoryKeyValueStore {
+	if values == nil {
+		values = make(map[string][]byte)
+	}
+	return &InMemoryKeyValueStore{values: values}
+}
+
+// Ping tests connectivity for retries number of time with time interval (in seconds) between retries
+func (s *InMemoryKeyValueStore) Ping(retries uint, interval uint) error {
+	return nil
+}
+
+// Close key-value store and free resources
+func (s *InMemoryKeyValueStore) Close() error {
+	logger.Debug("In memory key-value store closed")
+	return nil
+}
+
+// endregion
+
+// region Key actions --------------------------------------------------------------------------------------------------
+
+// Get the value of a key (ErrKeyNotFound if the key does not exist)
+func (s *InMemoryKeyValueStore) Get(key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if value, ok := s.values[key]; ok {
+		return append([]byte{}, value...), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+}
+
+// Set the value of a key
+func (s *InMemoryKeyValueStore) Set(key string, value []byte) error {
+	if len(key) == 0 {
+		return fmt.Errorf("missing key")
+	}
+	value = append([]byte{}, value...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, existed := s.values[key]
+	s.values[key] = value
+	if err := s.save(); err != nil {
+		if existed {
+			s.values[key] = previous
+		} else {
+			delete(s.values, key)
+		}
+		return err
+	}
+	s.watchers.publish(KeyValueEvent{Key: key, Value: append([]byte{}, value...)})
+	return nil
+}
+
+// Delete keys, keys which do not exist are ignored
+func (s *InMemoryKeyValueStore) Delete(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := make(map[string][]byte)
+	for _, key := range keys {
+		if value, ok := s.values[key]; ok {
+			deleted[key] = value
+			delete(s.values, key)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		for key, value := range deleted {
+			s.values[key] = value
+		}
+		return err
+	}
+	for _, key := range keys {
+		if _, ok := deleted[key]; ok {
+			s.watchers.publish(KeyValueEvent{Key: key, Deleted: true})
+		}
+	}
+	return nil
+}
+
+// Keys returns the sorted keys with the prefix (empty prefix for all keys)
+func (s *InMemoryKeyValueStore) Keys(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]string, 0)
+	for key := range s.values {
+		if strings.HasPrefix(key, prefix) {
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Watch returns a channel of the changes of keys with the prefix, the channel is closed when the context is done
+func (s *InMemoryKeyValueStore) Watch(ctx context.Context, prefix string) (<-chan KeyValueEvent, error) {
+	return s.watchers.add(ctx, prefix), nil
+}
+
+// save the values if the store is persistent
+func (s *InMemoryKeyValueStore) save() error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist(s.values)
+}
+
+// endregion
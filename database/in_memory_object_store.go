@@ -0,0 +1,138 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// region Object store definitions -------------------------------------------------------------------------------------
+
+// InMemoryObjectStore represent in memory object store
+type InMemoryObjectStore struct {
+	objects map[string]*inMemoryObject
+	signer  objectUrlSigner
+
+	mu sync.RWMutex
+}
+
+// inMemoryObject is the stored object content and info
+type inMemoryObject struct {
+	info ObjectInfo
+	data []byte
+}
+
+// endregion
+
+// region Factory and connectivity methods -----------------------------------------------------------------------------
+
+// NewInMemoryObjectStore is a factory method for in memory object store, the base URL and secret are used to create
+// signed URLs (optional, see NewObjectStoreHandler)
+func NewInMemoryObjectStore(baseUrl string, secret []byte) (IObjectStore, error) {
+	return &InMemoryObjectStore{
+		objects: make(map[string]*inMemoryObject),
+		signer:  objectUrlSigner{baseUrl: baseUrl, secret: secret},
+	}, nil
+}
+
+// Ping tests connectivity for retries number of time with time interval (in seconds) between retries
+func (s *InMemoryObjectStore) Ping(retries uint, interval uint) error {
+	return nil
+}
+
+// Close object store and free resources
+func (s *InMemoryObjectStore) Close() error {
+	logger.Debug("In memory object store closed")
+	return nil
+}
+
+// endregion
+
+// region Object actions -----------------------------------------------------------------------------------------------
+
+// Put stores the object content read from the reader, an existing object is replaced
+func (s *InMemoryObjectStore) Put(ctx context.Context, key string, reader io.Reader, options ...ObjectOption) (ObjectInfo, error) {
+	if err := validateObjectKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("read object %s failed: %w", key, err)
+	}
+	if err = ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info := newObjectInfo(key, options)
+	hash := md5.Sum(data)
+	info.ETag = hex.EncodeToString(hash[:])
+	info.Size = int64(len(data))
+	info.LastModified = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = &inMemoryObject{info: info, data: data}
+	return info.clone(), nil
+}
+
+// Get returns the object content reader (must be closed by the caller) and the object info
+func (s *InMemoryObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if obj, ok := s.objects[key]; ok {
+		return io.NopCloser(bytes.NewReader(obj.data)), obj.info.clone(), nil
+	}
+	return nil, ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+}
+
+// Stat returns the object info without the content
+func (s *InMemoryObjectStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if obj, ok := s.objects[key]; ok {
+		return obj.info.clone(), nil
+	}
+	return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+}
+
+// Delete objects, keys which do not exist are ignored
+func (s *InMemoryObjectStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.objects, key)
+	}
+	return nil
+}
+
+// List objects with the key prefix sorted by key, starting after the cursor key
+func (s *InMemoryObjectStore) List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, string, error) {
+	s.mu.RLock()
+	sorted := make([]ObjectInfo, 0, len(s.objects))
+	for _, obj := range s.objects {
+		sorted = append(sorted, obj.info.clone())
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	page, next := objectsPage(sorted, prefix, cursor, limit)
+	return page, next, nil
+}
+
+// SignedURL returns a URL granting temporary access to the object for the HTTP method (GET or PUT)
+func (s *InMemoryObjectStore) SignedURL(ctx context.Context, key string, method string, expiration time.Duration) (string, error) {
+	return s.signer.sign(key, method, expiration)
+}
+
+// endregion
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+// region Strict mode query validation ---------------------------------------------------------------------------------
+
+// In strict mode, the in-memory queries validate the filters before execution and return errors for unknown fields,
+// unsupported operators and type mismatches (like the real database adapters) instead of silently returning no results
+
+// validate query filters, sort fields and range field against the entity structure
+func validateQuery(factory EntityFactory, filters [][]QueryFilter, orders []any, rangeField string) error {
+	fields := entityFieldKinds(factory())
+
+	for _, list := range filters {
+		for _, filter := range list {
+			if err := validateFilter(fields, filter); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, order := range orders {
+		if _, ok := fields[fmt.Sprintf("%v", order)]; !ok {
+			return fmt.Errorf("unknown sort field: %v", order)
+		}
+	}
+
+	if len(rangeField) > 0 {
+		if kind, ok := fields[rangeField]; !ok {
+			return fmt.Errorf("unknown range field: %s", rangeField)
+		} else if !isNumericKind(kind) {
+			return fmt.Errorf("range field %s must be a timestamp", rangeField)
+		}
+	}
+	return nil
+}
+
+// validate single filter: field exists, operator is supported and values match the field type
+func validateFilter(fields map[string]reflect.Kind, filter QueryFilter) error {
+	if !filter.IsActive() {
+		return nil
+	}
+
+	field := filter.GetField()
+	kind, ok := fields[field]
+	if !ok {
+		return fmt.Errorf("unknown field: %s", field)
+	}
+
+	operator := filter.GetOperator()
+	if _, supported := operators[operator]; !supported {
+		return fmt.Errorf("operator %s is not supported", operator)
+	}
+
+	switch operator {
+	case Gt, Gte, Lt, Lte, Between:
+		if !isNumericKind(kind) {
+			return fmt.Errorf("operator %s is not supported for non numeric field: %s", operator, field)
+		}
+	case Like:
+		if kind != reflect.String {
+			return fmt.Errorf("operator %s is not supported for non string field: %s", operator, field)
+		}
+		return nil
+	case Contains:
+		if kind != reflect.Slice && kind != reflect.Array {
+			return fmt.Errorf("operator %s is not supported for non array field: %s", operator, field)
+		}
+		return nil
+	case Empty:
+		return nil
+	}
+
+	for _, value := range filter.GetValues() {
+		if err := validateValue(kind, fmt.Sprintf("%v", value)); err != nil {
+			return fmt.Errorf("invalid value for field %s: %s", field, err.Error())
+		}
+	}
+	return nil
+}
+
+// validate that the value can be converted to the field type
+func validateValue(kind reflect.Kind, value string) error {
+	if isNumericKind(kind) {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return fmt.Errorf("%s is not a number", value)
+		}
+	}
+	if kind == reflect.Bool {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s is not a boolean", value)
+		}
+	}
+	return nil
+}
+
+// check if the kind is numeric (including Timestamp)
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// get the json field names of the entity and their kinds (the same names used by the struct mapping and data import)
+func entityFieldKinds(entity Entity) map[string]reflect.Kind {
+	types := utils.JsonUtils().FieldTypes(entity)
+	fields := make(map[string]reflect.Kind, len(types))
+	for name, ft := range types {
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		fields[name] = ft.Kind()
+	}
+	return fields
+}
+
+// endregion
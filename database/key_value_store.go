@@ -0,0 +1,133 @@
+// Package database
+//
+// General interface for durable key-value store of settings and state (e.g. etcd, Consul KV)
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ErrKeyNotFound is returned when the key does not exist in the key-value store
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyValueEvent describes a change of a key
+type KeyValueEvent struct {
+	Key     string `json:"key"`     // Changed key
+	Value   []byte `json:"value"`   // New value (nil when the key was deleted)
+	Deleted bool   `json:"deleted"` // The key was deleted
+}
+
+// IKeyValueStore is a durable key-value store for settings and state. Unlike IDataCache, keys have no expiration and
+// are never evicted
+type IKeyValueStore interface {
+
+	// Closer includes method Close()
+	io.Closer
+
+	// Ping tests connectivity for retries number of time with time interval (in seconds) between retries
+	Ping(retries uint, intervalInSeconds uint) error
+
+	// Get the value of a key (ErrKeyNotFound if the key does not exist)
+	Get(key string) ([]byte, error)
+
+	// Set the value of a key
+	Set(key string, value []byte) error
+
+	// Delete keys, keys which do not exist are ignored
+	Delete(keys ...string) error
+
+	// Keys returns the sorted keys with the prefix (empty prefix for all keys)
+	Keys(prefix string) ([]string, error)
+
+	// Watch returns a channel of the changes of keys with the prefix, the channel is closed when the context is done
+	Watch(ctx context.Context, prefix string) (<-chan KeyValueEvent, error)
+}
+
+// region Watchers -----------------------------------------------------------------------------------------------------
+
+// keyValueWatchers dispatches the key changes to the watchers, each watcher has its own queue so a slow watcher does
+// not block the store updates
+type keyValueWatchers struct {
+	mu       sync.Mutex
+	watchers map[*keyValueWatcher]struct{}
+}
+
+// keyValueWatcher is a single watch subscription
+type keyValueWatcher struct {
+	prefix string
+	mu     sync.Mutex
+	queue  []KeyValueEvent
+	signal chan struct{}
+}
+
+// add a watcher of the prefix, the watcher is removed when the context is done
+func (w *keyValueWatchers) add(ctx context.Context, prefix string) <-chan KeyValueEvent {
+	watcher := &keyValueWatcher{prefix: prefix, signal: make(chan struct{}, 1)}
+
+	w.mu.Lock()
+	if w.watchers == nil {
+		w.watchers = make(map[*keyValueWatcher]struct{})
+	}
+	w.watchers[watcher] = struct{}{}
+	w.mu.Unlock()
+
+	out := make(chan KeyValueEvent)
+	go func() {
+		defer close(out)
+		defer func() {
+			w.mu.Lock()
+			delete(w.watchers, watcher)
+			w.mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-watcher.signal:
+			}
+			for _, event := range watcher.drain() {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// publish the event to the watchers of the key
+func (w *keyValueWatchers) publish(event KeyValueEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for watcher := range w.watchers {
+		if !strings.HasPrefix(event.Key, watcher.prefix) {
+			continue
+		}
+		watcher.mu.Lock()
+		watcher.queue = append(watcher.queue, event)
+		watcher.mu.Unlock()
+		select {
+		case watcher.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// drain the queued events
+func (w *keyValueWatcher) drain() []KeyValueEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	events := w.queue
+	w.queue = nil
+	return events
+}
+
+// endregion
@@ -0,0 +1,283 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+const (
+	localObjectsMetadataDir = ".metadata" // Object info files (content type, metadata, etag)
+	localObjectsUploadsDir  = ".uploads"  // Temporary files of objects being stored
+)
+
+// region Object store definitions -------------------------------------------------------------------------------------
+
+// LocalObjectStore represent object store on the local file system, each object is stored as a file under the root
+// folder (the key is the relative path) and the object info is stored in a sidecar file
+type LocalObjectStore struct {
+	root   string
+	signer objectUrlSigner
+
+	mu sync.RWMutex
+}
+
+// endregion
+
+// region Factory and connectivity methods -----------------------------------------------------------------------------
+
+// NewLocalObjectStore is a factory method for local file system object store, the root folder is created if it does not
+// exist. The base URL and secret are used to create signed URLs (optional, see NewObjectStoreHandler)
+func NewLocalObjectStore(root string, baseUrl string, secret []byte) (IObjectStore, error) {
+	for _, dir := range []string{root, filepath.Join(root, localObjectsMetadataDir), filepath.Join(root, localObjectsUploadsDir)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create object store folder failed: %w", err)
+		}
+	}
+	return &LocalObjectStore{root: root, signer: objectUrlSigner{baseUrl: baseUrl, secret: secret}}, nil
+}
+
+// Ping tests connectivity for retries number of time with time interval (in seconds) between retries
+func (s *LocalObjectStore) Ping(retries uint, interval uint) error {
+	if _, err := os.Stat(s.root); err != nil {
+		return fmt.Errorf("object store folder is not accessible: %w", err)
+	}
+	return nil
+}
+
+// Close object store and free resources
+func (s *LocalObjectStore) Close() error {
+	logger.Debug("Local object store closed")
+	return nil
+}
+
+// endregion
+
+// region Object actions -----------------------------------------------------------------------------------------------
+
+// Put stores the object content read from the reader, an existing object is replaced. The content is written to a
+// temporary file which replaces the object file once completed
+func (s *LocalObjectStore) Put(ctx context.Context, key string, reader io.Reader, options ...ObjectOption) (ObjectInfo, error) {
+	if err := s.validateKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	temp, err := os.CreateTemp(filepath.Join(s.root, localObjectsUploadsDir), "upload-*")
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer func() {
+		_ = os.Remove(temp.Name())
+	}()
+
+	hash := md5.New()
+	size, err := io.Copy(io.MultiWriter(temp, hash), reader)
+	if er := temp.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("write object %s failed: %w", key, err)
+	}
+	if err = ctx.Err(); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info := newObjectInfo(key, options)
+	info.ETag = hex.EncodeToString(hash.Sum(nil))
+	info.Size = size
+	info.LastModified = time.Now()
+	infoBytes, err := json.Marshal(info)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objectPath, metadataPath := s.paths(key)
+	for _, path := range []string{objectPath, metadataPath} {
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return ObjectInfo{}, err
+		}
+	}
+	if err = os.WriteFile(metadataPath, infoBytes, 0o644); err != nil {
+		return ObjectInfo{}, err
+	}
+	if err = os.Rename(temp.Name(), objectPath); err != nil {
+		return ObjectInfo{}, err
+	}
+	return info, nil
+}
+
+// Get returns the object content reader (must be closed by the caller) and the object info
+func (s *LocalObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error) {
+	if err := s.validateKey(key); err != nil {
+		return nil, ObjectInfo{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	info, err := s.stat(key)
+	if err != nil {
+		return nil, ObjectInfo{}, err
+	}
+	objectPath, _ := s.paths(key)
+	file, err := os.Open(objectPath)
+	if err != nil {
+		return nil, ObjectInfo{}, s.notFound(key, err)
+	}
+	return file, info, nil
+}
+
+// Stat returns the object info without the content
+func (s *LocalObjectStore) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := s.validateKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stat(key)
+}
+
+// Delete objects, keys which do not exist are ignored
+func (s *LocalObjectStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if err := s.validateKey(key); err != nil {
+			return err
+		}
+		objectPath, metadataPath := s.paths(key)
+		for _, path := range []string{objectPath, metadataPath} {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		s.removeEmptyDirs(filepath.Dir(objectPath), s.root)
+		s.removeEmptyDirs(filepath.Dir(metadataPath), filepath.Join(s.root, localObjectsMetadataDir))
+	}
+	return nil
+}
+
+// List objects with the key prefix sorted by key, starting after the cursor key
+func (s *LocalObjectStore) List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0)
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(s.root, path)
+		if d.IsDir() {
+			if rel == localObjectsMetadataDir || rel == localObjectsUploadsDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Strings(keys)
+	sorted := make([]ObjectInfo, 0, len(keys))
+	for _, key := range keys {
+		if info, er := s.stat(key); er == nil {
+			sorted = append(sorted, info)
+		}
+	}
+	page, next := objectsPage(sorted, prefix, cursor, limit)
+	return page, next, nil
+}
+
+// SignedURL returns a URL granting temporary access to the object for the HTTP method (GET or PUT)
+func (s *LocalObjectStore) SignedURL(ctx context.Context, key string, method string, expiration time.Duration) (string, error) {
+	return s.signer.sign(key, method, expiration)
+}
+
+// endregion
+
+// region PRIVATE SECTION ----------------------------------------------------------------------------------------------
+
+// validate the key, the store folders are reserved
+func (s *LocalObjectStore) validateKey(key string) error {
+	if err := validateObjectKey(key); err != nil {
+		return err
+	}
+	first, _, _ := strings.Cut(key, "/")
+	if first == localObjectsMetadataDir || first == localObjectsUploadsDir {
+		return fmt.Errorf("invalid object key: %s", key)
+	}
+	return nil
+}
+
+// object file and object info file paths
+func (s *LocalObjectStore) paths(key string) (string, string) {
+	rel := filepath.FromSlash(key)
+	return filepath.Join(s.root, rel), filepath.Join(s.root, localObjectsMetadataDir, rel+".json")
+}
+
+// read the object info, objects stored without the store (no info file) get default info
+func (s *LocalObjectStore) stat(key string) (ObjectInfo, error) {
+	objectPath, metadataPath := s.paths(key)
+	fi, err := os.Stat(objectPath)
+	if err != nil {
+		return ObjectInfo{}, s.notFound(key, err)
+	}
+	if fi.IsDir() {
+		return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+
+	info := newObjectInfo(key, nil)
+	if data, er := os.ReadFile(metadataPath); er == nil {
+		if er = json.Unmarshal(data, &info); er != nil {
+			return ObjectInfo{}, fmt.Errorf("invalid object info of %s: %w", key, er)
+		}
+	}
+	info.Key = key
+	info.Size = fi.Size()
+	info.LastModified = fi.ModTime()
+	return info, nil
+}
+
+// convert not exist error to ErrObjectNotFound
+func (s *LocalObjectStore) notFound(key string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+	}
+	return err
+}
+
+// remove empty parent folders up to the top folder (excluded)
+func (s *LocalObjectStore) removeEmptyDirs(dir, top string) {
+	for dir != top && strings.HasPrefix(dir, top) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// endregion
@@ -0,0 +1,254 @@
+// Package database
+//
+// General interface for object (blob) storage (e.g. S3, GCS, Azure Blob storage)
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+// ErrObjectNotFound is returned when the object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string            `json:"key"`          // Object key (path like, e.g. reports/2024/01.pdf)
+	Size         int64             `json:"size"`         // Object size in bytes
+	ContentType  string            `json:"contentType"`  // Object MIME type
+	ETag         string            `json:"etag"`         // Object content hash (MD5 hex)
+	Metadata     map[string]string `json:"metadata"`     // User defined metadata
+	LastModified time.Time         `json:"lastModified"` // Last modification time
+}
+
+// ObjectOption sets the object properties when storing the object
+type ObjectOption func(info *ObjectInfo)
+
+// WithContentType sets the object MIME type (default: application/octet-stream)
+func WithContentType(contentType string) ObjectOption {
+	return func(info *ObjectInfo) {
+		info.ContentType = contentType
+	}
+}
+
+// WithMetadata adds user defined metadata to the object
+func WithMetadata(metadata map[string]string) ObjectOption {
+	return func(info *ObjectInfo) {
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		for k, v := range metadata {
+			info.Metadata[k] = v
+		}
+	}
+}
+
+// IObjectStore object storage interface
+type IObjectStore interface {
+
+	// Closer includes method Close()
+	io.Closer
+
+	// Ping tests connectivity for retries number of time with time interval (in seconds) between retries
+	Ping(retries uint, intervalInSeconds uint) error
+
+	// Put stores the object content read from the reader, an existing object is replaced
+	Put(ctx context.Context, key string, reader io.Reader, options ...ObjectOption) (ObjectInfo, error)
+
+	// Get returns the object content reader (must be closed by the caller) and the object info
+	Get(ctx context.Context, key string) (io.ReadCloser, ObjectInfo, error)
+
+	// Stat returns the object info without the content
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// Delete objects, keys which do not exist are ignored
+	Delete(ctx context.Context, keys ...string) error
+
+	// List objects with the key prefix sorted by key, starting after the cursor key (empty for the first page).
+	// Returns up to limit objects (0 for no limit) and the cursor of the next page (empty if there are no more objects)
+	List(ctx context.Context, prefix string, cursor string, limit int) ([]ObjectInfo, string, error)
+
+	// SignedURL returns a URL granting temporary access to the object for the HTTP method (GET or PUT)
+	SignedURL(ctx context.Context, key string, method string, expiration time.Duration) (string, error)
+}
+
+// region Signed URLs --------------------------------------------------------------------------------------------------
+
+const (
+	signedUrlMethod    = "X-Method"
+	signedUrlExpires   = "X-Expires"
+	signedUrlSignature = "X-Signature"
+)
+
+// objectUrlSigner signs object URLs with HMAC-SHA256, used by the in-memory and local object stores
+type objectUrlSigner struct {
+	baseUrl string
+	secret  []byte
+}
+
+// sign the object URL
+func (s objectUrlSigner) sign(key, method string, expiration time.Duration) (string, error) {
+	if len(s.baseUrl) == 0 || len(s.secret) == 0 {
+		return "", fmt.Errorf("signed URLs require base URL and signing secret")
+	}
+	method = strings.ToUpper(method)
+	if method != http.MethodGet && method != http.MethodPut {
+		return "", fmt.Errorf("unsupported signed URL method: %s", method)
+	}
+	if err := validateObjectKey(key); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiration).Unix(), 10)
+	query := url.Values{}
+	query.Set(signedUrlMethod, method)
+	query.Set(signedUrlExpires, expires)
+	query.Set(signedUrlSignature, signObjectUrl(s.secret, key, method, expires))
+	return fmt.Sprintf("%s/%s?%s", strings.TrimSuffix(s.baseUrl, "/"), escapeObjectKey(key), query.Encode()), nil
+}
+
+// signature of the object URL
+func signObjectUrl(secret []byte, key, method, expires string) string {
+	return utils.CryptoUtils().Sign(secret, []byte(strings.Join([]string{method, key, expires}, "\n")))
+}
+
+// escape the key path segments
+func escapeObjectKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// validate the object key (relative path without empty, . or .. segments)
+func validateObjectKey(key string) error {
+	if len(key) == 0 {
+		return fmt.Errorf("missing object key")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if len(segment) == 0 || segment == "." || segment == ".." || strings.ContainsRune(segment, '\\') {
+			return fmt.Errorf("invalid object key: %s", key)
+		}
+	}
+	return nil
+}
+
+// NewObjectStoreHandler returns HTTP handler serving signed URLs of the object store (GET downloads the object, PUT
+// uploads the request body). The request path is the object key, so the handler is mounted with http.StripPrefix:
+//
+//	store, _ := database.NewLocalObjectStore("/var/data", "http://localhost:8080/objects", secret)
+//	mux.Handle("/objects/", http.StripPrefix("/objects", database.NewObjectStoreHandler(store, secret)))
+func NewObjectStoreHandler(store IObjectStore, secret []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		query := r.URL.Query()
+		method := query.Get(signedUrlMethod)
+		expires := query.Get(signedUrlExpires)
+
+		if method != r.Method {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		exp, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > exp {
+			http.Error(w, "signed URL expired", http.StatusForbidden)
+			return
+		}
+		if !utils.CryptoUtils().Verify(secret, []byte(strings.Join([]string{method, key, expires}, "\n")), query.Get(signedUrlSignature)) {
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			serveObject(w, r, store, key)
+		case http.MethodPut:
+			options := make([]ObjectOption, 0, 1)
+			if ct := r.Header.Get("Content-Type"); len(ct) > 0 {
+				options = append(options, WithContentType(ct))
+			}
+			info, er := store.Put(r.Context(), key, r.Body, options...)
+			if er != nil {
+				http.Error(w, er.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("ETag", strconv.Quote(info.ETag))
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// write the object content and headers
+func serveObject(w http.ResponseWriter, r *http.Request, store IObjectStore, key string) {
+	reader, info, err := store.Get(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("ETag", strconv.Quote(info.ETag))
+	w.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	for k, v := range info.Metadata {
+		w.Header().Set("X-Object-Meta-"+k, v)
+	}
+	_, _ = io.Copy(w, reader)
+}
+
+// endregion
+
+// region Object store helpers -----------------------------------------------------------------------------------------
+
+// newObjectInfo applies the options on the object info
+func newObjectInfo(key string, options []ObjectOption) ObjectInfo {
+	info := ObjectInfo{Key: key, ContentType: "application/octet-stream", Metadata: make(map[string]string)}
+	for _, opt := range options {
+		opt(&info)
+	}
+	return info
+}
+
+// copy the object info (including the metadata map)
+func (o ObjectInfo) clone() ObjectInfo {
+	metadata := make(map[string]string, len(o.Metadata))
+	for k, v := range o.Metadata {
+		metadata[k] = v
+	}
+	o.Metadata = metadata
+	return o
+}
+
+// page of the sorted objects list with the key prefix after the cursor
+func objectsPage(sorted []ObjectInfo, prefix, cursor string, limit int) ([]ObjectInfo, string) {
+	result := make([]ObjectInfo, 0)
+	for _, info := range sorted {
+		if !strings.HasPrefix(info.Key, prefix) || (len(cursor) > 0 && info.Key <= cursor) {
+			continue
+		}
+		if limit > 0 && len(result) == limit {
+			return result, result[len(result)-1].Key
+		}
+		result = append(result, info)
+	}
+	return result, ""
+}
+
+// endregion
@@ -33,6 +33,15 @@ type IQuery interface {
 	// MatchAny Add list of filters, any of them should be satisfied (OR)
 	MatchAny(filters ...QueryFilter) IQuery
 
+	// And Add a boolean condition, all the conditions (filters or nested expressions) should be satisfied
+	And(conditions ...Condition) IQuery
+
+	// Or Add a boolean condition, any of the conditions (filters or nested expressions) should be satisfied
+	Or(conditions ...Condition) IQuery
+
+	// Not Add a boolean condition, the condition (filter or nested expression) should not be satisfied
+	Not(condition Condition) IQuery
+
 	// Sort Add sort order by field,  expects sort parameter in the following form: field_name (Ascending) or field_name- (Descending)
 	Sort(sort string) IQuery
 
@@ -98,4 +107,7 @@ type IQuery interface {
 
 	// ToString Get the string representation of the query
 	ToString() string
+
+	// ToJson Get the portable JSON representation of the query filters, sort, pagination and range (see FromJson)
+	ToJson() ([]byte, error)
 }
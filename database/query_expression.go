@@ -0,0 +1,281 @@
+// Boolean filter trees
+//
+// Query filters are combined to nested boolean conditions using And, Or and Not, and added to the query using the
+// IQuery And, Or and Not methods (all the conditions added to the query should be satisfied):
+//
+//	query := db.Query(NewUser).Or(
+//		And(F("status").Eq("active"), F("age").Gt(30)),
+//		Not(F("role").In("admin", "ops")),
+//	)
+
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+)
+
+// LogicalOperator combines the filters of a filter expression
+type LogicalOperator string
+
+const (
+	AndOperator LogicalOperator = "AND"
+	OrOperator  LogicalOperator = "OR"
+	NotOperator LogicalOperator = "NOT"
+)
+
+// Condition is a node of a boolean filter tree (a QueryFilter or a FilterExpression)
+type Condition interface {
+
+	// Expression gets the condition as a filter expression tree
+	Expression() *FilterExpression
+}
+
+// region FilterExpression ---------------------------------------------------------------------------------------------
+
+// FilterExpression is a boolean tree of query filters, a leaf node has a filter and an inner node combines the
+// expressions of its children using the logical operator (NOT has a single child)
+type FilterExpression struct {
+	Operator LogicalOperator     // Logical operator of the inner node
+	Filter   QueryFilter         // Filter of the leaf node
+	Children []*FilterExpression // Child expressions of the inner node
+}
+
+// And creates an expression satisfied when all the conditions are satisfied, inactive filters are ignored
+func And(conditions ...Condition) *FilterExpression {
+	return newFilterExpression(AndOperator, conditions)
+}
+
+// Or creates an expression satisfied when any of the conditions is satisfied, inactive filters are ignored
+func Or(conditions ...Condition) *FilterExpression {
+	return newFilterExpression(OrOperator, conditions)
+}
+
+// Not creates an expression satisfied when the condition is not satisfied
+func Not(condition Condition) *FilterExpression {
+	expr := &FilterExpression{Operator: NotOperator, Children: make([]*FilterExpression, 0, 1)}
+	if child := activeExpression(condition); child != nil {
+		expr.Children = append(expr.Children, child)
+	}
+	return expr
+}
+
+// Expression gets the expression itself (implements Condition)
+func (e *FilterExpression) Expression() *FilterExpression {
+	return e
+}
+
+// Filters returns all the filters of the expression (the leaves of the tree)
+func (e *FilterExpression) Filters() []QueryFilter {
+	if e.Filter != nil {
+		return []QueryFilter{e.Filter}
+	}
+	result := make([]QueryFilter, 0)
+	for _, child := range e.Children {
+		result = append(result, child.Filters()...)
+	}
+	return result
+}
+
+// Validate checks that the filter fields exist in the entity and that the operators and values match the field types
+func (e *FilterExpression) Validate(factory EntityFactory) error {
+	fields := entityFieldKinds(factory())
+	for _, filter := range e.Filters() {
+		if err := validateFilter(fields, filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply adds the expression to the query, the expression should be satisfied in addition to the other query filters
+func (e *FilterExpression) Apply(query IQuery) IQuery {
+	return query.And(e)
+}
+
+// String returns the textual filter expression (see ParseFilter)
+func (e *FilterExpression) String() string {
+	if e.Filter != nil {
+		return formatFilter(e.Filter)
+	}
+	parts := make([]string, 0, len(e.Children))
+	for _, child := range e.Children {
+		if child.Filter == nil && child.Operator != e.Operator && child.Operator != NotOperator {
+			parts = append(parts, "("+child.String()+")")
+		} else {
+			parts = append(parts, child.String())
+		}
+	}
+	if e.Operator == NotOperator {
+		return strings.TrimSpace("NOT " + strings.Join(parts, ""))
+	}
+	return strings.Join(parts, " "+string(e.Operator)+" ")
+}
+
+// check if the expression has no filters
+func (e *FilterExpression) isEmpty() bool {
+	return e.Filter == nil && len(e.Children) == 0
+}
+
+// create AND / OR expression of the active conditions, nested expressions of the same operator are flattened
+func newFilterExpression(operator LogicalOperator, conditions []Condition) *FilterExpression {
+	expr := &FilterExpression{Operator: operator, Children: make([]*FilterExpression, 0, len(conditions))}
+	for _, condition := range conditions {
+		child := activeExpression(condition)
+		if child == nil {
+			continue
+		}
+		if child.Filter == nil && child.Operator == operator {
+			expr.Children = append(expr.Children, child.Children...)
+		} else {
+			expr.Children = append(expr.Children, child)
+		}
+	}
+	return expr
+}
+
+// get the condition expression, nil for missing conditions, inactive filters and empty expressions
+func activeExpression(condition Condition) *FilterExpression {
+	if condition == nil {
+		return nil
+	}
+	expr := condition.Expression()
+	if expr == nil || expr.isEmpty() || (expr.Filter != nil && !expr.Filter.IsActive()) {
+		return nil
+	}
+	return expr
+}
+
+// endregion
+
+// region FilterExpression JSON ----------------------------------------------------------------------------------------
+
+// JSON representation of the expression: {"and": [...]}, {"or": [...]}, {"not": {...}} or a filter leaf
+// {"field": "...", "op": "...", "values": [...]}
+type filterExpressionJson struct {
+	And    []*FilterExpression `json:"and,omitempty"`
+	Or     []*FilterExpression `json:"or,omitempty"`
+	Not    *FilterExpression   `json:"not,omitempty"`
+	Field  string              `json:"field,omitempty"`
+	Op     QueryOperator       `json:"op,omitempty"`
+	Values []any               `json:"values,omitempty"`
+}
+
+// MarshalJSON writes the JSON representation of the expression
+func (e *FilterExpression) MarshalJSON() ([]byte, error) {
+	if e.Filter != nil {
+		if e.Filter.GetSubQuery() != nil {
+			return nil, fmt.Errorf("sub-query filter on field %s can't be serialized", e.Filter.GetField())
+		}
+		return json.Marshal(filterExpressionJson{Field: e.Filter.GetField(), Op: e.Filter.GetOperator(), Values: e.Filter.GetValues()})
+	}
+
+	children := e.Children
+	if children == nil {
+		children = make([]*FilterExpression, 0)
+	}
+	switch e.Operator {
+	case AndOperator:
+		return json.Marshal(map[string]any{"and": children})
+	case OrOperator:
+		return json.Marshal(map[string]any{"or": children})
+	case NotOperator:
+		if len(children) == 1 {
+			return json.Marshal(map[string]any{"not": children[0]})
+		}
+		return json.Marshal(map[string]any{"and": children})
+	default:
+		return nil, fmt.Errorf("logical operator %s is not supported", e.Operator)
+	}
+}
+
+// UnmarshalJSON reads the JSON representation of the expression
+func (e *FilterExpression) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+
+	ej := filterExpressionJson{}
+	if err := decoder.Decode(&ej); err != nil {
+		return err
+	}
+
+	*e = FilterExpression{}
+	switch {
+	case ej.And != nil && ej.Or == nil && ej.Not == nil && len(ej.Field) == 0:
+		e.Operator, e.Children = AndOperator, ej.And
+	case ej.Or != nil && ej.And == nil && ej.Not == nil && len(ej.Field) == 0:
+		e.Operator, e.Children = OrOperator, ej.Or
+	case ej.Not != nil && ej.And == nil && ej.Or == nil && len(ej.Field) == 0:
+		e.Operator, e.Children = NotOperator, []*FilterExpression{ej.Not}
+	case len(ej.Field) > 0 && ej.And == nil && ej.Or == nil && ej.Not == nil:
+		filters, err := filtersFromSpec([]FilterSpec{{Field: ej.Field, Operator: ej.Op, Values: jsonNumbersToValues(ej.Values)}})
+		if err != nil {
+			return err
+		}
+		e.Filter = filters[0]
+	default:
+		return fmt.Errorf("invalid filter expression: expected one of and, or, not or field")
+	}
+	for _, child := range e.Children {
+		if child == nil {
+			return fmt.Errorf("invalid filter expression: null condition")
+		}
+	}
+	return nil
+}
+
+// endregion
+
+// region FilterExpression format --------------------------------------------------------------------------------------
+
+// format the filter as a condition of the expression syntax
+func formatFilter(filter QueryFilter) string {
+	values := filter.GetValues()
+	list := func() string {
+		items := make([]string, 0, len(values))
+		for _, value := range values {
+			items = append(items, formatFilterValue(value))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	}
+	field := filter.GetField()
+
+	switch filter.GetOperator() {
+	case In:
+		return field + " IN " + list()
+	case NotIn:
+		return field + " NOT IN " + list()
+	case Between:
+		return field + " BETWEEN " + list()
+	case Contains:
+		return field + " CONTAINS " + formatFilterValue(values[0])
+	case Empty:
+		return field + " IS EMPTY"
+	case Neq:
+		return field + "!=" + formatFilterValue(values[0])
+	default:
+		return field + string(filter.GetOperator()) + formatFilterValue(values[0])
+	}
+}
+
+// format the value, strings which are not parsed back as the same word are quoted
+func formatFilterValue(value any) string {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Sprintf("%v", value)
+	}
+	if tokens, err := tokenizeFilter(str); err == nil && len(tokens) == 1 && tokens[0].kind == tokenWord {
+		if parsed := parseFilterValue(tokens[0]); parsed == str {
+			return str
+		}
+	}
+	return strconv.Quote(str)
+}
+
+// endregion
@@ -72,6 +72,9 @@ type QueryFilter interface {
 
 	// GetSubQueryField gets the underlying sub-query field
 	GetSubQueryField() string
+
+	// Expression gets the filter as a leaf of a filter expression tree (implements Condition)
+	Expression() *FilterExpression
 }
 
 // endregion
@@ -256,4 +259,9 @@ func (q *queryFilter) GetSubQueryField() string {
 	return q.subQueryField
 }
 
+// Expression gets the filter as a leaf of a filter expression tree
+func (q *queryFilter) Expression() *FilterExpression {
+	return &FilterExpression{Filter: q}
+}
+
 // endregion
@@ -0,0 +1,242 @@
+// Portable JSON representation of a query
+//
+// Queries are serialized to a JSON document of filters, sort, pagination and range, which is independent of the
+// database implementation, so it can be stored, passed between services or sent from UIs:
+//
+//	data, err := db.Query(NewUser).Filter(F("status").Eq("active")).Sort("name").Limit(20).ToJson()
+//	...
+//	spec, err := database.FromJson(NewUser, data)
+//	list, total, err := spec.Apply(db.Query(NewUser)).Find()
+
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+)
+
+// FilterSpec is the JSON representation of a query filter
+type FilterSpec struct {
+	Field    string        `json:"field"`            // Field name
+	Operator QueryOperator `json:"op"`               // Filter operator
+	Values   []any         `json:"values,omitempty"` // Filter values
+}
+
+// RangeSpec is the JSON representation of the query time range
+type RangeSpec struct {
+	Field string    `json:"field"` // Time field name
+	From  Timestamp `json:"from"`  // Start timestamp
+	To    Timestamp `json:"to"`    // End timestamp
+}
+
+// QuerySpec is the portable JSON representation of a query
+type QuerySpec struct {
+	All   [][]FilterSpec      `json:"all,omitempty"`   // Lists of filters, all of them should be satisfied (AND)
+	Any   [][]FilterSpec      `json:"any,omitempty"`   // Lists of filters, any filter of each list should be satisfied (OR)
+	Where []*FilterExpression `json:"where,omitempty"` // Boolean conditions, all of them should be satisfied
+	Sort  []string            `json:"sort,omitempty"`  // Sort fields: field_name (Ascending) or field_name- (Descending)
+	Page  int                 `json:"page"`            // Page number
+	Limit int                 `json:"limit"`           // Page size
+	Range *RangeSpec          `json:"range,omitempty"` // Time range filter
+}
+
+// FromJson parses the JSON representation of a query and validates it against the entity fields (filter fields,
+// operators and values, sort fields and range field)
+func FromJson(factory EntityFactory, data []byte) (*QuerySpec, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+
+	spec := &QuerySpec{}
+	if err := decoder.Decode(spec); err != nil {
+		return nil, fmt.Errorf("invalid query json: %w", err)
+	}
+	if spec.Page < 0 || spec.Limit < 0 {
+		return nil, fmt.Errorf("invalid query pagination: page %d, limit %d", spec.Page, spec.Limit)
+	}
+
+	filters := make([][]QueryFilter, 0, len(spec.All)+len(spec.Any))
+	for _, lists := range [][][]FilterSpec{spec.All, spec.Any} {
+		for _, list := range lists {
+			for i := range list {
+				list[i].Values = jsonNumbersToValues(list[i].Values)
+			}
+			group, err := filtersFromSpec(list)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, group)
+		}
+	}
+	for _, condition := range spec.Where {
+		if condition == nil {
+			return nil, fmt.Errorf("invalid query json: null condition")
+		}
+		filters = append(filters, condition.Filters())
+	}
+
+	orders := make([]any, 0, len(spec.Sort))
+	for _, sort := range spec.Sort {
+		orders = append(orders, strings.TrimRight(sort, "+-"))
+	}
+	rangeField := ""
+	if spec.Range != nil {
+		rangeField = spec.Range.Field
+	}
+	if err := validateQuery(factory, filters, orders, rangeField); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Apply adds the filters, conditions, sort, pagination and range of the specification to the query
+func (q *QuerySpec) Apply(query IQuery) IQuery {
+	for _, list := range q.All {
+		filters, _ := filtersFromSpec(list)
+		query = query.MatchAll(filters...)
+	}
+	for _, list := range q.Any {
+		filters, _ := filtersFromSpec(list)
+		query = query.MatchAny(filters...)
+	}
+	for _, condition := range q.Where {
+		query = query.And(condition)
+	}
+	for _, sort := range q.Sort {
+		query = query.Sort(sort)
+	}
+	if q.Range != nil {
+		query = query.Range(q.Range.Field, q.Range.From, q.Range.To)
+	}
+	return query.Page(q.Page).Limit(q.Limit)
+}
+
+// Json returns the JSON representation of the query
+func (q *QuerySpec) Json() ([]byte, error) {
+	return json.Marshal(q)
+}
+
+// region Query specification helpers ----------------------------------------------------------------------------------
+
+// build the query specification from the query builder state
+func newQuerySpec(allFilters, anyFilters [][]QueryFilter, conditions []*FilterExpression, asc, desc []any, page, limit int, rangeField string, from, to Timestamp) (*QuerySpec, error) {
+	spec := &QuerySpec{Page: page, Limit: limit, Where: conditions}
+
+	toSpec := func(groups [][]QueryFilter) ([][]FilterSpec, error) {
+		result := make([][]FilterSpec, 0, len(groups))
+		for _, group := range groups {
+			list := make([]FilterSpec, 0, len(group))
+			for _, filter := range group {
+				if filter.GetSubQuery() != nil {
+					return nil, fmt.Errorf("sub-query filter on field %s can't be serialized", filter.GetField())
+				}
+				list = append(list, FilterSpec{Field: filter.GetField(), Operator: filter.GetOperator(), Values: filter.GetValues()})
+			}
+			result = append(result, list)
+		}
+		return result, nil
+	}
+
+	var err error
+	if spec.All, err = toSpec(allFilters); err != nil {
+		return nil, err
+	}
+	if spec.Any, err = toSpec(anyFilters); err != nil {
+		return nil, err
+	}
+	for _, field := range asc {
+		spec.Sort = append(spec.Sort, fmt.Sprintf("%v", field))
+	}
+	for _, field := range desc {
+		spec.Sort = append(spec.Sort, fmt.Sprintf("%v-", field))
+	}
+	if len(rangeField) > 0 {
+		spec.Range = &RangeSpec{Field: rangeField, From: from, To: to}
+	}
+	return spec, nil
+}
+
+// convert the filter specifications to query filters
+func filtersFromSpec(list []FilterSpec) ([]QueryFilter, error) {
+	filters := make([]QueryFilter, 0, len(list))
+	for _, fs := range list {
+		filter, values := F(fs.Field), fs.Values
+		expect := func(count int) error {
+			if len(values) != count {
+				return fmt.Errorf("operator %s on field %s expects %d value(s)", fs.Operator, fs.Field, count)
+			}
+			return nil
+		}
+
+		var err error
+		switch fs.Operator {
+		case Eq, Neq, Like, Gt, Gte, Lt, Lte, Contains:
+			if err = expect(1); err == nil {
+				filter = singleValueFilter(filter, fs.Operator, values[0])
+			}
+		case Between:
+			if err = expect(2); err == nil {
+				filter.Between(values[0], values[1])
+			}
+		case In, NotIn:
+			if len(values) == 0 {
+				err = fmt.Errorf("operator %s on field %s expects values", fs.Operator, fs.Field)
+			} else if fs.Operator == In {
+				filter.In(values...)
+			} else {
+				filter.NotIn(values...)
+			}
+		case Empty:
+			filter.IsEmpty()
+		default:
+			err = fmt.Errorf("operator %s is not supported", fs.Operator)
+		}
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// set the single value operator of the filter
+func singleValueFilter(filter QueryFilter, operator QueryOperator, value any) QueryFilter {
+	switch operator {
+	case Neq:
+		return filter.Neq(value)
+	case Like:
+		return filter.Like(fmt.Sprintf("%v", value))
+	case Gt:
+		return filter.Gt(value)
+	case Gte:
+		return filter.Gte(value)
+	case Lt:
+		return filter.Lt(value)
+	case Lte:
+		return filter.Lte(value)
+	case Contains:
+		return filter.Contains(value)
+	default:
+		return filter.Eq(value)
+	}
+}
+
+// convert the decoded JSON numbers to int64 (or float64 for non integer numbers)
+func jsonNumbersToValues(values []any) []any {
+	for i, value := range values {
+		if number, ok := value.(json.Number); ok {
+			if n, err := number.Int64(); err == nil {
+				values[i] = n
+			} else if f, er := number.Float64(); er == nil {
+				values[i] = f
+			}
+		}
+	}
+	return values
+}
+
+// endregion
@@ -0,0 +1,380 @@
+// Query DSL parser
+//
+// Converts a compact textual filter expression to a boolean tree of query filters, to support user-supplied filters in
+// REST APIs. The expression syntax:
+//
+//	expression := term { (AND | OR) term }     (NOT binds stronger than AND, AND binds stronger than OR,
+//	                                            keywords are case-insensitive)
+//	term       := NOT term | '(' expression ')' | condition
+//	condition  := field ( = | != | > | >= | < | <= | ~ ) value
+//	            | field [NOT] IN [value, ...]
+//	            | field BETWEEN [value, value]
+//	            | field LIKE value
+//	            | field CONTAINS value
+//	            | field IS EMPTY
+//	value      := number | true | false | word | 'quoted string' | "quoted string"
+//
+// For example:
+//
+//	expr, err := database.ParseFilter("status=active AND (age>30 OR role IN [admin,ops])")
+//	if err == nil {
+//		err = expr.Validate(NewUser)
+//	}
+//	query := expr.Apply(db.Query(NewUser))
+
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxFilterExpressionLength = 4096 // Maximum length of the filter expression
+	maxFilterDepth            = 16   // Maximum nesting level of parentheses and NOT
+)
+
+// region Filter expression parser -------------------------------------------------------------------------------------
+
+// ParseFilter parses the textual filter expression to a filter expression tree
+func ParseFilter(expression string) (*FilterExpression, error) {
+	if len(expression) > maxFilterExpressionLength {
+		return nil, fmt.Errorf("filter expression exceeds %d characters", maxFilterExpressionLength)
+	}
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.errorf("unexpected %s", p.peek().text)
+	}
+	return expr, nil
+}
+
+// endregion
+
+// region Filter expression tokenizer ----------------------------------------------------------------------------------
+
+type filterTokenKind int
+
+const (
+	tokenWord filterTokenKind = iota
+	tokenString
+	tokenOperator
+	tokenSymbol
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+// split the expression to words, quoted strings, comparison operators and symbols
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '[' || r == ']' || r == ',':
+			tokens = append(tokens, filterToken{kind: tokenSymbol, text: string(r), pos: i})
+			i++
+		case r == '=' || r == '!' || r == '>' || r == '<' || r == '~':
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' && r != '~' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("invalid operator at position %d", i)
+			}
+			tokens = append(tokens, filterToken{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			start := i
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: sb.String(), pos: start})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()[],=!<>~\"'", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, text: string(runes[start:i]), pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+// convert the value token to a number, boolean or string
+func parseFilterValue(token filterToken) any {
+	if token.kind == tokenString {
+		return token.text
+	}
+	if n, err := strconv.ParseInt(token.text, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(token.text, 64); err == nil {
+		return f
+	}
+	if b, err := strconv.ParseBool(token.text); err == nil && strings.EqualFold(token.text, strconv.FormatBool(b)) {
+		return b
+	}
+	return token.text
+}
+
+// endregion
+
+// region Filter expression parser internals ---------------------------------------------------------------------------
+
+type filterParser struct {
+	tokens []filterToken
+	index  int
+}
+
+// parse the OR of the AND expressions
+func (p *filterParser) parseExpression(depth int) (*FilterExpression, error) {
+	if depth > maxFilterDepth {
+		return nil, fmt.Errorf("filter expression is nested more than %d levels", maxFilterDepth)
+	}
+	return p.parseLogical(OrOperator, func() (*FilterExpression, error) {
+		return p.parseLogical(AndOperator, func() (*FilterExpression, error) {
+			return p.parseTerm(depth)
+		})
+	})
+}
+
+// parse a list of operands combined by the logical operator
+func (p *filterParser) parseLogical(operator LogicalOperator, operand func() (*FilterExpression, error)) (*FilterExpression, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	conditions := []Condition{first}
+	for p.keyword(string(operator)) {
+		p.index++
+		next, er := operand()
+		if er != nil {
+			return nil, er
+		}
+		conditions = append(conditions, next)
+	}
+	if len(conditions) == 1 {
+		return first, nil
+	}
+	return newFilterExpression(operator, conditions), nil
+}
+
+// parse a negated term, an expression in parentheses or a condition
+func (p *filterParser) parseTerm(depth int) (*FilterExpression, error) {
+	if p.keyword("NOT") {
+		p.index++
+		if depth >= maxFilterDepth {
+			return nil, fmt.Errorf("filter expression is nested more than %d levels", maxFilterDepth)
+		}
+		term, err := p.parseTerm(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not(term), nil
+	}
+	if p.symbol("(") {
+		p.index++
+		expr, err := p.parseExpression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, p.errorf("expected )")
+		}
+		p.index++
+		return expr, nil
+	}
+	filter, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	return &FilterExpression{Filter: filter}, nil
+}
+
+// parse a single field condition
+func (p *filterParser) parseCondition() (QueryFilter, error) {
+	if p.done() || p.peek().kind != tokenWord || isFilterKeyword(p.peek().text) {
+		return nil, p.errorf("expected field name")
+	}
+	field := F(p.next().text)
+
+	if p.done() {
+		return nil, p.errorf("expected operator")
+	}
+	if token := p.peek(); token.kind == tokenOperator {
+		p.index++
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		switch token.text {
+		case "=":
+			return field.Eq(value), nil
+		case "!=":
+			return field.Neq(value), nil
+		case ">":
+			return field.Gt(value), nil
+		case ">=":
+			return field.Gte(value), nil
+		case "<":
+			return field.Lt(value), nil
+		case "<=":
+			return field.Lte(value), nil
+		default:
+			return field.Like(fmt.Sprintf("%v", value)), nil
+		}
+	}
+
+	switch keyword := strings.ToUpper(p.next().text); keyword {
+	case "IN":
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return field.In(values...), nil
+	case "NOT":
+		if !p.keyword("IN") {
+			return nil, p.errorf("expected IN")
+		}
+		p.index++
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return field.NotIn(values...), nil
+	case "BETWEEN":
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 2 {
+			return nil, p.errorf("BETWEEN expects two values")
+		}
+		return field.Between(values[0], values[1]), nil
+	case "LIKE":
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return field.Like(fmt.Sprintf("%v", value)), nil
+	case "CONTAINS":
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return field.Contains(value), nil
+	case "IS":
+		if !p.keyword("EMPTY") {
+			return nil, p.errorf("expected EMPTY")
+		}
+		p.index++
+		return field.IsEmpty(), nil
+	default:
+		p.index--
+		return nil, p.errorf("unexpected %s", p.peek().text)
+	}
+}
+
+// parse a list of values in brackets
+func (p *filterParser) parseList() ([]any, error) {
+	if !p.symbol("[") {
+		return nil, p.errorf("expected [")
+	}
+	p.index++
+	values := make([]any, 0)
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.symbol("]") {
+			p.index++
+			return values, nil
+		}
+		if !p.symbol(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+		p.index++
+	}
+}
+
+// parse a single value
+func (p *filterParser) parseValue() (any, error) {
+	if p.done() || (p.peek().kind != tokenWord && p.peek().kind != tokenString) {
+		return nil, p.errorf("expected value")
+	}
+	return parseFilterValue(p.next()), nil
+}
+
+// check if the current token is the keyword
+func (p *filterParser) keyword(keyword string) bool {
+	return !p.done() && p.peek().kind == tokenWord && strings.EqualFold(p.peek().text, keyword)
+}
+
+// check if the current token is the symbol
+func (p *filterParser) symbol(symbol string) bool {
+	return !p.done() && p.peek().kind == tokenSymbol && p.peek().text == symbol
+}
+
+func (p *filterParser) done() bool {
+	return p.index >= len(p.tokens)
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.index]
+}
+
+func (p *filterParser) next() filterToken {
+	p.index++
+	return p.tokens[p.index-1]
+}
+
+// create a parsing error at the current position
+func (p *filterParser) errorf(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.done() {
+		return fmt.Errorf("invalid filter expression: %s at end of expression", msg)
+	}
+	return fmt.Errorf("invalid filter expression: %s at position %d", msg, p.peek().pos)
+}
+
+// check if the word is a reserved keyword
+func isFilterKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "AND", "OR", "NOT", "IN", "BETWEEN", "LIKE", "CONTAINS", "IS", "EMPTY":
+		return true
+	default:
+		return false
+	}
+}
+
+// endregion
@@ -0,0 +1,261 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// region Tenant context -----------------------------------------------------------------------------------------------
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of the context carrying the tenant id, the tenant id is attached to the context logger
+// (see logger.FromContext)
+func WithTenant(ctx context.Context, tenantId string) context.Context {
+	ctx = logger.WithContext(ctx, logger.F(logger.TenantIdKey, tenantId))
+	return context.WithValue(ctx, tenantContextKey{}, tenantId)
+}
+
+// TenantFromContext returns the tenant id carried by the context
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantId, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantId, ok && len(tenantId) > 0
+}
+
+// ForTenantContext returns the database bound to the tenant of the context, see ForTenant
+func ForTenantContext(ctx context.Context, db IDatabase) (IDatabase, error) {
+	tenantId, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("tenant id not found in context")
+	}
+	return ForTenant(db, tenantId), nil
+}
+
+// ForTenant returns the database bound to the tenant: the tenant id is supplied as the sharding key of every operation
+// and query which is called without explicit keys. Insert / Update operations resolve the table by the entity KEY()
+// Note: AdvancedQuery is not bound to the tenant since the analytic queries resolve the table by the entity KEY()
+func ForTenant(db IDatabase, tenantId string) IDatabase {
+	if td, ok := db.(*tenantDatabase); ok {
+		db = td.IDatabase
+	}
+	return &tenantDatabase{IDatabase: db, tenantId: tenantId}
+}
+
+// endregion
+
+// region Tenant database ----------------------------------------------------------------------------------------------
+
+// tenantDatabase wraps database and injects the tenant key
+type tenantDatabase struct {
+	IDatabase
+	tenantId string
+}
+
+// return the keys or the tenant key if no keys provided
+func (t *tenantDatabase) keys(keys []string) []string {
+	if len(keys) > 0 {
+		return keys
+	}
+	return []string{t.tenantId}
+}
+
+// CloneDatabase Returns a clone of the database bound to the same tenant
+func (t *tenantDatabase) CloneDatabase() (IDatabase, error) {
+	clone, err := t.IDatabase.CloneDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return ForTenant(clone, t.tenantId), nil
+}
+
+// Get a single entity by ID
+func (t *tenantDatabase) Get(factory EntityFactory, entityID string, keys ...string) (Entity, error) {
+	return t.IDatabase.Get(factory, entityID, t.keys(keys)...)
+}
+
+// List Get multiple entities by IDs
+func (t *tenantDatabase) List(factory EntityFactory, entityIDs []string, keys ...string) ([]Entity, error) {
+	return t.IDatabase.List(factory, entityIDs, t.keys(keys)...)
+}
+
+// Exists Check if entity exists by ID
+func (t *tenantDatabase) Exists(factory EntityFactory, entityID string, keys ...string) (bool, error) {
+	return t.IDatabase.Exists(factory, entityID, t.keys(keys)...)
+}
+
+// Delete entity by id
+func (t *tenantDatabase) Delete(factory EntityFactory, entityID string, keys ...string) error {
+	return t.IDatabase.Delete(factory, entityID, t.keys(keys)...)
+}
+
+// BulkDelete Delete multiple entities by IDs
+func (t *tenantDatabase) BulkDelete(factory EntityFactory, entityIDs []string, keys ...string) (int64, error) {
+	return t.IDatabase.BulkDelete(factory, entityIDs, t.keys(keys)...)
+}
+
+// SetField Update single field of the document
+func (t *tenantDatabase) SetField(factory EntityFactory, entityID string, field string, value any, keys ...string) error {
+	return t.IDatabase.SetField(factory, entityID, field, value, t.keys(keys)...)
+}
+
+// SetFields Update some fields of the document
+func (t *tenantDatabase) SetFields(factory EntityFactory, entityID string, fields map[string]any, keys ...string) error {
+	return t.IDatabase.SetFields(factory, entityID, fields, t.keys(keys)...)
+}
+
+// BulkSetFields Update specific field of multiple entities
+func (t *tenantDatabase) BulkSetFields(factory EntityFactory, field string, values map[string]any, keys ...string) (int64, error) {
+	return t.IDatabase.BulkSetFields(factory, field, values, t.keys(keys)...)
+}
+
+// Query returns query builder bound to the tenant
+func (t *tenantDatabase) Query(factory EntityFactory) IQuery {
+	return &tenantQuery{IQuery: t.IDatabase.Query(factory), db: t}
+}
+
+// endregion
+
+// region Tenant query -------------------------------------------------------------------------------------------------
+
+// tenantQuery wraps query and injects the tenant key to the query actions
+type tenantQuery struct {
+	IQuery
+	db *tenantDatabase
+}
+
+// wrap the builder result to keep the tenant binding along the builder chain
+func (q *tenantQuery) wrap(query IQuery) IQuery {
+	q.IQuery = query
+	return q
+}
+
+// Apply adds callback to apply on each result entity in the query
+func (q *tenantQuery) Apply(cb func(in Entity) Entity) IQuery {
+	return q.wrap(q.IQuery.Apply(cb))
+}
+
+// Filter adds a single field filter
+func (q *tenantQuery) Filter(filter QueryFilter) IQuery {
+	return q.wrap(q.IQuery.Filter(filter))
+}
+
+// Range adds time frame filter on specific time field
+func (q *tenantQuery) Range(field string, from Timestamp, to Timestamp) IQuery {
+	return q.wrap(q.IQuery.Range(field, from, to))
+}
+
+// MatchAll adds a list of filters, all of them should be satisfied
+func (q *tenantQuery) MatchAll(filters ...QueryFilter) IQuery {
+	return q.wrap(q.IQuery.MatchAll(filters...))
+}
+
+// MatchAny adds a list of filters, any of them should be satisfied
+func (q *tenantQuery) MatchAny(filters ...QueryFilter) IQuery {
+	return q.wrap(q.IQuery.MatchAny(filters...))
+}
+
+// And adds a boolean condition, all the conditions should be satisfied
+func (q *tenantQuery) And(conditions ...Condition) IQuery {
+	return q.wrap(q.IQuery.And(conditions...))
+}
+
+// Or adds a boolean condition, any of the conditions should be satisfied
+func (q *tenantQuery) Or(conditions ...Condition) IQuery {
+	return q.wrap(q.IQuery.Or(conditions...))
+}
+
+// Not adds a boolean condition, the condition should not be satisfied
+func (q *tenantQuery) Not(condition Condition) IQuery {
+	return q.wrap(q.IQuery.Not(condition))
+}
+
+// Sort adds sort order by field
+func (q *tenantQuery) Sort(sort string) IQuery {
+	return q.wrap(q.IQuery.Sort(sort))
+}
+
+// Page sets the requested page number
+func (q *tenantQuery) Page(page int) IQuery {
+	return q.wrap(q.IQuery.Page(page))
+}
+
+// Limit sets the page size limit
+func (q *tenantQuery) Limit(limit int) IQuery {
+	return q.wrap(q.IQuery.Limit(limit))
+}
+
+// List executes the query on the tenant table
+func (q *tenantQuery) List(entityIDs []string, keys ...string) ([]Entity, error) {
+	return q.IQuery.List(entityIDs, q.db.keys(keys)...)
+}
+
+// Find executes the query on the tenant table
+func (q *tenantQuery) Find(keys ...string) ([]Entity, int64, error) {
+	return q.IQuery.Find(q.db.keys(keys)...)
+}
+
+// Count executes the query on the tenant table
+func (q *tenantQuery) Count(keys ...string) (int64, error) {
+	return q.IQuery.Count(q.db.keys(keys)...)
+}
+
+// Aggregation executes the query on the tenant table
+func (q *tenantQuery) Aggregation(field string, function AggFunc, keys ...string) (float64, error) {
+	return q.IQuery.Aggregation(field, function, q.db.keys(keys)...)
+}
+
+// GroupCount executes the query on the tenant table
+func (q *tenantQuery) GroupCount(field string, keys ...string) (map[any]int64, int64, error) {
+	return q.IQuery.GroupCount(field, q.db.keys(keys)...)
+}
+
+// GroupAggregation executes the query on the tenant table
+func (q *tenantQuery) GroupAggregation(field string, function AggFunc, keys ...string) (map[any]Tuple[int64, float64], float64, error) {
+	return q.IQuery.GroupAggregation(field, function, q.db.keys(keys)...)
+}
+
+// Histogram executes the query on the tenant table
+func (q *tenantQuery) Histogram(field string, function AggFunc, timeField string, interval time.Duration, keys ...string) (map[Timestamp]Tuple[int64, float64], float64, error) {
+	return q.IQuery.Histogram(field, function, timeField, interval, q.db.keys(keys)...)
+}
+
+// Histogram2D executes the query on the tenant table
+func (q *tenantQuery) Histogram2D(field string, function AggFunc, dim, timeField string, interval time.Duration, keys ...string) (map[Timestamp]map[any]Tuple[int64, float64], float64, error) {
+	return q.IQuery.Histogram2D(field, function, dim, timeField, interval, q.db.keys(keys)...)
+}
+
+// FindSingle executes the query on the tenant table
+func (q *tenantQuery) FindSingle(keys ...string) (Entity, error) {
+	return q.IQuery.FindSingle(q.db.keys(keys)...)
+}
+
+// GetMap executes the query on the tenant table
+func (q *tenantQuery) GetMap(keys ...string) (map[string]Entity, error) {
+	return q.IQuery.GetMap(q.db.keys(keys)...)
+}
+
+// GetIDs executes the query on the tenant table
+func (q *tenantQuery) GetIDs(keys ...string) ([]string, error) {
+	return q.IQuery.GetIDs(q.db.keys(keys)...)
+}
+
+// Delete executes the query on the tenant table
+func (q *tenantQuery) Delete(keys ...string) (int64, error) {
+	return q.IQuery.Delete(q.db.keys(keys)...)
+}
+
+// SetField executes the query on the tenant table
+func (q *tenantQuery) SetField(field string, value any, keys ...string) (int64, error) {
+	return q.IQuery.SetField(field, value, q.db.keys(keys)...)
+}
+
+// SetFields executes the query on the tenant table
+func (q *tenantQuery) SetFields(fields map[string]any, keys ...string) (int64, error) {
+	return q.IQuery.SetFields(fields, q.db.keys(keys)...)
+}
+
+// endregion
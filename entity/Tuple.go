@@ -1,7 +1,68 @@
 package entity
 
+// region Tuple --------------------------------------------------------------------------------------------------------
+
 // Tuple model represents a generic key-value pair
 type Tuple[K, V any] struct {
 	Key   K `json:"key"`   // Tuple key
 	Value V `json:"value"` // Tuple value
 }
+
+// NewTuple return new instance of the tuple
+func NewTuple[K, V any](key K, value V) Tuple[K, V] {
+	return Tuple[K, V]{Key: key, Value: value}
+}
+
+// TupleKeys returns the keys of the tuples list (in the list order)
+func TupleKeys[K, V any](list []Tuple[K, V]) []K {
+	result := make([]K, 0, len(list))
+	for _, t := range list {
+		result = append(result, t.Key)
+	}
+	return result
+}
+
+// TupleValues returns the values of the tuples list (in the list order)
+func TupleValues[K, V any](list []Tuple[K, V]) []V {
+	result := make([]V, 0, len(list))
+	for _, t := range list {
+		result = append(result, t.Value)
+	}
+	return result
+}
+
+// MapToTuples converts map to list of tuples (the order of the list is not defined)
+func MapToTuples[K comparable, V any](m map[K]V) []Tuple[K, V] {
+	result := make([]Tuple[K, V], 0, len(m))
+	for k, v := range m {
+		result = append(result, NewTuple(k, v))
+	}
+	return result
+}
+
+// TuplesToMap converts list of tuples to map, for duplicate keys the last value wins
+func TuplesToMap[K comparable, V any](list []Tuple[K, V]) map[K]V {
+	result := make(map[K]V, len(list))
+	for _, t := range list {
+		result[t.Key] = t.Value
+	}
+	return result
+}
+
+// endregion
+
+// region Triple -------------------------------------------------------------------------------------------------------
+
+// Triple model represents a generic group of three values (e.g. two dimensional histogram cell: x, y and value)
+type Triple[A, B, C any] struct {
+	First  A `json:"first"`  // First value
+	Second B `json:"second"` // Second value
+	Third  C `json:"third"`  // Third value
+}
+
+// NewTriple return new instance of the triple
+func NewTriple[A, B, C any](first A, second B, third C) Triple[A, B, C] {
+	return Triple[A, B, C]{First: first, Second: second, Third: third}
+}
+
+// endregion
@@ -0,0 +1,127 @@
+package entity
+
+import (
+	"reflect"
+)
+
+// region Deep clone ---------------------------------------------------------------------------------------------------
+
+// ICloneable is implemented by entities providing their own (optimized) deep copy
+type ICloneable interface {
+	Clone() Entity
+}
+
+// CloneEntity returns a deep copy of the entity, entities implementing ICloneable are copied by their Clone method
+// while other entities are copied by reflection (see DeepClone)
+func CloneEntity(entity Entity) Entity {
+	if entity == nil {
+		return nil
+	}
+	if c, ok := entity.(ICloneable); ok {
+		return c.Clone()
+	}
+	return DeepClone(entity)
+}
+
+// DeepClone returns a deep copy of the value using reflection (without JSON round trip)
+// Pointers, slices, maps, arrays, interfaces and exported struct fields are copied recursively and shared pointers
+// (including cycles) are preserved in the copy. Unexported struct fields, channels and functions are copied as is
+func DeepClone[T any](value T) T {
+	src := reflect.ValueOf(&value).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	cloneValue(dst, src, make(map[cloneKey]reflect.Value))
+	return dst.Interface().(T)
+}
+
+// cloneKey identifies a cloned pointer, the type is part of the key since a pointer to a struct and a pointer to its
+// first field share the same address
+type cloneKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// copy the source value to the (settable) destination value
+func cloneValue(dst, src reflect.Value, visited map[cloneKey]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		key := cloneKey{addr: src.Pointer(), typ: src.Type()}
+		if cloned, ok := visited[key]; ok {
+			dst.Set(cloned)
+			return
+		}
+		ptr := reflect.New(src.Elem().Type())
+		visited[key] = ptr
+		cloneValue(ptr.Elem(), src.Elem(), visited)
+		dst.Set(ptr)
+
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		elem := reflect.New(src.Elem().Type()).Elem()
+		cloneValue(elem, src.Elem(), visited)
+		dst.Set(elem)
+
+	case reflect.Struct:
+		// Copy all fields (including unexported) then deep copy the exported fields
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				cloneValue(dst.Field(i), src.Field(i), visited)
+			}
+		}
+
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		slice := reflect.MakeSlice(src.Type(), src.Len(), src.Cap())
+		if isPlainKind(src.Type().Elem().Kind()) {
+			reflect.Copy(slice, src)
+		} else {
+			for i := 0; i < src.Len(); i++ {
+				cloneValue(slice.Index(i), src.Index(i), visited)
+			}
+		}
+		dst.Set(slice)
+
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			cloneValue(dst.Index(i), src.Index(i), visited)
+		}
+
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			val := reflect.New(src.Type().Elem()).Elem()
+			cloneValue(val, iter.Value(), visited)
+			m.SetMapIndex(iter.Key(), val)
+		}
+		dst.Set(m)
+
+	default:
+		dst.Set(src)
+	}
+}
+
+// check if the kind is copied by value (no nested references)
+func isPlainKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	default:
+		return false
+	}
+}
+
+// endregion
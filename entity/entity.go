@@ -115,38 +115,84 @@ func NewBaseEntityEx() Entity {
 // region Simple Entity ------------------------------------------------------------------------------------------------
 
 // SimpleEntity is a primitive type expressed as an Entity
+// Use WithTable / WithKey (or SimpleEntityFactory) to persist the value through the database and cache interfaces
 type SimpleEntity[T any] struct {
-	Value T `json:"value"` // entity value
+	Value T      `json:"value"` // entity value
+	table string // table name override
+	key   string // sharding key override
 }
 
 func (e *SimpleEntity[T]) ID() string { return fmt.Sprintf("%v", e.Value) }
 
-func (e *SimpleEntity[T]) TABLE() string { return "" }
+func (e *SimpleEntity[T]) TABLE() string { return e.table }
 
 func (e *SimpleEntity[T]) NAME() string { return fmt.Sprintf("%v", reflect.TypeOf(e.Value).Name()) }
 
-func (e *SimpleEntity[T]) KEY() string { return fmt.Sprintf("%v", e.Value) }
+func (e *SimpleEntity[T]) KEY() string {
+	if len(e.key) > 0 {
+		return e.key
+	}
+	return fmt.Sprintf("%v", e.Value)
+}
+
+// WithTable sets the table name of the entity
+func (e *SimpleEntity[T]) WithTable(table string) *SimpleEntity[T] {
+	e.table = table
+	return e
+}
+
+// WithKey sets the sharding key of the entity (the default key is the value)
+func (e *SimpleEntity[T]) WithKey(key string) *SimpleEntity[T] {
+	e.key = key
+	return e
+}
 
 func NewSimpleEntity[T any]() Entity {
 	return &SimpleEntity[T]{}
 }
 
+// SimpleEntityFactory returns factory of simple entities bound to the table and optional sharding key
+func SimpleEntityFactory[T any](table string, key ...string) EntityFactory {
+	return func() Entity {
+		e := &SimpleEntity[T]{table: table}
+		if len(key) > 0 {
+			e.key = key[0]
+		}
+		return e
+	}
+}
+
 // endregion
 
 // region Entities -----------------------------------------------------------------------------------------------------
 
 // Entities is a primitive/complex type array expressed as an Entity
+// Use WithTable / WithKey (or EntitiesFactory) to persist the list through the database and cache interfaces
 type Entities[T any] struct {
-	Values []T `json:"values"` // entity list
+	Values []T    `json:"values"` // entity list
+	table  string // table name override
+	key    string // sharding key override
 }
 
 func (e *Entities[T]) ID() string { return "" }
 
-func (e *Entities[T]) TABLE() string { return "" }
+func (e *Entities[T]) TABLE() string { return e.table }
 
 func (e *Entities[T]) NAME() string { return "" }
 
-func (e *Entities[T]) KEY() string { return "" }
+func (e *Entities[T]) KEY() string { return e.key }
+
+// WithTable sets the table name of the entity
+func (e *Entities[T]) WithTable(table string) *Entities[T] {
+	e.table = table
+	return e
+}
+
+// WithKey sets the sharding key of the entity
+func (e *Entities[T]) WithKey(key string) *Entities[T] {
+	e.key = key
+	return e
+}
 
 func NewEntities[T any]() Entity {
 	return &Entities[T]{
@@ -154,6 +200,17 @@ func NewEntities[T any]() Entity {
 	}
 }
 
+// EntitiesFactory returns factory of entity lists bound to the table and optional sharding key
+func EntitiesFactory[T any](table string, key ...string) EntityFactory {
+	return func() Entity {
+		e := &Entities[T]{Values: make([]T, 0), table: table}
+		if len(key) > 0 {
+			e.key = key[0]
+		}
+		return e
+	}
+}
+
 func (e *Entities[T]) Add(item T) int {
 	e.Values = append(e.Values, item)
 	return len(e.Values)
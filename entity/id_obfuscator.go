@@ -0,0 +1,108 @@
+package entity
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// region ID Obfuscator ------------------------------------------------------------------------------------------------
+
+const obfuscatorAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// IdObfuscator encodes numeric IDs to short reversible strings (hashids-style) based on a secret salt
+// It is used to avoid exposing sequential internal IDs in public APIs (e.g. /users/1024 -> /users/Xk9Rb)
+type IdObfuscator struct {
+	salt     string
+	alphabet string
+}
+
+// NewIdObfuscator creates a new ID obfuscator with the given salt, different salts produce different encodings
+func NewIdObfuscator(salt string) *IdObfuscator {
+	return &IdObfuscator{salt: salt, alphabet: consistentShuffle(obfuscatorAlphabet, salt)}
+}
+
+// Encode the numeric ID
+// The first character (lottery) is derived from the ID and used to re-shuffle the alphabet, so sequential IDs look unrelated
+func (o *IdObfuscator) Encode(id uint64) string {
+	lottery := o.alphabet[id%uint64(len(o.alphabet))]
+	alphabet := consistentShuffle(o.alphabet, string(lottery)+o.salt)
+
+	base := uint64(len(alphabet))
+	body := make([]byte, 0, 12)
+	for {
+		body = append(body, alphabet[id%base])
+		if id /= base; id == 0 {
+			break
+		}
+	}
+
+	// Reverse digits to most significant first
+	for i, j := 0, len(body)-1; i < j; i, j = i+1, j-1 {
+		body[i], body[j] = body[j], body[i]
+	}
+	return string(lottery) + string(body)
+}
+
+// Decode the obfuscated ID
+func (o *IdObfuscator) Decode(hash string) (uint64, error) {
+	if len(hash) < 2 {
+		return 0, fmt.Errorf("invalid id: %s", hash)
+	}
+
+	alphabet := consistentShuffle(o.alphabet, hash[0:1]+o.salt)
+	base := uint64(len(alphabet))
+
+	id := uint64(0)
+	for _, c := range hash[1:] {
+		index := strings.IndexRune(alphabet, c)
+		if index < 0 {
+			return 0, fmt.Errorf("invalid id: %s", hash)
+		}
+		id = id*base + uint64(index)
+	}
+
+	// Verify the hash was produced by this obfuscator (same salt)
+	if o.Encode(id) != hash {
+		return 0, fmt.Errorf("invalid id: %s", hash)
+	}
+	return id, nil
+}
+
+// EncodeString encodes numeric string ID (e.g. IDN())
+func (o *IdObfuscator) EncodeString(id string) (string, error) {
+	if value, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return "", fmt.Errorf("id %s is not numeric", id)
+	} else {
+		return o.Encode(value), nil
+	}
+}
+
+// DecodeString decodes the obfuscated ID to numeric string ID
+func (o *IdObfuscator) DecodeString(hash string) (string, error) {
+	if value, err := o.Decode(hash); err != nil {
+		return "", err
+	} else {
+		return strconv.FormatUint(value, 10), nil
+	}
+}
+
+// shuffle the alphabet consistently using the salt
+func consistentShuffle(alphabet, salt string) string {
+	if len(salt) == 0 {
+		return alphabet
+	}
+
+	result := []byte(alphabet)
+	for i, v, p := len(result)-1, 0, 0; i > 0; i-- {
+		v %= len(salt)
+		n := int(salt[v])
+		p += n
+		j := (n + v + p) % i
+		result[i], result[j] = result[j], result[i]
+		v++
+	}
+	return string(result)
+}
+
+// endregion
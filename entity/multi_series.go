@@ -0,0 +1,216 @@
+package entity
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MultiSeriesTimeColumn is the time column name of the rows and CSV export
+const MultiSeriesTimeColumn = "timestamp"
+
+// region MultiSeries --------------------------------------------------------------------------------------------------
+
+// MultiSeries is a set of time series sharing the same time buckets (e.g. one series per dimension), so it can be used
+// as a matrix where the rows are the buckets and the columns are the series
+type MultiSeries[T any] struct {
+	Range   TimeFrame        `json:"range"`   // Range of the series (from ... to)
+	Buckets []Timestamp      `json:"buckets"` // Sorted time buckets shared by all series
+	Series  []*TimeSeries[T] `json:"series"`  // Series sorted by name, each series has a data point per bucket
+}
+
+// MultiSeriesOptions controls the conversion of the two-dimensional histogram to multi series
+type MultiSeriesOptions struct {
+	Range    TimeFrame     // Buckets range [from, to), empty range for the histogram range
+	Interval time.Duration // Bucket interval used to add the missing buckets (0 to use only the histogram buckets)
+	Count    bool          // Use the count of each bucket instead of the aggregated value
+}
+
+// NewMultiSeriesFromHistogram converts the output of IQuery.Histogram2D (bucket -> dimension -> (count, value)) to
+// multi series with one series per dimension, all series have the same buckets and missing values are zero-filled
+func NewMultiSeriesFromHistogram(histogram map[Timestamp]map[any]Tuple[int64, float64], options MultiSeriesOptions) *MultiSeries[float64] {
+
+	// Collect the buckets and dimensions
+	inRange := func(ts Timestamp) bool {
+		return options.Range.To <= options.Range.From || (ts >= options.Range.From && ts < options.Range.To)
+	}
+	bucketSet := make(map[Timestamp]bool)
+	dimensions := make(map[string]map[Timestamp]float64)
+	for bucket, values := range histogram {
+		if !inRange(bucket) {
+			continue
+		}
+		bucketSet[bucket] = true
+		for dim, tuple := range values {
+			name := fmt.Sprint(dim)
+			if dimensions[name] == nil {
+				dimensions[name] = make(map[Timestamp]float64)
+			}
+			value := tuple.Value
+			if options.Count {
+				value = float64(tuple.Key)
+			}
+			dimensions[name][bucket] += value
+		}
+	}
+
+	// Add the missing buckets of the range
+	step := Timestamp(options.Interval.Milliseconds())
+	result := &MultiSeries[float64]{Range: options.Range, Buckets: make([]Timestamp, 0), Series: make([]*TimeSeries[float64], 0)}
+	if options.Range.To <= options.Range.From {
+		result.Range = histogramRange(bucketSet, step)
+	}
+	if step > 0 {
+		for ts := alignBucket(result.Range.From, bucketSet, step); ts < result.Range.To; ts += step {
+			bucketSet[ts] = true
+		}
+	}
+	for bucket := range bucketSet {
+		result.Buckets = append(result.Buckets, bucket)
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool { return result.Buckets[i] < result.Buckets[j] })
+
+	// Build the zero-filled series
+	names := make([]string, 0, len(dimensions))
+	for name := range dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		series := &TimeSeries[float64]{Name: name, Range: result.Range, Values: make([]TimeDataPoint[float64], 0, len(result.Buckets))}
+		for _, bucket := range result.Buckets {
+			series.Values = append(series.Values, NewTimeDataPoint(bucket, dimensions[name][bucket]))
+		}
+		result.Series = append(result.Series, series)
+	}
+	return result
+}
+
+// Names returns the series names (the matrix columns)
+func (ms *MultiSeries[T]) Names() []string {
+	names := make([]string, 0, len(ms.Series))
+	for _, series := range ms.Series {
+		names = append(names, series.Name)
+	}
+	return names
+}
+
+// Matrix returns the values as a matrix, a row per bucket and a column per series
+func (ms *MultiSeries[T]) Matrix() [][]T {
+	matrix := make([][]T, len(ms.Buckets))
+	for i := range ms.Buckets {
+		row := make([]T, len(ms.Series))
+		for j, series := range ms.Series {
+			if i < len(series.Values) {
+				row[j] = series.Values[i].Value
+			}
+		}
+		matrix[i] = row
+	}
+	return matrix
+}
+
+// Columns returns the column name of each series in the rows and CSV export, the series names made unique and
+// different from the time column (e.g. a series named "timestamp" is exported as "timestamp_2")
+func (ms *MultiSeries[T]) Columns() []string {
+	used := map[string]bool{MultiSeriesTimeColumn: true}
+	columns := make([]string, 0, len(ms.Series))
+	for _, series := range ms.Series {
+		column := series.Name
+		for i := 2; used[column]; i++ {
+			column = fmt.Sprintf("%s_%d", series.Name, i)
+		}
+		used[column] = true
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// Rows returns the values as a list of rows (bucket timestamp and a value per series column, see Columns), the format
+// used by most charting libraries
+func (ms *MultiSeries[T]) Rows() []map[string]any {
+	matrix := ms.Matrix()
+	columns := ms.Columns()
+	rows := make([]map[string]any, 0, len(ms.Buckets))
+	for i, bucket := range ms.Buckets {
+		row := map[string]any{MultiSeriesTimeColumn: bucket}
+		for j, column := range columns {
+			row[column] = matrix[i][j]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// WriteCSV writes the matrix as CSV with a header line (timestamp and the series columns, see Columns), the timestamps
+// are formatted using the format (see Timestamp.String) or written as epoch milliseconds if the format is empty
+func (ms *MultiSeries[T]) WriteCSV(w io.Writer, timeFormat string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{MultiSeriesTimeColumn}, ms.Columns()...)); err != nil {
+		return err
+	}
+
+	matrix := ms.Matrix()
+	for i, bucket := range ms.Buckets {
+		record := make([]string, 0, len(ms.Series)+1)
+		if len(timeFormat) == 0 {
+			record = append(record, strconv.FormatInt(int64(bucket), 10))
+		} else {
+			record = append(record, bucket.String(timeFormat))
+		}
+		for _, value := range matrix[i] {
+			record = append(record, fmt.Sprint(value))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON writes the rows (see Rows) as JSON array
+func (ms *MultiSeries[T]) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(ms.Rows())
+}
+
+// first timestamp from the start on the buckets grid (the buckets are step apart from the first bucket), so the filled
+// buckets are aligned with the histogram buckets
+func alignBucket(from Timestamp, buckets map[Timestamp]bool, step Timestamp) Timestamp {
+	anchor, found := from, false
+	for bucket := range buckets {
+		if !found || bucket < anchor {
+			anchor, found = bucket, true
+		}
+	}
+	offset := (anchor - from) % step
+	if offset < 0 {
+		offset += step
+	}
+	return from + offset
+}
+
+// range of the histogram buckets [first bucket, last bucket + step)
+func histogramRange(buckets map[Timestamp]bool, step Timestamp) (tf TimeFrame) {
+	if len(buckets) == 0 {
+		return tf
+	}
+	first := true
+	for bucket := range buckets {
+		if first || bucket < tf.From {
+			tf.From = bucket
+		}
+		if first || bucket > tf.To {
+			tf.To = bucket
+		}
+		first = false
+	}
+	tf.To += step
+	return tf
+}
+
+// endregion
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// region Entity Registry ----------------------------------------------------------------------------------------------
+
+// The entity registry maps entity type names (the entity TABLE() template) to their factory methods
+// It is used by generic tools (e.g. import / export) to reconstruct entities without knowing their concrete type
+var (
+	registryMu     sync.RWMutex
+	entityRegistry = make(map[string]EntityFactory)
+)
+
+// RegisterEntity adds one or more entity factories to the registry
+// The registration name is the entity TABLE() template (e.g. "usage-{{accountId}}"), or the struct name if the table is empty
+func RegisterEntity(factories ...EntityFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, factory := range factories {
+		if factory == nil {
+			continue
+		}
+		entityRegistry[EntityTypeName(factory())] = factory
+	}
+}
+
+// GetEntityFactory returns the factory registered for the given name
+func GetEntityFactory(name string) (EntityFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	factory, ok := entityRegistry[name]
+	return factory, ok
+}
+
+// RegisteredEntities returns the sorted list of all registered entity names
+func RegisteredEntities() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(entityRegistry))
+	for name := range entityRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EntityTypeName returns the registration name of an entity: the TABLE() template, or the struct name if the table is empty
+func EntityTypeName(entity Entity) string {
+	if table := entity.TABLE(); len(table) > 0 {
+		return table
+	}
+	t := reflect.TypeOf(entity)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// endregion
@@ -27,9 +27,13 @@ import (
 //	}
 //}
 
-// Marshal returns the JSON encoding of v
+// Marshal returns the JSON encoding of v, Timestamp fields tagged with rfc3339 or epoch option are written in that format
 func Marshal(v any) ([]byte, error) {
-	return json.Marshal(&v)
+	bytes, err := json.Marshal(&v)
+	if err != nil {
+		return nil, err
+	}
+	return applyTimestampTags(v, bytes)
 }
 
 // Unmarshal returns the struct from JSON byte array
@@ -44,7 +48,7 @@ func Unmarshal(data []byte, v any) error {
 
 // JsonMarshal convert any type to a map of string->any
 func JsonMarshal(v any) (Json, error) {
-	bytes, err := json.Marshal(&v)
+	bytes, err := Marshal(v)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,143 @@
+package entity
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// region Sortable Ids -------------------------------------------------------------------------------------------------
+/**
+ * Generate lexicographically sortable ids: the id starts with the creation time, so sorting the ids as strings sorts
+ * them by creation time. The creation time can be extracted from the id.
+ */
+
+const (
+	crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	base62Alphabet    = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	// ksuidEpoch is the KSUID custom epoch (2014-05-13) in seconds, extends the 32 bit timestamp range to year 2150
+	ksuidEpoch = 1400000000
+	ksuidSize  = 27
+	ulidSize   = 26
+)
+
+var (
+	ulidMu       sync.Mutex
+	ulidLastTime int64
+	ulidLastRand [10]byte
+)
+
+// ULID return a 26 characters Universally Unique Lexicographically Sortable Identifier (48 bits epoch milliseconds
+// followed by 80 random bits in Crockford base32). Ids generated within the same millisecond are monotonic
+func ULID() string {
+	ulidMu.Lock()
+	now := time.Now().UnixMilli()
+	if now > ulidLastTime {
+		_, _ = rand.Read(ulidLastRand[:])
+	} else if now = ulidLastTime; !incrementBytes(ulidLastRand[:]) {
+		// Random part overflow within the same millisecond, move to the next millisecond
+		now++
+		_, _ = rand.Read(ulidLastRand[:])
+	}
+	ulidLastTime = now
+	random := ulidLastRand
+	ulidMu.Unlock()
+
+	// 128 bits: 48 bits time + 80 bits random, encoded as 26 characters of 5 bits (the first character holds 3 bits)
+	value := new(big.Int).SetInt64(now)
+	value.Lsh(value, 80)
+	value.Or(value, new(big.Int).SetBytes(random[:]))
+	return encodeBigInt(value, crockfordAlphabet, ulidSize)
+}
+
+// ULIDTime extracts the creation time of the ULID
+func ULIDTime(id string) (Timestamp, error) {
+	if len(id) != ulidSize {
+		return 0, fmt.Errorf("invalid ulid: %s", id)
+	}
+	value, err := decodeBigInt(strings.ToUpper(id), crockfordAlphabet)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ulid: %s", id)
+	}
+	return Timestamp(value.Rsh(value, 80).Int64()), nil
+}
+
+// KSUID return a 27 characters K-Sortable Unique Identifier (32 bits epoch seconds followed by 128 random bits in base62)
+func KSUID() string {
+	data := make([]byte, 20)
+	ts := uint32(time.Now().Unix() - ksuidEpoch)
+	data[0], data[1], data[2], data[3] = byte(ts>>24), byte(ts>>16), byte(ts>>8), byte(ts)
+	_, _ = rand.Read(data[4:])
+	return encodeBigInt(new(big.Int).SetBytes(data), base62Alphabet, ksuidSize)
+}
+
+// KSUIDTime extracts the creation time (seconds resolution) of the KSUID
+func KSUIDTime(id string) (Timestamp, error) {
+	if len(id) != ksuidSize {
+		return 0, fmt.Errorf("invalid ksuid: %s", id)
+	}
+	value, err := decodeBigInt(id, base62Alphabet)
+	if err != nil || value.BitLen() > 160 {
+		return 0, fmt.Errorf("invalid ksuid: %s", id)
+	}
+	seconds := value.Rsh(value, 128).Int64() + ksuidEpoch
+	return Timestamp(seconds * 1000), nil
+}
+
+// PrefixedID return a typed, sortable id in the format: <prefix>_<ULID> (e.g. usr_01HN3Z8Q2V8XK6Y4T1JZ5M7C9D)
+func PrefixedID(prefix string) string {
+	return prefix + "_" + ULID()
+}
+
+// ParsePrefixedID extracts the prefix and the creation time of the prefixed id
+func ParsePrefixedID(id string) (prefix string, ts Timestamp, err error) {
+	idx := strings.LastIndex(id, "_")
+	if idx < 0 {
+		return "", 0, fmt.Errorf("invalid prefixed id: %s", id)
+	}
+	ts, err = ULIDTime(id[idx+1:])
+	return id[:idx], ts, err
+}
+
+// increment big endian bytes, returns false on overflow
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encode the value in the alphabet base, left padded with zeros to the size
+func encodeBigInt(value *big.Int, alphabet string, size int) string {
+	result := make([]byte, size)
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+	for i := size - 1; i >= 0; i-- {
+		value.DivMod(value, base, mod)
+		result[i] = alphabet[mod.Int64()]
+	}
+	return string(result)
+}
+
+// decode the value from the alphabet base
+func decodeBigInt(value string, alphabet string) (*big.Int, error) {
+	result := new(big.Int)
+	base := big.NewInt(int64(len(alphabet)))
+	for _, c := range value {
+		idx := strings.IndexRune(alphabet, c)
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid character: %c", c)
+		}
+		result.Mul(result, base).Add(result, big.NewInt(int64(idx)))
+	}
+	return result, nil
+}
+
+// endregion
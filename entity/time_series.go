@@ -0,0 +1,294 @@
+package entity
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// region Numeric aggregations -----------------------------------------------------------------------------------------
+
+// Numeric is a constraint for the numeric time series values
+type Numeric interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~float32 | ~float64
+}
+
+// AggSum returns the sum of the values (used as Resample aggregation function)
+func AggSum[T Numeric](values []T) (result T) {
+	for _, v := range values {
+		result += v
+	}
+	return result
+}
+
+// AggAvg returns the average of the values (used as Resample aggregation function)
+func AggAvg[T Numeric](values []T) T {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	return T(sum / float64(len(values)))
+}
+
+// AggMin returns the minimum of the values (used as Resample aggregation function)
+func AggMin[T Numeric](values []T) (result T) {
+	for i, v := range values {
+		if i == 0 || v < result {
+			result = v
+		}
+	}
+	return result
+}
+
+// AggMax returns the maximum of the values (used as Resample aggregation function)
+func AggMax[T Numeric](values []T) (result T) {
+	for i, v := range values {
+		if i == 0 || v > result {
+			result = v
+		}
+	}
+	return result
+}
+
+// AggLast returns the last value (used as Resample aggregation function)
+func AggLast[T any](values []T) (result T) {
+	if len(values) > 0 {
+		result = values[len(values)-1]
+	}
+	return result
+}
+
+// SeriesMinMax returns the minimum and maximum values of the series
+func SeriesMinMax[T Numeric](ts *TimeSeries[T]) (min T, max T) {
+	values := ts.values()
+	return AggMin(values), AggMax(values)
+}
+
+// SeriesSum returns the sum of the series values
+func SeriesSum[T Numeric](ts *TimeSeries[T]) T {
+	return AggSum(ts.values())
+}
+
+// SeriesAvg returns the average of the series values
+func SeriesAvg[T Numeric](ts *TimeSeries[T]) float64 {
+	if len(ts.Values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, dp := range ts.Values {
+		sum += float64(dp.Value)
+	}
+	return sum / float64(len(ts.Values))
+}
+
+// endregion
+
+// region TimeSeries transformations -----------------------------------------------------------------------------------
+
+// FillPolicy defines how missing data points are filled
+type FillPolicy int
+
+const (
+	// FillZero fills missing data points with the zero value
+	FillZero FillPolicy = iota
+	// FillPrevious fills missing data points with the previous value (zero value before the first data point)
+	FillPrevious
+	// FillInterpolate fills missing data points with linear interpolation of the surrounding values
+	// Applies to numeric values only, other types are filled like FillPrevious
+	FillInterpolate
+)
+
+// Resample groups the data points into buckets of the interval (aligned to the series range start) and aggregates
+// each bucket using the aggregation function. Empty buckets are omitted, use Fill to add them
+func (ts *TimeSeries[T]) Resample(interval time.Duration, agg func(values []T) T) *TimeSeries[T] {
+	result := &TimeSeries[T]{Name: ts.Name, Range: ts.Range, Values: make([]TimeDataPoint[T], 0)}
+	step := Timestamp(interval.Milliseconds())
+	if step <= 0 {
+		result.Values = append(result.Values, ts.sorted()...)
+		return result
+	}
+
+	buckets := make(map[Timestamp][]T)
+	keys := make([]Timestamp, 0)
+	for _, dp := range ts.sorted() {
+		slot := ts.slot(dp.Timestamp, step)
+		if _, ok := buckets[slot]; !ok {
+			keys = append(keys, slot)
+		}
+		buckets[slot] = append(buckets[slot], dp.Value)
+	}
+	for _, slot := range keys {
+		result.Values = append(result.Values, NewTimeDataPoint(slot, agg(buckets[slot])))
+	}
+	return result
+}
+
+// Merge combines the data points of both series by timestamp, data points existing in both series are combined using
+// the combine function. The result range covers both ranges
+func (ts *TimeSeries[T]) Merge(other *TimeSeries[T], combine func(a, b T) T) *TimeSeries[T] {
+	result := &TimeSeries[T]{Name: ts.Name, Range: ts.Range, Values: make([]TimeDataPoint[T], 0)}
+	if other == nil {
+		result.Values = append(result.Values, ts.sorted()...)
+		return result
+	}
+	if other.Range.From != 0 && (result.Range.From == 0 || other.Range.From < result.Range.From) {
+		result.Range.From = other.Range.From
+	}
+	if other.Range.To > result.Range.To {
+		result.Range.To = other.Range.To
+	}
+
+	merged := make(map[Timestamp]T)
+	for _, dp := range ts.Values {
+		merged[dp.Timestamp] = dp.Value
+	}
+	for _, dp := range other.Values {
+		if v, ok := merged[dp.Timestamp]; ok {
+			merged[dp.Timestamp] = combine(v, dp.Value)
+		} else {
+			merged[dp.Timestamp] = dp.Value
+		}
+	}
+	for t, v := range merged {
+		result.Values = append(result.Values, NewTimeDataPoint(t, v))
+	}
+	sort.Slice(result.Values, func(i, j int) bool { return result.Values[i].Timestamp < result.Values[j].Timestamp })
+	return result
+}
+
+// MaxFillPoints is the max number of intervals filled by Fill, series requiring more intervals are not filled
+const MaxFillPoints = 100000
+
+// Fill adds the missing data points in every interval of the series range (or between the first and last data points
+// if the range is not set) according to the fill policy. Intervals with data points keep their data points as is
+// (including data points which are not on the interval start), empty intervals get a data point at the interval start.
+// The series is not filled if the range has more than MaxFillPoints intervals
+func (ts *TimeSeries[T]) Fill(interval time.Duration, policy FillPolicy) *TimeSeries[T] {
+	points := ts.sorted()
+	result := &TimeSeries[T]{Name: ts.Name, Range: ts.Range, Values: make([]TimeDataPoint[T], 0)}
+	step := Timestamp(interval.Milliseconds())
+	if step <= 0 || (len(points) == 0 && ts.Range.To <= ts.Range.From) {
+		result.Values = append(result.Values, points...)
+		return result
+	}
+
+	from, to := ts.Range.From, ts.Range.To
+	if to <= from {
+		from, to = points[0].Timestamp, points[len(points)-1].Timestamp
+	}
+	if (to-from)/step >= MaxFillPoints {
+		result.Values = append(result.Values, points...)
+		return result
+	}
+
+	// Data points before the range are kept
+	next := 0
+	var prev *TimeDataPoint[T]
+	for next < len(points) && points[next].Timestamp < from {
+		result.Values = append(result.Values, points[next])
+		prev = &points[next]
+		next++
+	}
+
+	for t := from; t <= to; t += step {
+		if next < len(points) && points[next].Timestamp < t+step {
+			for next < len(points) && points[next].Timestamp < t+step {
+				result.Values = append(result.Values, points[next])
+				prev = &points[next]
+				next++
+			}
+			continue
+		}
+
+		var value T
+		switch policy {
+		case FillPrevious:
+			if prev != nil {
+				value = prev.Value
+			}
+		case FillInterpolate:
+			if prev != nil && next < len(points) {
+				value = interpolate(*prev, points[next], t)
+			} else if prev != nil {
+				value = prev.Value
+			}
+		}
+		result.Values = append(result.Values, NewTimeDataPoint(t, value))
+	}
+
+	// Data points after the range are kept
+	result.Values = append(result.Values, points[next:]...)
+	return result
+}
+
+// AlignTo returns the series on the grid of the time frame: data points outside the time frame are dropped, data
+// points are moved to the start of their interval (the last value wins) and the missing intervals are filled according
+// to the fill policy
+func (ts *TimeSeries[T]) AlignTo(tf TimeFrame, interval time.Duration, policy FillPolicy) *TimeSeries[T] {
+	inRange := &TimeSeries[T]{Name: ts.Name, Range: tf, Values: make([]TimeDataPoint[T], 0)}
+	for _, dp := range ts.Values {
+		if dp.Timestamp >= tf.From && dp.Timestamp <= tf.To {
+			inRange.Values = append(inRange.Values, dp)
+		}
+	}
+	return inRange.Resample(interval, AggLast[T]).Fill(interval, policy)
+}
+
+// return the data points sorted by timestamp
+func (ts *TimeSeries[T]) sorted() []TimeDataPoint[T] {
+	points := make([]TimeDataPoint[T], len(ts.Values))
+	copy(points, ts.Values)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	return points
+}
+
+// return the data point values
+func (ts *TimeSeries[T]) values() []T {
+	values := make([]T, 0, len(ts.Values))
+	for _, dp := range ts.Values {
+		values = append(values, dp.Value)
+	}
+	return values
+}
+
+// return the start of the interval slot of the timestamp (aligned to the range start)
+func (ts *TimeSeries[T]) slot(t Timestamp, step Timestamp) Timestamp {
+	offset := t - ts.Range.From
+	slot := offset - offset%step
+	if offset < 0 && offset%step != 0 {
+		slot -= step
+	}
+	return ts.Range.From + slot
+}
+
+// linear interpolation of numeric values, other types (or values of different types) return the previous value
+func interpolate[T any](prev, next TimeDataPoint[T], t Timestamp) T {
+	pv, nv := reflect.ValueOf(prev.Value), reflect.ValueOf(next.Value)
+	if !pv.IsValid() || !nv.IsValid() || pv.Type() != nv.Type() {
+		return prev.Value
+	}
+	ratio := float64(t-prev.Timestamp) / float64(next.Timestamp-prev.Timestamp)
+
+	// The value is computed on the concrete type, so interface typed series (e.g. TimeSeries[any]) are supported
+	out := reflect.New(pv.Type()).Elem()
+	switch pv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		out.SetInt(pv.Int() + int64(float64(nv.Int()-pv.Int())*ratio))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		out.SetUint(uint64(float64(pv.Uint()) + (float64(nv.Uint())-float64(pv.Uint()))*ratio))
+	case reflect.Float32, reflect.Float64:
+		out.SetFloat(pv.Float() + (nv.Float()-pv.Float())*ratio)
+	default:
+		return prev.Value
+	}
+	result, ok := out.Interface().(T)
+	if !ok {
+		return prev.Value
+	}
+	return result
+}
+
+// endregion
@@ -2,8 +2,13 @@ package entity
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 )
@@ -141,6 +146,287 @@ func (ts *Timestamp) LocalString(format string, tz string) string {
 
 // endregion
 
+// region Timestamp JSON -----------------------------------------------------------------------------------------------
+
+// TimestampFormat is the JSON representation of Timestamp values
+type TimestampFormat int32
+
+const (
+	// TimestampFormatEpoch marshals Timestamp as epoch milliseconds number (default)
+	TimestampFormatEpoch TimestampFormat = iota
+	// TimestampFormatRFC3339 marshals Timestamp as RFC3339 string with milliseconds in UTC
+	TimestampFormatRFC3339
+)
+
+var timestampFormat atomic.Int32
+
+// SetTimestampJSONFormat sets the package level JSON representation of Timestamp values (affects all the entities)
+// Unmarshaling accepts both formats regardless of this setting
+func SetTimestampJSONFormat(format TimestampFormat) {
+	timestampFormat.Store(int32(format))
+}
+
+// GetTimestampJSONFormat returns the current JSON representation of Timestamp values
+func GetTimestampJSONFormat() TimestampFormat {
+	return TimestampFormat(timestampFormat.Load())
+}
+
+// MarshalJSON writes the timestamp according to the package level format
+// A struct field may override the package level format using the json tag option rfc3339 or epoch
+// e.g. `json:"createdOn,rfc3339"`, the tag option is applied by entity.Marshal and entity.JsonMarshal
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	return ts.marshalJSON(GetTimestampJSONFormat()), nil
+}
+
+// marshalJSON writes the timestamp in the given format
+func (ts Timestamp) marshalJSON(format TimestampFormat) []byte {
+	if format == TimestampFormatRFC3339 {
+		return []byte(`"` + time.UnixMilli(int64(ts)).UTC().Format("2006-01-02T15:04:05.000Z07:00") + `"`)
+	}
+	return strconv.AppendInt(nil, int64(ts), 10)
+}
+
+// UnmarshalJSON reads epoch milliseconds number (or numeric string) and RFC3339 string
+func (ts *Timestamp) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+	if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+		if len(value) == 0 {
+			*ts = 0
+			return nil
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			*ts = Timestamp(t.UnixMilli())
+			return nil
+		}
+	}
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		*ts = Timestamp(millis)
+		return nil
+	}
+	if millis, err := strconv.ParseFloat(value, 64); err == nil {
+		*ts = Timestamp(millis)
+		return nil
+	}
+	return fmt.Errorf("invalid timestamp: %s", string(data))
+}
+
+// timestampPlan describes where the tagged Timestamp values are in the JSON of a type
+type timestampPlan struct {
+	leaf   bool                      // The value itself is a tagged Timestamp
+	format TimestampFormat           // Format of the tagged Timestamp
+	fields map[string]*timestampPlan // Object keys (struct fields) holding tagged values
+	elem   *timestampPlan            // Array elements or map values holding tagged values
+}
+
+var (
+	timestampType     = reflect.TypeOf(Timestamp(0))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timestampPlans    sync.Map // reflect.Type -> *timestampPlan (nil when the type has no tagged fields)
+)
+
+// applyTimestampTags rewrites the tagged Timestamp fields of the JSON encoding of v to their tag format
+func applyTimestampTags(v any, data []byte) ([]byte, error) {
+	if v == nil {
+		return data, nil
+	}
+	t := reflect.TypeOf(v)
+	cached, ok := timestampPlans.Load(t)
+	if !ok {
+		cached, _ = timestampPlans.LoadOrStore(t, typeTimestampPlan(t, map[reflect.Type]bool{}))
+	}
+	plan := cached.(*timestampPlan)
+	if plan == nil {
+		return data, nil
+	}
+	return plan.rewrite(data)
+}
+
+// typeTimestampPlan builds the plan of the tagged Timestamp fields of the type, nil if there are none
+func typeTimestampPlan(t reflect.Type, visiting map[reflect.Type]bool) *timestampPlan {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		// Types with their own JSON encoding are written as is
+		if visiting[t] || t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+			return nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		fields := make(map[string]*timestampPlan)
+		collectTimestampFields(t, fields, visiting)
+		if len(fields) == 0 {
+			return nil
+		}
+		return &timestampPlan{fields: fields}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if elem := typeTimestampPlan(t.Elem(), visiting); elem != nil {
+			return &timestampPlan{elem: elem}
+		}
+	}
+	return nil
+}
+
+// collectTimestampFields adds the plans of the struct fields by their JSON name, fields of embedded structs are
+// promoted unless shadowed by a field of the outer struct (the same way encoding/json does)
+func collectTimestampFields(t reflect.Type, fields map[string]*timestampPlan, visiting map[reflect.Type]bool) {
+	direct := make(map[string]bool)
+	embedded := make([]reflect.Type, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		direct[name] = true
+
+		var plan *timestampPlan
+		if format, ok := timestampTagFormat(options); ok {
+			plan = taggedTimestampPlan(f.Type, format)
+		} else {
+			plan = typeTimestampPlan(f.Type, visiting)
+		}
+		if plan != nil {
+			fields[name] = plan
+		}
+	}
+
+	for _, et := range embedded {
+		if visiting[et] {
+			continue
+		}
+		visiting[et] = true
+		promoted := make(map[string]*timestampPlan)
+		collectTimestampFields(et, promoted, visiting)
+		delete(visiting, et)
+
+		for name, plan := range promoted {
+			if _, exists := fields[name]; !exists && !direct[name] {
+				fields[name] = plan
+			}
+		}
+	}
+}
+
+// taggedTimestampPlan builds the plan of a tagged field of Timestamp type (or slice, array, map of Timestamp)
+func taggedTimestampPlan(t reflect.Type, format TimestampFormat) *timestampPlan {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timestampType {
+		return &timestampPlan{leaf: true, format: format}
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if elem := taggedTimestampPlan(t.Elem(), format); elem != nil {
+			return &timestampPlan{elem: elem}
+		}
+	}
+	return nil
+}
+
+// timestampTagFormat returns the Timestamp format of the json tag options (rfc3339 or epoch)
+func timestampTagFormat(options string) (TimestampFormat, bool) {
+	for _, option := range strings.Split(options, ",") {
+		switch option {
+		case "rfc3339":
+			return TimestampFormatRFC3339, true
+		case "epoch":
+			return TimestampFormatEpoch, true
+		}
+	}
+	return TimestampFormatEpoch, false
+}
+
+// rewrite the JSON value according to the plan, keeping the order of the object keys
+func (p *timestampPlan) rewrite(data []byte) ([]byte, error) {
+	value := bytes.TrimSpace(data)
+	if len(value) == 0 || string(value) == "null" {
+		return data, nil
+	}
+	if p.leaf {
+		var ts Timestamp
+		if err := ts.UnmarshalJSON(value); err != nil {
+			return nil, err
+		}
+		return ts.marshalJSON(p.format), nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(value))
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(byte(delim))
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		plan := p.elem
+		if delim == '{' {
+			key, er := dec.Token()
+			if er != nil {
+				return nil, er
+			}
+			name, _ := key.(string)
+			encoded, _ := json.Marshal(name)
+			buf.Write(encoded)
+			buf.WriteByte(':')
+			if p.fields != nil {
+				plan = p.fields[name]
+			}
+		}
+		var raw json.RawMessage
+		if err = dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if plan != nil {
+			if raw, err = plan.rewrite(raw); err != nil {
+				return nil, err
+			}
+		}
+		buf.Write(raw)
+	}
+	if delim == '{' {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+	return buf.Bytes(), nil
+}
+
+// endregion
+
 // region TimeFrame ----------------------------------------------------------------------------------------------------
 
 // TimeFrame represents a slot in time
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"context"
+)
+
+// Correlation field keys attached to the context by the REST middleware, tenant context and tracing helpers
+const (
+	RequestIdKey = "requestId"
+	TenantIdKey  = "tenantId"
+	TraceIdKey   = "traceId"
+	SpanIdKey    = "spanId"
+)
+
+// region Context logger -----------------------------------------------------------------------------------------------
+
+type contextFieldsKey struct{}
+
+// WithContext returns a copy of the context carrying the fields, the fields are attached to every log entry of the
+// context logger (see FromContext). A field replaces the context field with the same key
+func WithContext(ctx context.Context, fields ...Field) context.Context {
+	current := ContextFields(ctx)
+	list := make([]Field, 0, len(current)+len(fields))
+	for _, f := range current {
+		if !containsKey(fields, f.Key) {
+			list = append(list, f)
+		}
+	}
+	list = append(list, fields...)
+	return context.WithValue(ctx, contextFieldsKey{}, list)
+}
+
+// ContextFields returns the fields carried by the context
+func ContextFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(contextFieldsKey{}).([]Field)
+	return fields
+}
+
+// FromContext returns a child logger with the fields carried by the context (e.g. request id, tenant id, trace id)
+func FromContext(ctx context.Context) *Logger {
+	return With(ContextFields(ctx)...)
+}
+
+// check if the list contains field with the key
+func containsKey(fields []Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// endregion
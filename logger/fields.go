@@ -0,0 +1,183 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// region Structured fields --------------------------------------------------------------------------------------------
+
+// Field is a structured log field (key / value), written as JSON attribute in JSON mode
+type Field = zap.Field
+
+// F creates a structured log field
+func F(key string, value any) Field {
+	return zap.Any(key, value)
+}
+
+// Err creates a structured log field of the error (key: "error")
+func Err(err error) Field {
+	return zap.Error(err)
+}
+
+// endregion
+
+// region Module levels ------------------------------------------------------------------------------------------------
+
+// moduleLevels holds the per module level overrides (module name -> zap.AtomicLevel)
+var moduleLevels sync.Map
+
+// SetModuleLevel overrides the log level (DEBUG | INFO | WARN | ERROR) of the module loggers (see Module)
+func SetModuleLevel(module string, level string) {
+	lvl, ok := parseLevel(level)
+	if !ok {
+		return
+	}
+	if current, loaded := moduleLevels.LoadOrStore(module, zap.NewAtomicLevelAt(lvl)); loaded {
+		current.(zap.AtomicLevel).SetLevel(lvl)
+	}
+}
+
+// ResetModuleLevel removes the log level override of the module
+func ResetModuleLevel(module string) {
+	moduleLevels.Delete(module)
+}
+
+// parse the level name
+func parseLevel(level string) (zapcore.Level, bool) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return zap.DebugLevel, true
+	case "info":
+		return zap.InfoLevel, true
+	case "warn", "warning":
+		return zap.WarnLevel, true
+	case "error":
+		return zap.ErrorLevel, true
+	default:
+		return zap.InfoLevel, false
+	}
+}
+
+// moduleCore applies the module level override on top of the logger core
+type moduleCore struct {
+	zapcore.Core
+	module string
+}
+
+// Enabled checks the module level override or the logger level
+func (c *moduleCore) Enabled(level zapcore.Level) bool {
+	if lvl, ok := moduleLevels.Load(c.module); ok {
+		return lvl.(zap.AtomicLevel).Enabled(level)
+	}
+	return c.Core.Enabled(level)
+}
+
+// With adds structured context to the core
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &moduleCore{Core: c.Core.With(fields), module: c.module}
+}
+
+// Check delegates to the logger core (keeping its sampling and per core levels), unless the module level override
+// enables a level below the logger level, in which case the entry is written by the logger core directly
+func (c *moduleCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if lvl, ok := moduleLevels.Load(c.module); ok {
+		if !lvl.(zap.AtomicLevel).Enabled(entry.Level) {
+			return ce
+		}
+		if !c.Core.Enabled(entry.Level) {
+			return ce.AddCore(entry, c.Core)
+		}
+	}
+	return c.Core.Check(entry, ce)
+}
+
+// endregion
+
+// region Child logger -------------------------------------------------------------------------------------------------
+
+// Logger is a child logger carrying structured fields and optional module name
+type Logger struct {
+	module string
+	fields []Field
+	cached atomic.Pointer[resolvedLogger]
+}
+
+// resolvedLogger is the zap logger of the child logger, resolved from the base logger
+type resolvedLogger struct {
+	base *zap.Logger
+	zl   *zap.Logger
+}
+
+// With returns a child logger adding the fields to every log entry
+func With(fields ...Field) *Logger {
+	return &Logger{fields: fields}
+}
+
+// Module returns a child logger of the module, the module level can be overridden by SetModuleLevel
+func Module(name string) *Logger {
+	return &Logger{module: name, fields: []Field{zap.String("module", name)}}
+}
+
+// With returns a child logger adding the fields to the current fields
+func (l *Logger) With(fields ...Field) *Logger {
+	list := make([]Field, 0, len(l.fields)+len(fields))
+	list = append(append(list, l.fields...), fields...)
+	return &Logger{module: l.module, fields: list}
+}
+
+// resolve the zap logger with the module core and fields, the result is cached until the base logger is replaced (Init)
+func (l *Logger) zap() *zap.Logger {
+	base := getLogger()
+	if cached := l.cached.Load(); cached != nil && cached.base == base {
+		return cached.zl
+	}
+
+	zl := base
+	if len(l.module) > 0 {
+		zl = zl.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return &moduleCore{Core: core, module: l.module}
+		}))
+	}
+	zl = zl.With(l.fields...)
+	l.cached.Store(&resolvedLogger{base: base, zl: zl})
+	return zl
+}
+
+// Debug log level
+func (l *Logger) Debug(format string, params ...any) {
+	zl := l.zap()
+	zl.Debug(fmt.Sprintf(format, params...))
+}
+
+// Info log level
+func (l *Logger) Info(format string, params ...any) {
+	zl := l.zap()
+	zl.Info(fmt.Sprintf(format, params...))
+}
+
+// Warn log level
+func (l *Logger) Warn(format string, params ...any) {
+	zl := l.zap()
+	zl.Warn(fmt.Sprintf(format, params...))
+}
+
+// Error log level
+func (l *Logger) Error(format string, params ...any) {
+	zl := l.zap()
+	zl.Error(fmt.Sprintf(format, params...))
+}
+
+// Fatal log level
+func (l *Logger) Fatal(format string, params ...any) {
+	zl := l.zap()
+	defer zl.Sync()
+	zl.Fatal(fmt.Sprintf(format, params...))
+}
+
+// endregion
@@ -4,7 +4,6 @@ package logger
 import (
 	"fmt"
 	"go.uber.org/zap/zapcore"
-	"strings"
 	"sync"
 	"time"
 
@@ -95,17 +94,8 @@ func gcpEncodeLevel() zapcore.LevelEncoder {
 
 // SetLevel log level DEBUG | INFO | WARN | ERROR
 func SetLevel(level string) {
-	switch strings.ToLower(level) {
-	case "debug":
-		loggerConfig.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
-	case "info":
-		loggerConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	case "warn":
-		loggerConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
-	case "warning":
-		loggerConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
-	case "error":
-		loggerConfig.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	if lvl, ok := parseLevel(level); ok {
+		loggerConfig.Level = zap.NewAtomicLevelAt(lvl)
 	}
 }
 
@@ -139,6 +129,11 @@ func customEncodeTime(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 
 // Init initialize logger
 func Init() {
+	if core, ok := sinksCore(); ok {
+		loggerSingleton = zap.New(core, sinksOptions()...)
+		return
+	}
+
 	var err error
 	if loggerSingleton, err = loggerConfig.Build(); err != nil {
 		loggerSingleton, _ = zap.NewProduction()
@@ -0,0 +1,375 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger output configuration keys (environment variables) used by ConfigureFromEnv
+const (
+	EnvLogOutput         = "LOG_OUTPUT"           // Comma separated sinks: stdout | stderr | file | syslog (default: stderr)
+	EnvLogFilePath       = "LOG_FILE_PATH"        // Rolling file path
+	EnvLogFileMaxSizeMB  = "LOG_FILE_MAX_SIZE_MB" // Rotate the file when it exceeds the size (in megabytes, default: 100)
+	EnvLogFileMaxAge     = "LOG_FILE_MAX_AGE"     // Remove rotated files older than the duration (e.g. 168h, default: keep)
+	EnvLogFileMaxBackups = "LOG_FILE_MAX_BACKUPS" // Max number of rotated files to keep (default: keep all)
+	EnvLogSyslogNetwork  = "LOG_SYSLOG_NETWORK"   // Syslog network (udp | tcp, empty for local syslog)
+	EnvLogSyslogAddress  = "LOG_SYSLOG_ADDRESS"   // Syslog address (host:port, empty for local syslog)
+	EnvLogSyslogTag      = "LOG_SYSLOG_TAG"       // Syslog tag (default: process name)
+	EnvLogAsync          = "LOG_ASYNC"            // Enable async buffered writing (true | false)
+	EnvLogBufferSize     = "LOG_BUFFER_SIZE"      // Async buffer size in bytes (default: 256KB)
+)
+
+// region Sinks --------------------------------------------------------------------------------------------------------
+
+// Sink is a log output destination
+type Sink = zapcore.WriteSyncer
+
+var (
+	sinksMu sync.Mutex
+	sinks   []Sink
+)
+
+// SetSinks replaces the logger output with the sinks (call Init to apply), use no sinks to restore the default output
+// The replaced sinks are not closed, use Close to flush and close the current sinks
+func SetSinks(list ...Sink) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks = list
+}
+
+// StdoutSink writes to the standard output
+func StdoutSink() Sink {
+	return zapcore.Lock(os.Stdout)
+}
+
+// StderrSink writes to the standard error
+func StderrSink() Sink {
+	return zapcore.Lock(os.Stderr)
+}
+
+// WriterSink writes to the custom writer
+func WriterSink(w io.Writer) Sink {
+	return zapcore.Lock(zapcore.AddSync(w))
+}
+
+// AsyncSink buffers the writes to the sink and flushes the buffer when it is full, every flush interval (default: 1s)
+// and on Flush / Close
+func AsyncSink(sink Sink, bufferSize int, flushInterval time.Duration) Sink {
+	return &asyncSink{BufferedWriteSyncer: &zapcore.BufferedWriteSyncer{WS: sink, Size: bufferSize, FlushInterval: flushInterval}}
+}
+
+// asyncSink wraps the buffered write syncer to close the underlying sink on stop
+type asyncSink struct {
+	*zapcore.BufferedWriteSyncer
+}
+
+// Sync is a no-op, the log functions sync the logger after each entry which would defeat the buffering (the buffer is
+// flushed by Flush and Close)
+func (s *asyncSink) Sync() error {
+	return nil
+}
+
+// flush writes the buffered entries to the underlying sink
+func (s *asyncSink) flush() error {
+	return s.BufferedWriteSyncer.Sync()
+}
+
+// Close flushes the buffer and closes the underlying sink
+func (s *asyncSink) Close() error {
+	err := s.Stop()
+	if c, ok := s.WS.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// build the logger core writing to the sinks
+func sinksCore() (zapcore.Core, bool) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	if len(sinks) == 0 {
+		return nil, false
+	}
+
+	var encoder zapcore.Encoder
+	if loggerConfig.Encoding == "console" {
+		encoder = zapcore.NewConsoleEncoder(loggerConfig.EncoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(loggerConfig.EncoderConfig)
+	}
+	return zapcore.NewCore(encoder, zapcore.NewMultiWriteSyncer(sinks...), loggerConfig.Level), true
+}
+
+// logger options of the sinks core according to the logger configuration
+func sinksOptions() []zap.Option {
+	options := make([]zap.Option, 0)
+	if !loggerConfig.DisableCaller {
+		options = append(options, zap.AddCaller())
+	}
+	if !loggerConfig.DisableStacktrace {
+		options = append(options, zap.AddStacktrace(zap.ErrorLevel))
+	}
+	return options
+}
+
+// Flush writes the buffered log entries to the sinks
+func Flush() error {
+	err := getLogger().Sync()
+
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	for _, s := range sinks {
+		if async, ok := s.(*asyncSink); ok {
+			err = errors.Join(err, async.flush())
+		}
+	}
+	return err
+}
+
+// Close flushes the log entries and closes the sinks, should be called on shutdown (e.g. defer logger.Close() in main)
+// The logger falls back to the default output after Close
+func Close() error {
+	err := Flush()
+
+	sinksMu.Lock()
+	for _, s := range sinks {
+		if c, ok := s.(io.Closer); ok {
+			err = errors.Join(err, c.Close())
+		}
+	}
+	sinks = nil
+	sinksMu.Unlock()
+
+	Init()
+	return err
+}
+
+// ConfigureFromEnv configures the logger sinks from the LOG_* environment variables and initializes the logger
+func ConfigureFromEnv() error {
+	list := make([]Sink, 0)
+	for _, output := range strings.Split(os.Getenv(EnvLogOutput), ",") {
+		switch strings.ToLower(strings.TrimSpace(output)) {
+		case "", "stderr":
+			list = append(list, StderrSink())
+		case "stdout":
+			list = append(list, StdoutSink())
+		case "file":
+			file, err := NewRollingFile(RollingFileOptions{
+				Path:       os.Getenv(EnvLogFilePath),
+				MaxSize:    int64(envInt(EnvLogFileMaxSizeMB, 100)) * 1024 * 1024,
+				MaxAge:     envDuration(EnvLogFileMaxAge),
+				MaxBackups: envInt(EnvLogFileMaxBackups, 0),
+			})
+			if err != nil {
+				return err
+			}
+			list = append(list, file)
+		case "syslog":
+			sink, err := SyslogSink(os.Getenv(EnvLogSyslogNetwork), os.Getenv(EnvLogSyslogAddress), os.Getenv(EnvLogSyslogTag))
+			if err != nil {
+				return err
+			}
+			list = append(list, sink)
+		default:
+			return fmt.Errorf("unsupported log output: %s", output)
+		}
+	}
+
+	if async, _ := strconv.ParseBool(os.Getenv(EnvLogAsync)); async {
+		for i, s := range list {
+			list[i] = AsyncSink(s, envInt(EnvLogBufferSize, 256*1024), time.Second)
+		}
+	}
+	SetSinks(list...)
+	Init()
+	return nil
+}
+
+// get integer environment variable or default
+func envInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// get duration environment variable or zero
+func envDuration(key string) time.Duration {
+	value, _ := time.ParseDuration(os.Getenv(key))
+	return value
+}
+
+// endregion
+
+// region Rolling file -------------------------------------------------------------------------------------------------
+
+// RollingFileOptions configures the rolling file sink
+type RollingFileOptions struct {
+	Path       string        // Log file path, rotated files are named <path>.<yyyyMMdd-HHmmss.SSS>
+	MaxSize    int64         // Rotate the file when it exceeds the size in bytes (0 for no size limit)
+	MaxAge     time.Duration // Remove rotated files older than the duration (0 to keep)
+	MaxBackups int           // Max number of rotated files to keep (0 to keep all)
+}
+
+// RollingFile is a log file sink with size based rotation and retention of the rotated files
+type RollingFile struct {
+	mu      sync.Mutex
+	options RollingFileOptions
+	file    *os.File
+	size    int64
+}
+
+// NewRollingFile opens (or creates) the log file for append
+func NewRollingFile(options RollingFileOptions) (*RollingFile, error) {
+	if len(options.Path) == 0 {
+		return nil, fmt.Errorf("missing log file path")
+	}
+	if err := os.MkdirAll(filepath.Dir(options.Path), 0755); err != nil {
+		return nil, err
+	}
+	rf := &RollingFile{options: options}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// Write appends the data to the file, rotating the file if the max size is exceeded
+func (rf *RollingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.options.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.options.MaxSize {
+		// Failed rotation keeps writing to the original file and is retried on the next write
+		if err := rf.rotate(); err != nil && rf.file == nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Sync commits the file content to the disk
+func (rf *RollingFile) Sync() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	return rf.file.Sync()
+}
+
+// Close the file
+func (rf *RollingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+// backupSuffix matches the suffix of the rotated file names: .<yyyyMMdd-HHmmss.SSS>[-<n>]
+var backupSuffix = regexp.MustCompile(`^\.\d{8}-\d{6}\.\d{3}(-\d+)?$`)
+
+// Backups returns the rotated files sorted from the oldest to the newest, other files with the same prefix are ignored
+func (rf *RollingFile) Backups() ([]string, error) {
+	list, err := filepath.Glob(rf.options.Path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]string, 0, len(list))
+	for _, f := range list {
+		if backupSuffix.MatchString(strings.TrimPrefix(f, rf.options.Path)) {
+			backups = append(backups, f)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// open the log file for append
+func (rf *RollingFile) open() error {
+	file, err := os.OpenFile(rf.options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	rf.file, rf.size = file, info.Size()
+	return nil
+}
+
+// rotate the current file and remove the old rotated files
+func (rf *RollingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	rotated := rf.options.Path + "." + time.Now().Format("20060102-150405.000")
+	for i := 1; fileExists(rotated); i++ {
+		rotated = fmt.Sprintf("%s.%s-%d", rf.options.Path, time.Now().Format("20060102-150405.000"), i)
+	}
+	if err := os.Rename(rf.options.Path, rotated); err != nil {
+		// Keep writing to the original file
+		if oe := rf.open(); oe != nil {
+			rf.file = nil
+		}
+		return err
+	}
+	if err := rf.open(); err != nil {
+		rf.file = nil
+		return err
+	}
+	rf.cleanup()
+	return nil
+}
+
+// remove rotated files exceeding the retention
+func (rf *RollingFile) cleanup() {
+	backups, err := rf.Backups()
+	if err != nil {
+		return
+	}
+	if rf.options.MaxBackups > 0 && len(backups) > rf.options.MaxBackups {
+		for _, f := range backups[:len(backups)-rf.options.MaxBackups] {
+			_ = os.Remove(f)
+		}
+		backups = backups[len(backups)-rf.options.MaxBackups:]
+	}
+	if rf.options.MaxAge > 0 {
+		for _, f := range backups {
+			if info, fe := os.Stat(f); fe == nil && time.Since(info.ModTime()) > rf.options.MaxAge {
+				_ = os.Remove(f)
+			}
+		}
+	}
+}
+
+// check if the file exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// endregion
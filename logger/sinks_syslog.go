@@ -0,0 +1,24 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"log/syslog"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// SyslogSink writes to the syslog daemon (empty network and address for the local syslog)
+// All the entries are written with INFO priority, the entry level is part of the message
+func SyslogSink(network, address, tag string) (Sink, error) {
+	if len(tag) == 0 {
+		tag = filepath.Base(os.Args[0])
+	}
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+	return zapcore.Lock(zapcore.AddSync(writer)), nil
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package logger
+
+import (
+	"fmt"
+)
+
+// SyslogSink is not supported on this platform
+func SyslogSink(network, address, tag string) (Sink, error) {
+	return nil, fmt.Errorf("syslog is not supported on this platform")
+}
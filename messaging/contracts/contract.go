@@ -0,0 +1,224 @@
+// Inter-service event contracts
+//
+// An event contract declares once the topic name, payload type and schema version of an event shared between services.
+// Both producer and consumer import the same contract, so the topic name and the payload structure can not drift apart:
+//
+//	var HeroCreated = contracts.NewContract[*Hero]("hero-created", "1.0").WithValidator(validateHero)
+//
+//	// Producer side
+//	err := HeroCreated.Publish(bus, hero)
+//
+//	// Consumer side
+//	subId, err := HeroCreated.Subscribe(bus, "hero-service", func(hero *Hero, msg messaging.IMessage) bool { ... })
+//
+
+package contracts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	. "github.com/go-yaaf/yaaf-common/messaging"
+)
+
+// PayloadValidator validates the payload of an event
+type PayloadValidator[T any] func(payload T) error
+
+// ContractCallback is the typed subscription callback of an event contract, return true for ack
+type ContractCallback[T any] func(payload T, msg IMessage) bool
+
+// IContract is the non-generic view of an event contract (used by the contracts registry)
+type IContract interface {
+	// Topic name of the event
+	Topic() string
+
+	// Version of the event schema (major.minor)
+	Version() string
+
+	// OpCode of the event message
+	OpCode() int
+
+	// Factory returns the message factory of the event
+	Factory() MessageFactory
+}
+
+// region Contract -----------------------------------------------------------------------------------------------------
+
+// Contract declares an event: topic name, payload type and schema version
+type Contract[T any] struct {
+	topic     string
+	version   string
+	opCode    int
+	validator PayloadValidator[T]
+}
+
+// NewContract creates a new event contract and adds it to the contracts registry
+// The version format is "major.minor", messages are compatible with the contract if they share the same major version
+func NewContract[T any](topic, version string) *Contract[T] {
+	c := &Contract[T]{topic: topic, version: version}
+	register(c)
+	return c
+}
+
+// WithOpCode sets the op code of the event messages
+func (c *Contract[T]) WithOpCode(opCode int) *Contract[T] {
+	c.opCode = opCode
+	return c
+}
+
+// WithValidator sets the payload validator of the event
+func (c *Contract[T]) WithValidator(validator PayloadValidator[T]) *Contract[T] {
+	c.validator = validator
+	return c
+}
+
+// Topic name of the event
+func (c *Contract[T]) Topic() string { return c.topic }
+
+// Version of the event schema
+func (c *Contract[T]) Version() string { return c.version }
+
+// OpCode of the event message
+func (c *Contract[T]) OpCode() int { return c.opCode }
+
+// Factory returns the message factory of the event
+func (c *Contract[T]) Factory() MessageFactory { return NewMessage[T] }
+
+// NewMessage creates a new event message with the given payload, the payload is validated before the message is created
+func (c *Contract[T]) NewMessage(payload T) (IMessage, error) {
+	return c.NewSessionMessage(entity.NanoID(), payload)
+}
+
+// NewSessionMessage creates a new event message related to an existing session
+func (c *Contract[T]) NewSessionMessage(sessionId string, payload T) (IMessage, error) {
+	if err := c.ValidatePayload(payload); err != nil {
+		return nil, err
+	}
+	message := &Message[T]{
+		BaseMessage: BaseMessage{
+			MsgTopic:     c.topic,
+			MsgOpCode:    c.opCode,
+			MsgVersion:   c.version,
+			MsgSessionId: sessionId,
+		},
+		MsgPayload: payload,
+	}
+	return message, nil
+}
+
+// ValidatePayload validates the payload using the contract validator (if set)
+func (c *Contract[T]) ValidatePayload(payload T) error {
+	if c.validator == nil {
+		return nil
+	}
+	if err := c.validator(payload); err != nil {
+		return fmt.Errorf("invalid %s payload: %s", c.topic, err.Error())
+	}
+	return nil
+}
+
+// Validate checks that the message conforms to the contract (topic, version and payload) and returns the typed payload
+func (c *Contract[T]) Validate(msg IMessage) (payload T, err error) {
+	if msg == nil {
+		return payload, fmt.Errorf("nil message")
+	}
+	if msg.Topic() != c.topic {
+		return payload, fmt.Errorf("topic mismatch: expected %s, got %s", c.topic, msg.Topic())
+	}
+	if !IsCompatibleVersion(c.version, msg.Version()) {
+		return payload, fmt.Errorf("%s version mismatch: expected %s, got %s", c.topic, c.version, msg.Version())
+	}
+	if m, ok := msg.(*Message[T]); ok {
+		payload = m.MsgPayload
+	} else if p, ok := msg.Payload().(T); ok {
+		payload = p
+	} else {
+		return payload, fmt.Errorf("%s payload type mismatch: %T", c.topic, msg.Payload())
+	}
+	return payload, c.ValidatePayload(payload)
+}
+
+// Publish validates and publishes the payloads to the contract topic
+func (c *Contract[T]) Publish(bus IMessageBus, payloads ...T) error {
+	messages := make([]IMessage, 0, len(payloads))
+	for _, payload := range payloads {
+		msg, err := c.NewMessage(payload)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, msg)
+	}
+	return bus.Publish(messages...)
+}
+
+// Subscribe on the contract topic with a typed callback
+// Messages which do not conform to the contract are rejected (nack) and not passed to the callback
+func (c *Contract[T]) Subscribe(bus IMessageBus, subscription string, callback ContractCallback[T]) (string, error) {
+	return bus.Subscribe(subscription, c.Factory(), func(msg IMessage) bool {
+		payload, err := c.Validate(msg)
+		if err != nil {
+			return false
+		}
+		return callback(payload, msg)
+	}, c.topic)
+}
+
+// endregion
+
+// region Contracts registry -------------------------------------------------------------------------------------------
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]IContract)
+)
+
+// register the contract by its topic name
+func register(c IContract) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[c.Topic()] = c
+}
+
+// GetContract returns the contract declared for the topic
+func GetContract(topic string) (IContract, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[topic]
+	return c, ok
+}
+
+// Contracts returns the list of all declared contracts sorted by topic name
+func Contracts() []IContract {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	list := make([]IContract, 0, len(registry))
+	for _, c := range registry {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Topic() < list[j].Topic() })
+	return list
+}
+
+// endregion
+
+// IsCompatibleVersion checks if the message version is compatible with the contract version (same major version)
+// Empty message version is considered compatible for backward compatibility with messages created without a contract
+func IsCompatibleVersion(contractVersion, messageVersion string) bool {
+	if len(contractVersion) == 0 || len(messageVersion) == 0 {
+		return true
+	}
+	return majorVersion(contractVersion) == majorVersion(messageVersion)
+}
+
+// extract the major part of a version string (e.g. "v1.2" -> "1")
+func majorVersion(version string) string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if idx := strings.Index(version, "."); idx >= 0 {
+		return version[:idx]
+	}
+	return version
+}
@@ -4,7 +4,9 @@ package messaging
 
 import (
 	"fmt"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-yaaf/yaaf-common/entity"
@@ -13,20 +15,113 @@ import (
 	"github.com/go-yaaf/yaaf-common/utils/collections"
 )
 
+// OverflowPolicy defines how a message is published to a subscriber whose buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the publisher until there is room in the buffer or until the block timeout expires (then the message is dropped)
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest message in the buffer to make room for the new message
+	OverflowDropOldest
+	// OverflowDropNew drops the new message
+	OverflowDropNew
+)
+
+const (
+	defaultSubscriberBufferSize = 1000
+	defaultOverflowBlockTimeout = time.Second
+)
+
+// InMemoryMessageBusOptions configures the subscribers buffers of the in memory message bus
+type InMemoryMessageBusOptions struct {
+	BufferSize   int            // Per subscriber buffer size (default: 1000)
+	Overflow     OverflowPolicy // Policy for publishing to a full buffer (default: OverflowBlock)
+	BlockTimeout time.Duration  // Max time to block when the policy is OverflowBlock (default: 1 second)
+	Concurrency  int            // Number of concurrent dispatchers per subscriber, messages with the same ordering key are dispatched by the same dispatcher (default: 1)
+	JournalPath  string         // Optional append-only journal file of queues and topics, replayed on startup (default: no journal)
+}
+
 // InMemoryMessageBus represents in memory implementation of IMessageBus interface
-// topics is a map ot topic -> array of channels (channel per subscriber)
+// topics is a map ot topic -> array of subscribers (channel per subscriber)
+// patterns is a map of topic pattern -> array of subscribers (see MatchTopic)
+// subscriptions is a map of subscription id -> subscriber (used to detach subscribers)
+// retained is a map of topic -> messages published while the topic had no subscribers (only when journaling is enabled)
 type InMemoryMessageBus struct {
-	mu     sync.RWMutex
-	topics map[string][]chan []byte
-	queues map[string]collections.Queue
+	mu            sync.RWMutex
+	topics        map[string][]*inMemorySubscriber
+	patterns      map[string][]*inMemorySubscriber
+	queues        map[string]collections.Queue
+	subscriptions map[string]*inMemorySubscriber
+	imu           sync.RWMutex
+	interceptors  MessageInterceptors
+	options       InMemoryMessageBusOptions
+	dropped       atomic.Int64
+	journal       *messageJournal
+	rmu           sync.Mutex
+	retained      map[string][][]byte
+	queueTimes    map[string][]time.Time
+	smu           sync.Mutex
+	topicStats    map[string]*topicCounters
+	queueStats    map[string]*queueCounters
+}
+
+// inMemorySubscriber is a subscriber channel and the topics it is registered to
+// The channel is never closed since publishers write to it without holding the bus lock, the done channel is closed
+// when the subscriber is detached
+type inMemorySubscriber struct {
+	topics  []string
+	channel chan []byte
+	done    chan struct{}
+	dropped atomic.Int64
+	pmu     sync.Mutex
+	pending []time.Time
 }
 
 // NewInMemoryMessageBus Factory method
 func NewInMemoryMessageBus() (mq IMessageBus, err error) {
-	return &InMemoryMessageBus{
-		topics: make(map[string][]chan []byte),
-		queues: make(map[string]collections.Queue),
-	}, nil
+	return NewInMemoryMessageBusWithOptions(InMemoryMessageBusOptions{})
+}
+
+// NewInMemoryMessageBusWithOptions Factory method with subscribers buffer size and overflow policy
+func NewInMemoryMessageBusWithOptions(options InMemoryMessageBusOptions) (mq IMessageBus, err error) {
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaultSubscriberBufferSize
+	}
+	if options.BlockTimeout <= 0 {
+		options.BlockTimeout = defaultOverflowBlockTimeout
+	}
+	bus := &InMemoryMessageBus{
+		topics:        make(map[string][]*inMemorySubscriber),
+		patterns:      make(map[string][]*inMemorySubscriber),
+		queues:        make(map[string]collections.Queue),
+		subscriptions: make(map[string]*inMemorySubscriber),
+		retained:      make(map[string][][]byte),
+		queueTimes:    make(map[string][]time.Time),
+		topicStats:    make(map[string]*topicCounters),
+		queueStats:    make(map[string]*queueCounters),
+		options:       options,
+	}
+
+	if len(options.JournalPath) > 0 {
+		journal, queues, retained, fe := openJournal(options.JournalPath)
+		if fe != nil {
+			return nil, fmt.Errorf("open message bus journal %s: %w", options.JournalPath, fe)
+		}
+
+		// Replayed messages are kept serialized until they are consumed
+		now := time.Now()
+		for name, list := range queues {
+			queue := collections.NewQueue()
+			for _, data := range list {
+				queue.Push(data)
+				bus.queueTimes[name] = append(bus.queueTimes[name], now)
+			}
+			bus.queues[name] = queue
+		}
+		bus.retained = retained
+		bus.journal = journal
+	}
+	return bus, nil
 }
 
 // region IMessageBus methods implementation ---------------------------------------------------------------------------
@@ -36,9 +131,20 @@ func (m *InMemoryMessageBus) Ping(retries uint, intervalInSeconds uint) error {
 	return nil
 }
 
-// Close client and free resources
+// Close client and free resources (all subscribers are detached)
 func (m *InMemoryMessageBus) Close() error {
-	logger.Debug("In memory data-cache closed")
+	// Thread safeguard
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for subscriptionId := range m.subscriptions {
+		m.unsubscribe(subscriptionId)
+	}
+	if err := m.journal.close(); err != nil {
+		return err
+	}
+	m.journal = nil
+	logger.Debug("In memory message bus closed")
 	return nil
 }
 
@@ -47,26 +153,201 @@ func (m *InMemoryMessageBus) CloneMessageBus() (IMessageBus, error) {
 	return m, nil
 }
 
+// Use adds an interceptor to the chain wrapping Publish and the subscription callbacks
+func (m *InMemoryMessageBus) Use(interceptor MessageInterceptor) {
+	if interceptor == nil {
+		return
+	}
+
+	// Thread safeguard
+	m.imu.Lock()
+	defer m.imu.Unlock()
+
+	m.interceptors = append(m.interceptors, interceptor)
+}
+
+// get the current interceptors chain
+func (m *InMemoryMessageBus) getInterceptors() MessageInterceptors {
+	m.imu.RLock()
+	defer m.imu.RUnlock()
+	return m.interceptors
+}
+
 // Publish messages to a channel (topic)
 func (m *InMemoryMessageBus) Publish(messages ...IMessage) error {
-	// Thread safeguard
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return m.getInterceptors().WrapPublish(m.publish)(messages...)
+}
 
+// publish messages to the subscribers channels
+func (m *InMemoryMessageBus) publish(messages ...IMessage) error {
 	for _, message := range messages {
 		data, err := entity.Marshal(message)
 		if err != nil {
 			return err
 		}
 
-		for _, ch := range m.topics[message.Topic()] {
-			ch <- data
-		}
+		m.publishData(message.Topic(), data)
 	}
 
 	return nil
 }
 
+// deliver serialized message to all the topic subscribers
+// when journaling is enabled, messages published to a topic without subscribers are retained for the first subscriber
+func (m *InMemoryMessageBus) publishData(topic string, data []byte) {
+	m.topicCounters(topic).published.Add(1)
+
+	// The subscribers are taken under the lock and the message is delivered without it, since the delivery may block
+	// (see OverflowBlock) and subscriber callbacks may subscribe or unsubscribe
+	m.mu.RLock()
+	subscribers := m.topicSubscribers(topic)
+	if len(subscribers) == 0 && m.journal != nil {
+		m.rmu.Lock()
+		m.retained[topic] = append(m.retained[topic], data)
+		m.rmu.Unlock()
+		if err := m.journal.append(journalRetain, topic, data); err != nil {
+			logger.Warn("message bus journal error: %s", err.Error())
+		}
+		m.mu.RUnlock()
+		return
+	}
+	m.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		if !m.deliver(subscriber, data) {
+			subscriber.dropped.Add(1)
+			m.dropped.Add(1)
+		}
+	}
+}
+
+// get a copy of the subscribers of the topic including pattern subscribers, each subscriber is included once
+// (the caller must hold the read lock)
+func (m *InMemoryMessageBus) topicSubscribers(topic string) []*inMemorySubscriber {
+	subscribers := m.topics[topic]
+	if len(m.patterns) == 0 {
+		return append([]*inMemorySubscriber(nil), subscribers...)
+	}
+
+	result := make([]*inMemorySubscriber, 0, len(subscribers))
+	unique := make(map[*inMemorySubscriber]bool)
+	result = append(result, subscribers...)
+	for _, s := range subscribers {
+		unique[s] = true
+	}
+	for pattern, list := range m.patterns {
+		if !MatchTopic(pattern, topic) {
+			continue
+		}
+		for _, s := range list {
+			if !unique[s] {
+				unique[s] = true
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// get the subscribers map of the topic (exact topics or patterns)
+func (m *InMemoryMessageBus) subscribersMap(topic string) map[string][]*inMemorySubscriber {
+	if IsTopicPattern(topic) {
+		return m.patterns
+	}
+	return m.topics
+}
+
+// deliver message to the subscriber channel according to the overflow policy, return false if the message was dropped
+func (m *InMemoryMessageBus) deliver(subscriber *inMemorySubscriber, data []byte) bool {
+	// The publish time is registered before the message is written, since the reader may read it immediately
+	subscriber.sent(time.Now())
+	if m.write(subscriber, data) {
+		return true
+	}
+	subscriber.discard()
+	return false
+}
+
+// write message to the subscriber channel according to the overflow policy, return false if the message was dropped
+// or the subscriber is detached
+func (m *InMemoryMessageBus) write(subscriber *inMemorySubscriber, data []byte) bool {
+	select {
+	case <-subscriber.done:
+		return false
+	default:
+	}
+
+	// Try first without blocking
+	select {
+	case subscriber.channel <- data:
+		return true
+	default:
+	}
+
+	switch m.options.Overflow {
+	case OverflowDropNew:
+		return false
+	case OverflowDropOldest:
+		for {
+			select {
+			case subscriber.channel <- data:
+				return true
+			default:
+			}
+			select {
+			case <-subscriber.channel:
+				subscriber.received()
+				subscriber.dropped.Add(1)
+				m.dropped.Add(1)
+			default:
+			}
+		}
+	default:
+		timer := time.NewTimer(m.options.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case subscriber.channel <- data:
+			return true
+		case <-subscriber.done:
+			return false
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// read the next message of the subscriber channel, returns false when the subscriber is detached and the messages
+// buffered before it was detached are consumed
+func (s *inMemorySubscriber) next() ([]byte, bool) {
+	select {
+	case data := <-s.channel:
+		return data, true
+	case <-s.done:
+		select {
+		case data := <-s.channel:
+			return data, true
+		default:
+			return nil, false
+		}
+	}
+}
+
+// DroppedMessages returns the total number of messages dropped due to full subscribers buffers
+func (m *InMemoryMessageBus) DroppedMessages() int64 {
+	return m.dropped.Load()
+}
+
+// SubscriberDroppedMessages returns the number of messages dropped for the given subscription
+func (m *InMemoryMessageBus) SubscriberDroppedMessages(subscriptionId string) int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if subscriber, ok := m.subscriptions[subscriptionId]; ok {
+		return subscriber.dropped.Load()
+	}
+	return 0
+}
+
 // Subscribe on topics
 func (m *InMemoryMessageBus) Subscribe(subscription string, mf MessageFactory, callback SubscriptionCallback, topics ...string) (subscriptionId string, error error) {
 
@@ -75,39 +356,254 @@ func (m *InMemoryMessageBus) Subscribe(subscription string, mf MessageFactory, c
 		return "", fmt.Errorf("callback is nil")
 	}
 
+	subscriptionId, subscriber := m.subscribe(topics...)
+	defer m.releaseRetained(subscriber)
+
+	// The reader terminates when the subscriber is detached (see Unsubscribe)
+	if m.options.Concurrency <= 1 {
+		go func() {
+			for data, ok := subscriber.next(); ok; data, ok = subscriber.next() {
+				subscriber.received()
+				message := mf()
+				if err := entity.Unmarshal(data, &message); err == nil {
+					m.topicCounters(message.Topic()).delivered.Add(1)
+					m.getInterceptors().WrapConsume(callback)(message)
+				}
+			}
+		}()
+	} else {
+		go m.dispatchOrdered(subscriber, mf, callback)
+	}
+
+	return subscriptionId, nil
+}
+
+// dispatch messages to concurrent dispatchers, messages with the same ordering key are dispatched by the same dispatcher
+// to guarantee per key FIFO, messages without ordering key are distributed in round-robin
+func (m *InMemoryMessageBus) dispatchOrdered(subscriber *inMemorySubscriber, mf MessageFactory, callback SubscriptionCallback) {
+	dispatchers := make([]chan IMessage, m.options.Concurrency)
+	for i := range dispatchers {
+		dispatchers[i] = make(chan IMessage, m.options.BufferSize)
+		go func(ch chan IMessage) {
+			for message := range ch {
+				m.topicCounters(message.Topic()).delivered.Add(1)
+				m.getInterceptors().WrapConsume(callback)(message)
+			}
+		}(dispatchers[i])
+	}
+
+	next := 0
+	for data, ok := subscriber.next(); ok; data, ok = subscriber.next() {
+		subscriber.received()
+		message := mf()
+		if err := entity.Unmarshal(data, &message); err != nil {
+			continue
+		}
+
+		index := next
+		if key := message.OrderingKey(); len(key) > 0 {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(key))
+			index = int(h.Sum32() % uint32(len(dispatchers)))
+		} else {
+			next = (next + 1) % len(dispatchers)
+		}
+		dispatchers[index] <- message
+	}
+
+	for _, ch := range dispatchers {
+		close(ch)
+	}
+}
+
+// SubscribeWithAck on topics with explicit acknowledgement
+// Each message is redelivered to the callback until it is acknowledged or until it exceeds the max deliveries, messages
+// waiting for Ack or redelivery do not block the delivery of the next messages (up to the max in-flight messages)
+func (m *InMemoryMessageBus) SubscribeWithAck(subscription string, mf MessageFactory, callback AckSubscriptionCallback, options AckOptions, topics ...string) (subscriptionId string, error error) {
+
+	// Validate callback
+	if callback == nil {
+		return "", fmt.Errorf("callback is nil")
+	}
+	if options.VisibilityTimeout <= 0 {
+		options.VisibilityTimeout = defaultVisibilityTimeout
+	}
+	if options.MaxInFlight <= 0 {
+		options.MaxInFlight = defaultMaxInFlight
+	}
+
+	subscriptionId, subscriber := m.subscribe(topics...)
+	defer m.releaseRetained(subscriber)
+
+	go m.dispatchWithAck(subscriber, mf, callback, options)
+	return subscriptionId, nil
+}
+
+// create and register subscriber channel for the topics
+func (m *InMemoryMessageBus) subscribe(topics ...string) (string, *inMemorySubscriber) {
 	// Thread safeguard
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Create and register channel
-	subscriptionId = fmt.Sprintf("%d", entity.Now())
-	cn := make(chan []byte, 1000)
+	subscriptionId := entity.NanoID()
+	subscriber := &inMemorySubscriber{
+		topics:  topics,
+		channel: make(chan []byte, m.options.BufferSize),
+		done:    make(chan struct{}),
+	}
 
 	for _, topic := range topics {
-		if _, ok := m.topics[topic]; !ok {
-			m.topics[topic] = make([]chan []byte, 0)
+		subscribers := m.subscribersMap(topic)
+		subscribers[topic] = append(subscribers[topic], subscriber)
+	}
+	m.subscriptions[subscriptionId] = subscriber
+	return subscriptionId, subscriber
+}
+
+// deliver the messages retained for the subscriber topics (published while the topics had no subscribers)
+func (m *InMemoryMessageBus) releaseRetained(subscriber *inMemorySubscriber) {
+	for _, topic := range subscriber.topics {
+		m.mu.RLock()
+		m.rmu.Lock()
+		list := m.retained[topic]
+		delete(m.retained, topic)
+		m.rmu.Unlock()
+
+		if len(list) > 0 {
+			if err := m.journal.append(journalRelease, topic, nil); err != nil {
+				logger.Warn("message bus journal error: %s", err.Error())
+			}
+		}
+		m.mu.RUnlock()
+
+		for _, data := range list {
+			if !m.deliver(subscriber, data) {
+				subscriber.dropped.Add(1)
+				m.dropped.Add(1)
+			}
 		}
-		m.topics[topic] = append(m.topics[topic], cn)
 	}
+}
+
+// ackDelivery is a delivery attempt of a serialized message
+type ackDelivery struct {
+	data    []byte
+	attempt int
+}
+
+// dispatch the subscriber messages and the redeliveries to the callback until the subscriber is detached
+// New messages are read from the subscriber channel only while the number of in-flight messages (delivered and not yet
+// acknowledged or dead lettered) is below the max, so the redelivery queue is bounded by the max in-flight messages
+func (m *InMemoryMessageBus) dispatchWithAck(subscriber *inMemorySubscriber, mf MessageFactory, callback AckSubscriptionCallback, options AckOptions) {
+	redeliveries := make(chan ackDelivery, options.MaxInFlight)
+	finished := make(chan struct{}, options.MaxInFlight)
+	inFlight := 0
+
+	for {
+		var incoming chan []byte
+		if inFlight < options.MaxInFlight {
+			incoming = subscriber.channel
+		}
+
+		select {
+		case <-subscriber.done:
+			return
+		case <-finished:
+			inFlight--
+		case d := <-redeliveries:
+			m.deliverWithAck(subscriber, d, mf, callback, options, redeliveries, finished)
+		case data := <-incoming:
+			subscriber.received()
+			inFlight++
+			m.deliverWithAck(subscriber, ackDelivery{data: data, attempt: 1}, mf, callback, options, redeliveries, finished)
+		}
+	}
+}
 
+// deliver message to the callback and wait for the Ack in the background, messages which are not acknowledged are
+// queued for redelivery until they exceed the max deliveries, then the message is reported as finished
+func (m *InMemoryMessageBus) deliverWithAck(subscriber *inMemorySubscriber, ad ackDelivery, mf MessageFactory, callback AckSubscriptionCallback, options AckOptions, redeliveries chan ackDelivery, finished chan struct{}) {
+	message := mf()
+	if err := entity.Unmarshal(ad.data, &message); err != nil {
+		finished <- struct{}{}
+		return
+	}
+	if ad.attempt == 1 {
+		m.topicCounters(message.Topic()).delivered.Add(1)
+	}
+
+	// Interceptors rejecting the message are considered as Nack
+	d := newDelivery(ad.attempt)
+	consume := func(msg IMessage) bool {
+		callback(msg, d)
+		return true
+	}
+	if !m.getInterceptors().WrapConsume(consume)(message) {
+		d.Nack()
+	}
+
+	// The finished and redeliveries channels have room for all the in-flight messages, so the sends never block
 	go func() {
-		for {
-			select {
-			case data := <-cn:
-				message := mf()
-				if err := entity.Unmarshal(data, &message); err == nil {
-					callback(message)
-				}
+		if d.wait(options.VisibilityTimeout, subscriber.done) {
+			finished <- struct{}{}
+			return
+		}
+		select {
+		case <-subscriber.done:
+			return
+		default:
+		}
+		if options.MaxDeliveries > 0 && ad.attempt >= options.MaxDeliveries {
+			if len(options.DeadLetterTopic) > 0 {
+				m.publishData(options.DeadLetterTopic, ad.data)
 			}
+			finished <- struct{}{}
+			return
 		}
-	}()
 
-	return subscriptionId, nil
+		// Wait before redelivery
+		select {
+		case <-subscriber.done:
+		case <-time.After(options.RedeliveryDelay):
+			redeliveries <- ackDelivery{data: ad.data, attempt: ad.attempt + 1}
+		}
+	}()
 }
 
-// Unsubscribe with the given subscriber id
+// Unsubscribe with the given subscriber id, the subscriber channel is removed from all topics and detached
 func (m *InMemoryMessageBus) Unsubscribe(subscriptionId string) (success bool) {
-	// Tdo nothing
+	// Thread safeguard
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.unsubscribe(subscriptionId)
+}
+
+// detach subscriber from all its topics (the caller must hold the lock)
+func (m *InMemoryMessageBus) unsubscribe(subscriptionId string) bool {
+	subscriber, ok := m.subscriptions[subscriptionId]
+	if !ok {
+		return false
+	}
+
+	for _, topic := range subscriber.topics {
+		topicsMap := m.subscribersMap(topic)
+		subscribers := topicsMap[topic]
+		for i, s := range subscribers {
+			if s == subscriber {
+				subscribers = append(subscribers[:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		if len(subscribers) == 0 {
+			delete(topicsMap, topic)
+		} else {
+			topicsMap[topic] = subscribers
+		}
+	}
+
+	delete(m.subscriptions, subscriptionId)
+	close(subscriber.done)
 	return true
 }
 
@@ -120,6 +616,15 @@ func (m *InMemoryMessageBus) Push(messages ...IMessage) error {
 
 	for _, message := range messages {
 		queueName := message.Topic()
+		if m.journal != nil {
+			data, err := entity.Marshal(message)
+			if err != nil {
+				return err
+			}
+			if err = m.journal.append(journalPush, queueName, data); err != nil {
+				return err
+			}
+		}
 		if queue, ok := m.queues[queueName]; ok {
 			queue.Push(message)
 		} else {
@@ -127,6 +632,8 @@ func (m *InMemoryMessageBus) Push(messages ...IMessage) error {
 			queue.Push(message)
 			m.queues[queueName] = queue
 		}
+		m.queueTimes[queueName] = append(m.queueTimes[queueName], time.Now())
+		m.queueCounters(queueName).pushed.Add(1)
 	}
 	return nil
 }
@@ -135,14 +642,21 @@ func (m *InMemoryMessageBus) Push(messages ...IMessage) error {
 func (m *InMemoryMessageBus) Pop(mf MessageFactory, timeout time.Duration, queue ...string) (IMessage, error) {
 
 	if timeout == 0 {
-		return m.pop(queue...)
+		return m.pop(mf, queue...)
+	}
+
+	// Register the caller as a consumer of the queues while waiting
+	for _, qName := range queue {
+		counters := m.queueCounters(qName)
+		counters.consumers.Add(1)
+		defer counters.consumers.Add(-1)
 	}
 
 	after := time.After(timeout)
 	for {
 		select {
 		case _ = <-time.Tick(time.Millisecond):
-			if message, err := m.pop(queue...); err == nil {
+			if message, err := m.pop(mf, queue...); err == nil {
 				return message, nil
 			}
 		case <-after:
@@ -166,7 +680,7 @@ func (m *InMemoryMessageBus) CreateConsumer(subscription string, mf MessageFacto
 }
 
 // try to pop message from one of the queues
-func (m *InMemoryMessageBus) pop(queue ...string) (IMessage, error) {
+func (m *InMemoryMessageBus) pop(mf MessageFactory, queue ...string) (IMessage, error) {
 
 	// Thread safeguard
 	m.mu.Lock()
@@ -174,14 +688,45 @@ func (m *InMemoryMessageBus) pop(queue ...string) (IMessage, error) {
 
 	for _, qName := range queue {
 		if q, ok := m.queues[qName]; ok {
-			if msg, exists := q.Pop(); exists {
-				return msg.(IMessage), nil
+			entry, exists := q.Peek()
+			if !exists {
+				continue
+			}
+			// Convert the entry before it is removed, a failure leaves the message in the queue
+			msg, err := m.toMessage(mf, entry)
+			if err != nil {
+				return nil, err
+			}
+			q.Pop()
+			if times := m.queueTimes[qName]; len(times) > 0 {
+				m.queueTimes[qName] = times[1:]
+			}
+			m.queueCounters(qName).popped.Add(1)
+			if err = m.journal.append(journalPop, qName, nil); err != nil {
+				logger.Warn("message bus journal error: %s", err.Error())
 			}
+			return msg, nil
 		}
 	}
 	return nil, fmt.Errorf("not found")
 }
 
+// convert queue entry to message, entries replayed from the journal are serialized
+func (m *InMemoryMessageBus) toMessage(mf MessageFactory, entry any) (IMessage, error) {
+	data, ok := entry.([]byte)
+	if !ok {
+		return entry.(IMessage), nil
+	}
+	if mf == nil {
+		return nil, fmt.Errorf("message factory is required for journaled messages")
+	}
+	message := mf()
+	if err := entity.Unmarshal(data, &message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
 // endregion
 
 // region IMessageConsumer methods implementation ----------------------------------------------------------------------
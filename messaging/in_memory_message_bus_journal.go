@@ -0,0 +1,176 @@
+// Journaling (append-only file) of the in-memory message bus for durability tests
+//
+// When a journal path is configured, queue operations (push / pop) and topic messages published while the topic has
+// no subscribers are appended to the journal file. On startup the journal is replayed to restore the queues and the
+// retained topic messages (delivered to the first subscriber of the topic), and then compacted.
+
+package messaging
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// journal operations
+const (
+	journalPush    = "push"    // Message pushed to queue
+	journalPop     = "pop"     // Message popped from queue
+	journalRetain  = "retain"  // Message published to topic without subscribers
+	journalRelease = "release" // Retained topic messages delivered to subscriber
+)
+
+// journalRecord is a single line in the journal file
+type journalRecord struct {
+	Op   string `json:"op"`             // Operation
+	Name string `json:"name"`           // Queue or topic name
+	Data []byte `json:"data,omitempty"` // Serialized message
+}
+
+// messageJournal is an append-only file of message bus operations
+type messageJournal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// openJournal replays the journal file (if exists), compacts it and opens it for append
+// Returns the restored queues and retained topic messages
+func openJournal(path string) (journal *messageJournal, queues map[string][][]byte, retained map[string][][]byte, err error) {
+	if queues, retained, err = replayJournal(path); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Compact: rewrite the journal with the current state only
+	tmpPath := path + ".tmp"
+	if err = writeJournal(tmpPath, queues, retained); err != nil {
+		return nil, nil, nil, err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return nil, nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &messageJournal{file: file}, queues, retained, nil
+}
+
+// replay the journal records to build the queues and retained messages state
+func replayJournal(path string) (queues map[string][][]byte, retained map[string][][]byte, err error) {
+	queues = make(map[string][][]byte)
+	retained = make(map[string][][]byte)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return queues, retained, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var partial error
+	for line := 1; scanner.Scan(); line++ {
+		// Only the last line may be partial, a corrupt line in the middle fails the replay (to avoid losing the
+		// following records in the compaction)
+		if partial != nil {
+			return nil, nil, partial
+		}
+		record := journalRecord{}
+		if fe := json.Unmarshal(scanner.Bytes(), &record); fe != nil {
+			partial = fmt.Errorf("journal line %d: %w", line, fe)
+			continue
+		}
+
+		switch record.Op {
+		case journalPush:
+			queues[record.Name] = append(queues[record.Name], record.Data)
+		case journalPop:
+			if len(queues[record.Name]) > 0 {
+				queues[record.Name] = queues[record.Name][1:]
+			}
+		case journalRetain:
+			retained[record.Name] = append(retained[record.Name], record.Data)
+		case journalRelease:
+			delete(retained, record.Name)
+		default:
+			return nil, nil, fmt.Errorf("journal line %d: unknown operation %s", line, record.Op)
+		}
+	}
+	return queues, retained, scanner.Err()
+}
+
+// write the state to a new journal file
+func writeJournal(path string, queues map[string][][]byte, retained map[string][][]byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	write := func(op, name string, data []byte) error {
+		line, fe := json.Marshal(journalRecord{Op: op, Name: name, Data: data})
+		if fe != nil {
+			return fe
+		}
+		_, fe = w.Write(append(line, '\n'))
+		return fe
+	}
+
+	for name, list := range queues {
+		for _, data := range list {
+			if err = write(journalPush, name, data); err != nil {
+				_ = file.Close()
+				return err
+			}
+		}
+	}
+	for name, list := range retained {
+		for _, data := range list {
+			if err = write(journalRetain, name, data); err != nil {
+				_ = file.Close()
+				return err
+			}
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// append a record to the journal
+func (j *messageJournal) append(op, name string, data []byte) error {
+	if j == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(journalRecord{Op: op, Name: name, Data: data})
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+// close the journal file
+func (j *messageJournal) close() error {
+	if j == nil {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
@@ -0,0 +1,156 @@
+// Metrics of the in-memory message bus
+//
+
+package messaging
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// topicCounters of a single topic
+type topicCounters struct {
+	published atomic.Int64
+	delivered atomic.Int64
+}
+
+// queueCounters of a single queue
+type queueCounters struct {
+	pushed    atomic.Int64
+	popped    atomic.Int64
+	consumers atomic.Int64
+}
+
+// get (or create) the topic counters
+func (m *InMemoryMessageBus) topicCounters(topic string) *topicCounters {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+
+	counters, ok := m.topicStats[topic]
+	if !ok {
+		counters = &topicCounters{}
+		m.topicStats[topic] = counters
+	}
+	return counters
+}
+
+// get (or create) the queue counters
+func (m *InMemoryMessageBus) queueCounters(queue string) *queueCounters {
+	m.smu.Lock()
+	defer m.smu.Unlock()
+
+	counters, ok := m.queueStats[queue]
+	if !ok {
+		counters = &queueCounters{}
+		m.queueStats[queue] = counters
+	}
+	return counters
+}
+
+// Stats returns a snapshot of the per topic and per queue counters
+// Topic pending messages and lag are calculated from the buffers of the topic subscribers, a subscriber registered to
+// multiple topics is counted in each of its topics
+func (m *InMemoryMessageBus) Stats() (MessageBusStats, error) {
+	// Thread safeguard
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	result := MessageBusStats{
+		Topics: make(map[string]TopicStats),
+		Queues: make(map[string]QueueStats),
+	}
+
+	m.smu.Lock()
+	topics := make(map[string]*topicCounters, len(m.topicStats))
+	for name, counters := range m.topicStats {
+		topics[name] = counters
+	}
+	queues := make(map[string]*queueCounters, len(m.queueStats))
+	for name, counters := range m.queueStats {
+		queues[name] = counters
+	}
+	m.smu.Unlock()
+
+	// Include topics with subscribers which were not published yet
+	for topic := range m.topics {
+		if _, ok := topics[topic]; !ok {
+			topics[topic] = &topicCounters{}
+		}
+	}
+
+	for topic, counters := range topics {
+		stats := TopicStats{
+			Published: counters.published.Load(),
+			Delivered: counters.delivered.Load(),
+		}
+		subscribers := m.topicSubscribers(topic)
+		stats.Consumers = len(subscribers)
+		for _, subscriber := range subscribers {
+			count, oldest := subscriber.backlog()
+			stats.Pending += int64(count)
+			if count > 0 && now.Sub(oldest) > stats.OldestMessageAge {
+				stats.OldestMessageAge = now.Sub(oldest)
+			}
+		}
+
+		m.rmu.Lock()
+		stats.Pending += int64(len(m.retained[topic]))
+		m.rmu.Unlock()
+
+		result.Topics[topic] = stats
+	}
+
+	for queue, counters := range queues {
+		stats := QueueStats{
+			Pushed:    counters.pushed.Load(),
+			Popped:    counters.popped.Load(),
+			Consumers: int(counters.consumers.Load()),
+		}
+		if times := m.queueTimes[queue]; len(times) > 0 {
+			stats.Pending = int64(len(times))
+			stats.OldestMessageAge = now.Sub(times[0])
+		}
+		result.Queues[queue] = stats
+	}
+	return result, nil
+}
+
+// region Subscriber backlog -------------------------------------------------------------------------------------------
+
+// sent registers the publish time of a message written to the subscriber buffer
+func (s *inMemorySubscriber) sent(at time.Time) {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	s.pending = append(s.pending, at)
+}
+
+// discard removes the publish time of a message which was dropped before written to the subscriber buffer
+func (s *inMemorySubscriber) discard() {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	if len(s.pending) > 0 {
+		s.pending = s.pending[:len(s.pending)-1]
+	}
+}
+
+// received removes the publish time of the oldest message read from the subscriber buffer
+func (s *inMemorySubscriber) received() {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	if len(s.pending) > 0 {
+		s.pending = s.pending[1:]
+	}
+}
+
+// backlog returns the number of messages in the subscriber buffer and the publish time of the oldest one
+func (s *inMemorySubscriber) backlog() (count int, oldest time.Time) {
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	if len(s.pending) > 0 {
+		oldest = s.pending[0]
+	}
+	return len(s.pending), oldest
+}
+
+// endregion
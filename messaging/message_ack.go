@@ -0,0 +1,85 @@
+// Message acknowledgement and redelivery
+//
+
+package messaging
+
+import (
+	"sync"
+	"time"
+)
+
+// IDelivery represents a single delivery of a message to a subscriber, the handler must Ack or Nack the delivery
+// Messages which are nacked, or not acknowledged within the visibility timeout, are redelivered
+type IDelivery interface {
+	// Ack acknowledges the message, it will not be redelivered
+	Ack()
+
+	// Nack rejects the message, it will be redelivered
+	Nack()
+
+	// Attempt returns the delivery attempt number (1 for the first delivery)
+	Attempt() int
+}
+
+// AckSubscriptionCallback Message subscription callback function with explicit acknowledgement
+type AckSubscriptionCallback func(msg IMessage, delivery IDelivery)
+
+// AckOptions configures the acknowledgement and redelivery of a subscription
+type AckOptions struct {
+	VisibilityTimeout time.Duration // Max time to wait for Ack / Nack before redelivery (default: 30 seconds)
+	RedeliveryDelay   time.Duration // Delay before redelivery (default: no delay)
+	MaxDeliveries     int           // Max number of deliveries per message (0 for unlimited)
+	DeadLetterTopic   string        // Topic to publish messages exceeding MaxDeliveries (empty to discard)
+	MaxInFlight       int           // Max number of unacknowledged messages per subscriber, including messages waiting for redelivery (default: 100)
+}
+
+const (
+	defaultVisibilityTimeout = time.Second * 30
+	defaultMaxInFlight       = 100
+)
+
+// region Delivery implementation --------------------------------------------------------------------------------------
+
+// delivery is a single delivery of a message, the first Ack / Nack wins
+type delivery struct {
+	attempt int
+	once    sync.Once
+	result  chan bool
+}
+
+// newDelivery creates a new delivery for the given attempt
+func newDelivery(attempt int) *delivery {
+	return &delivery{attempt: attempt, result: make(chan bool, 1)}
+}
+
+// Ack acknowledges the message
+func (d *delivery) Ack() {
+	d.once.Do(func() { d.result <- true })
+}
+
+// Nack rejects the message
+func (d *delivery) Nack() {
+	d.once.Do(func() { d.result <- false })
+}
+
+// Attempt returns the delivery attempt number
+func (d *delivery) Attempt() int {
+	return d.attempt
+}
+
+// wait for Ack / Nack until the visibility timeout expires or until done is closed, return true for ack
+func (d *delivery) wait(timeout time.Duration, done <-chan struct{}) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case ack := <-d.result:
+		return ack
+	case <-timer.C:
+		return false
+	case <-done:
+		return false
+	}
+}
+
+// endregion
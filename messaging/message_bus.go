@@ -24,8 +24,14 @@ type IMessageBus interface {
 	Publish(messages ...IMessage) error
 
 	// Subscribe on topics and return subscriberId
+	// Topics may include wildcards (e.g. "events.*", "device.+.status", "events.#"), see MatchTopic for the pattern rules
+	// Implementations should map the patterns to the native wildcard syntax of the underlying broker
 	Subscribe(subscription string, mf MessageFactory, callback SubscriptionCallback, topics ...string) (string, error)
 
+	// SubscribeWithAck on topics with explicit acknowledgement and return subscriberId
+	// Messages which are nacked, or not acknowledged within the visibility timeout, are redelivered
+	SubscribeWithAck(subscription string, mf MessageFactory, callback AckSubscriptionCallback, options AckOptions, topics ...string) (string, error)
+
 	// Unsubscribe with the given subscriber id
 	Unsubscribe(subscriptionId string) bool
 
@@ -40,6 +46,13 @@ type IMessageBus interface {
 
 	// CreateConsumer creates message consumer for a specific topic
 	CreateConsumer(subscription string, mf MessageFactory, topics ...string) (IMessageConsumer, error)
+
+	// Use adds an interceptor to the chain wrapping Publish and the subscription callbacks
+	// Interceptors are invoked by the order they were added (the first is the outermost)
+	Use(interceptor MessageInterceptor)
+
+	// Stats returns a snapshot of the per topic and per queue counters (published, delivered, pending, consumers and lag)
+	Stats() (MessageBusStats, error)
 }
 
 // IMessageProducer Message bus producer interface
@@ -0,0 +1,93 @@
+// Message bus metrics and lag reporting
+//
+
+package messaging
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MessageBusStats is a snapshot of the message bus counters per topic and per queue
+type MessageBusStats struct {
+	Topics map[string]TopicStats `json:"topics"` // Topic name -> topic counters
+	Queues map[string]QueueStats `json:"queues"` // Queue name -> queue counters
+}
+
+// TopicStats counters of a single topic
+type TopicStats struct {
+	Published        int64         `json:"published"`        // Number of messages published to the topic
+	Delivered        int64         `json:"delivered"`        // Number of messages delivered to the topic subscribers callbacks
+	Pending          int64         `json:"pending"`          // Number of messages waiting in the topic subscribers buffers
+	Consumers        int           `json:"consumers"`        // Number of active subscribers (including pattern subscribers)
+	OldestMessageAge time.Duration `json:"oldestMessageAge"` // Age of the oldest pending message (consumer lag)
+}
+
+// QueueStats counters of a single queue
+type QueueStats struct {
+	Pushed           int64         `json:"pushed"`           // Number of messages pushed to the queue
+	Popped           int64         `json:"popped"`           // Number of messages popped from the queue
+	Pending          int64         `json:"pending"`          // Number of messages in the queue
+	Consumers        int           `json:"consumers"`        // Number of consumers currently waiting on the queue
+	OldestMessageAge time.Duration `json:"oldestMessageAge"` // Age of the oldest message in the queue (consumer lag)
+}
+
+// region Prometheus exposition ----------------------------------------------------------------------------------------
+
+// WritePrometheusStats writes the message bus stats in the Prometheus text exposition format
+// The metrics names are prefixed with the given namespace (e.g. "yaaf_messaging")
+func WritePrometheusStats(w io.Writer, namespace string, stats MessageBusStats) error {
+	topics := make([]string, 0, len(stats.Topics))
+	for name := range stats.Topics {
+		topics = append(topics, name)
+	}
+	sort.Strings(topics)
+
+	queues := make([]string, 0, len(stats.Queues))
+	for name := range stats.Queues {
+		queues = append(queues, name)
+	}
+	sort.Strings(queues)
+
+	sb := strings.Builder{}
+	metric := func(name, kind, help, label string, names []string, value func(name string) any) {
+		sb.WriteString(fmt.Sprintf("# HELP %s_%s %s\n# TYPE %s_%s %s\n", namespace, name, help, namespace, name, kind))
+		for _, n := range names {
+			sb.WriteString(fmt.Sprintf("%s_%s{%s=%q} %v\n", namespace, name, label, n, value(n)))
+		}
+	}
+
+	metric("topic_published_total", "counter", "Number of messages published to the topic", "topic", topics, func(n string) any { return stats.Topics[n].Published })
+	metric("topic_delivered_total", "counter", "Number of messages delivered to the topic subscribers", "topic", topics, func(n string) any { return stats.Topics[n].Delivered })
+	metric("topic_pending_messages", "gauge", "Number of messages waiting in the topic subscribers buffers", "topic", topics, func(n string) any { return stats.Topics[n].Pending })
+	metric("topic_consumers", "gauge", "Number of active topic subscribers", "topic", topics, func(n string) any { return stats.Topics[n].Consumers })
+	metric("topic_oldest_message_age_seconds", "gauge", "Age of the oldest pending topic message", "topic", topics, func(n string) any { return stats.Topics[n].OldestMessageAge.Seconds() })
+
+	metric("queue_pushed_total", "counter", "Number of messages pushed to the queue", "queue", queues, func(n string) any { return stats.Queues[n].Pushed })
+	metric("queue_popped_total", "counter", "Number of messages popped from the queue", "queue", queues, func(n string) any { return stats.Queues[n].Popped })
+	metric("queue_pending_messages", "gauge", "Number of messages in the queue", "queue", queues, func(n string) any { return stats.Queues[n].Pending })
+	metric("queue_consumers", "gauge", "Number of consumers waiting on the queue", "queue", queues, func(n string) any { return stats.Queues[n].Consumers })
+	metric("queue_oldest_message_age_seconds", "gauge", "Age of the oldest message in the queue", "queue", queues, func(n string) any { return stats.Queues[n].OldestMessageAge.Seconds() })
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// PrometheusStatsHandler returns http handler exposing the message bus stats to Prometheus scraping
+func PrometheusStatsHandler(bus IMessageBus, namespace string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats, err := bus.Stats()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WritePrometheusStats(w, namespace, stats)
+	})
+}
+
+// endregion
@@ -0,0 +1,73 @@
+// Message interceptors (middleware) for cross-cutting concerns (logging, metrics, tracing, validation)
+//
+
+package messaging
+
+// PublishFunc is the signature of the publish operation wrapped by interceptors
+type PublishFunc func(messages ...IMessage) error
+
+// MessageInterceptor wraps the publish operation and the subscription callbacks of a message bus
+// Each method receives the next handler in the chain and returns a new handler, the interceptor may modify the message,
+// reject it (return an error / false) or call the next handler
+type MessageInterceptor interface {
+
+	// InterceptPublish wraps the publish operation
+	InterceptPublish(next PublishFunc) PublishFunc
+
+	// InterceptConsume wraps the subscription callback
+	InterceptConsume(next SubscriptionCallback) SubscriptionCallback
+}
+
+// region Interceptor functions adapter --------------------------------------------------------------------------------
+
+// interceptorFuncs is an adapter to use ordinary functions as MessageInterceptor
+type interceptorFuncs struct {
+	publish func(next PublishFunc) PublishFunc
+	consume func(next SubscriptionCallback) SubscriptionCallback
+}
+
+// NewMessageInterceptor creates an interceptor from functions, nil function means pass-through
+func NewMessageInterceptor(publish func(next PublishFunc) PublishFunc, consume func(next SubscriptionCallback) SubscriptionCallback) MessageInterceptor {
+	return &interceptorFuncs{publish: publish, consume: consume}
+}
+
+// InterceptPublish wraps the publish operation
+func (i *interceptorFuncs) InterceptPublish(next PublishFunc) PublishFunc {
+	if i.publish == nil {
+		return next
+	}
+	return i.publish(next)
+}
+
+// InterceptConsume wraps the subscription callback
+func (i *interceptorFuncs) InterceptConsume(next SubscriptionCallback) SubscriptionCallback {
+	if i.consume == nil {
+		return next
+	}
+	return i.consume(next)
+}
+
+// endregion
+
+// region Interceptors chain -------------------------------------------------------------------------------------------
+
+// MessageInterceptors is a chain of interceptors, the first interceptor in the list is the outermost one
+type MessageInterceptors []MessageInterceptor
+
+// WrapPublish wraps the publish function with all the interceptors in the chain
+func (c MessageInterceptors) WrapPublish(publish PublishFunc) PublishFunc {
+	for i := len(c) - 1; i >= 0; i-- {
+		publish = c[i].InterceptPublish(publish)
+	}
+	return publish
+}
+
+// WrapConsume wraps the subscription callback with all the interceptors in the chain
+func (c MessageInterceptors) WrapConsume(callback SubscriptionCallback) SubscriptionCallback {
+	for i := len(c) - 1; i >= 0; i-- {
+		callback = c[i].InterceptConsume(callback)
+	}
+	return callback
+}
+
+// endregion
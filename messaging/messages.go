@@ -24,25 +24,57 @@ type IMessage interface {
 	// Version identifies a message version
 	Version() string
 
+	// OrderingKey messages with the same ordering key are delivered in the order they were published - optional field
+	OrderingKey() string
+
+	// Headers message metadata (e.g. trace context, tenant id, schema version) propagated with the message - optional field
+	Headers() map[string]string
+
 	// Payload is the message body
 	Payload() any
 }
 
 // BaseMessage base implementation of IMessage interface
 type BaseMessage struct {
-	MsgTopic     string `json:"topic"`     // Message topic (channel)
-	MsgOpCode    int    `json:"opCode"`    // Message op code
-	MsgVersion   string `json:"version"`   // Message op code
-	MsgAddressee string `json:"addressee"` // Message final addressee
-	MsgSessionId string `json:"sessionId"` // Session id shared across all messages related to the same session
+	MsgTopic       string            `json:"topic"`                 // Message topic (channel)
+	MsgOpCode      int               `json:"opCode"`                // Message op code
+	MsgVersion     string            `json:"version"`               // Message op code
+	MsgAddressee   string            `json:"addressee"`             // Message final addressee
+	MsgSessionId   string            `json:"sessionId"`             // Session id shared across all messages related to the same session
+	MsgOrderingKey string            `json:"orderingKey,omitempty"` // Messages with the same ordering key are delivered in order
+	MsgHeaders     map[string]string `json:"headers,omitempty"`     // Message metadata
 }
 
-func (m *BaseMessage) Topic() string     { return m.MsgTopic }
-func (m *BaseMessage) OpCode() int       { return m.MsgOpCode }
-func (m *BaseMessage) Version() string   { return m.MsgVersion }
-func (m *BaseMessage) Addressee() string { return m.MsgAddressee }
-func (m *BaseMessage) SessionId() string { return m.MsgSessionId }
-func (m *BaseMessage) Payload() any      { return nil }
+func (m *BaseMessage) Topic() string       { return m.MsgTopic }
+func (m *BaseMessage) OpCode() int         { return m.MsgOpCode }
+func (m *BaseMessage) Version() string     { return m.MsgVersion }
+func (m *BaseMessage) Addressee() string   { return m.MsgAddressee }
+func (m *BaseMessage) SessionId() string   { return m.MsgSessionId }
+func (m *BaseMessage) OrderingKey() string { return m.MsgOrderingKey }
+func (m *BaseMessage) Payload() any        { return nil }
+
+// Headers returns the message metadata (nil if no headers were set)
+func (m *BaseMessage) Headers() map[string]string {
+	return m.MsgHeaders
+}
+
+// Header returns the value of a single header, empty string if not exists
+func (m *BaseMessage) Header(key string) string {
+	return m.MsgHeaders[key]
+}
+
+// SetHeader sets the value of a single header
+func (m *BaseMessage) SetHeader(key, value string) {
+	if m.MsgHeaders == nil {
+		m.MsgHeaders = make(map[string]string)
+	}
+	m.MsgHeaders[key] = value
+}
+
+// SetHeaders replaces the message metadata
+func (m *BaseMessage) SetHeaders(headers map[string]string) {
+	m.MsgHeaders = headers
+}
 
 // MessageFactory is a factory method of any message
 type MessageFactory func() IMessage
@@ -74,6 +106,13 @@ func GetMessage[T any](topic string, payload T) IMessage {
 	return message
 }
 
+// GetOrderedMessage creates a message with ordering key, messages with the same key are delivered in order
+func GetOrderedMessage[T any](topic, orderingKey string, payload T) IMessage {
+	message := GetMessage[T](topic, payload).(*Message[T])
+	message.MsgOrderingKey = orderingKey
+	return message
+}
+
 // EntityMessage general entity message implementation of IMessage interface
 type EntityMessage struct {
 	BaseMessage
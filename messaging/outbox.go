@@ -0,0 +1,254 @@
+// Transactional outbox pattern linking IDatabase and IMessageBus
+//
+// Messages are stored in an outbox table in the same transaction as the entity mutations, a relay reads the pending
+// messages and publishes them to the message bus with at-least-once delivery. Each message carries a dedup key header
+// (the outbox message id) so consumers can detect duplicates.
+
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	. "github.com/go-yaaf/yaaf-common/database"
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// DedupKeyHeader is the message header holding the deduplication key of messages published by the outbox relay
+const DedupKeyHeader = "dedup-key"
+
+const (
+	defaultOutboxBatchSize   = 100
+	defaultOutboxMaxAttempts = 10
+)
+
+// region Outbox message entity ----------------------------------------------------------------------------------------
+
+// OutboxStatus is the publishing status of an outbox message
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"   // The message is waiting to be published
+	OutboxStatusPublished OutboxStatus = "published" // The message was published
+	OutboxStatusFailed    OutboxStatus = "failed"    // The message exceeded the max publish attempts
+)
+
+// OutboxMessage is a message stored in the outbox table
+type OutboxMessage struct {
+	BaseEntity
+	Topic       string          `json:"topic"`       // Message topic
+	Message     json.RawMessage `json:"message"`     // Serialized message
+	Status      OutboxStatus    `json:"status"`      // Publishing status
+	Attempts    int             `json:"attempts"`    // Number of publish attempts
+	LastError   string          `json:"lastError"`   // Last publish error
+	PublishedOn Timestamp       `json:"publishedOn"` // Publish time
+}
+
+func (e *OutboxMessage) TABLE() string { return "outbox" }
+func (e *OutboxMessage) NAME() string  { return fmt.Sprintf("%s %s", e.Topic, e.Id) }
+
+// NewOutboxMessage is a factory method
+func NewOutboxMessage() Entity {
+	return &OutboxMessage{}
+}
+
+// endregion
+
+// region Transactional outbox -----------------------------------------------------------------------------------------
+
+// TransactionalOutbox stores messages with the entity mutations and relays them to the message bus
+type TransactionalOutbox struct {
+	db          IDatabase
+	bus         IMessageBus
+	batchSize   int
+	maxAttempts int
+}
+
+// NewTransactionalOutbox creates a new outbox using the database outbox table and the message bus
+func NewTransactionalOutbox(db IDatabase, bus IMessageBus) *TransactionalOutbox {
+	return &TransactionalOutbox{db: db, bus: bus, batchSize: defaultOutboxBatchSize, maxAttempts: defaultOutboxMaxAttempts}
+}
+
+// WithBatchSize sets the max number of messages published by a single relay iteration (default: 100)
+func (o *TransactionalOutbox) WithBatchSize(size int) *TransactionalOutbox {
+	o.batchSize = size
+	return o
+}
+
+// WithMaxAttempts sets the max number of publish attempts before the message is marked as failed (default: 10)
+func (o *TransactionalOutbox) WithMaxAttempts(attempts int) *TransactionalOutbox {
+	o.maxAttempts = attempts
+	return o
+}
+
+// Execute runs the mutation and stores the messages in the outbox in the same transaction
+// If the database does not support transactions (see ITransactional), the mutation and the messages are stored sequentially
+// and the messages are stored only if the mutation succeeded
+func (o *TransactionalOutbox) Execute(mutation func(tx IDatabase) error, messages ...IMessage) error {
+	run := func(tx IDatabase) error {
+		if mutation != nil {
+			if err := mutation(tx); err != nil {
+				return err
+			}
+		}
+		return o.Store(tx, messages...)
+	}
+
+	if transactional, ok := o.db.(ITransactional); ok {
+		return transactional.WithTransaction(run)
+	}
+	return run(o.db)
+}
+
+// Store adds the messages to the outbox table using the given database (or transaction)
+// The dedup key header is set on the stored message, the given messages are not modified
+func (o *TransactionalOutbox) Store(tx IDatabase, messages ...IMessage) error {
+	for _, message := range messages {
+		id := NanoID()
+		data, err := Marshal(message)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := message.(interface{ SetHeader(key, value string) }); ok {
+			envelope, fe := newOutboxEnvelope(message.Topic(), data)
+			if fe != nil {
+				return fe
+			}
+			envelope.SetHeader(DedupKeyHeader, id)
+			if data, err = envelope.MarshalJSON(); err != nil {
+				return err
+			}
+		}
+
+		entity := &OutboxMessage{
+			BaseEntity: BaseEntity{Id: id, CreatedOn: Now(), UpdatedOn: Now()},
+			Topic:      message.Topic(),
+			Message:    data,
+			Status:     OutboxStatusPending,
+		}
+		if _, err = tx.Insert(entity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Relay publishes a single batch of pending messages by their creation order and returns the number of published messages
+// A message is marked as published only after it was published, so it may be published more than once (at-least-once)
+func (o *TransactionalOutbox) Relay() (published int, err error) {
+	list, _, err := o.db.Query(NewOutboxMessage).
+		Filter(F("status").Eq(OutboxStatusPending)).
+		Sort("createdOn").
+		Limit(o.batchSize).
+		Find()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, ent := range list {
+		msg := ent.(*OutboxMessage)
+		fields := map[string]any{"updatedOn": Now()}
+
+		if pe := o.publish(msg); pe != nil {
+			fields["attempts"] = msg.Attempts + 1
+			fields["lastError"] = pe.Error()
+			if msg.Attempts+1 >= o.maxAttempts {
+				fields["status"] = OutboxStatusFailed
+			}
+		} else {
+			fields["status"] = OutboxStatusPublished
+			fields["publishedOn"] = Now()
+			published += 1
+		}
+
+		if ue := o.db.SetFields(NewOutboxMessage, msg.Id, fields); ue != nil {
+			return published, ue
+		}
+	}
+	return published, nil
+}
+
+// Start runs the relay in the background every interval until the context is canceled
+func (o *TransactionalOutbox) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := o.Relay(); err != nil {
+					logger.Warn("outbox relay failed: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// publish the stored message to the message bus as is (the message type is unknown to the relay)
+func (o *TransactionalOutbox) publish(msg *OutboxMessage) error {
+	envelope, err := newOutboxEnvelope(msg.Topic, msg.Message)
+	if err != nil {
+		return err
+	}
+	return o.bus.Publish(envelope)
+}
+
+// endregion
+
+// region Outbox envelope ----------------------------------------------------------------------------------------------
+
+// outboxEnvelope is a serialized message published with its original content, only the headers may be changed (e.g.
+// the dedup key or the trace context set by interceptors)
+type outboxEnvelope struct {
+	BaseMessage
+	topic   string
+	data    []byte
+	fields  map[string]json.RawMessage
+	changed bool
+}
+
+// create envelope of the serialized message
+func newOutboxEnvelope(topic string, data []byte) (*outboxEnvelope, error) {
+	envelope := &outboxEnvelope{topic: topic, data: data}
+	if err := json.Unmarshal(data, &envelope.fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &envelope.BaseMessage); err != nil {
+		return nil, err
+	}
+	return envelope, nil
+}
+
+// Topic of the stored message
+func (e *outboxEnvelope) Topic() string { return e.topic }
+
+// Payload returns the serialized payload of the message
+func (e *outboxEnvelope) Payload() any { return e.fields["payload"] }
+
+// SetHeader sets the value of a single header
+func (e *outboxEnvelope) SetHeader(key, value string) {
+	e.BaseMessage.SetHeader(key, value)
+	e.changed = true
+}
+
+// MarshalJSON returns the original message with the updated headers
+func (e *outboxEnvelope) MarshalJSON() ([]byte, error) {
+	if !e.changed {
+		return e.data, nil
+	}
+	headers, err := json.Marshal(e.MsgHeaders)
+	if err != nil {
+		return nil, err
+	}
+	e.fields["headers"] = headers
+	return json.Marshal(e.fields)
+}
+
+// endregion
@@ -0,0 +1,235 @@
+// Message schema registry and versioned message payloads
+//
+// A message type registers its current schema version and the migrations from previous versions. Subscribing through
+// this package upgrades messages published with an older version before the callback is invoked, so producers and
+// consumers can be deployed independently during rolling deploys:
+//
+//	schema.Register("hero-created", "3").
+//		AddMigration("1", "2", renameField("hero_name", "name")).
+//		AddMigration("2", "3", addField("rank", 0))
+//
+//	// Producer side (message version is set to the current schema version)
+//	err := bus.Publish(schema.NewVersionedMessage("hero-created", hero))
+//
+//	// Consumer side (messages of version 1 and 2 are upgraded to version 3)
+//	subId, err := schema.Subscribe(bus, "hero-service", messaging.NewMessage[*Hero], callback, "hero-created")
+//
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	. "github.com/go-yaaf/yaaf-common/messaging"
+)
+
+// Migration upgrades the payload of a message from one schema version to the next one
+type Migration func(payload entity.Json) (entity.Json, error)
+
+// region Schema -------------------------------------------------------------------------------------------------------
+
+// Schema is the current version of a message type (by topic) and the migrations from its previous versions
+type Schema struct {
+	mu         sync.RWMutex
+	topic      string
+	version    string
+	migrations map[string]migrationStep
+}
+
+// migrationStep is a single migration to the target version
+type migrationStep struct {
+	to        string
+	migration Migration
+}
+
+// Register the current schema version of the messages of the topic and adds it to the schema registry
+// Registering the same topic again replaces the previous schema
+func Register(topic, version string) *Schema {
+	s := &Schema{
+		topic:      topic,
+		version:    version,
+		migrations: make(map[string]migrationStep),
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[topic] = s
+	return s
+}
+
+// AddMigration adds a migration of the payload from version to the next version
+func (s *Schema) AddMigration(from, to string, migration Migration) *Schema {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.migrations[from] = migrationStep{to: to, migration: migration}
+	return s
+}
+
+// Topic of the messages
+func (s *Schema) Topic() string { return s.topic }
+
+// Version is the current schema version
+func (s *Schema) Version() string { return s.version }
+
+// Upgrade the payload from the given version to the current version by applying the chain of migrations
+// Empty version is considered as the current version (messages created without a schema)
+func (s *Schema) Upgrade(version string, payload entity.Json) (entity.Json, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(version) == 0 {
+		return payload, nil
+	}
+
+	// The number of steps is bounded by the number of migrations to detect cycles
+	for steps := 0; version != s.version; steps++ {
+		step, ok := s.migrations[version]
+		if !ok || steps >= len(s.migrations) {
+			return nil, fmt.Errorf("%s: no migration path from version %s to version %s", s.topic, version, s.version)
+		}
+		upgraded, err := step.migration(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%s: migration from version %s to version %s failed: %s", s.topic, version, step.to, err.Error())
+		}
+		payload, version = upgraded, step.to
+	}
+	return payload, nil
+}
+
+// UpgradeMessage upgrades the serialized message (the payload and the version fields) to the current version
+func (s *Schema) UpgradeMessage(data []byte) ([]byte, error) {
+	envelope := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+
+	version := ""
+	if raw, ok := envelope["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, err
+		}
+	}
+	if len(version) == 0 || version == s.version {
+		return data, nil
+	}
+
+	payload := entity.Json{}
+	if raw, ok := envelope["payload"]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil, fmt.Errorf("%s: payload is not an object: %s", s.topic, err.Error())
+		}
+	}
+
+	upgraded, err := s.Upgrade(version, payload)
+	if err != nil {
+		return nil, err
+	}
+	if envelope["payload"], err = json.Marshal(upgraded); err != nil {
+		return nil, err
+	}
+	if envelope["version"], err = json.Marshal(s.version); err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
+
+// endregion
+
+// region Schema registry ----------------------------------------------------------------------------------------------
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Schema)
+)
+
+// GetSchema returns the schema registered for the topic
+func GetSchema(topic string) (*Schema, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	s, ok := registry[topic]
+	return s, ok
+}
+
+// Schemas returns the list of all registered schemas sorted by topic name
+func Schemas() []*Schema {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	list := make([]*Schema, 0, len(registry))
+	for _, s := range registry {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].topic < list[j].topic })
+	return list
+}
+
+// endregion
+
+// region Versioned messages -------------------------------------------------------------------------------------------
+
+// NewVersionedMessage creates a message with the current schema version of the topic
+func NewVersionedMessage[T any](topic string, payload T) IMessage {
+	message := GetMessage[T](topic, payload).(*Message[T])
+	if s, ok := GetSchema(topic); ok {
+		message.MsgVersion = s.version
+	}
+	return message
+}
+
+// upgradingMessage wraps a message and upgrades its serialized form to the current schema version before unmarshalling
+type upgradingMessage struct {
+	IMessage
+	factory MessageFactory
+}
+
+// UnmarshalJSON upgrades the message according to its topic schema and unmarshal it to the wrapped message
+func (m *upgradingMessage) UnmarshalJSON(data []byte) error {
+	header := BaseMessage{}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+	if s, ok := GetSchema(header.MsgTopic); ok {
+		upgraded, err := s.UpgradeMessage(data)
+		if err != nil {
+			return err
+		}
+		data = upgraded
+	}
+
+	message := m.factory()
+	if err := entity.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	m.IMessage = message
+	return nil
+}
+
+// Factory wraps the message factory, messages created by the factory are upgraded to the current schema version
+// when unmarshalled. Use Unwrap to get the message created by the original factory
+func Factory(mf MessageFactory) MessageFactory {
+	return func() IMessage {
+		return &upgradingMessage{factory: mf}
+	}
+}
+
+// Unwrap returns the message created by the original factory of a message created by Factory
+func Unwrap(msg IMessage) IMessage {
+	if m, ok := msg.(*upgradingMessage); ok {
+		return m.IMessage
+	}
+	return msg
+}
+
+// Subscribe on topics, messages of previous schema versions are upgraded to the current version before the callback
+// is invoked. Messages which can not be upgraded are not passed to the callback
+func Subscribe(bus IMessageBus, subscription string, mf MessageFactory, callback SubscriptionCallback, topics ...string) (string, error) {
+	return bus.Subscribe(subscription, Factory(mf), func(msg IMessage) bool {
+		return callback(Unwrap(msg))
+	}, topics...)
+}
+
+// endregion
@@ -0,0 +1,317 @@
+// In-memory implementation of a stream processor (IStreamProcessor interface)
+
+package streaming
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/logger"
+	. "github.com/go-yaaf/yaaf-common/messaging"
+)
+
+const (
+	defaultWindowSize = time.Second
+	defaultBackoff    = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// InMemoryStreamProcessor represents in memory implementation of IStreamProcessor interface
+// streams is a map of stream name -> records (the record offset is its index)
+// positions is a map of stream name -> consumer group -> next offset to read
+// committed is a map of stream name -> consumer group -> last committed offset
+type InMemoryStreamProcessor struct {
+	mu        sync.Mutex
+	streams   map[string][]inMemoryRecord
+	positions map[string]map[string]int64
+	committed map[string]map[string]int64
+	consumers map[string]chan struct{}
+	appended  chan struct{}
+}
+
+// inMemoryRecord is a serialized stream record
+type inMemoryRecord struct {
+	timestamp time.Time
+	data      []byte
+}
+
+// NewInMemoryStreamProcessor Factory method
+func NewInMemoryStreamProcessor() (IStreamProcessor, error) {
+	return &InMemoryStreamProcessor{
+		streams:   make(map[string][]inMemoryRecord),
+		positions: make(map[string]map[string]int64),
+		committed: make(map[string]map[string]int64),
+		consumers: make(map[string]chan struct{}),
+		appended:  make(chan struct{}),
+	}, nil
+}
+
+// Close stops all the consumers and free resources
+func (s *InMemoryStreamProcessor) Close() error {
+	// Thread safeguard
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, done := range s.consumers {
+		close(done)
+		delete(s.consumers, id)
+	}
+	logger.Debug("In memory stream processor closed")
+	return nil
+}
+
+// Append messages to the stream (the message topic is the stream name) and return the offset of the last record
+func (s *InMemoryStreamProcessor) Append(messages ...IMessage) (offset int64, err error) {
+	// Thread safeguard
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset = -1
+	for _, message := range messages {
+		data, fe := entity.Marshal(message)
+		if fe != nil {
+			return offset, fe
+		}
+		stream := message.Topic()
+		s.streams[stream] = append(s.streams[stream], inMemoryRecord{timestamp: time.Now(), data: data})
+		offset = int64(len(s.streams[stream]) - 1)
+	}
+
+	// Wake up the waiting readers
+	close(s.appended)
+	s.appended = make(chan struct{})
+	return offset, nil
+}
+
+// Read up to max records from the consumer group position, blocks until at least one record exists or until timeout expires
+func (s *InMemoryStreamProcessor) Read(stream, group string, mf MessageFactory, max int, timeout time.Duration) ([]StreamRecord, error) {
+	after := time.After(timeout)
+	for {
+		s.mu.Lock()
+		records, err := s.read(stream, group, mf, max)
+		appended := s.appended
+		s.mu.Unlock()
+
+		if err != nil || len(records) > 0 {
+			return records, err
+		}
+
+		select {
+		case <-appended:
+		case <-after:
+			return records, nil
+		}
+	}
+}
+
+// read records from the consumer group position and advance the position (the caller must hold the lock)
+// A record which fails to unmarshal is skipped (the position is advanced past it) so it does not block the group
+func (s *InMemoryStreamProcessor) read(stream, group string, mf MessageFactory, max int) ([]StreamRecord, error) {
+	records := s.streams[stream]
+	from := s.position(stream, group)
+	to := int64(len(records))
+	if max > 0 && from+int64(max) < to {
+		to = from + int64(max)
+	}
+
+	result := make([]StreamRecord, 0, to-from)
+	for offset := from; offset < to; offset++ {
+		message := mf()
+		if err := entity.Unmarshal(records[offset].data, &message); err != nil {
+			s.setPosition(stream, group, offset+1)
+			return result, fmt.Errorf("stream %s offset %d skipped: %s", stream, offset, err.Error())
+		}
+		result = append(result, StreamRecord{
+			Stream:    stream,
+			Offset:    offset,
+			Timestamp: records[offset].timestamp,
+			Message:   message,
+		})
+	}
+	s.setPosition(stream, group, to)
+	return result, nil
+}
+
+// get the consumer group position, a new group starts after its last committed offset (the caller must hold the lock)
+func (s *InMemoryStreamProcessor) position(stream, group string) int64 {
+	if offset, ok := s.positions[stream][group]; ok {
+		return offset
+	}
+	if offset, ok := s.committed[stream][group]; ok {
+		return offset + 1
+	}
+	return 0
+}
+
+// set the consumer group position (the caller must hold the lock)
+func (s *InMemoryStreamProcessor) setPosition(stream, group string, offset int64) {
+	if _, ok := s.positions[stream]; !ok {
+		s.positions[stream] = make(map[string]int64)
+	}
+	s.positions[stream][group] = offset
+}
+
+// Commit the consumer group offset, the next record to consume is the one following the committed offset
+func (s *InMemoryStreamProcessor) Commit(stream, group string, offset int64) error {
+	// Thread safeguard
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if offset < 0 || offset >= int64(len(s.streams[stream])) {
+		return fmt.Errorf("stream %s: offset %d out of range", stream, offset)
+	}
+	if _, ok := s.committed[stream]; !ok {
+		s.committed[stream] = make(map[string]int64)
+	}
+	s.committed[stream][group] = offset
+	return nil
+}
+
+// Committed returns the last committed offset of the consumer group (-1 if nothing was committed)
+func (s *InMemoryStreamProcessor) Committed(stream, group string) (int64, error) {
+	// Thread safeguard
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if offset, ok := s.committed[stream][group]; ok {
+		return offset, nil
+	}
+	return -1, nil
+}
+
+// SeekToTimestamp moves the consumer group position to the first record appended at or after the timestamp
+func (s *InMemoryStreamProcessor) SeekToTimestamp(stream, group string, timestamp time.Time) (int64, error) {
+	// Thread safeguard
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := s.streams[stream]
+	offset := int64(sort.Search(len(records), func(i int) bool {
+		return !records[i].timestamp.Before(timestamp)
+	}))
+	s.setPosition(stream, group, offset)
+	return offset, nil
+}
+
+// ConsumeWindow consumes the stream in tumbling windows and return the consumer id
+// A window is closed when the window size elapses or when it reaches the max records, empty windows are skipped
+func (s *InMemoryStreamProcessor) ConsumeWindow(stream, group string, mf MessageFactory, options WindowOptions, callback WindowCallback) (string, error) {
+	if callback == nil {
+		return "", fmt.Errorf("callback is nil")
+	}
+	if options.Size <= 0 {
+		options.Size = defaultWindowSize
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaultBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultMaxBackoff
+	}
+
+	// Thread safeguard
+	s.mu.Lock()
+	consumerId := entity.NanoID()
+	done := make(chan struct{})
+	s.consumers[consumerId] = done
+	s.mu.Unlock()
+
+	go s.consumeWindows(stream, group, mf, options, callback, done)
+	return consumerId, nil
+}
+
+// consume the stream windows until the consumer is stopped
+// Read errors and failed windows are retried after a backoff which is doubled on each consecutive failure
+func (s *InMemoryStreamProcessor) consumeWindows(stream, group string, mf MessageFactory, options WindowOptions, callback WindowCallback, done chan struct{}) {
+	readBackoff := options.Backoff
+	windowBackoff := options.Backoff
+	for {
+		deadline := time.Now().Add(options.Size)
+		window := make([]StreamRecord, 0)
+		for options.MaxRecords == 0 || len(window) < options.MaxRecords {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				break
+			}
+			max := 0
+			if options.MaxRecords > 0 {
+				max = options.MaxRecords - len(window)
+			}
+			records, err := s.Read(stream, group, mf, max, remaining)
+			window = append(window, records...)
+			if err != nil {
+				logger.Warn("stream %s window read error: %s", stream, err.Error())
+				if !sleep(done, readBackoff) {
+					return
+				}
+				readBackoff = nextBackoff(readBackoff, options.MaxBackoff)
+			} else {
+				readBackoff = options.Backoff
+			}
+
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+
+		if len(window) == 0 {
+			continue
+		}
+
+		last := window[len(window)-1].Offset
+		if err := callback(window); err != nil {
+			// Rewind to the window start for redelivery
+			s.mu.Lock()
+			s.setPosition(stream, group, window[0].Offset)
+			s.mu.Unlock()
+
+			logger.Warn("stream %s window [%d..%d] failed, redelivery in %s: %s", stream, window[0].Offset, last, windowBackoff, err.Error())
+			if !sleep(done, windowBackoff) {
+				return
+			}
+			windowBackoff = nextBackoff(windowBackoff, options.MaxBackoff)
+			continue
+		}
+		windowBackoff = options.Backoff
+		if err := s.Commit(stream, group, last); err != nil {
+			logger.Warn("stream %s window commit error: %s", stream, err.Error())
+		}
+	}
+}
+
+// sleep for the duration, return false if the consumer was stopped
+func sleep(done chan struct{}, d time.Duration) bool {
+	select {
+	case <-done:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// double the backoff up to the max backoff
+func nextBackoff(backoff, max time.Duration) time.Duration {
+	if backoff *= 2; backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// StopConsumer stops the windowed consumer with the given id
+func (s *InMemoryStreamProcessor) StopConsumer(consumerId string) bool {
+	// Thread safeguard
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	done, ok := s.consumers[consumerId]
+	if ok {
+		close(done)
+		delete(s.consumers, consumerId)
+	}
+	return ok
+}
@@ -0,0 +1,65 @@
+// Stream processing interface
+//
+// A stream is an append-only, ordered log of messages. Each record in the stream is identified by an offset, consumer
+// groups track their position in the stream by committing offsets, and may replay the stream from a point in time.
+// The interface is implemented by the in-memory stream processor and by the streaming adapters (e.g. Kafka, Pub/Sub)
+
+package streaming
+
+import (
+	"io"
+	"time"
+
+	. "github.com/go-yaaf/yaaf-common/messaging"
+)
+
+// StreamRecord is a single message in a stream
+type StreamRecord struct {
+	Stream    string    `json:"stream"`    // Stream name
+	Offset    int64     `json:"offset"`    // Record offset in the stream (starts at 0)
+	Timestamp time.Time `json:"timestamp"` // Time the record was appended to the stream
+	Message   IMessage  `json:"message"`   // Record message
+}
+
+// WindowOptions defines the tumbling window of the windowed consumer
+type WindowOptions struct {
+	Size       time.Duration // Max duration of the window (default: 1 second)
+	MaxRecords int           // Max number of records in the window, the window is closed when reached (default: unlimited)
+	Backoff    time.Duration // Delay before redelivery of a failed window or after a read error, doubled on each consecutive failure (default: 100ms)
+	MaxBackoff time.Duration // Max delay before redelivery or read retry (default: 5 seconds)
+}
+
+// WindowCallback processes the records of a window, the window offset is committed if the callback returns nil,
+// otherwise the window records are redelivered in the next window
+type WindowCallback func(records []StreamRecord) error
+
+// IStreamProcessor Stream processing interface
+type IStreamProcessor interface {
+
+	// Closer includes method Close()
+	io.Closer
+
+	// Append messages to the stream (the message topic is the stream name) and return the offset of the last record
+	Append(messages ...IMessage) (offset int64, err error)
+
+	// Read up to max records from the consumer group position, blocks until at least one record exists or until timeout expires
+	// The group position is advanced but not committed (see Commit), a record which can't be unmarshalled is skipped and
+	// reported in the error together with the records read before it
+	Read(stream, group string, mf MessageFactory, max int, timeout time.Duration) ([]StreamRecord, error)
+
+	// Commit the consumer group offset, the next record to consume is the one following the committed offset
+	Commit(stream, group string, offset int64) error
+
+	// Committed returns the last committed offset of the consumer group (-1 if nothing was committed)
+	Committed(stream, group string) (offset int64, err error)
+
+	// SeekToTimestamp moves the consumer group position to the first record appended at or after the timestamp
+	// (replay from timestamp) and return the new position
+	SeekToTimestamp(stream, group string, timestamp time.Time) (offset int64, err error)
+
+	// ConsumeWindow consumes the stream in tumbling windows and return the consumer id
+	ConsumeWindow(stream, group string, mf MessageFactory, options WindowOptions, callback WindowCallback) (consumerId string, err error)
+
+	// StopConsumer stops the windowed consumer with the given id
+	StopConsumer(consumerId string) bool
+}
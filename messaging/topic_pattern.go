@@ -0,0 +1,49 @@
+// Topic patterns for wildcard subscriptions
+//
+// Topic names are dot separated segments (e.g. "device.123.status"), a subscription topic may include wildcards:
+//   - "*" or "+" matches exactly one segment (e.g. "device.+.status" matches "device.123.status")
+//   - "#" or ">" as the last segment matches one or more segments (e.g. "events.#" matches "events.user.created")
+
+package messaging
+
+import "strings"
+
+const topicSeparator = "."
+
+// IsTopicPattern checks if the topic includes wildcards
+func IsTopicPattern(topic string) bool {
+	for _, segment := range strings.Split(topic, topicSeparator) {
+		switch segment {
+		case "*", "+", "#", ">":
+			return true
+		}
+	}
+	return false
+}
+
+// MatchTopic checks if the topic matches the pattern
+func MatchTopic(pattern, topic string) bool {
+	if pattern == topic {
+		return true
+	}
+
+	patternSegments := strings.Split(pattern, topicSeparator)
+	topicSegments := strings.Split(topic, topicSeparator)
+
+	for i, segment := range patternSegments {
+		switch segment {
+		case "#", ">":
+			// Multi segments wildcard is valid only as the last segment
+			return i == len(patternSegments)-1 && len(topicSegments) > i
+		case "*", "+":
+			if i >= len(topicSegments) {
+				return false
+			}
+		default:
+			if i >= len(topicSegments) || topicSegments[i] != segment {
+				return false
+			}
+		}
+	}
+	return len(patternSegments) == len(topicSegments)
+}
@@ -0,0 +1,232 @@
+// REST authentication and authorization middleware
+//
+// Authenticate validates the JWT access token (Authorization: Bearer <token>) or the API key header of the request
+// using utils.TokenUtils, and injects the authenticated principal into the request context. Routes which require
+// specific roles or scopes are wrapped with RequireRoles / RequireScopes:
+//
+//	auth := rest.Authenticate(rest.AuthOptions{RolesResolver: resolveRoles})
+//	mux.Handle("GET /users", auth(handler))
+//	mux.Handle("DELETE /users/{id}", auth(rest.RequireRoles("admin")(handler)))
+//
+
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// DefaultApiKeyHeader is the default header of the API key
+	DefaultApiKeyHeader = "X-API-KEY"
+)
+
+// Principal is the authenticated caller of the request
+type Principal struct {
+	Subject string                // Token subject (e.g. user id) or the application name of the API key
+	ApiKey  bool                  // The caller is authenticated by API key
+	Claims  *jwt.RegisteredClaims // Access token claims (nil for API key)
+	Roles   []string              // Caller roles (see AuthOptions.RolesResolver)
+	Scopes  []string              // Caller scopes (see AuthOptions.RolesResolver)
+}
+
+// HasRole checks if the principal has the role
+func (p *Principal) HasRole(role string) bool {
+	return contains(p.Roles, role)
+}
+
+// HasScope checks if the principal has the scope
+func (p *Principal) HasScope(scope string) bool {
+	return contains(p.Scopes, scope)
+}
+
+// RolesResolver populates the roles and scopes of the authenticated principal (e.g. from the user store)
+type RolesResolver func(r *http.Request, principal *Principal) error
+
+// ApiKeyResolver validates the application name of the API key (e.g. against the registered applications)
+type ApiKeyResolver func(r *http.Request, appName string) error
+
+// AuthOptions configures the authentication middleware
+type AuthOptions struct {
+	ApiKeyHeader   string        // Header of the API key (default: X-API-KEY)
+	DisableApiKey  bool          // Accept only access tokens
+	DisableToken   bool          // Accept only API keys
+	RolesResolver  RolesResolver // Optional resolver of the principal roles and scopes (by default the token audience is used as scopes)
+	AllowAnonymous bool          // Pass requests without credentials to the next handler (without principal)
+
+	// Optional validation of the API key application name, by allowlist and / or resolver (both must pass)
+	ApiKeyApps     []string
+	ApiKeyResolver ApiKeyResolver
+
+	// Accept legacy (AES-CFB) API keys, legacy keys are not protected against tampering so they are accepted only when
+	// the application name is validated by ApiKeyApps or ApiKeyResolver
+	AllowLegacyApiKeys bool
+
+	// Optional revocation list, access tokens revoked in the list are rejected (e.g. after logout)
+	Blacklist *utils.TokenBlacklist
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of the context with the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated principal of the request context
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// Authenticate returns a middleware which rejects requests without valid access token or API key (401)
+func Authenticate(options AuthOptions) func(next http.Handler) http.Handler {
+	if len(options.ApiKeyHeader) == 0 {
+		options.ApiKeyHeader = DefaultApiKeyHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticate(r, options)
+			if err != nil {
+				writeErrorResponse(w, http.StatusUnauthorized, err)
+				return
+			}
+			if principal == nil {
+				if options.AllowAnonymous {
+					next.ServeHTTP(w, r)
+				} else {
+					writeErrorResponse(w, http.StatusUnauthorized, fmt.Errorf("missing credentials"))
+				}
+				return
+			}
+			if options.RolesResolver != nil {
+				if err = options.RolesResolver(r, principal); err != nil {
+					writeErrorResponse(w, http.StatusUnauthorized, err)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// extract and validate the request credentials, returns nil principal if the request has no credentials
+func authenticate(r *http.Request, options AuthOptions) (*Principal, error) {
+	if !options.DisableToken {
+		if header := r.Header.Get("Authorization"); len(header) > 0 {
+			scheme, token, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") || len(token) == 0 {
+				return nil, fmt.Errorf("invalid authorization header")
+			}
+			var opts []utils.TokenParseOption
+			if options.Blacklist != nil {
+				opts = append(opts, utils.WithTokenBlacklist(options.Blacklist))
+			}
+			claims, err := utils.TokenUtils().ParseToken(strings.TrimSpace(token), opts...)
+			if err != nil {
+				return nil, fmt.Errorf("invalid access token: %s", err.Error())
+			}
+			return &Principal{Subject: claims.Subject, Claims: claims, Scopes: claims.Audience}, nil
+		}
+	}
+
+	if !options.DisableApiKey {
+		if apiKey := r.Header.Get(options.ApiKeyHeader); len(apiKey) > 0 {
+			appName, err := parseApiKey(r, apiKey, options)
+			if err != nil {
+				return nil, err
+			}
+			return &Principal{Subject: appName, ApiKey: true}, nil
+		}
+	}
+	return nil, nil
+}
+
+// decode the API key and validate its application name
+func parseApiKey(r *http.Request, apiKey string, options AuthOptions) (string, error) {
+	validated := len(options.ApiKeyApps) > 0 || options.ApiKeyResolver != nil
+	if utils.TokenUtils().IsLegacyApiKey(apiKey) {
+		if !options.AllowLegacyApiKeys || !validated {
+			return "", fmt.Errorf("invalid api key")
+		}
+		migrated, err := utils.TokenUtils().MigrateApiKey(apiKey)
+		if err != nil {
+			return "", fmt.Errorf("invalid api key")
+		}
+		apiKey = migrated
+	}
+
+	appName, err := utils.TokenUtils().ParseApiKey(apiKey)
+	if err != nil || len(appName) == 0 {
+		return "", fmt.Errorf("invalid api key")
+	}
+	if len(options.ApiKeyApps) > 0 && !contains(options.ApiKeyApps, appName) {
+		return "", fmt.Errorf("invalid api key")
+	}
+	if options.ApiKeyResolver != nil {
+		if err = options.ApiKeyResolver(r, appName); err != nil {
+			return "", fmt.Errorf("invalid api key")
+		}
+	}
+	return appName, nil
+}
+
+// RequireRoles returns a middleware which rejects requests of principals without any of the roles (403)
+// Must be used after Authenticate
+func RequireRoles(roles ...string) func(next http.Handler) http.Handler {
+	return authorize(func(p *Principal) bool {
+		for _, role := range roles {
+			if p.HasRole(role) {
+				return true
+			}
+		}
+		return len(roles) == 0
+	})
+}
+
+// RequireScopes returns a middleware which rejects requests of principals without all the scopes (403)
+// Must be used after Authenticate
+func RequireScopes(scopes ...string) func(next http.Handler) http.Handler {
+	return authorize(func(p *Principal) bool {
+		for _, scope := range scopes {
+			if !p.HasScope(scope) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// authorize the request principal by the rule
+func authorize(rule func(p *Principal) bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				writeErrorResponse(w, http.StatusUnauthorized, fmt.Errorf("missing credentials"))
+				return
+			}
+			if !rule(principal) {
+				writeErrorResponse(w, http.StatusForbidden, fmt.Errorf("access denied"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check if the list contains the value
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,381 @@
+// Generic REST client
+//
+// Fluent HTTP client for service-to-service calls with JSON encoding, authentication header injection, retries with
+// exponential backoff, per-request timeout and circuit breaker:
+//
+//	c := client.New("https://users-service/api/v1").
+//		WithBearerToken(token).
+//		WithTimeout(5 * time.Second).
+//		WithRetry(client.RetryPolicy{MaxAttempts: 3, Backoff: 100 * time.Millisecond}).
+//		WithCircuitBreaker(5, 30*time.Second)
+//
+//	user := &User{}
+//	err := c.Get(ctx, "/users/123", user)
+//
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// HttpError is returned for responses with non 2xx status code
+type HttpError struct {
+	StatusCode int    // Response status code
+	Body       string // Response body
+}
+
+// Error returns the error message
+func (e *HttpError) Error() string {
+	return fmt.Sprintf("http status code: %d %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// RetryPolicy configures the retries of failed requests (connection errors, timeouts and 5xx responses)
+// Only idempotent requests (GET, HEAD, OPTIONS, TRACE, PUT, DELETE) are retried unless RetryNonIdempotent is set
+type RetryPolicy struct {
+	MaxAttempts        int           // Max number of attempts including the first one (default: 1, no retries)
+	Backoff            time.Duration // Delay before the first retry, doubled on each retry (default: 100ms)
+	MaxBackoff         time.Duration // Max delay between retries (default: 5s)
+	RetryNonIdempotent bool          // Retry also POST and PATCH requests (the server must handle duplicate requests)
+}
+
+// attemptResult classifies the outcome of request attempt
+type attemptResult int
+
+const (
+	resultSuccess  attemptResult = iota // 2xx response
+	resultRejected                      // 4xx response or local error (e.g. token source), not retried and not counted by the circuit breaker
+	resultFailure                       // Upstream failure (connection error, timeout, 5xx response), retried and counted by the circuit breaker
+	resultCanceled                      // The caller context is done, not retried and not counted by the circuit breaker
+)
+
+// RoundTripFunc sends the request and returns the response
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the round trip of each attempt (e.g. for logging, tracing or request signing)
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Client is a REST client bound to a base URL
+type Client struct {
+	baseUrl     string
+	httpClient  *http.Client
+	headers     map[string]string
+	timeout     time.Duration
+	retry       RetryPolicy
+	middleware  []Middleware
+	breaker     *circuitBreaker
+	tokenSource func() (string, error)
+}
+
+// New creates a REST client for the base URL
+func New(baseUrl string) *Client {
+	return &Client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		httpClient: http.DefaultClient,
+		headers:    make(map[string]string),
+		retry:      RetryPolicy{MaxAttempts: 1},
+	}
+}
+
+// region Fluent configuration -----------------------------------------------------------------------------------------
+
+// WithHttpClient sets the underlying http client (default: http.DefaultClient)
+func (c *Client) WithHttpClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// WithHeader adds header to all the requests
+func (c *Client) WithHeader(key, value string) *Client {
+	c.headers[key] = value
+	return c
+}
+
+// WithBearerToken adds static bearer token authorization header to all the requests
+func (c *Client) WithBearerToken(token string) *Client {
+	return c.WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithTokenSource adds bearer token authorization header fetched for each request (e.g. refreshed access token)
+func (c *Client) WithTokenSource(source func() (string, error)) *Client {
+	c.tokenSource = source
+	return c
+}
+
+// WithApiKey adds API key header to all the requests
+func (c *Client) WithApiKey(header, apiKey string) *Client {
+	return c.WithHeader(header, apiKey)
+}
+
+// WithTimeout sets the timeout of each request attempt
+func (c *Client) WithTimeout(timeout time.Duration) *Client {
+	c.timeout = timeout
+	return c
+}
+
+// WithRetry sets the retry policy
+func (c *Client) WithRetry(policy RetryPolicy) *Client {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = 100 * time.Millisecond
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 5 * time.Second
+	}
+	c.retry = policy
+	return c
+}
+
+// WithCircuitBreaker opens the circuit after the number of consecutive upstream failures (connection errors, timeouts
+// and 5xx responses), while the circuit is open the requests fail immediately with ErrCircuitOpen. After the cool-down
+// a single trial request is allowed. A failure threshold <= 0 disables the circuit breaker
+func (c *Client) WithCircuitBreaker(failureThreshold int, coolDown time.Duration) *Client {
+	if failureThreshold <= 0 {
+		c.breaker = nil
+		return c
+	}
+	c.breaker = &circuitBreaker{threshold: failureThreshold, coolDown: coolDown}
+	return c
+}
+
+// WithMiddleware adds middleware wrapping each request attempt, the first middleware is the outermost
+func (c *Client) WithMiddleware(middleware ...Middleware) *Client {
+	c.middleware = append(c.middleware, middleware...)
+	return c
+}
+
+// endregion
+
+// region Requests -----------------------------------------------------------------------------------------------------
+
+// Get sends GET request and decodes the JSON response into result (if not nil)
+func (c *Client) Get(ctx context.Context, path string, result any) error {
+	return c.Do(ctx, http.MethodGet, path, nil, result)
+}
+
+// Post sends POST request with JSON body and decodes the JSON response into result (if not nil)
+func (c *Client) Post(ctx context.Context, path string, body, result any) error {
+	return c.Do(ctx, http.MethodPost, path, body, result)
+}
+
+// Put sends PUT request with JSON body and decodes the JSON response into result (if not nil)
+func (c *Client) Put(ctx context.Context, path string, body, result any) error {
+	return c.Do(ctx, http.MethodPut, path, body, result)
+}
+
+// Patch sends PATCH request with JSON body and decodes the JSON response into result (if not nil)
+func (c *Client) Patch(ctx context.Context, path string, body, result any) error {
+	return c.Do(ctx, http.MethodPatch, path, body, result)
+}
+
+// Delete sends DELETE request and decodes the JSON response into result (if not nil)
+func (c *Client) Delete(ctx context.Context, path string, result any) error {
+	return c.Do(ctx, http.MethodDelete, path, nil, result)
+}
+
+// Do sends the request with the JSON encoded body (nil for no body) and decodes the JSON response into result
+func (c *Client) Do(ctx context.Context, method, path string, body, result any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	data, err := c.send(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	if result == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// GetJSON sends GET request and returns the decoded JSON response
+func GetJSON[T any](ctx context.Context, c *Client, path string) (result T, err error) {
+	err = c.Get(ctx, path, &result)
+	return
+}
+
+// send the request with retries and return the response body
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) ([]byte, error) {
+	if c.breaker != nil && !c.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	maxAttempts := c.retry.MaxAttempts
+	if !c.retry.RetryNonIdempotent && !isIdempotent(method) {
+		maxAttempts = 1
+	}
+
+	backoff := c.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		data, result, err := c.attempt(ctx, method, path, payload)
+		if result != resultFailure || attempt >= maxAttempts {
+			if c.breaker != nil {
+				c.breaker.report(result)
+			}
+			return data, err
+		}
+
+		select {
+		case <-ctx.Done():
+			if c.breaker != nil {
+				c.breaker.report(resultCanceled)
+			}
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > c.retry.MaxBackoff {
+			backoff = c.retry.MaxBackoff
+		}
+	}
+}
+
+// send single request attempt, returns the response body and the attempt result
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte) ([]byte, attemptResult, error) {
+	parent := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), body)
+	if err != nil {
+		return nil, resultRejected, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	if c.tokenSource != nil {
+		token, fe := c.tokenSource()
+		if fe != nil {
+			return nil, resultRejected, fe
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	roundTrip := RoundTripFunc(c.httpClient.Do)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		roundTrip = c.middleware[i](roundTrip)
+	}
+
+	res, err := roundTrip(req)
+	if err != nil {
+		return nil, failureResult(parent), err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, failureResult(parent), err
+	}
+	if res.StatusCode >= 500 {
+		return data, resultFailure, &HttpError{StatusCode: res.StatusCode, Body: string(data)}
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return data, resultRejected, &HttpError{StatusCode: res.StatusCode, Body: string(data)}
+	}
+	return data, resultSuccess, nil
+}
+
+// classify a failed attempt, the attempt is canceled (not an upstream failure) when the caller context is done
+func failureResult(ctx context.Context) attemptResult {
+	if ctx.Err() != nil {
+		return resultCanceled
+	}
+	return resultFailure
+}
+
+// check if the request method is idempotent
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// build the request URL
+func (c *Client) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if len(path) > 0 && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return c.baseUrl + path
+}
+
+// endregion
+
+// region Circuit breaker ----------------------------------------------------------------------------------------------
+
+// circuitBreaker counts consecutive failures and opens the circuit for the cool-down period
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// check if a request is allowed (closed circuit, or a single trial after the cool-down)
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// report the request result, only successes and upstream failures change the circuit state
+func (b *circuitBreaker) report(result attemptResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	switch result {
+	case resultSuccess:
+		b.failures = 0
+	case resultFailure:
+		if b.failures++; b.failures >= b.threshold {
+			b.openUntil = time.Now().Add(b.coolDown)
+		}
+	}
+}
+
+// endregion
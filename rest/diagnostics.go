@@ -0,0 +1,129 @@
+// REST diagnostics handlers: redacted configuration, build info and runtime variables
+//
+// The handlers are registered on a standard http.ServeMux:
+//
+//	rest.SetBuildInfo(version, commit, buildTime)   // typically injected with -ldflags "-X main.version=..."
+//	rest.RegisterDiagnostics(mux, "/diagnostics")  // GET /diagnostics/config, /diagnostics/buildinfo, /diagnostics/vars
+//
+
+package rest
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/config"
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+// BuildInfo describes the service build
+type BuildInfo struct {
+	Version   string           `json:"version"`   // Service version
+	Commit    string           `json:"commit"`    // Source control commit
+	BuildTime string           `json:"buildTime"` // Build time
+	GoVersion string           `json:"goVersion"` // Go runtime version
+	StartTime entity.Timestamp `json:"startTime"` // Service start time
+	Uptime    string           `json:"uptime"`    // Time since the service started
+}
+
+// RuntimeVars are the service runtime variables
+type RuntimeVars struct {
+	Goroutines   int    `json:"goroutines"`   // Number of goroutines
+	NumCPU       int    `json:"numCPU"`       // Number of logical CPUs
+	HeapAlloc    uint64 `json:"heapAlloc"`    // Bytes of allocated heap objects
+	HeapInuse    uint64 `json:"heapInuse"`    // Bytes in in-use heap spans
+	Sys          uint64 `json:"sys"`          // Total bytes of memory obtained from the OS
+	NumGC        uint32 `json:"numGC"`        // Number of completed GC cycles
+	PauseTotalNs uint64 `json:"pauseTotalNs"` // Cumulative GC pause time in nanoseconds
+	Panics       int64  `json:"panics"`       // Number of recovered panics (see utils.PanicCount)
+}
+
+var (
+	buildInfoMu sync.RWMutex
+	buildInfo   = BuildInfo{}
+)
+
+// SetBuildInfo sets the service version, commit and build time reported by the build info handler
+// Empty values are resolved from the binary build information (module version and VCS revision) when available
+func SetBuildInfo(version, commit, buildTime string) {
+	buildInfoMu.Lock()
+	defer buildInfoMu.Unlock()
+	buildInfo = BuildInfo{Version: version, Commit: commit, BuildTime: buildTime}
+}
+
+// GetBuildInfo returns the service build info
+func GetBuildInfo() BuildInfo {
+	buildInfoMu.RLock()
+	info := buildInfo
+	buildInfoMu.RUnlock()
+
+	info.GoVersion = runtime.Version()
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if len(info.Version) == 0 && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" && len(info.Commit) == 0 {
+				info.Commit = setting.Value
+			}
+			if setting.Key == "vcs.time" && len(info.BuildTime) == 0 {
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	info.StartTime = config.Get().StartTime()
+	info.Uptime = time.Since(info.StartTime.Time()).Round(time.Second).String()
+	return info
+}
+
+// GetRuntimeVars returns the service runtime variables
+func GetRuntimeVars() RuntimeVars {
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	return RuntimeVars{
+		Goroutines:   runtime.NumGoroutine(),
+		NumCPU:       runtime.NumCPU(),
+		HeapAlloc:    ms.HeapAlloc,
+		HeapInuse:    ms.HeapInuse,
+		Sys:          ms.Sys,
+		NumGC:        ms.NumGC,
+		PauseTotalNs: ms.PauseTotalNs,
+		Panics:       utils.PanicCount(),
+	}
+}
+
+// ConfigHandler returns the configuration variables with redacted secrets (see config.BaseConfig.DumpSafe)
+func ConfigHandler() http.Handler {
+	return jsonHandler(func() any { return config.Get().DumpSafe() })
+}
+
+// BuildInfoHandler returns the service build info
+func BuildInfoHandler() http.Handler {
+	return jsonHandler(func() any { return GetBuildInfo() })
+}
+
+// VarsHandler returns the service runtime variables
+func VarsHandler() http.Handler {
+	return jsonHandler(func() any { return GetRuntimeVars() })
+}
+
+// RegisterDiagnostics registers the diagnostics handlers on the mux under the prefix: config, buildinfo and vars
+func RegisterDiagnostics(mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux.Handle("GET "+prefix+"/config", ConfigHandler())
+	mux.Handle("GET "+prefix+"/buildinfo", BuildInfoHandler())
+	mux.Handle("GET "+prefix+"/vars", VarsHandler())
+}
+
+// write the value returned by the function as JSON
+func jsonHandler(value func() any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = WriteJSON(w, http.StatusOK, value())
+	})
+}
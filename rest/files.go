@@ -0,0 +1,227 @@
+// Multipart upload and file download helpers
+//
+
+package rest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrFileTooLarge is returned when an uploaded file exceeds the max size
+var ErrFileTooLarge = errors.New("file too large")
+
+// ErrUnsupportedMediaType is returned when an uploaded file content type is not allowed
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// region Upload -------------------------------------------------------------------------------------------------------
+
+// UploadedFile describes a file received from multipart request
+type UploadedFile struct {
+	Field       string // Form field name
+	FileName    string // Original file name
+	ContentType string // Content type (declared by the client or detected from the content)
+	Size        int64  // Number of bytes written
+}
+
+// UploadOptions configures the multipart upload validation
+type UploadOptions struct {
+	Fields       []string // Accepted form fields (empty for any field)
+	MaxFileSize  int64    // Max size of a single file in bytes (0 for unlimited)
+	MaxFiles     int      // Max number of files (0 for unlimited)
+	AllowedTypes []string // Allowed content types, entries ending with "/" are treated as prefix (e.g. "image/")
+}
+
+// UploadSink provides the destination writer of an uploaded file (local file, object store writer etc.)
+// The writer is closed when the file is fully received, the size is set only after the file is fully received
+// If the file is rejected while written (e.g. exceeds the max size) the writer is aborted (see UploadAborter)
+type UploadSink func(file UploadedFile) (io.WriteCloser, error)
+
+// UploadAborter is implemented by upload sink writers which can discard a partially written file (e.g. delete the
+// object), such writer is aborted instead of closed when the upload fails
+type UploadAborter interface {
+	Abort() error
+}
+
+// ReceiveFiles streams the files of multipart request to the writers provided by the sink without buffering the
+// entire file in memory. Non file form values are ignored.
+// The content type is detected from the file content when the client does not declare a specific one
+func ReceiveFiles(r *http.Request, options UploadOptions, sink UploadSink) ([]UploadedFile, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]UploadedFile, 0)
+	for {
+		part, fe := reader.NextPart()
+		if fe == io.EOF {
+			return files, nil
+		}
+		if fe != nil {
+			return files, fe
+		}
+		if len(part.FileName()) == 0 || !acceptField(options.Fields, part.FormName()) {
+			_ = part.Close()
+			continue
+		}
+		if options.MaxFiles > 0 && len(files) >= options.MaxFiles {
+			_ = part.Close()
+			return files, fmt.Errorf("too many files, max: %d", options.MaxFiles)
+		}
+
+		file, fe := receiveFile(part.FormName(), part.FileName(), part.Header.Get("Content-Type"), part, options, sink)
+		_ = part.Close()
+		if fe != nil {
+			return files, fe
+		}
+		files = append(files, file)
+	}
+}
+
+// receive single file part
+func receiveFile(field, name, contentType string, src io.Reader, options UploadOptions, sink UploadSink) (UploadedFile, error) {
+	file := UploadedFile{Field: field, FileName: name, ContentType: contentType}
+
+	// Detect the content type from the first bytes of the file
+	buffered := bufio.NewReaderSize(src, 512)
+	if len(file.ContentType) == 0 || file.ContentType == "application/octet-stream" {
+		head, _ := buffered.Peek(512)
+		file.ContentType = http.DetectContentType(head)
+	}
+	if !acceptType(options.AllowedTypes, file.ContentType) {
+		return file, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, file.ContentType)
+	}
+
+	dst, err := sink(file)
+	if err != nil {
+		return file, err
+	}
+
+	// Never write more than the max size to the sink, the file is too large if there is more data after the limit
+	var reader io.Reader = buffered
+	if options.MaxFileSize > 0 {
+		reader = io.LimitReader(buffered, options.MaxFileSize)
+	}
+	file.Size, err = io.Copy(dst, reader)
+	if err == nil && options.MaxFileSize > 0 {
+		if _, pe := buffered.ReadByte(); pe == nil {
+			err = fmt.Errorf("%w: %s exceeds %d bytes", ErrFileTooLarge, name, options.MaxFileSize)
+		}
+	}
+	if err != nil {
+		abortUpload(dst)
+		return file, err
+	}
+	return file, dst.Close()
+}
+
+// abort the sink writer of a failed upload, writers which can't be aborted are closed
+func abortUpload(dst io.WriteCloser) {
+	if aborter, ok := dst.(UploadAborter); ok {
+		_ = aborter.Abort()
+	} else {
+		_ = dst.Close()
+	}
+}
+
+// check if the form field is accepted
+func acceptField(fields []string, field string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// check if the content type is allowed
+func acceptType(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if mediaType == a || (strings.HasSuffix(a, "/") && strings.HasPrefix(mediaType, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// endregion
+
+// region Download -----------------------------------------------------------------------------------------------------
+
+// DownloadOptions configures the file download
+type DownloadOptions struct {
+	ContentType    string    // Content type (detected from the file name or content if empty)
+	Inline         bool      // Display the content in the browser instead of downloading it as attachment
+	ModTime        time.Time // Modification time used for conditional requests (If-Modified-Since)
+	BytesPerSecond int64     // Throttle the download rate (0 for unlimited)
+}
+
+// ServeFile writes the content as file download with Content-Disposition header.
+// Range requests are supported to enable resumable downloads
+func ServeFile(w http.ResponseWriter, r *http.Request, fileName string, content io.ReadSeeker, options DownloadOptions) {
+	disposition := "attachment"
+	if options.Inline {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": fileName}))
+	if len(options.ContentType) > 0 {
+		w.Header().Set("Content-Type", options.ContentType)
+	}
+	if options.BytesPerSecond > 0 {
+		w = &throttledResponseWriter{ResponseWriter: w, rate: options.BytesPerSecond}
+	}
+	http.ServeContent(w, r, fileName, options.ModTime, content)
+}
+
+// throttledResponseWriter limits the write rate of the response body
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	rate int64
+}
+
+// Write the data in chunks of up to 1/10 of the rate, pausing between chunks
+func (t *throttledResponseWriter) Write(p []byte) (int, error) {
+	chunk := int(t.rate / 10)
+	if chunk < 1 {
+		chunk = 1
+	}
+	written := 0
+	for written < len(p) {
+		end := written + chunk
+		if end > len(p) {
+			end = len(p)
+		}
+		start := time.Now()
+		n, err := t.ResponseWriter.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if f, ok := t.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		if wait := time.Duration(n)*time.Second/time.Duration(t.rate) - time.Since(start); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	return written, nil
+}
+
+// endregion
@@ -0,0 +1,213 @@
+// REST HTTP metrics middleware
+//
+// HttpMetrics records the request count, duration histogram and in-flight requests per route template, and exposes them
+// in the Prometheus text exposition format:
+//
+//	metrics := rest.NewHttpMetrics()
+//	mux.Handle("GET /users/{id}", metrics.Handle("/users/{id}", handler))
+//	mux.Handle("GET /metrics", metrics)
+//
+// The route template (rather than the request path) is used as label to keep the metrics cardinality bounded, and
+// methods other than the standard HTTP methods are recorded as OTHER.
+//
+// The metrics are not collected automatically and are not registered in a Prometheus registry: each route must be
+// wrapped explicitly (Handle or Middleware) and the recorder itself must be mounted as the scrape endpoint (ServeHTTP)
+
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDurationBuckets are the default request duration histogram buckets (in seconds)
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// HttpMetrics records HTTP request metrics per route
+type HttpMetrics struct {
+	mu        sync.Mutex
+	buckets   []float64
+	requests  map[requestLabels]int64
+	durations map[durationLabels]*durationHistogram
+	inFlight  map[string]int64
+}
+
+// requestLabels are the labels of the requests counter
+type requestLabels struct {
+	route  string
+	method string
+	status int
+}
+
+// durationLabels are the labels of the duration histogram
+type durationLabels struct {
+	route  string
+	method string
+}
+
+// durationHistogram is a cumulative histogram of the request durations
+type durationHistogram struct {
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+// NewHttpMetrics creates the HTTP metrics recorder with the duration histogram buckets (default: DefaultDurationBuckets)
+func NewHttpMetrics(buckets ...float64) *HttpMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+
+	return &HttpMetrics{
+		buckets:   sorted,
+		requests:  make(map[requestLabels]int64),
+		durations: make(map[durationLabels]*durationHistogram),
+		inFlight:  make(map[string]int64),
+	}
+}
+
+// Middleware returns a middleware recording the metrics of the route template
+func (m *HttpMetrics) Middleware(route string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.Handle(route, next)
+	}
+}
+
+// Handle wraps the handler of the route template with the metrics recording
+func (m *HttpMetrics) Handle(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.addInFlight(route, 1)
+		start := time.Now()
+		rw := NewStatusResponseWriter(w)
+
+		defer func() {
+			m.addInFlight(route, -1)
+			m.observe(route, r.Method, rw.status, time.Since(start))
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// update the in-flight requests gauge
+func (m *HttpMetrics) addInFlight(route string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[route] += delta
+}
+
+// record the request
+func (m *HttpMetrics) observe(route, method string, status int, duration time.Duration) {
+	method = normalizeMethod(method)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestLabels{route: route, method: method, status: status}]++
+
+	key := durationLabels{route: route, method: method}
+	h, ok := m.durations[key]
+	if !ok {
+		h = &durationHistogram{counts: make([]int64, len(m.buckets))}
+		m.durations[key] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (m *HttpMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(m.String()))
+}
+
+// String returns the metrics in the Prometheus text exposition format
+func (m *HttpMetrics) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sb := strings.Builder{}
+
+	sb.WriteString("# HELP http_requests_total Number of HTTP requests\n# TYPE http_requests_total counter\n")
+	requests := make([]requestLabels, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, key := range requests {
+		sb.WriteString(fmt.Sprintf("http_requests_total{route=\"%s\",method=\"%s\",status=\"%d\"} %d\n", escapeLabel(key.route), key.method, key.status, m.requests[key]))
+	}
+
+	sb.WriteString("# HELP http_request_duration_seconds HTTP request duration\n# TYPE http_request_duration_seconds histogram\n")
+	durations := make([]durationLabels, 0, len(m.durations))
+	for key := range m.durations {
+		durations = append(durations, key)
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		if durations[i].route != durations[j].route {
+			return durations[i].route < durations[j].route
+		}
+		return durations[i].method < durations[j].method
+	})
+	for _, key := range durations {
+		h := m.durations[key]
+		labels := fmt.Sprintf("route=\"%s\",method=\"%s\"", escapeLabel(key.route), key.method)
+		for i, bound := range m.buckets {
+			sb.WriteString(fmt.Sprintf("http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, strconv.FormatFloat(bound, 'f', -1, 64), h.counts[i]))
+		}
+		sb.WriteString(fmt.Sprintf("http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count))
+		sb.WriteString(fmt.Sprintf("http_request_duration_seconds_sum{%s} %v\n", labels, h.sum))
+		sb.WriteString(fmt.Sprintf("http_request_duration_seconds_count{%s} %d\n", labels, h.count))
+	}
+
+	sb.WriteString("# HELP http_requests_in_flight Number of HTTP requests in process\n# TYPE http_requests_in_flight gauge\n")
+	routes := make([]string, 0, len(m.inFlight))
+	for route := range m.inFlight {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		sb.WriteString(fmt.Sprintf("http_requests_in_flight{route=\"%s\"} %d\n", escapeLabel(route), m.inFlight[route]))
+	}
+	return sb.String()
+}
+
+// labelEscaper escapes label values according to the Prometheus text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape the label value
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// normalize the method label to the standard HTTP methods (OTHER for any other method) to keep the cardinality bounded
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,253 @@
+// Standard REST middleware: panic recovery, access logging and request id propagation
+//
+// The middleware are standard http.Handler wrappers and may be combined using Chain:
+//
+//	handler := rest.Chain(mux, rest.RequestId, rest.AccessLog(nil), rest.Recover)
+//
+
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// RequestIdHeader is the header of the request id
+const RequestIdHeader = "X-Request-ID"
+
+// Middleware wraps http handler
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps the handler with the middleware, the first middleware is the outermost
+func Chain(handler http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// region Recovery -----------------------------------------------------------------------------------------------------
+
+// Recover is a middleware which recovers from panics in the next handlers, logs the panic with the stack trace and
+// returns 500 JSON error response
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// Aborted handler should abort the response
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+			logger.Error("panic in %s %s [request id: %s]: %v\n%s", r.Method, r.URL.Path, RequestIdFromContext(r.Context()), rec, debug.Stack())
+			writeErrorResponse(w, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// endregion
+
+// region Request id ---------------------------------------------------------------------------------------------------
+
+type requestIdContextKey struct{}
+
+// RequestId is a middleware which propagates the request id header (or generates a new id if the header is missing)
+// to the request context and to the response header
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := strings.TrimSpace(r.Header.Get(RequestIdHeader))
+		if len(requestId) == 0 || len(requestId) > 128 {
+			requestId = entity.NanoID()
+		}
+		w.Header().Set(RequestIdHeader, requestId)
+		next.ServeHTTP(w, r.WithContext(WithRequestId(r.Context(), requestId)))
+	})
+}
+
+// WithRequestId returns a copy of the context with the request id, the request id is attached to the context logger
+// (see logger.FromContext)
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	ctx = logger.WithContext(ctx, logger.F(logger.RequestIdKey, requestId))
+	return context.WithValue(ctx, requestIdContextKey{}, requestId)
+}
+
+// RequestIdFromContext returns the request id of the context (empty string if not exists)
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdContextKey{}).(string)
+	return requestId
+}
+
+// endregion
+
+// region Access log ---------------------------------------------------------------------------------------------------
+
+// AccessLogEntry is a single request access log entry
+type AccessLogEntry struct {
+	Method    string        `json:"method"`    // Request method
+	Path      string        `json:"path"`      // Request path
+	Status    int           `json:"status"`    // Response status code
+	Bytes     int64         `json:"bytes"`     // Response body size
+	Latency   time.Duration `json:"latency"`   // Request processing time
+	RemoteIP  string        `json:"remoteIp"`  // Client IP (see RemoteIP)
+	RequestId string        `json:"requestId"` // Request id (see RequestId middleware)
+	UserAgent string        `json:"userAgent"` // Client user agent
+}
+
+// AccessLog returns a middleware which reports the access log entry of each request to the sink
+// If the sink is nil, the entry is logged by the logger in key=value format
+func AccessLog(sink func(entry AccessLogEntry)) func(next http.Handler) http.Handler {
+	if sink == nil {
+		sink = logAccessEntry
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := NewStatusResponseWriter(w)
+			defer func() {
+				sink(AccessLogEntry{
+					Method:    r.Method,
+					Path:      r.URL.Path,
+					Status:    rw.status,
+					Bytes:     rw.bytes,
+					Latency:   time.Since(start),
+					RemoteIP:  RemoteIP(r),
+					RequestId: RequestIdFromContext(r.Context()),
+					UserAgent: r.UserAgent(),
+				})
+			}()
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// log the access log entry
+func logAccessEntry(e AccessLogEntry) {
+	logger.Info("method=%s path=%s status=%d bytes=%d latency=%s remote_ip=%s request_id=%s", e.Method, e.Path, e.Status, e.Bytes, e.Latency, e.RemoteIP, e.RequestId)
+}
+
+// trusted reverse proxies (see SetTrustedProxies)
+var trustedProxies atomic.Pointer[[]netip.Prefix]
+
+// SetTrustedProxies sets the IP addresses or CIDR ranges of the trusted reverse proxies (load balancers), the
+// X-Forwarded-For header is used by RemoteIP only for requests of trusted proxies. Call without arguments to trust none
+func SetTrustedProxies(proxies ...string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, er := netip.ParseAddr(proxy); er == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		} else {
+			return fmt.Errorf("invalid trusted proxy %s", proxy)
+		}
+	}
+	trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// RemoteIP returns the client IP of the request, which is the connection remote address unless the connection is from
+// a trusted proxy (see SetTrustedProxies), in that case it is the rightmost untrusted X-Forwarded-For address
+func RemoteIP(r *http.Request) string {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	// Each proxy appends the address it received the request from, so addresses left of the last untrusted hop are
+	// supplied by the client and can't be trusted
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if len(hop) == 0 {
+			continue
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
+
+// check if the address is a trusted proxy
+func isTrustedProxy(ip string) bool {
+	prefixes := trustedProxies.Load()
+	if prefixes == nil || len(*prefixes) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range *prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusResponseWriter captures the response status code and body size (e.g. for logging, metrics and tracing
+// middlewares)
+type StatusResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// NewStatusResponseWriter wraps the response writer, the status is 200 unless the handler writes another status
+func NewStatusResponseWriter(w http.ResponseWriter) *StatusResponseWriter {
+	return &StatusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// Status returns the response status code
+func (w *StatusResponseWriter) Status() int {
+	return w.status
+}
+
+// Bytes returns the number of body bytes written
+func (w *StatusResponseWriter) Bytes() int64 {
+	return w.bytes
+}
+
+// WriteHeader captures the status code
+func (w *StatusResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write captures the body size
+func (w *StatusResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the original response writer (used by http.ResponseController)
+func (w *StatusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// endregion
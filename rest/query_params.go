@@ -0,0 +1,263 @@
+// Helpers to build database query from REST request query parameters
+//
+
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+// QueryOptions configures the mapping of the request query parameters to the database query
+type QueryOptions struct {
+	TimeField    string   // Entity field used for the from / to range filter (default: "createdOn")
+	DefaultLimit int      // Page size when the limit param is missing (default: 100)
+	MaxLimit     int      // Max page size (0 for unlimited)
+	Fields       []string // Fields allowed for filter and sort (empty for any field)
+}
+
+// GetQueryFromRequest builds query for the entity from the standard list endpoint query parameters:
+//
+//	page=2                      page number (0 based)
+//	limit=50 (or size=50)       page size
+//	sort=name,createdOn-        comma separated sort fields, "-" suffix (or prefix) for descending order
+//	filter=field:op:value       filter expression (repeatable), for in / nin / between use | to separate the values,
+//	                            validated against the entity and the values are converted to the field type
+//	where=status=active AND ... boolean filter expression (see database.ParseFilter), validated against the entity
+//	from=...&to=...             time range on the time field (epoch milliseconds or RFC3339)
+//
+// Supported filter operators: eq, neq, like, gt, gte, lt, lte, in, nin, between, contains, empty
+func GetQueryFromRequest(r *http.Request, db database.IDatabase, factory entity.EntityFactory, options ...QueryOptions) (database.IQuery, error) {
+	opts := QueryOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if len(opts.TimeField) == 0 {
+		opts.TimeField = "createdOn"
+	}
+	if opts.DefaultLimit <= 0 {
+		opts.DefaultLimit = 100
+	}
+
+	values := r.URL.Query()
+	query := db.Query(factory)
+
+	// Pagination
+	page, err := queryIntValue(values.Get("page"), 0)
+	if err != nil || page < 0 {
+		return nil, fmt.Errorf("invalid page parameter: %s", values.Get("page"))
+	}
+	limitParam := values.Get("limit")
+	if len(limitParam) == 0 {
+		limitParam = values.Get("size")
+	}
+	limit, err := queryIntValue(limitParam, opts.DefaultLimit)
+	if err != nil || limit < 1 {
+		return nil, fmt.Errorf("invalid limit parameter: %s", limitParam)
+	}
+	if opts.MaxLimit > 0 && limit > opts.MaxLimit {
+		limit = opts.MaxLimit
+	}
+	query.Page(page).Limit(limit)
+
+	// Sort
+	for _, sort := range strings.Split(values.Get("sort"), ",") {
+		if sort = strings.TrimSpace(sort); len(sort) == 0 {
+			continue
+		}
+		if strings.HasPrefix(sort, "-") {
+			sort = sort[1:] + "-"
+		}
+		if !allowedField(opts.Fields, strings.TrimRight(sort, "+-")) {
+			return nil, fmt.Errorf("invalid sort field: %s", sort)
+		}
+		query.Sort(sort)
+	}
+
+	// Filters
+	filters := make([]database.QueryFilter, 0)
+	conditions := make([]database.Condition, 0)
+	kinds := entityFieldKinds(factory())
+	for _, expr := range values["filter"] {
+		filter, fe := parseFilterExpression(expr, kinds)
+		if fe != nil {
+			return nil, fe
+		}
+		if !allowedField(opts.Fields, filter.GetField()) {
+			return nil, fmt.Errorf("invalid filter field: %s", filter.GetField())
+		}
+		filters = append(filters, filter)
+		conditions = append(conditions, filter)
+	}
+	if len(filters) > 0 {
+		if fe := database.And(conditions...).Validate(factory); fe != nil {
+			return nil, fe
+		}
+		query.MatchAll(filters...)
+	}
+	if where := values.Get("where"); len(where) > 0 {
+		expr, fe := database.ParseFilter(where)
+		if fe != nil {
+			return nil, fe
+		}
+		for _, filter := range expr.Filters() {
+			if !allowedField(opts.Fields, filter.GetField()) {
+				return nil, fmt.Errorf("invalid filter field: %s", filter.GetField())
+			}
+		}
+		if fe = expr.Validate(factory); fe != nil {
+			return nil, fe
+		}
+		query = expr.Apply(query)
+	}
+
+	// Time range
+	if len(values.Get("from")) > 0 || len(values.Get("to")) > 0 {
+		tf, fe := GetTimeFrameParams(r, "from", "to")
+		if fe != nil {
+			return nil, fe
+		}
+		query.Range(opts.TimeField, tf.From, tf.To)
+	}
+	return query, nil
+}
+
+// parse filter expression in the format: field:op:value, the values are converted to the kind of the entity field
+func parseFilterExpression(expr string, kinds map[string]reflect.Kind) (database.QueryFilter, error) {
+	parts := strings.SplitN(expr, ":", 3)
+	if len(parts) < 2 || len(parts[0]) == 0 {
+		return nil, fmt.Errorf("invalid filter expression: %s", expr)
+	}
+	field, op, value := parts[0], strings.ToLower(parts[1]), ""
+	if len(parts) == 3 {
+		value = parts[2]
+	}
+
+	kind, ok := kinds[field]
+	if !ok {
+		return nil, fmt.Errorf("invalid filter field: %s", field)
+	}
+	list := []string{value}
+	switch op {
+	case "in", "nin", "between":
+		list = strings.Split(value, "|")
+	case "like", "contains", "empty":
+		// pattern, array item and empty filters are not converted
+		kind = reflect.String
+	}
+	converted := make([]any, 0, len(list))
+	for _, item := range list {
+		v, err := parseFilterValue(kind, item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for filter field %s: %s", field, item)
+		}
+		converted = append(converted, v)
+	}
+
+	filter := database.F(field)
+	switch op {
+	case "eq":
+		return filter.Eq(converted[0]), nil
+	case "neq":
+		return filter.Neq(converted[0]), nil
+	case "like":
+		return filter.Like(value), nil
+	case "gt":
+		return filter.Gt(converted[0]), nil
+	case "gte":
+		return filter.Gte(converted[0]), nil
+	case "lt":
+		return filter.Lt(converted[0]), nil
+	case "lte":
+		return filter.Lte(converted[0]), nil
+	case "in":
+		return filter.In(converted...), nil
+	case "nin":
+		return filter.NotIn(converted...), nil
+	case "between":
+		if len(converted) != 2 {
+			return nil, fmt.Errorf("between filter requires two values: %s", expr)
+		}
+		return filter.Between(converted[0], converted[1]), nil
+	case "contains":
+		return filter.Contains(value), nil
+	case "empty":
+		return filter.IsEmpty(), nil
+	default:
+		return nil, fmt.Errorf("unsupported filter operator: %s", op)
+	}
+}
+
+// convert the filter value to number or boolean according to the field kind (see database.ParseFilter)
+func parseFilterValue(kind reflect.Kind, value string) (any, error) {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n, nil
+		}
+		return strconv.ParseFloat(value, 64)
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	default:
+		return value, nil
+	}
+}
+
+// get the json field names of the entity and their kinds
+func entityFieldKinds(ent entity.Entity) map[string]reflect.Kind {
+	types := utils.JsonUtils().FieldTypes(ent)
+	kinds := make(map[string]reflect.Kind, len(types))
+	for name, ft := range types {
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		kinds[name] = ft.Kind()
+	}
+	return kinds
+}
+
+// check if the field is allowed
+func allowedField(fields []string, field string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parse int query value or return the default value when missing
+func queryIntValue(value string, defaultValue int) (int, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parse epoch milliseconds or RFC3339 value or return the default value when missing
+func parseTimestampValue(value string, defaultValue entity.Timestamp) (entity.Timestamp, error) {
+	if len(value) == 0 {
+		return defaultValue, nil
+	}
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return entity.Timestamp(millis), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return defaultValue, err
+	}
+	return entity.Timestamp(t.UnixMilli()), nil
+}
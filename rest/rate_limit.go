@@ -0,0 +1,141 @@
+// REST rate limiting middleware backed by IDataCache counters
+//
+// The requests are counted per key (client IP, API key, tenant etc.) in the distributed cache, so the limit is enforced
+// across all the service instances. Each route may have its own limit by wrapping the route handler:
+//
+//	limiter := rest.RateLimit(dc, rest.RateLimitOptions{Limit: 100, Window: time.Minute, KeyFunc: rest.RateLimitByIP})
+//	mux.Handle("POST /login", limiter(handler))
+//
+
+package rest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// RateLimitAlgorithm is the counting algorithm of the rate limiter
+type RateLimitAlgorithm int
+
+const (
+	// SlidingWindow weights the previous window count by the overlap with the sliding window (smooth limit)
+	SlidingWindow RateLimitAlgorithm = iota
+	// FixedWindow counts the requests in fixed time windows (allows bursts at the windows edges)
+	FixedWindow
+)
+
+// RateLimitKeyFunc extracts the rate limit key of the request, requests with empty key are not limited
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitOptions configures the rate limiting middleware
+type RateLimitOptions struct {
+	Limit     int                // Max number of requests per window (must be positive)
+	Window    time.Duration      // Window duration (default: 1 minute)
+	KeyFunc   RateLimitKeyFunc   // Request key extractor (default: RateLimitByIP)
+	Prefix    string             // Counters keys prefix, use different prefix per route for per route limits (default: "rate-limit")
+	Algorithm RateLimitAlgorithm // Counting algorithm (default: SlidingWindow)
+	FailOpen  bool               // Allow requests when the data cache fails (default: reject with 503)
+}
+
+// RateLimitByIP limits requests per client IP, the X-Forwarded-For header is used only for requests of trusted proxies
+// (see RemoteIP)
+func RateLimitByIP(r *http.Request) string {
+	return RemoteIP(r)
+}
+
+// RateLimitByHeader limits requests per header value (e.g. API key or tenant id header)
+func RateLimitByHeader(header string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// RateLimitByPrincipal limits requests per authenticated principal (see Authenticate)
+func RateLimitByPrincipal(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		return principal.Subject
+	}
+	return RemoteIP(r)
+}
+
+// RateLimit returns a middleware which rejects requests exceeding the limit (429 with Retry-After header)
+// The response includes the X-RateLimit-Limit and X-RateLimit-Remaining headers. Panics if the limit is not positive
+// (the zero value options would reject every request)
+func RateLimit(dc database.IDataCache, options RateLimitOptions) func(next http.Handler) http.Handler {
+	if options.Limit <= 0 {
+		panic("rest.RateLimit: limit must be positive")
+	}
+	if options.Window <= 0 {
+		options.Window = time.Minute
+	}
+	if options.KeyFunc == nil {
+		options.KeyFunc = RateLimitByIP
+	}
+	if len(options.Prefix) == 0 {
+		options.Prefix = "rate-limit"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := options.KeyFunc(r)
+			if len(key) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			count, retryAfter, err := countRequest(dc, options, key, time.Now())
+			if err != nil {
+				logger.Warn("rate limit counter error: %s", err.Error())
+				if options.FailOpen {
+					next.ServeHTTP(w, r)
+				} else {
+					writeErrorResponse(w, http.StatusServiceUnavailable, fmt.Errorf("rate limit unavailable"))
+				}
+				return
+			}
+
+			remaining := options.Limit - count
+			if remaining < 0 {
+				remaining = 0
+			}
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(options.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+			if count > options.Limit {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				writeErrorResponse(w, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// count the request and return the (weighted) number of requests in the window and the time until the window ends
+func countRequest(dc database.IDataCache, options RateLimitOptions, key string, now time.Time) (int, time.Duration, error) {
+	window := now.UnixNano() / int64(options.Window)
+	windowStart := time.Unix(0, window*int64(options.Window))
+	retryAfter := windowStart.Add(options.Window).Sub(now)
+
+	// The counter is kept for two windows since it is used as the previous window count by the sliding window
+	current, err := dc.Incr(fmt.Sprintf("%s:%s:%d", options.Prefix, key, window), 1, 2*options.Window)
+	if err != nil {
+		return 0, 0, err
+	}
+	if options.Algorithm == FixedWindow {
+		return int(current), retryAfter, nil
+	}
+
+	previous := int64(0)
+	if raw, fe := dc.GetRaw(fmt.Sprintf("%s:%s:%d", options.Prefix, key, window-1)); fe == nil {
+		previous, _ = strconv.ParseInt(string(raw), 10, 64)
+	}
+	overlap := 1 - float64(now.Sub(windowStart))/float64(options.Window)
+	return int(current) + int(float64(previous)*overlap), retryAfter, nil
+}
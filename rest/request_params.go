@@ -0,0 +1,117 @@
+// Helpers to extract parameters from REST requests
+//
+
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+)
+
+// GetObfuscatedIdParam extracts the path parameter (see http.ServeMux patterns, e.g. "/users/{id}") and decodes the
+// obfuscated ID to the internal numeric string ID
+func GetObfuscatedIdParam(r *http.Request, name string, obfuscator *entity.IdObfuscator) (string, error) {
+	value := r.PathValue(name)
+	if len(value) == 0 {
+		return "", fmt.Errorf("missing path parameter: %s", name)
+	}
+	return obfuscator.DecodeString(value)
+}
+
+// region Typed path parameters ----------------------------------------------------------------------------------------
+
+// The path parameter helpers extract the named path parameter (see http.ServeMux patterns, e.g. "/users/{id}") and
+// return the default value when the parameter is missing or can't be parsed to the requested type
+
+// GetStringParamValueFromPath returns the path parameter or the default value if missing
+func GetStringParamValueFromPath(r *http.Request, name string, defaultValue string) string {
+	if value := r.PathValue(name); len(value) > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+// GetIntParamValueFromPath returns the path parameter as int or the default value if missing or invalid
+func GetIntParamValueFromPath(r *http.Request, name string, defaultValue int) int {
+	if value, err := strconv.Atoi(r.PathValue(name)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetInt64ParamValueFromPath returns the path parameter as int64 or the default value if missing or invalid
+func GetInt64ParamValueFromPath(r *http.Request, name string, defaultValue int64) int64 {
+	if value, err := strconv.ParseInt(r.PathValue(name), 10, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetUint64ParamValueFromPath returns the path parameter as uint64 or the default value if missing or invalid
+func GetUint64ParamValueFromPath(r *http.Request, name string, defaultValue uint64) uint64 {
+	if value, err := strconv.ParseUint(r.PathValue(name), 10, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetFloatParamValueFromPath returns the path parameter as float64 or the default value if missing or invalid
+func GetFloatParamValueFromPath(r *http.Request, name string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(r.PathValue(name), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetBoolParamValueFromPath returns the path parameter as bool or the default value if missing or invalid
+func GetBoolParamValueFromPath(r *http.Request, name string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(r.PathValue(name)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetTimestampParamValueFromPath returns the path parameter (epoch milliseconds or RFC3339) as Timestamp or the
+// default value if missing or invalid
+func GetTimestampParamValueFromPath(r *http.Request, name string, defaultValue entity.Timestamp) entity.Timestamp {
+	if value, err := parseTimestampValue(r.PathValue(name), defaultValue); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// endregion
+
+// region Timestamp query parameters -----------------------------------------------------------------------------------
+
+// GetTimestampParamValue returns the query parameter (epoch milliseconds or RFC3339) as Timestamp or the default value
+// if missing or invalid
+func GetTimestampParamValue(r *http.Request, name string, defaultValue entity.Timestamp) entity.Timestamp {
+	if value, err := parseTimestampValue(r.URL.Query().Get(name), defaultValue); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// GetTimeFrameParams returns the time frame of the from / to query parameters (epoch milliseconds or RFC3339)
+// Missing from parameter is treated as the epoch start and missing to parameter as the current time
+func GetTimeFrameParams(r *http.Request, fromName, toName string) (entity.TimeFrame, error) {
+	values := r.URL.Query()
+	from, err := parseTimestampValue(values.Get(fromName), 0)
+	if err != nil {
+		return entity.TimeFrame{}, fmt.Errorf("invalid %s parameter: %s", fromName, values.Get(fromName))
+	}
+	to, err := parseTimestampValue(values.Get(toName), entity.Now())
+	if err != nil {
+		return entity.TimeFrame{}, fmt.Errorf("invalid %s parameter: %s", toName, values.Get(toName))
+	}
+	if from > to {
+		return entity.TimeFrame{}, fmt.Errorf("%s parameter is after %s parameter", fromName, toName)
+	}
+	return entity.NewTimeFrame(from, to), nil
+}
+
+// endregion
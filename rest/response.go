@@ -0,0 +1,146 @@
+// Standard REST response helpers
+//
+// Helpers to write consistent JSON payloads across services: plain JSON, error response, pagination envelope and
+// problem details (RFC 7807, application/problem+json)
+
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const (
+	ContentTypeJSON        = "application/json"
+	ContentTypeProblemJSON = "application/problem+json"
+)
+
+// region JSON response ------------------------------------------------------------------------------------------------
+
+// WriteJSON writes the value as JSON response with the status code
+func WriteJSON(w http.ResponseWriter, status int, value any) error {
+	return writeJSON(w, status, ContentTypeJSON, value)
+}
+
+// write the value as JSON with the content type and status code
+func writeJSON(w http.ResponseWriter, status int, contentType string, value any) error {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if value == nil {
+		return nil
+	}
+	return json.NewEncoder(w).Encode(value)
+}
+
+// endregion
+
+// region Error response -----------------------------------------------------------------------------------------------
+
+// ErrorResponse is the error response with the HTTP status code and optional details
+type ErrorResponse struct {
+	BaseRestResponse
+	Details any `json:"details,omitempty"` // Additional error details (e.g. validation errors per field)
+}
+
+// WriteError writes error response, the response code is the HTTP status code
+func WriteError(w http.ResponseWriter, status int, message string, details ...any) error {
+	res := &ErrorResponse{BaseRestResponse: BaseRestResponse{Code: status, Error: message}}
+	if len(details) == 1 {
+		res.Details = details[0]
+	} else if len(details) > 1 {
+		res.Details = details
+	}
+	return WriteJSON(w, status, res)
+}
+
+// write error response (BaseRestResponse format) as JSON with the status code
+func writeErrorResponse(w http.ResponseWriter, status int, err error) {
+	_ = WriteJSON(w, status, NewErrorResponse(err))
+}
+
+// endregion
+
+// region Pagination envelope ------------------------------------------------------------------------------------------
+
+// PageEnvelope is the pagination envelope of a list of items
+type PageEnvelope[T any] struct {
+	Items []T `json:"items"` // Items in the current page
+	Total int `json:"total"` // Total number of items in the query
+	Page  int `json:"page"`  // Current page number
+	Limit int `json:"limit"` // Max number of items per page
+	Pages int `json:"pages"` // Total number of pages
+}
+
+// NewPageEnvelope creates pagination envelope, nil items are returned as empty list
+func NewPageEnvelope[T any](items []T, total, page, limit int) *PageEnvelope[T] {
+	if items == nil {
+		items = make([]T, 0)
+	}
+	pages := 0
+	if limit > 0 {
+		pages = (total + limit - 1) / limit
+	}
+	return &PageEnvelope[T]{Items: items, Total: total, Page: page, Limit: limit, Pages: pages}
+}
+
+// WritePage writes the pagination envelope as JSON response (200)
+func WritePage[T any](w http.ResponseWriter, items []T, total, page, limit int) error {
+	return WriteJSON(w, http.StatusOK, NewPageEnvelope(items, total, page, limit))
+}
+
+// endregion
+
+// region Problem details ----------------------------------------------------------------------------------------------
+
+// ProblemDetails is the RFC 7807 problem details object
+type ProblemDetails struct {
+	Type       string         `json:"type,omitempty"`     // URI reference identifying the problem type (default: about:blank)
+	Title      string         `json:"title,omitempty"`    // Short summary of the problem type
+	Status     int            `json:"status,omitempty"`   // HTTP status code
+	Detail     string         `json:"detail,omitempty"`   // Explanation specific to this occurrence of the problem
+	Instance   string         `json:"instance,omitempty"` // URI reference identifying this occurrence of the problem
+	Extensions map[string]any `json:"-"`                  // Additional members
+}
+
+// NewProblem creates problem details with the status code, title defaults to the status text
+func NewProblem(status int, detail string) *ProblemDetails {
+	return &ProblemDetails{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// With adds extension member to the problem details
+func (p *ProblemDetails) With(key string, value any) *ProblemDetails {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// MarshalJSON writes the extension members at the top level of the problem object
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	type problem ProblemDetails
+	data, err := json.Marshal(problem(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return data, err
+	}
+
+	members := make(map[string]any)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+	if err = json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+// WriteProblem writes the problem details as application/problem+json response
+func WriteProblem(w http.ResponseWriter, problem *ProblemDetails) error {
+	status := problem.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	return writeJSON(w, status, ContentTypeProblemJSON, problem)
+}
+
+// endregion
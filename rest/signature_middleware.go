@@ -0,0 +1,55 @@
+// REST middleware to verify signed server-to-server requests
+//
+
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+const (
+	// DefaultSignatureMaxSkew is the default max difference between the request signing time and the server time
+	DefaultSignatureMaxSkew = 5 * time.Minute
+
+	// DefaultSignatureMaxBodySize is the default max size of a signed request body (the body is read to verify the signature)
+	DefaultSignatureMaxBodySize = 10 * 1024 * 1024
+)
+
+// SignatureOptions configures the signature verification middleware
+type SignatureOptions struct {
+	MaxSkew     time.Duration // Max difference between the signing time and the server time (default: 5 minutes, the check can't be disabled)
+	MaxBodySize int64         // Max size of the request body in bytes, larger requests are rejected with 413 (default: 10MB)
+}
+
+// VerifySignature returns a middleware which rejects requests without a valid HMAC signature (see utils.SignatureUtils)
+// Requests whose signing time differs from the server time by more than the max skew are rejected as well
+func VerifySignature(resolver utils.SecretResolver, options SignatureOptions) func(next http.Handler) http.Handler {
+	if options.MaxSkew <= 0 {
+		options.MaxSkew = DefaultSignatureMaxSkew
+	}
+	if options.MaxBodySize <= 0 {
+		options.MaxBodySize = DefaultSignatureMaxBodySize
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, options.MaxBodySize)
+			}
+			if err := utils.SignatureUtils().VerifyRequest(r, resolver, options.MaxSkew); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeErrorResponse(w, http.StatusRequestEntityTooLarge, err)
+				} else {
+					writeErrorResponse(w, http.StatusUnauthorized, err)
+				}
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
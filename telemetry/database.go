@@ -0,0 +1,375 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	. "github.com/go-yaaf/yaaf-common/entity"
+)
+
+// region Traced database ----------------------------------------------------------------------------------------------
+
+// TraceDatabase returns the database creating a client span (as a child of the span in the context) around each
+// entity operation and query execution. The database interface is not context aware, so the database is bound to the
+// context of the caller (e.g. the request context), similar to database.ForTenantContext
+// If the tracer is nil the global tracer is used
+func TraceDatabase(ctx context.Context, db database.IDatabase, tracer ITracer) database.IDatabase {
+	if td, ok := db.(*tracedDatabase); ok {
+		db = td.IDatabase
+	}
+	return &tracedDatabase{IDatabase: db, ctx: ctx, tracer: tracerOrGlobal(tracer)}
+}
+
+// tracedDatabase wraps database operations with spans
+type tracedDatabase struct {
+	database.IDatabase
+	ctx    context.Context
+	tracer ITracer
+}
+
+// start the operation span
+func (t *tracedDatabase) start(operation, table string) ISpan {
+	_, span := t.tracer.Start(t.ctx, "db."+operation, SpanKindClient)
+	span.SetAttribute("db.operation", operation)
+	if len(table) > 0 {
+		span.SetAttribute("db.table", table)
+	}
+	return span
+}
+
+// end the operation span with the error
+func endSpan(span ISpan, err error) {
+	span.SetError(err)
+	span.End()
+}
+
+// get the table name of the entity factory
+func tableOf(factory EntityFactory) string {
+	if factory == nil {
+		return ""
+	}
+	return factory().TABLE()
+}
+
+// get the table name of the first entity
+func tableOfList(entities []Entity) string {
+	if len(entities) == 0 || entities[0] == nil {
+		return ""
+	}
+	return entities[0].TABLE()
+}
+
+// CloneDatabase Returns a clone of the database bound to the same context and tracer
+func (t *tracedDatabase) CloneDatabase() (database.IDatabase, error) {
+	clone, err := t.IDatabase.CloneDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return TraceDatabase(t.ctx, clone, t.tracer), nil
+}
+
+// Get a single entity by ID
+func (t *tracedDatabase) Get(factory EntityFactory, entityID string, keys ...string) (result Entity, err error) {
+	span := t.start("Get", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.Get(factory, entityID, keys...)
+}
+
+// List Get multiple entities by IDs
+func (t *tracedDatabase) List(factory EntityFactory, entityIDs []string, keys ...string) (list []Entity, err error) {
+	span := t.start("List", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.List(factory, entityIDs, keys...)
+}
+
+// Exists Check if entity exists by ID
+func (t *tracedDatabase) Exists(factory EntityFactory, entityID string, keys ...string) (result bool, err error) {
+	span := t.start("Exists", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.Exists(factory, entityID, keys...)
+}
+
+// Insert new entity
+func (t *tracedDatabase) Insert(entity Entity) (added Entity, err error) {
+	span := t.start("Insert", tableOfList([]Entity{entity}))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.Insert(entity)
+}
+
+// Update existing entity
+func (t *tracedDatabase) Update(entity Entity) (updated Entity, err error) {
+	span := t.start("Update", tableOfList([]Entity{entity}))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.Update(entity)
+}
+
+// Upsert Update entity or create it if it does not exist
+func (t *tracedDatabase) Upsert(entity Entity) (updated Entity, err error) {
+	span := t.start("Upsert", tableOfList([]Entity{entity}))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.Upsert(entity)
+}
+
+// Delete entity by id
+func (t *tracedDatabase) Delete(factory EntityFactory, entityID string, keys ...string) (err error) {
+	span := t.start("Delete", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.Delete(factory, entityID, keys...)
+}
+
+// BulkInsert Insert multiple entities
+func (t *tracedDatabase) BulkInsert(entities []Entity) (affected int64, err error) {
+	span := t.start("BulkInsert", tableOfList(entities))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.BulkInsert(entities)
+}
+
+// BulkUpdate Update multiple entities
+func (t *tracedDatabase) BulkUpdate(entities []Entity) (affected int64, err error) {
+	span := t.start("BulkUpdate", tableOfList(entities))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.BulkUpdate(entities)
+}
+
+// BulkUpsert Upsert multiple entities
+func (t *tracedDatabase) BulkUpsert(entities []Entity) (affected int64, err error) {
+	span := t.start("BulkUpsert", tableOfList(entities))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.BulkUpsert(entities)
+}
+
+// BulkDelete Delete multiple entities by IDs
+func (t *tracedDatabase) BulkDelete(factory EntityFactory, entityIDs []string, keys ...string) (affected int64, err error) {
+	span := t.start("BulkDelete", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.BulkDelete(factory, entityIDs, keys...)
+}
+
+// SetField Update single field of the document
+func (t *tracedDatabase) SetField(factory EntityFactory, entityID string, field string, value any, keys ...string) (err error) {
+	span := t.start("SetField", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.SetField(factory, entityID, field, value, keys...)
+}
+
+// SetFields Update some fields of the document
+func (t *tracedDatabase) SetFields(factory EntityFactory, entityID string, fields map[string]any, keys ...string) (err error) {
+	span := t.start("SetFields", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.SetFields(factory, entityID, fields, keys...)
+}
+
+// BulkSetFields Update specific field of multiple entities
+func (t *tracedDatabase) BulkSetFields(factory EntityFactory, field string, values map[string]any, keys ...string) (affected int64, err error) {
+	span := t.start("BulkSetFields", tableOf(factory))
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.BulkSetFields(factory, field, values, keys...)
+}
+
+// ExecuteSQL Execute SQL command
+func (t *tracedDatabase) ExecuteSQL(sql string, args ...any) (affected int64, err error) {
+	span := t.start("ExecuteSQL", "")
+	span.SetAttribute("db.statement", sql)
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.ExecuteSQL(sql, args...)
+}
+
+// ExecuteQuery Execute native SQL query
+func (t *tracedDatabase) ExecuteQuery(source, sql string, args ...any) (out []Json, err error) {
+	span := t.start("ExecuteQuery", source)
+	span.SetAttribute("db.statement", sql)
+	defer func() { endSpan(span, err) }()
+	return t.IDatabase.ExecuteQuery(source, sql, args...)
+}
+
+// Query returns query builder creating span around the query execution
+func (t *tracedDatabase) Query(factory EntityFactory) database.IQuery {
+	return &tracedQuery{IQuery: t.IDatabase.Query(factory), db: t, table: tableOf(factory)}
+}
+
+// endregion
+
+// region Traced query -------------------------------------------------------------------------------------------------
+
+// tracedQuery wraps the query execution with spans
+type tracedQuery struct {
+	database.IQuery
+	db    *tracedDatabase
+	table string
+}
+
+// wrap the builder result to keep the tracing along the builder chain
+func (q *tracedQuery) wrap(query database.IQuery) database.IQuery {
+	q.IQuery = query
+	return q
+}
+
+// start the query execution span
+func (q *tracedQuery) start(operation string) ISpan {
+	span := q.db.start("query."+operation, q.table)
+	span.SetAttribute("db.statement", q.IQuery.ToString())
+	return span
+}
+
+// Apply adds callback to apply on each result entity in the query
+func (q *tracedQuery) Apply(cb func(in Entity) Entity) database.IQuery {
+	return q.wrap(q.IQuery.Apply(cb))
+}
+
+// Filter adds a single field filter
+func (q *tracedQuery) Filter(filter database.QueryFilter) database.IQuery {
+	return q.wrap(q.IQuery.Filter(filter))
+}
+
+// Range adds time frame filter on specific time field
+func (q *tracedQuery) Range(field string, from Timestamp, to Timestamp) database.IQuery {
+	return q.wrap(q.IQuery.Range(field, from, to))
+}
+
+// MatchAll adds a list of filters, all of them should be satisfied
+func (q *tracedQuery) MatchAll(filters ...database.QueryFilter) database.IQuery {
+	return q.wrap(q.IQuery.MatchAll(filters...))
+}
+
+// MatchAny adds a list of filters, any of them should be satisfied
+func (q *tracedQuery) MatchAny(filters ...database.QueryFilter) database.IQuery {
+	return q.wrap(q.IQuery.MatchAny(filters...))
+}
+
+// And adds a boolean condition, all the conditions should be satisfied
+func (q *tracedQuery) And(conditions ...database.Condition) database.IQuery {
+	return q.wrap(q.IQuery.And(conditions...))
+}
+
+// Or adds a boolean condition, any of the conditions should be satisfied
+func (q *tracedQuery) Or(conditions ...database.Condition) database.IQuery {
+	return q.wrap(q.IQuery.Or(conditions...))
+}
+
+// Not adds a boolean condition, the condition should not be satisfied
+func (q *tracedQuery) Not(condition database.Condition) database.IQuery {
+	return q.wrap(q.IQuery.Not(condition))
+}
+
+// Sort adds sort order by field
+func (q *tracedQuery) Sort(sort string) database.IQuery {
+	return q.wrap(q.IQuery.Sort(sort))
+}
+
+// Page sets the requested page number
+func (q *tracedQuery) Page(page int) database.IQuery {
+	return q.wrap(q.IQuery.Page(page))
+}
+
+// Limit sets the page size limit
+func (q *tracedQuery) Limit(limit int) database.IQuery {
+	return q.wrap(q.IQuery.Limit(limit))
+}
+
+// List executes the query
+func (q *tracedQuery) List(entityIDs []string, keys ...string) (out []Entity, err error) {
+	span := q.start("List")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.List(entityIDs, keys...)
+}
+
+// Find executes the query
+func (q *tracedQuery) Find(keys ...string) (out []Entity, total int64, err error) {
+	span := q.start("Find")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.Find(keys...)
+}
+
+// Select executes the query
+func (q *tracedQuery) Select(fields ...string) (out []Json, err error) {
+	span := q.start("Select")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.Select(fields...)
+}
+
+// Count executes the query
+func (q *tracedQuery) Count(keys ...string) (total int64, err error) {
+	span := q.start("Count")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.Count(keys...)
+}
+
+// Aggregation executes the query
+func (q *tracedQuery) Aggregation(field string, function database.AggFunc, keys ...string) (value float64, err error) {
+	span := q.start("Aggregation")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.Aggregation(field, function, keys...)
+}
+
+// GroupCount executes the query
+func (q *tracedQuery) GroupCount(field string, keys ...string) (out map[any]int64, total int64, err error) {
+	span := q.start("GroupCount")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.GroupCount(field, keys...)
+}
+
+// GroupAggregation executes the query
+func (q *tracedQuery) GroupAggregation(field string, function database.AggFunc, keys ...string) (out map[any]Tuple[int64, float64], total float64, err error) {
+	span := q.start("GroupAggregation")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.GroupAggregation(field, function, keys...)
+}
+
+// Histogram executes the query
+func (q *tracedQuery) Histogram(field string, function database.AggFunc, timeField string, interval time.Duration, keys ...string) (out map[Timestamp]Tuple[int64, float64], total float64, err error) {
+	span := q.start("Histogram")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.Histogram(field, function, timeField, interval, keys...)
+}
+
+// Histogram2D executes the query
+func (q *tracedQuery) Histogram2D(field string, function database.AggFunc, dim, timeField string, interval time.Duration, keys ...string) (out map[Timestamp]map[any]Tuple[int64, float64], total float64, err error) {
+	span := q.start("Histogram2D")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.Histogram2D(field, function, dim, timeField, interval, keys...)
+}
+
+// FindSingle executes the query
+func (q *tracedQuery) FindSingle(keys ...string) (entity Entity, err error) {
+	span := q.start("FindSingle")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.FindSingle(keys...)
+}
+
+// GetMap executes the query
+func (q *tracedQuery) GetMap(keys ...string) (out map[string]Entity, err error) {
+	span := q.start("GetMap")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.GetMap(keys...)
+}
+
+// GetIDs executes the query
+func (q *tracedQuery) GetIDs(keys ...string) (out []string, err error) {
+	span := q.start("GetIDs")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.GetIDs(keys...)
+}
+
+// Delete executes the query
+func (q *tracedQuery) Delete(keys ...string) (total int64, err error) {
+	span := q.start("Delete")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.Delete(keys...)
+}
+
+// SetField executes the query
+func (q *tracedQuery) SetField(field string, value any, keys ...string) (total int64, err error) {
+	span := q.start("SetField")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.SetField(field, value, keys...)
+}
+
+// SetFields executes the query
+func (q *tracedQuery) SetFields(fields map[string]any, keys ...string) (total int64, err error) {
+	span := q.start("SetFields")
+	defer func() { endSpan(span, err) }()
+	return q.IQuery.SetFields(fields, keys...)
+}
+
+// endregion
@@ -0,0 +1,74 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/go-yaaf/yaaf-common/rest/client"
+)
+
+// region REST handlers ------------------------------------------------------------------------------------------------
+
+// HttpServerTracing returns a middleware creating a server span for each request (as a child of the incoming
+// traceparent header) and propagating the span in the request context. Responses with status 5xx are marked as error
+// The span is named by the request method to keep the span names bounded (the path is set as attribute)
+// If the tracer is nil the global tracer is used
+func HttpServerTracing(tracer ITracer) rest.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := Extract(r.Context(), r.Header.Get)
+			ctx, span := tracerOrGlobal(tracer).Start(ctx, fmt.Sprintf("HTTP %s", r.Method), SpanKindServer)
+			defer span.End()
+
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.path", r.URL.Path)
+			if requestId := rest.RequestIdFromContext(ctx); len(requestId) > 0 {
+				span.SetAttribute("http.request_id", requestId)
+			}
+
+			rw := rest.NewStatusResponseWriter(w)
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			span.SetAttribute("http.status_code", rw.Status())
+			if rw.Status() >= http.StatusInternalServerError {
+				span.SetError(fmt.Errorf("%d %s", rw.Status(), http.StatusText(rw.Status())))
+			}
+		})
+	}
+}
+
+// endregion
+
+// region REST client --------------------------------------------------------------------------------------------------
+
+// HttpClientTracing returns a REST client middleware creating a client span for each request attempt (as a child of
+// the span in the request context) and injecting the traceparent header to the outgoing request
+// If the tracer is nil the global tracer is used
+func HttpClientTracing(tracer ITracer) client.Middleware {
+	return func(next client.RoundTripFunc) client.RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			ctx, span := tracerOrGlobal(tracer).Start(req.Context(), fmt.Sprintf("HTTP %s", req.Method), SpanKindClient)
+			defer span.End()
+
+			span.SetAttribute("http.method", req.Method)
+			span.SetAttribute("http.url", req.URL.String())
+
+			req = req.WithContext(ctx)
+			Inject(ctx, req.Header.Set)
+
+			resp, err := next(req)
+			if err != nil {
+				span.SetError(err)
+				return resp, err
+			}
+			span.SetAttribute("http.status_code", resp.StatusCode)
+			if resp.StatusCode >= http.StatusInternalServerError {
+				span.SetError(fmt.Errorf("%s", resp.Status))
+			}
+			return resp, nil
+		}
+	}
+}
+
+// endregion
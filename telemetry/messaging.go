@@ -0,0 +1,112 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+
+	"github.com/go-yaaf/yaaf-common/messaging"
+)
+
+// region Message bus --------------------------------------------------------------------------------------------------
+
+// headerSetter is implemented by messages supporting headers modification (e.g. BaseMessage)
+type headerSetter interface {
+	SetHeader(key, value string)
+}
+
+// headersSetter is implemented by messages supporting headers replacement (e.g. BaseMessage)
+type headersSetter interface {
+	SetHeaders(headers map[string]string)
+}
+
+// InjectMessage writes the trace context of the current span to the message headers, use it before publishing the
+// message to link the publish span (see MessageTracing) to the caller span
+func InjectMessage(ctx context.Context, message messaging.IMessage) {
+	if setter, ok := message.(headerSetter); ok {
+		Inject(ctx, setter.SetHeader)
+	}
+}
+
+// ExtractMessage returns a copy of the context carrying the trace context of the message headers, in a subscription
+// callback wrapped by MessageTracing the trace context is the consumer span
+func ExtractMessage(ctx context.Context, message messaging.IMessage) context.Context {
+	return Extract(ctx, func(key string) string {
+		return message.Headers()[key]
+	})
+}
+
+// MessageTracing returns a message bus interceptor creating a producer span for each published message and a consumer
+// span for each consumed message, the trace context is propagated by the traceparent message header
+// If the tracer is nil the global tracer is used
+func MessageTracing(tracer ITracer) messaging.MessageInterceptor {
+	return messaging.NewMessageInterceptor(
+		func(next messaging.PublishFunc) messaging.PublishFunc {
+			return func(messages ...messaging.IMessage) error {
+				spans := make([]ISpan, 0, len(messages))
+				for _, message := range messages {
+					ctx, span := tracerOrGlobal(tracer).Start(ExtractMessage(context.Background(), message), fmt.Sprintf("publish %s", message.Topic()), SpanKindProducer)
+					setMessageAttributes(span, message)
+					InjectMessage(ctx, message)
+					spans = append(spans, span)
+				}
+				err := next(messages...)
+				for _, span := range spans {
+					span.SetError(err)
+					span.End()
+				}
+				return err
+			}
+		},
+		func(next messaging.SubscriptionCallback) messaging.SubscriptionCallback {
+			return func(message messaging.IMessage) bool {
+				ctx, span := tracerOrGlobal(tracer).Start(ExtractMessage(context.Background(), message), fmt.Sprintf("consume %s", message.Topic()), SpanKindConsumer)
+				defer span.End()
+				setMessageAttributes(span, message)
+
+				// Expose the consumer span to the callback (see ExtractMessage) on a copy of the message, the delivered
+				// message may be shared by other subscribers
+				if copied, ok := copyMessage(message); ok {
+					InjectMessage(ctx, copied)
+					message = copied
+				}
+				result := next(message)
+				if !result {
+					span.SetError(fmt.Errorf("message rejected"))
+				}
+				return result
+			}
+		},
+	)
+}
+
+// shallow copy of the message (pointer to struct) with its own copy of the headers, returns false if the message can't
+// be copied
+func copyMessage(message messaging.IMessage) (messaging.IMessage, bool) {
+	v := reflect.ValueOf(message)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return message, false
+	}
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+
+	copied, ok := cp.Interface().(messaging.IMessage)
+	setter, canSet := cp.Interface().(headersSetter)
+	if !ok || !canSet {
+		return message, false
+	}
+	setter.SetHeaders(maps.Clone(message.Headers()))
+	return copied, true
+}
+
+// set the message span attributes
+func setMessageAttributes(span ISpan, message messaging.IMessage) {
+	span.SetAttribute("messaging.destination", message.Topic())
+	span.SetAttribute("messaging.opcode", message.OpCode())
+	if sessionId := message.SessionId(); len(sessionId) > 0 {
+		span.SetAttribute("messaging.session_id", sessionId)
+	}
+}
+
+// endregion
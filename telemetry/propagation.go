@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TraceParentHeader is the W3C Trace Context header (HTTP header and message header)
+const TraceParentHeader = "traceparent"
+
+// region Propagation --------------------------------------------------------------------------------------------------
+
+// FormatTraceParent formats the span context as W3C traceparent value: 00-<trace id>-<span id>-<flags>
+func FormatTraceParent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceId, sc.SpanId, flags)
+}
+
+// ParseTraceParent parses W3C traceparent value
+func ParseTraceParent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", value)
+	}
+	version, traceId, spanId, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent version: %s", value)
+	}
+	if !isHex(traceId, 32) || !isHex(spanId, 16) || !isHex(flags, 2) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent: %s", value)
+	}
+	if strings.Trim(traceId, "0") == "" || strings.Trim(spanId, "0") == "" {
+		return SpanContext{}, fmt.Errorf("invalid traceparent (zero id): %s", value)
+	}
+	return SpanContext{TraceId: traceId, SpanId: spanId, Sampled: (flags[1]-'0')&1 == 1, Remote: true}, nil
+}
+
+// Inject writes the trace context of the current span to the carrier (e.g. http.Header.Set or message SetHeader)
+func Inject(ctx context.Context, set func(key, value string)) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		set(TraceParentHeader, FormatTraceParent(sc))
+	}
+}
+
+// Extract reads the trace context from the carrier (e.g. http.Header.Get or message Header) and returns a copy of the
+// context carrying the remote parent span context, the context is returned as is if the trace context is missing
+func Extract(ctx context.Context, get func(key string) string) context.Context {
+	value := get(TraceParentHeader)
+	if len(value) == 0 {
+		return ctx
+	}
+	sc, err := ParseTraceParent(value)
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// check that the value is lowercase hex of the given length
+func isHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, c := range value {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// endregion
@@ -0,0 +1,285 @@
+// Tracing abstraction for spans around database queries, message bus publish / consume and REST handlers
+//
+// The package does not depend on a specific tracing SDK: ITracer can be implemented by an adapter of the application
+// tracing provider (e.g. OpenTelemetry) and registered with SetTracer. Trace context is propagated across processes
+// using the W3C Trace Context "traceparent" header (HTTP headers and message headers).
+
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// region Tracer interfaces --------------------------------------------------------------------------------------------
+
+// SpanKind describes the relationship of the span to its parent / remote peer
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota // Internal operation
+	SpanKindServer                   // Handling of incoming request (e.g. REST handler)
+	SpanKindClient                   // Outgoing request (e.g. HTTP client, database query)
+	SpanKindProducer                 // Message publish
+	SpanKindConsumer                 // Message consume
+)
+
+// SpanContext identifies the span across process boundaries
+type SpanContext struct {
+	TraceId string // 32 lowercase hex characters trace id
+	SpanId  string // 16 lowercase hex characters span id
+	Sampled bool   // Sampled flag
+	Remote  bool   // The span context was extracted from incoming request / message
+}
+
+// IsValid checks that the trace id and span id are set
+func (sc SpanContext) IsValid() bool {
+	return len(sc.TraceId) == 32 && len(sc.SpanId) == 16
+}
+
+// ISpan is a single timed operation within a trace
+type ISpan interface {
+
+	// Context returns the span context (trace id and span id)
+	Context() SpanContext
+
+	// SetAttribute sets the span attribute
+	SetAttribute(key string, value any)
+
+	// SetError records the error on the span (nil error is ignored)
+	SetError(err error)
+
+	// End completes the span
+	End()
+}
+
+// ITracer creates spans
+type ITracer interface {
+
+	// Start creates a span as a child of the span carried by the context (or the remote span context, see Extract)
+	// and returns a copy of the context carrying the new span
+	Start(ctx context.Context, name string, kind SpanKind) (context.Context, ISpan)
+}
+
+// endregion
+
+// region Global tracer ------------------------------------------------------------------------------------------------
+
+var globalTracer atomic.Value
+
+// SetTracer sets the global tracer used when no explicit tracer is provided (nil restores the no-op tracer)
+func SetTracer(tracer ITracer) {
+	if tracer == nil {
+		tracer = NoopTracer()
+	}
+	globalTracer.Store(tracerHolder{tracer})
+}
+
+// GetTracer returns the global tracer (no-op tracer by default)
+func GetTracer() ITracer {
+	if holder, ok := globalTracer.Load().(tracerHolder); ok {
+		return holder.tracer
+	}
+	return NoopTracer()
+}
+
+// tracerHolder keeps the concrete type stored in the atomic value consistent
+type tracerHolder struct {
+	tracer ITracer
+}
+
+// resolve the tracer or the global tracer
+func tracerOrGlobal(tracer ITracer) ITracer {
+	if tracer == nil {
+		return GetTracer()
+	}
+	return tracer
+}
+
+// endregion
+
+// region Span context -------------------------------------------------------------------------------------------------
+
+type spanContextKey struct{}
+
+type remoteContextKey struct{}
+
+// ContextWithSpan returns a copy of the context carrying the span, the trace id and span id are attached to the
+// context logger (see logger.FromContext)
+func ContextWithSpan(ctx context.Context, span ISpan) context.Context {
+	if sc := span.Context(); sc.IsValid() {
+		ctx = logger.WithContext(ctx, logger.F(logger.TraceIdKey, sc.TraceId), logger.F(logger.SpanIdKey, sc.SpanId))
+	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span carried by the context
+func SpanFromContext(ctx context.Context) (ISpan, bool) {
+	span, ok := ctx.Value(spanContextKey{}).(ISpan)
+	return span, ok
+}
+
+// ContextWithRemoteSpanContext returns a copy of the context carrying the span context of the remote parent
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the current span, or the remote parent span context if no span
+// was started in the context
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span, ok := SpanFromContext(ctx); ok {
+		if sc := span.Context(); sc.IsValid() {
+			return sc, true
+		}
+	}
+	sc, ok := ctx.Value(remoteContextKey{}).(SpanContext)
+	return sc, ok && sc.IsValid()
+}
+
+// endregion
+
+// region No-op tracer -------------------------------------------------------------------------------------------------
+
+// noopTracer creates spans which do nothing but keep propagating the parent span context
+type noopTracer struct{}
+
+// NoopTracer returns tracer which does not record spans, the incoming trace context is still propagated
+func NoopTracer() ITracer {
+	return noopTracer{}
+}
+
+// Start returns the context with no-op span carrying the parent span context
+func (noopTracer) Start(ctx context.Context, _ string, _ SpanKind) (context.Context, ISpan) {
+	sc, _ := SpanContextFromContext(ctx)
+	span := noopSpan{sc: sc}
+	return ContextWithSpan(ctx, span), span
+}
+
+// noopSpan does nothing
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (s noopSpan) Context() SpanContext         { return s.sc }
+func (s noopSpan) SetAttribute(_ string, _ any) {}
+func (s noopSpan) SetError(_ error)             {}
+func (s noopSpan) End()                         {}
+
+// endregion
+
+// region In-memory tracer ---------------------------------------------------------------------------------------------
+
+// SpanData is the recorded data of a completed span
+type SpanData struct {
+	Name       string         // Span name
+	Kind       SpanKind       // Span kind
+	Context    SpanContext    // Span context
+	ParentId   string         // Parent span id (empty for root span)
+	Start      time.Time      // Start time
+	End        time.Time      // End time
+	Attributes map[string]any // Span attributes
+	Error      error          // Recorded error
+}
+
+// InMemoryTracer records the completed spans in memory (for testing and debugging)
+type InMemoryTracer struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryTracer creates in-memory tracer
+func NewInMemoryTracer() *InMemoryTracer {
+	return &InMemoryTracer{spans: make([]SpanData, 0)}
+}
+
+// Start creates a recording span
+func (t *InMemoryTracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, ISpan) {
+	span := &inMemorySpan{tracer: t, data: SpanData{Name: name, Kind: kind, Start: time.Now(), Attributes: make(map[string]any)}}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.data.Context = SpanContext{TraceId: parent.TraceId, SpanId: newId(8), Sampled: parent.Sampled}
+		span.data.ParentId = parent.SpanId
+	} else {
+		span.data.Context = SpanContext{TraceId: newId(16), SpanId: newId(8), Sampled: true}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// Spans returns the completed spans by the order of completion
+func (t *InMemoryTracer) Spans() []SpanData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]SpanData(nil), t.spans...)
+}
+
+// Reset removes the recorded spans
+func (t *InMemoryTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = t.spans[:0]
+}
+
+// inMemorySpan records the span data on End
+type inMemorySpan struct {
+	mu     sync.Mutex
+	tracer *InMemoryTracer
+	data   SpanData
+	ended  bool
+}
+
+// Context returns the span context
+func (s *inMemorySpan) Context() SpanContext {
+	return s.data.Context
+}
+
+// SetAttribute sets the span attribute
+func (s *inMemorySpan) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes[key] = value
+}
+
+// SetError records the error on the span
+func (s *inMemorySpan) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err
+}
+
+// End completes the span, subsequent calls are ignored
+func (s *inMemorySpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.tracer.spans = append(s.tracer.spans, data)
+}
+
+// generate random hex id of the given size in bytes
+func newId(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%0*x", size*2, time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// endregion
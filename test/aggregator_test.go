@@ -1,11 +1,17 @@
 package test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/go-yaaf/yaaf-common/entity"
 	. "github.com/go-yaaf/yaaf-common/utils/Aggregator"
 )
@@ -41,3 +47,155 @@ func TestAggregator(t *testing.T) {
 		time.Sleep(time.Duration(sec) * time.Second)
 	}
 }
+
+func TestAggregator_FlushAndClose(t *testing.T) {
+	var mu sync.Mutex
+	bulks := make([][]int, 0)
+	collect := func(bulk []int) {
+		mu.Lock()
+		bulks = append(bulks, bulk)
+		mu.Unlock()
+	}
+
+	agg := NewAggregator[int](10, time.Hour)
+	agg.SetBulkCallback(collect)
+
+	for i := 0; i < 13; i++ {
+		agg.Add(i)
+	}
+	assert.Equal(t, 3, agg.Count())
+
+	// Flush delivers the pending items synchronously
+	agg.Flush()
+	assert.Equal(t, 0, agg.Count())
+	require.Len(t, bulks, 2)
+	assert.Equal(t, []int{10, 11, 12}, bulks[1])
+
+	// Close drains the pending items
+	agg.Add(13)
+	agg.Close()
+	agg.Close()
+	require.Len(t, bulks, 3)
+	assert.Equal(t, []int{13}, bulks[2])
+}
+
+func TestAggregator_MaxBytes(t *testing.T) {
+	bulks := make([][]string, 0)
+
+	agg := NewAggregator[string](100, time.Hour)
+	defer agg.Close()
+	agg.SetBulkCallback(func(bulk []string) { bulks = append(bulks, bulk) })
+	agg.SetMaxBytes(10, func(item string) int { return len(item) })
+
+	agg.Add("abcd")
+	agg.Add("efgh")
+	assert.Equal(t, 8, agg.Bytes())
+	assert.Empty(t, bulks)
+
+	agg.Add("ijk")
+	require.Len(t, bulks, 1)
+	assert.Equal(t, []string{"abcd", "efgh", "ijk"}, bulks[0])
+	assert.Equal(t, 0, agg.Bytes())
+}
+
+func TestAggregator_Retry(t *testing.T) {
+	failure := errors.New("failure")
+	attempts := 0
+	var failed []int
+	var failedErr error
+
+	agg := NewAggregator[int](2, time.Hour)
+	defer agg.Close()
+	agg.SetRetry(2, time.Millisecond)
+	agg.SetErrorCallback(func(bulk []int, err error) {
+		failed, failedErr = bulk, err
+	})
+
+	// Succeeds on the second attempt
+	agg.SetBulkHandler(func(ctx context.Context, bulk []int) error {
+		attempts++
+		if attempts < 2 {
+			return failure
+		}
+		return nil
+	})
+	agg.Add(1)
+	agg.Add(2)
+	assert.Equal(t, 2, attempts)
+	assert.Nil(t, failed)
+
+	// Fails after all the retries
+	attempts = 0
+	agg.SetBulkHandler(func(ctx context.Context, bulk []int) error {
+		attempts++
+		return failure
+	})
+	agg.Add(3)
+	agg.Add(4)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []int{3, 4}, failed)
+	assert.ErrorIs(t, failedErr, failure)
+}
+
+func TestAggregator_ContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	flushed := make(chan []int, 1)
+
+	agg := NewAggregatorWithContext[int](ctx, 10, time.Hour)
+	agg.SetBulkHandler(func(ctx context.Context, bulk []int) error {
+		// The drain on cancellation is not canceled
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		flushed <- bulk
+		return nil
+	})
+	agg.Add(1)
+	agg.Add(2)
+	cancel()
+
+	select {
+	case bulk := <-flushed:
+		assert.Equal(t, []int{1, 2}, bulk)
+	case <-time.After(time.Second):
+		t.Fatal("pending items were not flushed on context cancellation")
+	}
+	agg.Close()
+}
+
+func TestAggregator_ConcurrentSetters(t *testing.T) {
+	var mutex sync.Mutex
+	total := 0
+	handler := func(ctx context.Context, bulk []int) error {
+		mutex.Lock()
+		total += len(bulk)
+		mutex.Unlock()
+		return nil
+	}
+
+	// Setters are called while the timeout process is delivering bulks (run with -race)
+	agg := NewAggregator[int](5, time.Millisecond)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			agg.SetBulkHandler(handler)
+			agg.SetRetry(1, time.Millisecond)
+			agg.SetErrorCallback(func(bulk []int, err error) {})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			agg.Add(i)
+		}
+	}()
+	wg.Wait()
+	agg.SetBulkHandler(handler)
+	agg.Close()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.LessOrEqual(t, total, 100)
+}
@@ -3,11 +3,18 @@
 package test
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-yaaf/yaaf-common/config"
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/entity"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBaseConfig_ReadConfig(t *testing.T) {
@@ -34,3 +41,183 @@ func TestBaseConfig_ReadConfig(t *testing.T) {
 	assert.Equal(t, true, config.Get().GetBoolParamValueOrDefault("KEY_2", false))
 	assert.Equal(t, int64(456), config.Get().GetInt64ParamValueOrDefault("KEY_3", 100))
 }
+
+func TestBaseConfig_TypedAccessors(t *testing.T) {
+	config.Get().AddConfigVar("CFG_TIMEOUT", "1m30s")
+	config.Get().AddConfigVar("CFG_BAD_TIMEOUT", "90")
+	config.Get().AddConfigVar("CFG_RATIO", "0.75")
+	config.Get().AddConfigVar("CFG_HOSTS", "host-1, host-2,,host-3 ")
+
+	assert.Equal(t, 90*time.Second, config.Get().GetDurationParamValueOrDefault("CFG_TIMEOUT", time.Second))
+	assert.Equal(t, time.Second, config.Get().GetDurationParamValueOrDefault("CFG_BAD_TIMEOUT", time.Second))
+	assert.Equal(t, time.Second, config.Get().GetDurationParamValueOrDefault("CFG_MISSING", time.Second))
+	assert.Equal(t, 0.75, config.Get().GetFloatParamValueOrDefault("CFG_RATIO", 1))
+	assert.Equal(t, 1.5, config.Get().GetFloatParamValueOrDefault("CFG_MISSING", 1.5))
+	assert.Equal(t, []string{"host-1", "host-2", "host-3"}, config.Get().GetStringSliceParamValueOrDefault("CFG_HOSTS", nil))
+	assert.Equal(t, []string{"a"}, config.Get().GetStringSliceParamValueOrDefault("CFG_MISSING", []string{"a"}))
+	assert.Equal(t, time.Duration(config.Get().HttpReadTimeoutMs())*time.Millisecond, config.Get().HttpReadTimeout())
+}
+
+func TestBaseConfig_Load(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "config.yaml")
+	jsonFile := filepath.Join(dir, "config.json")
+	envFile := filepath.Join(dir, ".env")
+
+	require.NoError(t, os.WriteFile(yamlFile, []byte("loader:\n  uri: yaml-uri\n  hosts: [host-1, host-2]\n  size: 10\n  file: yaml\n"), 0644))
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"loader": {"size": 1000000, "file": "json"}}`), 0644))
+	require.NoError(t, os.WriteFile(envFile, []byte("# comment\nexport LOADER_URI=\"env-uri\"\nLOADER_SECRET=env # inline comment\nLOADER_ENV_ONLY='quoted value'\n"), 0644))
+	t.Setenv("LOADER_SIZE", "20")
+
+	remote := config.RemoteProviderFunc("remote", func() (map[string]string, error) {
+		return map[string]string{"LOADER_SECRET": "remote-secret"}, nil
+	})
+
+	err := config.Get().Load(config.LoaderOptions{
+		Files:           []string{yamlFile, jsonFile, filepath.Join(dir, "missing.yaml")},
+		EnvFiles:        []string{envFile},
+		RemoteProviders: []config.IRemoteConfigProvider{remote},
+		IgnoreMissing:   true,
+	})
+	require.NoError(t, err)
+
+	cfg := config.Get()
+	assert.Equal(t, "env-uri", cfg.GetStringParamValueOrDefault("LOADER_URI", ""))
+	assert.Equal(t, []string{"host-1", "host-2"}, cfg.GetStringSliceParamValueOrDefault("LOADER_HOSTS", nil))
+	assert.Equal(t, "json", cfg.GetStringParamValueOrDefault("LOADER_FILE", ""))
+	assert.Equal(t, "remote-secret", cfg.GetStringParamValueOrDefault("LOADER_SECRET", ""))
+	assert.Equal(t, "quoted value", cfg.GetStringParamValueOrDefault("LOADER_ENV_ONLY", ""))
+	assert.Equal(t, 20, cfg.GetIntParamValueOrDefault("LOADER_SIZE", 0))
+
+	assert.Error(t, cfg.LoadFile(filepath.Join(dir, "missing.yaml")))
+	assert.Error(t, cfg.LoadRemote(config.RemoteProviderFunc("failed", func() (map[string]string, error) {
+		return nil, os.ErrPermission
+	})))
+}
+
+type bindCacheConfig struct {
+	Uri string        `env:"URI" default:"redis://localhost:6379"`
+	TTL time.Duration `env:"TTL" default:"5m"`
+}
+
+type bindServiceConfig struct {
+	ListenAddr  string          `env:"BIND_LISTEN_ADDR" default:":8080"`
+	DatabaseUri string          `env:"BIND_DATABASE_URI" required:"true"`
+	Workers     int             `env:"BIND_WORKERS" default:"4"`
+	Ratio       float64         `env:"BIND_RATIO"`
+	Enabled     bool            `env:"BIND_ENABLED" default:"false"`
+	Ports       []int           `env:"BIND_PORTS"`
+	Cache       bindCacheConfig `envPrefix:"BIND_CACHE_"`
+	internal    string
+}
+
+func TestBaseConfig_Bind(t *testing.T) {
+	t.Setenv("BIND_DATABASE_URI", "postgres://localhost/db")
+	t.Setenv("BIND_PORTS", "80, 443")
+	t.Setenv("BIND_CACHE_TTL", "1m")
+	config.Get().AddConfigVar("BIND_RATIO", "0.5")
+	config.Get().AddConfigVar("BIND_ENABLED", "true")
+
+	cfg := bindServiceConfig{}
+	require.NoError(t, config.Bind(&cfg))
+	assert.Equal(t, ":8080", cfg.ListenAddr)
+	assert.Equal(t, "postgres://localhost/db", cfg.DatabaseUri)
+	assert.Equal(t, 4, cfg.Workers)
+	assert.Equal(t, 0.5, cfg.Ratio)
+	assert.True(t, cfg.Enabled)
+	assert.Equal(t, []int{80, 443}, cfg.Ports)
+	assert.Equal(t, "redis://localhost:6379", cfg.Cache.Uri)
+	assert.Equal(t, time.Minute, cfg.Cache.TTL)
+
+	// Missing required field and invalid values are reported
+	t.Setenv("BIND_DATABASE_URI", "")
+	t.Setenv("BIND_WORKERS", "many")
+	err := config.Bind(&cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "BIND_DATABASE_URI is required")
+	assert.Contains(t, err.Error(), "BIND_WORKERS")
+
+	assert.Error(t, config.Bind(cfg))
+}
+
+func TestBaseConfig_ForTenant(t *testing.T) {
+	store := config.NewInMemoryTenantConfigStore()
+	store.Set("tenant-1", "TENANT_MAX_USERS", "500")
+	store.Set("tenant-1", "TENANT_FEATURES", "reports,export")
+	config.Get().AddConfigVar("TENANT_MAX_USERS", "100")
+	config.Get().SetTenantStore(store)
+	defer config.Get().SetTenantStore(nil)
+
+	tenant1 := config.Get().ForTenant("tenant-1")
+	assert.Equal(t, "tenant-1", tenant1.TenantId())
+	assert.Equal(t, 500, tenant1.GetInt("TENANT_MAX_USERS", 10))
+	assert.Equal(t, []string{"reports", "export"}, tenant1.GetStringSlice("TENANT_FEATURES", nil))
+	assert.Equal(t, time.Minute, tenant1.GetDuration("TENANT_TIMEOUT", time.Minute))
+
+	// Tenant without overrides falls back to the service configuration
+	tenant2 := config.Get().ForTenant("tenant-2")
+	assert.Equal(t, 100, tenant2.GetInt("TENANT_MAX_USERS", 10))
+	assert.Equal(t, "none", tenant2.GetString("TENANT_FEATURES", "none"))
+
+	// Failing store falls back to the service configuration
+	config.Get().SetTenantStore(config.TenantConfigStoreFunc(func(tenantId string) (map[string]string, error) {
+		return nil, os.ErrNotExist
+	}))
+	assert.Equal(t, int64(100), config.Get().ForTenant("tenant-1").GetInt64("TENANT_MAX_USERS", 10))
+}
+
+func TestBaseConfig_TenantConfigStores(t *testing.T) {
+	dc, err := database.NewInMemoryDataCache()
+	require.NoError(t, err)
+	require.NoError(t, dc.HSetRaw("tenant-config:tenant-1", "TENANT_MAX_USERS", []byte("500")))
+	require.NoError(t, dc.HSetRaw("tenant-config:tenant-2", "TENANT_MAX_USERS", []byte("50")))
+
+	vars, err := database.NewDataCacheTenantConfigStore(dc, "tenant-config:").GetTenantVars("tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"TENANT_MAX_USERS": "500"}, vars)
+
+	db, err := database.NewInMemoryDatabase()
+	require.NoError(t, err)
+	_, err = db.BulkInsert([]entity.Entity{
+		database.NewTenantConfigVar("tenant-1", "TENANT_MAX_USERS", "600"),
+		database.NewTenantConfigVar("tenant-1", "TENANT_FEATURES", "reports"),
+		database.NewTenantConfigVar("tenant-2", "TENANT_MAX_USERS", "60"),
+	})
+	require.NoError(t, err)
+
+	config.Get().AddConfigVar("TENANT_MAX_USERS", "100")
+	config.Get().SetTenantStore(database.NewDatabaseTenantConfigStore(db))
+	defer config.Get().SetTenantStore(nil)
+
+	tenant1 := config.Get().ForTenant("tenant-1")
+	assert.Equal(t, 600, tenant1.GetInt("TENANT_MAX_USERS", 10))
+	assert.Equal(t, "reports", tenant1.GetString("TENANT_FEATURES", "none"))
+	assert.Equal(t, 60, config.Get().ForTenant("tenant-2").GetInt("TENANT_MAX_USERS", 10))
+	assert.Equal(t, 100, config.Get().ForTenant("tenant-3").GetInt("TENANT_MAX_USERS", 10))
+}
+
+func TestBaseConfig_ConcurrentAccess(t *testing.T) {
+	cfg := config.Get()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cfg.AddConfigVar(fmt.Sprintf("RACE_KEY_%d", j%10), fmt.Sprintf("%d", i))
+				cfg.ScanEnvVariables()
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = cfg.GetIntParamValueOrDefault(fmt.Sprintf("RACE_KEY_%d", j%10), 0)
+				_ = cfg.LogLevel()
+				_ = cfg.GetAllVars()
+				_ = cfg.GetAllKeysSorted()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.True(t, cfg.GetIntParamValueOrDefault("RACE_KEY_0", -1) >= 0)
+}
@@ -3,8 +3,16 @@
 package test
 
 import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
 	"github.com/go-yaaf/yaaf-common/entity"
 	"github.com/go-yaaf/yaaf-common/utils/binary"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type SampleObjectNotBinary struct {
@@ -161,3 +169,249 @@ func (c *ComplexObject) UnmarshalBinary(data []byte) (e error) {
 
 	return nil
 }
+
+// PooledSampleObject is encoded using a pooled writer
+type PooledSampleObject struct {
+	SampleObject
+}
+
+// MarshalBinary convert current structure to a minimal wire-format byte array using a pooled writer
+func (s *PooledSampleObject) MarshalBinary() (data []byte, err error) {
+	w := binary.Acquire()
+	defer binary.Release(w)
+	w.IP(s.SrcIP)
+	w.IPArray(s.DstIPs)
+	w.Timestamp(s.Timestamp).Int(s.IntValue).Int32(s.Int32Value).Int64(s.Int64Value).IntArray(s.IntArray).String(s.StringValue).StringArray(s.StringArray)
+	return w.GetBytes(), nil
+}
+
+func newSampleObject() *SampleObject {
+	return &SampleObject{
+		Timestamp:   entity.Timestamp(1700000000000),
+		SrcIP:       "10.0.0.1",
+		DstIPs:      []string{"192.168.1.1", "::1", "host.local"},
+		IntValue:    -42,
+		Int32Value:  100000,
+		Int64Value:  1 << 40,
+		IntArray:    []int{1, 2, 300, -5},
+		StringValue: "hello",
+		StringArray: []string{"a", "bc", ""},
+	}
+}
+
+func TestBinary_WireFormat(t *testing.T) {
+	// The encoding must stay compatible with data written by previous versions
+	w := binary.NewWriter()
+	w.Uint64(0).Uint64(127).Uint64(200).Uint64(16384).Int(-1).String("ab").Bool(true).IP("10.0.0.1")
+	assert.Equal(t, "00ff00c801808001ffffffffffffffffff026162010481808050", hex.EncodeToString(w.Bytes()))
+	assert.Equal(t, int64(w.Len()), w.Written())
+}
+
+func TestBinary_WriterPool(t *testing.T) {
+	expected := &PooledSampleObject{SampleObject: *newSampleObject()}
+	data, err := expected.MarshalBinary()
+	require.NoError(t, err)
+
+	// The pooled writer produces the same wire format
+	plain, err := expected.SampleObject.MarshalBinary()
+	require.NoError(t, err)
+	assert.Equal(t, plain, data)
+
+	// GetBytes returns a copy which is not affected by the reuse of the pooled writer
+	other := &PooledSampleObject{SampleObject: *newSampleObject()}
+	other.StringValue = "other value"
+	otherData, err := other.MarshalBinary()
+	require.NoError(t, err)
+
+	actual := &PooledSampleObject{}
+	require.NoError(t, actual.UnmarshalBinary(data))
+	assert.Equal(t, expected, actual)
+
+	// Nested objects are encoded by another pooled writer while the outer writer is in use
+	outer := binary.Acquire()
+	outer.String("nested").Object(&data).ObjectArray(&[][]byte{otherData, data})
+	nested := outer.GetBytes()
+	binary.Release(outer)
+
+	r := binary.NewReader(nested)
+	name, err := r.String()
+	require.NoError(t, err)
+	assert.Equal(t, "nested", name)
+	objData, err := r.Object()
+	require.NoError(t, err)
+	require.NoError(t, actual.UnmarshalBinary(objData))
+	assert.Equal(t, expected, actual)
+	list, err := r.ObjectArray()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(list))
+	require.NoError(t, actual.UnmarshalBinary(list[0]))
+	assert.Equal(t, other, actual)
+
+	// WriteTo flushes the buffer and keeps counting the written bytes
+	w := binary.Acquire()
+	defer binary.Release(w)
+	out := &bytes.Buffer{}
+	w.String("first")
+	n, err := w.WriteTo(out)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+	assert.Equal(t, 0, w.Len())
+	w.String("second")
+	assert.Equal(t, int64(13), w.Written())
+}
+
+func BenchmarkBinary_Encode(b *testing.B) {
+	obj := newSampleObject()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w := binary.Acquire()
+		w.IP(obj.SrcIP).IPArray(obj.DstIPs).Timestamp(obj.Timestamp).Int(obj.IntValue).Int32(obj.Int32Value).Int64(obj.Int64Value)
+		w.IntArray(obj.IntArray).String(obj.StringValue).StringArray(obj.StringArray)
+		b.SetBytes(int64(w.Len()))
+		binary.Release(w)
+	}
+}
+
+func BenchmarkBinary_Decode(b *testing.B) {
+	data, _ := newSampleObject().MarshalBinary()
+	obj := &SampleObject{}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := obj.UnmarshalBinary(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type TelemetryPoint struct {
+	Name  string
+	Value float64
+}
+
+func (p *TelemetryPoint) MarshalTo(w *binary.Writer) {
+	w.String(p.Name).Float64(p.Value)
+}
+
+func (p *TelemetryPoint) UnmarshalFrom(r *binary.Reader) (e error) {
+	if p.Name, e = r.String(); e != nil {
+		return e
+	}
+	p.Value, e = r.Float64()
+	return e
+}
+
+type TelemetryObject struct {
+	Labels     map[string]string
+	Attributes entity.Json
+	Primary    TelemetryPoint
+	Points     []TelemetryPoint
+}
+
+func (o *TelemetryObject) MarshalTo(w *binary.Writer) {
+	w.Map(o.Labels).JsonObject(o.Attributes).Struct(&o.Primary)
+	points := make([]*TelemetryPoint, len(o.Points))
+	for i := range o.Points {
+		points[i] = &o.Points[i]
+	}
+	binary.WriteStructArray(w, points)
+}
+
+func (o *TelemetryObject) UnmarshalFrom(r *binary.Reader) (e error) {
+	if o.Labels, e = r.Map(); e != nil {
+		return e
+	}
+	if o.Attributes, e = r.JsonObject(); e != nil {
+		return e
+	}
+	if e = r.Struct(&o.Primary); e != nil {
+		return e
+	}
+	o.Points, e = binary.ReadStructArray[TelemetryPoint](r)
+	return e
+}
+
+func TestBinary_ComplexTypes(t *testing.T) {
+	expected := &TelemetryObject{
+		Labels: map[string]string{"host": "srv-1", "region": "eu", "": "empty"},
+		Attributes: entity.Json{
+			"count":   int64(-5),
+			"size":    uint64(1 << 40),
+			"ratio":   0.25,
+			"enabled": true,
+			"name":    "cpu",
+			"missing": nil,
+			"tags":    []any{"a", int64(1), false},
+			"nested":  map[string]any{"level": int64(2)},
+		},
+		Primary: TelemetryPoint{Name: "cpu", Value: 0.75},
+		Points:  []TelemetryPoint{{Name: "mem", Value: 1024}, {Name: "disk", Value: -1}},
+	}
+
+	data := binary.Marshal(expected)
+	actual := &TelemetryObject{}
+	require.NoError(t, binary.Unmarshal(data, actual))
+	assert.Equal(t, expected, actual)
+
+	// Map entries are written by key order
+	assert.Equal(t, data, binary.Marshal(expected))
+
+	// Unsupported types are written as raw JSON
+	w := binary.NewWriter().JsonObject(entity.Json{"ts": entity.Timestamp(1000)})
+	obj, err := binary.NewReader(w.Bytes()).JsonObject()
+	require.NoError(t, err)
+	assert.Equal(t, float64(1000), obj["ts"])
+
+	// Corrupted data
+	_, err = binary.NewReader([]byte{0xff, 0xff, 0x7f}).Map()
+	assert.Error(t, err)
+	_, err = binary.NewReader(data[:len(data)-3]).Map()
+	require.NoError(t, err)
+	assert.Error(t, binary.Unmarshal(data[:len(data)-3], &TelemetryObject{}))
+}
+
+func TestBinary_TimeAndUUID(t *testing.T) {
+	id := uuid.New()
+	local := time.Date(2024, 3, 10, 14, 30, 15, 123456789, time.FixedZone("IST", 2*3600))
+	utc := time.Date(1900, 1, 1, 0, 0, 0, 1, time.UTC)
+	count := int64(-7)
+
+	w := binary.NewWriter()
+	w.Duration(90*time.Minute + 5*time.Nanosecond).Time(local).Time(utc).Time(time.Time{}).UUID(id)
+	binary.WriteNullable(w, &count, w.Int64)
+	binary.WriteNullable[string](w, nil, w.String)
+
+	r := binary.NewReader(w.Bytes())
+	d, err := r.Duration()
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Minute+5*time.Nanosecond, d)
+
+	actual, err := r.Time()
+	require.NoError(t, err)
+	assert.True(t, local.Equal(actual))
+	_, offset := actual.Zone()
+	assert.Equal(t, 2*3600, offset)
+
+	actual, err = r.Time()
+	require.NoError(t, err)
+	assert.Equal(t, utc, actual)
+
+	actual, err = r.Time()
+	require.NoError(t, err)
+	assert.True(t, actual.IsZero())
+
+	actualId, err := r.UUID()
+	require.NoError(t, err)
+	assert.Equal(t, id, uuid.UUID(actualId))
+
+	actualCount, err := binary.ReadNullable(r, r.Int64)
+	require.NoError(t, err)
+	assert.Equal(t, &count, actualCount)
+
+	actualString, err := binary.ReadNullable(r, r.String)
+	require.NoError(t, err)
+	assert.Nil(t, actualString)
+
+	_, err = r.UUID()
+	assert.Error(t, err)
+}
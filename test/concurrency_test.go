@@ -0,0 +1,135 @@
+// Concurrency utilities tests
+
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common/utils/concurrency"
+)
+
+// track the max number of concurrent calls
+type concurrencyTracker struct {
+	current atomic.Int32
+	max     atomic.Int32
+}
+
+func (c *concurrencyTracker) enter() {
+	n := c.current.Add(1)
+	for {
+		m := c.max.Load()
+		if n <= m || c.max.CompareAndSwap(m, n) {
+			return
+		}
+	}
+}
+
+func (c *concurrencyTracker) leave() {
+	c.current.Add(-1)
+}
+
+func TestConcurrency_Pool(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	var done atomic.Int32
+
+	p := concurrency.NewPool(context.Background(), 3)
+	for i := 0; i < 10; i++ {
+		i := i
+		err := p.Submit(func(ctx context.Context) error {
+			tracker.enter()
+			defer tracker.leave()
+			time.Sleep(10 * time.Millisecond)
+			done.Add(1)
+			if i%4 == 0 {
+				return fmt.Errorf("task %d failed", i)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	// Wait drains all the submitted tasks and aggregates the errors
+	err := p.Wait()
+	require.Error(t, err)
+	assert.Equal(t, int32(10), done.Load())
+	assert.LessOrEqual(t, tracker.max.Load(), int32(3))
+	assert.Contains(t, err.Error(), "task 0 failed")
+	assert.Contains(t, err.Error(), "task 4 failed")
+	assert.Contains(t, err.Error(), "task 8 failed")
+
+	assert.ErrorIs(t, p.Submit(func(ctx context.Context) error { return nil }), concurrency.ErrPoolClosed)
+}
+
+func TestConcurrency_PoolFailFast(t *testing.T) {
+	p := concurrency.NewPool(context.Background(), 1).WithFailFast()
+
+	failure := errors.New("failure")
+	require.NoError(t, p.Submit(func(ctx context.Context) error { return failure }))
+
+	// The pool context is canceled once the failed task completes, so the next submit is rejected eventually
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = p.Submit(func(ctx context.Context) error { return nil })
+	}
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, p.Wait(), failure)
+}
+
+func TestConcurrency_PoolPanic(t *testing.T) {
+	p := concurrency.NewPool(context.Background(), 2)
+	require.NoError(t, p.Submit(func(ctx context.Context) error { panic("boom") }))
+	err := p.Wait()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestConcurrency_ParallelMap(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	items := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	result, err := concurrency.ParallelMap(context.Background(), items, 4, func(ctx context.Context, v int) (string, error) {
+		tracker.enter()
+		defer tracker.leave()
+		time.Sleep(time.Duration(10-v) * time.Millisecond)
+		return fmt.Sprintf("#%d", v*v), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"#1", "#4", "#9", "#16", "#25", "#36", "#49", "#64", "#81", "#100"}, result)
+	assert.LessOrEqual(t, tracker.max.Load(), int32(4))
+
+	// First error cancels the running calls and skips the remaining items
+	var calls atomic.Int32
+	failure := errors.New("failure")
+	items = make([]int, 100)
+	err = concurrency.ForEach(context.Background(), items, 2, func(ctx context.Context, v int) error {
+		if calls.Add(1) == 3 {
+			return failure
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Millisecond):
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Less(t, calls.Load(), int32(100))
+
+	// Canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = concurrency.ForEach(ctx, items, 2, func(ctx context.Context, v int) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Empty items
+	empty, err := concurrency.ParallelMap(context.Background(), []int{}, 2, func(ctx context.Context, v int) (int, error) { return v, nil })
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
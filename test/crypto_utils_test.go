@@ -0,0 +1,101 @@
+// Test payload signing and envelope encryption utilities
+package test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/config"
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrypto_WebhookSignature(t *testing.T) {
+	cu := utils.CryptoUtils()
+	secret := []byte("webhook-shared-secret")
+	payload := []byte(`{"event":"invoice.paid","id":"inv-1"}`)
+
+	signature := cu.Sign(secret, payload)
+	assert.True(t, cu.Verify(secret, payload, signature))
+	assert.False(t, cu.Verify([]byte("other-secret"), payload, signature))
+	assert.False(t, cu.Verify(secret, []byte(`{}`), signature))
+
+	header := cu.SignWebhook(secret, payload)
+	assert.NoError(t, cu.VerifyWebhook(secret, payload, header, time.Minute))
+	assert.Error(t, cu.VerifyWebhook(secret, []byte(`{"event":"invoice.void"}`), header, time.Minute))
+	assert.Error(t, cu.VerifyWebhook([]byte("other-secret"), payload, header, time.Minute))
+	assert.Error(t, cu.VerifyWebhook(secret, payload, "", time.Minute))
+
+	// Old payloads are rejected (replay protection)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	oldHeader := fmt.Sprintf("t=%s,v1=%s", old, cu.Sign(secret, []byte(old+"."+string(payload))))
+	assert.Error(t, cu.VerifyWebhook(secret, payload, oldHeader, time.Minute))
+	assert.NoError(t, cu.VerifyWebhook(secret, payload, oldHeader, 0))
+
+	// Any of the signatures may match (secret rotation)
+	rotated := cu.SignWebhook([]byte("new-secret"), payload) + ",v1=" + cu.Sign(secret, []byte(old+"."+string(payload)))
+	assert.NoError(t, cu.VerifyWebhook([]byte("new-secret"), payload, rotated, time.Minute))
+}
+
+func TestCrypto_EnvelopeEncryption(t *testing.T) {
+	oldKey := []byte("thisIsTheOldMasterKeyOf32Length!")
+	newKey := []byte("thisIsTheNewMasterKeyOf32Length!")
+
+	config.Get().AddConfigVar(config.CfgEncryptionSecret, base64.StdEncoding.EncodeToString(oldKey))
+	config.Get().AddConfigVar(config.CfgEncryptionKeyId, "key-1")
+	defer config.Get().AddConfigVar(config.CfgEncryptionSecret, "")
+	defer config.Get().AddConfigVar(config.CfgEncryptionKeyId, "")
+
+	ec, err := utils.NewEnvelopeCipherFromConfig()
+	require.NoError(t, err)
+
+	hero := NewHero1("1", 1, "Superman")
+	encrypted, err := ec.EncryptEntity(hero)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "Superman")
+
+	keyId, err := ec.KeyId(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", keyId)
+
+	decrypted := &Hero{}
+	require.NoError(t, ec.DecryptEntity(encrypted, decrypted))
+	assert.Equal(t, "Superman", decrypted.Name)
+
+	// Each payload has its own data key
+	again, err := ec.EncryptEntity(hero)
+	require.NoError(t, err)
+	assert.NotEqual(t, encrypted, again)
+
+	// Tampered payloads are rejected
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 0x01
+	assert.ErrorIs(t, ec.DecryptEntity(tampered, &Hero{}), utils.ErrDecryptionFailed)
+	_, err = ec.Decrypt([]byte{1})
+	assert.ErrorIs(t, err, utils.ErrDecryptionFailed)
+
+	// Key rotation: the new key encrypts, the old key still decrypts
+	rotated, err := utils.NewEnvelopeCipher("key-2", newKey)
+	require.NoError(t, err)
+	_, err = rotated.Decrypt(encrypted)
+	assert.ErrorIs(t, err, utils.ErrDecryptionFailed)
+	require.NoError(t, rotated.AddKey("key-1", oldKey))
+	plain, err := rotated.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Contains(t, string(plain), "Superman")
+
+	token, err := rotated.EncryptString("secret value")
+	require.NoError(t, err)
+	value, err := rotated.DecryptString(token)
+	require.NoError(t, err)
+	assert.Equal(t, "secret value", value)
+	_, err = ec.DecryptString(token)
+	assert.ErrorIs(t, err, utils.ErrDecryptionFailed)
+
+	_, err = utils.NewEnvelopeCipher("short", []byte("short"))
+	assert.Error(t, err)
+}
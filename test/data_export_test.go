@@ -0,0 +1,188 @@
+// Test import / export utilities
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportDatabase(t *testing.T) {
+	entity.RegisterEntity(NewHero)
+
+	src, err := getInitializedDb()
+	require.NoError(t, err)
+
+	buffer := &bytes.Buffer{}
+	total, err := ExportDatabase(src, buffer, ExportOptions{TablePattern: "her*", BatchSize: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(len(list_of_heroes)), total)
+	require.Equal(t, len(list_of_heroes), strings.Count(buffer.String(), "\n"))
+
+	dst, err := NewInMemoryDatabase()
+	require.NoError(t, err)
+	_, err = dst.Get(NewHero, "5")
+	require.ErrorIs(t, err, ErrTableNotExists)
+
+	loaded, err := ImportDatabase(dst, buffer, ImportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, total, loaded)
+
+	hero, err := dst.Get(NewHero, "5")
+	require.NoError(t, err)
+	require.Equal(t, "Bat Man", hero.(*Hero).Name)
+}
+
+func TestExportDatastoreWithRange(t *testing.T) {
+	// The sidekick index does not exist in the source and is skipped
+	entity.RegisterEntity(NewHero, NewSidekick)
+
+	src, err := getInitializedDs()
+	require.NoError(t, err)
+	_, _, err = src.Query(NewSidekick).Find()
+	require.ErrorIs(t, err, ErrIndexNotExists)
+
+	buffer := &bytes.Buffer{}
+	total, err := ExportDatastore(src, buffer, ExportOptions{TimeField: "key", From: 1, To: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(10), total)
+
+	// Pattern not matching any registered entity
+	total, err = ExportDatastore(src, &bytes.Buffer{}, ExportOptions{TablePattern: "villain*"})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), total)
+}
+
+func TestImportRecords(t *testing.T) {
+	csvData := "Hero Id,Hero Key,Hero Name,comment\n" +
+		"h1,1,super man,first\n" +
+		"h2,two,bat man,invalid key\n" +
+		"h3,3,,missing name\n" +
+		"h4,4,wonder woman,last\n"
+
+	opts := RecordsImportOptions{
+		Mapping: map[string]string{"Hero Id": "id", "Hero Key": "key", "Hero Name": "name"},
+		Ignore:  []string{"comment"},
+		Transforms: map[string]FieldTransform{
+			"name": func(value any) (any, error) { return strings.ToUpper(value.(string)), nil },
+		},
+		Validate: func(ent entity.Entity) error {
+			if len(ent.(*Hero).Name) == 0 {
+				return fmt.Errorf("name is required")
+			}
+			return nil
+		},
+		BatchSize: 1,
+		MaxErrors: 5,
+	}
+
+	// Dry run: nothing is written
+	db, err := NewInMemoryDatabase()
+	require.NoError(t, err)
+
+	opts.DryRun = true
+	result, err := ImportRecordsDatabase(db, NewHero, strings.NewReader(csvData), opts)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), result.Records)
+	require.Equal(t, int64(2), result.Imported)
+	require.Equal(t, int64(2), result.Failed)
+	require.Equal(t, 3, result.Errors[0].Line)
+	require.Equal(t, 4, result.Errors[1].Line)
+	_, err = db.Get(NewHero, "h1")
+	require.Error(t, err)
+
+	// Import with progress
+	progress := make([]ImportProgress, 0)
+	opts.DryRun = false
+	opts.OnProgress = func(p ImportProgress) { progress = append(progress, p) }
+	result, err = ImportRecordsDatabase(db, NewHero, strings.NewReader(csvData), opts)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Imported)
+	require.Equal(t, int64(2), progress[len(progress)-1].Imported)
+
+	hero, err := db.Get(NewHero, "h4")
+	require.NoError(t, err)
+	require.Equal(t, 4, hero.(*Hero).Key)
+	require.Equal(t, "WONDER WOMAN", hero.(*Hero).Name)
+
+	// Too many errors
+	opts.MaxErrors = 0
+	_, err = ImportRecordsDatabase(db, NewHero, strings.NewReader(csvData), opts)
+	var recordErr ImportRecordError
+	require.ErrorAs(t, err, &recordErr)
+	require.Equal(t, 3, recordErr.Line)
+}
+
+func TestImportRecordsNdjson(t *testing.T) {
+	ndjson := `{"id":"n1","key":1,"name":"Iron Man"}` + "\n\n" +
+		`{"id":"n2","key":"2","name":"Spider Man"}` + "\n" +
+		`{"id":"n3",` + "\n"
+
+	ds, err := getInitializedDs()
+	require.NoError(t, err)
+
+	result, err := ImportRecordsDatastore(ds, NewHero, strings.NewReader(ndjson), RecordsImportOptions{Format: ImportFormatNdjson, Upsert: true, MaxErrors: 1})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Imported)
+	require.Equal(t, 4, result.Errors[0].Line)
+
+	hero, err := ds.Get(NewHero, "n2")
+	require.NoError(t, err)
+	require.Equal(t, 2, hero.(*Hero).Key)
+}
+
+type Sidekick struct {
+	entity.BaseEntity
+	Name string `json:"name"` // Name
+}
+
+func (a Sidekick) TABLE() string { return "sidekick" }
+
+func NewSidekick() entity.Entity {
+	return &Sidekick{}
+}
+
+// database recording the bulk inserts
+type bulkRecorderDatabase struct {
+	IDatabase
+	bulks [][]string
+}
+
+func (db *bulkRecorderDatabase) BulkInsert(entities []entity.Entity) (int64, error) {
+	tables := make([]string, 0, len(entities))
+	for _, ent := range entities {
+		tables = append(tables, ent.TABLE())
+	}
+	db.bulks = append(db.bulks, tables)
+	return db.IDatabase.BulkInsert(entities)
+}
+
+func TestImportDatabaseMixedTypes(t *testing.T) {
+	entity.RegisterEntity(NewHero, NewSidekick)
+
+	buffer := &bytes.Buffer{}
+	for i, typ := range []string{"hero", "hero", "sidekick", "hero", "sidekick", "sidekick"} {
+		buffer.WriteString(fmt.Sprintf(`{"type":"%s","data":{"id":"%d","name":"name %d"}}`+"\n", typ, i, i))
+	}
+
+	mem, err := NewInMemoryDatabase()
+	require.NoError(t, err)
+	db := &bulkRecorderDatabase{IDatabase: mem}
+
+	loaded, err := ImportDatabase(db, buffer, ImportOptions{BatchSize: 10})
+	require.NoError(t, err)
+	require.Equal(t, int64(6), loaded)
+	require.Equal(t, [][]string{{"hero", "hero"}, {"sidekick"}, {"hero"}, {"sidekick", "sidekick"}}, db.bulks)
+
+	sidekick, err := mem.Get(NewSidekick, "2")
+	require.NoError(t, err)
+	require.Equal(t, "name 2", sidekick.(*Sidekick).Name)
+	hero, err := mem.Get(NewHero, "3")
+	require.NoError(t, err)
+	require.Equal(t, "name 3", hero.(*Hero).Name)
+}
@@ -0,0 +1,169 @@
+// Docker utils tests
+
+package test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerUtils_WaitForPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+
+	container := utils.DockerUtils().CreateContainer("postgres").Name("test-postgres").Port(port, "5432")
+	require.NoError(t, container.WaitForPort(port, time.Second))
+
+	// The port is closed
+	require.NoError(t, listener.Close())
+	start := time.Now()
+	err = container.WaitForPort(port, 300*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not ready")
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+
+	// Invalid log pattern
+	assert.Error(t, container.WaitForLog("(", time.Second))
+}
+
+// fakeDockerEngine is a minimal Docker Engine API server
+type fakeDockerEngine struct {
+	mu       sync.Mutex
+	images   map[string]bool
+	running  map[string]bool
+	requests []string
+	created  map[string]any
+}
+
+func newFakeDockerEngine(t *testing.T) (*fakeDockerEngine, string) {
+	engine := &fakeDockerEngine{images: map[string]bool{}, running: map[string]bool{}}
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return engine, "tcp://" + strings.TrimPrefix(server.URL, "http://")
+}
+
+func (e *fakeDockerEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests = append(e.requests, r.Method+" "+r.URL.Path)
+
+	fail := func(status int, message string) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) >= 2 && parts[0] == "containers" && parts[1] != "create" {
+		if _, ok := e.running[parts[1]]; !ok {
+			fail(http.StatusNotFound, "No such container: "+parts[1])
+			return
+		}
+	}
+	switch {
+	case r.URL.Path == "/images/create":
+		e.images[r.URL.Query().Get("fromImage")+":"+r.URL.Query().Get("tag")] = true
+		_, _ = w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Done"}`))
+	case r.URL.Path == "/containers/create":
+		body := map[string]any{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if !e.images[body["Image"].(string)] {
+			fail(http.StatusNotFound, "No such image")
+			return
+		}
+		name := r.URL.Query().Get("name")
+		if _, ok := e.running[name]; ok {
+			fail(http.StatusConflict, "Conflict. The container name is already in use")
+			return
+		}
+		e.created = body
+		e.running[name] = false
+		_ = json.NewEncoder(w).Encode(map[string]string{"Id": name})
+	case len(parts) == 3 && parts[2] == "start":
+		e.running[parts[1]] = true
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[2] == "stop":
+		if !e.running[parts[1]] {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		e.running[parts[1]] = false
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		delete(e.running, parts[1])
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 3 && parts[2] == "json":
+		_, _ = w.Write([]byte(`{"State":{"Running":` + strconv.FormatBool(e.running[parts[1]]) + `,"Health":{"Status":"healthy"}}}`))
+	case len(parts) == 3 && parts[2] == "logs":
+		// Multiplexed stream frames
+		for i, line := range []string{"starting\n", "ready to accept connections\n"} {
+			header := make([]byte, 8)
+			header[0] = byte(i + 1)
+			binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+			_, _ = w.Write(append(header, line...))
+		}
+	default:
+		fail(http.StatusNotFound, "page not found")
+	}
+}
+
+func TestDockerUtils_EngineApi(t *testing.T) {
+	engine, host := newFakeDockerEngine(t)
+	du := utils.DockerUtilsWithHost(host)
+	assert.Equal(t, "api", du.Engine())
+
+	container := du.CreateContainer("redis:7").Name("test-redis").Port("6379", "6379").
+		Var("MODE", "test").Volume("data", "/data").Network("test-net").
+		HealthCheck("redis-cli ping", time.Second, 0, 3)
+	assert.False(t, container.Exists())
+
+	// The image is pulled when missing
+	require.NoError(t, container.Run())
+	assert.True(t, container.IsRunning())
+	assert.True(t, du.ContainerExists("test-redis"))
+	assert.Equal(t, []string{"POST /containers/create", "POST /images/create", "POST /containers/create", "POST /containers/test-redis/start"}, engine.requests[2:6])
+	assert.Equal(t, []any{"MODE=test"}, engine.created["Env"])
+	assert.Equal(t, map[string]any{"6379/tcp": []any{map[string]any{"HostIp": "", "HostPort": "6379"}}}, engine.created["HostConfig"].(map[string]any)["PortBindings"])
+	assert.Equal(t, "test-net", engine.created["HostConfig"].(map[string]any)["NetworkMode"])
+	assert.Equal(t, []any{"CMD-SHELL", "redis-cli ping"}, engine.created["Healthcheck"].(map[string]any)["Test"])
+
+	// Running container is not created again
+	require.NoError(t, container.Run())
+
+	require.NoError(t, container.WaitForLog("ready to accept", time.Second))
+	require.NoError(t, container.WaitForHealthy(time.Second))
+
+	logs, err := container.Logs(context.Background())
+	require.NoError(t, err)
+	out, err := io.ReadAll(logs)
+	require.NoError(t, err)
+	assert.Equal(t, "starting\nready to accept connections\n", string(out))
+
+	require.NoError(t, container.StopContext(context.Background()))
+	assert.False(t, container.Exists())
+	err = container.Stop()
+	require.Error(t, err)
+	assert.True(t, utils.IsDockerNotFound(err))
+
+	// Name conflict of a stopped container is returned as structured error
+	engine.mu.Lock()
+	engine.running["test-redis"] = false
+	engine.mu.Unlock()
+	err = du.CreateContainer("redis:7").Name("test-redis").Run()
+	var de *utils.DockerError
+	require.ErrorAs(t, err, &de)
+	assert.Equal(t, http.StatusConflict, de.StatusCode)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/go-yaaf/yaaf-common/entity"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"strings"
 	"testing"
 )
 
@@ -75,3 +76,211 @@ func TestMarshalJson(t *testing.T) {
 
 	fmt.Printf("Done \n\n")
 }
+
+func TestTimestampJson(t *testing.T) {
+	hero := NewHero1("1", 1, "Ant man").(*Hero)
+	hero.CreatedOn = entity.Timestamp(1704067200123)
+
+	bytes, err := entity.Marshal(hero)
+	require.Nil(t, err)
+	assert.Contains(t, string(bytes), `"createdOn":1704067200123`)
+
+	entity.SetTimestampJSONFormat(entity.TimestampFormatRFC3339)
+	defer entity.SetTimestampJSONFormat(entity.TimestampFormatEpoch)
+
+	bytes, err = entity.Marshal(hero)
+	require.Nil(t, err)
+	assert.Contains(t, string(bytes), `"createdOn":"2024-01-01T00:00:00.123Z"`)
+
+	// Both formats are accepted regardless of the setting
+	result := &Hero{}
+	require.Nil(t, entity.Unmarshal(bytes, result))
+	assert.Equal(t, hero.CreatedOn, result.CreatedOn)
+
+	require.Nil(t, entity.Unmarshal([]byte(`{"createdOn": 1704067200123, "updatedOn": "2024-01-01T02:00:00+02:00"}`), result))
+	assert.Equal(t, entity.Timestamp(1704067200123), result.CreatedOn)
+	assert.Equal(t, entity.Timestamp(1704067200000), result.UpdatedOn)
+
+	assert.NotNil(t, entity.Unmarshal([]byte(`{"createdOn": "yesterday"}`), result))
+}
+
+func TestSortableIDs(t *testing.T) {
+	start := entity.Now()
+	count := 10000
+
+	ulids := make(map[string]bool, count)
+	ksuids := make(map[string]bool, count)
+	prev := ""
+	for i := 0; i < count; i++ {
+		id := entity.ULID()
+		assert.Equal(t, 26, len(id))
+		assert.True(t, id > prev, "ulid should be monotonic")
+		prev = id
+		ulids[id] = true
+		ksuids[entity.KSUID()] = true
+	}
+	assert.Equal(t, count, len(ulids))
+	assert.Equal(t, count, len(ksuids))
+
+	ts, err := entity.ULIDTime(prev)
+	require.Nil(t, err)
+	assert.True(t, ts >= start && ts <= entity.Now())
+
+	ksuid := entity.KSUID()
+	assert.Equal(t, 27, len(ksuid))
+	ts, err = entity.KSUIDTime(ksuid)
+	require.Nil(t, err)
+	assert.True(t, ts >= start-1000 && ts <= entity.Now())
+
+	id := entity.PrefixedID("usr")
+	assert.True(t, strings.HasPrefix(id, "usr_"))
+	prefix, ts, err := entity.ParsePrefixedID(id)
+	require.Nil(t, err)
+	assert.Equal(t, "usr", prefix)
+	assert.True(t, ts >= start)
+
+	_, _, err = entity.ParsePrefixedID("usr-123")
+	assert.NotNil(t, err)
+	_, err = entity.ULIDTime("not-a-valid-ulid-not-valid")
+	assert.NotNil(t, err)
+}
+
+type cloneNode struct {
+	Name     string
+	Tags     []string
+	Props    entity.Json
+	Parent   *cloneNode
+	Children []*cloneNode
+	internal string
+}
+
+func TestDeepClone(t *testing.T) {
+	root := &cloneNode{Name: "root", Tags: []string{"a"}, Props: entity.Json{"list": []any{1, "x"}}, internal: "secret"}
+	child := &cloneNode{Name: "child", Parent: root}
+	root.Children = []*cloneNode{child, child}
+
+	clone := entity.DeepClone(root)
+	assert.Equal(t, "secret", clone.internal)
+	assert.Equal(t, root.Tags, clone.Tags)
+	assert.Equal(t, root.Props, clone.Props)
+
+	// Shared pointers and cycles are preserved in the copy
+	assert.Same(t, clone, clone.Children[0].Parent)
+	assert.Same(t, clone.Children[0], clone.Children[1])
+	assert.NotSame(t, child, clone.Children[0])
+
+	// Changing the copy does not affect the source
+	clone.Tags[0] = "b"
+	clone.Props["list"].([]any)[0] = 2
+	clone.Children[0].Name = "changed"
+	assert.Equal(t, "a", root.Tags[0])
+	assert.Equal(t, 1, root.Props["list"].([]any)[0])
+	assert.Equal(t, "child", child.Name)
+
+	hero := list_of_heroes[0]
+	cloned := entity.CloneEntity(hero)
+	assert.Equal(t, hero, cloned)
+	assert.NotSame(t, hero, cloned)
+	assert.Nil(t, entity.CloneEntity(nil))
+}
+
+type cloneInner struct {
+	Value int
+}
+
+type cloneOuter struct {
+	In cloneInner
+	P  *cloneOuter
+	Q  *cloneInner
+}
+
+func TestDeepClone_SharedAddress(t *testing.T) {
+	// Pointer to the struct and pointer to its first field share the same address
+	outer := &cloneOuter{In: cloneInner{Value: 1}}
+	outer.P = outer
+	outer.Q = &outer.In
+
+	clone := entity.DeepClone(outer)
+	assert.Same(t, clone, clone.P)
+	assert.Equal(t, 1, clone.Q.Value)
+	clone.Q.Value = 2
+	assert.Equal(t, 1, outer.In.Value)
+}
+
+func BenchmarkCloneEntity(b *testing.B) {
+	hero := list_of_heroes[0]
+	b.Run("reflection", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = entity.CloneEntity(hero)
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data, _ := entity.Marshal(hero)
+			_ = entity.Unmarshal(data, NewHero())
+		}
+	})
+}
+
+func TestTuples(t *testing.T) {
+	list := []entity.Tuple[string, int]{entity.NewTuple("a", 1), entity.NewTuple("b", 2), entity.NewTuple("a", 3)}
+	assert.Equal(t, []string{"a", "b", "a"}, entity.TupleKeys(list))
+	assert.Equal(t, []int{1, 2, 3}, entity.TupleValues(list))
+
+	m := entity.TuplesToMap(list)
+	assert.Equal(t, map[string]int{"a": 3, "b": 2}, m)
+	assert.ElementsMatch(t, []entity.Tuple[string, int]{entity.NewTuple("a", 3), entity.NewTuple("b", 2)}, entity.MapToTuples(m))
+
+	triple := entity.NewTriple(entity.Timestamp(1000), "cpu", 0.5)
+	bytes, err := entity.Marshal(triple)
+	require.Nil(t, err)
+	assert.Equal(t, `{"first":1000,"second":"cpu","third":0.5}`, string(bytes))
+}
+
+type timestampTagsBase struct {
+	CreatedOn entity.Timestamp `json:"createdOn,rfc3339"` // Promoted tagged field
+	UpdatedOn entity.Timestamp `json:"updatedOn"`         // Untagged field
+}
+
+type timestampTagsEntity struct {
+	timestampTagsBase
+	Name     string              `json:"name"`            // Name
+	Expiry   *entity.Timestamp   `json:"expiry,rfc3339"`  // Tagged pointer
+	Missing  *entity.Timestamp   `json:"missing,rfc3339"` // Tagged nil pointer
+	Events   []entity.Timestamp  `json:"events,rfc3339"`  // Tagged slice
+	Deadline entity.Timestamp    `json:"deadline,epoch"`  // Always epoch milliseconds
+	Children []timestampTagsBase `json:"children"`        // Nested structs with tagged fields
+}
+
+func TestTimestampJsonTags(t *testing.T) {
+	ts := entity.Timestamp(1704067200123)
+	value := &timestampTagsEntity{
+		timestampTagsBase: timestampTagsBase{CreatedOn: ts, UpdatedOn: ts},
+		Name:              "tags",
+		Expiry:            &ts,
+		Events:            []entity.Timestamp{ts, 0},
+		Deadline:          ts,
+		Children:          []timestampTagsBase{{CreatedOn: ts, UpdatedOn: ts}},
+	}
+
+	// The package level default (epoch) is unchanged, the tag option overrides it per field and keys keep their order
+	bytes, err := entity.Marshal(value)
+	require.Nil(t, err)
+	assert.Equal(t, `{"createdOn":"2024-01-01T00:00:00.123Z","updatedOn":1704067200123,"name":"tags",`+
+		`"expiry":"2024-01-01T00:00:00.123Z","missing":null,"events":["2024-01-01T00:00:00.123Z","1970-01-01T00:00:00.000Z"],`+
+		`"deadline":1704067200123,"children":[{"createdOn":"2024-01-01T00:00:00.123Z","updatedOn":1704067200123}]}`, string(bytes))
+	assert.Equal(t, entity.TimestampFormatEpoch, entity.GetTimestampJSONFormat())
+
+	result := &timestampTagsEntity{}
+	require.Nil(t, entity.Unmarshal(bytes, result))
+	assert.Equal(t, value, result)
+
+	// The epoch tag option keeps the field as number when the package level format is RFC3339
+	entity.SetTimestampJSONFormat(entity.TimestampFormatRFC3339)
+	defer entity.SetTimestampJSONFormat(entity.TimestampFormatEpoch)
+
+	bytes, err = entity.Marshal(value)
+	require.Nil(t, err)
+	assert.Contains(t, string(bytes), `"updatedOn":"2024-01-01T00:00:00.123Z"`)
+	assert.Contains(t, string(bytes), `"deadline":1704067200123`)
+}
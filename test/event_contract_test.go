@@ -0,0 +1,68 @@
+// Test inter-service event contracts
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/go-yaaf/yaaf-common/messaging"
+	"github.com/go-yaaf/yaaf-common/messaging/contracts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var heroCreatedContract = contracts.NewContract[*Hero]("hero-created", "1.2").WithValidator(func(hero *Hero) error {
+	if hero == nil || len(hero.Name) == 0 {
+		return fmt.Errorf("hero name is required")
+	}
+	return nil
+})
+
+func TestEventContract_Validate(t *testing.T) {
+	msg, err := heroCreatedContract.NewMessage(NewHero1("1", 1, "Ant Man").(*Hero))
+	require.NoError(t, err)
+	assert.Equal(t, "hero-created", msg.Topic())
+	assert.Equal(t, "1.2", msg.Version())
+
+	hero, err := heroCreatedContract.Validate(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "Ant Man", hero.Name)
+
+	_, err = heroCreatedContract.NewMessage(NewHero1("2", 2, "").(*Hero))
+	require.Error(t, err)
+
+	_, err = heroCreatedContract.Validate(GetMessage[*Hero]("hero-deleted", NewHero1("1", 1, "Ant Man").(*Hero)))
+	require.Error(t, err)
+
+	other := GetMessage[*Hero]("hero-created", NewHero1("1", 1, "Ant Man").(*Hero))
+	other.(*Message[*Hero]).MsgVersion = "2.0"
+	_, err = heroCreatedContract.Validate(other)
+	require.Error(t, err)
+
+	c, ok := contracts.GetContract("hero-created")
+	require.True(t, ok)
+	assert.Equal(t, "1.2", c.Version())
+}
+
+func TestEventContract_PublishSubscribe(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	received := make(chan *Hero, 1)
+	_, err = heroCreatedContract.Subscribe(bus, "hero-service", func(hero *Hero, msg IMessage) bool {
+		received <- hero
+		return true
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, heroCreatedContract.Publish(bus, NewHero1("7", 7, "Cat Woman").(*Hero)))
+	require.Error(t, heroCreatedContract.Publish(bus, NewHero1("8", 8, "").(*Hero)))
+
+	select {
+	case hero := <-received:
+		assert.Equal(t, "Cat Woman", hero.Name)
+	case <-time.After(time.Second):
+		require.Fail(t, "event was not delivered")
+	}
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/config"
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlags_Env(t *testing.T) {
+	t.Setenv("FFT_REPORTS", "true")
+	t.Setenv("FFT_DISABLED", "false")
+	t.Setenv("FFT_BETA", "tenants:acme, globex")
+	t.Setenv("FFT_ROLLOUT", "30%")
+	t.Setenv("FFT_LIMIT", "5000")
+	t.Setenv("FFT_THEME", "dark")
+
+	flags := config.NewFeatureFlags(config.NewEnvFeatureFlagProvider("FFT_"))
+	assert.True(t, flags.IsEnabled("REPORTS", "any"))
+	assert.False(t, flags.IsEnabled("DISABLED", "any"))
+	assert.False(t, flags.IsEnabled("UNDEFINED", "any"))
+	assert.True(t, flags.IsEnabled("BETA", "globex"))
+	assert.False(t, flags.IsEnabled("BETA", "initech"))
+	assert.Equal(t, 5000, flags.GetInt("LIMIT", "any", 100))
+	assert.Equal(t, 100, flags.GetInt("THEME", "any", 100))
+	assert.Equal(t, "dark", flags.GetString("THEME", "any", "light"))
+
+	// Percentage rollout is stable per tenant and close to the percentage
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		tenant := fmt.Sprintf("tenant-%d", i)
+		if flags.IsEnabled("ROLLOUT", tenant) {
+			enabled++
+			assert.True(t, flags.IsEnabled("ROLLOUT", tenant))
+		}
+	}
+	assert.InDelta(t, 300, enabled, 60)
+}
+
+func TestFeatureFlags_OnChange(t *testing.T) {
+	mu := sync.Mutex{}
+	definitions := map[string]config.FeatureFlag{"REPORTS": {Enabled: true}}
+	provider := config.FeatureFlagProviderFunc(func() (map[string]config.FeatureFlag, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result := make(map[string]config.FeatureFlag)
+		for k, v := range definitions {
+			result[k] = v
+		}
+		return result, nil
+	})
+
+	flags := config.NewFeatureFlags(provider)
+	changes := make(map[string]config.FeatureFlag)
+	flags.OnChange(func(name string, previous, current config.FeatureFlag) {
+		changes[name] = current
+	})
+
+	require.NoError(t, flags.Refresh())
+	assert.Equal(t, 0, len(changes))
+
+	mu.Lock()
+	definitions["REPORTS"] = config.FeatureFlag{Enabled: false}
+	definitions["EXPORT"] = config.FeatureFlag{Enabled: true, Value: "10"}
+	mu.Unlock()
+
+	require.NoError(t, flags.Refresh())
+	assert.Equal(t, 2, len(changes))
+	assert.False(t, changes["REPORTS"].Enabled)
+	assert.False(t, flags.IsEnabled("REPORTS", "any"))
+	assert.Equal(t, 10, flags.GetInt("EXPORT", "any", 0))
+}
+
+func TestFeatureFlags_DataCache(t *testing.T) {
+	dc, err := database.NewInMemoryDataCache()
+	require.NoError(t, err)
+	require.NoError(t, dc.SetRaw("ff:REPORTS", []byte("true")))
+	require.NoError(t, dc.SetRaw("ff:BETA", []byte(`{"enabled":true,"tenants":["acme"]}`)))
+	require.NoError(t, dc.SetRaw("ff:LIMIT", []byte("5000")))
+	require.NoError(t, dc.SetRaw("other:THEME", []byte("dark")))
+
+	provider := database.NewDataCacheFeatureFlagProvider(dc, "ff:", time.Hour)
+	flags := config.NewFeatureFlags(provider)
+	assert.True(t, flags.IsEnabled("REPORTS", "any"))
+	assert.True(t, flags.IsEnabled("BETA", "acme"))
+	assert.False(t, flags.IsEnabled("BETA", "globex"))
+	assert.Equal(t, 5000, flags.GetInt("LIMIT", "any", 100))
+	assert.False(t, flags.IsEnabled("THEME", "any"))
+
+	// Changes are not loaded until the ttl expires
+	require.NoError(t, dc.SetRaw("ff:REPORTS", []byte("false")))
+	require.NoError(t, flags.Refresh())
+	assert.True(t, flags.IsEnabled("REPORTS", "any"))
+
+	flags = config.NewFeatureFlags(database.NewDataCacheFeatureFlagProvider(dc, "ff:", 0))
+	assert.False(t, flags.IsEnabled("REPORTS", "any"))
+}
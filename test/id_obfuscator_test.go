@@ -0,0 +1,55 @@
+// Test ID obfuscation
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdObfuscator(t *testing.T) {
+	obfuscator := entity.NewIdObfuscator("my-secret-salt")
+
+	unique := make(map[string]bool)
+	for _, id := range []uint64{0, 1, 2, 3, 61, 62, 1024, 1 << 40, ^uint64(0)} {
+		hash := obfuscator.Encode(id)
+		require.False(t, unique[hash], "duplicate hash %s", hash)
+		unique[hash] = true
+
+		decoded, err := obfuscator.Decode(hash)
+		require.NoError(t, err)
+		require.Equal(t, id, decoded)
+	}
+
+	// Different salt can not decode
+	_, err := entity.NewIdObfuscator("other-salt").Decode(obfuscator.Encode(1024))
+	assert.Error(t, err)
+	_, err = obfuscator.Decode("!!")
+	assert.Error(t, err)
+
+	// REST extractor
+	hash, err := obfuscator.EncodeString("1024")
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if id, fe := rest.GetObfuscatedIdParam(r, "id", obfuscator); fe != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			_, _ = w.Write([]byte(id))
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/"+hash, nil))
+	assert.Equal(t, "1024", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1024", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
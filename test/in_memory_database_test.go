@@ -3,7 +3,9 @@ package test
 
 import (
 	. "github.com/go-yaaf/yaaf-common/database"
+	. "github.com/go-yaaf/yaaf-common/entity"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -100,3 +102,112 @@ func TestInMemoryDatabase_IsEmpty(t *testing.T) {
 
 	return
 }
+
+func TestInMemoryDatabase_StrictMode(t *testing.T) {
+	db, fe := getInitializedDb()
+	require.NoError(t, fe)
+
+	// Non strict mode: unknown field returns no results
+	list, _, fe := db.Query(NewHero).Filter(F("nickname").Eq("Bat")).Find()
+	require.NoError(t, fe)
+	assert.Equal(t, 0, len(list))
+
+	db.(*InMemoryDatabase).SetStrictMode(true)
+
+	_, _, fe = db.Query(NewHero).Filter(F("nickname").Eq("Bat")).Find()
+	assert.ErrorContains(t, fe, "unknown field: nickname")
+
+	_, fe = db.Query(NewHero).Filter(F("key").Gt("abc")).Count()
+	assert.ErrorContains(t, fe, "abc is not a number")
+
+	_, _, fe = db.Query(NewHero).Filter(F("name").Gt(5)).Find()
+	assert.ErrorContains(t, fe, "non numeric field")
+
+	_, _, fe = db.Query(NewHero).Sort("rank-").Find()
+	assert.ErrorContains(t, fe, "unknown sort field: rank")
+
+	list, _, fe = db.Query(NewHero).Filter(F("key").Lte(5)).MatchAll(F("createdOn").Gt(0)).Sort("name").Find()
+	require.NoError(t, fe)
+	assert.Equal(t, 5, len(list))
+}
+
+func TestInMemoryDatabase_SimpleEntityTable(t *testing.T) {
+	db, fe := NewInMemoryDatabase()
+	require.Nil(t, fe)
+
+	for _, v := range []string{"dark", "light"} {
+		e := &SimpleEntity[string]{Value: v}
+		_, fe = db.Insert(e.WithTable("themes"))
+		require.Nil(t, fe)
+	}
+
+	theme, fe := db.Get(SimpleEntityFactory[string]("themes"), "dark")
+	require.Nil(t, fe)
+	assert.Equal(t, "dark", theme.(*SimpleEntity[string]).Value)
+	assert.Equal(t, "themes", theme.TABLE())
+
+	count, fe := db.Query(SimpleEntityFactory[string]("themes")).Count()
+	require.Nil(t, fe)
+	assert.Equal(t, int64(2), count)
+
+	// Entities list with table and sharding key
+	list := NewEntities[int]().(*Entities[int]).WithTable("scores-{{0}}").WithKey("tenant-1")
+	list.Add(1)
+	assert.Equal(t, "tenant-1", list.KEY())
+	assert.Equal(t, "scores-{{0}}", EntitiesFactory[int]("scores-{{0}}")().TABLE())
+	assert.Equal(t, "dark", (&SimpleEntity[string]{Value: "dark"}).KEY())
+	assert.Equal(t, "k", (&SimpleEntity[string]{Value: "dark"}).WithKey("k").KEY())
+}
+
+func TestInMemoryDatabase_BooleanConditions(t *testing.T) {
+	db, err := getInitializedDb()
+	require.NoError(t, err)
+
+	// Multiple OR groups should all be satisfied
+	_, total, err := db.Query(NewHero).
+		Filter(F("key").Lte(20)).
+		MatchAny(F("name").Like("Bat*"), F("name").Like("Black*")).
+		MatchAny(F("key").Eq(5), F("key").Eq(7), F("key").Eq(9)).
+		Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// (key <= 10 AND (Bat* OR Black*)) OR NOT key < 29
+	query := db.Query(NewHero).Or(
+		And(F("key").Lte(10), Or(F("name").Like("Bat*"), F("name").Like("Black*"))),
+		Not(F("key").Lt(29)),
+	)
+	list, total, err := query.Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), total)
+	ids := make([]string, 0, len(list))
+	for _, ent := range list {
+		ids = append(ids, ent.ID())
+	}
+	assert.ElementsMatch(t, []string{"4", "5", "6", "7", "8", "29", "30"}, ids)
+
+	// Conditions are combined with the other filters, inactive filters are ignored
+	_, total, err = db.Query(NewHero).
+		Filter(F("key").Gt(5)).
+		Not(F("name").Like("Bat*")).
+		And(F("key").Lte(8), F("name").Eq("").If(false)).
+		Or(F("id").Eq("").If(false)).
+		Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// Conditions are serialized
+	data, err := db.Query(NewHero).Not(Or(F("key").Gt(2), F("name").IsEmpty())).ToJson()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"where":[{"not":{"or":[{"field":"key","op":">","values":[2]},{"field":"name","op":"^"}]}}],"page":0,"limit":100}`, string(data))
+	spec, err := FromJson(NewHero, data)
+	require.NoError(t, err)
+	_, total, err = spec.Apply(db.Query(NewHero)).Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	_, err = FromJson(NewHero, []byte(`{"where":[{"not":{"field":"age","op":"=","values":[1]}}]}`))
+	assert.Error(t, err)
+	_, err = FromJson(NewHero, []byte(`{"where":[{"and":[],"field":"key"}]}`))
+	assert.Error(t, err)
+}
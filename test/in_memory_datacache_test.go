@@ -4,7 +4,10 @@ package test
 import (
 	"fmt"
 	. "github.com/go-yaaf/yaaf-common/database"
+	. "github.com/go-yaaf/yaaf-common/entity"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
 	"time"
 )
@@ -66,3 +69,339 @@ func TestInMemoryDataCache_SetWithTTL(t *testing.T) {
 
 	return
 }
+
+func TestInMemoryDataCache_LExpire(t *testing.T) {
+	dc, fe := getInitializedCache()
+	assert.Nil(t, fe, "error initializing DataCache")
+
+	_ = dc.RPush("work_list", list_of_heroes[0:5]...)
+	assert.Equal(t, int64(5), dc.LLen("work_list"))
+
+	ok, fe := dc.LExpire("work_list", time.Millisecond*20)
+	assert.Nil(t, fe, "error")
+	assert.True(t, ok)
+
+	ok, fe = dc.LExpire("no_such_list", time.Millisecond*20)
+	assert.Nil(t, fe, "error")
+	assert.False(t, ok)
+
+	time.Sleep(time.Millisecond * 30)
+	assert.Equal(t, int64(0), dc.LLen("work_list"))
+
+	// Pushing to an expired list creates a new list without expiration
+	_ = dc.LPush("work_list", list_of_heroes[0])
+	assert.Equal(t, int64(1), dc.LLen("work_list"))
+}
+
+func TestInMemoryDataCache_InvalidateTag(t *testing.T) {
+	dc, fe := getInitializedCache()
+	assert.Nil(t, fe, "error initializing DataCache")
+
+	for i, h := range list_of_heroes[0:6] {
+		tenant := fmt.Sprintf("tenant-%d", i%2)
+		fe = dc.SetWithTags("query:"+h.ID(), h, "hero", tenant)
+		assert.Nil(t, fe, "error")
+	}
+
+	count, fe := dc.InvalidateTag("tenant-0")
+	assert.Nil(t, fe, "error")
+	assert.Equal(t, int64(3), count)
+
+	exists, _ := dc.Exists("query:1")
+	assert.False(t, exists)
+	exists, _ = dc.Exists("query:2")
+	assert.True(t, exists)
+
+	// Keys already invalidated by another tag are not counted
+	count, _ = dc.InvalidateTag("hero")
+	assert.Equal(t, int64(3), count)
+	count, _ = dc.InvalidateTag("hero")
+	assert.Equal(t, int64(0), count)
+
+	// Deleted and expired keys are removed from the tags, so keys set again without tags are not invalidated
+	require.NoError(t, dc.SetWithTags("query:deleted", list_of_heroes[0], "reset"))
+	require.NoError(t, dc.SetWithTags("query:expired", list_of_heroes[1], "reset"))
+	require.NoError(t, dc.SetRaw("query:expired", []byte("{}"), 20*time.Millisecond))
+	require.NoError(t, dc.Del("query:deleted"))
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, dc.Set("query:deleted", list_of_heroes[0]))
+	require.NoError(t, dc.Set("query:expired", list_of_heroes[1]))
+	count, _ = dc.InvalidateTag("reset")
+	assert.Equal(t, int64(0), count)
+	exists, _ = dc.Exists("query:deleted")
+	assert.True(t, exists)
+	exists, _ = dc.Exists("query:expired")
+	assert.True(t, exists)
+}
+
+func TestInMemoryDataCache_GetKeysTyped(t *testing.T) {
+	dc, fe := getInitializedCache()
+	assert.Nil(t, fe, "error initializing DataCache")
+
+	_ = dc.Set("config", NewSimpleEntity[string]())
+
+	result, fe := dc.GetKeysTyped(map[string]EntityFactory{
+		"1":       NewHero,
+		"2":       NewHero,
+		"config":  NewSimpleEntity[string],
+		"missing": NewHero,
+	})
+	assert.Nil(t, fe, "error")
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, "Ant man", result["1"].(*Hero).Name)
+	_, ok := result["config"].(*SimpleEntity[string])
+	assert.True(t, ok)
+}
+
+func TestInMemoryDataCache_Incr(t *testing.T) {
+	dc, fe := NewInMemoryDataCache()
+	assert.Nil(t, fe, "error initializing DataCache")
+
+	v, fe := dc.Incr("counter", 5)
+	assert.Nil(t, fe, "error")
+	assert.Equal(t, int64(5), v)
+	v, fe = dc.Incr("counter", -2, time.Minute)
+	assert.Nil(t, fe, "error")
+	assert.Equal(t, int64(3), v)
+
+	raw, fe := dc.GetRaw("counter")
+	assert.Nil(t, fe, "error")
+	assert.Equal(t, "3", string(raw))
+
+	_ = dc.SetRaw("text", []byte("abc"))
+	_, fe = dc.Incr("text", 1)
+	assert.NotNil(t, fe, "expected error")
+}
+
+func TestInMemoryDataCache_ListOperations(t *testing.T) {
+	dc, fe := getInitializedCache()
+	require.NoError(t, fe)
+
+	ids := func(list []Entity) []string {
+		result := make([]string, 0, len(list))
+		for _, ent := range list {
+			result = append(result, ent.ID())
+		}
+		return result
+	}
+	lrange := func(start, stop int64) []string {
+		list, err := dc.LRange(NewHero, "heroes_list", start, stop)
+		require.NoError(t, err)
+		return ids(list)
+	}
+
+	_ = dc.RPush("heroes_list", list_of_heroes[0:6]...)
+
+	// Redis-style indexes
+	assert.Equal(t, []string{"1", "2", "3", "4", "5", "6"}, lrange(0, -1))
+	assert.Equal(t, []string{"5", "6"}, lrange(-2, -1))
+	assert.Equal(t, []string{"2", "3"}, lrange(1, 2))
+	assert.Equal(t, []string{"1"}, lrange(0, 0))
+	assert.Equal(t, []string{"4", "5", "6"}, lrange(3, 100))
+	assert.Equal(t, []string{"1", "2"}, lrange(-100, 1))
+	assert.Empty(t, lrange(4, 2))
+	assert.Empty(t, lrange(10, 20))
+
+	// Set
+	require.NoError(t, dc.LSet("heroes_list", -1, list_of_heroes[0]))
+	require.NoError(t, dc.LSet("heroes_list", 2, list_of_heroes[0]))
+	assert.Error(t, dc.LSet("heroes_list", 6, list_of_heroes[0]))
+	assert.Error(t, dc.LSet("no_such_list", 0, list_of_heroes[0]))
+	assert.Equal(t, []string{"1", "2", "1", "4", "5", "1"}, lrange(0, -1))
+
+	// Remove
+	removed, err := dc.LRem("heroes_list", -1, list_of_heroes[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	assert.Equal(t, []string{"1", "2", "1", "4", "5"}, lrange(0, -1))
+	removed, err = dc.LRem("heroes_list", 0, list_of_heroes[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+	assert.Equal(t, []string{"2", "4", "5"}, lrange(0, -1))
+
+	// Trim
+	require.NoError(t, dc.LTrim("heroes_list", 1, -1))
+	assert.Equal(t, []string{"4", "5"}, lrange(0, -1))
+	require.NoError(t, dc.LTrim("heroes_list", 5, 10))
+	assert.Equal(t, int64(0), dc.LLen("heroes_list"))
+	require.NoError(t, dc.LTrim("no_such_list", 0, 1))
+}
+
+func TestInMemoryDataCache_ConcurrentPushTrim(t *testing.T) {
+	dc, fe := NewInMemoryDataCache()
+	require.NoError(t, fe)
+
+	const pushes = 1000
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < pushes; i++ {
+			_ = dc.RPush("concurrent_list", list_of_heroes[0])
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < pushes; i++ {
+			_ = dc.LTrim("concurrent_list", -10, -1)
+		}
+	}()
+	wg.Wait()
+
+	// The last pushed values must survive the trims
+	assert.Greater(t, dc.LLen("concurrent_list"), int64(0))
+	assert.LessOrEqual(t, dc.LLen("concurrent_list"), int64(pushes))
+
+	// Values pushed after the list was trimmed to empty are kept
+	require.NoError(t, dc.LTrim("concurrent_list", 1, 0))
+	assert.Equal(t, int64(0), dc.LLen("concurrent_list"))
+	require.NoError(t, dc.RPush("concurrent_list", list_of_heroes[0:2]...))
+	assert.Equal(t, int64(2), dc.LLen("concurrent_list"))
+
+	// Pop from both sides
+	entity, err := dc.LPop(NewHero, "concurrent_list")
+	require.NoError(t, err)
+	assert.Equal(t, "1", entity.ID())
+	entity, err = dc.RPop(NewHero, "concurrent_list")
+	require.NoError(t, err)
+	assert.Equal(t, "2", entity.ID())
+	_, err = dc.RPop(NewHero, "concurrent_list")
+	assert.Error(t, err)
+}
+
+func TestInMemoryDataCache_Scan(t *testing.T) {
+	dc, fe := getInitializedCache()
+	require.NoError(t, fe)
+
+	// Iterate all the keys using the cursor
+	all := make([]string, 0)
+	cursor, iterations := uint64(0), 0
+	for {
+		keys, next, err := dc.Scan(cursor, "", 7)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(keys), 7+1)
+		all = append(all, keys...)
+		iterations += 1
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	assert.Equal(t, len(list_of_heroes), len(all))
+	assert.GreaterOrEqual(t, iterations, (len(list_of_heroes)+6)/7)
+
+	// Glob pattern
+	keys, cursor, err := dc.Scan(0, "1?", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), cursor)
+	assert.ElementsMatch(t, []string{"10", "11", "12", "13", "14", "15", "16", "17", "18", "19"}, keys)
+
+	keys, _, err = dc.Scan(0, "[23]*", 1000)
+	require.NoError(t, err)
+	assert.Len(t, keys, 13)
+
+	keys, _, err = dc.Scan(0, "[^0-2]", 1000)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"3", "4", "5", "6", "7", "8", "9"}, keys)
+
+	// Keys existing during the whole iteration are returned even if other keys are deleted
+	first, cursor, err := dc.Scan(0, "*", 10)
+	require.NoError(t, err)
+	require.NotEqual(t, uint64(0), cursor)
+	for _, k := range first {
+		_ = dc.Del(k)
+	}
+	rest := make([]string, 0)
+	for cursor != 0 {
+		keys, cursor, err = dc.Scan(cursor, "*", 10)
+		require.NoError(t, err)
+		rest = append(rest, keys...)
+	}
+	assert.Equal(t, len(list_of_heroes)-len(first), len(rest))
+}
+
+func TestInMemoryDataCache_Hash(t *testing.T) {
+	dc, fe := getInitializedCache()
+	require.NoError(t, fe)
+
+	for _, h := range list_of_heroes[0:12] {
+		require.NoError(t, dc.HSet("heroes", h.ID(), h))
+	}
+	require.NoError(t, dc.HSet("heroes_other", "1", list_of_heroes[0]))
+
+	fields, err := dc.HKeys("heroes")
+	require.NoError(t, err)
+	assert.Len(t, fields, 12)
+	assert.Contains(t, fields, "11")
+	assert.NotContains(t, fields, "heroes@11")
+
+	// The hash values are mapped by the field name (without the hash key)
+	all, err := dc.HGetAll(NewHero, "heroes")
+	require.NoError(t, err)
+	assert.Len(t, all, 12)
+	assert.Equal(t, "Cat Woman", all["11"].(*Hero).Name)
+
+	require.NoError(t, dc.HSetRaw("settings", "MAX_USERS", []byte("500")))
+	raw, err := dc.HGetRawAll("settings")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"MAX_USERS": []byte("500")}, raw)
+}
+
+func TestInMemoryDataCache_Codec(t *testing.T) {
+	codecs := []IEntityCodec{NewJsonCodec(), NewBinaryCodec(), NewGobCodec(), NewMsgPackCodec()}
+
+	for _, codec := range codecs {
+		t.Run(codec.Name(), func(t *testing.T) {
+			dc, fe := NewInMemoryDataCache()
+			require.NoError(t, fe)
+			dc.(*InMemoryDataCache).SetCodec(codec)
+
+			hero := &Hero{BaseEntity: BaseEntity{Id: "1", CreatedOn: 1700000000000}, Key: 1, Name: "Ant Man"}
+			require.NoError(t, dc.Set("hero:1", hero))
+
+			ent, err := dc.Get(NewHero, "hero:1")
+			require.NoError(t, err)
+			assert.Equal(t, hero, ent)
+
+			// Raw value set by Set is encoded by the codec
+			raw, err := dc.GetRaw("hero:1")
+			require.NoError(t, err)
+			ent, err = codec.Decode(NewHero, raw)
+			require.NoError(t, err)
+			assert.Equal(t, hero, ent)
+
+			// Raw value encoded by the codec is read by Get
+			other := &Hero{BaseEntity: BaseEntity{Id: "2"}, Key: -200, Name: "Black Panther"}
+			raw, err = codec.Encode(other)
+			require.NoError(t, err)
+			require.NoError(t, dc.SetRaw("hero:2", raw))
+			ent, err = dc.Get(NewHero, "hero:2")
+			require.NoError(t, err)
+			assert.Equal(t, other, ent)
+
+			require.NoError(t, dc.Rename("hero:2", "hero:3"))
+			ent, err = dc.Get(NewHero, "hero:3")
+			require.NoError(t, err)
+			assert.Equal(t, other, ent)
+
+			require.NoError(t, dc.HSet("heroes", "1", hero))
+			all, err := dc.HGetAll(NewHero, "heroes")
+			require.NoError(t, err)
+			assert.Equal(t, map[string]Entity{"1": hero}, all)
+
+			// List values are compared by their codec encoding
+			require.NoError(t, dc.RPush("list", hero, other, hero))
+			copied := *hero
+			removed, err := dc.LRem("list", 0, &copied)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), removed)
+			assert.Equal(t, int64(1), dc.LLen("list"))
+
+			_, err = dc.Get(nil, "hero:1")
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := NewMsgPackCodec().Decode(NewHero, []byte{0x82, 0xa2, 'i', 'd'})
+	assert.Error(t, err)
+}
@@ -7,6 +7,9 @@ import (
 	. "github.com/go-yaaf/yaaf-common/messaging"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -159,3 +162,509 @@ func subscriberCallback(msg IMessage) bool {
 	fmt.Println(msg.Topic(), msg.OpCode(), msg.SessionId(), hero.Id, hero.Name)
 	return true
 }
+
+func TestInMemoryMessageBus_Interceptors(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	published, consumed := 0, 0
+	bus.Use(NewMessageInterceptor(
+		func(next PublishFunc) PublishFunc {
+			return func(messages ...IMessage) error {
+				for _, msg := range messages {
+					if msg.Topic() == "forbidden" {
+						return fmt.Errorf("topic %s is forbidden", msg.Topic())
+					}
+				}
+				published += len(messages)
+				return next(messages...)
+			}
+		},
+		func(next SubscriptionCallback) SubscriptionCallback {
+			return func(msg IMessage) bool {
+				consumed += 1
+				return next(msg)
+			}
+		},
+	))
+
+	received := make(chan IMessage, 1)
+	_, err = bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+		received <- msg
+		return true
+	}, "heroes")
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", list_of_heroes[0].(*Hero))))
+	require.Error(t, bus.Publish(GetMessage[*Hero]("forbidden", list_of_heroes[0].(*Hero))))
+
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		require.Fail(t, "message was not delivered")
+	}
+	assert.Equal(t, 1, published)
+	assert.Equal(t, 1, consumed)
+}
+
+func TestInMemoryMessageBus_Unsubscribe(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	// Subscribe and unsubscribe in a loop, detached subscribers must not block Publish
+	for i := 0; i < 100; i++ {
+		subId, fe := bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool { return true }, "heroes")
+		require.NoError(t, fe)
+		require.True(t, bus.Unsubscribe(subId))
+		require.False(t, bus.Unsubscribe(subId))
+	}
+
+	received := make(chan IMessage, 2000)
+	subId, err := bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+		received <- msg
+		return true
+	}, "heroes")
+	require.NoError(t, err)
+
+	for i := 0; i < 1500; i++ {
+		require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", list_of_heroes[0].(*Hero))))
+	}
+	require.Eventually(t, func() bool { return len(received) == 1500 }, time.Second, time.Millisecond*10)
+
+	require.True(t, bus.Unsubscribe(subId))
+	require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", list_of_heroes[0].(*Hero))))
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, 1500, len(received))
+}
+
+func TestInMemoryMessageBus_OverflowPolicy(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDropOldest, OverflowDropNew} {
+		bus, err := NewInMemoryMessageBusWithOptions(InMemoryMessageBusOptions{
+			BufferSize:   5,
+			Overflow:     policy,
+			BlockTimeout: time.Millisecond * 10,
+		})
+		require.NoError(t, err)
+
+		// Block the subscriber until all messages are published
+		started, release := make(chan struct{}, 20), make(chan struct{})
+		received := make(chan string, 20)
+		subId, err := bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+			started <- struct{}{}
+			<-release
+			received <- msg.(*Message[*Hero]).MsgPayload.Id
+			return true
+		}, "heroes")
+		require.NoError(t, err)
+
+		require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", list_of_heroes[0].(*Hero))))
+		<-started
+		for i := 1; i < 10; i++ {
+			require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", list_of_heroes[i].(*Hero))))
+		}
+		close(release)
+
+		// The first message is held by the subscriber, 5 are buffered and the other 4 are dropped
+		inMemoryBus := bus.(*InMemoryMessageBus)
+		assert.Equal(t, int64(4), inMemoryBus.DroppedMessages(), "policy %d", policy)
+		assert.Equal(t, int64(4), inMemoryBus.SubscriberDroppedMessages(subId), "policy %d", policy)
+
+		require.Eventually(t, func() bool { return len(received) == 6 }, time.Second, time.Millisecond*10)
+		ids := make([]string, 0, 6)
+		for len(received) > 0 {
+			ids = append(ids, <-received)
+		}
+		if policy == OverflowDropOldest {
+			assert.Equal(t, []string{"1", "6", "7", "8", "9", "10"}, ids)
+		} else {
+			assert.Equal(t, []string{"1", "2", "3", "4", "5", "6"}, ids)
+		}
+		require.NoError(t, bus.Close())
+	}
+}
+
+func TestInMemoryMessageBus_SubscribeWithAck(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	// Nack the first delivery, ignore the second (visibility timeout) and ack the third
+	attempts := make(chan int, 10)
+	_, err = bus.SubscribeWithAck("subscriber", NewMessage[*Hero], func(msg IMessage, delivery IDelivery) {
+		attempts <- delivery.Attempt()
+		switch delivery.Attempt() {
+		case 1:
+			delivery.Nack()
+		case 3:
+			delivery.Ack()
+			delivery.Nack()
+		}
+	}, AckOptions{VisibilityTimeout: time.Millisecond * 20}, "heroes")
+	require.NoError(t, err)
+
+	// Always nack, messages exceeding max deliveries are sent to the dead letter topic
+	deadLetters := make(chan IMessage, 1)
+	_, err = bus.Subscribe("dead-letters", NewMessage[*Hero], func(msg IMessage) bool {
+		deadLetters <- msg
+		return true
+	}, "heroes-dlq")
+	require.NoError(t, err)
+
+	_, err = bus.SubscribeWithAck("subscriber", NewMessage[*Hero], func(msg IMessage, delivery IDelivery) {
+		delivery.Nack()
+	}, AckOptions{MaxDeliveries: 2, DeadLetterTopic: "heroes-dlq"}, "villains")
+	require.NoError(t, err)
+
+	require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", list_of_heroes[0].(*Hero))))
+	require.NoError(t, bus.Publish(GetMessage[*Hero]("villains", list_of_heroes[1].(*Hero))))
+
+	select {
+	case msg := <-deadLetters:
+		assert.Equal(t, "2", msg.(*Message[*Hero]).MsgPayload.Id)
+	case <-time.After(time.Second):
+		require.Fail(t, "message was not sent to dead letter topic")
+	}
+
+	require.Eventually(t, func() bool { return len(attempts) == 3 }, time.Second, time.Millisecond*5)
+	time.Sleep(time.Millisecond * 50)
+	require.Equal(t, 3, len(attempts))
+	assert.Equal(t, []int{1, 2, 3}, []int{<-attempts, <-attempts, <-attempts})
+	require.NoError(t, bus.Close())
+}
+
+func TestInMemoryMessageBus_SubscribeWithAckNoHeadOfLineBlocking(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+	defer func() { _ = bus.Close() }()
+
+	// The first message is never acknowledged, the next messages are delivered while it waits for redelivery
+	received := make(chan string, 100)
+	_, err = bus.SubscribeWithAck("subscriber", NewMessage[*Hero], func(msg IMessage, delivery IDelivery) {
+		id := msg.(*Message[*Hero]).MsgPayload.Id
+		received <- fmt.Sprintf("%s:%d", id, delivery.Attempt())
+		if id != "1" {
+			delivery.Ack()
+		}
+	}, AckOptions{VisibilityTimeout: 50 * time.Millisecond, RedeliveryDelay: time.Second, MaxDeliveries: 2}, "heroes")
+	require.NoError(t, err)
+
+	for _, hero := range list_of_heroes[0:3] {
+		require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", hero.(*Hero))))
+	}
+	deliveries := make([]string, 0)
+	for len(deliveries) < 3 {
+		select {
+		case d := <-received:
+			deliveries = append(deliveries, d)
+		case <-time.After(500 * time.Millisecond):
+			require.Fail(t, "messages were blocked by unacknowledged message", "deliveries: %v", deliveries)
+		}
+	}
+	assert.Equal(t, []string{"1:1", "2:1", "3:1"}, deliveries)
+
+	// The unacknowledged message is redelivered after the delay
+	select {
+	case d := <-received:
+		assert.Equal(t, "1:2", d)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "message was not redelivered")
+	}
+}
+
+func TestInMemoryMessageBus_SubscribeWithAckMaxInFlight(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+	defer func() { _ = bus.Close() }()
+
+	deliveries := make(chan IDelivery, 10)
+	_, err = bus.SubscribeWithAck("subscriber", NewMessage[*Hero], func(msg IMessage, delivery IDelivery) {
+		deliveries <- delivery
+	}, AckOptions{MaxInFlight: 1}, "heroes")
+	require.NoError(t, err)
+
+	for _, hero := range list_of_heroes[0:2] {
+		require.NoError(t, bus.Publish(GetMessage[*Hero]("heroes", hero.(*Hero))))
+	}
+
+	// The second message is delivered only after the first is acknowledged
+	first := <-deliveries
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, len(deliveries))
+	first.Ack()
+	select {
+	case <-deliveries:
+	case <-time.After(time.Second):
+		require.Fail(t, "message was not delivered after ack")
+	}
+}
+
+func TestInMemoryMessageBus_TopicPatterns(t *testing.T) {
+	assert.True(t, MatchTopic("events.*", "events.created"))
+	assert.False(t, MatchTopic("events.*", "events.user.created"))
+	assert.True(t, MatchTopic("device.+.status", "device.123.status"))
+	assert.False(t, MatchTopic("device.+.status", "device.123.config"))
+	assert.True(t, MatchTopic("events.#", "events.user.created"))
+	assert.False(t, MatchTopic("events.#", "events"))
+	assert.False(t, MatchTopic("events.#.created", "events.user.created"))
+
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	received := make(chan string, 10)
+	subId, err := bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+		received <- msg.Topic()
+		return true
+	}, "device.+.status", "device.1.status")
+	require.NoError(t, err)
+
+	for _, topic := range []string{"device.1.status", "device.2.status", "device.2.config"} {
+		require.NoError(t, bus.Publish(GetMessage[*Hero](topic, list_of_heroes[0].(*Hero))))
+	}
+
+	require.Eventually(t, func() bool { return len(received) == 2 }, time.Second, time.Millisecond*5)
+	time.Sleep(time.Millisecond * 20)
+	require.Equal(t, 2, len(received))
+	assert.ElementsMatch(t, []string{"device.1.status", "device.2.status"}, []string{<-received, <-received})
+
+	require.True(t, bus.Unsubscribe(subId))
+	require.NoError(t, bus.Publish(GetMessage[*Hero]("device.3.status", list_of_heroes[0].(*Hero))))
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, 0, len(received))
+}
+
+func TestInMemoryMessageBus_OrderingKey(t *testing.T) {
+	bus, err := NewInMemoryMessageBusWithOptions(InMemoryMessageBusOptions{Concurrency: 4})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	received := make(map[string][]int)
+	count := 0
+	_, err = bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+		hero := msg.(*Message[*Hero]).MsgPayload
+		time.Sleep(time.Duration(hero.Key%3) * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		received[msg.OrderingKey()] = append(received[msg.OrderingKey()], hero.Key)
+		count += 1
+		return true
+	}, "heroes")
+	require.NoError(t, err)
+
+	keys := []string{"alpha", "beta", "gamma"}
+	for i := 0; i < 30; i++ {
+		for _, key := range keys {
+			hero := NewHero1(fmt.Sprintf("%s-%d", key, i), i, key).(*Hero)
+			require.NoError(t, bus.Publish(GetOrderedMessage[*Hero]("heroes", key, hero)))
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return count == 90
+	}, time.Second*5, time.Millisecond*10)
+
+	for _, key := range keys {
+		for i, seq := range received[key] {
+			require.Equal(t, i, seq, "message out of order for key %s", key)
+		}
+	}
+}
+
+func TestInMemoryMessageBus_Headers(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	newMessage := func(topic string) IMessage {
+		msg := GetMessage[*Hero](topic, list_of_heroes[0].(*Hero)).(*Message[*Hero])
+		msg.SetHeader("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		msg.SetHeader("tenant-id", "tenant-1")
+		return msg
+	}
+
+	received := make(chan IMessage, 1)
+	_, err = bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+		received <- msg
+		return true
+	}, "heroes")
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(newMessage("heroes")))
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "tenant-1", msg.Headers()["tenant-id"])
+		assert.Equal(t, 2, len(msg.Headers()))
+	case <-time.After(time.Second):
+		require.Fail(t, "message was not delivered")
+	}
+
+	require.NoError(t, bus.Push(newMessage("heroes_queue")))
+	msg, err := bus.Pop(NewMessage[*Hero], 0, "heroes_queue")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", msg.Headers()["tenant-id"])
+}
+
+func TestInMemoryMessageBus_BlockedPublishReleasesLock(t *testing.T) {
+	bus, err := NewInMemoryMessageBusWithOptions(InMemoryMessageBusOptions{BufferSize: 1, Overflow: OverflowBlock, BlockTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	defer func() { _ = bus.Close() }()
+
+	// The callback subscribes while the publisher is blocked on the full subscriber buffer
+	elapsed := make(chan time.Duration, 1)
+	var once sync.Once
+	_, err = bus.Subscribe("subscriber", NewHeroMessage, func(msg IMessage) bool {
+		once.Do(func() {
+			time.Sleep(50 * time.Millisecond)
+			start := time.Now()
+			if id, er := bus.Subscribe("other", NewHeroMessage, func(msg IMessage) bool { return true }, "other_topic"); er == nil {
+				bus.Unsubscribe(id)
+			}
+			elapsed <- time.Since(start)
+		})
+		return true
+	}, "heroes_topic")
+	require.NoError(t, err)
+
+	go func() {
+		for i := 0; i < 4; i++ {
+			_ = bus.Publish(newHeroMessage("heroes_topic", list_of_heroes[i].(*Hero)))
+		}
+	}()
+
+	select {
+	case d := <-elapsed:
+		assert.Less(t, d, time.Second)
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "subscribe was blocked by the publisher")
+	}
+}
+
+func TestInMemoryMessageBus_Journal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus.journal")
+	options := InMemoryMessageBusOptions{JournalPath: path}
+
+	bus, err := NewInMemoryMessageBusWithOptions(options)
+	require.NoError(t, err)
+	require.NoError(t, bus.Push(newHeroMessage("heroes_queue", list_of_heroes[0].(*Hero)), newHeroMessage("heroes_queue", list_of_heroes[1].(*Hero))))
+	_, err = bus.Pop(NewHeroMessage, 0, "heroes_queue")
+	require.NoError(t, err)
+	require.NoError(t, bus.Publish(newHeroMessage("heroes_topic", list_of_heroes[2].(*Hero))))
+	require.NoError(t, bus.Close())
+
+	// Simulate restart: the unconsumed queue message and the retained topic message are replayed
+	bus, err = NewInMemoryMessageBusWithOptions(options)
+	require.NoError(t, err)
+	defer func() { _ = bus.Close() }()
+
+	// Pop without a factory fails and keeps the replayed message in the queue
+	_, err = bus.Pop(nil, 0, "heroes_queue")
+	require.ErrorContains(t, err, "message factory is required")
+
+	msg, err := bus.Pop(NewHeroMessage, 0, "heroes_queue")
+	require.NoError(t, err)
+	assert.Equal(t, list_of_heroes[1].ID(), msg.Payload().(*Hero).ID())
+	_, err = bus.Pop(NewHeroMessage, 0, "heroes_queue")
+	assert.Error(t, err)
+
+	received := make(chan IMessage, 1)
+	_, err = bus.Subscribe("subscriber", NewHeroMessage, func(msg IMessage) bool {
+		received <- msg
+		return true
+	}, "heroes_topic")
+	require.NoError(t, err)
+
+	select {
+	case msg = <-received:
+		assert.Equal(t, list_of_heroes[2].ID(), msg.Payload().(*Hero).ID())
+	case <-time.After(time.Second):
+		require.Fail(t, "retained message was not delivered")
+	}
+}
+
+func TestInMemoryMessageBus_JournalCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bus.journal")
+	options := InMemoryMessageBusOptions{JournalPath: path}
+
+	bus, err := NewInMemoryMessageBusWithOptions(options)
+	require.NoError(t, err)
+	require.NoError(t, bus.Push(newHeroMessage("heroes_queue", list_of_heroes[0].(*Hero)), newHeroMessage("heroes_queue", list_of_heroes[1].(*Hero))))
+	require.NoError(t, bus.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Equal(t, 2, len(lines))
+
+	// Corrupt line in the middle fails the replay and the journal is not compacted
+	corrupt := lines[0] + "{\"op\":\"push\",\n" + lines[1] + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(corrupt), 0644))
+	_, err = NewInMemoryMessageBusWithOptions(options)
+	require.ErrorContains(t, err, "journal line 2")
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, corrupt, string(data))
+
+	// Partial last line is ignored
+	require.NoError(t, os.WriteFile(path, []byte(lines[0]+lines[1]+"\n{\"op\":\"pu"), 0644))
+	bus, err = NewInMemoryMessageBusWithOptions(options)
+	require.NoError(t, err)
+	defer func() { _ = bus.Close() }()
+	for i := 0; i < 2; i++ {
+		msg, er := bus.Pop(NewHeroMessage, 0, "heroes_queue")
+		require.NoError(t, er)
+		assert.Equal(t, list_of_heroes[i].ID(), msg.Payload().(*Hero).ID())
+	}
+}
+
+func TestInMemoryMessageBus_Stats(t *testing.T) {
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+	defer func() { _ = bus.Close() }()
+
+	release := make(chan struct{})
+	delivered := make(chan struct{}, 3)
+	_, err = bus.Subscribe("subscriber", NewHeroMessage, func(msg IMessage) bool {
+		<-release
+		delivered <- struct{}{}
+		return true
+	}, "heroes_topic")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, bus.Publish(newHeroMessage("heroes_topic", list_of_heroes[i].(*Hero))))
+	}
+	require.NoError(t, bus.Push(newHeroMessage("heroes_queue", list_of_heroes[0].(*Hero)), newHeroMessage("heroes_queue", list_of_heroes[1].(*Hero))))
+	_, err = bus.Pop(NewHeroMessage, 0, "heroes_queue")
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+	stats, err := bus.Stats()
+	require.NoError(t, err)
+
+	topic := stats.Topics["heroes_topic"]
+	assert.Equal(t, int64(3), topic.Published)
+	assert.Equal(t, int64(1), topic.Delivered)
+	assert.Equal(t, int64(2), topic.Pending)
+	assert.Equal(t, 1, topic.Consumers)
+	assert.True(t, topic.OldestMessageAge > 0)
+
+	queue := stats.Queues["heroes_queue"]
+	assert.Equal(t, int64(2), queue.Pushed)
+	assert.Equal(t, int64(1), queue.Popped)
+	assert.Equal(t, int64(1), queue.Pending)
+	assert.True(t, queue.OldestMessageAge > 0)
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		<-delivered
+	}
+	stats, err = bus.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Topics["heroes_topic"].Delivered)
+	assert.Equal(t, int64(0), stats.Topics["heroes_topic"].Pending)
+
+	sb := strings.Builder{}
+	require.NoError(t, WritePrometheusStats(&sb, "yaaf_messaging", stats))
+	assert.Contains(t, sb.String(), `yaaf_messaging_topic_published_total{topic="heroes_topic"} 3`)
+	assert.Contains(t, sb.String(), `yaaf_messaging_queue_pending_messages{queue="heroes_queue"} 1`)
+}
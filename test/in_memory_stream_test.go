@@ -0,0 +1,148 @@
+package test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/go-yaaf/yaaf-common/messaging"
+	"github.com/go-yaaf/yaaf-common/messaging/streaming"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryStream_ReadCommitReplay(t *testing.T) {
+	sp, err := streaming.NewInMemoryStreamProcessor()
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	offset, err := sp.Append(newHeroMessage("heroes", list_of_heroes[0].(*Hero)), newHeroMessage("heroes", list_of_heroes[1].(*Hero)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), offset)
+
+	time.Sleep(10 * time.Millisecond)
+	replayFrom := time.Now()
+	_, err = sp.Append(newHeroMessage("heroes", list_of_heroes[2].(*Hero)))
+	require.NoError(t, err)
+
+	records, err := sp.Read("heroes", "group", NewHeroMessage, 2, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(records))
+	assert.Equal(t, list_of_heroes[1].ID(), records[1].Message.Payload().(*Hero).ID())
+	require.NoError(t, sp.Commit("heroes", "group", records[1].Offset))
+
+	committed, err := sp.Committed("heroes", "group")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), committed)
+
+	// Replay from timestamp
+	offset, err = sp.SeekToTimestamp("heroes", "replay", replayFrom)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), offset)
+	records, err = sp.Read("heroes", "replay", NewHeroMessage, 0, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(records))
+	assert.Equal(t, list_of_heroes[2].ID(), records[0].Message.Payload().(*Hero).ID())
+
+	// Blocking read returns when a record is appended
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = sp.Append(newHeroMessage("heroes", list_of_heroes[3].(*Hero)))
+	}()
+	records, err = sp.Read("heroes", "replay", NewHeroMessage, 0, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(records))
+}
+
+func TestInMemoryStream_ConsumeWindow(t *testing.T) {
+	sp, err := streaming.NewInMemoryStreamProcessor()
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	var failures atomic.Int32
+	windows := make(chan []streaming.StreamRecord, 10)
+	id, err := sp.ConsumeWindow("heroes", "group", NewHeroMessage, streaming.WindowOptions{Size: 100 * time.Millisecond, MaxRecords: 3}, func(records []streaming.StreamRecord) error {
+		// Fail the first window to verify redelivery
+		if failures.Add(1) == 1 {
+			return fmt.Errorf("failed")
+		}
+		windows <- records
+		return nil
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err = sp.Append(newHeroMessage("heroes", list_of_heroes[i].(*Hero)))
+		require.NoError(t, err)
+	}
+
+	received := 0
+	for received < 5 {
+		select {
+		case records := <-windows:
+			assert.True(t, len(records) <= 3)
+			assert.Equal(t, int64(received), records[0].Offset)
+			received += len(records)
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "window was not delivered")
+		}
+	}
+
+	committed, err := sp.Committed("heroes", "group")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), committed)
+	assert.True(t, sp.StopConsumer(id))
+}
+
+// poisonMessage is a message which can't be unmarshalled as HeroMessage
+type poisonMessage struct {
+	BaseMessage
+	Hero string `json:"hero"`
+}
+
+func (m *poisonMessage) Payload() any { return m.Hero }
+
+func TestInMemoryStream_SkipPoisonRecord(t *testing.T) {
+	sp, err := streaming.NewInMemoryStreamProcessor()
+	require.NoError(t, err)
+	defer func() { _ = sp.Close() }()
+
+	poison := &poisonMessage{Hero: "poison"}
+	poison.MsgTopic = "heroes"
+	_, err = sp.Append(newHeroMessage("heroes", list_of_heroes[0].(*Hero)), poison, newHeroMessage("heroes", list_of_heroes[1].(*Hero)))
+	require.NoError(t, err)
+
+	// The poison record is reported and skipped
+	records, err := sp.Read("heroes", "group", NewHeroMessage, 0, time.Second)
+	require.Error(t, err)
+	require.Equal(t, 1, len(records))
+	assert.Equal(t, int64(0), records[0].Offset)
+
+	records, err = sp.Read("heroes", "group", NewHeroMessage, 0, time.Second)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(records))
+	assert.Equal(t, int64(2), records[0].Offset)
+
+	// The windowed consumer is not blocked by the poison record
+	windows := make(chan []streaming.StreamRecord, 10)
+	id, err := sp.ConsumeWindow("heroes", "windows", NewHeroMessage, streaming.WindowOptions{Size: 100 * time.Millisecond, Backoff: 10 * time.Millisecond}, func(records []streaming.StreamRecord) error {
+		windows <- records
+		return nil
+	})
+	require.NoError(t, err)
+	defer sp.StopConsumer(id)
+
+	offsets := make([]int64, 0)
+	for len(offsets) < 2 {
+		select {
+		case records := <-windows:
+			for _, record := range records {
+				offsets = append(offsets, record.Offset)
+			}
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "window was not delivered")
+		}
+	}
+	assert.Equal(t, []int64{0, 2}, offsets)
+}
@@ -0,0 +1,162 @@
+// JSON utils tests
+
+package test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJsonUtils_Stream(t *testing.T) {
+	entities := make(chan Entity)
+	go func() {
+		defer close(entities)
+		for _, h := range list_of_heroes {
+			entities <- h
+		}
+	}()
+
+	buffer := &bytes.Buffer{}
+	count, err := utils.JsonUtils().StreamEncode(buffer, entities)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(list_of_heroes)), count)
+	assert.Equal(t, len(list_of_heroes), strings.Count(buffer.String(), "\n"))
+
+	decoded, errs := utils.JsonUtils().StreamDecode(buffer, NewHero)
+	result := make([]Entity, 0)
+	for e := range decoded {
+		result = append(result, e)
+	}
+	assert.NoError(t, <-errs)
+	require.Equal(t, len(list_of_heroes), len(result))
+	assert.Equal(t, "Ant man", result[0].(*Hero).Name)
+
+	// Invalid line stops the decoding
+	decoded, errs = utils.JsonUtils().StreamDecode(strings.NewReader("{\"name\":\"a\"}\n\nnot json\n{\"name\":\"b\"}"), NewHero)
+	result = result[:0]
+	for e := range decoded {
+		result = append(result, e)
+	}
+	err = <-errs
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+	assert.Equal(t, 1, len(result))
+}
+
+type mapTestAddress struct {
+	City string `json:"city"`
+}
+
+type mapTestEntity struct {
+	BaseEntity
+	Name     string          `json:"name"`
+	Tags     []string        `json:"tags"`
+	Score    float64         `json:"score,omitempty"`
+	Address  mapTestAddress  `json:"address"`
+	Previous *mapTestAddress `json:"previous"`
+	Skip     string          `json:"-"`
+	internal string
+}
+
+func TestJsonUtils_ToMapFromMap(t *testing.T) {
+	source := &mapTestEntity{
+		BaseEntity: BaseEntity{Id: "1", CreatedOn: 1000},
+		Name:       "Ant man",
+		Tags:       []string{"small"},
+		Address:    mapTestAddress{City: "San Francisco"},
+		Skip:       "skip",
+	}
+
+	raw, err := utils.JsonUtils().ToMap(source)
+	require.NoError(t, err)
+	assert.Equal(t, "1", raw["id"])
+	assert.Equal(t, int64(1000), raw["createdOn"])
+	assert.Equal(t, []string{"small"}, raw["tags"])
+	assert.Equal(t, map[string]any{"city": "San Francisco"}, raw["address"])
+	assert.Nil(t, raw["previous"])
+	assert.NotContains(t, raw, "score")
+	assert.NotContains(t, raw, "Skip")
+	assert.NotContains(t, raw, "internal")
+
+	// Same keys as the JSON round trip
+	js, _ := utils.JsonUtils().ToJson(source)
+	assert.Equal(t, len(js), len(raw))
+
+	raw["score"] = 7
+	raw["updatedOn"] = "2024-01-01T00:00:00Z"
+	raw["previous"] = map[string]any{"city": "Boston"}
+	result, err := utils.JsonUtils().FromMap(func() Entity { return &mapTestEntity{} }, raw)
+	require.NoError(t, err)
+	target := result.(*mapTestEntity)
+	assert.Equal(t, "Ant man", target.Name)
+	assert.Equal(t, 7.0, target.Score)
+	assert.Equal(t, Timestamp(1704067200000), target.UpdatedOn)
+	assert.Equal(t, "Boston", target.Previous.City)
+	assert.Equal(t, source.Address, target.Address)
+
+	raw["createdOn"] = "yesterday"
+	_, err = utils.JsonUtils().FromMap(func() Entity { return &mapTestEntity{} }, raw)
+	assert.Error(t, err)
+}
+
+type mapTestNumbers struct {
+	BaseEntity
+	Count int     `json:"count"`
+	Level uint    `json:"level"`
+	Small int8    `json:"small"`
+	Ratio float32 `json:"ratio"`
+}
+
+func TestJsonUtils_FromMapNumbers(t *testing.T) {
+	factory := func() Entity { return &mapTestNumbers{} }
+
+	result, err := utils.JsonUtils().FromMap(factory, map[string]any{"count": 2.0, "level": 3, "small": int64(-4), "ratio": 0.5})
+	require.NoError(t, err)
+	assert.Equal(t, &mapTestNumbers{Count: 2, Level: 3, Small: -4, Ratio: 0.5}, result)
+
+	// Lossy conversions are rejected
+	_, err = utils.JsonUtils().FromMap(factory, map[string]any{"count": 1.5})
+	assert.Error(t, err)
+	_, err = utils.JsonUtils().FromMap(factory, map[string]any{"level": -1})
+	assert.Error(t, err)
+	_, err = utils.JsonUtils().FromMap(factory, map[string]any{"small": 300})
+	assert.Error(t, err)
+}
+
+func BenchmarkJsonUtils_ToMap(b *testing.B) {
+	hero := list_of_heroes[0]
+	b.Run("reflection", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = utils.JsonUtils().ToMap(hero)
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = utils.JsonUtils().ToJson(hero)
+		}
+	})
+}
+
+func TestJsonUtils_FieldTypes(t *testing.T) {
+	fields := utils.JsonUtils().FieldTypes(&mapTestEntity{})
+	assert.Equal(t, reflect.TypeOf(""), fields["id"])
+	assert.Equal(t, reflect.TypeOf(Timestamp(0)), fields["createdOn"])
+	assert.Equal(t, reflect.TypeOf(&mapTestAddress{}), fields["previous"])
+	assert.NotContains(t, fields, "Skip")
+	assert.NotContains(t, fields, "internal")
+
+	// Same names as the struct map
+	raw, err := utils.JsonUtils().ToMap(&mapTestEntity{Score: 1})
+	require.NoError(t, err)
+	for name := range raw {
+		assert.Contains(t, fields, name)
+	}
+	assert.Equal(t, len(raw), len(fields))
+}
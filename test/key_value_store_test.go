@@ -0,0 +1,105 @@
+// Test in memory and file backed key-value store implementations
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyValueStore_InMemory(t *testing.T) {
+	store, err := database.NewInMemoryKeyValueStore()
+	require.NoError(t, err)
+	testKeyValueStore(t, store)
+}
+
+func TestKeyValueStore_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "settings.json")
+	store, err := database.NewFileKeyValueStore(path)
+	require.NoError(t, err)
+	testKeyValueStore(t, store)
+
+	// Values survive reopening the store
+	reopened, err := database.NewFileKeyValueStore(path)
+	require.NoError(t, err)
+	keys, err := reopened.Keys("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature/beta", "job/last-run"}, keys)
+	value, err := reopened.Get("job/last-run")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02", string(value))
+
+	// Failed writes are rolled back
+	require.NoError(t, os.Chmod(filepath.Dir(path), 0o500))
+	defer func() { _ = os.Chmod(filepath.Dir(path), 0o755) }()
+	if os.Geteuid() != 0 {
+		assert.Error(t, reopened.Set("job/last-run", []byte("2024-01-03")))
+		value, err = reopened.Get("job/last-run")
+		require.NoError(t, err)
+		assert.Equal(t, "2024-01-02", string(value))
+	}
+
+	// Corrupted file
+	corrupted := filepath.Join(t.TempDir(), "corrupted.json")
+	require.NoError(t, os.WriteFile(corrupted, []byte("{"), 0o644))
+	_, err = database.NewFileKeyValueStore(corrupted)
+	assert.Error(t, err)
+}
+
+func testKeyValueStore(t *testing.T, store database.IKeyValueStore) {
+	require.NoError(t, store.Ping(1, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := store.Watch(ctx, "job/")
+	require.NoError(t, err)
+
+	require.NoError(t, store.Set("job/last-run", []byte("2024-01-01")))
+	require.NoError(t, store.Set("feature/beta", []byte("true")))
+	require.NoError(t, store.Set("job/last-run", []byte("2024-01-02")))
+	require.NoError(t, store.Set("job/cursor", []byte("42")))
+	require.NoError(t, store.Delete("job/cursor", "missing"))
+	assert.Error(t, store.Set("", []byte("x")))
+
+	value, err := store.Get("job/last-run")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01-02", string(value))
+	_, err = store.Get("job/cursor")
+	assert.ErrorIs(t, err, database.ErrKeyNotFound)
+
+	keys, err := store.Keys("job/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job/last-run"}, keys)
+
+	// Watchers get the changes of the prefix in order
+	expected := []database.KeyValueEvent{
+		{Key: "job/last-run", Value: []byte("2024-01-01")},
+		{Key: "job/last-run", Value: []byte("2024-01-02")},
+		{Key: "job/cursor", Value: []byte("42")},
+		{Key: "job/cursor", Deleted: true},
+	}
+	for _, exp := range expected {
+		select {
+		case event := <-events:
+			assert.Equal(t, exp, event)
+		case <-time.After(time.Second):
+			require.Fail(t, "missing watch event", exp.Key)
+		}
+	}
+
+	// The watch channel is closed when the context is done
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "watch channel was not closed")
+	}
+	require.NoError(t, store.Close())
+}
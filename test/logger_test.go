@@ -3,10 +3,25 @@
 package test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/go-yaaf/yaaf-common/database"
 	"github.com/go-yaaf/yaaf-common/logger"
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/go-yaaf/yaaf-common/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -73,3 +88,138 @@ func SyslogTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 func CustomLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString("[" + level.CapitalString() + "]")
 }
+
+// captureLog redirects the logger output (stderr) during the function
+func captureLog(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = w
+	logger.Init()
+
+	fn()
+
+	os.Stderr = stderr
+	logger.Init()
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestLogger_StructuredFields(t *testing.T) {
+	logger.EnableJsonFormat(true)
+
+	// The child logger is resolved before the logger is initialized again
+	log := logger.With(logger.F("requestId", "r-1")).With(logger.F("count", 3))
+	log.Info("before capture")
+
+	out := captureLog(t, func() {
+		log.Info("hello %s", "world")
+
+		db := logger.Module("database")
+		db.Debug("hidden debug")
+		logger.SetModuleLevel("database", "DEBUG")
+		db.With(logger.Err(errors.New("boom"))).Debug("module debug")
+		logger.SetModuleLevel("database", "ERROR")
+		db.Warn("hidden warning")
+		logger.ResetModuleLevel("database")
+		db.Warn("module warning")
+	})
+
+	// Keep only the entries of this test (other tests may log in the background)
+	lines := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.Contains(line, "hello") || strings.Contains(line, "module") {
+			lines = append(lines, line)
+		}
+	}
+	require.Equal(t, 3, len(lines), out)
+
+	entry := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "hello world", entry["message"])
+	assert.Equal(t, "r-1", entry["requestId"])
+	assert.Equal(t, float64(3), entry["count"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "module debug", entry["message"])
+	assert.Equal(t, "database", entry["module"])
+	assert.Equal(t, "boom", entry["error"])
+	assert.Contains(t, lines[2], "module warning")
+}
+
+func TestLogger_Sinks(t *testing.T) {
+	logger.EnableJsonFormat(true)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.log.keep"), []byte("not a backup"), 0644))
+	file, err := logger.NewRollingFile(logger.RollingFileOptions{Path: filepath.Join(dir, "app.log"), MaxSize: 200, MaxBackups: 2})
+	require.NoError(t, err)
+
+	buffer := &bytes.Buffer{}
+	logger.SetSinks(logger.AsyncSink(logger.WriterSink(buffer), 64*1024, time.Minute), file)
+	logger.Init()
+
+	for i := 0; i < 20; i++ {
+		logger.Info("sink message %d", i)
+	}
+
+	// Rolling file is rotated when exceeding the max size, keeping the last backups
+	backups, err := file.Backups()
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(backups))
+	assert.NotContains(t, backups, filepath.Join(dir, "app.log.keep"))
+	assert.FileExists(t, filepath.Join(dir, "app.log.keep"))
+
+	content, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "sink message 19")
+	assert.True(t, len(content) <= 200)
+
+	// Async sink writes to the underlying sink on flush
+	assert.Equal(t, 0, buffer.Len())
+	require.NoError(t, logger.Flush())
+	assert.Equal(t, 20, strings.Count(buffer.String(), "sink message"))
+
+	require.NoError(t, logger.Close())
+	_, err = file.Write([]byte("closed"))
+	assert.Error(t, err)
+}
+
+func TestLogger_FromContext(t *testing.T) {
+	logger.EnableJsonFormat(true)
+	tracer := telemetry.NewInMemoryTracer()
+
+	var traceId string
+	handler := rest.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := database.WithTenant(r.Context(), "tenant-1")
+		span, _ := telemetry.SpanFromContext(ctx)
+		traceId = span.Context().TraceId
+		logger.FromContext(ctx).With(logger.F("hero", "1")).Info("context message")
+	}), rest.RequestId, telemetry.HttpServerTracing(tracer))
+
+	out := captureLog(t, func() {
+		req := httptest.NewRequest(http.MethodGet, "/heroes/1", nil)
+		req.Header.Set(rest.RequestIdHeader, "req-1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	var line string
+	for _, l := range strings.Split(out, "\n") {
+		if strings.Contains(l, "context message") {
+			line = l
+		}
+	}
+	entry := map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(line), &entry), out)
+	assert.Equal(t, "req-1", entry[logger.RequestIdKey])
+	assert.Equal(t, "tenant-1", entry[logger.TenantIdKey])
+	assert.Equal(t, traceId, entry[logger.TraceIdKey])
+	assert.NotEmpty(t, entry[logger.SpanIdKey])
+	assert.Equal(t, "1", entry["hero"])
+
+	// Context fields are replaced by key
+	ctx := logger.WithContext(database.WithTenant(context.Background(), "a"), logger.F(logger.TenantIdKey, "b"))
+	fields := logger.ContextFields(ctx)
+	require.Equal(t, 1, len(fields))
+	assert.Equal(t, "b", fields[0].String)
+}
@@ -0,0 +1,577 @@
+// Mail client tests
+
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common/utils/mail"
+)
+
+// fakeSmtpServer is a minimal SMTP server capturing the received messages
+type fakeSmtpServer struct {
+	listener net.Listener
+	tls      *tls.Config // STARTTLS is offered when set
+	silent   bool        // accept connections without greeting
+	mu       sync.Mutex
+	messages []string
+	secured  []bool
+}
+
+func newFakeSmtpServer(t *testing.T, startTls bool, silent bool) *fakeSmtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSmtpServer{listener: listener, silent: silent}
+	if startTls {
+		s.tls = selfSignedTlsConfig(t)
+	}
+	go s.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return s
+}
+
+func (s *fakeSmtpServer) uri() string {
+	return "smtp://" + s.listener.Addr().String()
+}
+
+func (s *fakeSmtpServer) received() ([]string, []bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.messages...), append([]bool{}, s.secured...)
+}
+
+func (s *fakeSmtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSmtpServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	if s.silent {
+		time.Sleep(time.Second)
+		return
+	}
+
+	secured := false
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			_, _ = w.WriteString(line + "\r\n")
+		}
+		_ = w.Flush()
+	}
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			if s.tls != nil && !secured {
+				reply("250-fake", "250 STARTTLS")
+			} else {
+				reply("250 fake")
+			}
+		case cmd == "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err = tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secured = tlsConn, true
+			r, w = bufio.NewReader(conn), bufio.NewWriter(conn)
+		case cmd == "DATA":
+			reply("354 go ahead")
+			data := strings.Builder{}
+			for {
+				dataLine, er := r.ReadString('\n')
+				if er != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.secured = append(s.secured, secured)
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func selfSignedTlsConfig(t *testing.T) *tls.Config {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+func sendTestMail(t *testing.T, config mail.MailConfig) error {
+	config.MailRelayUser = "user"
+	client, err := mail.NewMailClient(config)
+	require.NoError(t, err)
+	return client.CreateTextMessage().
+		From("Sender <sender@mail.com>").
+		To([]string{"to@mail.com"}).
+		Cc([]string{"Copy <cc@mail.com>"}).
+		Subject("Hello").
+		Body("Hello world").
+		Send()
+}
+
+func TestMail_SmtpPlain(t *testing.T) {
+	server := newFakeSmtpServer(t, false, false)
+
+	require.NoError(t, sendTestMail(t, mail.MailConfig{MailRelayUri: server.uri()}))
+
+	messages, secured := server.received()
+	require.Len(t, messages, 1)
+	assert.False(t, secured[0])
+	assert.Contains(t, messages[0], "Subject: Hello")
+	assert.Contains(t, messages[0], "Hello world")
+
+	// STARTTLS is required but not offered by the server
+	err := sendTestMail(t, mail.MailConfig{MailRelayUri: server.uri(), TLSMode: mail.TLSModeStartTLS})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not support STARTTLS")
+}
+
+func TestMail_SmtpStartTls(t *testing.T) {
+	server := newFakeSmtpServer(t, true, false)
+
+	// Self-signed certificate is rejected unless verification is skipped
+	err := sendTestMail(t, mail.MailConfig{MailRelayUri: server.uri(), UseTLS: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "STARTTLS failed")
+
+	require.NoError(t, sendTestMail(t, mail.MailConfig{MailRelayUri: server.uri(), UseTLS: true, InsecureSkipVerify: true}))
+
+	// TLS mode none does not upgrade the connection
+	require.NoError(t, sendTestMail(t, mail.MailConfig{MailRelayUri: server.uri(), TLSMode: mail.TLSModeNone}))
+
+	_, secured := server.received()
+	assert.Equal(t, []bool{true, false}, secured)
+}
+
+func TestMail_SmtpTimeout(t *testing.T) {
+	server := newFakeSmtpServer(t, false, true)
+
+	start := time.Now()
+	err := sendTestMail(t, mail.MailConfig{MailRelayUri: server.uri(), SendTimeout: 100 * time.Millisecond})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 900*time.Millisecond, fmt.Sprintf("send did not time out: %v", err))
+
+	_, err = mail.NewMailClient(mail.MailConfig{MailRelayUri: server.uri(), MailRelayUser: "user", CACertFile: "/not/exists.pem"})
+	assert.Error(t, err)
+}
+
+// fakeMailMessage fails the first failures sends
+type fakeMailMessage struct {
+	failures int32
+	sends    atomic.Int32
+}
+
+func (m *fakeMailMessage) From(string) mail.IMailMessage                              { return m }
+func (m *fakeMailMessage) To([]string) mail.IMailMessage                              { return m }
+func (m *fakeMailMessage) Cc([]string) mail.IMailMessage                              { return m }
+func (m *fakeMailMessage) Subject(string) mail.IMailMessage                           { return m }
+func (m *fakeMailMessage) Body(string) mail.IMailMessage                              { return m }
+func (m *fakeMailMessage) HtmlBody(string) mail.IMailMessage                          { return m }
+func (m *fakeMailMessage) Attachments([]mail.MailMessageAttachment) mail.IMailMessage { return m }
+func (m *fakeMailMessage) Inline([]mail.MailMessageAttachment) mail.IMailMessage      { return m }
+func (m *fakeMailMessage) Calendar(*mail.CalendarEvent) mail.IMailMessage             { return m }
+func (m *fakeMailMessage) Send() error {
+	if m.sends.Add(1) <= m.failures {
+		return errors.New("relay unavailable")
+	}
+	return nil
+}
+
+// statusRecorder collects the dispatcher delivery reports
+type statusRecorder struct {
+	mu      sync.Mutex
+	reports []mail.DeliveryReport
+}
+
+func (r *statusRecorder) onStatus(report mail.DeliveryReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, report)
+}
+
+func (r *statusRecorder) statuses(id string) []mail.DeliveryStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result []mail.DeliveryStatus
+	for _, report := range r.reports {
+		if report.Id == id {
+			result = append(result, report.Status)
+		}
+	}
+	return result
+}
+
+func TestMail_DispatcherRetry(t *testing.T) {
+	recorder := &statusRecorder{}
+	dispatcher := mail.NewMailDispatcher(mail.DispatcherOptions{
+		MaxRetries: 2,
+		Backoff:    10 * time.Millisecond,
+		OnStatus:   recorder.onStatus,
+	})
+
+	_, err := dispatcher.Send(&fakeMailMessage{})
+	assert.ErrorIs(t, err, mail.ErrDispatcherClosed)
+
+	require.NoError(t, dispatcher.Start(context.Background()))
+
+	recovered := &fakeMailMessage{failures: 2}
+	recoveredId, err := dispatcher.Send(recovered)
+	require.NoError(t, err)
+
+	failed := &fakeMailMessage{failures: 10}
+	failedId, err := dispatcher.Send(failed)
+	require.NoError(t, err)
+
+	require.NoError(t, dispatcher.Close())
+
+	assert.Equal(t, int32(3), recovered.sends.Load())
+	assert.Equal(t, []mail.DeliveryStatus{mail.DeliveryStatusRetrying, mail.DeliveryStatusRetrying, mail.DeliveryStatusSent}, recorder.statuses(recoveredId))
+	assert.Equal(t, int32(3), failed.sends.Load())
+	assert.Equal(t, []mail.DeliveryStatus{mail.DeliveryStatusRetrying, mail.DeliveryStatusRetrying, mail.DeliveryStatusFailed}, recorder.statuses(failedId))
+
+	_, err = dispatcher.Send(&fakeMailMessage{})
+	assert.ErrorIs(t, err, mail.ErrDispatcherClosed)
+}
+
+func TestMail_DispatcherRateLimit(t *testing.T) {
+	recorder := &statusRecorder{}
+	dispatcher := mail.NewMailDispatcher(mail.DispatcherOptions{
+		Workers:   4,
+		RateLimit: mail.RateLimit{Limit: 10, Period: 500 * time.Millisecond},
+		OnStatus:  recorder.onStatus,
+	})
+	require.NoError(t, dispatcher.Start(context.Background()))
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := dispatcher.Send(&fakeMailMessage{})
+		require.NoError(t, err)
+	}
+	require.NoError(t, dispatcher.Close())
+
+	// 5 messages at 50ms interval
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	assert.Len(t, recorder.reports, 5)
+}
+
+func TestMail_DispatcherStopTimeout(t *testing.T) {
+	recorder := &statusRecorder{}
+	dispatcher := mail.NewMailDispatcher(mail.DispatcherOptions{
+		QueueSize: 2,
+		RateLimit: mail.RateLimit{Limit: 1, Period: time.Hour},
+		OnStatus:  recorder.onStatus,
+	})
+	require.NoError(t, dispatcher.Start(context.Background()))
+
+	for i := 0; i < 2; i++ {
+		_, err := dispatcher.Send(&fakeMailMessage{})
+		require.NoError(t, err)
+	}
+	// Wait for the first message to be delivered, the second one waits for the rate limiter
+	require.Eventually(t, func() bool { return dispatcher.Pending() == 0 }, time.Second, 5*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		_, err := dispatcher.Send(&fakeMailMessage{})
+		require.NoError(t, err)
+	}
+	_, err := dispatcher.Send(&fakeMailMessage{})
+	assert.ErrorIs(t, err, mail.ErrDispatcherQueueFull)
+
+	assert.Error(t, dispatcher.Stop(50*time.Millisecond))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	statuses := map[mail.DeliveryStatus]int{}
+	for _, report := range recorder.reports {
+		statuses[report.Status] += 1
+	}
+	assert.Equal(t, map[mail.DeliveryStatus]int{mail.DeliveryStatusSent: 1, mail.DeliveryStatusDropped: 3}, statuses)
+}
+
+func TestMail_DispatcherStopDuringRetry(t *testing.T) {
+	recorder := &statusRecorder{}
+	dispatcher := mail.NewMailDispatcher(mail.DispatcherOptions{
+		MaxRetries: 5,
+		Backoff:    20 * time.Millisecond,
+		OnStatus:   recorder.onStatus,
+	})
+	require.NoError(t, dispatcher.Start(context.Background()))
+
+	id, err := dispatcher.Send(&fakeMailMessage{failures: 10})
+	require.NoError(t, err)
+
+	// Messages waiting for retry are pending
+	require.Eventually(t, func() bool { return len(recorder.statuses(id)) > 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, dispatcher.Pending())
+
+	// Stop while retry timers fire, the message is reported as dropped exactly once
+	time.Sleep(30 * time.Millisecond)
+	assert.Error(t, dispatcher.Stop(time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+
+	statuses := recorder.statuses(id)
+	require.NotEmpty(t, statuses)
+	assert.Equal(t, mail.DeliveryStatusDropped, statuses[len(statuses)-1])
+	dropped := 0
+	for _, status := range statuses {
+		if status == mail.DeliveryStatusDropped {
+			dropped += 1
+		}
+	}
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 0, dispatcher.Pending())
+}
+
+// capturedRequest is a mail provider API request received by the fake API server
+type capturedRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+	form   *multipart.Form
+}
+
+func newFakeMailApi(t *testing.T, status int) (*httptest.Server, *[]capturedRequest) {
+	requests := make([]capturedRequest, 0)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			_ = r.ParseMultipartForm(1 << 20)
+		}
+		requests = append(requests, capturedRequest{path: r.URL.Path, header: r.Header, body: body, form: r.MultipartForm})
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"message":"done"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestMail_SendGrid(t *testing.T) {
+	server, requests := newFakeMailApi(t, http.StatusAccepted)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	_, err := mail.NewMailClient(mail.MailConfig{MailRelayUri: "sendgrid://" + host, MailRelayUser: "apikey"})
+	assert.Error(t, err)
+
+	client, err := mail.NewMailClient(mail.MailConfig{MailRelayUri: "sendgrid://" + host, MailRelayUser: "apikey", MailRelayPassword: "SG.key", InsecureSkipVerify: true})
+	require.NoError(t, err)
+
+	attachment := mail.MailMessageAttachment{FileName: "/tmp/report.txt", Base64Content: base64.StdEncoding.EncodeToString([]byte("report"))}
+	err = client.CreateHtmlMessage().From("Sender <sender@mail.com>").To([]string{"to@mail.com"}).Subject("Hello").
+		HtmlBody("<b>Hello</b>").Attachments([]mail.MailMessageAttachment{attachment}).Send()
+	require.NoError(t, err)
+
+	err = client.CreateTemplateMessage("d-123", map[string]string{"name": "John"}).From("sender@mail.com").To([]string{"to@mail.com"}).Send()
+	require.NoError(t, err)
+
+	require.Len(t, *requests, 2)
+	html := (*requests)[0]
+	assert.Equal(t, "/v3/mail/send", html.path)
+	assert.Equal(t, "Bearer SG.key", html.header.Get("Authorization"))
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(html.body, &payload))
+	assert.Equal(t, map[string]any{"email": "sender@mail.com", "name": "Sender"}, payload["from"])
+	assert.Equal(t, []any{map[string]any{"type": "text/html", "value": "<b>Hello</b>"}}, payload["content"])
+	assert.Equal(t, []any{map[string]any{"content": attachment.Base64Content, "type": "text/plain; charset=utf-8", "filename": "report.txt"}}, payload["attachments"])
+
+	payload = map[string]any{}
+	require.NoError(t, json.Unmarshal((*requests)[1].body, &payload))
+	assert.Equal(t, "d-123", payload["template_id"])
+	assert.Equal(t, map[string]any{"name": "John"}, payload["personalizations"].([]any)[0].(map[string]any)["dynamic_template_data"])
+}
+
+func TestMail_Mailgun(t *testing.T) {
+	server, requests := newFakeMailApi(t, http.StatusOK)
+	uri := "mailgun://" + strings.TrimPrefix(server.URL, "https://")
+
+	_, err := mail.NewMailClient(mail.MailConfig{MailRelayUri: uri, MailRelayUser: "api", MailRelayPassword: "key"})
+	assert.Error(t, err, "missing domain")
+
+	client, err := mail.NewMailClient(mail.MailConfig{MailRelayUri: uri + "/mg.example.com", MailRelayUser: "api", MailRelayPassword: "key", InsecureSkipVerify: true})
+	require.NoError(t, err)
+
+	attachment := mail.MailMessageAttachment{FileName: "data.json", Base64Content: base64.StdEncoding.EncodeToString([]byte(`{}`))}
+	err = client.CreateTextMessage().From("sender@mail.com").To([]string{"a@mail.com", "b@mail.com"}).Subject("Hello").
+		Body("Hello world").Attachments([]mail.MailMessageAttachment{attachment}).Send()
+	require.NoError(t, err)
+
+	require.Len(t, *requests, 1)
+	req := (*requests)[0]
+	assert.Equal(t, "/v3/mg.example.com/messages", req.path)
+	assert.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("api:key")), req.header.Get("Authorization"))
+
+	require.NotNil(t, req.form)
+	assert.Equal(t, []string{"a@mail.com", "b@mail.com"}, req.form.Value["to"])
+	assert.Equal(t, []string{"Hello world"}, req.form.Value["text"])
+	require.Len(t, req.form.File["attachment"], 1)
+	assert.Equal(t, "data.json", req.form.File["attachment"][0].Filename)
+
+	// Provider errors are returned with the response status
+	failing, _ := newFakeMailApi(t, http.StatusUnauthorized)
+	client, err = mail.NewMailClient(mail.MailConfig{MailRelayUri: "mailgun://" + strings.TrimPrefix(failing.URL, "https://") + "/mg.example.com", MailRelayUser: "api", MailRelayPassword: "key", InsecureSkipVerify: true})
+	require.NoError(t, err)
+	err = client.CreateTemplateMessage("welcome", map[string]string{"name": "John"}).From("sender@mail.com").To([]string{"a@mail.com"}).Send()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 401")
+}
+
+func TestMail_Ses(t *testing.T) {
+	server, requests := newFakeMailApi(t, http.StatusOK)
+	uri := "ses://" + strings.TrimPrefix(server.URL, "https://")
+
+	_, err := mail.NewMailClient(mail.MailConfig{MailRelayUri: uri, MailRelayUser: "AKID", MailRelayPassword: "secret"})
+	assert.Error(t, err, "missing region")
+
+	client, err := mail.NewMailClient(mail.MailConfig{MailRelayUri: "ses://eu-west-1", MailRelayUser: "AKID", MailRelayPassword: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "AKID", client.MailUsr())
+
+	client, err = mail.NewMailClient(mail.MailConfig{MailRelayUri: uri + "?region=eu-west-1", MailRelayUser: "AKID", MailRelayPassword: "secret", InsecureSkipVerify: true})
+	require.NoError(t, err)
+
+	require.NoError(t, client.CreateTextMessage().From("sender@mail.com").To([]string{"to@mail.com"}).Subject("Hello").Body("Hello world").Send())
+
+	attachment := mail.MailMessageAttachment{FileName: "report.txt", Base64Content: base64.StdEncoding.EncodeToString([]byte("report"))}
+	require.NoError(t, client.CreateTextMessage().From("sender@mail.com").To([]string{"to@mail.com"}).Subject("Report").
+		Attachments([]mail.MailMessageAttachment{attachment}).Send())
+
+	err = client.CreateTemplateMessage("welcome", nil).From("sender@mail.com").To([]string{"to@mail.com"}).
+		Attachments([]mail.MailMessageAttachment{attachment}).Send()
+	assert.Error(t, err)
+
+	require.Len(t, *requests, 2)
+	simple := (*requests)[0]
+	assert.Equal(t, "/v2/email/outbound-emails", simple.path)
+	assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=AKID/\d{8}/eu-west-1/ses/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=[0-9a-f]{64}$`, simple.header.Get("Authorization"))
+	assert.NotEmpty(t, simple.header.Get("X-Amz-Date"))
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(simple.body, &payload))
+	assert.Equal(t, "Hello world", payload["Content"].(map[string]any)["Simple"].(map[string]any)["Body"].(map[string]any)["Text"].(map[string]any)["Data"])
+
+	payload = map[string]any{}
+	require.NoError(t, json.Unmarshal((*requests)[1].body, &payload))
+	raw, err := base64.StdEncoding.DecodeString(payload["Content"].(map[string]any)["Raw"].(map[string]any)["Data"].(string))
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "Subject: Report")
+	assert.Contains(t, string(raw), `filename="report.txt"`)
+}
+
+func TestMail_InlineAndCalendar(t *testing.T) {
+	server := newFakeSmtpServer(t, false, false)
+	client, err := mail.NewMailClient(mail.MailConfig{MailRelayUri: server.uri(), MailRelayUser: "user"})
+	require.NoError(t, err)
+
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	event := &mail.CalendarEvent{
+		Uid:         "event-1@mail.com",
+		Summary:     "Design review; part 1",
+		Description: strings.Repeat("Long description ", 10),
+		Start:       start,
+		End:         start.Add(time.Hour),
+		Organizer:   "Organizer <org@mail.com>",
+		Attendees:   []string{"to@mail.com"},
+	}
+	logo := mail.MailMessageAttachment{FileName: "logo.png", ContentId: "logo", Base64Content: base64.StdEncoding.EncodeToString([]byte("png"))}
+
+	err = client.CreateHtmlMessage().From("org@mail.com").To([]string{"to@mail.com"}).Subject("Invite").
+		HtmlBody(`<img src="cid:logo">`).Inline([]mail.MailMessageAttachment{logo}).Calendar(event).Send()
+	require.NoError(t, err)
+
+	messages, _ := server.received()
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "Content-ID: <logo>")
+	assert.Contains(t, messages[0], "Content-Type: text/calendar; method=REQUEST")
+	assert.Contains(t, messages[0], `filename="invite.ics"`)
+
+	ics := event.ICS()
+	assert.Contains(t, ics, "METHOD:REQUEST\r\n")
+	assert.Contains(t, ics, "DTSTART:20260301T100000Z\r\n")
+	assert.Contains(t, ics, "SUMMARY:Design review\\; part 1\r\n")
+	assert.Contains(t, ics, `ORGANIZER;CN="Organizer":mailto:org@mail.com`)
+	for _, line := range strings.Split(ics, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+
+	event.Method = mail.CalendarMethodCancel
+	assert.Contains(t, event.ICS(), "STATUS:CANCELLED")
+
+	// SendGrid sends the inline images as inline attachments
+	api, requests := newFakeMailApi(t, http.StatusAccepted)
+	client, err = mail.NewMailClient(mail.MailConfig{MailRelayUri: "sendgrid://" + strings.TrimPrefix(api.URL, "https://"), MailRelayUser: "apikey", MailRelayPassword: "key", InsecureSkipVerify: true})
+	require.NoError(t, err)
+	err = client.CreateHtmlMessage().From("org@mail.com").To([]string{"to@mail.com"}).Subject("Invite").
+		HtmlBody(`<img src="cid:logo">`).Inline([]mail.MailMessageAttachment{logo}).Calendar(event).Send()
+	require.NoError(t, err)
+
+	var payload struct {
+		Attachments []map[string]string `json:"attachments"`
+	}
+	require.NoError(t, json.Unmarshal((*requests)[0].body, &payload))
+	require.Len(t, payload.Attachments, 2)
+	assert.Equal(t, "invite.ics", payload.Attachments[0]["filename"])
+	assert.Equal(t, "text/calendar; method=CANCEL", payload.Attachments[0]["type"])
+	assert.Equal(t, "inline", payload.Attachments[1]["disposition"])
+	assert.Equal(t, "logo", payload.Attachments[1]["content_id"])
+}
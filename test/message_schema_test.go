@@ -0,0 +1,79 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	. "github.com/go-yaaf/yaaf-common/messaging"
+	"github.com/go-yaaf/yaaf-common/messaging/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type heroV1 struct {
+	HeroName string `json:"hero_name"`
+}
+
+func TestMessageSchema_Upgrade(t *testing.T) {
+	schema.Register("hero-versioned", "3").
+		AddMigration("1", "2", func(payload entity.Json) (entity.Json, error) {
+			payload["name"] = payload["hero_name"]
+			delete(payload, "hero_name")
+			return payload, nil
+		}).
+		AddMigration("2", "3", func(payload entity.Json) (entity.Json, error) {
+			payload["key"] = 1
+			return payload, nil
+		})
+
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	received := make(chan IMessage, 2)
+	_, err = schema.Subscribe(bus, "subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+		received <- msg
+		return true
+	}, "hero-versioned")
+	require.NoError(t, err)
+
+	// Message published by an old producer (version 1)
+	old := GetMessage[*heroV1]("hero-versioned", &heroV1{HeroName: "Batman"}).(*Message[*heroV1])
+	old.MsgVersion = "1"
+	require.NoError(t, bus.Publish(old))
+
+	// Message published by an up-to-date producer
+	current := schema.NewVersionedMessage[*Hero]("hero-versioned", &Hero{Key: 2, Name: "Robin"})
+	assert.Equal(t, "3", current.Version())
+	require.NoError(t, bus.Publish(current))
+
+	for _, expected := range []*Hero{{Key: 1, Name: "Batman"}, {Key: 2, Name: "Robin"}} {
+		select {
+		case msg := <-received:
+			hero, ok := msg.(*Message[*Hero])
+			require.True(t, ok)
+			assert.Equal(t, "3", hero.Version())
+			assert.Equal(t, expected.Name, hero.MsgPayload.Name)
+			assert.Equal(t, expected.Key, hero.MsgPayload.Key)
+		case <-time.After(time.Second):
+			require.Fail(t, "message was not delivered")
+		}
+	}
+}
+
+func TestMessageSchema_MissingMigration(t *testing.T) {
+	s := schema.Register("hero-unversioned", "2")
+	_, err := s.Upgrade("1", entity.Json{})
+	assert.Error(t, err)
+
+	s.AddMigration("1", "2", func(payload entity.Json) (entity.Json, error) {
+		return nil, fmt.Errorf("invalid payload")
+	})
+	_, err = s.Upgrade("1", entity.Json{})
+	assert.Error(t, err)
+
+	payload, err := s.Upgrade("2", entity.Json{"name": "Robin"})
+	require.NoError(t, err)
+	assert.Equal(t, "Robin", payload["name"])
+}
@@ -0,0 +1,166 @@
+// Test in memory and local file system object store implementations
+package test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var objectStoreSecret = []byte("object-store-signing-secret")
+
+func TestObjectStore_InMemory(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+
+	store, err := database.NewInMemoryObjectStore(server.URL+"/objects", objectStoreSecret)
+	require.NoError(t, err)
+	testObjectStore(t, store, server)
+}
+
+func TestObjectStore_Local(t *testing.T) {
+	server := httptest.NewServer(nil)
+	defer server.Close()
+
+	root := t.TempDir()
+	store, err := database.NewLocalObjectStore(root, server.URL+"/objects", objectStoreSecret)
+	require.NoError(t, err)
+	testObjectStore(t, store, server)
+
+	// Objects are kept on the file system
+	reopened, err := database.NewLocalObjectStore(root, "", nil)
+	require.NoError(t, err)
+	info, err := reopened.Stat(context.Background(), "reports/2024/02.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "text/csv", info.ContentType)
+	assert.Equal(t, "finance", info.Metadata["owner"])
+
+	_, err = reopened.Put(context.Background(), ".metadata/x", strings.NewReader("x"))
+	assert.Error(t, err)
+}
+
+func testObjectStore(t *testing.T, store database.IObjectStore, server *httptest.Server) {
+	ctx := context.Background()
+	require.NoError(t, store.Ping(1, 1))
+
+	info, err := store.Put(ctx, "reports/2024/01.csv", strings.NewReader("a,b\n1,2\n"),
+		database.WithContentType("text/csv"), database.WithMetadata(map[string]string{"owner": "finance"}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), info.Size)
+	assert.Equal(t, "e5ebd4c02cefbe7955977c67ada242b7", info.ETag)
+
+	_, err = store.Put(ctx, "reports/2024/02.csv", strings.NewReader("c,d\n"), database.WithContentType("text/csv"),
+		database.WithMetadata(map[string]string{"owner": "finance"}))
+	require.NoError(t, err)
+	_, err = store.Put(ctx, "images/logo.png", strings.NewReader("png"))
+	require.NoError(t, err)
+
+	// Invalid keys
+	_, err = store.Put(ctx, "../escape", strings.NewReader("x"))
+	assert.Error(t, err)
+	_, err = store.Put(ctx, "", strings.NewReader("x"))
+	assert.Error(t, err)
+
+	// Get and stat
+	reader, info, err := store.Get(ctx, "reports/2024/01.csv")
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "a,b\n1,2\n", string(content))
+	assert.Equal(t, "text/csv", info.ContentType)
+	assert.Equal(t, "finance", info.Metadata["owner"])
+
+	info, err = store.Stat(ctx, "images/logo.png")
+	require.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", info.ContentType)
+
+	_, _, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, database.ErrObjectNotFound)
+	_, err = store.Stat(ctx, "reports")
+	assert.ErrorIs(t, err, database.ErrObjectNotFound)
+
+	// List with prefix and pagination
+	list, next, err := store.List(ctx, "", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"images/logo.png", "reports/2024/01.csv", "reports/2024/02.csv"}, objectKeys(list))
+	assert.Empty(t, next)
+
+	list, next, err = store.List(ctx, "reports/", "", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reports/2024/01.csv"}, objectKeys(list))
+	assert.Equal(t, "reports/2024/01.csv", next)
+	list, next, err = store.List(ctx, "reports/", next, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reports/2024/02.csv"}, objectKeys(list))
+	assert.Empty(t, next)
+
+	// Signed URLs
+	mux := http.NewServeMux()
+	mux.Handle("/objects/", http.StripPrefix("/objects", database.NewObjectStoreHandler(store, objectStoreSecret)))
+	server.Config.Handler = mux
+
+	putUrl, err := store.SignedURL(ctx, "uploads/my file.txt", http.MethodPut, time.Minute)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, putUrl, strings.NewReader("uploaded"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	getUrl, err := store.SignedURL(ctx, "uploads/my file.txt", http.MethodGet, time.Minute)
+	require.NoError(t, err)
+	resp, err = http.Get(getUrl)
+	require.NoError(t, err)
+	content, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "uploaded", string(content))
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+
+	// The signature is bound to the method, key and expiration
+	resp, err = http.Get(strings.Replace(getUrl, "my%20file", "other", 1))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	expiredUrl, err := store.SignedURL(ctx, "uploads/my file.txt", http.MethodGet, -time.Minute)
+	require.NoError(t, err)
+	resp, err = http.Get(expiredUrl)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = http.Get(putUrl)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	_, err = store.SignedURL(ctx, "images/logo.png", http.MethodDelete, time.Minute)
+	assert.Error(t, err)
+
+	// Delete
+	require.NoError(t, store.Delete(ctx, "reports/2024/01.csv", "missing"))
+	list, _, err = store.List(ctx, "reports/", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"reports/2024/02.csv"}, objectKeys(list))
+	require.NoError(t, store.Close())
+}
+
+func objectKeys(list []database.ObjectInfo) []string {
+	keys := make([]string, 0, len(list))
+	for _, info := range list {
+		keys = append(keys, info.Key)
+	}
+	return keys
+}
@@ -0,0 +1,83 @@
+// Test transactional outbox
+package test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/go-yaaf/yaaf-common/database"
+	. "github.com/go-yaaf/yaaf-common/messaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionalOutbox(t *testing.T) {
+	db, err := NewInMemoryDatabase()
+	require.NoError(t, err)
+	bus, err := NewInMemoryMessageBus()
+	require.NoError(t, err)
+
+	received := make(chan IMessage, 10)
+	_, err = bus.Subscribe("subscriber", NewMessage[*Hero], func(msg IMessage) bool {
+		received <- msg
+		return true
+	}, "hero-created")
+	require.NoError(t, err)
+
+	customReceived := make(chan IMessage, 10)
+	_, err = bus.Subscribe("subscriber", NewHeroMessage, func(msg IMessage) bool {
+		customReceived <- msg
+		return true
+	}, "hero-custom")
+	require.NoError(t, err)
+
+	outbox := NewTransactionalOutbox(db, bus)
+
+	// Mutation and message are stored together
+	hero := NewHero1("100", 100, "Iron Man").(*Hero)
+	err = outbox.Execute(func(tx IDatabase) error {
+		_, fe := tx.Insert(hero)
+		return fe
+	}, GetMessage[*Hero]("hero-created", hero))
+	require.NoError(t, err)
+
+	// Custom message types are relayed with their own fields and the stored message is not modified
+	custom := &HeroMessage{BaseMessage: BaseMessage{MsgTopic: "hero-custom", MsgOpCode: 7}, Hero: hero}
+	require.NoError(t, outbox.Execute(nil, custom))
+	assert.Empty(t, custom.Headers()[DedupKeyHeader])
+
+	// Failed mutation does not store the message
+	err = outbox.Execute(func(tx IDatabase) error {
+		return fmt.Errorf("mutation failed")
+	}, GetMessage[*Hero]("hero-created", hero))
+	require.Error(t, err)
+
+	published, err := outbox.Relay()
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+
+	published, err = outbox.Relay()
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "Iron Man", msg.(*Message[*Hero]).MsgPayload.Name)
+		assert.NotEmpty(t, msg.Headers()[DedupKeyHeader])
+	case <-time.After(time.Second):
+		require.Fail(t, "message was not relayed")
+	}
+	select {
+	case msg := <-customReceived:
+		assert.Equal(t, 7, msg.OpCode())
+		assert.Equal(t, "Iron Man", msg.(*HeroMessage).Hero.Name)
+		assert.NotEmpty(t, msg.Headers()[DedupKeyHeader])
+	case <-time.After(time.Second):
+		require.Fail(t, "custom message was not relayed")
+	}
+
+	list, _, err := db.Query(NewOutboxMessage).Filter(F("status").Eq(OutboxStatusPublished)).Find()
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+}
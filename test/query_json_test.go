@@ -0,0 +1,76 @@
+// Query JSON serialization tests
+
+package test
+
+import (
+	"testing"
+
+	. "github.com/go-yaaf/yaaf-common/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryJson(t *testing.T) {
+	db, err := getInitializedDb()
+	require.NoError(t, err)
+
+	data, err := db.Query(NewHero).
+		MatchAny(F("name").Like("Bat*"), F("name").Like("Black*")).
+		Filter(F("key").Lte(20)).
+		Sort("key-").
+		Page(0).
+		Limit(3).
+		ToJson()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"all": [[{"field":"key","op":"<=","values":[20]}]],
+		"any": [[{"field":"name","op":"~","values":["Bat*"]},{"field":"name","op":"~","values":["Black*"]}]],
+		"sort": ["key-"],
+		"page": 0,
+		"limit": 3
+	}`, string(data))
+
+	// Reconstruct the query on another database
+	spec, err := FromJson(NewHero, data)
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(20)}, spec.All[0][0].Values)
+
+	_, total, err := spec.Apply(db.Query(NewHero)).Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+
+	again, err := spec.Apply(db.Query(NewHero)).ToJson()
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+
+	// Datastore query with range
+	ds, err := getInitializedDs()
+	require.NoError(t, err)
+	data, err = ds.Query(NewHero).Filter(F("name").In("Thor", "Robin", "Elektra")).Range("key", 10, 25).ToJson()
+	require.NoError(t, err)
+	spec, err = FromJson(NewHero, data)
+	require.NoError(t, err)
+	require.NotNil(t, spec.Range)
+	_, total, err = spec.Apply(ds.Query(NewHero)).Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// Sub-queries can't be serialized
+	_, err = db.Query(NewHero).Filter(F("id").InSubQuery("id", db.Query(NewHero))).ToJson()
+	assert.Error(t, err)
+
+	for _, bad := range []string{
+		`{"all":[[{"field":"age","op":"=","values":[1]}]]}`,
+		`{"all":[[{"field":"key","op":"?","values":[1]}]]}`,
+		`{"all":[[{"field":"key","op":"#","values":[1]}]]}`,
+		`{"all":[[{"field":"key","op":"=","values":["abc"]}]]}`,
+		`{"sort":["secret-"]}`,
+		`{"range":{"field":"name","from":1,"to":2}}`,
+		`{"limit":-1}`,
+		`{"unknown":true}`,
+		`not json`,
+	} {
+		_, err = FromJson(NewHero, []byte(bad))
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,88 @@
+// Query DSL parser tests
+
+package test
+
+import (
+	"testing"
+
+	. "github.com/go-yaaf/yaaf-common/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	expr, err := ParseFilter("status=active AND (age>30 OR role IN [admin, 'ops team']) and name ~ 'Bat*'")
+	require.NoError(t, err)
+	assert.Equal(t, AndOperator, expr.Operator)
+	require.Len(t, expr.Children, 3)
+	assert.Equal(t, OrOperator, expr.Children[1].Operator)
+
+	filters := expr.Filters()
+	require.Len(t, filters, 4)
+	assert.Equal(t, "status", filters[0].GetField())
+	assert.Equal(t, QueryOperator(Eq), filters[0].GetOperator())
+	assert.Equal(t, []any{int64(30)}, filters[1].GetValues())
+	assert.Equal(t, []any{"admin", "ops team"}, filters[2].GetValues())
+	assert.Equal(t, QueryOperator(Like), filters[3].GetOperator())
+
+	// The string representation is parsed back to the same expression
+	text := expr.String()
+	assert.Equal(t, `status=active AND (age>30 OR role IN [admin,"ops team"]) AND name~Bat*`, text)
+	again, err := ParseFilter(text)
+	require.NoError(t, err)
+	assert.Equal(t, text, again.String())
+
+	// Other conditions
+	expr, err = ParseFilter("key NOT IN [1,2] OR key BETWEEN [5, 7] OR tags CONTAINS x OR name IS EMPTY OR flag != true")
+	require.NoError(t, err)
+	operators := make([]QueryOperator, 0)
+	for _, f := range expr.Filters() {
+		operators = append(operators, f.GetOperator())
+	}
+	assert.Equal(t, []QueryOperator{NotIn, Between, Contains, Empty, Neq}, operators)
+
+	for _, bad := range []string{"", "name", "name =", "(key>1", "key>1)", "key>1 AND", "key IN 1", "key BETWEEN [1]", "a ! b", "name='x", "AND=1", "key IS NULL", "NOT", "key>1 AND NOT"} {
+		_, err = ParseFilter(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseFilter_Apply(t *testing.T) {
+	db, err := getInitializedDb()
+	require.NoError(t, err)
+
+	expr, err := ParseFilter("key<=10 AND (name ~ Bat* OR name ~ Black* OR key = 1)")
+	require.NoError(t, err)
+	require.NoError(t, expr.Validate(NewHero))
+
+	list, total, err := expr.Apply(db.Query(NewHero)).Find()
+	require.NoError(t, err)
+	require.Equal(t, int64(6), total)
+	names := make([]string, 0, len(list))
+	for _, hero := range list {
+		names = append(names, hero.(*Hero).Name)
+	}
+	assert.ElementsMatch(t, []string{"Ant man", "Bat Girl", "Bat Man", "Bat Woman", "Black Canary", "Black Panther"}, names)
+
+	// (key>20 AND name~*man) OR key=1
+	expr, err = ParseFilter("key>20 AND name ~ *man OR key=1")
+	require.NoError(t, err)
+	_, total, err = expr.Apply(db.Query(NewHero)).Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+
+	// Negation
+	expr, err = ParseFilter("key<=10 AND NOT (name ~ Bat* OR NOT key>5)")
+	require.NoError(t, err)
+	assert.Equal(t, "key<=10 AND NOT (name~Bat* OR NOT key>5)", expr.String())
+	_, total, err = expr.Apply(db.Query(NewHero)).Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), total)
+
+	// Validation against the entity fields
+	for _, bad := range []string{"age>30", "name>3", "key=abc"} {
+		expr, err = ParseFilter(bad)
+		require.NoError(t, err)
+		assert.Error(t, expr.Validate(NewHero), bad)
+	}
+}
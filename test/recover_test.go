@@ -0,0 +1,95 @@
+// Test panic recovery utilities
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover_Stack(t *testing.T) {
+	before := utils.PanicCount()
+
+	var recovered any
+	var stack []byte
+	func() {
+		defer utils.RecoverAllWithStack(func(v any, s []byte) {
+			recovered, stack = v, s
+		})
+		panicWithStack()
+	}()
+	assert.Equal(t, "boom", recovered)
+	assert.Contains(t, string(stack), "panicWithStack")
+
+	// No panic, no stack
+	func() {
+		defer utils.RecoverAllWithStack(func(v any, s []byte) {
+			recovered, stack = v, s
+		})
+	}()
+	assert.Nil(t, recovered)
+	assert.Nil(t, stack)
+
+	errBlank := errors.New("blank")
+	func() {
+		defer utils.RecoverOne(errBlank, func(v any) {
+			recovered = v
+		})
+		panic(errBlank)
+	}()
+	assert.Equal(t, errBlank, recovered)
+
+	assert.Equal(t, before+2, utils.PanicCount())
+}
+
+func TestRecover_GoSafe(t *testing.T) {
+	before := utils.PanicCount()
+
+	done := make(chan []byte, 1)
+	utils.GoSafe(func() {
+		panicWithStack()
+	}, func(v any, stack []byte) {
+		done <- stack
+	})
+
+	select {
+	case stack := <-done:
+		assert.Contains(t, string(stack), "panicWithStack")
+	case <-time.After(time.Second):
+		require.Fail(t, "panic callback was not called")
+	}
+	assert.Equal(t, before+1, utils.PanicCount())
+
+	// Goroutine without panic
+	finished := make(chan struct{})
+	utils.GoSafe(func() { close(finished) })
+	<-finished
+	assert.Equal(t, before+1, utils.PanicCount())
+}
+
+func TestRecover_RunSafe(t *testing.T) {
+	before := utils.PanicCount()
+
+	err := utils.RunSafe(func() error {
+		panicWithStack()
+		return nil
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "panic: boom")
+	assert.Contains(t, err.Error(), "panicWithStack")
+	assert.Equal(t, before+1, utils.PanicCount())
+
+	// Errors are returned as is
+	errFailed := errors.New("failed")
+	assert.Equal(t, errFailed, utils.RunSafe(func() error { return errFailed }))
+	assert.NoError(t, utils.RunSafe(func() error { return nil }))
+	assert.Equal(t, before+1, utils.PanicCount())
+}
+
+func panicWithStack() {
+	panic("boom")
+}
@@ -0,0 +1,97 @@
+// Test HTTP request signing and verification middleware
+package test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestSignature(t *testing.T) {
+	secrets := map[string][]byte{"billing": []byte("billing-shared-secret")}
+	resolver := func(keyId string) ([]byte, error) {
+		if secret, ok := secrets[keyId]; ok {
+			return secret, nil
+		}
+		return nil, fmt.Errorf("unknown key id: %s", keyId)
+	}
+
+	handler := rest.VerifySignature(resolver, rest.SignatureOptions{MaxSkew: time.Minute, MaxBodySize: 64})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// Signed request
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/invoices?account=1", strings.NewReader(`{"amount":10}`))
+	require.NoError(t, err)
+	require.NoError(t, utils.SignatureUtils().SignRequest(req, "billing", secrets["billing"]))
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `{"amount":10}`, string(body))
+
+	// Tampered body
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/invoices?account=1", strings.NewReader(`{"amount":10}`))
+	require.NoError(t, utils.SignatureUtils().SignRequest(req, "billing", secrets["billing"]))
+	req.Body = io.NopCloser(strings.NewReader(`{"amount":99}`))
+	req.ContentLength = 13
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// Unknown key and unsigned request
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/v1/invoices", nil)
+	require.NoError(t, utils.SignatureUtils().SignRequest(req, "shipping", secrets["billing"]))
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res, err = http.Get(server.URL + "/v1/invoices")
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// Body exceeding the max size
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/v1/invoices", strings.NewReader(strings.Repeat("x", 65)))
+	require.NoError(t, utils.SignatureUtils().SignRequest(req, "billing", secrets["billing"]))
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+}
+
+func TestRequestSignature_DefaultSkew(t *testing.T) {
+	secret := []byte("billing-shared-secret")
+	resolver := func(keyId string) ([]byte, error) { return secret, nil }
+
+	// Zero max skew uses the default skew instead of disabling the check
+	handler := rest.VerifySignature(resolver, rest.SignatureOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/invoices", nil)
+	timestamp := fmt.Sprintf("%d", time.Now().Add(-rest.DefaultSignatureMaxSkew-time.Minute).UnixMilli())
+	bodyHash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	req.Header.Set(utils.SignatureKeyIdHeader, "billing")
+	req.Header.Set(utils.SignatureTimestampHeader, timestamp)
+	req.Header.Set(utils.SignatureHeader, utils.SignatureUtils().Sign(secret, http.MethodGet, "/v1/invoices", timestamp, bodyHash))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "out of range")
+}
@@ -0,0 +1,117 @@
+package test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestAuthMiddleware(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+
+	token, err := utils.TokenUtils().CreateToken(&jwt.RegisteredClaims{Subject: "user-1", Audience: jwt.ClaimStrings{"users:read"}})
+	require.NoError(t, err)
+	apiKey, err := utils.TokenUtils().CreateApiKey("billing-service")
+	require.NoError(t, err)
+
+	auth := rest.Authenticate(rest.AuthOptions{
+		RolesResolver: func(r *http.Request, p *rest.Principal) error {
+			if p.Subject == "billing-service" {
+				p.Roles = []string{"admin"}
+			}
+			return nil
+		},
+	})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := rest.PrincipalFromContext(r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(p.Subject))
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /users", auth(rest.RequireScopes("users:read")(handler)))
+	mux.Handle("DELETE /users", auth(rest.RequireRoles("admin")(handler)))
+
+	call := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call(http.MethodGet, map[string]string{"Authorization": "Bearer " + token})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "user-1", rec.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, call(http.MethodGet, map[string]string{"Authorization": "Bearer invalid"}).Code)
+	assert.Equal(t, http.StatusForbidden, call(http.MethodDelete, map[string]string{"Authorization": "Bearer " + token}).Code)
+	assert.Equal(t, http.StatusForbidden, call(http.MethodGet, map[string]string{rest.DefaultApiKeyHeader: apiKey}).Code)
+
+	rec = call(http.MethodDelete, map[string]string{rest.DefaultApiKeyHeader: apiKey})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "billing-service", rec.Body.String())
+}
+
+func TestRestAuthMiddleware_ApiKeyValidation(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+
+	apiKey, err := utils.TokenUtils().CreateApiKey("billing-service")
+	require.NoError(t, err)
+	otherKey, err := utils.TokenUtils().CreateApiKey("unknown-service")
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher([]byte(tokenApiSecret))
+	require.NoError(t, err)
+	legacy := make([]byte, aes.BlockSize+len("billing-service"))
+	_, err = rand.Read(legacy[:aes.BlockSize])
+	require.NoError(t, err)
+	cipher.NewCFBEncrypter(block, legacy[:aes.BlockSize]).XORKeyStream(legacy[aes.BlockSize:], []byte("billing-service"))
+	legacyKey := hex.EncodeToString(legacy)
+	forgedKey := strings.Repeat("00", 20)
+
+	call := func(options rest.AuthOptions, apiKey string) int {
+		handler := rest.Authenticate(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(rest.DefaultApiKeyHeader, apiKey)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Legacy and forged keys are rejected by default
+	assert.Equal(t, http.StatusOK, call(rest.AuthOptions{}, apiKey))
+	assert.Equal(t, http.StatusUnauthorized, call(rest.AuthOptions{}, forgedKey))
+	assert.Equal(t, http.StatusUnauthorized, call(rest.AuthOptions{}, legacyKey))
+	assert.Equal(t, http.StatusUnauthorized, call(rest.AuthOptions{AllowLegacyApiKeys: true}, legacyKey))
+
+	// Application names are validated by the allowlist and resolver
+	allowed := rest.AuthOptions{ApiKeyApps: []string{"billing-service"}, AllowLegacyApiKeys: true}
+	assert.Equal(t, http.StatusOK, call(allowed, apiKey))
+	assert.Equal(t, http.StatusOK, call(allowed, legacyKey))
+	assert.Equal(t, http.StatusUnauthorized, call(allowed, forgedKey))
+	assert.Equal(t, http.StatusUnauthorized, call(allowed, otherKey))
+
+	resolved := rest.AuthOptions{ApiKeyResolver: func(r *http.Request, appName string) error {
+		if appName != "billing-service" {
+			return assert.AnError
+		}
+		return nil
+	}}
+	assert.Equal(t, http.StatusOK, call(resolved, apiKey))
+	assert.Equal(t, http.StatusUnauthorized, call(resolved, otherKey))
+}
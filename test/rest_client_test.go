@@ -0,0 +1,155 @@
+// REST client tests
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/rest/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestClient_JSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path != "/heroes/1" {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(list_of_heroes[0])
+		case http.MethodPost:
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			hero := &Hero{}
+			_ = json.NewDecoder(r.Body).Decode(hero)
+			hero.Name = hero.Name + " (created)"
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(hero)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL + "/").WithBearerToken("token")
+	ctx := context.Background()
+
+	hero, err := client.GetJSON[Hero](ctx, c, "heroes/1")
+	require.NoError(t, err)
+	assert.Equal(t, "Ant man", hero.Name)
+
+	created := &Hero{}
+	require.NoError(t, c.Post(ctx, "/heroes", &Hero{Name: "Thor"}, created))
+	assert.Equal(t, "Thor (created)", created.Name)
+
+	require.NoError(t, c.Delete(ctx, "/heroes/1", nil))
+
+	err = c.Get(ctx, "/heroes/2", &Hero{})
+	var httpErr *client.HttpError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+}
+
+func TestRestClient_RetryAndCircuitBreaker(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"name": "ok"}`))
+	}))
+	defer server.Close()
+
+	var attempts int32
+	c := client.New(server.URL).
+		WithRetry(client.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}).
+		WithMiddleware(func(next client.RoundTripFunc) client.RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				atomic.AddInt32(&attempts, 1)
+				return next(req)
+			}
+		})
+
+	result := map[string]string{}
+	require.NoError(t, c.Get(context.Background(), "/", &result))
+	assert.Equal(t, "ok", result["name"])
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	// Connection errors open the circuit after the threshold
+	down := client.New("http://127.0.0.1:1").
+		WithTimeout(100*time.Millisecond).
+		WithCircuitBreaker(2, 50*time.Millisecond)
+	assert.Error(t, down.Get(context.Background(), "/", nil))
+	assert.Error(t, down.Get(context.Background(), "/", nil))
+	assert.ErrorIs(t, down.Get(context.Background(), "/", nil), client.ErrCircuitOpen)
+
+	// After the cool-down a trial request is allowed
+	time.Sleep(60 * time.Millisecond)
+	err := down.Get(context.Background(), "/", nil)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, client.ErrCircuitOpen)
+}
+
+func TestRestClient_RetryIdempotentOnly(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := client.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	assert.Error(t, client.New(server.URL).WithRetry(policy).Post(context.Background(), "/", &Hero{}, nil))
+	assert.Equal(t, int32(1), atomic.SwapInt32(&calls, 0))
+
+	assert.Error(t, client.New(server.URL).WithRetry(policy).Put(context.Background(), "/", &Hero{}, nil))
+	assert.Equal(t, int32(3), atomic.SwapInt32(&calls, 0))
+
+	policy.RetryNonIdempotent = true
+	assert.Error(t, client.New(server.URL).WithRetry(policy).Post(context.Background(), "/", &Hero{}, nil))
+	assert.Equal(t, int32(3), atomic.SwapInt32(&calls, 0))
+}
+
+func TestRestClient_CircuitBreakerCountsUpstreamFailures(t *testing.T) {
+	status := int32(http.StatusBadRequest)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL).WithCircuitBreaker(1, time.Minute)
+
+	// 4xx responses, token source errors and canceled requests do not open the circuit
+	for i := 0; i < 3; i++ {
+		assert.NotErrorIs(t, c.Get(context.Background(), "/", nil), client.ErrCircuitOpen)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, c.Get(ctx, "/", nil), context.Canceled)
+	failing := client.New(server.URL).WithCircuitBreaker(1, time.Minute).WithTokenSource(func() (string, error) {
+		return "", errors.New("no token")
+	})
+	assert.Error(t, failing.Get(context.Background(), "/", nil))
+	assert.NotErrorIs(t, failing.Get(context.Background(), "/", nil), client.ErrCircuitOpen)
+
+	// 5xx response opens the circuit
+	atomic.StoreInt32(&status, http.StatusInternalServerError)
+	assert.NotErrorIs(t, c.Get(context.Background(), "/", nil), client.ErrCircuitOpen)
+	assert.ErrorIs(t, c.Get(context.Background(), "/", nil), client.ErrCircuitOpen)
+
+	// Threshold <= 0 disables the circuit breaker (concurrent requests are not serialized)
+	disabled := client.New(server.URL).WithCircuitBreaker(0, time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.NotErrorIs(t, disabled.Get(context.Background(), "/", nil), client.ErrCircuitOpen)
+	}
+}
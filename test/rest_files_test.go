@@ -0,0 +1,121 @@
+// REST upload and download helpers tests
+
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bufferSink struct {
+	bytes.Buffer
+}
+
+func (b *bufferSink) Close() error { return nil }
+
+type abortableSink struct {
+	bufferSink
+	closed  bool
+	aborted bool
+}
+
+func (a *abortableSink) Close() error { a.closed = true; return nil }
+
+func (a *abortableSink) Abort() error { a.aborted = true; return nil }
+
+func newUploadRequest(t *testing.T, files map[string]string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	require.NoError(t, mw.WriteField("description", "ignored"))
+	for name, content := range files {
+		part, err := mw.CreateFormFile("file", name)
+		require.NoError(t, err)
+		_, _ = part.Write([]byte(content))
+	}
+	require.NoError(t, mw.Close())
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestRest_ReceiveFiles(t *testing.T) {
+	sinks := map[string]*bufferSink{}
+	sink := func(file rest.UploadedFile) (io.WriteCloser, error) {
+		sinks[file.FileName] = &bufferSink{}
+		return sinks[file.FileName], nil
+	}
+
+	r := newUploadRequest(t, map[string]string{"heroes.txt": "Ant man, Batman"})
+	files, err := rest.ReceiveFiles(r, rest.UploadOptions{MaxFileSize: 100, AllowedTypes: []string{"text/"}}, sink)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+	assert.Equal(t, "file", files[0].Field)
+	assert.Equal(t, "text/plain; charset=utf-8", files[0].ContentType)
+	assert.Equal(t, int64(15), files[0].Size)
+	assert.Equal(t, "Ant man, Batman", sinks["heroes.txt"].String())
+
+	r = newUploadRequest(t, map[string]string{"big.txt": strings.Repeat("x", 101)})
+	_, err = rest.ReceiveFiles(r, rest.UploadOptions{MaxFileSize: 100}, sink)
+	assert.True(t, errors.Is(err, rest.ErrFileTooLarge))
+	assert.Equal(t, 100, sinks["big.txt"].Len())
+
+	// The sink of a too large file is aborted instead of closed
+	var abortable *abortableSink
+	r = newUploadRequest(t, map[string]string{"big.txt": strings.Repeat("x", 101)})
+	_, err = rest.ReceiveFiles(r, rest.UploadOptions{MaxFileSize: 100}, func(file rest.UploadedFile) (io.WriteCloser, error) {
+		abortable = &abortableSink{}
+		return abortable, nil
+	})
+	assert.True(t, errors.Is(err, rest.ErrFileTooLarge))
+	assert.True(t, abortable.aborted)
+	assert.False(t, abortable.closed)
+
+	// File of exactly the max size is accepted
+	r = newUploadRequest(t, map[string]string{"exact.txt": strings.Repeat("x", 100)})
+	files, err = rest.ReceiveFiles(r, rest.UploadOptions{MaxFileSize: 100}, sink)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), files[0].Size)
+
+	r = newUploadRequest(t, map[string]string{"heroes.txt": "Ant man"})
+	_, err = rest.ReceiveFiles(r, rest.UploadOptions{AllowedTypes: []string{"image/"}}, sink)
+	assert.True(t, errors.Is(err, rest.ErrUnsupportedMediaType))
+}
+
+func TestRest_ServeFile(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+
+	r := httptest.NewRequest(http.MethodGet, "/download", nil)
+	w := httptest.NewRecorder()
+	rest.ServeFile(w, r, "heroes list.txt", strings.NewReader(content), rest.DownloadOptions{})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="heroes list.txt"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, content, w.Body.String())
+
+	// Resume download from offset
+	r.Header.Set("Range", "bytes=90-")
+	w = httptest.NewRecorder()
+	rest.ServeFile(w, r, "heroes.txt", strings.NewReader(content), rest.DownloadOptions{Inline: true})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "inline; filename=heroes.txt", w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "0123456789", w.Body.String())
+
+	// Throttled download
+	w = httptest.NewRecorder()
+	start := time.Now()
+	rest.ServeFile(w, httptest.NewRequest(http.MethodGet, "/download", nil), "heroes.txt", strings.NewReader(content), rest.DownloadOptions{BytesPerSecond: 1000})
+	assert.Equal(t, content, w.Body.String())
+	assert.True(t, time.Since(start) >= 80*time.Millisecond)
+}
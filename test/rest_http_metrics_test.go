@@ -0,0 +1,49 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestHttpMetrics(t *testing.T) {
+	metrics := rest.NewHttpMetrics(0.1, 1)
+	mux := http.NewServeMux()
+	mux.Handle("GET /heroes/{id}", metrics.Handle("/heroes/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})))
+	mux.Handle("GET /metrics", metrics)
+
+	for _, id := range []string{"1", "2", "0"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/heroes/"+id, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	assert.Contains(t, body, `http_requests_total{route="/heroes/{id}",method="GET",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_total{route="/heroes/{id}",method="GET",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{route="/heroes/{id}",method="GET",le="+Inf"} 3`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{route="/heroes/{id}",method="GET"} 3`)
+	assert.Contains(t, body, `http_requests_in_flight{route="/heroes/{id}"} 0`)
+}
+
+func TestRestHttpMetrics_Labels(t *testing.T) {
+	metrics := rest.NewHttpMetrics()
+	handler := metrics.Handle("/files/\\\"a\"\n", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Non standard methods are recorded as OTHER
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/files", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("X-RANDOM-1", "/files", nil))
+
+	// Label values are escaped according to the exposition format (backslash, quote and new line only)
+	body := metrics.String()
+	assert.Contains(t, body, `http_requests_total{route="/files/\\\"a\"\n",method="OTHER",status="200"} 2`)
+	assert.Contains(t, body, `http_requests_in_flight{route="/files/\\\"a\"\n"} 0`)
+	assert.NotContains(t, body, "PROPFIND")
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestMiddleware(t *testing.T) {
+	entries := make([]rest.AccessLogEntry, 0)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(rest.RequestIdFromContext(r.Context())))
+	})
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handler := rest.Chain(mux, rest.RequestId, rest.AccessLog(func(entry rest.AccessLogEntry) {
+		entries = append(entries, entry)
+	}), rest.Recover)
+
+	// Request id is propagated
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(rest.RequestIdHeader, "req-1")
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(rest.RequestIdHeader))
+	assert.Equal(t, "req-1", rec.Body.String())
+
+	// Request id is generated and panic is recovered
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "internal server error")
+	assert.NotEmpty(t, rec.Header().Get(rest.RequestIdHeader))
+
+	if assert.Equal(t, 2, len(entries)) {
+		assert.Equal(t, http.StatusCreated, entries[0].Status)
+		assert.Equal(t, "192.0.2.1", entries[0].RemoteIP)
+		assert.Equal(t, "req-1", entries[0].RequestId)
+		assert.Equal(t, int64(5), entries[0].Bytes)
+		assert.Equal(t, http.StatusInternalServerError, entries[1].Status)
+		assert.Equal(t, "/panic", entries[1].Path)
+	}
+}
+
+func TestRestRemoteIP(t *testing.T) {
+	assert.Error(t, rest.SetTrustedProxies("not-an-ip"))
+	require.NoError(t, rest.SetTrustedProxies("10.0.0.0/8", "192.0.2.1"))
+	defer func() { _ = rest.SetTrustedProxies() }()
+
+	remoteIP := func(remoteAddr string, forwarded ...string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		for _, value := range forwarded {
+			req.Header.Add("X-Forwarded-For", value)
+		}
+		return rest.RemoteIP(req)
+	}
+
+	// Forwarded addresses of untrusted connections are ignored
+	assert.Equal(t, "203.0.113.5", remoteIP("203.0.113.5:4000", "198.51.100.7"))
+	assert.Equal(t, "203.0.113.5", remoteIP("203.0.113.5:4000"))
+
+	// The rightmost untrusted hop is the client, client supplied addresses are ignored
+	assert.Equal(t, "198.51.100.7", remoteIP("192.0.2.1:4000", "1.2.3.4, 198.51.100.7, 10.0.0.2"))
+	assert.Equal(t, "198.51.100.7", remoteIP("192.0.2.1:4000", "1.2.3.4", "198.51.100.7"))
+	assert.Equal(t, "10.0.0.2", remoteIP("192.0.2.1:4000", "10.0.0.2"))
+	assert.Equal(t, "192.0.2.1", remoteIP("192.0.2.1:4000"))
+}
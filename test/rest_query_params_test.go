@@ -0,0 +1,64 @@
+// REST query parameters helpers tests
+
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestGetQueryFromRequest(t *testing.T) {
+	db, fe := getInitializedDb()
+	require.NoError(t, fe)
+
+	r := httptest.NewRequest(http.MethodGet, "/heroes?filter=name:like:Bat*&filter=key:in:4|5|7&sort=-key&limit=2&page=0", nil)
+	query, err := rest.GetQueryFromRequest(r, db, NewHero, rest.QueryOptions{MaxLimit: 10})
+	require.NoError(t, err)
+
+	list, total, err := query.Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	names := []string{list[0].(*Hero).Name, list[1].(*Hero).Name}
+	assert.ElementsMatch(t, []string{"Bat Girl", "Bat Man"}, names)
+
+	// Time range on custom field
+	r = httptest.NewRequest(http.MethodGet, "/heroes?filter=name:like:C*&from=10&to=12", nil)
+	query, err = rest.GetQueryFromRequest(r, db, NewHero, rest.QueryOptions{TimeField: "key"})
+	require.NoError(t, err)
+	_, total, _ = query.Find()
+	assert.Equal(t, int64(3), total)
+
+	// Boolean filter expression
+	r = httptest.NewRequest(http.MethodGet, "/heroes?where="+url.QueryEscape("key<10 AND (name~Bat* OR name~Black*)"), nil)
+	query, err = rest.GetQueryFromRequest(r, db, NewHero)
+	require.NoError(t, err)
+	_, total, _ = query.Find()
+	assert.Equal(t, int64(5), total)
+
+	for _, bad := range []string{"filter=name:regex:x", "filter=name", "page=x", "limit=0", "from=yesterday", "sort=secret", "where=secret%3D1", "where=key%3Dx", "where=key%3E"} {
+		r = httptest.NewRequest(http.MethodGet, "/heroes?"+bad, nil)
+		_, err = rest.GetQueryFromRequest(r, db, NewHero, rest.QueryOptions{Fields: []string{"name", "key"}})
+		assert.Error(t, err, bad)
+	}
+
+	// Filters are validated against the entity without the allowed fields
+	for _, bad := range []string{"filter=secret:eq:1", "filter=key:eq:x", "filter=key:in:1|x", "filter=name:gt:A"} {
+		r = httptest.NewRequest(http.MethodGet, "/heroes?"+bad, nil)
+		_, err = rest.GetQueryFromRequest(r, db, NewHero)
+		assert.Error(t, err, bad)
+	}
+
+	// Filter values are converted to the field type
+	r = httptest.NewRequest(http.MethodGet, "/heroes?filter=key:between:4|6&filter=key:neq:5", nil)
+	query, err = rest.GetQueryFromRequest(r, db, NewHero)
+	require.NoError(t, err)
+	_, total, err = query.Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
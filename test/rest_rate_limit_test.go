@@ -0,0 +1,76 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestRateLimit(t *testing.T) {
+	dc, err := database.NewInMemoryDataCache()
+	require.NoError(t, err)
+
+	limiter := rest.RateLimit(dc, rest.RateLimitOptions{
+		Limit:     3,
+		Window:    time.Hour,
+		KeyFunc:   rest.RateLimitByHeader("X-TENANT-ID"),
+		Algorithm: rest.FixedWindow,
+	})
+	handler := limiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	call := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-TENANT-ID", tenant)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		rec := call("tenant-1")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "3", rec.Header().Get("X-RateLimit-Limit"))
+	}
+	rec := call("tenant-1")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Other tenant has its own counter and requests without key are not limited
+	assert.Equal(t, http.StatusOK, call("tenant-2").Code)
+	assert.Equal(t, http.StatusOK, call("").Code)
+
+	// Missing limit is a setup error
+	assert.Panics(t, func() { rest.RateLimit(dc, rest.RateLimitOptions{}) })
+}
+
+func TestRestRateLimitByIP(t *testing.T) {
+	dc, err := database.NewInMemoryDataCache()
+	require.NoError(t, err)
+
+	limiter := rest.RateLimit(dc, rest.RateLimitOptions{Limit: 2, Window: time.Hour, Algorithm: rest.FixedWindow})
+	handler := limiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Spoofed X-Forwarded-For headers don't bypass the limit
+	codes := make([]int, 0)
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "203.0.113.5:4000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", i))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
@@ -0,0 +1,64 @@
+// REST request parameters helpers tests
+
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestPathParams(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/heroes/12", nil)
+	r.SetPathValue("id", "12")
+	r.SetPathValue("neg", "-5")
+	r.SetPathValue("flag", "true")
+	r.SetPathValue("ratio", "0.5")
+	r.SetPathValue("name", "thor")
+	r.SetPathValue("ts", "2024-01-01T00:00:00Z")
+
+	assert.Equal(t, 12, rest.GetIntParamValueFromPath(r, "id", 7))
+	assert.Equal(t, int64(-5), rest.GetInt64ParamValueFromPath(r, "neg", 7))
+	assert.Equal(t, uint64(12), rest.GetUint64ParamValueFromPath(r, "id", 7))
+	assert.Equal(t, 0.5, rest.GetFloatParamValueFromPath(r, "ratio", 1))
+	assert.True(t, rest.GetBoolParamValueFromPath(r, "flag", false))
+	assert.Equal(t, "thor", rest.GetStringParamValueFromPath(r, "name", "none"))
+	assert.Equal(t, entity.Timestamp(1704067200000), rest.GetTimestampParamValueFromPath(r, "ts", 0))
+	assert.Equal(t, entity.Timestamp(12), rest.GetTimestampParamValueFromPath(r, "id", 0))
+
+	// Missing or invalid parameters return the default value
+	assert.Equal(t, 7, rest.GetIntParamValueFromPath(r, "missing", 7))
+	assert.Equal(t, 7, rest.GetIntParamValueFromPath(r, "name", 7))
+	assert.Equal(t, int64(7), rest.GetInt64ParamValueFromPath(r, "missing", 7))
+	assert.Equal(t, uint64(7), rest.GetUint64ParamValueFromPath(r, "neg", 7))
+	assert.Equal(t, 1.5, rest.GetFloatParamValueFromPath(r, "missing", 1.5))
+	assert.True(t, rest.GetBoolParamValueFromPath(r, "name", true))
+	assert.Equal(t, "none", rest.GetStringParamValueFromPath(r, "missing", "none"))
+	assert.Equal(t, entity.Timestamp(99), rest.GetTimestampParamValueFromPath(r, "name", 99))
+}
+
+func TestRestTimestampParams(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/events?from=1704067200000&to=2024-01-02T00:00:00Z&bad=yesterday", nil)
+
+	assert.Equal(t, entity.Timestamp(1704067200000), rest.GetTimestampParamValue(r, "from", 0))
+	assert.Equal(t, entity.Timestamp(5), rest.GetTimestampParamValue(r, "bad", 5))
+	assert.Equal(t, entity.Timestamp(5), rest.GetTimestampParamValue(r, "missing", 5))
+
+	tf, err := rest.GetTimeFrameParams(r, "from", "to")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.NewTimeFrame(1704067200000, 1704153600000), tf)
+
+	tf, err = rest.GetTimeFrameParams(httptest.NewRequest(http.MethodGet, "/events", nil), "from", "to")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.Timestamp(0), tf.From)
+	assert.True(t, tf.To > 0)
+
+	_, err = rest.GetTimeFrameParams(r, "from", "bad")
+	assert.Error(t, err)
+	_, err = rest.GetTimeFrameParams(r, "to", "from")
+	assert.Error(t, err)
+}
@@ -0,0 +1,37 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/rest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	require.NoError(t, rest.WriteError(rec, http.StatusBadRequest, "invalid request", map[string]string{"name": "required"}))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, rest.ContentTypeJSON, rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":400,"error":"invalid request","details":{"name":"required"}}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, rest.WritePage(rec, []string{"a", "b"}, 5, 1, 2))
+	page := rest.PageEnvelope[string]{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+	assert.Equal(t, []string{"a", "b"}, page.Items)
+	assert.Equal(t, 3, page.Pages)
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, rest.WritePage[string](rec, nil, 0, 1, 10))
+	assert.JSONEq(t, `{"items":[],"total":0,"page":1,"limit":10,"pages":0}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, rest.WriteProblem(rec, rest.NewProblem(http.StatusNotFound, "hero 7 not found").With("heroId", "7")))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, rest.ContentTypeProblemJSON, rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"hero 7 not found","heroId":"7"}`, rec.Body.String())
+}
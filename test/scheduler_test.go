@@ -0,0 +1,139 @@
+// Scheduler tests
+
+package test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/utils/scheduler"
+)
+
+func TestScheduler_ParseCron(t *testing.T) {
+	from := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC) // Friday
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 3, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * mon-fri", time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * sat,sun", time.Date(2024, 3, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"*/20 * * * * *", time.Date(2024, 3, 15, 10, 7, 40, 0, time.UTC)},
+		{"@daily", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+		{"0 12 1 * 1", time.Date(2024, 3, 18, 12, 0, 0, 0, time.UTC)}, // day-of-month or day-of-week
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},   // Sunday as 7
+	}
+	for _, test := range tests {
+		s, err := scheduler.ParseCron(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.next, s.Next(from), test.expr)
+	}
+
+	// Time zone of the given time
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	s := scheduler.MustParseCron("0 8 * * *")
+	assert.Equal(t, time.Date(2024, 3, 16, 8, 0, 0, 0, loc), s.Next(from.In(loc)))
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "@every x"} {
+		_, err := scheduler.ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestScheduler_Jobs(t *testing.T) {
+	var runs, panics atomic.Int32
+
+	s := scheduler.NewScheduler()
+	require.NoError(t, s.RegisterInterval("counter", 20*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}, scheduler.JobOptions{}))
+	require.NoError(t, s.RegisterInterval("panic", 20*time.Millisecond, func(ctx context.Context) error {
+		panics.Add(1)
+		panic("boom")
+	}, scheduler.JobOptions{}))
+	require.Error(t, s.RegisterInterval("counter", time.Second, func(ctx context.Context) error { return nil }, scheduler.JobOptions{}))
+	require.Error(t, s.RegisterCron("invalid", "* *", func(ctx context.Context) error { return nil }, scheduler.JobOptions{}))
+
+	require.NoError(t, s.Start(context.Background()))
+	time.Sleep(150 * time.Millisecond)
+	require.NoError(t, s.Stop(time.Second))
+
+	assert.GreaterOrEqual(t, runs.Load(), int32(3))
+	assert.GreaterOrEqual(t, panics.Load(), int32(3))
+
+	jobs := s.Jobs()
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "counter", jobs[0].Name)
+	assert.Equal(t, 0, jobs[0].Failures)
+	assert.Equal(t, "panic", jobs[1].Name)
+	assert.Equal(t, jobs[1].Runs, jobs[1].Failures)
+	assert.Contains(t, jobs[1].LastError, "boom")
+}
+
+func TestScheduler_DistributedSingleton(t *testing.T) {
+	dc, err := database.NewInMemoryDataCache()
+	require.NoError(t, err)
+
+	// Two instances of the service share the data cache lock, only one of them runs each activation
+	var runs atomic.Int32
+	instances := make([]*scheduler.Scheduler, 2)
+	for i := range instances {
+		instances[i] = scheduler.NewScheduler()
+		err = instances[i].RegisterInterval("singleton", 50*time.Millisecond, func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		}, scheduler.JobOptions{Locker: dc, LockTTL: time.Hour})
+		require.NoError(t, err)
+		require.NoError(t, instances[i].Start(context.Background()))
+	}
+	time.Sleep(220 * time.Millisecond)
+	for _, s := range instances {
+		require.NoError(t, s.Close())
+	}
+
+	// The lock is held for an hour, so only the first activation ran
+	assert.Equal(t, int32(1), runs.Load())
+	skipped := instances[0].Jobs()[0].Skipped + instances[1].Jobs()[0].Skipped
+	assert.GreaterOrEqual(t, skipped, 3)
+}
+
+func TestDataCache_Locker(t *testing.T) {
+	dc, err := database.NewInMemoryDataCache()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	locker, err := dc.ObtainLocker("resource", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "resource", locker.Key())
+	assert.NotEmpty(t, locker.Token())
+
+	_, err = dc.ObtainLocker("resource", time.Minute)
+	assert.Error(t, err, "lock is held")
+
+	ttl, err := locker.TTL(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, ttl, 50*time.Second)
+
+	require.NoError(t, locker.Release(ctx))
+	assert.Error(t, locker.Release(ctx))
+
+	// Expired lock can be obtained again
+	expired, err := dc.ObtainLocker("resource-2", time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	assert.Error(t, expired.Refresh(ctx, time.Minute))
+	_, err = dc.ObtainLocker("resource-2", time.Minute)
+	assert.NoError(t, err)
+}
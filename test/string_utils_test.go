@@ -0,0 +1,29 @@
+// String utilities tests
+
+package test
+
+import (
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	su := utils.StringUtils()
+	for pattern, cases := range map[string]map[string]bool{
+		"user:*":   {"user:1": true, "user:": true, "users:1": false},
+		"h?llo":    {"hello": true, "hallo": true, "hllo": false},
+		"h[ae]llo": {"hello": true, "hallo": true, "hillo": false},
+		"h[^e]llo": {"hallo": true, "hello": false},
+		"h[!e]llo": {"hallo": true, "hello": false},
+		"h[a-c]":   {"hb": true, "hd": false},
+		`a\*b`:     {"a*b": true, "axb": false},
+		"a.b[":     {"a.b[": true, "axb[": false},
+		"(x)+":     {"(x)+": true, "xx": false},
+	} {
+		for source, expected := range cases {
+			assert.Equal(t, expected, su.GlobMatch(source, pattern), "%s ~ %s", source, pattern)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Tracing helpers tests
+
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/messaging"
+	"github.com/go-yaaf/yaaf-common/rest/client"
+	"github.com/go-yaaf/yaaf-common/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetry_TraceParent(t *testing.T) {
+	sc, err := telemetry.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceId)
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanId)
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", telemetry.FormatTraceParent(sc))
+
+	for _, invalid := range []string{"", "00-abc-def-01", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"} {
+		_, err = telemetry.ParseTraceParent(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestTelemetry_Http(t *testing.T) {
+	tracer := telemetry.NewInMemoryTracer()
+	server := httptest.NewServer(telemetry.HttpServerTracing(tracer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := telemetry.SpanFromContext(r.Context())
+		assert.True(t, ok)
+		w.WriteHeader(http.StatusInternalServerError)
+	})))
+	defer server.Close()
+
+	ctx, parent := tracer.Start(context.Background(), "parent", telemetry.SpanKindInternal)
+	c := client.New(server.URL).WithMiddleware(telemetry.HttpClientTracing(tracer))
+	assert.Error(t, c.Get(ctx, "/heroes", nil))
+	parent.End()
+
+	spans := tracer.Spans()
+	require.Equal(t, 3, len(spans))
+	serverSpan, clientSpan := spans[0], spans[1]
+	assert.Equal(t, telemetry.SpanKindServer, serverSpan.Kind)
+	assert.Equal(t, "HTTP GET", serverSpan.Name)
+	assert.Equal(t, "/heroes", serverSpan.Attributes["http.path"])
+	assert.Equal(t, http.StatusInternalServerError, serverSpan.Attributes["http.status_code"])
+	assert.Error(t, serverSpan.Error)
+
+	// All spans share the trace, the server span is a child of the client span
+	assert.Equal(t, telemetry.SpanKindClient, clientSpan.Kind)
+	assert.Equal(t, parent.Context().SpanId, clientSpan.ParentId)
+	assert.Equal(t, clientSpan.Context.SpanId, serverSpan.ParentId)
+	assert.Equal(t, parent.Context().TraceId, serverSpan.Context.TraceId)
+}
+
+func TestTelemetry_Messaging(t *testing.T) {
+	tracer := telemetry.NewInMemoryTracer()
+	bus, err := messaging.NewInMemoryMessageBus()
+	require.NoError(t, err)
+	bus.Use(telemetry.MessageTracing(tracer))
+
+	received := make(chan context.Context, 1)
+	_, err = bus.Subscribe("tracing", NewHeroMessage, func(msg messaging.IMessage) bool {
+		received <- telemetry.ExtractMessage(context.Background(), msg)
+		return true
+	}, "heroes_tracing")
+	require.NoError(t, err)
+
+	ctx, parent := tracer.Start(context.Background(), "parent", telemetry.SpanKindInternal)
+	message := newHeroMessage("heroes_tracing", list_of_heroes[0].(*Hero))
+	telemetry.InjectMessage(ctx, message)
+	require.NoError(t, bus.Publish(message))
+	parent.End()
+
+	select {
+	case consumerCtx := <-received:
+		sc, ok := telemetry.SpanContextFromContext(consumerCtx)
+		require.True(t, ok)
+		assert.Equal(t, parent.Context().TraceId, sc.TraceId)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+
+	// Wait for the consumer span to end
+	require.Eventually(t, func() bool { return len(tracer.Spans()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	kinds := make(map[telemetry.SpanKind]telemetry.SpanData)
+	for _, span := range tracer.Spans() {
+		kinds[span.Kind] = span
+	}
+	assert.Equal(t, parent.Context().SpanId, kinds[telemetry.SpanKindProducer].ParentId)
+	assert.Equal(t, kinds[telemetry.SpanKindProducer].Context.SpanId, kinds[telemetry.SpanKindConsumer].ParentId)
+	assert.Equal(t, "heroes_tracing", kinds[telemetry.SpanKindConsumer].Attributes["messaging.destination"])
+}
+
+func TestTelemetry_MessagingConsumerCopy(t *testing.T) {
+	tracer := telemetry.NewInMemoryTracer()
+	message := newHeroMessage("heroes_tracing", list_of_heroes[0].(*Hero))
+	message.(*HeroMessage).SetHeader("tenant", "acme")
+
+	var delivered messaging.IMessage
+	callback := telemetry.MessageTracing(tracer).InterceptConsume(func(msg messaging.IMessage) bool {
+		delivered = msg
+		return true
+	})
+	assert.True(t, callback(message))
+
+	// The callback gets a copy of the message carrying the consumer span, the delivered message is not modified
+	require.NotNil(t, delivered)
+	hero, ok := delivered.(*HeroMessage)
+	require.True(t, ok)
+	assert.Equal(t, "acme", hero.Header("tenant"))
+	sc, ok := telemetry.SpanContextFromContext(telemetry.ExtractMessage(context.Background(), delivered))
+	require.True(t, ok)
+	assert.Equal(t, tracer.Spans()[0].Context.SpanId, sc.SpanId)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, message.Headers())
+}
+
+func TestTelemetry_Database(t *testing.T) {
+	db, err := getInitializedDb()
+	require.NoError(t, err)
+
+	tracer := telemetry.NewInMemoryTracer()
+	ctx, parent := tracer.Start(context.Background(), "parent", telemetry.SpanKindInternal)
+	traced := telemetry.TraceDatabase(ctx, db, tracer)
+
+	_, err = traced.Get(NewHero, "1")
+	require.NoError(t, err)
+	_, err = traced.Get(NewHero, "missing")
+	require.Error(t, err)
+
+	count, err := traced.Query(NewHero).Filter(database.F("key").Eq(1)).Count()
+	require.NoError(t, err)
+	assert.True(t, count > 0)
+	parent.End()
+
+	spans := tracer.Spans()
+	require.Equal(t, 4, len(spans))
+	assert.Equal(t, "db.Get", spans[0].Name)
+	assert.Equal(t, "hero", spans[0].Attributes["db.table"])
+	assert.NoError(t, spans[0].Error)
+	assert.Error(t, spans[1].Error)
+	assert.Equal(t, "db.query.Count", spans[2].Name)
+	assert.NotEmpty(t, spans[2].Attributes["db.statement"])
+	for _, span := range spans[:3] {
+		assert.Equal(t, parent.Context().SpanId, span.ParentId)
+	}
+}
+
+func TestTelemetry_DatabaseQueryBuilders(t *testing.T) {
+	db, err := getInitializedDb()
+	require.NoError(t, err)
+
+	tracer := telemetry.NewInMemoryTracer()
+	traced := telemetry.TraceDatabase(context.Background(), db, tracer)
+	builders := queryBuilders(t)
+	for _, builder := range builders {
+		_, err = builder(traced.Query(NewHero)).Count()
+		require.NoError(t, err)
+	}
+
+	// Each builder chain keeps the tracing wrapper
+	spans := tracer.Spans()
+	require.Equal(t, len(builders), len(spans))
+	for _, span := range spans {
+		assert.Equal(t, "db.query.Count", span.Name)
+	}
+}
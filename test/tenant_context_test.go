@@ -0,0 +1,117 @@
+// Tenant context and tenant bound database tests
+
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	. "github.com/go-yaaf/yaaf-common/database"
+	. "github.com/go-yaaf/yaaf-common/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TenantHero struct {
+	Hero
+	Tenant string `json:"tenant"`
+}
+
+func (a TenantHero) TABLE() string { return "hero-{{accountId}}" }
+func (a TenantHero) KEY() string   { return a.Tenant }
+
+func NewTenantHero() Entity {
+	return &TenantHero{}
+}
+
+func TestTenantDatabase(t *testing.T) {
+	db, err := NewInMemoryDatabase()
+	require.NoError(t, err)
+
+	for i, h := range list_of_heroes[0:6] {
+		tenant := "tenant-a"
+		if i%3 == 0 {
+			tenant = "tenant-b"
+		}
+		_, err = db.Insert(&TenantHero{Hero: *h.(*Hero), Tenant: tenant})
+		require.NoError(t, err)
+	}
+
+	_, err = ForTenantContext(context.Background(), db)
+	assert.Error(t, err)
+
+	ctx := WithTenant(context.Background(), "tenant-a")
+	tenantId, ok := TenantFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "tenant-a", tenantId)
+
+	tdb, err := ForTenantContext(ctx, db)
+	require.NoError(t, err)
+
+	hero, err := tdb.Get(NewTenantHero, "2")
+	require.NoError(t, err)
+	assert.Equal(t, "Aqua man", hero.(*TenantHero).Name)
+
+	exists, _ := tdb.Exists(NewTenantHero, "1")
+	assert.False(t, exists)
+
+	// Keys are injected along the builder chain
+	list, total, err := tdb.Query(NewTenantHero).Filter(F("key").Gt(2)).Sort("key").Find()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Equal(t, 3, len(list))
+
+	count, err := ForTenant(tdb, "tenant-b").Query(NewTenantHero).Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	// Explicit keys take precedence
+	count, err = tdb.Query(NewTenantHero).Count("tenant-b")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+// queryBuilders returns a call of each IQuery builder method (methods returning the query), the calls do not filter out
+// any entity
+func queryBuilders(t *testing.T) map[string]func(q IQuery) IQuery {
+	builders := map[string]func(q IQuery) IQuery{
+		"Apply":    func(q IQuery) IQuery { return q.Apply(func(in Entity) Entity { return in }) },
+		"Filter":   func(q IQuery) IQuery { return q.Filter(F("key").Gte(0)) },
+		"Range":    func(q IQuery) IQuery { return q.Range("key", 0, 1000) },
+		"MatchAll": func(q IQuery) IQuery { return q.MatchAll(F("key").Gte(0)) },
+		"MatchAny": func(q IQuery) IQuery { return q.MatchAny(F("key").Gte(0)) },
+		"And":      func(q IQuery) IQuery { return q.And(F("key").Gte(0)) },
+		"Or":       func(q IQuery) IQuery { return q.Or(F("key").Gte(0)) },
+		"Not":      func(q IQuery) IQuery { return q.Not(F("key").Lt(0)) },
+		"Sort":     func(q IQuery) IQuery { return q.Sort("key") },
+		"Page":     func(q IQuery) IQuery { return q.Page(0) },
+		"Limit":    func(q IQuery) IQuery { return q.Limit(100) },
+	}
+
+	// Ensure all the builder methods are covered
+	queryType := reflect.TypeOf((*IQuery)(nil)).Elem()
+	for i := 0; i < queryType.NumMethod(); i++ {
+		method := queryType.Method(i)
+		if method.Type.NumOut() == 1 && method.Type.Out(0) == queryType {
+			assert.Contains(t, builders, method.Name, "builder method is not covered")
+		}
+	}
+	return builders
+}
+
+func TestTenantDatabase_QueryBuilders(t *testing.T) {
+	db, err := NewInMemoryDatabase()
+	require.NoError(t, err)
+	for _, h := range list_of_heroes[0:3] {
+		_, err = db.Insert(&TenantHero{Hero: *h.(*Hero), Tenant: "tenant-a"})
+		require.NoError(t, err)
+	}
+
+	tdb := ForTenant(db, "tenant-a")
+	for name, builder := range queryBuilders(t) {
+		_, total, fe := builder(tdb.Query(NewTenantHero)).Find()
+		require.NoError(t, fe, name)
+		assert.Equal(t, int64(3), total, name)
+	}
+}
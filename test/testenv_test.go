@@ -0,0 +1,84 @@
+// Test environment tests
+
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-yaaf/yaaf-common/config"
+	"github.com/go-yaaf/yaaf-common/utils/testenv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeContainer records the container start / stop events
+type fakeContainer struct {
+	name   string
+	events *[]string
+	err    error
+}
+
+func (c *fakeContainer) Run() error {
+	*c.events = append(*c.events, "run:"+c.name)
+	return c.err
+}
+
+func (c *fakeContainer) Stop() error {
+	*c.events = append(*c.events, "stop:"+c.name)
+	return nil
+}
+
+func TestTestEnvironment_Start(t *testing.T) {
+	events := make([]string, 0)
+
+	t.Run("services", func(t *testing.T) {
+		env := testenv.NewTestEnvironment(t)
+		env.Service("bus", &fakeContainer{name: "bus", events: &events}).DependsOn("cache", "db")
+		env.Service("cache", &fakeContainer{name: "cache", events: &events}).
+			DependsOn("db").
+			Config(config.CfgDataCacheUri, "redis://localhost:6379")
+		env.Service("db", &fakeContainer{name: "db", events: &events}).
+			Config(config.CfgDatabaseUri, "postgres://localhost:5432/db").
+			Ready(func() error {
+				events = append(events, "ready:db")
+				return nil
+			})
+
+		require.NoError(t, env.Start())
+		assert.Equal(t, []string{"db", "cache", "bus"}, env.Started())
+		assert.Equal(t, "postgres://localhost:5432/db", config.Get().DatabaseUri())
+		assert.Equal(t, "redis://localhost:6379", config.Get().DataCacheUri())
+	})
+
+	// The services are stopped in reverse order when the test completes
+	assert.Equal(t, []string{"run:db", "ready:db", "run:cache", "run:bus", "stop:bus", "stop:cache", "stop:db"}, events)
+}
+
+func TestTestEnvironment_Failures(t *testing.T) {
+	events := make([]string, 0)
+
+	env := testenv.NewTestEnvironment(t)
+	env.Service("db", &fakeContainer{name: "db", events: &events})
+	env.Service("cache", &fakeContainer{name: "cache", events: &events}).DependsOn("db").
+		Ready(func() error { return errors.New("timeout") })
+
+	err := env.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "service cache is not ready")
+	assert.Equal(t, []string{"run:db", "run:cache", "stop:cache", "stop:db"}, events)
+	assert.Empty(t, env.Started())
+
+	env = testenv.NewTestEnvironment(t)
+	env.Service("a", &fakeContainer{name: "a", events: &events}).DependsOn("b")
+	env.Service("b", &fakeContainer{name: "b", events: &events}).DependsOn("a")
+	err = env.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circular service dependency")
+
+	env = testenv.NewTestEnvironment(t)
+	env.Service("a", &fakeContainer{name: "a", events: &events}).DependsOn("missing")
+	err = env.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown service missing")
+}
@@ -0,0 +1,151 @@
+// Time series transformations tests
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSeries(name string, values map[entity.Timestamp]float64) *entity.TimeSeries[float64] {
+	ts := &entity.TimeSeries[float64]{Name: name}
+	for t, v := range values {
+		ts.Values = append(ts.Values, entity.NewTimeDataPoint(t, v))
+	}
+	return ts
+}
+
+func seriesValues[T any](ts *entity.TimeSeries[T]) []T {
+	result := make([]T, 0)
+	for _, dp := range ts.Values {
+		result = append(result, dp.Value)
+	}
+	return result
+}
+
+func TestTimeSeries_Resample(t *testing.T) {
+	ts := newTestSeries("cpu", map[entity.Timestamp]float64{0: 1, 400: 3, 1000: 5, 1999: 7, 3500: 10})
+
+	assert.Equal(t, []float64{4, 12, 10}, seriesValues(ts.Resample(time.Second, entity.AggSum[float64])))
+	assert.Equal(t, []float64{2, 6, 10}, seriesValues(ts.Resample(time.Second, entity.AggAvg[float64])))
+	assert.Equal(t, []float64{3, 7, 10}, seriesValues(ts.Resample(time.Second, entity.AggMax[float64])))
+
+	resampled := ts.Resample(time.Second, entity.AggMin[float64])
+	assert.Equal(t, []float64{1, 5, 10}, seriesValues(resampled))
+	assert.Equal(t, entity.Timestamp(3000), resampled.Values[2].Timestamp)
+
+	min, max := entity.SeriesMinMax(ts)
+	assert.Equal(t, 1.0, min)
+	assert.Equal(t, 10.0, max)
+	assert.Equal(t, 26.0, entity.SeriesSum(ts))
+	assert.Equal(t, 5.2, entity.SeriesAvg(ts))
+}
+
+func TestTimeSeries_MergeAndFill(t *testing.T) {
+	a := newTestSeries("a", map[entity.Timestamp]float64{1000: 1, 2000: 2})
+	b := newTestSeries("b", map[entity.Timestamp]float64{2000: 10, 5000: 50})
+
+	merged := a.Merge(b, func(x, y float64) float64 { return x + y })
+	assert.Equal(t, []float64{1, 12, 50}, seriesValues(merged))
+
+	assert.Equal(t, []float64{1, 12, 0, 0, 50}, seriesValues(merged.Fill(time.Second, entity.FillZero)))
+	assert.Equal(t, []float64{1, 12, 12, 12, 50}, seriesValues(merged.Fill(time.Second, entity.FillPrevious)))
+	assert.InDeltaSlice(t, []float64{1, 12, 24.667, 37.333, 50}, seriesValues(merged.Fill(time.Second, entity.FillInterpolate)), 0.001)
+
+	// Align to time frame drops points out of range and fills the grid
+	aligned := merged.AlignTo(entity.NewTimeFrame(0, 4000), 2*time.Second, entity.FillPrevious)
+	assert.Equal(t, []entity.Timestamp{0, 2000, 4000}, []entity.Timestamp{aligned.Values[0].Timestamp, aligned.Values[1].Timestamp, aligned.Values[2].Timestamp})
+	assert.Equal(t, []float64{1, 12, 12}, seriesValues(aligned))
+
+	// Interpolation of integer series
+	ints := &entity.TimeSeries[int]{Values: []entity.TimeDataPoint[int]{entity.NewTimeDataPoint[int](0, 0), entity.NewTimeDataPoint[int](4000, 8)}}
+	assert.Equal(t, []int{0, 2, 4, 6, 8}, seriesValues(ints.Fill(time.Second, entity.FillInterpolate)))
+
+	// Data points which are not on the grid are kept, only the empty intervals are filled
+	offGrid := &entity.TimeSeries[int]{Range: entity.NewTimeFrame(0, 4000), Values: []entity.TimeDataPoint[int]{entity.NewTimeDataPoint[int](1500, 7), entity.NewTimeDataPoint[int](3500, 9)}}
+	filled := offGrid.Fill(time.Second, entity.FillInterpolate)
+	assert.Equal(t, []int{0, 7, 7, 9, 9}, seriesValues(filled))
+	assert.Equal(t, entity.Timestamp(1500), filled.Values[1].Timestamp)
+	assert.Equal(t, entity.Timestamp(2000), filled.Values[2].Timestamp)
+
+	// Interface typed series
+	anys := &entity.TimeSeries[any]{Values: []entity.TimeDataPoint[any]{entity.NewTimeDataPoint[any](0, 0), entity.NewTimeDataPoint[any](2000, 4), entity.NewTimeDataPoint[any](4000, "x")}}
+	assert.Equal(t, []any{0, 2, 4, 4, "x"}, seriesValues(anys.Fill(time.Second, entity.FillInterpolate)))
+
+	// Too many intervals are not filled
+	wide := &entity.TimeSeries[int]{Range: entity.NewTimeFrame(0, entity.Timestamp(time.Hour.Milliseconds()*24*365))}
+	assert.Empty(t, wide.Fill(time.Millisecond, entity.FillZero).Values)
+}
+
+func TestMultiSeries_FromHistogram(t *testing.T) {
+	hour := entity.Timestamp(time.Hour.Milliseconds())
+	histogram := map[entity.Timestamp]map[any]entity.Tuple[int64, float64]{
+		0:        {"web": entity.NewTuple[int64, float64](2, 10), "mobile": entity.NewTuple[int64, float64](1, 5)},
+		2 * hour: {"web": entity.NewTuple[int64, float64](4, 20)},
+		3 * hour: {3: entity.NewTuple[int64, float64](1, 1)},
+	}
+
+	// Aligned buckets with zero-filled missing values
+	ms := entity.NewMultiSeriesFromHistogram(histogram, entity.MultiSeriesOptions{Interval: time.Hour})
+	assert.Equal(t, []entity.Timestamp{0, hour, 2 * hour, 3 * hour}, ms.Buckets)
+	assert.Equal(t, entity.NewTimeFrame(0, 4*hour), ms.Range)
+	assert.Equal(t, []string{"3", "mobile", "web"}, ms.Names())
+	assert.Equal(t, [][]float64{{0, 5, 10}, {0, 0, 0}, {0, 0, 20}, {1, 0, 0}}, ms.Matrix())
+	for _, series := range ms.Series {
+		assert.Len(t, series.Values, 4)
+	}
+
+	// Counts within an explicit range
+	counts := entity.NewMultiSeriesFromHistogram(histogram, entity.MultiSeriesOptions{Range: entity.NewTimeFrame(hour, 4*hour), Interval: time.Hour, Count: true})
+	assert.Equal(t, []entity.Timestamp{hour, 2 * hour, 3 * hour}, counts.Buckets)
+	assert.Equal(t, []string{"3", "web"}, counts.Names())
+	assert.Equal(t, [][]float64{{0, 0}, {0, 4}, {1, 0}}, counts.Matrix())
+
+	// Without interval only the histogram buckets are used
+	sparse := entity.NewMultiSeriesFromHistogram(histogram, entity.MultiSeriesOptions{})
+	assert.Equal(t, []entity.Timestamp{0, 2 * hour, 3 * hour}, sparse.Buckets)
+
+	// Export
+	buf := bytes.Buffer{}
+	assert.NoError(t, counts.WriteCSV(&buf, ""))
+	assert.Equal(t, "timestamp,3,web\n3600000,0,0\n7200000,0,4\n10800000,1,0\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, counts.WriteJSON(&buf))
+	rows := make([]map[string]float64, 0)
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &rows))
+	assert.Equal(t, []map[string]float64{
+		{"timestamp": 3600000, "3": 0, "web": 0},
+		{"timestamp": 7200000, "3": 0, "web": 4},
+		{"timestamp": 10800000, "3": 1, "web": 0},
+	}, rows)
+
+	empty := entity.NewMultiSeriesFromHistogram(nil, entity.MultiSeriesOptions{Interval: time.Hour})
+	assert.Empty(t, empty.Buckets)
+	assert.Empty(t, empty.Series)
+
+	// Filled buckets are aligned with the histogram buckets when the range start is not aligned
+	minute := entity.Timestamp(time.Minute.Milliseconds())
+	shifted := map[entity.Timestamp]map[any]entity.Tuple[int64, float64]{
+		hour + 5*minute:   {"web": entity.NewTuple[int64, float64](1, 1)},
+		3*hour + 5*minute: {"web": entity.NewTuple[int64, float64](1, 3)},
+	}
+	aligned := entity.NewMultiSeriesFromHistogram(shifted, entity.MultiSeriesOptions{Range: entity.NewTimeFrame(0, 4*hour), Interval: time.Hour})
+	assert.Equal(t, []entity.Timestamp{5 * minute, hour + 5*minute, 2*hour + 5*minute, 3*hour + 5*minute}, aligned.Buckets)
+	assert.Equal(t, [][]float64{{0}, {1}, {0}, {3}}, aligned.Matrix())
+
+	// Series named as the time column don't overwrite the bucket time
+	collision := entity.NewMultiSeriesFromHistogram(map[entity.Timestamp]map[any]entity.Tuple[int64, float64]{
+		hour: {"timestamp": entity.NewTuple[int64, float64](1, 7)},
+	}, entity.MultiSeriesOptions{})
+	assert.Equal(t, []string{"timestamp_2"}, collision.Columns())
+	assert.Equal(t, []map[string]any{{"timestamp": hour, "timestamp_2": float64(7)}}, collision.Rows())
+	buf.Reset()
+	assert.NoError(t, collision.WriteCSV(&buf, ""))
+	assert.Equal(t, "timestamp,timestamp_2\n3600000,7\n", buf.String())
+}
@@ -4,10 +4,14 @@ package test
 
 import (
 	"fmt"
-	"github.com/go-yaaf/yaaf-common/entity"
-	"github.com/go-yaaf/yaaf-common/utils"
 	"testing"
 	"time"
+	_ "time/tzdata"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBounds(t *testing.T) {
@@ -85,3 +89,101 @@ func printFrames(period time.Duration, interval time.Duration, format string) {
 		fmt.Println(i, f.String(format))
 	}
 }
+
+func TestCalendarSeries(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	local := func(year int, month time.Month, day, hour int) entity.Timestamp {
+		return entity.Timestamp(time.Date(year, month, day, hour, 0, 0, 0, ny).UnixMilli())
+	}
+
+	// Daily frames are aligned to the local midnight, including the daylight saving day (23 hours)
+	from := local(2024, 3, 9, 10)
+	to := local(2024, 3, 12, 0)
+	frames := utils.TimeUtils(from).GetTimeFramesIn(ny, to, entity.TimePeriodCodes.DAY)
+	require.Len(t, frames, 3)
+	assert.Equal(t, local(2024, 3, 9, 0), frames[0].From)
+	assert.Equal(t, local(2024, 3, 10, 0), frames[1].From)
+	assert.Equal(t, 23*time.Hour, frames[1].Duration())
+	assert.Equal(t, 24*time.Hour, frames[2].Duration())
+	assert.Equal(t, to, frames[2].To)
+
+	// Inclusive / exclusive end
+	assert.Len(t, utils.TimeUtils(from).GetSeriesIn(ny, to, entity.TimePeriodCodes.DAY, false), 3)
+	series := utils.TimeUtils(from).GetSeriesIn(ny, to, entity.TimePeriodCodes.DAY, true)
+	require.Len(t, series, 4)
+	assert.Equal(t, to, series[3])
+
+	// The same instant is a different day in another time zone
+	utc := utils.TimeUtils(from).GetSeriesIn(time.UTC, to, entity.TimePeriodCodes.DAY, false)
+	assert.Equal(t, entity.Timestamp(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC).UnixMilli()), utc[0])
+
+	// Weeks start on Monday, months on the first day of the month
+	assert.Equal(t, local(2024, 3, 4, 0), utils.TimeUtils(from).In(ny).StartOf(entity.TimePeriodCodes.WEEK).Get())
+	assert.Equal(t, local(2024, 3, 1, 0), utils.TimeUtils(from).In(ny).StartOf(entity.TimePeriodCodes.MONTH).Get())
+	months := utils.TimeUtils(local(2024, 1, 31, 12)).GetCalendarSeries(local(2024, 5, 1, 0), utils.SeriesOptions{
+		Location: ny, Period: entity.TimePeriodCodes.MONTH, Align: true, InclusiveEnd: true,
+	})
+	assert.Equal(t, []entity.Timestamp{local(2024, 1, 1, 0), local(2024, 2, 1, 0), local(2024, 3, 1, 0), local(2024, 4, 1, 0), local(2024, 5, 1, 0)}, months)
+
+	// Unaligned series with multiple periods per step
+	hours := utils.TimeUtils(local(2024, 3, 9, 10)).GetCalendarSeriesMap(local(2024, 3, 9, 16), utils.SeriesOptions{
+		Location: ny, Period: entity.TimePeriodCodes.HOUR, Count: 2,
+	})
+	assert.Len(t, hours, 3)
+	assert.Contains(t, hours, local(2024, 3, 9, 14))
+
+	// Adding days keeps the local time of day across daylight saving change
+	assert.Equal(t, local(2024, 3, 10, 10), utils.TimeUtils(from).In(ny).AddPeriod(entity.TimePeriodCodes.DAY, 1).Get())
+
+	// Unsupported period
+	assert.Empty(t, utils.TimeUtils(from).GetSeriesIn(ny, to, entity.TimePeriodCodes.UNDEFINED, true))
+}
+
+func TestBusinessCalendar(t *testing.T) {
+	utils.RegisterHolidayCalendar("TEST-US", utils.NewHolidayList().
+		AddYearly(time.December, 25, "Christmas").
+		Add(2024, time.November, 28, "Thanksgiving"))
+	utils.RegisterHolidayCalendar("TEST-IL", utils.NewHolidayList(time.Friday, time.Saturday))
+
+	ts := func(month time.Month, day, hour int) entity.Timestamp {
+		return entity.Timestamp(time.Date(2024, month, day, hour, 0, 0, 0, time.UTC).UnixMilli())
+	}
+
+	// Wednesday before Thanksgiving
+	wednesday := ts(time.November, 27, 10)
+	assert.True(t, utils.TimeUtils(wednesday).IsBusinessDay("TEST-US"))
+	assert.False(t, utils.TimeUtils(ts(time.November, 28, 10)).IsBusinessDay("TEST-US"))
+	assert.False(t, utils.TimeUtils(ts(time.December, 25, 10)).IsBusinessDay("TEST-US"))
+
+	// Skips the holiday and the weekend, keeps the time of day
+	assert.Equal(t, ts(time.November, 29, 10), utils.TimeUtils(wednesday).NextBusinessDay("TEST-US").Get())
+	assert.Equal(t, ts(time.December, 3, 10), utils.TimeUtils(wednesday).AddBusinessDays("TEST-US", 3).Get())
+	assert.Equal(t, ts(time.November, 27, 10), utils.TimeUtils(ts(time.December, 3, 10)).AddBusinessDays("TEST-US", -3).Get())
+
+	// Friday is weekend in IL but not in US (and the unknown region uses the default calendar)
+	friday := ts(time.November, 29, 10)
+	assert.False(t, utils.TimeUtils(friday).IsBusinessDay("TEST-IL"))
+	assert.True(t, utils.TimeUtils(ts(time.December, 1, 10)).IsBusinessDay("TEST-IL"))
+	assert.True(t, utils.TimeUtils(friday).IsBusinessDay("unknown"))
+	_, found := utils.GetHolidayCalendar("unknown")
+	assert.False(t, found)
+
+	// Business days in the time zone: Sunday 23:00 in UTC is already Monday in Jerusalem
+	jerusalem, err := time.LoadLocation("Asia/Jerusalem")
+	require.NoError(t, err)
+	sundayNight := ts(time.December, 1, 23)
+	assert.False(t, utils.TimeUtils(sundayNight).IsBusinessDay("TEST-US"))
+	assert.True(t, utils.TimeUtils(sundayNight).In(jerusalem).IsBusinessDay("TEST-US"))
+
+	// Count business days for SLA
+	assert.Equal(t, 3, utils.TimeUtils(wednesday).BusinessDaysUntil(ts(time.December, 3, 0), "TEST-US"))
+	assert.Equal(t, -3, utils.TimeUtils(ts(time.December, 3, 0)).BusinessDaysUntil(wednesday, "TEST-US"))
+	assert.Equal(t, 0, utils.TimeUtils(wednesday).BusinessDaysUntil(wednesday, "TEST-US"))
+
+	// Calendar without business days does not hang
+	utils.RegisterHolidayCalendar("TEST-NONE", utils.NewHolidayList(time.Sunday, time.Monday, time.Tuesday,
+		time.Wednesday, time.Thursday, time.Friday, time.Saturday))
+	assert.Equal(t, wednesday, utils.TimeUtils(wednesday).NextBusinessDay("TEST-NONE").Get())
+	assert.Equal(t, wednesday, utils.TimeUtils(wednesday).AddBusinessDays("TEST-NONE", -2).Get())
+}
@@ -3,11 +3,31 @@
 package test
 
 import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/go-yaaf/yaaf-common/database"
 	"github.com/go-yaaf/yaaf-common/utils"
 )
 
@@ -67,3 +87,368 @@ func TestJwtToken(t *testing.T) {
 		fmt.Println(claims.ID)
 	}
 }
+
+// userClaims is a custom claims structure
+type userClaims struct {
+	jwt.RegisteredClaims
+	Role   string `json:"role"`
+	Tenant string `json:"tenant"`
+}
+
+func TestJwtToken_CustomClaims(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+	tu := utils.TokenUtils()
+
+	claims := &userClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		Role:             "admin",
+		Tenant:           "acme",
+	}
+	token, err := tu.CreateTokenWithClaims(claims)
+	require.NoError(t, err)
+
+	parsed := &userClaims{}
+	require.NoError(t, tu.ParseTokenWithClaims(token, parsed))
+	assert.Equal(t, "user-1", parsed.Subject)
+	assert.Equal(t, "admin", parsed.Role)
+	assert.Equal(t, "acme", parsed.Tenant)
+
+	// Expired token
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	token, err = tu.CreateTokenWithClaims(claims)
+	require.NoError(t, err)
+	assert.ErrorIs(t, tu.ParseTokenWithClaims(token, &userClaims{}), jwt.ErrTokenExpired)
+
+	// Token signed with other algorithm is rejected
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	assert.Error(t, tu.ParseTokenWithClaims(unsigned, &userClaims{}))
+}
+
+func TestJwtToken_TokenPair(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+	utils.SetTokenPolicy(utils.TokenPolicy{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour, Issuer: "auth-service"})
+	defer utils.SetTokenPolicy(utils.TokenPolicy{})
+	tu := utils.TokenUtils()
+
+	pair, err := tu.CreateTokenPair(&jwt.RegisteredClaims{Subject: "user-1"}, map[string]any{"role": "admin", "exp": 1})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), pair.AccessExpiresAt, time.Second)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), pair.RefreshExpiresAt, time.Second)
+
+	access := &userClaims{}
+	require.NoError(t, tu.ParseTokenWithClaims(pair.AccessToken, access))
+	assert.Equal(t, "user-1", access.Subject)
+	assert.Equal(t, "auth-service", access.Issuer)
+	assert.Equal(t, "admin", access.Role)
+	assert.Equal(t, pair.AccessExpiresAt.Unix(), access.ExpiresAt.Unix(), "custom claims do not override the expiration")
+
+	// Refresh tokens are not access tokens and vice versa
+	_, err = tu.ParseToken(pair.RefreshToken)
+	assert.Error(t, err)
+	_, err = tu.ParseRefreshToken(pair.AccessToken)
+	assert.Error(t, err)
+
+	refresh, err := tu.ParseRefreshToken(pair.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, pair.RefreshTokenId, refresh["jti"])
+
+	// Rotation issues a new pair with the same claims
+	next, err := tu.RefreshTokenPair(pair.RefreshToken)
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshTokenId, next.RefreshTokenId)
+
+	rotated := &userClaims{}
+	require.NoError(t, tu.ParseTokenWithClaims(next.AccessToken, rotated))
+	assert.Equal(t, "user-1", rotated.Subject)
+	assert.Equal(t, "admin", rotated.Role)
+	assert.NotEqual(t, access.ID, rotated.ID)
+
+	_, err = tu.RefreshTokenPair("invalid")
+	assert.Error(t, err)
+}
+
+func TestJwtToken_Blacklist(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+	tu := utils.TokenUtils()
+
+	dc, err := database.NewInMemoryDataCache()
+	require.NoError(t, err)
+	blacklist := utils.NewTokenBlacklist(dc)
+
+	pair, err := tu.CreateTokenPair(&jwt.RegisteredClaims{Subject: "user-1"}, nil)
+	require.NoError(t, err)
+
+	claims, err := tu.ParseToken(pair.AccessToken, utils.WithTokenBlacklist(blacklist))
+	require.NoError(t, err)
+
+	// Logout revokes the access token until it expires
+	require.NoError(t, blacklist.RevokeToken(claims))
+	revoked, err := blacklist.IsRevoked(claims.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, err = tu.ParseToken(pair.AccessToken, utils.WithTokenBlacklist(blacklist))
+	assert.ErrorIs(t, err, utils.ErrTokenRevoked)
+	assert.ErrorIs(t, tu.ParseTokenWithClaims(pair.AccessToken, &userClaims{}, utils.WithTokenBlacklist(blacklist)), utils.ErrTokenRevoked)
+
+	// The blacklist is consulted only when requested
+	_, err = tu.ParseToken(pair.AccessToken)
+	assert.NoError(t, err)
+
+	// Refresh token rotation revokes the used refresh token
+	next, err := tu.RefreshTokenPair(pair.RefreshToken, utils.WithTokenBlacklist(blacklist))
+	require.NoError(t, err)
+	_, err = tu.RefreshTokenPair(pair.RefreshToken, utils.WithTokenBlacklist(blacklist))
+	assert.ErrorIs(t, err, utils.ErrTokenRevoked)
+	_, err = tu.RefreshTokenPair(next.RefreshToken, utils.WithTokenBlacklist(blacklist))
+	assert.NoError(t, err)
+
+	// Expired revocations are ignored
+	require.NoError(t, blacklist.Revoke("expired", -time.Second))
+	revoked, err = blacklist.IsRevoked("expired")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	assert.Error(t, blacklist.Revoke("", time.Minute))
+}
+
+func TestJwtToken_AsymmetricKeys(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+	defer func() { _ = utils.SetSigningKeys() }()
+	tu := utils.TokenUtils()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	esKey, err := utils.NewECSigningKey("ec-1", ecKey)
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", esKey.Method.Alg())
+
+	claims := &jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	hsToken, err := tu.CreateToken(claims)
+	require.NoError(t, err)
+
+	// Sign with RSA key
+	require.NoError(t, utils.SetSigningKeys(utils.NewRSASigningKey("rsa-1", rsaKey)))
+	rsToken, err := tu.CreateToken(claims)
+	require.NoError(t, err)
+	parsed, err := tu.ParseToken(rsToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", parsed.Subject)
+
+	// The shared key is not accepted once signing keys are configured
+	_, err = tu.ParseToken(hsToken)
+	assert.Error(t, err)
+
+	// Key rotation: the new key signs, the previous key still verifies
+	require.NoError(t, utils.SetSigningKeys(esKey, utils.NewRSASigningKey("rsa-1", rsaKey)))
+	esToken, err := tu.CreateToken(claims)
+	require.NoError(t, err)
+	header, _, err := jwt.NewParser().ParseUnverified(esToken, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "ec-1", header.Header["kid"])
+	assert.Equal(t, "ES256", header.Method.Alg())
+
+	_, err = tu.ParseToken(esToken)
+	assert.NoError(t, err)
+	_, err = tu.ParseToken(rsToken)
+	assert.NoError(t, err)
+
+	// Token pairs are signed by the active key
+	pair, err := tu.CreateTokenPair(&jwt.RegisteredClaims{Subject: "user-1"}, nil)
+	require.NoError(t, err)
+	_, err = tu.ParseToken(pair.AccessToken)
+	assert.NoError(t, err)
+	_, err = tu.RefreshTokenPair(pair.RefreshToken)
+	assert.NoError(t, err)
+
+	// Removed keys are rejected
+	require.NoError(t, utils.SetSigningKeys(esKey))
+	_, err = tu.ParseToken(rsToken)
+	assert.Error(t, err)
+
+	// Invalid key sets
+	assert.Error(t, utils.SetSigningKeys(esKey, esKey))
+	assert.Error(t, utils.SetSigningKeys(utils.SigningKey{Id: "public", Method: jwt.SigningMethodRS256, PublicKey: &rsaKey.PublicKey}))
+
+	// PEM encoded keys
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	fromPem, err := utils.ParseSigningKeyPEM("rsa-pem", pemKey)
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", fromPem.Method.Alg())
+}
+
+func TestJwtToken_JWKS(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+	defer func() { _ = utils.SetSigningKeys() }()
+	tu := utils.TokenUtils()
+
+	// External identity provider keys
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	esKey, err := utils.NewECSigningKey("idp-ec", ecKey)
+	require.NoError(t, err)
+
+	require.NoError(t, utils.SetSigningKeys(utils.NewRSASigningKey("idp-rsa", rsaKey), esKey))
+	jwks := utils.PublicJWKS()
+	require.Len(t, jwks.Keys, 2)
+	require.NoError(t, utils.SetSigningKeys())
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+	fetcher := utils.NewJWKSFetcher(server.URL, time.Hour)
+
+	claims := jwt.RegisteredClaims{Subject: "external-user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	rsToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	rsToken.Header["kid"] = "idp-rsa"
+	rsSigned, err := rsToken.SignedString(rsaKey)
+	require.NoError(t, err)
+	esToken := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	esToken.Header["kid"] = "idp-ec"
+	esSigned, err := esToken.SignedString(ecKey)
+	require.NoError(t, err)
+
+	// Tokens of external providers are verified only with the JWKS
+	_, err = tu.ParseToken(rsSigned)
+	assert.Error(t, err)
+
+	parsed, err := tu.ParseToken(rsSigned, utils.WithJWKS(fetcher))
+	require.NoError(t, err)
+	assert.Equal(t, "external-user", parsed.Subject)
+	_, err = tu.ParseToken(esSigned, utils.WithJWKS(fetcher))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "keys are cached")
+
+	// Algorithm must match the key
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	forged.Header["kid"] = "idp-ec"
+	forgedSigned, err := forged.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = tu.ParseToken(forgedSigned, utils.WithJWKS(fetcher))
+	assert.Error(t, err)
+
+	// Unknown key ids are rejected
+	unknown := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	unknown.Header["kid"] = "unknown"
+	unknownSigned, err := unknown.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = tu.ParseToken(unknownSigned, utils.WithJWKS(fetcher))
+	assert.Error(t, err)
+}
+
+func TestJwtToken_JWKSOutage(t *testing.T) {
+	tu := utils.TokenUtils()
+
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	fetcher := utils.NewJWKSFetcher(server.URL, time.Hour)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	claims := jwt.RegisteredClaims{Subject: "external-user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-rsa"
+	signed, err := token.SignedString(rsaKey)
+	require.NoError(t, err)
+
+	// Concurrent validations share a single fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, er := tu.ParseToken(signed, utils.WithJWKS(fetcher))
+			assert.Error(t, er)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// Failed fetches are not retried before the minimum refresh interval
+	_, err = tu.ParseToken(signed, utils.WithJWKS(fetcher))
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// The caller context cancels the fetch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	other := utils.NewJWKSFetcher(server.URL, time.Hour)
+	_, err = tu.ParseToken(signed, utils.WithJWKS(other), utils.WithTokenContext(ctx))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestApiKey_Encryption(t *testing.T) {
+	require.NoError(t, utils.SetSecret([]byte(tokenApiSecret), []byte(tokenApiVector)))
+	tu := utils.TokenUtils()
+
+	apiKey, err := tu.CreateApiKey("billing-service")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(apiKey, "v2."))
+	assert.False(t, tu.IsLegacyApiKey(apiKey))
+
+	appName, err := tu.ParseApiKey(apiKey)
+	require.NoError(t, err)
+	assert.Equal(t, "billing-service", appName)
+
+	// Tampered keys are rejected
+	raw, err := hex.DecodeString(strings.TrimPrefix(apiKey, "v2."))
+	require.NoError(t, err)
+	raw[len(raw)-1] ^= 0x01
+	_, err = tu.ParseApiKey("v2." + hex.EncodeToString(raw))
+	assert.ErrorIs(t, err, utils.ErrInvalidApiKey)
+	_, err = tu.ParseApiKey("v2.00")
+	assert.ErrorIs(t, err, utils.ErrInvalidApiKey)
+
+	// Legacy AES-CFB keys are accepted during migration
+	block, err := aes.NewCipher([]byte(tokenApiSecret))
+	require.NoError(t, err)
+	legacy := make([]byte, aes.BlockSize+len("legacy-service"))
+	_, err = rand.Read(legacy[:aes.BlockSize])
+	require.NoError(t, err)
+	cipher.NewCFBEncrypter(block, legacy[:aes.BlockSize]).XORKeyStream(legacy[aes.BlockSize:], []byte("legacy-service"))
+	legacyKey := hex.EncodeToString(legacy)
+
+	assert.True(t, tu.IsLegacyApiKey(legacyKey))
+	appName, err = tu.ParseApiKey(legacyKey)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-service", appName)
+
+	// Legacy keys decrypted to garbage are rejected
+	garbage := make([]byte, aes.BlockSize+4)
+	cipher.NewCFBEncrypter(block, garbage[:aes.BlockSize]).XORKeyStream(garbage[aes.BlockSize:], []byte{0, 1, 2, 3})
+	_, err = tu.ParseApiKey(hex.EncodeToString(garbage))
+	assert.ErrorIs(t, err, utils.ErrInvalidApiKey)
+	_, err = tu.ParseApiKey("not-hex")
+	assert.ErrorIs(t, err, utils.ErrInvalidApiKey)
+
+	// Legacy keys are rejected once the migration is over
+	utils.SetLegacyApiKeys(false)
+	defer utils.SetLegacyApiKeys(true)
+	_, err = tu.ParseApiKey(legacyKey)
+	assert.ErrorIs(t, err, utils.ErrInvalidApiKey)
+
+	migrated, err := tu.MigrateApiKey(legacyKey)
+	require.NoError(t, err)
+	assert.False(t, tu.IsLegacyApiKey(migrated))
+	appName, err = tu.ParseApiKey(migrated)
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-service", appName)
+
+	same, err := tu.MigrateApiKey(migrated)
+	require.NoError(t, err)
+	assert.Equal(t, migrated, same)
+}
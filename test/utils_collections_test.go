@@ -3,10 +3,14 @@
 package test
 
 import (
+	"context"
 	"github.com/go-yaaf/yaaf-common/utils/collections"
 	"github.com/stretchr/testify/assert"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var str_array = []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
@@ -62,3 +66,268 @@ func TestCollections_AnyN(t *testing.T) {
 		return n < 4
 	}))
 }
+
+func TestCollections_Generics(t *testing.T) {
+	groups := collections.GroupBy(num_array, func(v int) string {
+		if v%2 == 0 {
+			return "even"
+		}
+		return "odd"
+	})
+	assert.Equal(t, []int{0, 2, 4, 6, 8, 10}, groups["even"])
+	assert.Equal(t, []int{1, 3, 5, 7, 9}, groups["odd"])
+
+	chunks := collections.Chunk(num_array, 4)
+	assert.Equal(t, [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}, {8, 9, 10}}, chunks)
+	assert.Equal(t, 0, len(collections.Chunk([]int{}, 3)))
+	assert.Panics(t, func() { collections.Chunk(num_array, 0) })
+
+	// Appending to a chunk does not override the next chunk
+	chunks[0] = append(chunks[0], 100)
+	assert.Equal(t, 4, chunks[1][0])
+
+	sum := collections.Reduce(num_array, 0, func(acc, v int) int { return acc + v })
+	assert.Equal(t, 55, sum)
+	joined := collections.Reduce(str_array[:3], "", func(acc string, v string) string { return acc + v })
+	assert.Equal(t, "012", joined)
+
+	words := collections.FlatMap([]string{"a b", "c"}, func(s string) []string { return strings.Split(s, " ") })
+	assert.Equal(t, []string{"a", "b", "c"}, words)
+	assert.Equal(t, []int{4, 5, 6, 7, 8, 9, 10}, collections.Flatten(chunks[1:]))
+
+	assert.Equal(t, []string{"2", "1", "0"}, collections.Reverse(str_array[:3]))
+	assert.Equal(t, "0", str_array[0])
+
+	type item struct {
+		name  string
+		score int
+	}
+	items := []item{{"a", 3}, {"b", 1}, {"c", 5}, {"d", 1}, {"e", 5}}
+	minItem, ok := collections.MinBy(items, func(i item) int { return i.score })
+	assert.True(t, ok)
+	assert.Equal(t, "b", minItem.name)
+	maxItem, ok := collections.MaxBy(items, func(i item) int { return i.score })
+	assert.True(t, ok)
+	assert.Equal(t, "c", maxItem.name)
+	_, ok = collections.MaxBy([]item{}, func(i item) int { return i.score })
+	assert.False(t, ok)
+}
+
+func TestCollections_Set(t *testing.T) {
+	a := collections.NewSet(1, 2, 3, 3)
+	b := collections.NewSet(3, 4)
+	assert.Equal(t, 3, a.Len())
+	assert.True(t, a.Contains(2))
+	assert.False(t, a.Contains(4))
+
+	sorted := func(s *collections.Set[int]) []int {
+		items := s.Items()
+		sort.Ints(items)
+		return items
+	}
+	assert.Equal(t, []int{1, 2, 3, 4}, sorted(a.Union(b)))
+	assert.Equal(t, []int{3}, sorted(a.Intersect(b)))
+	assert.Equal(t, []int{1, 2}, sorted(a.Diff(b)))
+	assert.Equal(t, []int{1, 2, 3}, sorted(a.Union(a)))
+
+	a.Remove(1, 2)
+	a.Add(5)
+	assert.Equal(t, []int{3, 5}, sorted(a))
+	a.Clear()
+	assert.Equal(t, 0, a.Len())
+
+	// Concurrent set
+	s := collections.NewConcurrentSet[string]()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, v := range str_array {
+				s.Add(v)
+				s.Contains(v)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, len(str_array), s.Len())
+	assert.Equal(t, len(str_array), s.Union(s).Len())
+}
+
+func TestCollections_OrderedMap(t *testing.T) {
+	m := collections.NewOrderedMap[string, int]()
+	m.Set("c", 3)
+	m.Set("a", 1)
+	m.Set("b", 2)
+	m.Set("a", 10)
+	assert.Equal(t, []string{"c", "a", "b"}, m.Keys())
+	assert.Equal(t, []int{3, 10, 2}, m.Values())
+
+	v, ok := m.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+	assert.True(t, m.Delete("c"))
+	assert.False(t, m.Delete("c"))
+	assert.False(t, m.Has("c"))
+	m.Set("c", 30)
+	assert.Equal(t, []string{"a", "b", "c"}, m.Keys())
+
+	visited := make([]string, 0)
+	m.Range(func(key string, value int) bool {
+		visited = append(visited, key)
+		return key != "b"
+	})
+	assert.Equal(t, []string{"a", "b"}, visited)
+
+	m.Clear()
+	assert.Equal(t, 0, m.Len())
+
+	// Concurrent ordered map
+	cm := collections.NewConcurrentOrderedMap[int, int]()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cm.Set(i*100+j, j)
+				cm.Get(j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1000, cm.Len())
+}
+
+func TestCollections_BlockingQueue(t *testing.T) {
+	q := collections.NewBlockingQueue[int](2)
+	assert.True(t, q.Push(1))
+	assert.True(t, q.Push(2))
+	assert.False(t, q.Push(3))
+
+	// Push waits for free capacity
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.PushWait(ctx, 3), context.DeadlineExceeded)
+
+	done := make(chan error)
+	go func() { done <- q.PushWait(context.Background(), 3) }()
+	v, ok := q.Pop()
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 2, q.Length())
+
+	// Pop waits for items
+	for _, expected := range []int{2, 3} {
+		v, err := q.PopWait(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, expected, v)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := q.PopWait(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push(4)
+	}()
+	v, err = q.PopWait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, v)
+
+	// Closed queue releases the waiting consumers after the remaining items
+	q.Push(5)
+	q.Close()
+	assert.False(t, q.Push(6))
+	v, err = q.PopWait(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, v)
+	_, err = q.PopWait(context.Background())
+	assert.ErrorIs(t, err, collections.ErrQueueClosed)
+
+	// Concurrent producers and consumers
+	q = collections.NewBlockingQueue[int](10)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.NoError(t, q.PushWait(context.Background(), j))
+			}
+		}()
+	}
+	received := make(chan int, 400)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for {
+				if _, e := q.PopWait(context.Background()); e != nil {
+					return
+				}
+				received <- 1
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 400; i++ {
+		<-received
+	}
+	q.Close()
+}
+
+func TestCollections_RingBuffer(t *testing.T) {
+	r := collections.NewRingBuffer[int](3)
+	_, ok := r.Newest()
+	assert.False(t, ok)
+
+	assert.False(t, r.Add(1, 2))
+	assert.Equal(t, []int{1, 2}, r.Items())
+	assert.True(t, r.Add(3, 4, 5))
+	assert.Equal(t, []int{3, 4, 5}, r.Items())
+	assert.True(t, r.IsFull())
+
+	oldest, _ := r.Oldest()
+	newest, _ := r.Newest()
+	assert.Equal(t, 3, oldest)
+	assert.Equal(t, 5, newest)
+
+	r.Clear()
+	assert.Equal(t, 0, r.Length())
+	assert.Equal(t, 3, r.Capacity())
+	assert.Panics(t, func() { collections.NewRingBuffer[int](0) })
+}
+
+func TestCollections_PriorityQueue(t *testing.T) {
+	type task struct {
+		name     string
+		priority int
+	}
+	q := collections.NewPriorityQueue(func(a, b task) bool { return a.priority > b.priority })
+	q.Push(task{"low-1", 1}, task{"high-1", 5}, task{"mid", 3}, task{"high-2", 5}, task{"low-2", 1})
+
+	first, ok := q.Peek()
+	assert.True(t, ok)
+	assert.Equal(t, "high-1", first.name)
+	assert.Equal(t, 5, q.Length())
+
+	// Equal priorities are popped by push order
+	names := make([]string, 0)
+	for q.Length() > 0 {
+		v, _ := q.Pop()
+		names = append(names, v.name)
+	}
+	assert.Equal(t, []string{"high-1", "high-2", "mid", "low-1", "low-2"}, names)
+
+	_, ok = q.PopTimeout(10 * time.Millisecond)
+	assert.False(t, ok)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		q.Push(task{"late", 2})
+	}()
+	v, ok := q.PopTimeout(time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, "late", v.name)
+}
@@ -0,0 +1,59 @@
+// Test supervised background workers
+package test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/utils/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkersManager(t *testing.T) {
+	manager := workers.NewManager()
+
+	// Long-running worker with 3 instances
+	var running atomic.Int32
+	require.NoError(t, manager.Register("poller", func(ctx context.Context) error {
+		running.Add(1)
+		defer running.Add(-1)
+		<-ctx.Done()
+		return nil
+	}, workers.WorkerOptions{Concurrency: 3}))
+
+	// Failing worker restarted twice
+	var attempts atomic.Int32
+	require.NoError(t, manager.Register("flaky", func(ctx context.Context) error {
+		if attempts.Add(1) == 2 {
+			panic("boom")
+		}
+		return fmt.Errorf("attempt %d failed", attempts.Load())
+	}, workers.WorkerOptions{Restart: workers.RestartOnFailure, MaxRestarts: 2, Backoff: time.Millisecond}))
+
+	require.Error(t, manager.Register("poller", func(ctx context.Context) error { return nil }, workers.WorkerOptions{}))
+	require.NoError(t, manager.Start(context.Background()))
+
+	// The worker is degraded (unhealthy) while waiting for restart, wait for the final failure
+	require.Eventually(t, func() bool {
+		return running.Load() == 3 && manager.Health()[0].State == workers.WorkerStateFailed
+	}, time.Second, time.Millisecond*5)
+	assert.False(t, manager.Healthy())
+	assert.Equal(t, int32(3), attempts.Load())
+
+	health := manager.Health()
+	require.Equal(t, 2, len(health))
+	assert.Equal(t, "flaky", health[0].Name)
+	assert.Equal(t, workers.WorkerStateFailed, health[0].State)
+	assert.Equal(t, 2, health[0].Restarts)
+	assert.Equal(t, "attempt 3 failed", health[0].LastError)
+	assert.Equal(t, workers.WorkerStateRunning, health[1].State)
+	assert.Equal(t, 3, health[1].Running)
+
+	require.NoError(t, manager.Stop(time.Second))
+	assert.Equal(t, int32(0), running.Load())
+	assert.Equal(t, workers.WorkerStateStopped, manager.Health()[1].State)
+}
@@ -5,6 +5,7 @@
 package aggregator
 
 import (
+	"context"
 	"sync"
 	"time"
 )
@@ -15,26 +16,85 @@ type bulkCallback[T any] func(bulk []T)
 // timeoutCallback is called when bulk did not reach the bulk size but a timeout aws triggered
 type timeoutCallback[T any] func(bulk []T)
 
+// bulkHandler processes a bulk and returns an error if the bulk should be retried, when set it replaces the callbacks
+type bulkHandler[T any] func(ctx context.Context, bulk []T) error
+
+// errorCallback is called when the bulk handler failed after all the retries
+type errorCallback[T any] func(bulk []T, err error)
+
+// sizeOfFunc returns the size in bytes of an item
+type sizeOfFunc[T any] func(item T) int
+
 // Aggregator is a synchronized map of items that can auto-expire once stale
 type Aggregator[T any] struct {
-	mutex           sync.Mutex    // Mutex for sync operations
-	timeout         time.Duration // Timeout no notify when bulk was not yet created
-	bulkSize        int           // Bulk size
-	items           []T
+	mutex          sync.Mutex    // Mutex for sync operations
+	timeout        time.Duration // Timeout no notify when bulk was not yet created
+	bulkSize       int           // Bulk size
+	maxBytes       int           // Max bulk size in bytes (0 for no limit)
+	sizeOf         sizeOfFunc[T] // Item size in bytes
+	bytes          int           // Current bulk size in bytes
+	items          []T
+	handlers       aggregatorHandlers[T] // Callbacks and retry policy (guarded by the mutex)
+	ctx            context.Context
+	shutdownSignal chan (chan struct{})
+	done           chan struct{} // Closed when the timeout process exits
+	isShutDown     bool
+}
+
+// aggregatorHandlers are the bulk callbacks and retry policy, copied under the lock since they may be set while the
+// timeout process is running
+type aggregatorHandlers[T any] struct {
 	bulkCallback    bulkCallback[T]
 	timeoutCallback timeoutCallback[T]
-	shutdownSignal  chan (chan struct{})
-	isShutDown      bool
+	bulkHandler     bulkHandler[T]
+	errorCallback   errorCallback[T]
+	retries         int           // Number of retries of a failed bulk
+	retryBackoff    time.Duration // Backoff between retries (multiplied by the attempt number)
 }
 
 // SetBulkCallback sets the callback on bulk creation
 func (agg *Aggregator[T]) SetBulkCallback(callback bulkCallback[T]) {
-	agg.bulkCallback = callback
+	agg.mutex.Lock()
+	agg.handlers.bulkCallback = callback
+	agg.mutex.Unlock()
 }
 
 // SetTimeoutCallback sets the callback on timeout
 func (agg *Aggregator[T]) SetTimeoutCallback(callback timeoutCallback[T]) {
-	agg.timeoutCallback = callback
+	agg.mutex.Lock()
+	agg.handlers.timeoutCallback = callback
+	agg.mutex.Unlock()
+}
+
+// SetBulkHandler sets the handler of all bulks (on bulk size, on timeout and on flush), a failed bulk is retried
+// according to SetRetry and then passed to the error callback. When set, the bulk and timeout callbacks are not called
+func (agg *Aggregator[T]) SetBulkHandler(handler bulkHandler[T]) {
+	agg.mutex.Lock()
+	agg.handlers.bulkHandler = handler
+	agg.mutex.Unlock()
+}
+
+// SetErrorCallback sets the callback of a bulk which failed after all the retries
+func (agg *Aggregator[T]) SetErrorCallback(callback errorCallback[T]) {
+	agg.mutex.Lock()
+	agg.handlers.errorCallback = callback
+	agg.mutex.Unlock()
+}
+
+// SetRetry sets the number of retries of a failed bulk and the backoff between retries (multiplied by the attempt number)
+func (agg *Aggregator[T]) SetRetry(retries int, backoff time.Duration) {
+	agg.mutex.Lock()
+	agg.handlers.retries = retries
+	agg.handlers.retryBackoff = backoff
+	agg.mutex.Unlock()
+}
+
+// SetMaxBytes triggers the bulk when the total size of the items reached or exceeded maxBytes (in addition to the bulk size)
+func (agg *Aggregator[T]) SetMaxBytes(maxBytes int, sizeOf sizeOfFunc[T]) {
+	agg.mutex.Lock()
+	agg.maxBytes = maxBytes
+	agg.sizeOf = sizeOf
+	agg.mutex.Unlock()
 }
 
 // Add item to the aggregator
@@ -42,23 +102,23 @@ func (agg *Aggregator[T]) Add(item T) {
 
 	agg.mutex.Lock()
 	agg.items = append(agg.items, item)
+	if agg.sizeOf != nil {
+		agg.bytes += agg.sizeOf(item)
+	}
 
-	// return if number of items is less than bulk size
-	if len(agg.items) < agg.bulkSize {
+	// return if number of items is less than bulk size and the bytes are less than the max bytes
+	if len(agg.items) < agg.bulkSize && (agg.maxBytes <= 0 || agg.bytes < agg.maxBytes) {
 		agg.mutex.Unlock()
 		return
 	}
 
 	// Move items to bulk and invoke callback
-	bulk := make([]T, 0)
-	bulk = append(bulk, agg.items...)
-	agg.items = make([]T, 0)
+	bulk := agg.takeItems()
+	handlers := agg.handlers
 	agg.mutex.Unlock()
 
 	// Invoke callback
-	if agg.bulkCallback != nil {
-		agg.bulkCallback(bulk)
-	}
+	agg.deliver(agg.ctx, bulk, handlers, handlers.bulkCallback)
 }
 
 // Count returns the number of items in the aggregator
@@ -69,32 +129,107 @@ func (agg *Aggregator[T]) Count() int {
 	return length
 }
 
-// Close calls Purge, and then stops the goroutine that does ttl checking, for a clean shutdown.
-// The cache is no longer cleaning up after the first call to Close, repeated calls are safe though.
+// Bytes returns the total size in bytes of the items in the aggregator (when SetMaxBytes is used)
+func (agg *Aggregator[T]) Bytes() int {
+	agg.mutex.Lock()
+	defer agg.mutex.Unlock()
+	return agg.bytes
+}
+
+// Flush synchronously delivers the pending items to the bulk handler, or to the timeout callback (bulk callback if the
+// timeout callback is not set). The items are kept if no handler or callback is set
+func (agg *Aggregator[T]) Flush() {
+	agg.flush(agg.ctx)
+}
+
+// Close stops the goroutine that does timeout checking and flushes the pending items, for a clean shutdown.
+// Repeated calls are safe, items added after Close are delivered only by bulk size or explicit Flush.
 func (agg *Aggregator[T]) Close() {
 	agg.mutex.Lock()
 	if !agg.isShutDown {
 		agg.isShutDown = true
 		agg.mutex.Unlock()
 		feedback := make(chan struct{})
-		agg.shutdownSignal <- feedback
-		<-feedback
-		close(agg.shutdownSignal)
+		select {
+		case agg.shutdownSignal <- feedback:
+			<-feedback
+		case <-agg.done:
+		}
 	} else {
 		agg.mutex.Unlock()
 	}
-	agg.Purge()
+	agg.flush(context.WithoutCancel(agg.ctx))
 }
 
 // Purge will remove all entries
 func (agg *Aggregator[T]) Purge() {
 	agg.mutex.Lock()
 	agg.items = make([]T, 0)
+	agg.bytes = 0
 	agg.mutex.Unlock()
 }
 
+// flush the pending items
+func (agg *Aggregator[T]) flush(ctx context.Context) {
+	agg.mutex.Lock()
+	handlers := agg.handlers
+	callback := func(bulk []T) {}
+	if handlers.timeoutCallback != nil {
+		callback = handlers.timeoutCallback
+	} else if handlers.bulkCallback != nil {
+		callback = handlers.bulkCallback
+	} else if handlers.bulkHandler == nil {
+		agg.mutex.Unlock()
+		return
+	}
+
+	if len(agg.items) == 0 {
+		agg.mutex.Unlock()
+		return
+	}
+	bulk := agg.takeItems()
+	agg.mutex.Unlock()
+
+	agg.deliver(ctx, bulk, handlers, callback)
+}
+
+// takeItems moves the items to a new bulk (under lock)
+func (agg *Aggregator[T]) takeItems() []T {
+	bulk := make([]T, 0, len(agg.items))
+	bulk = append(bulk, agg.items...)
+	agg.items = make([]T, 0)
+	agg.bytes = 0
+	return bulk
+}
+
+// deliver the bulk to the bulk handler (with retries) or to the callback
+func (agg *Aggregator[T]) deliver(ctx context.Context, bulk []T, handlers aggregatorHandlers[T], callback func(bulk []T)) {
+	if handlers.bulkHandler == nil {
+		if callback != nil {
+			callback(bulk)
+		}
+		return
+	}
+
+	err := handlers.bulkHandler(ctx, bulk)
+retry:
+	for attempt := 1; err != nil && attempt <= handlers.retries; attempt++ {
+		select {
+		case <-time.After(handlers.retryBackoff * time.Duration(attempt)):
+		case <-ctx.Done():
+			// Stop retrying, the bulk is passed to the error callback
+			break retry
+		}
+		err = handlers.bulkHandler(ctx, bulk)
+	}
+	if err != nil && handlers.errorCallback != nil {
+		handlers.errorCallback(bulk, err)
+	}
+}
+
 // start the timeout thread
 func (agg *Aggregator[T]) startBulkTimeoutProcess() {
+	defer close(agg.done)
 	timer := time.NewTimer(agg.timeout)
 	for {
 		timer.Reset(agg.timeout)
@@ -103,33 +238,45 @@ func (agg *Aggregator[T]) startBulkTimeoutProcess() {
 			timer.Stop()
 			shutdownFeedback <- struct{}{}
 			return
+		case <-agg.ctx.Done():
+			// Context canceled: shut down and drain the pending items
+			timer.Stop()
+			agg.mutex.Lock()
+			agg.isShutDown = true
+			agg.mutex.Unlock()
+			agg.flush(context.WithoutCancel(agg.ctx))
+			return
 		case <-timer.C:
 			timer.Stop()
 			agg.mutex.Lock()
-			if len(agg.items) == 0 {
+			handlers := agg.handlers
+			if (handlers.timeoutCallback == nil && handlers.bulkHandler == nil) || len(agg.items) == 0 {
 				agg.mutex.Unlock()
 				continue
-			} else {
-				bulk := make([]T, 0)
-				bulk = append(bulk, agg.items...)
-				if agg.timeoutCallback != nil {
-					agg.items = make([]T, 0)
-					agg.timeoutCallback(bulk)
-				}
 			}
+			bulk := agg.takeItems()
 			agg.mutex.Unlock()
+			agg.deliver(agg.ctx, bulk, handlers, handlers.timeoutCallback)
 		}
 	}
 }
 
 // NewAggregator is a helper to create instance of the aggregator
 func NewAggregator[T any](bulkSize int, timeout time.Duration) *Aggregator[T] {
+	return NewAggregatorWithContext[T](context.Background(), bulkSize, timeout)
+}
+
+// NewAggregatorWithContext creates instance of the aggregator which is closed (and the pending items are flushed) when
+// the context is canceled, the context is passed to the bulk handler
+func NewAggregatorWithContext[T any](ctx context.Context, bulkSize int, timeout time.Duration) *Aggregator[T] {
 	shutdownChan := make(chan chan struct{})
 	agg := &Aggregator[T]{
 		items:          make([]T, 0),
 		timeout:        timeout,
 		bulkSize:       bulkSize,
+		ctx:            ctx,
 		shutdownSignal: shutdownChan,
+		done:           make(chan struct{}),
 		isShutDown:     false,
 	}
 	go agg.startBulkTimeoutProcess()
@@ -0,0 +1,295 @@
+package binary
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-yaaf/yaaf-common/entity"
+	"sort"
+)
+
+// Marshaler is implemented by types encoding themselves to the writer (see Writer.Struct)
+type Marshaler interface {
+	MarshalTo(w *Writer)
+}
+
+// Unmarshaler is implemented by types decoding themselves from the reader (see Reader.Struct)
+type Unmarshaler interface {
+	UnmarshalFrom(r *Reader) error
+}
+
+// Value type headers of the JsonObject encoding
+const (
+	jsonNil    uint8 = 0
+	jsonFalse  uint8 = 1
+	jsonTrue   uint8 = 2
+	jsonInt    uint8 = 3
+	jsonUint   uint8 = 4
+	jsonFloat  uint8 = 5
+	jsonString uint8 = 6
+	jsonArray  uint8 = 7
+	jsonObject uint8 = 8
+	jsonRaw    uint8 = 9
+)
+
+// Marshal will encode the value to a wire-format byte array
+func Marshal(v Marshaler) []byte {
+	w := Acquire()
+	defer Release(w)
+	v.MarshalTo(w)
+	return w.GetBytes()
+}
+
+// Unmarshal will decode the wire-format byte array to the value
+func Unmarshal(data []byte, v Unmarshaler) error {
+	return v.UnmarshalFrom(NewReader(data))
+}
+
+// Map will encode a map of strings, the entries are written by the order of the keys
+func (w *Writer) Map(v map[string]string) *Writer {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w.varInt(uint64(len(keys)))
+	for _, k := range keys {
+		w.String(k).String(v[k])
+	}
+	return w
+}
+
+// JsonObject will encode an arbitrary JSON object, each value is written with a type header (see jsonNil .. jsonRaw)
+// Values of types other than nil, bool, numbers, string, arrays and maps are written as raw JSON
+func (w *Writer) JsonObject(v entity.Json) *Writer {
+	return w.jsonMap(v)
+}
+
+// Struct will encode a nested struct as variable length byte array (compatible with Object)
+func (w *Writer) Struct(v Marshaler) *Writer {
+	nested := Acquire()
+	defer Release(nested)
+	v.MarshalTo(nested)
+	w.varInt(uint64(nested.Len()))
+	w.buf = append(w.buf, nested.buf...)
+	return w
+}
+
+// WriteStructArray will encode variable length array of nested structs
+func WriteStructArray[T Marshaler](w *Writer, v []T) *Writer {
+	w.varInt(uint64(len(v)))
+	for _, item := range v {
+		w.Struct(item)
+	}
+	return w
+}
+
+// encode map of JSON values
+func (w *Writer) jsonMap(v map[string]any) *Writer {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w.varInt(uint64(len(keys)))
+	for _, k := range keys {
+		w.String(k)
+		w.jsonValue(v[k])
+	}
+	return w
+}
+
+// encode JSON value with type header
+func (w *Writer) jsonValue(v any) {
+	switch val := v.(type) {
+	case nil:
+		w.Uint8(jsonNil)
+	case bool:
+		if val {
+			w.Uint8(jsonTrue)
+		} else {
+			w.Uint8(jsonFalse)
+		}
+	case int:
+		w.Uint8(jsonInt).Int64(int64(val))
+	case int8:
+		w.Uint8(jsonInt).Int64(int64(val))
+	case int16:
+		w.Uint8(jsonInt).Int64(int64(val))
+	case int32:
+		w.Uint8(jsonInt).Int64(int64(val))
+	case int64:
+		w.Uint8(jsonInt).Int64(val)
+	case uint:
+		w.Uint8(jsonUint).Uint64(uint64(val))
+	case uint8:
+		w.Uint8(jsonUint).Uint64(uint64(val))
+	case uint16:
+		w.Uint8(jsonUint).Uint64(uint64(val))
+	case uint32:
+		w.Uint8(jsonUint).Uint64(uint64(val))
+	case uint64:
+		w.Uint8(jsonUint).Uint64(val)
+	case float32:
+		w.Uint8(jsonFloat).Float64(float64(val))
+	case float64:
+		w.Uint8(jsonFloat).Float64(val)
+	case string:
+		w.Uint8(jsonString).String(val)
+	case []any:
+		w.Uint8(jsonArray)
+		w.varInt(uint64(len(val)))
+		for _, item := range val {
+			w.jsonValue(item)
+		}
+	case map[string]any:
+		w.Uint8(jsonObject).jsonMap(val)
+	case entity.Json:
+		w.Uint8(jsonObject).jsonMap(val)
+	default:
+		if data, err := json.Marshal(val); err != nil {
+			w.Uint8(jsonNil)
+		} else {
+			w.Uint8(jsonRaw).Object(&data)
+		}
+	}
+}
+
+// Map read map of strings
+func (r *Reader) Map() (map[string]string, error) {
+	size, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, size)
+	for i := 0; i < size; i++ {
+		key, e := r.String()
+		if e != nil {
+			return nil, e
+		}
+		if result[key], e = r.String(); e != nil {
+			return nil, e
+		}
+	}
+	return result, nil
+}
+
+// JsonObject read arbitrary JSON object, raw JSON values are decoded to the generic JSON types (e.g. float64)
+func (r *Reader) JsonObject() (entity.Json, error) {
+	return r.jsonMap()
+}
+
+// Struct read nested struct encoded by Writer.Struct (or Writer.Object)
+func (r *Reader) Struct(v Unmarshaler) error {
+	data, err := r.Object()
+	if err != nil {
+		return err
+	}
+	return v.UnmarshalFrom(NewReader(data))
+}
+
+// ReadStructArray read variable length array of nested structs encoded by WriteStructArray
+func ReadStructArray[T any, PT interface {
+	*T
+	Unmarshaler
+}](r *Reader) ([]T, error) {
+	size, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]T, size)
+	for i := range result {
+		if e := r.Struct(PT(&result[i])); e != nil {
+			return nil, e
+		}
+	}
+	return result, nil
+}
+
+// read collection length, every item takes at least one byte so the length can't exceed the remaining bytes
+func (r *Reader) length() (int, error) {
+	size, err := r.Int()
+	if err != nil {
+		return 0, err
+	}
+	if size < 0 || size > r.reader.Len() {
+		return 0, fmt.Errorf("invalid length: %d", size)
+	}
+	return size, nil
+}
+
+// read map of JSON values
+func (r *Reader) jsonMap() (map[string]any, error) {
+	size, err := r.length()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]any, size)
+	for i := 0; i < size; i++ {
+		key, e := r.String()
+		if e != nil {
+			return nil, e
+		}
+		if result[key], e = r.jsonValue(); e != nil {
+			return nil, fmt.Errorf("error decoding field %s: %v", key, e)
+		}
+	}
+	return result, nil
+}
+
+// read JSON value with type header
+func (r *Reader) jsonValue() (any, error) {
+	hdr, err := r.Uint8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch hdr {
+	case jsonNil:
+		return nil, nil
+	case jsonFalse:
+		return false, nil
+	case jsonTrue:
+		return true, nil
+	case jsonInt:
+		return r.Int64()
+	case jsonUint:
+		return r.Uint64()
+	case jsonFloat:
+		return r.Float64()
+	case jsonString:
+		return r.String()
+	case jsonArray:
+		size, er := r.length()
+		if er != nil {
+			return nil, er
+		}
+		list := make([]any, 0, size)
+		for i := 0; i < size; i++ {
+			item, fe := r.jsonValue()
+			if fe != nil {
+				return nil, fe
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case jsonObject:
+		return r.jsonMap()
+	case jsonRaw:
+		data, er := r.Object()
+		if er != nil {
+			return nil, er
+		}
+		var value any
+		if er = json.Unmarshal(data, &value); er != nil {
+			return nil, er
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("invalid json value header: %d", hdr)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"math/big"
 	"net"
-	"strings"
 )
 
 var ErrInvalidIPAddress = errors.New("invalid ip address")
@@ -99,16 +98,3 @@ func BigIntToIPv6(ipaddr big.Int) net.IP {
 
 	return ip
 }
-
-// parseIP implements extension of net.ParseIP. It returns additional
-// information about IP address bytes length. In general, it works typically
-// as standard net.ParseIP. So if IP is not valid, nil is returned.
-func parseIP(s string) (net.IP, int, error) {
-	pip := net.ParseIP(s)
-	if pip == nil {
-		return nil, 0, ErrInvalidIPAddress
-	} else if strings.Contains(s, ".") {
-		return pip, 4, nil
-	}
-	return pip, 16, nil
-}
@@ -31,6 +31,11 @@ type Reader struct {
 	reader *bytes.Reader
 }
 
+// Reset will reset the reader to read from the data (reuse the reader for multiple payloads)
+func (r *Reader) Reset(data []byte) {
+	r.reader.Reset(data)
+}
+
 // Uint read unsigned int value
 func (r *Reader) Uint() (uint, error) {
 	if u64, err := r.Uint64(); err != nil {
@@ -254,6 +259,10 @@ func (r *Reader) Object() (result []byte, err error) {
 		err = fmt.Errorf("error decoding bytes length: %v", err)
 		return
 	}
+	if bsLength < 0 || bsLength > r.reader.Len() {
+		err = fmt.Errorf("invalid bytes length: %d", bsLength)
+		return
+	}
 
 	expandSlice(&result, bsLength)
 
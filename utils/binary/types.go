@@ -0,0 +1,91 @@
+package binary
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Duration will encode a duration (int64 nanoseconds)
+func (w *Writer) Duration(v time.Duration) *Writer {
+	return w.Int64(int64(v))
+}
+
+// Time will encode a time with nanosecond precision: unix seconds, nanoseconds and the zone offset in seconds
+// The zone name is not stored, the time is decoded in UTC or in a fixed zone of the offset
+func (w *Writer) Time(v time.Time) *Writer {
+	_, offset := v.Zone()
+	return w.Int64(v.Unix()).Uint32(uint32(v.Nanosecond())).Int32(int32(offset))
+}
+
+// UUID will encode a UUID (e.g. uuid.UUID) as 16 bytes without length prefix
+func (w *Writer) UUID(v [16]byte) *Writer {
+	w.buf = append(w.buf, v[:]...)
+	return w
+}
+
+// WriteNullable will encode a nullable value: a presence flag (like Bool) followed by the value if not nil
+//
+//	binary.WriteNullable(w, s.Count, w.Int64)
+func WriteNullable[T any](w *Writer, v *T, write func(T) *Writer) *Writer {
+	if v == nil {
+		return w.Bool(false)
+	}
+	w.Bool(true)
+	return write(*v)
+}
+
+// Duration read duration value
+func (r *Reader) Duration() (time.Duration, error) {
+	if i64, err := r.Int64(); err != nil {
+		return 0, err
+	} else {
+		return time.Duration(i64), nil
+	}
+}
+
+// Time read time value, the time is returned in UTC or in a fixed zone of the encoded offset
+func (r *Reader) Time() (time.Time, error) {
+	sec, err := r.Int64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	nsec, err := r.Uint32()
+	if err != nil {
+		return time.Time{}, err
+	}
+	offset, err := r.Int32()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if nsec >= uint32(time.Second) {
+		return time.Time{}, fmt.Errorf("invalid nanoseconds: %d", nsec)
+	}
+
+	t := time.Unix(sec, int64(nsec))
+	if offset == 0 {
+		return t.UTC(), nil
+	}
+	return t.In(time.FixedZone("", int(offset))), nil
+}
+
+// UUID read 16 bytes UUID value
+func (r *Reader) UUID() (v [16]byte, err error) {
+	_, err = io.ReadFull(r.reader, v[:])
+	return
+}
+
+// ReadNullable read nullable value encoded by WriteNullable, returns nil if the value is null
+//
+//	s.Count, e = binary.ReadNullable(r, r.Int64)
+func ReadNullable[T any](r *Reader, read func() (T, error)) (*T, error) {
+	present, err := r.Bool()
+	if err != nil || !present {
+		return nil, err
+	}
+	v, err := read()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
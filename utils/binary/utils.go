@@ -6,10 +6,6 @@ import (
 
 // const notEnoughBytesLayout = "not enough bytes available to decode <%T>, needed %d and has an available %d"
 
-func getStringBytes(str *string) *[]byte {
-	return (*[]byte)(unsafe.Pointer(str))
-}
-
 func getStringFromBytes(bs []byte) string {
 	return *((*string)(unsafe.Pointer(&bs)))
 }
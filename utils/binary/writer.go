@@ -1,27 +1,55 @@
 package binary
 
 import (
-	"bytes"
+	"encoding/binary"
 	"github.com/go-yaaf/yaaf-common/entity"
 	"io"
 	"math"
+	"net/netip"
+	"strings"
+	"sync"
 	"unsafe"
 )
 
+const (
+	// Initial capacity of the writer buffer
+	defaultWriterCapacity = 256
+
+	// Writers with larger buffers are not returned to the pool to avoid retaining large memory blocks
+	maxPooledWriterCapacity = 64 * 1024
+)
+
+// writersPool holds released writers for reuse
+var writersPool = sync.Pool{
+	New: func() any {
+		return &Writer{buf: make([]byte, 0, defaultWriterCapacity)}
+	},
+}
+
 // NewWriter will initialize a new instance of writer
 func NewWriter() *Writer {
-	return &Writer{
-		buffer:  bytes.NewBuffer(nil),
-		bs:      nil,
-		written: 0,
+	return &Writer{buf: make([]byte, 0, defaultWriterCapacity)}
+}
+
+// Acquire will get a writer from the pool, the writer should be returned to the pool using Release
+func Acquire() *Writer {
+	return writersPool.Get().(*Writer)
+}
+
+// Release will reset the writer and return it to the pool, the writer (and the slice returned by Bytes) must not be
+// used after release
+func Release(w *Writer) {
+	if w == nil || cap(w.buf) > maxPooledWriterCapacity {
+		return
 	}
+	w.Reset()
+	writersPool.Put(w)
 }
 
-// Writer manages the writing of the output
+// Writer manages the writing of the output to a single growable buffer
 type Writer struct {
-	buffer  *bytes.Buffer
-	bs      []byte
-	written int64
+	buf     []byte
+	flushed int64
 }
 
 // Uint will encode unsigned int value
@@ -31,8 +59,7 @@ func (w *Writer) Uint(v uint) *Writer {
 
 // Uint8 will encode unsigned int 8 bit value (0 .. 255)
 func (w *Writer) Uint8(v uint8) *Writer {
-	w.bs = append(w.bs, v)
-	w.flush()
+	w.buf = append(w.buf, v)
 	return w
 }
 
@@ -49,33 +76,20 @@ func (w *Writer) Uint32(v uint32) *Writer {
 // Uint64 will encode unsigned int 64 bits value (0 .. 18,446,744,073,709,551,615)
 func (w *Writer) Uint64(v uint64) *Writer {
 	w.varInt(v)
-	w.flush()
 	return w
 }
 
-// varInt will put variable length integer in the temp buffer
+// varInt will append variable length integer to the buffer: every byte but the last has the high bit set, the value
+// is stored in 1 to 9 bytes according to its magnitude
 func (w *Writer) varInt(v uint64) {
-
-	switch {
-	case v < 1<<7-1:
-		w.bs = append(w.bs, byte(v))
-	case v < 1<<14-1:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7))
-	case v < 1<<21-1:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7)|0x80, byte(v>>14))
-	case v < 1<<28-1:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7)|0x80, byte(v>>14)|0x80, byte(v>>21))
-	case v < 1<<35-1:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7)|0x80, byte(v>>14)|0x80, byte(v>>21)|0x80, byte(v>>28))
-	case v < 1<<42-1:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7)|0x80, byte(v>>14)|0x80, byte(v>>21)|0x80, byte(v>>28)|0x80, byte(v>>35))
-	case v < 1<<49-1:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7)|0x80, byte(v>>14)|0x80, byte(v>>21)|0x80, byte(v>>28)|0x80, byte(v>>35)|0x80, byte(v>>42))
-	case v < 1<<56-1:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7)|0x80, byte(v>>14)|0x80, byte(v>>21)|0x80, byte(v>>28)|0x80, byte(v>>35)|0x80, byte(v>>42)|0x80, byte(v>>49))
-	default:
-		w.bs = append(w.bs, byte(v)|0x80, byte(v>>7)|0x80, byte(v>>14)|0x80, byte(v>>21)|0x80, byte(v>>28)|0x80, byte(v>>35)|0x80, byte(v>>42)|0x80, byte(v>>49)|0x80, byte(v>>56))
+	size := 1
+	for size < 9 && v >= 1<<(7*size)-1 {
+		size++
 	}
+	for i := 0; i < size-1; i++ {
+		w.buf = append(w.buf, byte(v>>(7*i))|0x80)
+	}
+	w.buf = append(w.buf, byte(v>>(7*(size-1))))
 }
 
 // Int will encode int value
@@ -90,7 +104,6 @@ func (w *Writer) IntArray(v []int) *Writer {
 	for _, val := range v {
 		w.varInt(uint64(val))
 	}
-	w.flush()
 	return w
 }
 
@@ -126,7 +139,6 @@ func (w *Writer) Float32Array(v []float32) *Writer {
 	for _, val := range v {
 		w.varInt(uint64(math.Float32bits(val)))
 	}
-	w.flush()
 	return w
 }
 
@@ -142,7 +154,6 @@ func (w *Writer) Float64Array(v []float64) *Writer {
 	for _, val := range v {
 		w.varInt(math.Float64bits(val))
 	}
-	w.flush()
 	return w
 }
 
@@ -157,8 +168,9 @@ func (w *Writer) Bool(v bool) *Writer {
 
 // String will encode a variable length string
 func (w *Writer) String(v string) *Writer {
-	bsp := getStringBytes(&v)
-	return w.Object(bsp)
+	w.varInt(uint64(len(v)))
+	w.buf = append(w.buf, v...)
+	return w
 }
 
 // StringArray will encode variable length array of strings
@@ -166,19 +178,16 @@ func (w *Writer) StringArray(v []string) *Writer {
 	// Write array sized
 	w.varInt(uint64(len(v)))
 	for _, val := range v {
-		bsp := getStringBytes(&val)
-		w.varInt(uint64(len(*bsp)))
-		w.bs = append(w.bs, *bsp...)
+		w.varInt(uint64(len(val)))
+		w.buf = append(w.buf, val...)
 	}
-	w.flush()
 	return w
 }
 
 // Object will encode an arbitrary object represented as variable length byte array
 func (w *Writer) Object(v *[]byte) *Writer {
 	w.varInt(uint64(len(*v)))
-	w.bs = append(w.bs, *v...)
-	w.flush()
+	w.buf = append(w.buf, *v...)
 	return w
 }
 
@@ -190,9 +199,8 @@ func (w *Writer) ObjectArray(v *[][]byte) *Writer {
 	// for each item, write the size of the item and then its content
 	for _, val := range *v {
 		w.varInt(uint64(len(val)))
-		w.bs = append(w.bs, val...)
+		w.buf = append(w.buf, val...)
 	}
-	w.flush()
 	return w
 }
 
@@ -204,23 +212,20 @@ func (w *Writer) Timestamp(v entity.Timestamp) *Writer {
 // IP will encode an IPv4 or IPv6 to byte array, to distinguish between IP types, we need a small uint8 header:
 // 1: IP represented as string, 4: IP represented as IPv4 int (uint32), 6: IP represented as IPv6 bigInt (2 * uint64)
 func (w *Writer) IP(v string) *Writer {
-	if ip, version, err := parseIP(v); err != nil {
+	addr, err := netip.ParseAddr(v)
+	if err != nil || len(addr.Zone()) > 0 {
 		return w.Uint8(1).String(v)
-	} else {
-		if version == 4 {
-			if ipv4, er := IPv4ToInt(ip); er != nil {
-				return w.Uint8(1).String(v)
-			} else {
-				return w.Uint8(4).Uint32(ipv4)
-			}
-		} else {
-			if ipv6, er := IPv6ToInt(ip); er != nil {
-				return w.Uint8(1).String(v)
-			} else {
-				return w.Uint8(6).Uint64(ipv6[0]).Uint64(ipv6[1])
-			}
+	}
+	// Dotted notation is stored as IPv4 (including IPv4-mapped IPv6 address)
+	if strings.Contains(v, ".") {
+		if addr = addr.Unmap(); !addr.Is4() {
+			return w.Uint8(1).String(v)
 		}
+		ipv4 := addr.As4()
+		return w.Uint8(4).Uint32(binary.BigEndian.Uint32(ipv4[:]))
 	}
+	ipv6 := addr.As16()
+	return w.Uint8(6).Uint64(binary.BigEndian.Uint64(ipv6[0:8])).Uint64(binary.BigEndian.Uint64(ipv6[8:16]))
 }
 
 // IPArray will encode a list of IPv4 or IPv6 to byte array, each IP is stored as defined in the IP() method
@@ -231,52 +236,60 @@ func (w *Writer) IPArray(v []string) *Writer {
 	for _, ip := range v {
 		w.IP(ip)
 	}
-
-	w.flush()
 	return w
 }
 
-// Reset will reset the underlying bytes of the Encoder
+// Grow will grow the buffer capacity to guarantee space for another n bytes
+func (w *Writer) Grow(n int) {
+	if cap(w.buf)-len(w.buf) < n {
+		buf := make([]byte, len(w.buf), 2*cap(w.buf)+n)
+		copy(buf, w.buf)
+		w.buf = buf
+	}
+}
+
+// Reset will reset the underlying bytes of the Encoder (the buffer capacity is kept for reuse)
 func (w *Writer) Reset() {
-	w.bs = w.bs[:0]
+	w.buf = w.buf[:0]
+	w.flushed = 0
 }
 
-// WriteTo will write to an io.Writer
+// WriteTo will write the buffered bytes to an io.Writer and reset the buffer
 func (w *Writer) WriteTo(dest io.Writer) (int64, error) {
-	if written, err := dest.Write(w.GetBytes()); err != nil {
-		return 0, err
-	} else {
-		n := int64(written)
-		w.Reset()
-		return n, nil
+	written, err := dest.Write(w.buf)
+	if err != nil {
+		return int64(written), err
 	}
+	n := int64(written)
+	w.flushed += n
+	w.buf = w.buf[:0]
+	return n, nil
+}
+
+// Bytes will expose the underlying bytes without copy, the slice is valid until the next write, Reset or Release
+func (w *Writer) Bytes() []byte {
+	return w.buf
 }
 
-// GetBytes will expose the underlying bytes
+// GetBytes will return a copy of the encoded bytes (safe to use after the writer is reset or released)
 func (w *Writer) GetBytes() []byte {
-	return w.bs
+	result := make([]byte, len(w.buf))
+	copy(result, w.buf)
+	return result
+}
+
+// Len will return the number of bytes in the buffer
+func (w *Writer) Len() int {
+	return len(w.buf)
 }
 
-// Written will return the total number of bytes written
+// Written will return the total number of bytes written (including bytes already written by WriteTo)
 func (w *Writer) Written() int64 {
-	return w.written
+	return w.flushed + int64(len(w.buf))
 }
 
 // Close will close the writer
 func (w *Writer) Close() (err error) {
-	w.bs = nil
+	w.buf = nil
 	return
 }
-
-// Flash temp bytes array (bs) to the buffer
-func (w *Writer) flush() {
-	if n, err := w.buffer.Write(w.bs); err == nil {
-		w.written += int64(n)
-		w.bs = w.bs[:]
-		//return nil
-	} else {
-		panic(err)
-		//w.bs = w.bs[:]
-		//return err
-	}
-}
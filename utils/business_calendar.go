@@ -0,0 +1,185 @@
+// Business calendar utilities
+//
+// Business days are the days which are neither weekend days nor holidays according to the holiday calendar of a region.
+// Holiday calendars are registered per region (e.g. "US", "IL") and used by the TimeUtils business day functions:
+//
+//	utils.RegisterHolidayCalendar("US", utils.NewHolidayList().AddYearly(time.December, 25, "Christmas"))
+//	due := utils.TimeUtils(entity.Now()).In(loc).AddBusinessDays("US", 3).Get()
+
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+)
+
+// HolidayCalendar defines the non-working days of a region, the day is provided in the time zone of the calendar user
+type HolidayCalendar interface {
+
+	// IsWeekend checks if the day is a weekend day
+	IsWeekend(day time.Time) bool
+
+	// IsHoliday checks if the day is a holiday
+	IsHoliday(day time.Time) bool
+}
+
+// region Holiday list -------------------------------------------------------------------------------------------------
+
+// HolidayList is a holiday calendar of weekend days, specific dates and yearly dates
+type HolidayList struct {
+	mu      sync.RWMutex
+	weekend map[time.Weekday]bool
+	dates   map[string]string
+	yearly  map[string]string
+}
+
+// NewHolidayList creates a holiday calendar with the weekend days (default: Saturday and Sunday)
+func NewHolidayList(weekend ...time.Weekday) *HolidayList {
+	if len(weekend) == 0 {
+		weekend = []time.Weekday{time.Saturday, time.Sunday}
+	}
+	h := &HolidayList{weekend: make(map[time.Weekday]bool), dates: make(map[string]string), yearly: make(map[string]string)}
+	for _, day := range weekend {
+		h.weekend[day] = true
+	}
+	return h
+}
+
+// Add a holiday on a specific date
+func (h *HolidayList) Add(year int, month time.Month, day int, name string) *HolidayList {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dates[fmt.Sprintf("%04d-%02d-%02d", year, month, day)] = name
+	return h
+}
+
+// AddYearly adds a holiday on the same date every year
+func (h *HolidayList) AddYearly(month time.Month, day int, name string) *HolidayList {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.yearly[fmt.Sprintf("%02d-%02d", month, day)] = name
+	return h
+}
+
+// IsWeekend checks if the day is a weekend day
+func (h *HolidayList) IsWeekend(day time.Time) bool {
+	return h.weekend[day.Weekday()]
+}
+
+// IsHoliday checks if the day is a holiday
+func (h *HolidayList) IsHoliday(day time.Time) bool {
+	_, ok := h.Holiday(day)
+	return ok
+}
+
+// Holiday returns the holiday name of the day
+func (h *HolidayList) Holiday(day time.Time) (string, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if name, ok := h.dates[day.Format("2006-01-02")]; ok {
+		return name, true
+	}
+	name, ok := h.yearly[day.Format("01-02")]
+	return name, ok
+}
+
+// endregion
+
+// region Holiday calendars registry -----------------------------------------------------------------------------------
+
+var (
+	holidayCalendarsMu sync.RWMutex
+	holidayCalendars   = make(map[string]HolidayCalendar)
+
+	// default calendar: Saturday and Sunday weekend without holidays
+	defaultHolidayCalendar HolidayCalendar = NewHolidayList()
+)
+
+// RegisterHolidayCalendar registers the holiday calendar of the region, an existing calendar of the region is replaced
+func RegisterHolidayCalendar(region string, calendar HolidayCalendar) {
+	holidayCalendarsMu.Lock()
+	defer holidayCalendarsMu.Unlock()
+	holidayCalendars[region] = calendar
+}
+
+// GetHolidayCalendar returns the holiday calendar of the region, regions without registered calendar use the default
+// calendar (Saturday and Sunday weekend without holidays) and false is returned
+func GetHolidayCalendar(region string) (HolidayCalendar, bool) {
+	holidayCalendarsMu.RLock()
+	defer holidayCalendarsMu.RUnlock()
+	if calendar, ok := holidayCalendars[region]; ok {
+		return calendar, true
+	}
+	return defaultHolidayCalendar, false
+}
+
+// endregion
+
+// region Business days ------------------------------------------------------------------------------------------------
+
+// IsBusinessDay checks if the day of the timestamp (in the time zone, see In) is a business day in the region
+func (t *timeUtils) IsBusinessDay(region string) bool {
+	calendar, _ := GetHolidayCalendar(region)
+	return isBusinessDay(calendar, t.time())
+}
+
+// AddBusinessDays adds n business days of the region to the timestamp (negative n subtracts business days), the time
+// of day is kept. When the base day is not a business day, the first added day is the nearest business day.
+// The search is limited to 366 days per business day, the timestamp is not changed if the calendar has fewer business
+// days (e.g. all the days are weekend days)
+func (t *timeUtils) AddBusinessDays(region string, n int) *timeUtils {
+	calendar, _ := GetHolidayCalendar(region)
+	day := t.time()
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for limit := 366 * n; n > 0; limit-- {
+		if limit == 0 {
+			return t
+		}
+		day = day.AddDate(0, 0, step)
+		if isBusinessDay(calendar, day) {
+			n--
+		}
+	}
+	t.baseTime = Timestamp(day.UnixMilli())
+	return t
+}
+
+// NextBusinessDay moves the timestamp to the next business day of the region (after the base day), the time of day is
+// kept (see AddBusinessDays)
+func (t *timeUtils) NextBusinessDay(region string) *timeUtils {
+	return t.AddBusinessDays(region, 1)
+}
+
+// BusinessDaysUntil counts the business days of the region from the base day (excluded) to the end day (included),
+// the result is negative when the end time is before the base time
+func (t *timeUtils) BusinessDaysUntil(end Timestamp, region string) int {
+	calendar, _ := GetHolidayCalendar(region)
+	from := startOfPeriod(t.time(), TimePeriodCodes.DAY)
+	to := startOfPeriod(time.UnixMilli(int64(end)).In(from.Location()), TimePeriodCodes.DAY)
+
+	sign := 1
+	if to.Before(from) {
+		from, to, sign = to, from, -1
+	}
+	count := 0
+	for day := from.AddDate(0, 0, 1); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if isBusinessDay(calendar, day) {
+			count++
+		}
+	}
+	return sign * count
+}
+
+// check if the day is neither weekend nor holiday
+func isBusinessDay(calendar HolidayCalendar, day time.Time) bool {
+	return !calendar.IsWeekend(day) && !calendar.IsHoliday(day)
+}
+
+// endregion
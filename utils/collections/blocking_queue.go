@@ -0,0 +1,142 @@
+// Thread-safe implementation of bounded FIFO queue with blocking operations
+//
+
+package collections
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueClosed is returned by the blocking operations of a closed queue
+var ErrQueueClosed = errors.New("queue is closed")
+
+// BlockingQueue is a thread-safe FIFO queue with optional bounded capacity and blocking push / pop operations
+type BlockingQueue[T any] struct {
+	mu       sync.Mutex
+	items    []T
+	capacity int
+	closed   bool
+	changed  chan struct{} // closed (and replaced) on every change to wake up the waiting operations
+}
+
+// NewBlockingQueue creates a queue bounded by the capacity (0 for unbounded queue)
+func NewBlockingQueue[T any](capacity int) *BlockingQueue[T] {
+	return &BlockingQueue[T]{items: make([]T, 0), capacity: capacity, changed: make(chan struct{})}
+}
+
+// Push item to the queue, returns false if the queue is full or closed
+func (q *BlockingQueue[T]) Push(v T) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed || q.isFull() {
+		return false
+	}
+	q.items = append(q.items, v)
+	q.notify()
+	return true
+}
+
+// Pop item from the queue, returns false if the queue is empty
+func (q *BlockingQueue[T]) Pop() (v T, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return v, false
+	}
+	return q.pop(), true
+}
+
+// PushWait pushes item to the queue, waiting for free capacity until the context is done or the queue is closed
+func (q *BlockingQueue[T]) PushWait(ctx context.Context, v T) error {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return ErrQueueClosed
+		}
+		if !q.isFull() {
+			q.items = append(q.items, v)
+			q.notify()
+			q.mu.Unlock()
+			return nil
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// PopWait pops item from the queue, waiting for an item until the context is done (e.g. timeout)
+// Items of a closed queue are still returned until the queue is empty, then ErrQueueClosed is returned
+func (q *BlockingQueue[T]) PopWait(ctx context.Context) (v T, err error) {
+	for {
+		q.mu.Lock()
+		if len(q.items) > 0 {
+			v = q.pop()
+			q.mu.Unlock()
+			return v, nil
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return v, ErrQueueClosed
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return v, ctx.Err()
+		}
+	}
+}
+
+// Length get queue length (number of items)
+func (q *BlockingQueue[T]) Length() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Capacity get queue capacity (0 for unbounded queue)
+func (q *BlockingQueue[T]) Capacity() int {
+	return q.capacity
+}
+
+// Close the queue: push operations fail and the waiting operations are released
+func (q *BlockingQueue[T]) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		q.notify()
+	}
+}
+
+// check if the queue reached its capacity
+func (q *BlockingQueue[T]) isFull() bool {
+	return q.capacity > 0 && len(q.items) >= q.capacity
+}
+
+// pop the first item (under lock)
+func (q *BlockingQueue[T]) pop() T {
+	var zero T
+	v := q.items[0]
+	q.items[0] = zero
+	q.items = q.items[1:]
+	q.notify()
+	return v
+}
+
+// wake up the waiting operations (under lock)
+func (q *BlockingQueue[T]) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
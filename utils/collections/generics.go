@@ -0,0 +1,98 @@
+// Generic slice helpers (grouping, chunking, reducing, flattening and ordering)
+//
+
+package collections
+
+import (
+	"cmp"
+)
+
+// GroupBy returns a map of the key returned by `keyFn` to the items with that key (keeping the items order)
+func GroupBy[T any, K comparable](vs []T, keyFn func(T) K) map[K][]T {
+	result := make(map[K][]T)
+	for _, v := range vs {
+		key := keyFn(v)
+		result[key] = append(result[key], v)
+	}
+	return result
+}
+
+// Chunk splits the slice into chunks of `size` items (the last chunk may be smaller), the chunks share the memory
+// of the original slice. Panics if size is not positive
+func Chunk[T any](vs []T, size int) [][]T {
+	if size <= 0 {
+		panic("collections.Chunk: size must be positive")
+	}
+	result := make([][]T, 0, (len(vs)+size-1)/size)
+	for size < len(vs) {
+		vs, result = vs[size:], append(result, vs[0:size:size])
+	}
+	if len(vs) > 0 {
+		result = append(result, vs)
+	}
+	return result
+}
+
+// Reduce applies the function `f` to the accumulator (starting with `initial`) and each item in the slice
+func Reduce[T any, A any](vs []T, initial A, f func(acc A, v T) A) A {
+	acc := initial
+	for _, v := range vs {
+		acc = f(acc, v)
+	}
+	return acc
+}
+
+// FlatMap returns a new slice concatenating the results of applying the function `f` to each item in the slice
+func FlatMap[T any, R any](vs []T, f func(T) []R) []R {
+	result := make([]R, 0, len(vs))
+	for _, v := range vs {
+		result = append(result, f(v)...)
+	}
+	return result
+}
+
+// Flatten returns a new slice concatenating all the slices
+func Flatten[T any](slices [][]T) []T {
+	total := 0
+	for _, slc := range slices {
+		total += len(slc)
+	}
+	result := make([]T, 0, total)
+	for _, slc := range slices {
+		result = append(result, slc...)
+	}
+	return result
+}
+
+// Reverse returns a new slice with the items in reverse order
+func Reverse[T any](vs []T) []T {
+	result := make([]T, len(vs))
+	for i, v := range vs {
+		result[len(vs)-1-i] = v
+	}
+	return result
+}
+
+// MinBy returns the first item with the minimal key returned by `keyFn`, false if the slice is empty
+func MinBy[T any, K cmp.Ordered](vs []T, keyFn func(T) K) (result T, ok bool) {
+	return selectBy(vs, keyFn, func(a, b K) bool { return a < b })
+}
+
+// MaxBy returns the first item with the maximal key returned by `keyFn`, false if the slice is empty
+func MaxBy[T any, K cmp.Ordered](vs []T, keyFn func(T) K) (result T, ok bool) {
+	return selectBy(vs, keyFn, func(a, b K) bool { return a > b })
+}
+
+// select the first item whose key is better than all other keys
+func selectBy[T any, K cmp.Ordered](vs []T, keyFn func(T) K, better func(a, b K) bool) (result T, ok bool) {
+	if len(vs) == 0 {
+		return result, false
+	}
+	result, best := vs[0], keyFn(vs[0])
+	for _, v := range vs[1:] {
+		if key := keyFn(v); better(key, best) {
+			result, best = v, key
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,124 @@
+// Generic insertion-ordered map, optionally thread-safe
+//
+
+package collections
+
+import (
+	"container/list"
+)
+
+// OrderedMap is a map which keeps the insertion order of the keys
+type OrderedMap[K comparable, V any] struct {
+	optionalLock
+	entries map[K]*list.Element
+	order   *list.List
+}
+
+// orderedEntry is the list element value of the ordered map
+type orderedEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewOrderedMap creates an ordered map (not thread-safe)
+func NewOrderedMap[K comparable, V any]() *OrderedMap[K, V] {
+	return &OrderedMap[K, V]{entries: make(map[K]*list.Element), order: list.New()}
+}
+
+// NewConcurrentOrderedMap creates a thread-safe ordered map
+func NewConcurrentOrderedMap[K comparable, V any]() *OrderedMap[K, V] {
+	m := NewOrderedMap[K, V]()
+	m.safe = true
+	return m
+}
+
+// Set the value of the key, an existing key keeps its position
+func (m *OrderedMap[K, V]) Set(key K, value V) {
+	m.lock()
+	defer m.unlock()
+	if e, ok := m.entries[key]; ok {
+		e.Value.(*orderedEntry[K, V]).value = value
+		return
+	}
+	m.entries[key] = m.order.PushBack(&orderedEntry[K, V]{key: key, value: value})
+}
+
+// Get the value of the key
+func (m *OrderedMap[K, V]) Get(key K) (value V, ok bool) {
+	m.rlock()
+	defer m.runlock()
+	if e, found := m.entries[key]; found {
+		return e.Value.(*orderedEntry[K, V]).value, true
+	}
+	return value, false
+}
+
+// Has returns true if the key exists
+func (m *OrderedMap[K, V]) Has(key K) bool {
+	m.rlock()
+	defer m.runlock()
+	_, ok := m.entries[key]
+	return ok
+}
+
+// Delete the key, returns true if the key existed
+func (m *OrderedMap[K, V]) Delete(key K) bool {
+	m.lock()
+	defer m.unlock()
+	e, ok := m.entries[key]
+	if ok {
+		m.order.Remove(e)
+		delete(m.entries, key)
+	}
+	return ok
+}
+
+// Len returns the number of keys
+func (m *OrderedMap[K, V]) Len() int {
+	m.rlock()
+	defer m.runlock()
+	return len(m.entries)
+}
+
+// Keys returns the keys by insertion order
+func (m *OrderedMap[K, V]) Keys() []K {
+	m.rlock()
+	defer m.runlock()
+	result := make([]K, 0, len(m.entries))
+	for e := m.order.Front(); e != nil; e = e.Next() {
+		result = append(result, e.Value.(*orderedEntry[K, V]).key)
+	}
+	return result
+}
+
+// Values returns the values by insertion order of the keys
+func (m *OrderedMap[K, V]) Values() []V {
+	m.rlock()
+	defer m.runlock()
+	result := make([]V, 0, len(m.entries))
+	for e := m.order.Front(); e != nil; e = e.Next() {
+		result = append(result, e.Value.(*orderedEntry[K, V]).value)
+	}
+	return result
+}
+
+// Range calls the function for each key and value by insertion order until the function returns false
+// The map must not be modified by the function
+func (m *OrderedMap[K, V]) Range(f func(key K, value V) bool) {
+	m.rlock()
+	defer m.runlock()
+	for e := m.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*orderedEntry[K, V])
+		if !f(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+// Clear removes all the keys
+func (m *OrderedMap[K, V]) Clear() {
+	m.lock()
+	defer m.unlock()
+	m.entries = make(map[K]*list.Element)
+	m.order.Init()
+}
@@ -0,0 +1,142 @@
+// Thread-safe implementation of generic priority queue (binary heap) with stable ordering
+//
+
+package collections
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// PriorityQueue is a thread-safe priority queue ordered by a comparator, items with equal priority are popped by
+// the order they were pushed (FIFO)
+type PriorityQueue[T any] struct {
+	mu      sync.Mutex
+	heap    priorityHeap[T]
+	seq     uint64
+	changed chan struct{} // closed (and replaced) on push to wake up the waiting consumers
+}
+
+// NewPriorityQueue creates a priority queue, `less` returns true if `a` should be popped before `b`
+func NewPriorityQueue[T any](less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{heap: priorityHeap[T]{less: less}, changed: make(chan struct{})}
+}
+
+// Push items to the queue
+func (q *PriorityQueue[T]) Push(items ...T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, v := range items {
+		q.seq++
+		heap.Push(&q.heap, priorityItem[T]{value: v, seq: q.seq})
+	}
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// Pop the first item by priority, returns false if the queue is empty
+func (q *PriorityQueue[T]) Pop() (v T, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.heap.Len() == 0 {
+		return v, false
+	}
+	return heap.Pop(&q.heap).(priorityItem[T]).value, true
+}
+
+// Peek returns the first item by priority without removing it, returns false if the queue is empty
+func (q *PriorityQueue[T]) Peek() (v T, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.heap.Len() == 0 {
+		return v, false
+	}
+	return q.heap.items[0].value, true
+}
+
+// PopWait pops the first item by priority, waiting for an item until the context is done
+func (q *PriorityQueue[T]) PopWait(ctx context.Context) (v T, err error) {
+	for {
+		q.mu.Lock()
+		if q.heap.Len() > 0 {
+			v = heap.Pop(&q.heap).(priorityItem[T]).value
+			q.mu.Unlock()
+			return v, nil
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return v, ctx.Err()
+		}
+	}
+}
+
+// PopTimeout pops the first item by priority, waiting for an item up to the timeout, returns false on timeout
+func (q *PriorityQueue[T]) PopTimeout(timeout time.Duration) (v T, ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	v, err := q.PopWait(ctx)
+	return v, err == nil
+}
+
+// Length get number of items in the queue
+func (q *PriorityQueue[T]) Length() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.heap.Len()
+}
+
+// Clear removes all the items
+func (q *PriorityQueue[T]) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.heap.items = nil
+}
+
+// priorityItem is the heap item with the push sequence for stable ordering
+type priorityItem[T any] struct {
+	value T
+	seq   uint64
+}
+
+// priorityHeap implements heap.Interface
+type priorityHeap[T any] struct {
+	items []priorityItem[T]
+	less  func(a, b T) bool
+}
+
+func (h *priorityHeap[T]) Len() int {
+	return len(h.items)
+}
+
+func (h *priorityHeap[T]) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if h.less(a.value, b.value) {
+		return true
+	}
+	if h.less(b.value, a.value) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (h *priorityHeap[T]) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *priorityHeap[T]) Push(x any) {
+	h.items = append(h.items, x.(priorityItem[T]))
+}
+
+func (h *priorityHeap[T]) Pop() any {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items[n-1] = priorityItem[T]{}
+	h.items = h.items[:n-1]
+	return item
+}
@@ -15,6 +15,9 @@ type Queue interface {
 	// Pop last item
 	Pop() (any, bool)
 
+	// Peek first item without removing it
+	Peek() (any, bool)
+
 	// Length get length of the queue
 	Length() int
 }
@@ -51,6 +54,17 @@ func (p *defaultQueue) Pop() (v any, exist bool) {
 	return
 }
 
+// Peek first item in the queue without removing it
+func (p *defaultQueue) Peek() (v any, exist bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.queue) == 0 {
+		return
+	}
+	return p.queue[0], true
+}
+
 // Length get queue length (number of items)
 func (p *defaultQueue) Length() int {
 	p.Lock()
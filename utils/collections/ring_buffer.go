@@ -0,0 +1,99 @@
+// Thread-safe implementation of fixed size ring buffer (e.g. for sliding telemetry windows)
+//
+
+package collections
+
+import (
+	"sync"
+)
+
+// RingBuffer keeps the last N items, adding an item to a full buffer overrides the oldest item
+type RingBuffer[T any] struct {
+	mu    sync.RWMutex
+	items []T
+	start int
+	size  int
+}
+
+// NewRingBuffer creates a ring buffer of the capacity, panics if the capacity is not positive
+func NewRingBuffer[T any](capacity int) *RingBuffer[T] {
+	if capacity <= 0 {
+		panic("collections.NewRingBuffer: capacity must be positive")
+	}
+	return &RingBuffer[T]{items: make([]T, capacity)}
+}
+
+// Add items to the buffer, returns true if an item was overridden
+func (r *RingBuffer[T]) Add(items ...T) (overridden bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range items {
+		if r.size < len(r.items) {
+			r.items[(r.start+r.size)%len(r.items)] = v
+			r.size++
+		} else {
+			r.items[r.start] = v
+			r.start = (r.start + 1) % len(r.items)
+			overridden = true
+		}
+	}
+	return overridden
+}
+
+// Items returns the items from the oldest to the newest
+func (r *RingBuffer[T]) Items() []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]T, r.size)
+	for i := 0; i < r.size; i++ {
+		result[i] = r.items[(r.start+i)%len(r.items)]
+	}
+	return result
+}
+
+// Oldest returns the oldest item, false if the buffer is empty
+func (r *RingBuffer[T]) Oldest() (v T, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.size == 0 {
+		return v, false
+	}
+	return r.items[r.start], true
+}
+
+// Newest returns the newest item, false if the buffer is empty
+func (r *RingBuffer[T]) Newest() (v T, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.size == 0 {
+		return v, false
+	}
+	return r.items[(r.start+r.size-1)%len(r.items)], true
+}
+
+// Length get number of items in the buffer
+func (r *RingBuffer[T]) Length() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.size
+}
+
+// Capacity get the buffer capacity
+func (r *RingBuffer[T]) Capacity() int {
+	return len(r.items)
+}
+
+// IsFull returns true if the buffer reached its capacity
+func (r *RingBuffer[T]) IsFull() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.size == len(r.items)
+}
+
+// Clear removes all the items
+func (r *RingBuffer[T]) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clear(r.items)
+	r.start, r.size = 0, 0
+}
@@ -0,0 +1,150 @@
+// Generic set data structure, optionally thread-safe
+//
+
+package collections
+
+import (
+	"sync"
+)
+
+// Set is a collection of unique items
+type Set[T comparable] struct {
+	optionalLock
+	items map[T]struct{}
+}
+
+// NewSet creates a set (not thread-safe) with the items
+func NewSet[T comparable](items ...T) *Set[T] {
+	s := &Set[T]{items: make(map[T]struct{}, len(items))}
+	s.add(items)
+	return s
+}
+
+// NewConcurrentSet creates a thread-safe set with the items
+func NewConcurrentSet[T comparable](items ...T) *Set[T] {
+	s := NewSet(items...)
+	s.safe = true
+	return s
+}
+
+// Add items to the set
+func (s *Set[T]) Add(items ...T) {
+	s.lock()
+	defer s.unlock()
+	s.add(items)
+}
+
+// Remove items from the set
+func (s *Set[T]) Remove(items ...T) {
+	s.lock()
+	defer s.unlock()
+	for _, item := range items {
+		delete(s.items, item)
+	}
+}
+
+// Contains returns true if the item is in the set
+func (s *Set[T]) Contains(item T) bool {
+	s.rlock()
+	defer s.runlock()
+	_, ok := s.items[item]
+	return ok
+}
+
+// Len returns the number of items in the set
+func (s *Set[T]) Len() int {
+	s.rlock()
+	defer s.runlock()
+	return len(s.items)
+}
+
+// Items returns the items of the set (in no particular order)
+func (s *Set[T]) Items() []T {
+	s.rlock()
+	defer s.runlock()
+	result := make([]T, 0, len(s.items))
+	for item := range s.items {
+		result = append(result, item)
+	}
+	return result
+}
+
+// Clear removes all the items from the set
+func (s *Set[T]) Clear() {
+	s.lock()
+	defer s.unlock()
+	s.items = make(map[T]struct{})
+}
+
+// Union returns a new set with the items of both sets
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	result := s.newSet(s.Items())
+	result.add(other.Items())
+	return result
+}
+
+// Intersect returns a new set with the items which are in both sets
+func (s *Set[T]) Intersect(other *Set[T]) *Set[T] {
+	result := s.newSet(nil)
+	for _, item := range s.Items() {
+		if other.Contains(item) {
+			result.items[item] = struct{}{}
+		}
+	}
+	return result
+}
+
+// Diff returns a new set with the items which are in this set but not in the other set
+func (s *Set[T]) Diff(other *Set[T]) *Set[T] {
+	result := s.newSet(nil)
+	for _, item := range s.Items() {
+		if !other.Contains(item) {
+			result.items[item] = struct{}{}
+		}
+	}
+	return result
+}
+
+// create a set with the same thread-safety of this set
+func (s *Set[T]) newSet(items []T) *Set[T] {
+	result := NewSet(items...)
+	result.safe = s.safe
+	return result
+}
+
+// add items (without lock)
+func (s *Set[T]) add(items []T) {
+	for _, item := range items {
+		s.items[item] = struct{}{}
+	}
+}
+
+// optionalLock is a read/write lock which is applied only if the collection is thread-safe
+type optionalLock struct {
+	mu   sync.RWMutex
+	safe bool
+}
+
+func (l *optionalLock) lock() {
+	if l.safe {
+		l.mu.Lock()
+	}
+}
+
+func (l *optionalLock) unlock() {
+	if l.safe {
+		l.mu.Unlock()
+	}
+}
+
+func (l *optionalLock) rlock() {
+	if l.safe {
+		l.mu.RLock()
+	}
+}
+
+func (l *optionalLock) runlock() {
+	if l.safe {
+		l.mu.RUnlock()
+	}
+}
@@ -0,0 +1,206 @@
+// Package concurrency provides bounded parallel execution helpers
+//
+// Pool runs submitted tasks with a concurrency limit and aggregates their errors, ParallelMap / ForEach process a
+// slice with a concurrency limit and stop on the first error or when the context is canceled, e.g. fan-out of a bulk
+// database operation to the shards:
+//
+//	err := concurrency.ForEach(ctx, shards, 4, func(ctx context.Context, shard string) error {
+//		_, err := db.BulkInsert(entitiesOf[shard])
+//		return err
+//	})
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// ErrPoolClosed is returned when submitting a task to a pool after Wait was called
+var ErrPoolClosed = errors.New("pool is closed")
+
+// region Pool ---------------------------------------------------------------------------------------------------------
+
+// Task is a unit of work executed by the pool, the context is canceled when the pool context is done (or on the first
+// error if the pool is fail fast)
+type Task func(ctx context.Context) error
+
+// Pool runs tasks with bounded concurrency and aggregates the task errors
+type Pool struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	sem      chan struct{}
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	errs     []error
+	closed   bool
+	failFast bool
+}
+
+// NewPool creates a pool running up to `limit` tasks concurrently (limit <= 0 means 1)
+func NewPool(ctx context.Context, limit int) *Pool {
+	if limit <= 0 {
+		limit = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Pool{ctx: ctx, cancel: cancel, sem: make(chan struct{}, limit)}
+}
+
+// WithFailFast cancels the context of the running tasks and rejects new tasks on the first error
+func (p *Pool) WithFailFast() *Pool {
+	p.failFast = true
+	return p
+}
+
+// Submit runs the task when a worker is available, blocks while all the workers are busy
+// Returns an error if the pool is closed or the pool context is done before the task was started
+func (p *Pool) Submit(task Task) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	p.wg.Add(1)
+	p.mu.Unlock()
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-p.ctx.Done():
+		p.wg.Done()
+		return p.ctx.Err()
+	}
+
+	go func() {
+		defer func() {
+			<-p.sem
+			p.wg.Done()
+		}()
+		if err := runTask(p.ctx, task); err != nil {
+			p.addError(err)
+		}
+	}()
+	return nil
+}
+
+// Wait closes the pool for new tasks, waits for the submitted tasks to complete (graceful drain) and returns the
+// aggregated errors of the tasks (nil if all the tasks succeeded)
+func (p *Pool) Wait() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	p.cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return errors.Join(p.errs...)
+}
+
+// add task error
+func (p *Pool) addError(err error) {
+	p.mu.Lock()
+	p.errs = append(p.errs, err)
+	p.mu.Unlock()
+	if p.failFast {
+		p.cancel()
+	}
+}
+
+// run the task and convert panic to error
+func runTask(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in task: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return task(ctx)
+}
+
+// endregion
+
+// region Parallel map -------------------------------------------------------------------------------------------------
+
+// ParallelMap applies the function to the items with up to `limit` concurrent calls and returns the results by the
+// order of the items. On the first error (or when the context is done) the context of the running calls is canceled,
+// the remaining items are skipped and the error is returned
+func ParallelMap[T any, R any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+	results := make([]R, len(items))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		firstErr error
+		once     sync.Once
+		wg       sync.WaitGroup
+	)
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// Workers pull the next item index until the items are exhausted or the context is done
+	next := make(chan int)
+	for w := 0; w < limit; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				err := runTask(ctx, func(ctx context.Context) (err error) {
+					results[i], err = fn(ctx, items[i])
+					return err
+				})
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	skipped := false
+dispatch:
+	for i := range items {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			skipped = true
+			break dispatch
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// The parent context was done before all the items were dispatched
+	if skipped {
+		return nil, parent.Err()
+	}
+	return results, nil
+}
+
+// ForEach calls the function for the items with up to `limit` concurrent calls, see ParallelMap
+func ForEach[T any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) error) error {
+	_, err := ParallelMap(ctx, items, limit, func(ctx context.Context, item T) (struct{}, error) {
+		return struct{}{}, fn(ctx, item)
+	})
+	return err
+}
+
+// endregion
@@ -0,0 +1,332 @@
+// Payload signing and encryption utilities
+//
+// Payloads (e.g. webhook bodies, message payloads) are signed using HMAC-SHA256 and encrypted using envelope encryption:
+// each payload is encrypted (AES-256-GCM) with a random data key, and the data key is encrypted with the master key.
+
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/config"
+)
+
+// WebhookSignatureHeader is the header of the webhook payload signature (t=<epoch seconds>,v1=<hex signature>)
+const WebhookSignatureHeader = "X-Webhook-Signature"
+
+// ErrDecryptionFailed is returned when the encrypted payload is malformed, was tampered with or the key is unknown
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// region Singleton Pattern --------------------------------------------------------------------------------------------
+
+type cryptoUtils struct{}
+
+var doOnceForCryptoUtils sync.Once
+
+var cryptoUtilsSingleton *cryptoUtils = nil
+
+// CryptoUtils is a factory method that acts as a static member
+func CryptoUtils() *cryptoUtils {
+	doOnceForCryptoUtils.Do(func() {
+		cryptoUtilsSingleton = &cryptoUtils{}
+	})
+	return cryptoUtilsSingleton
+}
+
+// endregion
+
+// region HMAC signing -------------------------------------------------------------------------------------------------
+
+// Sign calculates the hex encoded HMAC-SHA256 signature of the payload
+func (c *cryptoUtils) Sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the hex encoded HMAC-SHA256 signature of the payload (constant time comparison)
+func (c *cryptoUtils) Verify(secret, payload []byte, signature string) bool {
+	return hmac.Equal([]byte(c.Sign(secret, payload)), []byte(strings.ToLower(signature)))
+}
+
+// SignWebhook calculates the webhook signature header value of the payload, the signing time is part of the signature
+// to prevent replay of old payloads
+func (c *cryptoUtils) SignWebhook(secret, payload []byte) string {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, c.Sign(secret, c.webhookPayload(timestamp, payload)))
+}
+
+// VerifyWebhook verifies the webhook signature header value, payloads older (or newer) than maxSkew are rejected
+// (0 for no limit). The header may include several v1 signatures (e.g. during the sender secret rotation)
+func (c *cryptoUtils) VerifyWebhook(secret, payload []byte, header string, maxSkew time.Duration) error {
+	timestamp := ""
+	signatures := make([]string, 0, 1)
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if len(timestamp) == 0 || len(signatures) == 0 {
+		return fmt.Errorf("missing webhook signature")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook signature timestamp: %s", timestamp)
+	}
+	if maxSkew > 0 {
+		if skew := time.Since(time.Unix(ts, 0)); skew > maxSkew || skew < -maxSkew {
+			return fmt.Errorf("webhook signature timestamp is out of range")
+		}
+	}
+
+	signed := c.webhookPayload(timestamp, payload)
+	for _, signature := range signatures {
+		if c.Verify(secret, signed, signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid webhook signature")
+}
+
+// signed webhook content: timestamp and payload
+func (c *cryptoUtils) webhookPayload(timestamp string, payload []byte) []byte {
+	result := make([]byte, 0, len(timestamp)+1+len(payload))
+	result = append(result, timestamp...)
+	result = append(result, '.')
+	return append(result, payload...)
+}
+
+// endregion
+
+// region Envelope encryption ------------------------------------------------------------------------------------------
+
+// envelope format version
+const envelopeVersion byte = 1
+
+// EnvelopeCipher encrypts payloads with a random data key per payload, the data key is encrypted with the active master
+// key and stored with the payload together with the master key id. Previous master keys can be added to decrypt
+// payloads encrypted before the key rotation:
+//
+//	ec, err := utils.NewEnvelopeCipherFromConfig()
+//	data, err := ec.EncryptEntity(user)
+//	err = ec.DecryptEntity(data, &user)
+type EnvelopeCipher struct {
+	mu       sync.RWMutex
+	activeId string
+	keys     map[string]cipher.AEAD
+}
+
+// NewEnvelopeCipher creates envelope cipher with the active master key (must be 32 bytes length)
+func NewEnvelopeCipher(keyId string, masterKey []byte) (*EnvelopeCipher, error) {
+	if len(keyId) > 255 {
+		return nil, fmt.Errorf("key id is too long")
+	}
+	aead, err := newMasterKeyCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &EnvelopeCipher{activeId: keyId, keys: map[string]cipher.AEAD{keyId: aead}}, nil
+}
+
+// NewEnvelopeCipherFromConfig creates envelope cipher with the master key of the configuration (see config.CfgEncryptionSecret)
+func NewEnvelopeCipherFromConfig() (*EnvelopeCipher, error) {
+	secret := config.Get().EncryptionSecret()
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("missing %s configuration", config.CfgEncryptionSecret)
+	}
+	masterKey, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s configuration: %w", config.CfgEncryptionSecret, err)
+	}
+	return NewEnvelopeCipher(config.Get().EncryptionKeyId(), masterKey)
+}
+
+// AddKey adds a master key used only to decrypt payloads (e.g. the previous key after key rotation)
+func (e *EnvelopeCipher) AddKey(keyId string, masterKey []byte) error {
+	aead, err := newMasterKeyCipher(masterKey)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.keys[keyId]; exists {
+		return fmt.Errorf("duplicate master key id: %s", keyId)
+	}
+	e.keys[keyId] = aead
+	return nil
+}
+
+// KeyId returns the id of the master key encrypting the payload
+func (e *EnvelopeCipher) KeyId(data []byte) (string, error) {
+	header, err := envelopeHeader(data)
+	if err != nil {
+		return "", err
+	}
+	return string(header[2:]), nil
+}
+
+// Encrypt the payload
+func (e *EnvelopeCipher) Encrypt(plain []byte) ([]byte, error) {
+	e.mu.RLock()
+	master := e.keys[e.activeId]
+	keyId := e.activeId
+	e.mu.RUnlock()
+
+	// Generate a new data key
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	data, err := newMasterKeyCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: version, key id length and key id (authenticated by both ciphers)
+	header := append([]byte{envelopeVersion, byte(len(keyId))}, keyId...)
+
+	// Output: header, encrypted data key and encrypted payload
+	result, err := seal(master, append([]byte{}, header...), dataKey, header)
+	if err != nil {
+		return nil, err
+	}
+	return seal(data, result, plain, header)
+}
+
+// Decrypt the payload
+func (e *EnvelopeCipher) Decrypt(encrypted []byte) ([]byte, error) {
+	header, err := envelopeHeader(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.RLock()
+	master, found := e.keys[string(header[2:])]
+	e.mu.RUnlock()
+	if !found {
+		return nil, ErrDecryptionFailed
+	}
+
+	// Decrypt the data key
+	rest := encrypted[len(header):]
+	keySize := master.NonceSize() + 32 + master.Overhead()
+	if len(rest) < keySize {
+		return nil, ErrDecryptionFailed
+	}
+	dataKey, err := open(master, rest[:keySize], header)
+	if err != nil {
+		return nil, err
+	}
+	data, err := newMasterKeyCipher(dataKey)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return open(data, rest[keySize:], header)
+}
+
+// EncryptEntity encrypts the JSON representation of the entity (or any other value)
+func (e *EnvelopeCipher) EncryptEntity(value any) ([]byte, error) {
+	plain, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return e.Encrypt(plain)
+}
+
+// DecryptEntity decrypts the payload and unmarshal the JSON into the entity (or any other value)
+func (e *EnvelopeCipher) DecryptEntity(encrypted []byte, value any) error {
+	plain, err := e.Decrypt(encrypted)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, value)
+}
+
+// EncryptString encrypts the string and return base64 (URL safe) string, e.g. to use in headers or JSON fields
+func (e *EnvelopeCipher) EncryptString(plain string) (string, error) {
+	encrypted, err := e.Encrypt([]byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(encrypted), nil
+}
+
+// DecryptString decrypts base64 string encrypted by EncryptString
+func (e *EnvelopeCipher) DecryptString(encrypted string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+	plain, err := e.Decrypt(data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// endregion
+
+// region PRIVATE SECTION ----------------------------------------------------------------------------------------------
+
+// AES-256-GCM cipher of the key
+func newMasterKeyCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes length")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal the plain text with a random nonce and append the nonce and the cipher text to dst
+func seal(aead cipher.AEAD, dst, plain, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plain, additional), nil
+}
+
+// open the nonce prefixed cipher text
+func open(aead cipher.AEAD, encrypted, additional []byte) ([]byte, error) {
+	if len(encrypted) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrDecryptionFailed
+	}
+	plain, err := aead.Open(nil, encrypted[:aead.NonceSize()], encrypted[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plain, nil
+}
+
+// extract the envelope header (version, key id length and key id)
+func envelopeHeader(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != envelopeVersion || len(data) < 2+int(data[1]) {
+		return nil, ErrDecryptionFailed
+	}
+	return data[:2+int(data[1])], nil
+}
+
+// endregion
@@ -0,0 +1,405 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDockerHost = "unix:///var/run/docker.sock"
+	dockerPingTimeout = 2 * time.Second // Engine ping timeout when selecting the docker engine
+)
+
+// DockerError is an error response of the Docker Engine API
+type DockerError struct {
+	StatusCode int    // HTTP status code
+	Message    string // Error message
+}
+
+// Error implements the error interface
+func (e *DockerError) Error() string {
+	return fmt.Sprintf("docker engine error %d: %s", e.StatusCode, e.Message)
+}
+
+// IsDockerNotFound returns true if the error is a Docker Engine API not found error (e.g. no such container)
+func IsDockerNotFound(err error) bool {
+	var de *DockerError
+	return errors.As(err, &de) && de.StatusCode == http.StatusNotFound
+}
+
+// region Docker Engine API engine -------------------------------------------------------------------------------------
+
+// dockerApiEngine executes the docker operations using the Docker Engine API
+type dockerApiEngine struct {
+	client  *http.Client
+	baseUrl string
+}
+
+// create the Docker Engine API client, supported hosts: unix://path, tcp://host:port and http(s)://host:port
+// (default: DOCKER_HOST environment variable or the local docker socket)
+func newDockerApiEngine(host string) (*dockerApiEngine, error) {
+	if len(host) == 0 {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if len(host) == 0 {
+		host = defaultDockerHost
+	}
+
+	uri, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host: %s: %s", host, err.Error())
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	engine := &dockerApiEngine{client: &http.Client{Transport: transport}}
+	switch uri.Scheme {
+	case "unix":
+		socket := uri.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		engine.baseUrl = "http://docker"
+	case "tcp", "http":
+		engine.baseUrl = "http://" + uri.Host
+	case "https":
+		engine.baseUrl = "https://" + uri.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host: %s", host)
+	}
+	return engine, nil
+}
+
+func (e *dockerApiEngine) name() string {
+	return "api"
+}
+
+// ping the docker engine
+func (e *dockerApiEngine) ping(ctx context.Context) error {
+	return e.call(ctx, http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// apiContainerConfig is the create container request
+type apiContainerConfig struct {
+	Image        string              `json:"Image"`
+	Hostname     string              `json:"Hostname,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Healthcheck  *apiHealthcheck     `json:"Healthcheck,omitempty"`
+	HostConfig   apiHostConfig       `json:"HostConfig"`
+}
+
+type apiHealthcheck struct {
+	Test     []string `json:"Test"`
+	Interval int64    `json:"Interval,omitempty"`
+	Timeout  int64    `json:"Timeout,omitempty"`
+	Retries  int      `json:"Retries,omitempty"`
+}
+
+type apiHostConfig struct {
+	PortBindings map[string][]apiPortBinding `json:"PortBindings,omitempty"`
+	Binds        []string                    `json:"Binds,omitempty"`
+	NetworkMode  string                      `json:"NetworkMode,omitempty"`
+}
+
+type apiPortBinding struct {
+	HostIp   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+// apiContainerState is the relevant part of the inspect container response
+type apiContainerState struct {
+	State struct {
+		Running bool `json:"Running"`
+		Health  *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// run creates and starts the container, the image is pulled if it does not exist
+func (e *dockerApiEngine) run(ctx context.Context, c *DockerContainer) error {
+	config := e.containerConfig(c)
+	query := url.Values{}
+	if len(c.name) > 0 {
+		query.Set("name", c.name)
+	}
+
+	var created struct {
+		Id string `json:"Id"`
+	}
+	err := e.call(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	if IsDockerNotFound(err) {
+		if err = e.pull(ctx, c.image); err != nil {
+			return err
+		}
+		err = e.call(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The first network is set by the network mode, connect the additional networks
+	for i := 1; i < len(c.networks); i++ {
+		body := map[string]string{"Container": created.Id}
+		if err = e.call(ctx, http.MethodPost, fmt.Sprintf("/networks/%s/connect", url.PathEscape(c.networks[i])), nil, body, nil); err != nil {
+			return err
+		}
+	}
+	return e.call(ctx, http.MethodPost, fmt.Sprintf("/containers/%s/start", created.Id), nil, nil, nil)
+}
+
+// build the create container request
+func (e *dockerApiEngine) containerConfig(c *DockerContainer) *apiContainerConfig {
+	config := &apiContainerConfig{Image: c.image, Labels: c.labels, Cmd: c.entryPoint}
+
+	// Expose ports if defined, otherwise, use host network
+	if len(c.ports) > 0 {
+		config.ExposedPorts = make(map[string]struct{})
+		config.HostConfig.PortBindings = make(map[string][]apiPortBinding)
+		for k, v := range c.ports {
+			port := v
+			if !strings.Contains(port, "/") {
+				port += "/tcp"
+			}
+			config.ExposedPorts[port] = struct{}{}
+			config.HostConfig.PortBindings[port] = append(config.HostConfig.PortBindings[port], apiPortBinding{HostPort: k})
+		}
+	} else {
+		config.Hostname = getMachineIP()
+	}
+
+	for k, v := range c.vars {
+		config.Env = append(config.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	for k, v := range c.volumes {
+		config.HostConfig.Binds = append(config.HostConfig.Binds, fmt.Sprintf("%s:%s", k, v))
+	}
+	if len(c.networks) > 0 {
+		config.HostConfig.NetworkMode = c.networks[0]
+	}
+	if c.health != nil {
+		config.Healthcheck = &apiHealthcheck{
+			Test:     []string{"CMD-SHELL", c.health.command},
+			Interval: int64(c.health.interval),
+			Timeout:  int64(c.health.timeout),
+			Retries:  c.health.retries,
+		}
+	}
+	return config
+}
+
+// pull the image, the pull progress stream is consumed until the pull completes
+func (e *dockerApiEngine) pull(ctx context.Context, image string) error {
+	query := url.Values{}
+	name, tag := image, "latest"
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		name, tag = image[:idx], image[idx+1:]
+	}
+	query.Set("fromImage", name)
+	query.Set("tag", tag)
+
+	resp, err := e.do(ctx, http.MethodPost, "/images/create", query, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var progress struct {
+			Error string `json:"error"`
+		}
+		if err = decoder.Decode(&progress); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(progress.Error) > 0 {
+			return &DockerError{StatusCode: resp.StatusCode, Message: progress.Error}
+		}
+	}
+}
+
+func (e *dockerApiEngine) stop(ctx context.Context, container string) error {
+	err := e.call(ctx, http.MethodPost, fmt.Sprintf("/containers/%s/stop", url.PathEscape(container)), nil, nil, nil)
+	var de *DockerError
+	if errors.As(err, &de) && de.StatusCode == http.StatusNotModified {
+		// Already stopped
+		return nil
+	}
+	return err
+}
+
+func (e *dockerApiEngine) remove(ctx context.Context, container string) error {
+	return e.call(ctx, http.MethodDelete, fmt.Sprintf("/containers/%s", url.PathEscape(container)), nil, nil, nil)
+}
+
+func (e *dockerApiEngine) exists(ctx context.Context, container string) (bool, error) {
+	_, err := e.inspect(ctx, container)
+	if IsDockerNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (e *dockerApiEngine) isRunning(ctx context.Context, container string) (bool, error) {
+	state, err := e.inspect(ctx, container)
+	if IsDockerNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return state.State.Running, nil
+}
+
+func (e *dockerApiEngine) health(ctx context.Context, container string) (string, error) {
+	state, err := e.inspect(ctx, container)
+	if err != nil {
+		return "", err
+	}
+	if state.State.Health == nil {
+		return "", fmt.Errorf("container %s has no health check", container)
+	}
+	return state.State.Health.Status, nil
+}
+
+func (e *dockerApiEngine) inspect(ctx context.Context, container string) (*apiContainerState, error) {
+	state := &apiContainerState{}
+	if err := e.call(ctx, http.MethodGet, fmt.Sprintf("/containers/%s/json", url.PathEscape(container)), nil, nil, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// logs returns the container stdout and stderr, the multiplexed stream is demultiplexed
+func (e *dockerApiEngine) logs(ctx context.Context, container string, follow bool) (io.ReadCloser, error) {
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}}
+	if follow {
+		query.Set("follow", "1")
+	}
+	resp, err := e.do(ctx, http.MethodGet, fmt.Sprintf("/containers/%s/logs", url.PathEscape(container)), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The stream is multiplexed unless the container has TTY (older API versions do not set the content type)
+	if resp.Header.Get("Content-Type") == "application/vnd.docker.raw-stream" {
+		return resp.Body, nil
+	}
+	return &demuxReader{body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+// call the API and decode the JSON response into the result (optional)
+func (e *dockerApiEngine) call(ctx context.Context, method, path string, query url.Values, body any, result any) error {
+	resp, err := e.do(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if result == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// execute the API request, non 2xx responses are returned as DockerError
+func (e *dockerApiEngine) do(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := e.baseUrl + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker engine request failed: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	de := &DockerError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &msg) == nil && len(msg.Message) > 0 {
+		de.Message = msg.Message
+	} else {
+		de.Message = strings.TrimSpace(string(data))
+	}
+	if len(de.Message) == 0 {
+		de.Message = http.StatusText(resp.StatusCode)
+	}
+	return nil, de
+}
+
+// demuxReader reads the payload of the multiplexed stdout / stderr stream (8 bytes header per frame)
+type demuxReader struct {
+	body      io.ReadCloser
+	reader    *bufio.Reader
+	remaining uint32
+}
+
+func (r *demuxReader) Read(p []byte) (int, error) {
+	for r.remaining == 0 {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(r.reader, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		r.remaining = binary.BigEndian.Uint32(header[4:])
+	}
+	if uint32(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= uint32(n)
+	return n, err
+}
+
+func (r *demuxReader) Close() error {
+	return r.body.Close()
+}
+
+// endregion
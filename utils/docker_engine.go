@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// dockerEngine executes the docker operations, implemented by the Docker Engine API client and by the docker CLI
+type dockerEngine interface {
+	name() string
+	run(ctx context.Context, c *DockerContainer) error
+	stop(ctx context.Context, container string) error
+	remove(ctx context.Context, container string) error
+	exists(ctx context.Context, container string) (bool, error)
+	isRunning(ctx context.Context, container string) (bool, error)
+	health(ctx context.Context, container string) (string, error)
+	logs(ctx context.Context, container string, follow bool) (io.ReadCloser, error)
+}
+
+// region Docker CLI engine --------------------------------------------------------------------------------------------
+
+// dockerCliEngine executes the docker operations using the docker shell command
+type dockerCliEngine struct {
+}
+
+func (e *dockerCliEngine) name() string {
+	return "cli"
+}
+
+// run the container in detached mode
+func (e *dockerCliEngine) run(ctx context.Context, c *DockerContainer) error {
+
+	// construct the docker shell command
+	args := make([]string, 0)
+	args = append(args, "run", "-d")
+
+	if len(c.name) > 0 {
+		args = append(args, "--name")
+		args = append(args, c.name)
+	}
+
+	// Expose ports if defined, otherwise, use host network
+	if len(c.ports) > 0 {
+		for k, v := range c.ports {
+			args = append(args, "-p")
+			args = append(args, fmt.Sprintf("%s:%s", k, v))
+		}
+	} else {
+		args = append(args, "-h")
+		args = append(args, getMachineIP())
+	}
+
+	// Add environment variables
+	for k, v := range c.vars {
+		args = append(args, "-e")
+		args = append(args, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	// Add metadata (labels)
+	for k, v := range c.labels {
+		args = append(args, "-l")
+		args = append(args, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	// Add networks
+	for _, network := range c.networks {
+		args = append(args, "--network", network)
+	}
+
+	// Add volume mounts
+	for k, v := range c.volumes {
+		args = append(args, "-v", fmt.Sprintf("%s:%s", k, v))
+	}
+
+	// Add health check
+	if c.health != nil {
+		args = append(args, "--health-cmd", c.health.command)
+		if c.health.interval > 0 {
+			args = append(args, "--health-interval", c.health.interval.String())
+		}
+		if c.health.timeout > 0 {
+			args = append(args, "--health-timeout", c.health.timeout.String())
+		}
+		if c.health.retries > 0 {
+			args = append(args, "--health-retries", strconv.Itoa(c.health.retries))
+		}
+	}
+
+	// Add docker image and entry point
+	args = append(args, c.image)
+	args = append(args, c.entryPoint...)
+
+	_, err := e.exec(ctx, args...)
+	return err
+}
+
+func (e *dockerCliEngine) stop(ctx context.Context, container string) error {
+	_, err := e.exec(ctx, "stop", container)
+	return err
+}
+
+func (e *dockerCliEngine) remove(ctx context.Context, container string) error {
+	_, err := e.exec(ctx, "rm", container)
+	return err
+}
+
+func (e *dockerCliEngine) exists(ctx context.Context, container string) (bool, error) {
+	out, err := e.exec(ctx, "ps", "-a", "--filter", "name=^/"+container+"$", "--format", "{{.Names}}")
+	if err != nil {
+		return false, err
+	}
+	return len(strings.TrimSpace(out)) > 0, nil
+}
+
+func (e *dockerCliEngine) isRunning(ctx context.Context, container string) (bool, error) {
+	out, err := e.exec(ctx, "ps", "--filter", "status=running", "--filter", "name=^/"+container+"$", "--format", "{{.Names}}")
+	if err != nil {
+		return false, err
+	}
+	return len(strings.TrimSpace(out)) > 0, nil
+}
+
+func (e *dockerCliEngine) health(ctx context.Context, container string) (string, error) {
+	out, err := e.exec(ctx, "inspect", "--format", "{{if .State.Health}}{{.State.Health.Status}}{{end}}", container)
+	if err != nil {
+		return "", err
+	}
+	status := strings.TrimSpace(out)
+	if len(status) == 0 {
+		return "", fmt.Errorf("container %s has no health check", container)
+	}
+	return status, nil
+}
+
+func (e *dockerCliEngine) logs(ctx context.Context, container string, follow bool) (io.ReadCloser, error) {
+	args := []string{"logs"}
+	if follow {
+		args = append(args, "--follow")
+	}
+	args = append(args, container)
+
+	reader, writer := io.Pipe()
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		_ = writer.CloseWithError(cmd.Wait())
+	}()
+	return &logsReader{PipeReader: reader, cmd: cmd}, nil
+}
+
+// execute the docker command, the error includes the command output
+func (e *dockerCliEngine) exec(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); len(msg) > 0 {
+			return "", fmt.Errorf("docker %s failed: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("docker %s failed: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// logsReader stops the docker logs command when closed
+type logsReader struct {
+	*io.PipeReader
+	cmd *exec.Cmd
+}
+
+func (r *logsReader) Close() error {
+	if r.cmd.Process != nil {
+		_ = r.cmd.Process.Kill()
+	}
+	return r.PipeReader.Close()
+}
+
+// endregion
@@ -1,13 +1,19 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"os/exec"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
+const defaultDockerTimeout = 2 * time.Minute
+
 // region Docker configuration object ----------------------------------------------------------------------------------
 
 // DockerContainer is used to construct docker container spec for the docker engine.
@@ -19,6 +25,19 @@ type DockerContainer struct {
 	labels     map[string]string // Container labels
 	entryPoint []string          // Entry point
 	autoRemove bool              // Automatically remove container when stopped (default: true)
+	networks   []string          // Networks to connect
+	volumes    map[string]string // Volume mounts (host path or volume name -> container path)
+	health     *healthCheck      // Health check command
+	timeout    time.Duration     // Timeout of the docker operations
+	engine     dockerEngine      // Docker engine executing the operations
+}
+
+// healthCheck is the container health check command configuration
+type healthCheck struct {
+	command  string
+	interval time.Duration
+	timeout  time.Duration
+	retries  int
 }
 
 // Name sets the container name.
@@ -81,115 +100,150 @@ func (c *DockerContainer) AutoRemove(value bool) *DockerContainer {
 	return c
 }
 
-// Run builds and run command
-func (c *DockerContainer) Run() error {
-
-	if c.IsRunning() {
-		return nil
-	}
-
-	// construct the docker shell command
-	command := "docker"
-	args := make([]string, 0)
-	args = append(args, "run")
+// Network connects the container to the network
+func (c *DockerContainer) Network(name string) *DockerContainer {
+	c.networks = append(c.networks, name)
+	return c
+}
 
-	if len(c.name) > 0 {
-		args = append(args, "--name")
-		args = append(args, c.name)
-	}
+// Volume adds a volume mount, the source is a host path or a volume name
+func (c *DockerContainer) Volume(source, target string) *DockerContainer {
+	c.volumes[source] = target
+	return c
+}
 
-	// Expose ports if defined, otherwise, use host network
-	if len(c.ports) > 0 {
-		for k, v := range c.ports {
-			args = append(args, "-p")
-			args = append(args, fmt.Sprintf("%s:%s", k, v))
-		}
-	} else {
-		args = append(args, "-h")
-		args = append(args, getMachineIP())
-	}
+// HealthCheck sets the health check command executed inside the container (e.g. pg_isready -U postgres),
+// zero interval, timeout or retries use the docker defaults
+func (c *DockerContainer) HealthCheck(command string, interval, timeout time.Duration, retries int) *DockerContainer {
+	c.health = &healthCheck{command: command, interval: interval, timeout: timeout, retries: retries}
+	return c
+}
 
-	// Add environment variables
-	if len(c.vars) > 0 {
-		for k, v := range c.vars {
-			args = append(args, "-e")
-			args = append(args, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
+// Timeout sets the timeout of the docker operations (default: 2 minutes, includes pulling the image)
+func (c *DockerContainer) Timeout(value time.Duration) *DockerContainer {
+	c.timeout = value
+	return c
+}
 
-	// Add metadata (labels)
-	if len(c.labels) > 0 {
-		for k, v := range c.labels {
-			args = append(args, "-l")
-			args = append(args, fmt.Sprintf("%s=%s", k, v))
-		}
-	}
+// Run builds and run command
+func (c *DockerContainer) Run() error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.RunContext(ctx)
+}
 
-	// Add docker image
-	if len(c.image) > 0 {
-		args = append(args, c.image)
-	} else {
+// RunContext creates and starts the container (the image is pulled if it does not exist)
+func (c *DockerContainer) RunContext(ctx context.Context) error {
+	if len(c.image) == 0 {
 		return fmt.Errorf("missing image field")
 	}
-
-	// Add entry point
-	if len(c.entryPoint) > 0 {
-		for _, v := range c.entryPoint {
-			args = append(args, v)
+	if len(c.name) > 0 {
+		if running, _ := c.engine.isRunning(ctx, c.name); running {
+			return nil
 		}
 	}
-
-	cmd := exec.Command(command, args...)
-	go cmd.Run()
-	return nil
+	return c.engine.run(ctx, c)
 }
 
 // Stop and kill container
 func (c *DockerContainer) Stop() error {
-	// construct the docker shell command
-	command := "docker"
-	args := make([]string, 0)
-	args = append(args, "stop")
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.StopContext(ctx)
+}
 
-	if len(c.name) > 0 {
-		args = append(args, c.name)
-	} else {
+// StopContext stops and removes the container
+func (c *DockerContainer) StopContext(ctx context.Context) error {
+	if len(c.name) == 0 {
 		return fmt.Errorf("missing container name")
 	}
-
-	cmd := exec.Command(command, args...)
-	if er := cmd.Run(); er != nil {
-		return er
+	if err := c.engine.stop(ctx, c.name); err != nil {
+		return err
 	}
-
-	args2 := make([]string, 0)
-	args2 = append(args2, "rm")
-	args2 = append(args2, c.name)
-
-	cmd2 := exec.Command(command, args2...)
-	return cmd2.Run()
+	return c.engine.remove(ctx, c.name)
 }
 
 // Exists return true if the container exists
 func (c *DockerContainer) Exists() bool {
-	cmd := fmt.Sprintf("docker ps -a | grep '%s'", c.name)
-	out, err := exec.Command("bash", "-c", cmd).Output()
-	if err != nil {
-		return false
-	} else {
-		return strings.Contains(string(out), c.name)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	exists, _ := c.engine.exists(ctx, c.name)
+	return exists
 }
 
 // IsRunning return true if the container exists and running
 func (c *DockerContainer) IsRunning() bool {
-	cmd := fmt.Sprintf(`docker ps -f "status=running" | grep '%s'`, c.name)
-	out, err := exec.Command("bash", "-c", cmd).Output()
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	running, _ := c.engine.isRunning(ctx, c.name)
+	return running
+}
+
+// WaitForPort waits until the host port accepts connections or the timeout expires
+func (c *DockerContainer) WaitForPort(port string, timeout time.Duration) error {
+	address := net.JoinHostPort("127.0.0.1", port)
+	return waitFor(timeout, func(ctx context.Context) (bool, error) {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err != nil {
+			return false, nil
+		}
+		_ = conn.Close()
+		return true, nil
+	}, fmt.Sprintf("port %s is not ready", port))
+}
+
+// WaitForLog waits until the container log matches the pattern (regular expression) or the timeout expires
+func (c *DockerContainer) WaitForLog(pattern string, timeout time.Duration) error {
+	re, err := regexp.Compile(pattern)
 	if err != nil {
-		return false
-	} else {
-		return strings.Contains(string(out), c.name)
+		return err
 	}
+	if len(c.name) == 0 {
+		return fmt.Errorf("missing container name")
+	}
+	return waitFor(timeout, func(ctx context.Context) (bool, error) {
+		reader, er := c.engine.logs(ctx, c.name, false)
+		if er != nil {
+			return false, nil
+		}
+		defer func() {
+			_ = reader.Close()
+		}()
+		out, _ := io.ReadAll(reader)
+		return re.Match(out), nil
+	}, fmt.Sprintf("container %s log does not match: %s", c.name, pattern))
+}
+
+// WaitForHealthy waits until the container health check reports healthy or the timeout expires
+func (c *DockerContainer) WaitForHealthy(timeout time.Duration) error {
+	if len(c.name) == 0 {
+		return fmt.Errorf("missing container name")
+	}
+	return waitFor(timeout, func(ctx context.Context) (bool, error) {
+		status, er := c.engine.health(ctx, c.name)
+		if er != nil {
+			return false, nil
+		}
+		if status == "unhealthy" {
+			return false, fmt.Errorf("container %s is unhealthy", c.name)
+		}
+		return status == "healthy", nil
+	}, fmt.Sprintf("container %s is not healthy", c.name))
+}
+
+// Health returns the container health status (starting, healthy or unhealthy)
+func (c *DockerContainer) Health() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.engine.health(ctx, c.name)
+}
+
+// Logs streams the container logs (stdout and stderr) until the context is canceled or the reader is closed
+func (c *DockerContainer) Logs(ctx context.Context) (io.ReadCloser, error) {
+	if len(c.name) == 0 {
+		return nil, fmt.Errorf("missing container name")
+	}
+	return c.engine.logs(ctx, c.name, true)
 }
 
 // endregion
@@ -197,15 +251,17 @@ func (c *DockerContainer) IsRunning() bool {
 // region Singleton Pattern --------------------------------------------------------------------------------------------
 
 type dockerUtils struct {
+	host       string
+	engineOnce sync.Once
+	dockerEng  dockerEngine
 }
 
 var onlyOnce sync.Once
 var dockerUtilsSingleton *dockerUtils = nil
 
-// DockerUtils is a simple utility to execute docker commands using shell
-// This utility does not us the docker client library (due to compatability issues) but instead utilizes
-// the shell command executor (from os/exec package).
-// The pre-requisite
+// DockerUtils is a simple utility to execute docker commands.
+// The utility uses the Docker Engine API (DOCKER_HOST or the local docker socket), if the engine is not reachable and
+// the docker CLI is installed, the shell command executor (from os/exec package) is used instead.
 func DockerUtils() (du *dockerUtils) {
 	onlyOnce.Do(func() {
 		dockerUtilsSingleton = &dockerUtils{}
@@ -213,6 +269,12 @@ func DockerUtils() (du *dockerUtils) {
 	return dockerUtilsSingleton
 }
 
+// DockerUtilsWithHost creates docker utility bound to the Docker Engine API of the host
+// (unix:///var/run/docker.sock, tcp://host:port), the docker CLI is not used
+func DockerUtilsWithHost(host string) (du *dockerUtils) {
+	return &dockerUtils{host: host}
+}
+
 // endregion
 
 // region Docker utilities ---------------------------------------------------------------------------------------------
@@ -226,6 +288,10 @@ func (c *dockerUtils) CreateContainer(image string) *DockerContainer {
 		labels:     make(map[string]string),
 		entryPoint: make([]string, 0),
 		autoRemove: true,
+		networks:   make([]string, 0),
+		volumes:    make(map[string]string),
+		timeout:    defaultDockerTimeout,
+		engine:     c.engine(),
 	}
 }
 
@@ -237,30 +303,49 @@ func (c *dockerUtils) StopContainer(container string) error {
 		return fmt.Errorf("missing container name")
 	}
 
-	// construct the docker stop shell command
-	command := "docker"
-	args := make([]string, 0)
-	args = append(args, "stop", container)
-	_ = exec.Command(command, args...).Run()
-
-	// construct the docker rm shell command
-	args = nil
-	args = make([]string, 0)
-	args = append(args, "rm", container)
-	_ = exec.Command(command, args...).Run()
-
+	ctx, cancel := context.WithTimeout(context.Background(), defaultDockerTimeout)
+	defer cancel()
+	_ = c.engine().stop(ctx, container)
+	_ = c.engine().remove(ctx, container)
 	return nil
 }
 
 // ContainerExists return true if the container exists
 func (c *dockerUtils) ContainerExists(container string) bool {
-	cmd := fmt.Sprintf("docker ps -a | grep '%s'", container)
-	out, err := exec.Command("bash", "-c", cmd).Output()
-	if err != nil {
-		return false
-	} else {
-		return strings.Contains(string(out), container)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultDockerTimeout)
+	defer cancel()
+	exists, _ := c.engine().exists(ctx, container)
+	return exists
+}
+
+// Engine returns the docker engine in use: api (Docker Engine API) or cli (docker shell command)
+func (c *dockerUtils) Engine() string {
+	return c.engine().name()
+}
+
+// select the docker engine, the docker CLI is used only when the engine API is not reachable and the CLI is installed
+func (c *dockerUtils) engine() dockerEngine {
+	c.engineOnce.Do(func() {
+		api, err := newDockerApiEngine(c.host)
+		if err == nil && len(c.host) > 0 {
+			c.dockerEng = api
+			return
+		}
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), dockerPingTimeout)
+			defer cancel()
+			if err = api.ping(ctx); err == nil {
+				c.dockerEng = api
+				return
+			}
+		}
+		if _, er := exec.LookPath("docker"); er == nil || api == nil {
+			c.dockerEng = &dockerCliEngine{}
+			return
+		}
+		c.dockerEng = api
+	})
+	return c.dockerEng
 }
 
 // endregion
@@ -294,4 +379,24 @@ func getMachineIP() (result string) {
 	return results[len(results)-1]
 }
 
+// waitFor polls the condition every 200ms until it is met, fails or the timeout expires
+func waitFor(timeout time.Duration, condition func(ctx context.Context) (bool, error), message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := condition(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s after %s", message, timeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
 // endregion
@@ -33,6 +33,13 @@ func (t *hashUtils) Hash(s string) uint32 {
 	return h.Sum32()
 }
 
+// Hash64 hashes a string using 64 bit FNV hash
+func (t *hashUtils) Hash64(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
+
 // HashStringToString returns a string containing the base64-encoded SHA-1 hash
 // of the input string.
 func (t *hashUtils) HashStringToString(s string) string {
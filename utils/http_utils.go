@@ -18,6 +18,8 @@ type HttpUtilsStruct struct {
 	body       string
 	headers    map[string]string
 	TimeoutSec int
+	signKeyId  string
+	signSecret []byte
 }
 
 // HttpUtils is a factory method that acts as a static member
@@ -56,6 +58,13 @@ func (u *HttpUtilsStruct) WithTimeout(timeout int) *HttpUtilsStruct {
 	return u
 }
 
+// WithSignature signs the request using HMAC with the shared secret (see SignatureUtils)
+func (u *HttpUtilsStruct) WithSignature(keyId string, secret []byte) *HttpUtilsStruct {
+	u.signKeyId = keyId
+	u.signSecret = secret
+	return u
+}
+
 func (u *HttpUtilsStruct) Send() (*http.Response, error) {
 
 	parsedUrl, err := url.Parse(u.url)
@@ -100,6 +109,12 @@ func (u *HttpUtilsStruct) Send() (*http.Response, error) {
 		req.Header.Set(k, v)
 	}
 
+	if len(u.signSecret) > 0 {
+		if err = SignatureUtils().SignRequest(req, u.signKeyId, u.signSecret); err != nil {
+			return nil, err
+		}
+	}
+
 	if res, err = http.DefaultClient.Do(req); err != nil {
 		return nil, err
 	}
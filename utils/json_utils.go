@@ -1,6 +1,12 @@
 package utils
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
 	. "github.com/go-yaaf/yaaf-common/entity"
 )
 
@@ -59,3 +65,62 @@ func (t *jsonUtils) FromJson(factory EntityFactory, raw map[string]any) (entity
 }
 
 // endregion
+
+// region Streaming methods --------------------------------------------------------------------------------------------
+
+// StreamEncode writes the entities from the channel to the writer as newline delimited JSON (one entity per line) until
+// the channel is closed, returns the number of entities written
+// On error the remaining entities in the channel are drained so the producer is not blocked
+func (t *jsonUtils) StreamEncode(w io.Writer, entities <-chan Entity) (count int64, err error) {
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	for entity := range entities {
+		if err != nil {
+			continue
+		}
+		if err = encoder.Encode(entity); err == nil {
+			count++
+		}
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// StreamDecode reads newline delimited JSON (one entity per line) from the reader and sends the entities to the
+// returned channel. Both channels are closed when the reader is exhausted, the errors channel receives at most one
+// error (invalid line or read error) after which the decoding stops. Empty lines are skipped
+// The entities channel must be consumed until it is closed
+func (t *jsonUtils) StreamDecode(r io.Reader, factory EntityFactory) (<-chan Entity, <-chan error) {
+	entities := make(chan Entity, 64)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(entities)
+
+		reader := bufio.NewReader(r)
+		for line := 1; ; line++ {
+			data, err := reader.ReadBytes('\n')
+			if data = bytes.TrimSpace(data); len(data) > 0 {
+				entity := factory()
+				if fe := json.Unmarshal(data, entity); fe != nil {
+					errs <- fmt.Errorf("line %d: %w", line, fe)
+					return
+				}
+				entities <- entity
+			}
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	return entities, errs
+}
+
+// endregion
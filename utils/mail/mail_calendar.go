@@ -0,0 +1,145 @@
+package mail
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarMethod is the iCalendar method of the invitation
+type CalendarMethod string
+
+const (
+	CalendarMethodRequest CalendarMethod = "REQUEST" // Invite the attendees (or update the event)
+	CalendarMethodCancel  CalendarMethod = "CANCEL"  // Cancel the event
+)
+
+// CalendarEvent is an iCalendar (.ics) event invitation sent with the message
+type CalendarEvent struct {
+	// Unique event id, use the same id to update or cancel the event
+	Uid string
+
+	// Invitation method (default: CalendarMethodRequest)
+	Method CalendarMethod
+
+	// Event sequence, increment when updating the event
+	Sequence int
+
+	// Event title
+	Summary string
+
+	// Event description
+	Description string
+
+	// Event location
+	Location string
+
+	// Event start time
+	Start time.Time
+
+	// Event end time
+	End time.Time
+
+	// Organizer mail address (format: name <user@mail.com>)
+	Organizer string
+
+	// Attendees mail addresses (format: name <user@mail.com>)
+	Attendees []string
+}
+
+// method returns the invitation method
+func (e *CalendarEvent) method() CalendarMethod {
+	if len(e.Method) == 0 {
+		return CalendarMethodRequest
+	}
+	return e.Method
+}
+
+// ContentType returns the MIME type of the invitation
+func (e *CalendarEvent) ContentType() string {
+	return fmt.Sprintf("text/calendar; method=%s", e.method())
+}
+
+// ICS returns the iCalendar content of the invitation
+func (e *CalendarEvent) ICS() string {
+	status := "CONFIRMED"
+	if e.method() == CalendarMethodCancel {
+		status = "CANCELLED"
+	}
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//go-yaaf//yaaf-common//EN",
+		"CALSCALE:GREGORIAN",
+		fmt.Sprintf("METHOD:%s", e.method()),
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:%s", e.Uid),
+		fmt.Sprintf("SEQUENCE:%d", e.Sequence),
+		fmt.Sprintf("DTSTAMP:%s", icsTime(time.Now())),
+		fmt.Sprintf("DTSTART:%s", icsTime(e.Start)),
+		fmt.Sprintf("DTEND:%s", icsTime(e.End)),
+		fmt.Sprintf("STATUS:%s", status),
+		fmt.Sprintf("SUMMARY:%s", icsText(e.Summary)),
+	}
+	if len(e.Description) > 0 {
+		lines = append(lines, fmt.Sprintf("DESCRIPTION:%s", icsText(e.Description)))
+	}
+	if len(e.Location) > 0 {
+		lines = append(lines, fmt.Sprintf("LOCATION:%s", icsText(e.Location)))
+	}
+	if len(e.Organizer) > 0 {
+		lines = append(lines, icsAddress("ORGANIZER", e.Organizer, ""))
+	}
+	for _, attendee := range e.Attendees {
+		lines = append(lines, icsAddress("ATTENDEE", attendee, ";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE"))
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	folded := make([]string, 0, len(lines))
+	for _, line := range lines {
+		folded = append(folded, icsFold(line))
+	}
+	return strings.Join(folded, "\r\n") + "\r\n"
+}
+
+// format time in UTC
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escape text value
+func icsText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+// format organizer / attendee property
+func icsAddress(property, addr, params string) string {
+	a := parseMailAddress(addr)
+	if len(a.Name) > 0 {
+		params = fmt.Sprintf(";CN=\"%s\"%s", strings.ReplaceAll(a.Name, `"`, ""), params)
+	}
+	return fmt.Sprintf("%s%s:mailto:%s", property, params, a.Email)
+}
+
+// fold lines longer than 75 octets (RFC 5545)
+func icsFold(line string) string {
+	if len(line) <= 75 {
+		return line
+	}
+	var sb strings.Builder
+	limit := 75
+	for len(line) > limit {
+		// Do not split multibyte characters
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		sb.WriteString(line[:cut])
+		sb.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	sb.WriteString(line)
+	return sb.String()
+}
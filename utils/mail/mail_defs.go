@@ -4,14 +4,30 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 )
 
 type TemplateName string
 
+// TLSMode defines how the mail client secures the connection to the mail relay
+type TLSMode string
+
+const (
+	// TLSModeAuto uses STARTTLS when offered by the server (implicit TLS for port 465), when UseTLS is set STARTTLS is required
+	TLSModeAuto TLSMode = ""
+	// TLSModeNone plain connection, STARTTLS is not used
+	TLSModeNone TLSMode = "none"
+	// TLSModeStartTLS plain connection upgraded using STARTTLS, fails if the server does not support STARTTLS
+	TLSModeStartTLS TLSMode = "starttls"
+	// TLSModeImplicit TLS encrypted connection (SMTPS)
+	TLSModeImplicit TLSMode = "tls"
+)
+
 // MailConfig Configure mail client parameters
 type MailConfig struct {
 
-	// Mail relay URI (type://host:port)
+	// Mail relay URI (type://host:port), supported types: smtp, smtps, sendgrid, ses and mailgun
+	// (e.g. sendgrid://api.sendgrid.com, ses://us-east-1, mailgun://api.mailgun.net/mg.example.com)
 	MailRelayUri string
 
 	// Mail Relay User
@@ -22,6 +38,24 @@ type MailConfig struct {
 
 	// Flag to use TLS encrypted connection
 	UseTLS bool
+
+	// TLS mode (default: TLSModeAuto), the smtps:// scheme implies TLSModeImplicit
+	TLSMode TLSMode
+
+	// Skip the server certificate verification (for development only)
+	InsecureSkipVerify bool
+
+	// PEM file of CA certificates to verify the server certificate (default: system CAs)
+	CACertFile string
+
+	// Server name to verify the server certificate (default: the mail relay host)
+	TLSServerName string
+
+	// Timeout to connect the mail relay (default: 10 seconds)
+	DialTimeout time.Duration
+
+	// Timeout of sending a message including the SMTP conversation (default: 1 minute)
+	SendTimeout time.Duration
 }
 
 // IMailClient Mail client interface
@@ -43,6 +77,9 @@ type MailMessageAttachment struct {
 
 	// Base64 content of the file (ignore this field)
 	Base64Content string
+
+	// Content ID of an inline attachment referenced from the HTML body as cid:<ContentId> (default: the file base name)
+	ContentId string
 }
 
 // IMailMessage Mail message interface
@@ -54,6 +91,8 @@ type IMailMessage interface {
 	Body(body string) IMailMessage
 	HtmlBody(html string) IMailMessage
 	Attachments(attachments []MailMessageAttachment) IMailMessage
+	Inline(images []MailMessageAttachment) IMailMessage
+	Calendar(event *CalendarEvent) IMailMessage
 	Send() error
 }
 
@@ -71,9 +110,19 @@ func NewMailClient(config MailConfig) (IMailClient, error) {
 
 	scheme := strings.ToLower(uri.Scheme)
 
-	if scheme == "smtp" {
-		return newSmtpMailClient(uri.Hostname(), uri.Port(), config.MailRelayUser, config.MailRelayPassword, config.UseTLS), nil
-	} else {
+	switch scheme {
+	case "smtp", "smtps":
+		if scheme == "smtps" {
+			config.TLSMode = TLSModeImplicit
+		}
+		return newSmtpMailClient(uri.Hostname(), uri.Port(), config)
+	case "sendgrid":
+		return newSendGridMailClient(uri.Hostname(), uri.Host, config)
+	case "ses":
+		return newSesMailClient(uri.Hostname(), uri.Host, uri.Query().Get("region"), config)
+	case "mailgun":
+		return newMailgunMailClient(uri.Hostname(), uri.Host, uri.Path, config)
+	default:
 		return nil, fmt.Errorf("unsupported mail type: %s", scheme)
 	}
 }
@@ -0,0 +1,417 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+)
+
+// ErrDispatcherQueueFull is returned when the dispatcher queue is full
+var ErrDispatcherQueueFull = errors.New("mail dispatcher queue is full")
+
+// ErrDispatcherClosed is returned when sending a message to a closed (or not started) dispatcher
+var ErrDispatcherClosed = errors.New("mail dispatcher is closed")
+
+// DeliveryStatus is the delivery status of a message sent by the dispatcher
+type DeliveryStatus string
+
+const (
+	DeliveryStatusSent     DeliveryStatus = "sent"     // The message was delivered to the mail provider
+	DeliveryStatusRetrying DeliveryStatus = "retrying" // The delivery failed and will be retried
+	DeliveryStatusFailed   DeliveryStatus = "failed"   // The delivery failed after all the retries
+	DeliveryStatusDropped  DeliveryStatus = "dropped"  // The message was not delivered before the dispatcher stopped
+)
+
+// DeliveryReport reports the delivery status of a message
+type DeliveryReport struct {
+	Id       string         // Message id returned by Send
+	Message  IMailMessage   // The message
+	Provider string         // Mail provider (e.g. smtp)
+	Status   DeliveryStatus // Delivery status
+	Attempt  int            // Delivery attempt (1 for the first attempt)
+	Error    error          // Delivery error
+}
+
+// RateLimit limits the number of messages per period
+type RateLimit struct {
+	Limit  int           // Max number of messages per period (0 for no limit)
+	Period time.Duration // Period (default: 1 second)
+}
+
+// DispatcherOptions configures the mail dispatcher
+type DispatcherOptions struct {
+	QueueSize  int                  // Max number of queued messages (default: 1000)
+	Workers    int                  // Number of concurrent deliveries (default: 1)
+	MaxRetries int                  // Max number of retries of a failed delivery (default: 0, no retries)
+	Backoff    time.Duration        // Initial delay before retry, doubled after each retry (default: 1 second)
+	MaxBackoff time.Duration        // Max delay before retry (default: 1 minute)
+	RateLimit  RateLimit            // Rate limit of each provider
+	RateLimits map[string]RateLimit // Rate limit by provider name (e.g. smtp), overrides RateLimit
+	OnStatus   func(report DeliveryReport)
+}
+
+const (
+	defaultDispatcherQueueSize  = 1000
+	defaultDispatcherBackoff    = time.Second
+	defaultDispatcherMaxBackoff = time.Minute
+	defaultProvider             = "default"
+)
+
+// providerMessage is implemented by the messages of the built-in mail clients to report their provider
+type providerMessage interface {
+	provider() string
+}
+
+// region Mail Dispatcher ----------------------------------------------------------------------------------------------
+
+// MailDispatcher delivers messages asynchronously with retries and rate limiting, so request paths don't block on the
+// mail provider:
+//
+//	dispatcher := mail.NewMailDispatcher(mail.DispatcherOptions{MaxRetries: 3, RateLimit: mail.RateLimit{Limit: 10}})
+//	_ = dispatcher.Start(ctx)
+//	defer dispatcher.Close()
+//	id, err := dispatcher.Send(client.CreateHtmlMessage().To(to).Subject(subject).HtmlBody(html))
+type MailDispatcher struct {
+	options  DispatcherOptions
+	queue    chan *delivery
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+	retries  map[*delivery]*time.Timer // Messages waiting for retry
+	waiting  int                       // Retried messages waiting for free space in the queue
+	ctx      context.Context
+	cancel   context.CancelFunc
+	workers  sync.WaitGroup
+	pending  sync.WaitGroup // Accepted messages which are not completed yet
+	started  bool
+	closed   bool
+	dropped  bool // The undelivered messages were dropped by Stop
+}
+
+// delivery is a queued message
+type delivery struct {
+	id       string
+	message  IMailMessage
+	provider string
+	attempt  int
+}
+
+// NewMailDispatcher creates a mail dispatcher
+func NewMailDispatcher(options DispatcherOptions) *MailDispatcher {
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultDispatcherQueueSize
+	}
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaultDispatcherBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultDispatcherMaxBackoff
+	}
+	return &MailDispatcher{
+		options:  options,
+		queue:    make(chan *delivery, options.QueueSize),
+		limiters: make(map[string]*rateLimiter),
+		retries:  make(map[*delivery]*time.Timer),
+	}
+}
+
+// Start the delivery workers, the workers are stopped when the context is canceled or Stop is called
+func (d *MailDispatcher) Start(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.started {
+		return fmt.Errorf("mail dispatcher already started")
+	}
+	d.started = true
+
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	for i := 0; i < d.options.Workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+	return nil
+}
+
+// Send queues the message for delivery and returns the message id, does not block if the queue is full
+func (d *MailDispatcher) Send(message IMailMessage) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.started || d.closed || d.ctx.Err() != nil {
+		return "", ErrDispatcherClosed
+	}
+
+	item := &delivery{id: entity.NanoID(), message: message, provider: defaultProvider}
+	if pm, ok := message.(providerMessage); ok {
+		item.provider = pm.provider()
+	}
+
+	d.pending.Add(1)
+	select {
+	case d.queue <- item:
+		return item.id, nil
+	default:
+		d.pending.Done()
+		return "", ErrDispatcherQueueFull
+	}
+}
+
+// Stop accepting messages, wait for the queued messages (including retries) to be delivered or until the timeout
+// expires (0 for no timeout), messages which were not delivered are reported as dropped
+func (d *MailDispatcher) Stop(timeout time.Duration) error {
+	d.mu.Lock()
+	if !d.started || d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+
+	var err error
+	if timeout <= 0 {
+		select {
+		case <-done:
+		case <-d.ctx.Done():
+		}
+	} else {
+		select {
+		case <-done:
+		case <-d.ctx.Done():
+		case <-time.After(timeout):
+			err = fmt.Errorf("mail dispatcher did not deliver all the messages within %s", timeout)
+		}
+	}
+
+	d.cancel()
+	d.workers.Wait()
+	d.drop()
+	return err
+}
+
+// Close stops the dispatcher after delivering the queued messages (implements io.Closer)
+func (d *MailDispatcher) Close() error {
+	return d.Stop(0)
+}
+
+// Pending returns the number of messages waiting for delivery (including the messages waiting for retry)
+func (d *MailDispatcher) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.queue) + len(d.retries) + d.waiting
+}
+
+// deliver the queued messages until the dispatcher is stopped
+func (d *MailDispatcher) work() {
+	defer d.workers.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case item := <-d.queue:
+			if err := d.limiter(item.provider).wait(d.ctx); err != nil {
+				// Stopped while waiting for the rate limiter
+				d.report(item, DeliveryStatusDropped, ErrDispatcherClosed)
+				d.pending.Done()
+				return
+			}
+			d.deliver(item)
+		}
+	}
+}
+
+// deliver a single message and schedule a retry on failure
+func (d *MailDispatcher) deliver(item *delivery) {
+	item.attempt += 1
+	err := item.message.Send()
+	if err == nil {
+		d.report(item, DeliveryStatusSent, nil)
+		d.pending.Done()
+		return
+	}
+
+	if item.attempt > d.options.MaxRetries {
+		d.report(item, DeliveryStatusFailed, err)
+		d.pending.Done()
+		return
+	}
+
+	d.report(item, DeliveryStatusRetrying, err)
+	backoff := d.options.Backoff << (item.attempt - 1)
+	if backoff <= 0 || backoff > d.options.MaxBackoff {
+		backoff = d.options.MaxBackoff
+	}
+	d.mu.Lock()
+	d.retries[item] = time.AfterFunc(backoff, func() {
+		d.mu.Lock()
+		delete(d.retries, item)
+		d.mu.Unlock()
+		d.requeue(item)
+	})
+	d.mu.Unlock()
+}
+
+// put back the item to the queue (waits for free space), the item is dropped if the dispatcher is stopped
+func (d *MailDispatcher) requeue(item *delivery) {
+	d.mu.Lock()
+	if d.ctx.Err() != nil {
+		// The retry timer fired after Stop, the item would never be delivered
+		d.mu.Unlock()
+		d.report(item, DeliveryStatusDropped, ErrDispatcherClosed)
+		d.pending.Done()
+		return
+	}
+	select {
+	case d.queue <- item:
+		d.mu.Unlock()
+		return
+	default:
+		d.waiting += 1
+		d.mu.Unlock()
+	}
+
+	go func() {
+		select {
+		case d.queue <- item:
+		case <-d.ctx.Done():
+			d.report(item, DeliveryStatusDropped, d.ctx.Err())
+			d.pending.Done()
+		}
+
+		d.mu.Lock()
+		d.waiting -= 1
+		dropped := d.dropped
+		d.mu.Unlock()
+
+		// The item was queued after Stop drained the queue
+		if dropped {
+			d.drainQueue()
+		}
+	}()
+}
+
+// report the queued messages and the messages waiting for retry which were not delivered
+func (d *MailDispatcher) drop() {
+	d.mu.Lock()
+	for item, timer := range d.retries {
+		if timer.Stop() {
+			d.report(item, DeliveryStatusDropped, ErrDispatcherClosed)
+			d.pending.Done()
+		}
+		delete(d.retries, item)
+	}
+	d.dropped = true
+	d.mu.Unlock()
+
+	d.drainQueue()
+}
+
+// report the queued messages as dropped
+func (d *MailDispatcher) drainQueue() {
+	for {
+		select {
+		case item := <-d.queue:
+			d.report(item, DeliveryStatusDropped, ErrDispatcherClosed)
+			d.pending.Done()
+		default:
+			return
+		}
+	}
+}
+
+// report the delivery status
+func (d *MailDispatcher) report(item *delivery, status DeliveryStatus, err error) {
+	if d.options.OnStatus == nil {
+		return
+	}
+	d.options.OnStatus(DeliveryReport{
+		Id:       item.id,
+		Message:  item.message,
+		Provider: item.provider,
+		Status:   status,
+		Attempt:  item.attempt,
+		Error:    err,
+	})
+}
+
+// get the rate limiter of the provider
+func (d *MailDispatcher) limiter(provider string) *rateLimiter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if l, ok := d.limiters[provider]; ok {
+		return l
+	}
+	rate := d.options.RateLimit
+	if r, ok := d.options.RateLimits[provider]; ok {
+		rate = r
+	}
+	l := newRateLimiter(rate)
+	d.limiters[provider] = l
+	return l
+}
+
+// endregion
+
+// region Rate Limiter -------------------------------------------------------------------------------------------------
+
+// rateLimiter spaces the deliveries evenly over the period
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate RateLimit) *rateLimiter {
+	if rate.Limit <= 0 {
+		return &rateLimiter{}
+	}
+	if rate.Period <= 0 {
+		rate.Period = time.Second
+	}
+	return &rateLimiter{interval: rate.Period / time.Duration(rate.Limit)}
+}
+
+// wait for the next delivery slot or until the context is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// endregion
@@ -0,0 +1,217 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// region HTTP Mail Message --------------------------------------------------------------------------------------------
+
+// httpMailProvider is implemented by the mail clients of the HTTP API providers
+type httpMailProvider interface {
+	name() string
+	send(m *httpMailMessage) error
+}
+
+// HTTP API mail message implementation (shared by the SendGrid, SES and Mailgun clients)
+type httpMailMessage struct {
+	client      httpMailProvider
+	from        string
+	to          []string
+	cc          []string
+	subject     string
+	body        string
+	html        string
+	mime        string
+	template    TemplateName
+	attachments []MailMessageAttachment
+	inline      []MailMessageAttachment
+	event       *CalendarEvent
+	variables   map[string]string
+}
+
+func newHttpMailMessage(client httpMailProvider, mime string) *httpMailMessage {
+	return &httpMailMessage{
+		client: client,
+		to:     make([]string, 0),
+		cc:     make([]string, 0),
+		mime:   mime,
+	}
+}
+
+// From Set sender mail address
+func (m *httpMailMessage) From(from string) IMailMessage {
+	m.from = from
+	return m
+}
+
+// To Set recipients mail addresses
+func (m *httpMailMessage) To(to []string) IMailMessage {
+	m.to = to
+	return m
+}
+
+// Cc Set cc list mail addresses
+func (m *httpMailMessage) Cc(cc []string) IMailMessage {
+	m.cc = cc
+	return m
+}
+
+// Subject Set subject
+func (m *httpMailMessage) Subject(subject string) IMailMessage {
+	m.subject = subject
+	return m
+}
+
+// Body Set body
+func (m *httpMailMessage) Body(body string) IMailMessage {
+	m.body = body
+	return m
+}
+
+// HtmlBody Set HTML Body
+func (m *httpMailMessage) HtmlBody(html string) IMailMessage {
+	m.html = html
+	return m
+}
+
+// Attachments set list of message attachments
+func (m *httpMailMessage) Attachments(attachments []MailMessageAttachment) IMailMessage {
+	m.attachments = attachments
+	return m
+}
+
+// Inline set list of inline attachments (e.g. images) referenced from the HTML body by their content id
+func (m *httpMailMessage) Inline(images []MailMessageAttachment) IMailMessage {
+	m.inline = images
+	return m
+}
+
+// Calendar set calendar event invitation
+func (m *httpMailMessage) Calendar(event *CalendarEvent) IMailMessage {
+	m.event = event
+	return m
+}
+
+// Send mail message
+func (m *httpMailMessage) Send() error {
+	return m.client.send(m)
+}
+
+// provider returns the mail provider name
+func (m *httpMailMessage) provider() string {
+	return m.client.name()
+}
+
+// textBody returns the plain text body (empty for HTML messages)
+func (m *httpMailMessage) textBody() string {
+	if m.mime == "text/html" {
+		return ""
+	}
+	return m.body
+}
+
+// htmlBody returns the HTML body (empty for non HTML messages)
+func (m *httpMailMessage) htmlBody() string {
+	if m.mime == "text/html" {
+		return m.html
+	}
+	return ""
+}
+
+// resolvedAttachments returns the attachments with their content and content type
+func (m *httpMailMessage) resolvedAttachments() ([]MailMessageAttachment, error) {
+	return resolveAttachments(m.attachments)
+}
+
+// resolvedInline returns the inline attachments with their content, content type and content id
+func (m *httpMailMessage) resolvedInline() ([]MailMessageAttachment, error) {
+	return resolveAttachments(m.inline)
+}
+
+// calendarAttachment returns the calendar invitation as attachment
+func (m *httpMailMessage) calendarAttachment() []MailMessageAttachment {
+	if m.event == nil {
+		return nil
+	}
+	return []MailMessageAttachment{{
+		FileName:      "invite.ics",
+		ContentType:   m.event.ContentType(),
+		Base64Content: base64.StdEncoding.EncodeToString([]byte(m.event.ICS())),
+	}}
+}
+
+// resolve the attachments content, content type and content id, file names are replaced by the base names
+func resolveAttachments(list []MailMessageAttachment) ([]MailMessageAttachment, error) {
+	result := make([]MailMessageAttachment, 0, len(list))
+	for _, att := range list {
+		if len(att.Base64Content) == 0 {
+			content, err := os.ReadFile(att.FileName)
+			if err != nil {
+				return nil, fmt.Errorf("read attachment %s failed: %s", att.FileName, err.Error())
+			}
+			att.Base64Content = base64.StdEncoding.EncodeToString(content)
+		}
+		if len(att.ContentType) == 0 {
+			att.ContentType = mime.TypeByExtension(filepath.Ext(att.FileName))
+			if len(att.ContentType) == 0 {
+				att.ContentType = "application/octet-stream"
+			}
+		}
+		att.FileName = filepath.Base(att.FileName)
+		if len(att.ContentId) == 0 {
+			att.ContentId = att.FileName
+		}
+		result = append(result, att)
+	}
+	return result, nil
+}
+
+// endregion
+
+// region HTTP Transport -----------------------------------------------------------------------------------------------
+
+// create the HTTP client of the mail provider API
+func newMailHttpClient(host string, config MailConfig) (*http.Client, error) {
+	tlsConfig, err := newTlsConfig(host, config)
+	if err != nil {
+		return nil, err
+	}
+	timeout := config.SendTimeout
+	if timeout <= 0 {
+		timeout = defaultSendTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// execute the mail provider API request, non 2xx responses are returned as errors
+func doMailRequest(client *http.Client, provider string, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", provider, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s request failed with status %d: %s", provider, resp.StatusCode, string(bytes.TrimSpace(body)))
+	}
+	return nil
+}
+
+// mail provider API URL of the host
+func mailApiUrl(host, path string) string {
+	return fmt.Sprintf("https://%s%s", host, path)
+}
+
+// endregion
@@ -0,0 +1,171 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+const defaultMailgunHost = "api.mailgun.net"
+
+// region Mailgun Mail Client ------------------------------------------------------------------------------------------
+
+// Mailgun mail client implementation (Mailgun v3 messages API), the mail relay URI path is the sending domain
+// (e.g. mailgun://api.eu.mailgun.net/mg.example.com) and the API key is taken from the mail relay password.
+// Template messages are sent using the stored template name and the variables as the template variables
+type mailgunMailClient struct {
+	address string // API host:port
+	domain  string
+	user    string
+	apiKey  string
+	client  *http.Client
+}
+
+func newMailgunMailClient(hostname, host, path string, config MailConfig) (IMailClient, error) {
+	domain := strings.Trim(path, "/")
+	if len(domain) == 0 {
+		return nil, fmt.Errorf("missing Mailgun domain in mail provider URI: %s", config.MailRelayUri)
+	}
+	if len(config.MailRelayPassword) == 0 {
+		return nil, fmt.Errorf("empty Mailgun API key (mail relay password)")
+	}
+	if len(host) == 0 {
+		hostname, host = defaultMailgunHost, defaultMailgunHost
+	}
+	client, err := newMailHttpClient(hostname, config)
+	if err != nil {
+		return nil, err
+	}
+	return &mailgunMailClient{address: host, domain: domain, user: config.MailRelayUser, apiKey: config.MailRelayPassword, client: client}, nil
+}
+
+// MailUsr set mail server authentication user
+func (c *mailgunMailClient) MailUsr() string {
+	return c.user
+}
+
+// CreateTextMessage Create plain text message
+func (c *mailgunMailClient) CreateTextMessage() IMailMessage {
+	return newHttpMailMessage(c, "text/plain")
+}
+
+// CreateHtmlMessage Create HTML message
+func (c *mailgunMailClient) CreateHtmlMessage() IMailMessage {
+	return newHttpMailMessage(c, "text/html")
+}
+
+// CreateJsonMessage Create Json message
+func (c *mailgunMailClient) CreateJsonMessage() IMailMessage {
+	return newHttpMailMessage(c, "application/json")
+}
+
+// CreateTemplateMessage Create Template message
+func (c *mailgunMailClient) CreateTemplateMessage(template TemplateName, variables map[string]string) IMailMessage {
+	m := newHttpMailMessage(c, "")
+	m.template = template
+	m.variables = variables
+	return m
+}
+
+// name returns the mail provider name
+func (c *mailgunMailClient) name() string {
+	return "mailgun"
+}
+
+// Send mail
+func (c *mailgunMailClient) send(m *httpMailMessage) error {
+	body, contentType, err := c.buildForm(m)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, mailApiUrl(c.address, fmt.Sprintf("/v3/%s/messages", c.domain)), body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth("api", c.apiKey)
+	req.Header.Set("Content-Type", contentType)
+	return doMailRequest(c.client, c.name(), req)
+}
+
+// Build the multipart form of the message, attachments are sent as form files
+func (c *mailgunMailClient) buildForm(m *httpMailMessage) (*bytes.Buffer, string, error) {
+	attachments, err := m.resolvedAttachments()
+	if err != nil {
+		return nil, "", err
+	}
+	inline, err := m.resolvedInline()
+	if err != nil {
+		return nil, "", err
+	}
+
+	buffer := &bytes.Buffer{}
+	form := multipart.NewWriter(buffer)
+	fields := [][2]string{{"from", m.from}, {"subject", m.subject}}
+	for _, to := range m.to {
+		fields = append(fields, [2]string{"to", to})
+	}
+	for _, cc := range m.cc {
+		fields = append(fields, [2]string{"cc", cc})
+	}
+
+	if len(m.template) > 0 {
+		fields = append(fields, [2]string{"template", string(m.template)})
+		if len(m.variables) > 0 {
+			variables, er := json.Marshal(m.variables)
+			if er != nil {
+				return nil, "", er
+			}
+			fields = append(fields, [2]string{"h:X-Mailgun-Variables", string(variables)})
+		}
+	} else if html := m.htmlBody(); len(html) > 0 {
+		fields = append(fields, [2]string{"html", html})
+	} else {
+		fields = append(fields, [2]string{"text", m.textBody()})
+	}
+
+	for _, field := range fields {
+		if err = form.WriteField(field[0], field[1]); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, att := range append(attachments, m.calendarAttachment()...) {
+		if err = writeMailgunFile(form, "attachment", att.FileName, att); err != nil {
+			return nil, "", err
+		}
+	}
+	// Mailgun uses the inline file name as the content id
+	for _, img := range inline {
+		if err = writeMailgunFile(form, "inline", img.ContentId, img); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = form.Close(); err != nil {
+		return nil, "", err
+	}
+	return buffer, form.FormDataContentType(), nil
+}
+
+// write the attachment as form file
+func writeMailgunFile(form *multipart.Writer, field, fileName string, att MailMessageAttachment) error {
+	data, err := base64.StdEncoding.DecodeString(att.Base64Content)
+	if err != nil {
+		return fmt.Errorf("invalid attachment %s content: %s", att.FileName, err.Error())
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, field, strings.ReplaceAll(fileName, `"`, "")))
+	header.Set("Content-Type", att.ContentType)
+	w, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// endregion
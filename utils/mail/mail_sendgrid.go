@@ -0,0 +1,184 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const defaultSendGridHost = "api.sendgrid.com"
+
+// region SendGrid Mail Client -----------------------------------------------------------------------------------------
+
+// SendGrid mail client implementation (SendGrid v3 Mail Send API), the API key is taken from the mail relay password.
+// Template messages are sent using the dynamic template id as the template name and the variables as the template data
+type sendGridMailClient struct {
+	address string // API host:port
+	user    string
+	apiKey  string
+	client  *http.Client
+}
+
+// sendGridAddress is an email address in the SendGrid API payload
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// sendGridContent is a message body in the SendGrid API payload
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sendGridAttachment is a message attachment in the SendGrid API payload
+type sendGridAttachment struct {
+	Content     string `json:"content"`
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition,omitempty"`
+	ContentId   string `json:"content_id,omitempty"`
+}
+
+// sendGridPersonalization is the recipients and template data in the SendGrid API payload
+type sendGridPersonalization struct {
+	To                  []sendGridAddress `json:"to"`
+	Cc                  []sendGridAddress `json:"cc,omitempty"`
+	DynamicTemplateData map[string]string `json:"dynamic_template_data,omitempty"`
+}
+
+// sendGridRequest is the SendGrid Mail Send API payload
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject,omitempty"`
+	Content          []sendGridContent         `json:"content,omitempty"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	TemplateId       string                    `json:"template_id,omitempty"`
+}
+
+func newSendGridMailClient(hostname, host string, config MailConfig) (IMailClient, error) {
+	if len(config.MailRelayPassword) == 0 {
+		return nil, fmt.Errorf("empty SendGrid API key (mail relay password)")
+	}
+	if len(host) == 0 {
+		hostname, host = defaultSendGridHost, defaultSendGridHost
+	}
+	client, err := newMailHttpClient(hostname, config)
+	if err != nil {
+		return nil, err
+	}
+	return &sendGridMailClient{address: host, user: config.MailRelayUser, apiKey: config.MailRelayPassword, client: client}, nil
+}
+
+// MailUsr set mail server authentication user
+func (c *sendGridMailClient) MailUsr() string {
+	return c.user
+}
+
+// CreateTextMessage Create plain text message
+func (c *sendGridMailClient) CreateTextMessage() IMailMessage {
+	return newHttpMailMessage(c, "text/plain")
+}
+
+// CreateHtmlMessage Create HTML message
+func (c *sendGridMailClient) CreateHtmlMessage() IMailMessage {
+	return newHttpMailMessage(c, "text/html")
+}
+
+// CreateJsonMessage Create Json message
+func (c *sendGridMailClient) CreateJsonMessage() IMailMessage {
+	return newHttpMailMessage(c, "application/json")
+}
+
+// CreateTemplateMessage Create Template message
+func (c *sendGridMailClient) CreateTemplateMessage(template TemplateName, variables map[string]string) IMailMessage {
+	m := newHttpMailMessage(c, "")
+	m.template = template
+	m.variables = variables
+	return m
+}
+
+// name returns the mail provider name
+func (c *sendGridMailClient) name() string {
+	return "sendgrid"
+}
+
+// Send mail
+func (c *sendGridMailClient) send(m *httpMailMessage) error {
+	payload, err := c.buildRequest(m)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, mailApiUrl(c.address, "/v3/mail/send"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+	return doMailRequest(c.client, c.name(), req)
+}
+
+// Build the SendGrid API payload
+func (c *sendGridMailClient) buildRequest(m *httpMailMessage) (*sendGridRequest, error) {
+	personalization := sendGridPersonalization{To: sendGridAddresses(m.to), Cc: sendGridAddresses(m.cc)}
+	from := parseMailAddress(m.from)
+	payload := &sendGridRequest{
+		From:    sendGridAddress{Email: from.Email, Name: from.Name},
+		Subject: m.subject,
+	}
+
+	if len(m.template) > 0 {
+		payload.TemplateId = string(m.template)
+		personalization.DynamicTemplateData = m.variables
+	} else if html := m.htmlBody(); len(html) > 0 {
+		payload.Content = []sendGridContent{{Type: "text/html", Value: html}}
+	} else {
+		payload.Content = []sendGridContent{{Type: "text/plain", Value: m.textBody()}}
+	}
+	payload.Personalizations = []sendGridPersonalization{personalization}
+
+	// The calendar invitation is sent as invite.ics attachment
+	attachments, err := m.resolvedAttachments()
+	if err != nil {
+		return nil, err
+	}
+	for _, att := range append(attachments, m.calendarAttachment()...) {
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{Content: att.Base64Content, Type: att.ContentType, Filename: att.FileName})
+	}
+
+	inline, err := m.resolvedInline()
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range inline {
+		payload.Attachments = append(payload.Attachments, sendGridAttachment{
+			Content:     img.Base64Content,
+			Type:        img.ContentType,
+			Filename:    img.FileName,
+			Disposition: "inline",
+			ContentId:   img.ContentId,
+		})
+	}
+	return payload, nil
+}
+
+func sendGridAddresses(list []string) []sendGridAddress {
+	if len(list) == 0 {
+		return nil
+	}
+	result := make([]sendGridAddress, 0, len(list))
+	for _, addr := range list {
+		a := parseMailAddress(addr)
+		result = append(result, sendGridAddress{Email: a.Email, Name: a.Name})
+	}
+	return result
+}
+
+// endregion
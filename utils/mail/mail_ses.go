@@ -0,0 +1,247 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// region SES Mail Client ----------------------------------------------------------------------------------------------
+
+// Amazon SES mail client implementation (SES v2 SendEmail API), the mail relay URI host is the AWS region
+// (e.g. ses://us-east-1) or the API endpoint with the region query parameter, the mail relay user and password are the
+// AWS access key id and secret access key. Messages with attachments, inline images or calendar invitations are sent as
+// raw MIME messages, template messages are sent using the stored template name and the variables as the template data
+// (attachments are not supported)
+type sesMailClient struct {
+	address   string // API host:port
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// sesContent is a text in the SES API payload
+type sesContent struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset,omitempty"`
+}
+
+// sesRequest is the SES SendEmail API payload
+type sesRequest struct {
+	FromEmailAddress string         `json:"FromEmailAddress"`
+	Destination      map[string]any `json:"Destination,omitempty"`
+	Content          map[string]any `json:"Content"`
+}
+
+func newSesMailClient(hostname, host, region string, config MailConfig) (IMailClient, error) {
+	if len(config.MailRelayPassword) == 0 {
+		return nil, fmt.Errorf("empty AWS secret access key (mail relay password)")
+	}
+	if len(region) == 0 {
+		if !strings.Contains(hostname, ".") {
+			// The host is the region name
+			region = hostname
+			hostname = fmt.Sprintf("email.%s.amazonaws.com", region)
+			host = hostname
+		} else if parts := strings.Split(hostname, "."); len(parts) > 2 && parts[0] == "email" {
+			region = parts[1]
+		}
+	}
+	if len(region) == 0 {
+		return nil, fmt.Errorf("missing AWS region in mail provider URI: %s", config.MailRelayUri)
+	}
+
+	client, err := newMailHttpClient(hostname, config)
+	if err != nil {
+		return nil, err
+	}
+	return &sesMailClient{address: host, region: region, accessKey: config.MailRelayUser, secretKey: config.MailRelayPassword, client: client}, nil
+}
+
+// MailUsr set mail server authentication user
+func (c *sesMailClient) MailUsr() string {
+	return c.accessKey
+}
+
+// CreateTextMessage Create plain text message
+func (c *sesMailClient) CreateTextMessage() IMailMessage {
+	return newHttpMailMessage(c, "text/plain")
+}
+
+// CreateHtmlMessage Create HTML message
+func (c *sesMailClient) CreateHtmlMessage() IMailMessage {
+	return newHttpMailMessage(c, "text/html")
+}
+
+// CreateJsonMessage Create Json message
+func (c *sesMailClient) CreateJsonMessage() IMailMessage {
+	return newHttpMailMessage(c, "application/json")
+}
+
+// CreateTemplateMessage Create Template message
+func (c *sesMailClient) CreateTemplateMessage(template TemplateName, variables map[string]string) IMailMessage {
+	m := newHttpMailMessage(c, "")
+	m.template = template
+	m.variables = variables
+	return m
+}
+
+// name returns the mail provider name
+func (c *sesMailClient) name() string {
+	return "ses"
+}
+
+// Send mail
+func (c *sesMailClient) send(m *httpMailMessage) error {
+	payload, err := c.buildRequest(m)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, mailApiUrl(c.address, "/v2/email/outbound-emails"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.sign(req, body, time.Now().UTC())
+	return doMailRequest(c.client, c.name(), req)
+}
+
+// Build the SES API payload
+func (c *sesMailClient) buildRequest(m *httpMailMessage) (*sesRequest, error) {
+	payload := &sesRequest{
+		FromEmailAddress: m.from,
+		Destination:      map[string]any{"ToAddresses": m.to},
+		Content:          map[string]any{},
+	}
+	if len(m.cc) > 0 {
+		payload.Destination["CcAddresses"] = m.cc
+	}
+
+	if len(m.template) > 0 {
+		if len(m.attachments) > 0 || len(m.inline) > 0 || m.event != nil {
+			return nil, fmt.Errorf("SES template messages do not support attachments")
+		}
+		data, err := json.Marshal(m.variables)
+		if err != nil {
+			return nil, err
+		}
+		payload.Content["Template"] = map[string]string{"TemplateName": string(m.template), "TemplateData": string(data)}
+		return payload, nil
+	}
+
+	if len(m.attachments) > 0 || len(m.inline) > 0 || m.event != nil {
+		raw, err := buildRawMessage(m)
+		if err != nil {
+			return nil, err
+		}
+		payload.Content["Raw"] = map[string]string{"Data": base64.StdEncoding.EncodeToString(raw)}
+		return payload, nil
+	}
+
+	body := map[string]sesContent{}
+	if html := m.htmlBody(); len(html) > 0 {
+		body["Html"] = sesContent{Data: html, Charset: "UTF-8"}
+	} else {
+		body["Text"] = sesContent{Data: m.textBody(), Charset: "UTF-8"}
+	}
+	payload.Content["Simple"] = map[string]any{
+		"Subject": sesContent{Data: m.subject, Charset: "UTF-8"},
+		"Body":    body,
+	}
+	return payload, nil
+}
+
+// sign the request using AWS signature version 4
+func (c *sesMailClient) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	payloadHash := sha256.Sum256(body)
+	signedHeaders := "content-type;host;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		fmt.Sprintf("content-type:%s\nhost:%s\nx-amz-date:%s\n", req.Header.Get("Content-Type"), req.URL.Host, amzDate),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/ses/aws4_request", date, c.region)
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSha256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSha256(key, c.region)
+	key = hmacSha256(key, "ses")
+	key = hmacSha256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Build the raw MIME message (used for messages with attachments, inline images or calendar invitations)
+func buildRawMessage(m *httpMailMessage) ([]byte, error) {
+	attachments, err := m.resolvedAttachments()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.from)
+	msg.SetHeader("To", m.to...)
+	if len(m.cc) > 0 {
+		msg.SetHeader("Cc", m.cc...)
+	}
+	msg.SetHeader("Subject", m.subject)
+	if html := m.htmlBody(); len(html) > 0 {
+		msg.SetBody("text/html", html)
+	} else {
+		msg.SetBody("text/plain", m.textBody())
+	}
+	for _, att := range attachments {
+		data, er := base64.StdEncoding.DecodeString(att.Base64Content)
+		if er != nil {
+			return nil, fmt.Errorf("invalid attachment %s content: %s", att.FileName, er.Error())
+		}
+		msg.Attach(att.FileName, gomail.SetCopyFunc(func(w io.Writer) error {
+			_, e := w.Write(data)
+			return e
+		}), gomail.SetHeader(map[string][]string{"Content-Type": {att.ContentType}}))
+	}
+	if err = embedInline(msg, m.inline); err != nil {
+		return nil, err
+	}
+	attachCalendar(msg, m.event)
+
+	buffer := &bytes.Buffer{}
+	if _, err = msg.WriteTo(buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// endregion
@@ -2,18 +2,29 @@ package mail
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
+	"net"
+	"net/smtp"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/gomail.v2"
 )
 
+const (
+	defaultDialTimeout = 10 * time.Second
+	defaultSendTimeout = time.Minute
+)
+
 // region SMTP Mail Message --------------------------------------------------------------------------------------------
 
 // SMTP mail message implementation
@@ -28,6 +39,8 @@ type smtpMailMessage struct {
 	mime        string
 	template    TemplateName
 	attachments []MailMessageAttachment
+	inline      []MailMessageAttachment
+	event       *CalendarEvent
 	variables   map[string]string
 }
 
@@ -73,22 +86,42 @@ func (m *smtpMailMessage) Attachments(attachments []MailMessageAttachment) IMail
 	return m
 }
 
+// Inline set list of inline attachments (e.g. images) referenced from the HTML body by their content id
+func (m *smtpMailMessage) Inline(images []MailMessageAttachment) IMailMessage {
+	m.inline = images
+	return m
+}
+
+// Calendar set calendar event invitation
+func (m *smtpMailMessage) Calendar(event *CalendarEvent) IMailMessage {
+	m.event = event
+	return m
+}
+
 // Send mail message
 func (m *smtpMailMessage) Send() error {
 	return m.client.send(m)
 }
 
+// provider returns the mail provider name
+func (m *smtpMailMessage) provider() string {
+	return "smtp"
+}
+
 // endregion
 
 // region SMTP Mail Client ---------------------------------------------------------------------------------------------
 
 // SMTP mail client implementation
 type smtpMailClient struct {
-	host     string
-	port     int
-	user     string
-	password string
-	useTls   bool
+	host        string
+	port        int
+	user        string
+	password    string
+	tlsMode     TLSMode
+	tlsConfig   *tls.Config
+	dialTimeout time.Duration
+	sendTimeout time.Duration
 }
 
 // MailUsr set mail server authentication user
@@ -156,7 +189,7 @@ func (c *smtpMailClient) buildMessage(m *smtpMailMessage) string {
 
 // endregion
 
-func newSmtpMailClient(host, port, usr, pwd string, tls bool) IMailClient {
+func newSmtpMailClient(host, port string, config MailConfig) (IMailClient, error) {
 
 	var err error
 	p := 80
@@ -164,13 +197,62 @@ func newSmtpMailClient(host, port, usr, pwd string, tls bool) IMailClient {
 	if p, err = strconv.Atoi(port); err != nil {
 		p = 80
 	}
-	return &smtpMailClient{
-		host:     host,
-		port:     p,
-		user:     usr,
-		password: pwd,
-		useTls:   tls,
+
+	// Resolve the TLS mode
+	mode := config.TLSMode
+	if mode == TLSModeAuto && (config.UseTLS || p == 465) {
+		if p == 465 {
+			mode = TLSModeImplicit
+		} else {
+			mode = TLSModeStartTLS
+		}
+	}
+
+	tlsConfig, err := newTlsConfig(host, config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &smtpMailClient{
+		host:        host,
+		port:        p,
+		user:        config.MailRelayUser,
+		password:    config.MailRelayPassword,
+		tlsMode:     mode,
+		tlsConfig:   tlsConfig,
+		dialTimeout: config.DialTimeout,
+		sendTimeout: config.SendTimeout,
 	}
+	if client.dialTimeout <= 0 {
+		client.dialTimeout = defaultDialTimeout
+	}
+	if client.sendTimeout <= 0 {
+		client.sendTimeout = defaultSendTimeout
+	}
+	return client, nil
+}
+
+// Create the TLS configuration to verify the mail relay certificate
+func newTlsConfig(host string, config MailConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if len(config.TLSServerName) > 0 {
+		tlsConfig.ServerName = config.TLSServerName
+	}
+	if len(config.CACertFile) > 0 {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("read mail relay CA certificates failed: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid CA certificates in: %s", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
 }
 
 // Send mail
@@ -203,6 +285,11 @@ func (c *smtpMailClient) send(m *smtpMailMessage) (retError error) {
 		}
 	}
 
+	if err := embedInline(msg, m.inline); err != nil {
+		return err
+	}
+	attachCalendar(msg, m.event)
+
 	//f := func(w io.Writer) error {
 	//	data, _ := base64.StdEncoding.DecodeString(m.attachments[0].Base64Content)
 	//	_, err := io.Copy(w, bytes.NewReader(data))
@@ -212,8 +299,12 @@ func (c *smtpMailClient) send(m *smtpMailMessage) (retError error) {
 	//	msg.Attach("event_image.jpg", gomail.SetCopyFunc(f))
 	//}
 
-	d := gomail.NewDialer(c.host, c.port, c.user, c.password)
-	return d.DialAndSend(msg)
+	// Envelope recipients
+	recipients := make([]string, 0, len(m.to)+len(m.cc))
+	for _, addr := range append(append([]string{}, m.to...), m.cc...) {
+		recipients = append(recipients, parseMailAddress(addr).Email)
+	}
+	return c.deliver(parseMailAddress(m.from).Email, recipients, msg)
 
 	/*
 		if err := d.DialAndSend(msg); err != nil {
@@ -283,3 +374,157 @@ func (c *smtpMailClient) attachPath(att *MailMessageAttachment) error {
 	}
 	return nil
 }
+
+// embed the inline attachments in the message, referenced from the HTML body by their content id
+func embedInline(msg *gomail.Message, inline []MailMessageAttachment) error {
+	images, err := resolveAttachments(inline)
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		data, er := base64.StdEncoding.DecodeString(img.Base64Content)
+		if er != nil {
+			return fmt.Errorf("invalid inline attachment %s content: %s", img.FileName, er.Error())
+		}
+		msg.Embed(img.FileName, gomail.SetCopyFunc(func(w io.Writer) error {
+			_, e := w.Write(data)
+			return e
+		}), gomail.SetHeader(map[string][]string{
+			"Content-ID":   {"<" + img.ContentId + ">"},
+			"Content-Type": {img.ContentType},
+		}))
+	}
+	return nil
+}
+
+// add the calendar invitation as an alternative part (for inline rendering) and as invite.ics attachment
+func attachCalendar(msg *gomail.Message, event *CalendarEvent) {
+	if event == nil {
+		return
+	}
+	ics := event.ICS()
+	msg.AddAlternative(event.ContentType(), ics)
+	msg.Attach("invite.ics", gomail.SetCopyFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, ics)
+		return err
+	}), gomail.SetHeader(map[string][]string{"Content-Type": {"application/ics; name=invite.ics"}}))
+}
+
+// region SMTP Transport -----------------------------------------------------------------------------------------------
+
+// deliver the message over a new SMTP connection, the whole conversation is bounded by the send timeout
+func (c *smtpMailClient) deliver(from string, to []string, msg io.WriterTo) error {
+
+	address := net.JoinHostPort(c.host, strconv.Itoa(c.port))
+	conn, err := net.DialTimeout("tcp", address, c.dialTimeout)
+	if err != nil {
+		return fmt.Errorf("connect mail relay %s failed: %w", address, err)
+	}
+	if err = conn.SetDeadline(time.Now().Add(c.sendTimeout)); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	if c.tlsMode == TLSModeImplicit {
+		conn = tls.Client(conn, c.tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("mail relay %s handshake failed: %w", address, err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	// Upgrade the connection using STARTTLS
+	if c.tlsMode == TLSModeAuto || c.tlsMode == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(c.tlsConfig); err != nil {
+				return fmt.Errorf("mail relay %s STARTTLS failed: %w", address, err)
+			}
+		} else if c.tlsMode == TLSModeStartTLS {
+			return fmt.Errorf("mail relay %s does not support STARTTLS", address)
+		}
+	}
+
+	if auth := c.auth(client); auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return fmt.Errorf("mail relay %s authentication failed: %w", address, err)
+		}
+	}
+
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err = client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = msg.WriteTo(w); err != nil {
+		_ = w.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// select the authentication mechanism supported by the server
+func (c *smtpMailClient) auth(client *smtp.Client) smtp.Auth {
+	if len(c.user) == 0 {
+		return nil
+	}
+	ok, mechanisms := client.Extension("AUTH")
+	if !ok {
+		return nil
+	}
+	if strings.Contains(mechanisms, "CRAM-MD5") {
+		return smtp.CRAMMD5Auth(c.user, c.password)
+	}
+	if strings.Contains(mechanisms, "LOGIN") && !strings.Contains(mechanisms, "PLAIN") {
+		return &loginAuth{username: c.user, password: c.password, host: c.host}
+	}
+	return smtp.PlainAuth("", c.user, c.password, c.host)
+}
+
+// loginAuth implements the LOGIN authentication mechanism
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch {
+	case bytes.EqualFold(fromServer, []byte("Username:")):
+		return []byte(a.username), nil
+	case bytes.EqualFold(fromServer, []byte("Password:")):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected server challenge: %s", fromServer)
+	}
+}
+
+// endregion
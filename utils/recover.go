@@ -2,7 +2,18 @@
 
 package utils
 
-// RecoverAll performs recover for all panics.
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// number of recovered panics
+var panicCount atomic.Int64
+
+// RecoverAll performs recover for all panics, the panic is logged with the stack trace.
 //
 // Sample usage:
 //
@@ -11,9 +22,77 @@ package utils
 //	})
 func RecoverAll(cb func(v any)) {
 	r := recover()
+	if r != nil {
+		reportPanic(r, debug.Stack())
+	}
 	cb(r)
 }
 
+// RecoverAllWithStack performs recover for all panics and pass the stack trace of the panic to the callback
+// (nil if there was no panic), the panic is logged with the stack trace.
+//
+// Sample usage:
+//
+//	defer RecoverAllWithStack(func(err any, stack []byte) {
+//		fmt.Printf("got error: %s\n%s", err, stack)
+//	})
+func RecoverAllWithStack(cb func(v any, stack []byte)) {
+	r := recover()
+	var stack []byte
+	if r != nil {
+		stack = debug.Stack()
+		reportPanic(r, stack)
+	}
+	cb(r, stack)
+}
+
+// GoSafe runs the function in a new goroutine, a panic in the function is recovered, logged with the stack trace and
+// passed to the optional callback instead of crashing the process.
+//
+// Sample usage:
+//
+//	GoSafe(func() {
+//		processEvents(events)
+//	}, func(err any, stack []byte) {
+//		fmt.Printf("events processor failed: %s", err)
+//	})
+func GoSafe(fn func(), onPanic ...func(v any, stack []byte)) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				stack := debug.Stack()
+				reportPanic(r, stack)
+				for _, cb := range onPanic {
+					cb(r, stack)
+				}
+			}
+		}()
+		fn()
+	}()
+}
+
+// RunSafe runs the function and converts a panic in the function to an error with the stack trace, the panic is logged
+// and counted like the other recover utilities.
+//
+// Sample usage:
+//
+//	err := RunSafe(func() error {
+//		return job.Run(ctx)
+//	})
+func RunSafe(fn func() error) (err error) {
+	defer RecoverAllWithStack(func(v any, stack []byte) {
+		if v != nil {
+			err = fmt.Errorf("panic: %v\n%s", v, stack)
+		}
+	})
+	return fn()
+}
+
+// PanicCount returns the number of panics recovered by RecoverAll, RecoverOne, RecoverAny, GoSafe and RunSafe
+func PanicCount() int64 {
+	return panicCount.Load()
+}
+
 // RecoverOne calls the callback function: cb with recovered value
 // in case when recovered value equals to e otherwise panic won't be recovered and will be propagated.
 //
@@ -28,6 +107,7 @@ func RecoverOne(e error, cb func(v any)) {
 	errors := []error{e}
 
 	if inErrors(r, errors) {
+		reportPanic(r, debug.Stack())
 		cb(r)
 		return
 	}
@@ -46,12 +126,21 @@ func RecoverAny(errors []error, cb func(v any)) {
 	r := recover()
 
 	if len(errors) == 0 || inErrors(r, errors) {
+		if r != nil {
+			reportPanic(r, debug.Stack())
+		}
 		cb(r)
 		return
 	}
 	panic(r)
 }
 
+// count the recovered panic and log it with the stack trace
+func reportPanic(v any, stack []byte) {
+	panicCount.Add(1)
+	logger.With(logger.F("stack", string(stack))).Error("recovered panic: %v", v)
+}
+
 // Check if the given error included in the error list
 func inErrors(e any, errors []error) bool {
 	for _, err := range errors {
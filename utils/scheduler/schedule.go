@@ -0,0 +1,243 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next activation time after the given time (zero time if there is no next activation)
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// region Interval schedule --------------------------------------------------------------------------------------------
+
+// intervalSchedule activates in a fixed interval
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Interval creates a fixed-interval schedule, a non-positive interval is never activated
+func Interval(interval time.Duration) Schedule {
+	return &intervalSchedule{interval: interval}
+}
+
+// Next returns the time after the interval
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	if s.interval <= 0 {
+		return time.Time{}
+	}
+	return t.Add(s.interval)
+}
+
+// endregion
+
+// region Cron schedule ------------------------------------------------------------------------------------------------
+
+// cronSchedule is a cron expression, each field is a bitmask of the matching values
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domAny, dowAny                        bool // true if the field is `*` (day matching rule)
+}
+
+// cronField is the range of a cron expression field
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = cronField{name: "second", min: 0, max: 59}
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronDescriptors are the predefined schedules
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron expression in the time zone of the time passed to Next:
+//
+//   - Standard 5 fields: minute hour day-of-month month day-of-week (e.g. "*/15 8-18 * * mon-fri")
+//   - 6 fields with leading seconds: second minute hour day-of-month month day-of-week
+//   - Descriptors: @yearly, @monthly, @weekly, @daily, @hourly and @every <duration> (e.g. "@every 5m")
+//
+// Fields support `*`, `?`, lists (1,5), ranges (1-5), steps (*/10, 0-30/5) and month / weekday names.
+// When both day-of-month and day-of-week are restricted, a day matching either of them is activated (like cron)
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err.Error())
+		}
+		return Interval(interval), nil
+	}
+	if descriptor, ok := cronDescriptors[strings.ToLower(expr)]; ok {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 or 6 fields", expr)
+	}
+
+	s := &cronSchedule{}
+	var err error
+	if s.second, err = parseCronField(fields[0], secondField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err.Error())
+	}
+	if s.minute, err = parseCronField(fields[1], minuteField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err.Error())
+	}
+	if s.hour, err = parseCronField(fields[2], hourField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err.Error())
+	}
+	if s.dom, err = parseCronField(fields[3], domField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err.Error())
+	}
+	if s.month, err = parseCronField(fields[4], monthField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err.Error())
+	}
+	if s.dow, err = parseCronField(fields[5], dowField); err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err.Error())
+	}
+
+	// Sunday is either 0 or 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = fields[3] == "*" || fields[3] == "?"
+	s.dowAny = fields[5] == "*" || fields[5] == "?"
+	return s, nil
+}
+
+// MustParseCron parses a cron expression and panics if the expression is invalid
+func MustParseCron(expr string) Schedule {
+	s, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Next returns the next matching time (second resolution) after the given time, zero time if no match within 5 years
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		if s.second&(1<<uint(t.Second())) == 0 {
+			t = t.Add(time.Second)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// check if the day matches the day-of-month and day-of-week fields
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// parse a cron field to a bitmask of the matching values
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(expr, ",") {
+		from, to, step := field.min, field.max, 1
+
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			value, err := strconv.Atoi(stepExpr)
+			if err != nil || value <= 0 {
+				return 0, fmt.Errorf("invalid %s step: %s", field.name, part)
+			}
+			step = value
+		}
+
+		if rangeExpr != "*" && rangeExpr != "?" {
+			fromExpr, toExpr, isRange := strings.Cut(rangeExpr, "-")
+			value, err := field.value(fromExpr)
+			if err != nil {
+				return 0, err
+			}
+			from, to = value, value
+			if isRange {
+				if to, err = field.value(toExpr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// `n/step` means from n to the max value
+				to = field.max
+			}
+		}
+		if from > to {
+			return 0, fmt.Errorf("invalid %s range: %s", field.name, part)
+		}
+		for v := from; v <= to; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+// parse a field value (number or name) and validate its range
+func (f cronField) value(expr string) (int, error) {
+	if v, ok := f.names[strings.ToLower(expr)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value: %s", f.name, expr)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s value %d out of range [%d-%d]", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// endregion
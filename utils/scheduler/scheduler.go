@@ -0,0 +1,224 @@
+// Package scheduler provides cron-expression and fixed-interval job scheduling
+//
+// Services register named jobs with a schedule instead of running ad-hoc time.Ticker loops. Jobs are panic-safe, an
+// optional jitter spreads the activation of many instances and a distributed lock (e.g. IDataCache) makes sure only one
+// instance of the service runs each activation:
+//
+//	s := scheduler.NewScheduler()
+//	_ = s.RegisterCron("purge-sessions", "0 3 * * *", purgeSessions, scheduler.JobOptions{Locker: dataCache})
+//	_ = s.RegisterInterval("refresh-config", time.Minute, refreshConfig, scheduler.JobOptions{Jitter: 5 * time.Second})
+//	_ = s.Start(ctx)
+//	defer s.Close()
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/database"
+	"github.com/go-yaaf/yaaf-common/utils"
+	"github.com/go-yaaf/yaaf-common/utils/workers"
+)
+
+// JobFunc is the job function, it should return when the context is canceled
+type JobFunc func(ctx context.Context) error
+
+// Locker obtains distributed locks, implemented by database.IDataCache
+type Locker interface {
+	ObtainLocker(key string, ttl time.Duration) (database.ILocker, error)
+}
+
+// JobOptions configures the job execution
+type JobOptions struct {
+	Locker  Locker        // Distributed lock, when set the job runs only on the instance obtaining the lock of the activation
+	LockTTL time.Duration // Lock TTL (default: until the next activation), the lock is not released to block other instances
+	Jitter  time.Duration // Max random delay added to each activation
+	Timeout time.Duration // Max job run time (0 for no timeout)
+}
+
+// JobStatus is the execution report of a job
+type JobStatus struct {
+	Name      string    `json:"name"`                // Job name
+	Running   bool      `json:"running"`             // The job is running now
+	Runs      int       `json:"runs"`                // Number of runs
+	Failures  int       `json:"failures"`            // Number of failed runs (error or panic)
+	Skipped   int       `json:"skipped"`             // Number of activations skipped because the lock was held by another instance
+	LastError string    `json:"lastError,omitempty"` // The last error returned by the job
+	LastRun   time.Time `json:"lastRun"`             // Last time the job started
+	NextRun   time.Time `json:"nextRun"`             // Next activation time
+}
+
+// lockKeyPrefix is the prefix of the distributed lock key of a job
+const lockKeyPrefix = "scheduler:"
+
+// region Scheduler ----------------------------------------------------------------------------------------------------
+
+// Scheduler runs the registered jobs by their schedule, the schedule loop of each job is a worker of the workers manager
+type Scheduler struct {
+	mu      sync.RWMutex
+	jobs    map[string]*job
+	manager *workers.Manager
+}
+
+// job holds the registration and runtime state of a named job
+type job struct {
+	name     string
+	schedule Schedule
+	run      JobFunc
+	options  JobOptions
+	status   JobStatus
+}
+
+// NewScheduler creates a new scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*job), manager: workers.NewManager()}
+}
+
+// Register adds a named job with a schedule, jobs registered after Start are scheduled immediately
+func (s *Scheduler) Register(name string, schedule Schedule, run JobFunc, options JobOptions) error {
+	if run == nil {
+		return fmt.Errorf("job %s function is nil", name)
+	}
+	if schedule == nil {
+		return fmt.Errorf("job %s schedule is nil", name)
+	}
+
+	s.mu.Lock()
+	if _, exists := s.jobs[name]; exists {
+		s.mu.Unlock()
+		return fmt.Errorf("job %s already registered", name)
+	}
+	j := &job{name: name, schedule: schedule, run: run, options: options, status: JobStatus{Name: name}}
+	s.jobs[name] = j
+	s.mu.Unlock()
+
+	return s.manager.Register(name, func(ctx context.Context) error {
+		s.loop(ctx, j)
+		return nil
+	}, workers.WorkerOptions{})
+}
+
+// RegisterCron adds a named job with a cron expression schedule (see ParseCron)
+func (s *Scheduler) RegisterCron(name string, expr string, run JobFunc, options JobOptions) error {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	return s.Register(name, schedule, run, options)
+}
+
+// RegisterInterval adds a named job running in a fixed interval
+func (s *Scheduler) RegisterInterval(name string, interval time.Duration, run JobFunc, options JobOptions) error {
+	return s.Register(name, Interval(interval), run, options)
+}
+
+// Start scheduling all the registered jobs, the jobs are stopped when the context is canceled or Stop is called
+func (s *Scheduler) Start(ctx context.Context) error {
+	return s.manager.Start(ctx)
+}
+
+// Stop scheduling, cancel the running jobs and wait until they return or until the timeout expires (0 for no timeout)
+func (s *Scheduler) Stop(timeout time.Duration) error {
+	return s.manager.Stop(timeout)
+}
+
+// Close stops the scheduler (implements io.Closer)
+func (s *Scheduler) Close() error {
+	return s.manager.Close()
+}
+
+// Jobs returns the status of all the jobs sorted by name
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		list = append(list, j.status)
+	}
+	sort.Slice(list, func(i, k int) bool { return list[i].Name < list[k].Name })
+	return list
+}
+
+// endregion
+
+// region Execution ----------------------------------------------------------------------------------------------------
+
+// wait for the job activations and run the job, activations missed while the job is running are skipped
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		s.update(func() { j.status.NextRun = next })
+
+		delay := time.Until(next)
+		if j.options.Jitter > 0 {
+			delay += rand.N(j.options.Jitter)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.activate(ctx, j, next)
+	}
+}
+
+// run a single activation of the job, guarded by the distributed lock if configured
+func (s *Scheduler) activate(ctx context.Context, j *job, activation time.Time) {
+	if j.options.Locker != nil {
+		ttl := j.options.LockTTL
+		if ttl <= 0 {
+			// Hold the lock until the next activation so other instances skip this one
+			ttl = time.Until(j.schedule.Next(activation))
+		}
+		if ttl < time.Second {
+			ttl = time.Second
+		}
+		if _, err := j.options.Locker.ObtainLocker(lockKeyPrefix+j.name, ttl); err != nil {
+			s.update(func() { j.status.Skipped += 1 })
+			return
+		}
+	}
+
+	s.update(func() {
+		j.status.Running = true
+		j.status.LastRun = time.Now()
+	})
+
+	runCtx := ctx
+	if j.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, j.options.Timeout)
+		defer cancel()
+	}
+	err := utils.RunSafe(func() error { return j.run(runCtx) })
+
+	s.update(func() {
+		j.status.Running = false
+		j.status.Runs += 1
+		if err != nil {
+			j.status.Failures += 1
+			j.status.LastError = err.Error()
+		}
+	})
+}
+
+// update job status under lock
+func (s *Scheduler) update(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+}
+
+// endregion
@@ -42,6 +42,75 @@ func (t *stringUtils) WildCardToRegexp(pattern string) string {
 	return "^" + result.String() + "$"
 }
 
+// GlobToRegexp converts a Redis-style glob pattern to regular expression, the pattern supports * (any sequence),
+// ? (any single character), [abc] / [a-z] / [^a] (character classes) and \ to escape special characters
+func (t *stringUtils) GlobToRegexp(pattern string) string {
+	var result strings.Builder
+	runes := []rune(pattern)
+
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			result.WriteString(".*")
+		case '?':
+			result.WriteString(".")
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			result.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case '[':
+			// Find the end of the character class, unterminated class is a literal [
+			end := i + 1
+			if end < len(runes) && (runes[end] == '^' || runes[end] == '!') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				result.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+
+			result.WriteString("[")
+			j := i + 1
+			if runes[j] == '^' || runes[j] == '!' {
+				result.WriteString("^")
+				j++
+			}
+			for ; j < end; j++ {
+				c := runes[j]
+				if c == '\\' && j+1 < end {
+					j++
+					c = runes[j]
+				}
+				if c == '\\' || c == '[' || c == ']' {
+					result.WriteString("\\")
+				}
+				result.WriteRune(c)
+			}
+			result.WriteString("]")
+			i = end
+		default:
+			result.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return "(?s)^" + result.String() + "$"
+}
+
+// GlobMatch returns true if the source string matches the Redis-style glob pattern (see GlobToRegexp)
+func (t *stringUtils) GlobMatch(source string, pattern string) bool {
+	result, _ := regexp.MatchString(t.GlobToRegexp(pattern), source)
+	return result
+}
+
 // WildCardMatch returns true if the source string matches the wildcard pattern
 func (t *stringUtils) WildCardMatch(source string, pattern string) bool {
 	result, _ := regexp.MatchString(t.WildCardToRegexp(pattern), source)
@@ -0,0 +1,356 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	. "github.com/go-yaaf/yaaf-common/entity"
+)
+
+// region Struct to map conversion -------------------------------------------------------------------------------------
+
+// structField is the cached metadata of a struct field
+type structField struct {
+	name      string // JSON name of the field
+	index     []int  // Field index path (including embedded structs)
+	omitEmpty bool   // Omit zero value
+}
+
+// structFieldsCache caches the fields metadata by struct type
+var structFieldsCache sync.Map
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// ToMap converts entity to map of the JSON field names into values using reflection (without JSON round trip)
+// The map follows the JSON representation rules (json tags, omitempty, embedded structs), primitive values keep their
+// Go type (e.g. int64 instead of float64) and nested structs are converted to nested maps
+func (t *jsonUtils) ToMap(entity Entity) (map[string]any, error) {
+	v := reflect.ValueOf(entity)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil entity")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return t.ToJson(entity)
+	}
+	return structToMap(v), nil
+}
+
+// FromMap converts map of JSON field names into values to entity using reflection (without JSON round trip)
+// Values which are not assignable to the field type (e.g. nested maps or RFC3339 timestamp strings) are converted
+// using JSON encoding of the single value
+func (t *jsonUtils) FromMap(factory EntityFactory, raw map[string]any) (Entity, error) {
+	entity := factory()
+	v := reflect.ValueOf(entity)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return t.FromJson(factory, raw)
+	}
+	v = v.Elem()
+
+	for _, f := range getStructFields(v.Type()) {
+		value, ok := raw[f.name]
+		if !ok {
+			continue
+		}
+		field, err := fieldByIndexAlloc(v, f.index)
+		if err != nil {
+			return nil, err
+		}
+		if err = setFieldValue(field, value); err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return entity, nil
+}
+
+// FieldTypes maps the JSON field names of the entity (the names used by ToMap and FromMap) to the field types
+func (t *jsonUtils) FieldTypes(entity Entity) map[string]reflect.Type {
+	result := make(map[string]reflect.Type)
+	st := reflect.TypeOf(entity)
+	for st != nil && st.Kind() == reflect.Pointer {
+		st = st.Elem()
+	}
+	if st == nil || st.Kind() != reflect.Struct {
+		return result
+	}
+	for _, f := range getStructFields(st) {
+		result[f.name] = st.FieldByIndex(f.index).Type
+	}
+	return result
+}
+
+// convert struct value to map
+func structToMap(v reflect.Value) map[string]any {
+	fields := getStructFields(v.Type())
+	result := make(map[string]any, len(fields))
+	for _, f := range fields {
+		field, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && isEmptyValue(field) {
+			continue
+		}
+		result[f.name] = toMapValue(field)
+	}
+	return result
+}
+
+// convert field value to the map representation
+func toMapValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toMapValue(v.Elem())
+	}
+
+	// Complex types with custom JSON representation
+	if v.Type().Implements(jsonMarshalerType) || reflect.PointerTo(v.Type()).Implements(jsonMarshalerType) {
+		return jsonValue(v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return structToMap(v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		// Slices of builtin primitive types are kept as is (e.g. []string, []int)
+		elem := v.Type().Elem()
+		if v.Kind() == reflect.Slice && elem.PkgPath() == "" && isPrimitiveKind(elem.Kind()) && elem.Kind() != reflect.Uint8 {
+			return v.Interface()
+		}
+		if elem.Kind() == reflect.Uint8 {
+			return jsonValue(v.Interface())
+		}
+		list := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			list[i] = toMapValue(v.Index(i))
+		}
+		return list
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return jsonValue(v.Interface())
+		}
+		m := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = toMapValue(iter.Value())
+		}
+		return m
+	default:
+		return nil
+	}
+}
+
+// convert value through JSON encoding
+func jsonValue(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var result any
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return result
+}
+
+// check if the kind is bool, numeric or string
+func isPrimitiveKind(kind reflect.Kind) bool {
+	return (kind >= reflect.Bool && kind <= reflect.Float64 && kind != reflect.Uintptr) || kind == reflect.String
+}
+
+// set the field value from the map value
+func setFieldValue(field reflect.Value, value any) error {
+	if value == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+
+	src := reflect.ValueOf(value)
+	if src.Type().AssignableTo(field.Type()) {
+		field.Set(src)
+		return nil
+	}
+	if isConvertibleKind(src.Kind(), field.Kind()) {
+		if converted, ok := convertValue(src, field.Type()); ok {
+			field.Set(converted)
+			return nil
+		}
+	}
+
+	// Fallback to JSON conversion of the single value (returns an error for lossy numeric conversions)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, field.Addr().Interface())
+}
+
+// check if the kind conversion keeps the value semantics (numeric to numeric, string to string, bool to bool)
+func isConvertibleKind(from, to reflect.Kind) bool {
+	isNumeric := func(k reflect.Kind) bool { return k >= reflect.Int && k <= reflect.Float64 && k != reflect.Uintptr }
+	switch {
+	case isNumeric(from) && isNumeric(to):
+		return true
+	case from == reflect.String && to == reflect.String:
+		return true
+	case from == reflect.Bool && to == reflect.Bool:
+		return true
+	default:
+		return false
+	}
+}
+
+// convert the value to the type, returns false if the conversion does not keep the value (fraction truncated, sign
+// changed or overflow of the target type)
+func convertValue(src reflect.Value, t reflect.Type) (reflect.Value, bool) {
+	converted := src.Convert(t)
+	if !converted.Convert(src.Type()).Equal(src) {
+		return converted, false
+	}
+	return converted, isNegative(src) == isNegative(converted)
+}
+
+// check if the numeric value is negative
+func isNegative(v reflect.Value) bool {
+	switch {
+	case v.CanInt():
+		return v.Int() < 0
+	case v.CanFloat():
+		return v.Float() < 0
+	default:
+		return false
+	}
+}
+
+// get the field by index path, returns false if one of the embedded pointers is nil
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
+
+// get the field by index path, allocating nil embedded pointers
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("can't set embedded field of %s", v.Type())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, nil
+}
+
+// get the cached fields metadata of the struct type
+func getStructFields(t reflect.Type) []structField {
+	if cached, ok := structFieldsCache.Load(t); ok {
+		return cached.([]structField)
+	}
+	fields := collectStructFields(t, nil, make(map[reflect.Type]bool))
+
+	// Fields of the outer struct hide the embedded struct fields with the same name
+	seen := make(map[string]bool, len(fields))
+	result := make([]structField, 0, len(fields))
+	for _, f := range fields {
+		if !seen[f.name] {
+			seen[f.name] = true
+			result = append(result, f)
+		}
+	}
+	structFieldsCache.Store(t, result)
+	return result
+}
+
+// collect the exported fields of the struct type ordered by depth (outer fields first)
+func collectStructFields(t reflect.Type, parent []int, visited map[reflect.Type]bool) []structField {
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+
+	direct := make([]structField, 0, t.NumField())
+	embedded := make([]structField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		index := append(append(make([]int, 0, len(parent)+1), parent...), i)
+
+		// Embedded struct without explicit name is flattened
+		if sf.Anonymous && len(name) == 0 {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, collectStructFields(ft, index, visited)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = sf.Name
+		}
+		direct = append(direct, structField{name: name, index: index, omitEmpty: strings.Contains(opts, "omitempty")})
+	}
+	return append(direct, embedded...)
+}
+
+// check if the value is empty according to the JSON omitempty rules
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+// endregion
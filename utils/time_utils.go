@@ -12,6 +12,7 @@ import (
 // timeUtils internal helper
 type timeUtils struct {
 	baseTime Timestamp
+	loc      *time.Location
 	SECOND   uint64
 	MINUTE   uint64
 	HOUR     uint64
@@ -20,7 +21,7 @@ type timeUtils struct {
 
 // TimeUtils is a factory method
 func TimeUtils(ts Timestamp) *timeUtils {
-	return &timeUtils{baseTime: ts, SECOND: 1000, MINUTE: 60 * 1000, HOUR: 60 * 60 * 1000, DAY: 24 * 60 * 60 * 1000}
+	return &timeUtils{baseTime: ts, loc: time.UTC, SECOND: 1000, MINUTE: 60 * 1000, HOUR: 60 * 60 * 1000, DAY: 24 * 60 * 60 * 1000}
 }
 
 // Get returns the current timestamp
@@ -330,3 +331,170 @@ func (t *timeUtils) GetTimeFramesMap(end Timestamp, interval time.Duration) map[
 	}
 	return frames
 }
+
+// region Calendar aware series ----------------------------------------------------------------------------------------
+
+// SeriesOptions controls the generation of calendar aware series and time frames
+type SeriesOptions struct {
+	Location     *time.Location // Time zone of the calendar boundaries (default: the location set by In, UTC if not set)
+	Period       TimePeriodCode // Calendar step: minute, hour, day, week (starts on Monday) or month
+	Count        int            // Number of periods per step (default: 1)
+	Align        bool           // Align the base time to the start of the period (e.g. midnight for daily series)
+	InclusiveEnd bool           // Include the end time when it falls on a series point
+}
+
+// In sets the time zone of the calendar operations (StartOf, AddPeriod and the calendar series), the default is UTC
+func (t *timeUtils) In(loc *time.Location) *timeUtils {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t.loc = loc
+	return t
+}
+
+// StartOf return the start of the calendar period of the timestamp in the time zone (see In), unlike LowerBound the
+// boundaries are calendar boundaries: local midnight, Monday for weeks and the first day of the month
+func (t *timeUtils) StartOf(period TimePeriodCode) *timeUtils {
+	t.baseTime = Timestamp(startOfPeriod(t.time(), period).UnixMilli())
+	return t
+}
+
+// AddPeriod adds n calendar periods to the timestamp in the time zone (see In), days and months are added by the
+// calendar so the local time of day is kept across daylight saving changes
+func (t *timeUtils) AddPeriod(period TimePeriodCode, n int) *timeUtils {
+	t.baseTime = Timestamp(addPeriod(t.time(), period, n).UnixMilli())
+	return t
+}
+
+// GetSeriesIn creates a series of the calendar period boundaries in the time zone, from the start of the period of the
+// base time to the end time. The end time is included only if inclusiveEnd is true and it falls on a period boundary
+func (t *timeUtils) GetSeriesIn(loc *time.Location, end Timestamp, period TimePeriodCode, inclusiveEnd bool) []Timestamp {
+	return t.GetCalendarSeries(end, SeriesOptions{Location: loc, Period: period, Align: true, InclusiveEnd: inclusiveEnd})
+}
+
+// GetTimeFramesIn creates the calendar period time frames in the time zone covering the base time to the end time
+// (e.g. the local days of a daily report)
+func (t *timeUtils) GetTimeFramesIn(loc *time.Location, end Timestamp, period TimePeriodCode) []TimeFrame {
+	return t.GetCalendarTimeFrames(end, SeriesOptions{Location: loc, Period: period, Align: true})
+}
+
+// GetCalendarSeries creates a series from the base time to the end time stepping by calendar periods
+func (t *timeUtils) GetCalendarSeries(end Timestamp, options SeriesOptions) (series []Timestamp) {
+	series = make([]Timestamp, 0)
+	t.calendarSeries(end, options, func(from, _ time.Time) {
+		series = append(series, Timestamp(from.UnixMilli()))
+	})
+	return series
+}
+
+// GetCalendarSeriesMap creates a series from the base time to the end time stepping by calendar periods as a map
+func (t *timeUtils) GetCalendarSeriesMap(end Timestamp, options SeriesOptions) map[Timestamp]int {
+	series := make(map[Timestamp]int)
+	t.calendarSeries(end, options, func(from, _ time.Time) {
+		series[Timestamp(from.UnixMilli())] = 0
+	})
+	return series
+}
+
+// GetCalendarTimeFrames creates time frames of calendar periods from the base time to the end time, each frame starts
+// at a series point and ends at the next one (the last frame may end after the end time)
+func (t *timeUtils) GetCalendarTimeFrames(end Timestamp, options SeriesOptions) (frames []TimeFrame) {
+	frames = make([]TimeFrame, 0)
+	t.calendarSeries(end, options, func(from, to time.Time) {
+		frames = append(frames, NewTimeFrame(Timestamp(from.UnixMilli()), Timestamp(to.UnixMilli())))
+	})
+	return frames
+}
+
+// GetCalendarTimeFramesMap creates time frames of calendar periods from the base time to the end time as a map
+func (t *timeUtils) GetCalendarTimeFramesMap(end Timestamp, options SeriesOptions) map[Timestamp]TimeFrame {
+	frames := make(map[Timestamp]TimeFrame)
+	t.calendarSeries(end, options, func(from, to time.Time) {
+		frames[Timestamp(from.UnixMilli())] = NewTimeFrame(Timestamp(from.UnixMilli()), Timestamp(to.UnixMilli()))
+	})
+	return frames
+}
+
+// iterate the calendar series points, each point is computed from the start time (rather than the previous point) to
+// avoid drift of month days (e.g. Jan 31 + 1 month)
+func (t *timeUtils) calendarSeries(end Timestamp, options SeriesOptions, fn func(from, to time.Time)) {
+	loc := options.Location
+	if loc == nil {
+		loc = t.loc
+	}
+	count := options.Count
+	if count <= 0 {
+		count = 1
+	}
+	if !isCalendarPeriod(options.Period) {
+		return
+	}
+
+	start := time.UnixMilli(int64(t.baseTime)).In(loc)
+	if options.Align {
+		start = startOfPeriod(start, options.Period)
+	}
+	to := time.UnixMilli(int64(end)).In(loc)
+
+	for i := 0; ; i++ {
+		from := addPeriod(start, options.Period, i*count)
+		if from.After(to) || (from.Equal(to) && !options.InclusiveEnd) {
+			return
+		}
+		fn(from, addPeriod(start, options.Period, (i+1)*count))
+	}
+}
+
+// the base time in the time zone
+func (t *timeUtils) time() time.Time {
+	loc := t.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.UnixMilli(int64(t.baseTime)).In(loc)
+}
+
+// start of the calendar period in the time location
+func startOfPeriod(tm time.Time, period TimePeriodCode) time.Time {
+	loc := tm.Location()
+	switch period {
+	case TimePeriodCodes.MINUTE:
+		return time.Date(tm.Year(), tm.Month(), tm.Day(), tm.Hour(), tm.Minute(), 0, 0, loc)
+	case TimePeriodCodes.HOUR:
+		return time.Date(tm.Year(), tm.Month(), tm.Day(), tm.Hour(), 0, 0, 0, loc)
+	case TimePeriodCodes.DAY:
+		return time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, loc)
+	case TimePeriodCodes.WEEK:
+		offset := (int(tm.Weekday()) + 6) % 7
+		return time.Date(tm.Year(), tm.Month(), tm.Day()-offset, 0, 0, 0, 0, loc)
+	case TimePeriodCodes.MONTH:
+		return time.Date(tm.Year(), tm.Month(), 1, 0, 0, 0, 0, loc)
+	default:
+		return tm
+	}
+}
+
+// add n calendar periods
+func addPeriod(tm time.Time, period TimePeriodCode, n int) time.Time {
+	switch period {
+	case TimePeriodCodes.MINUTE:
+		return tm.Add(time.Duration(n) * time.Minute)
+	case TimePeriodCodes.HOUR:
+		return tm.Add(time.Duration(n) * time.Hour)
+	case TimePeriodCodes.DAY:
+		return tm.AddDate(0, 0, n)
+	case TimePeriodCodes.WEEK:
+		return tm.AddDate(0, 0, 7*n)
+	case TimePeriodCodes.MONTH:
+		return tm.AddDate(0, n, 0)
+	default:
+		return tm
+	}
+}
+
+// check if the period is supported by the calendar operations
+func isCalendarPeriod(period TimePeriodCode) bool {
+	return period >= TimePeriodCodes.MINUTE && period <= TimePeriodCodes.MONTH
+}
+
+// endregion
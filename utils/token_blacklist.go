@@ -0,0 +1,140 @@
+// JWT token revocation list
+//
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrTokenRevoked is returned when parsing a revoked token
+var ErrTokenRevoked = errors.New("token is revoked")
+
+const tokenBlacklistPrefix = "revoked-token:"
+
+// TokenRevocationStore stores the revoked token ids, implemented by database.IDataCache
+type TokenRevocationStore interface {
+	SetRaw(key string, bytes []byte, expiration ...time.Duration) error
+	Exists(key string) (result bool, err error)
+}
+
+// region Token Blacklist ----------------------------------------------------------------------------------------------
+
+// TokenBlacklist is a revocation list of token ids (jti) shared by the service instances (e.g. for logout and forced
+// invalidation). Revoked ids are kept until the token expires:
+//
+//	blacklist := utils.NewTokenBlacklist(dataCache)
+//	_ = blacklist.RevokeToken(claims)
+//	claims, err := utils.TokenUtils().ParseToken(token, utils.WithTokenBlacklist(blacklist))
+type TokenBlacklist struct {
+	store TokenRevocationStore
+}
+
+// NewTokenBlacklist creates a token revocation list backed by the store
+func NewTokenBlacklist(store TokenRevocationStore) *TokenBlacklist {
+	return &TokenBlacklist{store: store}
+}
+
+// Revoke the token id for the ttl (should be the remaining lifetime of the token)
+func (b *TokenBlacklist) Revoke(jti string, ttl time.Duration) error {
+	if len(jti) == 0 {
+		return fmt.Errorf("missing token id")
+	}
+	if ttl <= 0 {
+		// The token is already expired
+		return nil
+	}
+	return b.store.SetRaw(tokenBlacklistPrefix+jti, []byte{1}, ttl)
+}
+
+// RevokeToken revokes the token id until the token expiration (tokens without expiration are revoked for the refresh
+// token TTL of the token policy)
+func (b *TokenBlacklist) RevokeToken(claims *jwt.RegisteredClaims) error {
+	ttl := tokenPolicy.RefreshTokenTTL
+	if claims.ExpiresAt != nil {
+		ttl = time.Until(claims.ExpiresAt.Time) + tokenPolicy.Leeway
+	}
+	return b.Revoke(claims.ID, ttl)
+}
+
+// IsRevoked checks if the token id is revoked
+func (b *TokenBlacklist) IsRevoked(jti string) (bool, error) {
+	if len(jti) == 0 {
+		return false, nil
+	}
+	return b.store.Exists(tokenBlacklistPrefix + jti)
+}
+
+// check the token id of the parsed token
+func (b *TokenBlacklist) check(tokenString string) error {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return err
+	}
+	jti, _ := claims["jti"].(string)
+	revoked, err := b.IsRevoked(jti)
+	if err != nil {
+		return fmt.Errorf("check token revocation failed: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// endregion
+
+// region Token parse options ------------------------------------------------------------------------------------------
+
+// TokenParseOption configures the token validation
+type TokenParseOption func(options *tokenParseOptions)
+
+type tokenParseOptions struct {
+	blacklist *TokenBlacklist
+	jwks      *JWKSFetcher
+	ctx       context.Context
+}
+
+// WithTokenBlacklist rejects tokens revoked in the blacklist
+func WithTokenBlacklist(blacklist *TokenBlacklist) TokenParseOption {
+	return func(options *tokenParseOptions) {
+		options.blacklist = blacklist
+	}
+}
+
+// WithJWKS verifies tokens signed by unknown key ids with the keys of the JWKS (e.g. tokens of external identity providers)
+func WithJWKS(jwks *JWKSFetcher) TokenParseOption {
+	return func(options *tokenParseOptions) {
+		options.jwks = jwks
+	}
+}
+
+// WithTokenContext sets the context of the token validation, used to cancel the JWKS fetch (default: background context)
+func WithTokenContext(ctx context.Context) TokenParseOption {
+	return func(options *tokenParseOptions) {
+		options.ctx = ctx
+	}
+}
+
+func newTokenParseOptions(opts []TokenParseOption) *tokenParseOptions {
+	options := &tokenParseOptions{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// validate the parsed token according to the options
+func (o *tokenParseOptions) validate(tokenString string) error {
+	if o.blacklist != nil {
+		return o.blacklist.check(tokenString)
+	}
+	return nil
+}
+
+// endregion
@@ -0,0 +1,415 @@
+// JWT signing keys and JWKS support
+//
+
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a JWT signing key identified by its key id (kid header)
+type SigningKey struct {
+	Id         string            // Key id (kid header)
+	Method     jwt.SigningMethod // Signing method (HS256, RS256, ES256 ...)
+	PrivateKey any               // Signing key: []byte (HMAC), *rsa.PrivateKey or *ecdsa.PrivateKey (nil for verification only keys)
+	PublicKey  any               // Verification key: []byte (HMAC), *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// NewHMACSigningKey creates HS256 signing key
+func NewHMACSigningKey(kid string, secret []byte) SigningKey {
+	return SigningKey{Id: kid, Method: jwt.SigningMethodHS256, PrivateKey: secret, PublicKey: secret}
+}
+
+// NewRSASigningKey creates RS256 signing key
+func NewRSASigningKey(kid string, key *rsa.PrivateKey) SigningKey {
+	return SigningKey{Id: kid, Method: jwt.SigningMethodRS256, PrivateKey: key, PublicKey: &key.PublicKey}
+}
+
+// NewECSigningKey creates ECDSA signing key, the method (ES256, ES384 or ES512) is set by the key curve
+func NewECSigningKey(kid string, key *ecdsa.PrivateKey) (SigningKey, error) {
+	method, err := ecSigningMethod(key.Curve)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{Id: kid, Method: method, PrivateKey: key, PublicKey: &key.PublicKey}, nil
+}
+
+// ParseSigningKeyPEM creates signing key from PEM encoded private key (PKCS#1, PKCS#8 or EC) or public key (PKIX),
+// public keys create verification only keys
+func ParseSigningKeyPEM(kid string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("no PEM data found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported PEM type: %s", block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return NewRSASigningKey(kid, k), nil
+	case *ecdsa.PrivateKey:
+		return NewECSigningKey(kid, k)
+	case *rsa.PublicKey:
+		return SigningKey{Id: kid, Method: jwt.SigningMethodRS256, PublicKey: k}, nil
+	case *ecdsa.PublicKey:
+		method, er := ecSigningMethod(k.Curve)
+		return SigningKey{Id: kid, Method: method, PublicKey: k}, er
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported key type: %T", key)
+	}
+}
+
+// region Signing keys registry ----------------------------------------------------------------------------------------
+
+var (
+	signingKeysMu sync.RWMutex
+	activeKey     *SigningKey
+	signingKeys   = make(map[string]SigningKey)
+)
+
+// SetSigningKeys set the JWT signing keys, the first key signs the new tokens and all the keys verify tokens by their
+// key id (key rotation: keep the previous key for verification until the tokens it signed expire). Calling without keys
+// reverts to HS256 with the shared signing key (see SetSecret)
+func SetSigningKeys(keys ...SigningKey) error {
+	registry := make(map[string]SigningKey)
+	for i, key := range keys {
+		if key.Method == nil || key.PublicKey == nil {
+			return fmt.Errorf("signing key %s: missing method or verification key", key.Id)
+		}
+		if i == 0 && key.PrivateKey == nil {
+			return fmt.Errorf("signing key %s: the active key must have a private key", key.Id)
+		}
+		if _, exists := registry[key.Id]; exists {
+			return fmt.Errorf("duplicate signing key id: %s", key.Id)
+		}
+		registry[key.Id] = key
+	}
+
+	signingKeysMu.Lock()
+	defer signingKeysMu.Unlock()
+	signingKeys = registry
+	activeKey = nil
+	if len(keys) > 0 {
+		active := keys[0]
+		activeKey = &active
+	}
+	return nil
+}
+
+// sign the claims with the active signing key (HS256 with the shared key if not configured)
+func signToken(claims jwt.Claims) (string, error) {
+	signingKeysMu.RLock()
+	key := activeKey
+	signingKeysMu.RUnlock()
+
+	if key == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(tokenSigningKey)
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	if len(key.Id) > 0 {
+		token.Header["kid"] = key.Id
+	}
+	return token.SignedString(key.PrivateKey)
+}
+
+// verification key of the token: configured key by kid, JWKS key by kid or the shared key
+func verificationKey(ctx context.Context, token *jwt.Token, jwks *JWKSFetcher) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	signingKeysMu.RLock()
+	key, found := signingKeys[kid]
+	configured := len(signingKeys) > 0
+	signingKeysMu.RUnlock()
+
+	if !found && jwks != nil && len(kid) > 0 {
+		jwk, err := jwks.Key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		key, found = jwk, true
+	}
+	if found {
+		if key.Method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("token algorithm %s does not match key %s", token.Method.Alg(), kid)
+		}
+		return key.PublicKey, nil
+	}
+	if !configured && token.Method == jwt.SigningMethodHS256 {
+		return tokenSigningKey, nil
+	}
+	return nil, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+func ecSigningMethod(curve elliptic.Curve) (jwt.SigningMethod, error) {
+	switch curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	default:
+		return nil, fmt.Errorf("unsupported elliptic curve: %s", curve.Params().Name)
+	}
+}
+
+// endregion
+
+// region JWKS ---------------------------------------------------------------------------------------------------------
+
+// JWK is a JSON Web Key (RFC 7517) public key
+type JWK struct {
+	Kty string `json:"kty"`           // Key type: RSA or EC
+	Kid string `json:"kid,omitempty"` // Key id
+	Use string `json:"use,omitempty"` // Key use (sig)
+	Alg string `json:"alg,omitempty"` // Signing algorithm
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC curve
+	X   string `json:"x,omitempty"`   // EC x coordinate
+	Y   string `json:"y,omitempty"`   // EC y coordinate
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns the public keys of the configured asymmetric signing keys (e.g. to serve as /.well-known/jwks.json)
+func PublicJWKS() *JWKS {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+
+	result := &JWKS{Keys: make([]JWK, 0, len(signingKeys))}
+	for _, key := range signingKeys {
+		jwk := JWK{Kid: key.Id, Use: "sig", Alg: key.Method.Alg()}
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		default:
+			// Symmetric keys are never published
+			continue
+		}
+		result.Keys = append(result.Keys, jwk)
+	}
+	return result
+}
+
+// SigningKey converts the JWK to a verification only signing key
+func (k JWK) SigningKey() (SigningKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("invalid RSA modulus of key %s", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("invalid RSA exponent of key %s", k.Kid)
+		}
+		method := jwt.GetSigningMethod(k.Alg)
+		if method == nil {
+			method = jwt.SigningMethodRS256
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return SigningKey{Id: k.Kid, Method: method, PublicKey: pub}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return SigningKey{}, fmt.Errorf("unsupported curve %s of key %s", k.Crv, k.Kid)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("invalid EC x coordinate of key %s", k.Kid)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("invalid EC y coordinate of key %s", k.Kid)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		method, _ := ecSigningMethod(curve)
+		return SigningKey{Id: k.Kid, Method: method, PublicKey: pub}, nil
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported key type %s of key %s", k.Kty, k.Kid)
+	}
+}
+
+// JWKSFetcher fetches and caches the JWKS of an external identity provider, the keys are refreshed periodically and
+// when a token is signed by an unknown key id (at most once per minimum refresh interval). A single fetch runs at a
+// time and failed fetches are not retried before the minimum refresh interval
+type JWKSFetcher struct {
+	url        string
+	client     *http.Client
+	refresh    time.Duration
+	minRefresh time.Duration
+	mu         sync.Mutex
+	keys       map[string]SigningKey
+	fetchedAt  time.Time
+	failedAt   time.Time
+	inflight   *jwksFetch
+}
+
+// in-flight fetch of the key set, done is closed when the fetch is completed
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKSFetcher creates JWKS fetcher of the URL (e.g. https://idp.example.com/.well-known/jwks.json) with the refresh
+// interval (default: 1 hour)
+func NewJWKSFetcher(url string, refresh time.Duration) *JWKSFetcher {
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+	return &JWKSFetcher{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		refresh:    refresh,
+		minRefresh: 30 * time.Second,
+		keys:       make(map[string]SigningKey),
+	}
+}
+
+// Key returns the verification key by key id, the cached key is returned if the refresh of the key set fails
+func (f *JWKSFetcher) Key(ctx context.Context, kid string) (SigningKey, error) {
+	f.mu.Lock()
+	key, found := f.keys[kid]
+	age := time.Since(f.fetchedAt)
+	due := age > f.refresh || (!found && age > f.minRefresh)
+	if !due || time.Since(f.failedAt) <= f.minRefresh {
+		f.mu.Unlock()
+		return jwksKey(key, found, kid, nil)
+	}
+
+	// Join the in-flight fetch or start a new one
+	fetch := f.inflight
+	if fetch == nil {
+		fetch = &jwksFetch{done: make(chan struct{})}
+		f.inflight = fetch
+		f.mu.Unlock()
+		f.refreshKeys(ctx, fetch)
+	} else {
+		f.mu.Unlock()
+	}
+
+	select {
+	case <-fetch.done:
+	case <-ctx.Done():
+		return jwksKey(key, found, kid, ctx.Err())
+	}
+
+	f.mu.Lock()
+	key, found = f.keys[kid]
+	f.mu.Unlock()
+	return jwksKey(key, found, kid, fetch.err)
+}
+
+// result of the key lookup, the fetch error is reported only if the key is not found
+func jwksKey(key SigningKey, found bool, kid string, err error) (SigningKey, error) {
+	if found {
+		return key, nil
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{}, fmt.Errorf("unknown signing key: %s", kid)
+}
+
+// fetch the key set (without holding the lock) and complete the in-flight fetch
+func (f *JWKSFetcher) refreshKeys(ctx context.Context, fetch *jwksFetch) {
+	keys, err := f.fetch(ctx)
+
+	f.mu.Lock()
+	if err == nil {
+		f.keys = keys
+		f.fetchedAt = time.Now()
+	} else if ctx.Err() == nil {
+		// Back off only when the identity provider fails, not when the caller gives up
+		f.failedAt = time.Now()
+	}
+	f.inflight = nil
+	f.mu.Unlock()
+
+	fetch.err = err
+	close(fetch.done)
+}
+
+// fetch the key set
+func (f *JWKSFetcher) fetch(ctx context.Context) (map[string]SigningKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS failed: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS failed with status %d", resp.StatusCode)
+	}
+
+	jwks := &JWKS{}
+	if err = json.NewDecoder(resp.Body).Decode(jwks); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := make(map[string]SigningKey)
+	for _, jwk := range jwks.Keys {
+		if len(jwk.Use) > 0 && jwk.Use != "sig" {
+			continue
+		}
+		if key, er := jwk.SigningKey(); er == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// endregion
@@ -6,13 +6,32 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/go-yaaf/yaaf-common/entity"
+)
+
+// ErrInvalidApiKey is returned when the API key is malformed or was tampered with
+var ErrInvalidApiKey = errors.New("invalid API key")
+
+// API key cipher text version prefix (AES-GCM), legacy API keys (AES-CFB) have no prefix
+const (
+	apiKeyVersion   = "v2"
+	apiKeySeparator = "."
 )
 
 // Secret key to encode API keys (must be 32 characters)
@@ -38,6 +57,46 @@ func SetSecret(secret, vector []byte) error {
 	return nil
 }
 
+// rejectLegacyApiKeys disables parsing of legacy (AES-CFB) API keys, they are accepted by default during migration
+var rejectLegacyApiKeys atomic.Bool
+
+// SetLegacyApiKeys enable or disable parsing of legacy (AES-CFB) API keys by ParseApiKey (default: enabled).
+// Disable it once all the API keys were re-issued using MigrateApiKey
+func SetLegacyApiKeys(enabled bool) {
+	rejectLegacyApiKeys.Store(!enabled)
+}
+
+// TokenPolicy defines the expiration policy of the issued tokens
+type TokenPolicy struct {
+	AccessTokenTTL  time.Duration // Access token expiration (default: 15 minutes)
+	RefreshTokenTTL time.Duration // Refresh token expiration (default: 7 days)
+	Issuer          string        // Issuer of the token pairs (optional)
+	Audience        []string      // Audience of the token pairs (optional)
+	Leeway          time.Duration // Clock skew tolerance when validating the token times
+}
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+
+	// claim marking refresh tokens
+	claimTokenUse   = "token_use"
+	tokenUseRefresh = "refresh"
+)
+
+var tokenPolicy = TokenPolicy{AccessTokenTTL: defaultAccessTokenTTL, RefreshTokenTTL: defaultRefreshTokenTTL}
+
+// SetTokenPolicy set the expiration policy of the issued tokens, zero TTLs use the defaults
+func SetTokenPolicy(policy TokenPolicy) {
+	if policy.AccessTokenTTL <= 0 {
+		policy.AccessTokenTTL = defaultAccessTokenTTL
+	}
+	if policy.RefreshTokenTTL <= 0 {
+		policy.RefreshTokenTTL = defaultRefreshTokenTTL
+	}
+	tokenPolicy = policy
+}
+
 // endregion
 
 // region Singleton Pattern --------------------------------------------------------------------------------------------
@@ -63,25 +122,151 @@ func TokenUtils() *TokenUtilsStruct {
 
 // CreateToken build JWT token from Token Data structure
 func (t *TokenUtilsStruct) CreateToken(claims *jwt.RegisteredClaims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(tokenSigningKey)
+	return t.CreateTokenWithClaims(claims)
 }
 
 // ParseToken rebuild Token Data structure from JWT token
-func (t *TokenUtilsStruct) ParseToken(tokenString string) (*jwt.RegisteredClaims, error) {
+func (t *TokenUtilsStruct) ParseToken(tokenString string, opts ...TokenParseOption) (*jwt.RegisteredClaims, error) {
 
 	rc := &jwt.RegisteredClaims{}
-	_, err := jwt.ParseWithClaims(tokenString, rc, func(token *jwt.Token) (interface{}, error) {
-		return tokenSigningKey, nil
-	})
-
-	if err != nil {
+	if err := t.ParseTokenWithClaims(tokenString, rc, opts...); err != nil {
 		return nil, err
 	} else {
 		return rc, nil
 	}
 }
 
+// CreateTokenWithClaims build JWT token from custom claims structure (e.g. struct embedding jwt.RegisteredClaims)
+func (t *TokenUtilsStruct) CreateTokenWithClaims(claims jwt.Claims) (string, error) {
+	return signToken(claims)
+}
+
+// ParseTokenWithClaims validate JWT token and populate the custom claims structure. The verification key is selected
+// by the token kid header from the signing keys (see SetSigningKeys) or from the JWKS (see WithJWKS)
+func (t *TokenUtilsStruct) ParseTokenWithClaims(tokenString string, claims jwt.Claims, opts ...TokenParseOption) error {
+	options := newTokenParseOptions(opts)
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return verificationKey(options.ctx, token, options.jwks)
+	}, jwt.WithValidMethods(accessTokenMethods), jwt.WithLeeway(tokenPolicy.Leeway))
+	if err != nil {
+		return err
+	}
+	return options.validate(tokenString)
+}
+
+// endregion
+
+// region Access / Refresh token pairs ---------------------------------------------------------------------------------
+
+// TokenPair is an access token and the refresh token used to issue the next pair
+type TokenPair struct {
+	AccessToken      string    `json:"accessToken"`      // Short-lived access token
+	AccessExpiresAt  time.Time `json:"accessExpiresAt"`  // Access token expiration
+	RefreshToken     string    `json:"refreshToken"`     // Long-lived refresh token
+	RefreshExpiresAt time.Time `json:"refreshExpiresAt"` // Refresh token expiration
+	RefreshTokenId   string    `json:"refreshTokenId"`   // Refresh token id (jti)
+}
+
+// CreateTokenPair issue access and refresh tokens for the claims according to the token policy, the custom claims
+// (optional) are added to both tokens. Refresh tokens are signed with a dedicated key, so they are not accepted as
+// access tokens by ParseToken
+func (t *TokenUtilsStruct) CreateTokenPair(claims *jwt.RegisteredClaims, custom map[string]any) (*TokenPair, error) {
+	base := jwt.MapClaims{}
+	for k, v := range custom {
+		base[k] = v
+	}
+	if len(claims.Subject) > 0 {
+		base["sub"] = claims.Subject
+	}
+	issuer := claims.Issuer
+	if len(issuer) == 0 {
+		issuer = tokenPolicy.Issuer
+	}
+	if len(issuer) > 0 {
+		base["iss"] = issuer
+	}
+	audience := claims.Audience
+	if len(audience) == 0 {
+		audience = tokenPolicy.Audience
+	}
+	if len(audience) > 0 {
+		base["aud"] = audience
+	}
+	return t.issueTokenPair(base, claims.ID)
+}
+
+// RefreshTokenPair validate the refresh token and issue a new pair with the same subject and claims (refresh token
+// rotation). When a token blacklist is provided the previous refresh token is revoked to prevent reuse, otherwise the
+// caller should revoke the previous refresh token id
+func (t *TokenUtilsStruct) RefreshTokenPair(refreshToken string, opts ...TokenParseOption) (*TokenPair, error) {
+	claims, err := t.ParseRefreshToken(refreshToken, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if blacklist := newTokenParseOptions(opts).blacklist; blacklist != nil {
+		jti, _ := claims["jti"].(string)
+		exp, _ := claims.GetExpirationTime()
+		if err = blacklist.RevokeToken(&jwt.RegisteredClaims{ID: jti, ExpiresAt: exp}); err != nil {
+			return nil, err
+		}
+	}
+	base := jwt.MapClaims{}
+	for k, v := range claims {
+		switch k {
+		case "jti", "iat", "nbf", "exp", claimTokenUse:
+		default:
+			base[k] = v
+		}
+	}
+	return t.issueTokenPair(base, "")
+}
+
+// ParseRefreshToken validate the refresh token and return its claims
+func (t *TokenUtilsStruct) ParseRefreshToken(refreshToken string, opts ...TokenParseOption) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if err := parseSignedToken(refreshToken, claims, refreshSigningKey()); err != nil {
+		return nil, err
+	}
+	if claims[claimTokenUse] != tokenUseRefresh {
+		return nil, fmt.Errorf("not a refresh token")
+	}
+	if err := newTokenParseOptions(opts).validate(refreshToken); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// issue access and refresh tokens from the base claims
+func (t *TokenUtilsStruct) issueTokenPair(base jwt.MapClaims, accessId string) (*TokenPair, error) {
+	now := time.Now()
+	pair := &TokenPair{
+		AccessExpiresAt:  now.Add(tokenPolicy.AccessTokenTTL),
+		RefreshExpiresAt: now.Add(tokenPolicy.RefreshTokenTTL),
+		RefreshTokenId:   entity.NanoID(),
+	}
+	if len(accessId) == 0 {
+		accessId = entity.NanoID()
+	}
+
+	access := jwt.MapClaims{"jti": accessId, "iat": now.Unix(), "exp": pair.AccessExpiresAt.Unix()}
+	refresh := jwt.MapClaims{"jti": pair.RefreshTokenId, "iat": now.Unix(), "exp": pair.RefreshExpiresAt.Unix(), claimTokenUse: tokenUseRefresh}
+	for k, v := range base {
+		if _, reserved := access[k]; !reserved {
+			access[k] = v
+			refresh[k] = v
+		}
+	}
+
+	var err error
+	if pair.AccessToken, err = signToken(access); err != nil {
+		return nil, err
+	}
+	if pair.RefreshToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, refresh).SignedString(refreshSigningKey()); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
 // endregion
 
 // region API Key parsing helpers --------------------------------------------------------------------------------------
@@ -91,38 +276,111 @@ func (t *TokenUtilsStruct) CreateApiKey(appName string) (string, error) {
 	return t.encrypt(appName)
 }
 
-// ParseApiKey extract application name from API key
+// ParseApiKey extract application name from API key. Legacy (AES-CFB) API keys are not protected against tampering,
+// they are accepted during migration (see SetLegacyApiKeys) and should be re-issued using MigrateApiKey
 func (t *TokenUtilsStruct) ParseApiKey(apiKey string) (string, error) {
-	return t.decrypt(apiKey)
+	if !t.IsLegacyApiKey(apiKey) {
+		return t.decrypt(apiKey)
+	}
+	if rejectLegacyApiKeys.Load() {
+		return "", ErrInvalidApiKey
+	}
+	appName, err := t.decryptLegacy(apiKey)
+	if err != nil || !isApplicationName(appName) {
+		return "", ErrInvalidApiKey
+	}
+	return appName, nil
+}
+
+// IsLegacyApiKey checks if the API key was created with the legacy (AES-CFB) encryption
+func (t *TokenUtilsStruct) IsLegacyApiKey(apiKey string) bool {
+	return !strings.HasPrefix(apiKey, apiKeyVersion+apiKeySeparator)
+}
+
+// MigrateApiKey re-encrypt legacy API key with the current encryption, current API keys are returned as is.
+// Legacy API keys can't be verified (any hex string is decrypted to some value), so the application name of the
+// migrated key must be validated by the caller
+func (t *TokenUtilsStruct) MigrateApiKey(apiKey string) (string, error) {
+	if !t.IsLegacyApiKey(apiKey) {
+		return apiKey, nil
+	}
+	appName, err := t.decryptLegacy(apiKey)
+	if err != nil {
+		return "", err
+	}
+	return t.encrypt(appName)
 }
 
 // endregion
 
 // region PRIVATE SECTION ----------------------------------------------------------------------------------------------
 
-// encrypt string using AES and return base64
-func (t *TokenUtilsStruct) encrypt(value string) (string, error) {
+// access token signing algorithms
+var accessTokenMethods = []string{"HS256", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
 
-	block, err := aes.NewCipher(tokenApiSecret)
+// parse and validate HS256 signed token
+func parseSignedToken(tokenString string, claims jwt.Claims, key []byte) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithLeeway(tokenPolicy.Leeway))
+	return err
+}
+
+// refresh tokens signing key derived from the signing key
+func refreshSigningKey() []byte {
+	mac := hmac.New(sha256.New, tokenSigningKey)
+	mac.Write([]byte("refresh-token"))
+	return mac.Sum(nil)
+}
+
+// encrypt string using AES-GCM and return the versioned hex cipher text (nonce followed by the sealed value)
+func (t *TokenUtilsStruct) encrypt(value string) (string, error) {
+	gcm, err := newApiKeyCipher()
 	if err != nil {
 		return "", err
 	}
 
-	// Generate a new random IV
-	cipherText := make([]byte, aes.BlockSize+len(value))
-	iv := cipherText[:aes.BlockSize]
-	if _, er := io.ReadFull(rand.Reader, iv); er != nil {
+	// Generate a new random nonce
+	nonce := make([]byte, gcm.NonceSize())
+	if _, er := io.ReadFull(rand.Reader, nonce); er != nil {
 		return "", er
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
-	stream.XORKeyStream(cipherText[aes.BlockSize:], []byte(value))
-
-	return hex.EncodeToString(cipherText), nil
+	cipherText := gcm.Seal(nonce, nonce, []byte(value), []byte(apiKeyVersion))
+	return apiKeyVersion + apiKeySeparator + hex.EncodeToString(cipherText), nil
 }
 
-// decrypt base64 string using AES
+// decrypt versioned AES-GCM cipher text
 func (t *TokenUtilsStruct) decrypt(value string) (string, error) {
+	encoded, versioned := strings.CutPrefix(value, apiKeyVersion+apiKeySeparator)
+	if !versioned {
+		return "", ErrInvalidApiKey
+	}
+
+	cipherTextBytes, err := hex.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidApiKey
+	}
+
+	gcm, err := newApiKeyCipher()
+	if err != nil {
+		return "", err
+	}
+
+	if len(cipherTextBytes) < gcm.NonceSize()+gcm.Overhead() {
+		return "", ErrInvalidApiKey
+	}
+
+	nonce := cipherTextBytes[:gcm.NonceSize()]
+	plainText, err := gcm.Open(nil, nonce, cipherTextBytes[gcm.NonceSize():], []byte(apiKeyVersion))
+	if err != nil {
+		return "", ErrInvalidApiKey
+	}
+	return string(plainText), nil
+}
+
+// decrypt legacy (unauthenticated) AES-CFB hex string
+func (t *TokenUtilsStruct) decryptLegacy(value string) (string, error) {
 	cipherTextBytes, err := hex.DecodeString(value)
 	if err != nil {
 		return "", err
@@ -146,4 +404,26 @@ func (t *TokenUtilsStruct) decrypt(value string) (string, error) {
 	return string(cipherTextBytes), nil
 }
 
+// isApplicationName rejects legacy API keys decrypted to garbage (e.g. forged or tampered keys)
+func isApplicationName(value string) bool {
+	if len(value) == 0 || !utf8.ValidString(value) {
+		return false
+	}
+	for _, r := range value {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// AES-GCM cipher of the API keys secret
+func newApiKeyCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(tokenApiSecret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // endregion
@@ -0,0 +1,296 @@
+// Package workers provides supervised background workers
+//
+// Services register named workers (func(ctx) error) with a restart policy and concurrency level, the workers manager
+// starts them with the service, restarts failed workers according to the policy, reports their health and stops them
+// gracefully when the service shuts down:
+//
+//	manager := workers.NewManager()
+//	_ = manager.Register("usage-aggregator", aggregator.Run, workers.WorkerOptions{Restart: workers.RestartOnFailure})
+//	_ = manager.Start(ctx)
+//	defer manager.Close()
+package workers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-yaaf/yaaf-common/utils"
+)
+
+// WorkerFunc is the worker main function, it should return when the context is canceled
+type WorkerFunc func(ctx context.Context) error
+
+// RestartPolicy defines when a worker is restarted after it returned
+type RestartPolicy int
+
+const (
+	// RestartNever never restart the worker
+	RestartNever RestartPolicy = iota
+	// RestartOnFailure restart the worker only if it returned an error (or panicked)
+	RestartOnFailure
+	// RestartAlways restart the worker whenever it returned
+	RestartAlways
+)
+
+// WorkerState is the current state of a worker
+type WorkerState string
+
+const (
+	WorkerStateIdle     WorkerState = "idle"     // The worker was registered but not started yet
+	WorkerStateRunning  WorkerState = "running"  // All the worker instances are running
+	WorkerStateStopped  WorkerState = "stopped"  // The worker completed or stopped by the manager
+	WorkerStateDegraded WorkerState = "degraded" // Some worker instances are waiting for restart
+	WorkerStateFailed   WorkerState = "failed"   // The worker failed and will not be restarted
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultMaxRestartBackoff = time.Minute
+)
+
+// WorkerOptions configures the worker supervision
+type WorkerOptions struct {
+	Concurrency int           // Number of worker instances (default: 1)
+	Restart     RestartPolicy // Restart policy (default: RestartNever)
+	MaxRestarts int           // Max number of restarts per instance (0 for unlimited)
+	Backoff     time.Duration // Initial delay before restart, doubled after each restart (default: 1 second)
+	MaxBackoff  time.Duration // Max delay before restart (default: 1 minute)
+}
+
+// WorkerStatus is the health report of a worker
+type WorkerStatus struct {
+	Name      string      `json:"name"`                // Worker name
+	State     WorkerState `json:"state"`               // Worker state
+	Running   int         `json:"running"`             // Number of running instances
+	Restarts  int         `json:"restarts"`            // Total number of restarts
+	LastError string      `json:"lastError,omitempty"` // The last error returned by the worker
+	StartedOn time.Time   `json:"startedOn"`           // Last time the worker was started
+}
+
+// region Manager ------------------------------------------------------------------------------------------------------
+
+// Manager supervises the registered workers
+type Manager struct {
+	mu      sync.RWMutex
+	workers map[string]*worker
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// worker holds the registration and runtime state of a named worker
+type worker struct {
+	name    string
+	run     WorkerFunc
+	options WorkerOptions
+	status  WorkerStatus
+	failed  int
+}
+
+// NewManager creates a new workers manager
+func NewManager() *Manager {
+	return &Manager{workers: make(map[string]*worker)}
+}
+
+// Register adds a named worker, workers registered after Start are started immediately
+func (m *Manager) Register(name string, run WorkerFunc, options WorkerOptions) error {
+	if run == nil {
+		return fmt.Errorf("worker %s function is nil", name)
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 1
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = defaultRestartBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultMaxRestartBackoff
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.workers[name]; exists {
+		return fmt.Errorf("worker %s already registered", name)
+	}
+
+	w := &worker{name: name, run: run, options: options, status: WorkerStatus{Name: name, State: WorkerStateIdle}}
+	m.workers[name] = w
+
+	if m.started {
+		m.startWorker(m.ctx, w)
+	}
+	return nil
+}
+
+// Start all the registered workers, the workers are stopped when the context is canceled or Stop is called
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started {
+		return fmt.Errorf("workers manager already started")
+	}
+	m.started = true
+
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	for _, w := range m.workers {
+		m.startWorker(m.ctx, w)
+	}
+	return nil
+}
+
+// Stop signals all the workers to stop and waits until they return or until the timeout expires (0 for no timeout)
+func (m *Manager) Stop(timeout time.Duration) error {
+	m.mu.Lock()
+	cancel := m.cancel
+	m.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	if timeout <= 0 {
+		<-done
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("workers did not stop within %s", timeout)
+	}
+}
+
+// Close stops all the workers (implements io.Closer)
+func (m *Manager) Close() error {
+	return m.Stop(0)
+}
+
+// Health returns the status of all the workers sorted by name
+func (m *Manager) Health() []WorkerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list := make([]WorkerStatus, 0, len(m.workers))
+	for _, w := range m.workers {
+		list = append(list, w.status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Healthy returns false if any of the workers failed or degraded
+func (m *Manager) Healthy() bool {
+	for _, status := range m.Health() {
+		if status.State == WorkerStateFailed || status.State == WorkerStateDegraded {
+			return false
+		}
+	}
+	return true
+}
+
+// endregion
+
+// region Supervision --------------------------------------------------------------------------------------------------
+
+// start all the worker instances (the caller must hold the lock)
+func (m *Manager) startWorker(ctx context.Context, w *worker) {
+	w.status.State = WorkerStateRunning
+	w.status.StartedOn = time.Now()
+	for i := 0; i < w.options.Concurrency; i++ {
+		m.wg.Add(1)
+		go m.supervise(ctx, w)
+	}
+}
+
+// run a single worker instance and restart it according to the restart policy
+func (m *Manager) supervise(ctx context.Context, w *worker) {
+	defer m.wg.Done()
+
+	backoff := w.options.Backoff
+	for restarts := 0; ; restarts++ {
+		m.update(w, func() { w.status.Running += 1 })
+		err := utils.RunSafe(func() error { return w.run(ctx) })
+		m.update(w, func() {
+			w.status.Running -= 1
+			if err != nil {
+				w.status.LastError = err.Error()
+			}
+		})
+
+		// Check if the worker should be restarted
+		if ctx.Err() != nil || !shouldRestart(w.options, err, restarts) {
+			m.update(w, func() {
+				if err != nil && ctx.Err() == nil {
+					w.failed += 1
+				}
+				if w.status.Running == 0 {
+					if w.failed > 0 {
+						w.status.State = WorkerStateFailed
+					} else {
+						w.status.State = WorkerStateStopped
+					}
+				}
+			})
+			return
+		}
+
+		m.update(w, func() { w.status.State = WorkerStateDegraded })
+		select {
+		case <-ctx.Done():
+			m.update(w, func() {
+				if w.status.Running == 0 {
+					w.status.State = WorkerStateStopped
+				}
+			})
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > w.options.MaxBackoff {
+			backoff = w.options.MaxBackoff
+		}
+		m.update(w, func() {
+			w.status.Restarts += 1
+			w.status.State = WorkerStateRunning
+			w.status.StartedOn = time.Now()
+		})
+	}
+}
+
+// update worker status under lock
+func (m *Manager) update(w *worker, fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+}
+
+// check if the worker should be restarted by its policy
+func shouldRestart(options WorkerOptions, err error, restarts int) bool {
+	if options.MaxRestarts > 0 && restarts >= options.MaxRestarts {
+		return false
+	}
+	switch options.Restart {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// endregion